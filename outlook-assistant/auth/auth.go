@@ -1,39 +1,126 @@
 package auth
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity/cache"
+	"github.com/microsoft/kiota-abstractions-go/authentication"
 	auth "github.com/microsoft/kiota-authentication-azure-go"
+	khttp "github.com/microsoft/kiota-http-go"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphgocore "github.com/microsoftgraph/msgraph-sdk-go-core"
+
+	"outlook-assistant/retry"
+	"outlook-assistant/schema"
 )
 
+// scopes are requested by every delegated sign-in: what the mail, calendar,
+// contacts, and settings commands need, none of which needs admin consent.
 var scopes = []string{
 	"Mail.ReadWrite",
 	"Mail.Send",
 	"Calendars.ReadWrite",
+	"Contacts.ReadWrite",
+	"People.Read",
+	"MailboxSettings.ReadWrite",
 	"User.Read",
+	"User.ReadBasic.All",
+}
+
+// commandScopes are requested in addition to scopes only when a command that
+// needs them runs, keyed by group or by "group action". Some need admin
+// consent, and a tenant that has not granted them must still be able to sign
+// in for every other command.
+var commandScopes = map[string][]string{
+	"people":                {"GroupMember.Read.All"},
+	"calendar meeting-info": {"OnlineMeetings.Read"},
 }
 
+// ArtifactScopes read a Teams meeting's recordings and transcripts. Both need
+// admin consent, so calendar meeting-info asks for them with a client of their
+// own and goes without recordings and transcripts when they are not granted.
+var ArtifactScopes = []string{"OnlineMeetingRecording.Read.All", "OnlineMeetingTranscript.Read.All"}
+
+// CommandScopes returns the delegated scopes the command group action needs
+// beyond those every sign-in requests, for Config.Scopes.
+func CommandScopes(group, action string) []string {
+	if s, ok := commandScopes[group+" "+action]; ok {
+		return s
+	}
+	return commandScopes[group]
+}
+
+// sharedScopes are requested in addition to scopes when a delegated sign-in
+// acts on another mailbox, such as a shared mailbox or one the user is a
+// delegate of. Exchange still decides which mailboxes the user may open.
+var sharedScopes = []string{
+	"Mail.ReadWrite.Shared",
+	"Mail.Send.Shared",
+	"Calendars.ReadWrite.Shared",
+	"Contacts.ReadWrite.Shared",
+}
+
+// appScopes requests the application permissions granted to the app or
+// managed identity; app-only tokens cannot ask for individual scopes.
+var appScopes = []string{"https://graph.microsoft.com/.default"}
+
+// Auth modes accepted by --auth.
+const (
+	// ModeDelegated signs in a user through the browser (the default).
+	ModeDelegated = "delegated"
+	// ModeDeviceCode signs in a user with a code entered in a browser on any
+	// other device, for SSH sessions and CI runners that cannot open one.
+	// Tokens and the auth record are kept as with ModeDelegated.
+	ModeDeviceCode = "device-code"
+	// ModeManagedIdentity uses the Azure managed identity of the VM, Function,
+	// or container the tool runs on. It is app-only: no user signs in and no
+	// secret or token is stored locally.
+	ModeManagedIdentity = "managed-identity"
+	// ModeClientCredentials authenticates as the app registration itself, with
+	// the certificate at ClientCertPath or else ClientSecret. It is app-only,
+	// for automation service accounts on hosts without a managed identity.
+	ModeClientCredentials = "client-credentials"
+)
+
+// GraphURLEnv names the environment variable that sends every request to
+// another Graph endpoint, such as `devtools mock-server`, without signing in.
+const GraphURLEnv = "OUTLOOK_ASSISTANT_GRAPH_URL"
+
 const authRecordFile = ".outlook-assistant-auth.json"
 
-func recordPath() (string, error) {
+// recordPath returns the auth record location for a profile. The default
+// profile ("") uses authRecordFile; any other profile, such as a tenant passed
+// with --tenant, gets its own file so switching tenants never reuses or
+// overwrites another tenant's sign-in.
+func recordPath(profile string) (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("could not find home directory: %w", err)
 	}
-	return filepath.Join(home, authRecordFile), nil
+	if profile == "" {
+		return filepath.Join(home, authRecordFile), nil
+	}
+	return filepath.Join(home, fmt.Sprintf(".outlook-assistant-auth.%s.json", profileName(profile))), nil
+}
+
+// profileName makes a profile (a tenant ID or domain) safe for file and cache names.
+func profileName(profile string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(profile))
 }
 
-func loadRecord() (azidentity.AuthenticationRecord, error) {
+func loadRecord(profile string) (azidentity.AuthenticationRecord, error) {
 	record := azidentity.AuthenticationRecord{}
-	path, err := recordPath()
+	path, err := recordPath(profile)
 	if err != nil {
 		return record, err
 	}
@@ -46,8 +133,8 @@ func loadRecord() (azidentity.AuthenticationRecord, error) {
 	return record, err
 }
 
-func saveRecord(record azidentity.AuthenticationRecord) error {
-	path, err := recordPath()
+func saveRecord(profile string, record azidentity.AuthenticationRecord) error {
+	path, err := recordPath(profile)
 	if err != nil {
 		return err
 	}
@@ -58,56 +145,308 @@ func saveRecord(record azidentity.AuthenticationRecord) error {
 	return os.WriteFile(path, b, 0600)
 }
 
+// Config selects the auth mode, tenant, auth record, and token store for a Graph client.
+type Config struct {
+	// Mode is ModeDelegated, ModeDeviceCode, ModeManagedIdentity, or
+	// ModeClientCredentials; "" means ModeDelegated.
+	Mode string
+	// ManagedIdentityID is the client ID of a user-assigned managed identity;
+	// "" uses the system-assigned identity. Only used with ModeManagedIdentity.
+	ManagedIdentityID string
+	// ClientCertPath is a PEM or PKCS#12 file holding the app's certificate
+	// and private key, decrypted with ClientCertPassword if it is protected.
+	// ClientSecret is used when no certificate is given. Both are only used
+	// with ModeClientCredentials.
+	ClientCertPath     string
+	ClientCertPassword string
+	ClientSecret       string
+
+	ClientID string
+	TenantID string
+	// Profile selects a separate auth record and token cache; "" is the
+	// default, or the tenant when TENANT_ID is overridden for one invocation.
+	Profile string
+	// TokenStore is StoreAuto, StoreKeychain, StoreFile, or StoreMemory; "" means StoreAuto.
+	TokenStore string
+	// GraphURL replaces https://graph.microsoft.com/v1.0 and turns off
+	// authentication; it is meant for a mock server. "" means Graph itself.
+	GraphURL string
+	// SharedMailbox requests sharedScopes as well, for a delegated sign-in
+	// acting on a mailbox other than the user's own.
+	SharedMailbox bool
+	// Scopes are delegated scopes requested on top of the usual ones, from
+	// CommandScopes; consent to them is asked for when they are first used.
+	Scopes []string
+	// MaxRetries is how often a throttled or transiently failed request is
+	// re-sent; 0 turns retrying off.
+	MaxRetries int
+}
+
 // NewGraphClient returns an authenticated Microsoft Graph client.
-// On first run the user is prompted to log in via browser; subsequent runs
-// reuse the cached token without any browser interaction.
-func NewGraphClient(clientID, tenantID string) (*msgraphsdk.GraphServiceClient, error) {
-	record, err := loadRecord()
+// On first run the user is prompted to log in via browser, or with a device
+// code; subsequent runs reuse the cached token without any interaction.
+// Any extra middleware is appended to the default Graph HTTP pipeline, after
+// the retry handler, so it sees every attempt sent over the wire.
+func NewGraphClient(cfg Config, middleware ...khttp.Middleware) (*msgraphsdk.GraphServiceClient, error) {
+	if cfg.GraphURL != "" {
+		// No token is requested or sent: a mock server has no use for one,
+		// and a real one must never reach an endpoint other than Graph.
+		return newAdapterClient(&authentication.AnonymousAuthenticationProvider{}, cfg.GraphURL, cfg.MaxRetries, middleware)
+	}
+	switch cfg.Mode {
+	case "", ModeDelegated, ModeDeviceCode:
+	case ModeManagedIdentity:
+		cred, err := newManagedIdentityCredential(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return newClient(cred, appScopes, cfg.MaxRetries, middleware)
+	case ModeClientCredentials:
+		cred, err := newClientCredential(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return newClient(cred, appScopes, cfg.MaxRetries, middleware)
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q — valid modes: delegated, device-code, managed-identity, client-credentials", cfg.Mode)
+	}
+
+	record, err := loadRecord(cfg.Profile)
 	if err != nil {
 		return nil, fmt.Errorf("loading auth record: %w", err)
 	}
 
-	persistentCache, err := cache.New(nil)
+	store, keychain, _, err := resolveStore(cfg.TokenStore, cfg.Profile)
 	if err != nil {
-		// Persistent caching unavailable in this environment; fall back to memory-only.
-		persistentCache = azidentity.Cache{}
+		return nil, err
+	}
+	var cred azcore.TokenCredential
+	if store == StoreFile {
+		cred, err = newFileCredential(cfg, record)
+	} else {
+		// keychain is the zero (memory-only) cache for StoreMemory.
+		cred, err = newIdentityCredential(cfg, record, keychain)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(cred, cfg.delegatedScopes(), cfg.MaxRetries, middleware)
+}
 
-	cred, err := azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
-		ClientID:             clientID,
-		TenantID:             tenantID,
-		RedirectURL:          "http://localhost:4321",
-		AuthenticationRecord: record,
-		Cache:                persistentCache,
-	})
+// signInScopes returns the scopes a delegated sign-in requests up front.
+func (cfg Config) signInScopes() []string {
+	if !cfg.SharedMailbox {
+		return scopes
+	}
+	return append(append([]string{}, scopes...), sharedScopes...)
+}
+
+// delegatedScopes returns the scopes a delegated client's tokens are
+// requested for: signInScopes plus the command's own Scopes.
+func (cfg Config) delegatedScopes() []string {
+	return append(append([]string{}, cfg.signInScopes()...), cfg.Scopes...)
+}
+
+// AppOnly reports whether cfg authenticates as an application rather than a
+// signed-in user, so there is no /me and a mailbox must be named explicitly.
+func (cfg Config) AppOnly() bool {
+	return cfg.Mode == ModeManagedIdentity || cfg.Mode == ModeClientCredentials
+}
+
+// newManagedIdentityCredential returns a credential for the host's managed identity.
+func newManagedIdentityCredential(cfg Config) (azcore.TokenCredential, error) {
+	opts := &azidentity.ManagedIdentityCredentialOptions{}
+	if cfg.ManagedIdentityID != "" {
+		opts.ID = azidentity.ClientID(cfg.ManagedIdentityID)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(opts)
 	if err != nil {
-		return nil, fmt.Errorf("creating credential: %w", err)
-	}
-
-	// If no record was stored, authenticate now and save the record so future
-	// invocations skip the browser entirely.
-	if record == (azidentity.AuthenticationRecord{}) {
-		fmt.Fprintln(os.Stderr, "Opening browser for authentication…")
-		newRecord, authErr := cred.Authenticate(context.Background(), &policy.TokenRequestOptions{
-			Scopes: scopes,
-		})
-		if authErr != nil {
-			return nil, fmt.Errorf("authenticating: %w", authErr)
+		return nil, fmt.Errorf("creating managed identity credential: %w", err)
+	}
+	return cred, nil
+}
+
+// newClientCredential returns a credential for the app registration, from its
+// certificate if one is configured and from its client secret otherwise.
+func newClientCredential(cfg Config) (azcore.TokenCredential, error) {
+	if cfg.ClientCertPath != "" {
+		data, err := os.ReadFile(cfg.ClientCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading client certificate: %w", err)
+		}
+		var password []byte
+		if cfg.ClientCertPassword != "" {
+			password = []byte(cfg.ClientCertPassword)
 		}
-		if saveErr := saveRecord(newRecord); saveErr != nil {
-			fmt.Fprintf(os.Stderr, "warning: could not save auth record: %v\n", saveErr)
+		certs, key, err := azidentity.ParseCertificates(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("parsing client certificate %s: %w", cfg.ClientCertPath, err)
 		}
+		cred, err := azidentity.NewClientCertificateCredential(cfg.TenantID, cfg.ClientID, certs, key, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating client certificate credential: %w", err)
+		}
+		return cred, nil
+	}
+	if cfg.ClientSecret == "" {
+		return nil, fmt.Errorf("--auth=%s needs CLIENT_CERT_PATH or CLIENT_SECRET", ModeClientCredentials)
+	}
+	cred, err := azidentity.NewClientSecretCredential(cfg.TenantID, cfg.ClientID, cfg.ClientSecret, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating client secret credential: %w", err)
 	}
+	return cred, nil
+}
 
+// newClient wraps cred in a Graph client whose HTTP pipeline ends with middleware.
+func newClient(cred azcore.TokenCredential, scopes []string, maxRetries int, middleware []khttp.Middleware) (*msgraphsdk.GraphServiceClient, error) {
 	tokenProvider, err := auth.NewAzureIdentityAuthenticationProviderWithScopes(cred, scopes)
 	if err != nil {
 		return nil, fmt.Errorf("creating token provider: %w", err)
 	}
+	return newAdapterClient(tokenProvider, "", maxRetries, middleware)
+}
 
-	adapter, err := msgraphsdk.NewGraphRequestAdapter(tokenProvider)
+// newAdapterClient builds the Graph client around provider. baseURL, when
+// set, replaces the Graph endpoint.
+func newAdapterClient(provider authentication.AuthenticationProvider, baseURL string, maxRetries int, middleware []khttp.Middleware) (*msgraphsdk.GraphServiceClient, error) {
+	options := msgraphsdk.GetDefaultClientOptions()
+	pipeline := msgraphgocore.GetDefaultMiddlewaresWithOptions(&options)
+	// Kiota's retry handler always retries at least three times and gives up
+	// on 500 and 502; the configurable one takes its place.
+	for i, m := range pipeline {
+		if _, ok := m.(*khttp.RetryHandler); ok {
+			pipeline[i] = retry.New(maxRetries)
+		}
+	}
+	pipeline = append(pipeline, middleware...)
+	httpClient := msgraphgocore.GetDefaultClient(&options, pipeline...)
+
+	adapter, err := msgraphsdk.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
+		provider, nil, nil, httpClient)
 	if err != nil {
 		return nil, fmt.Errorf("creating graph adapter: %w", err)
 	}
+	if baseURL != "" {
+		adapter.SetBaseUrl(strings.TrimRight(baseURL, "/"))
+	}
 
 	return msgraphsdk.NewGraphServiceClient(adapter), nil
 }
+
+// Status is the JSON representation of `auth status`.
+type Status struct {
+	Mode       string `json:"mode"`
+	Identity   string `json:"managedIdentity,omitempty"`
+	Credential string `json:"credential,omitempty"`
+	TokenStore string `json:"tokenStore"`
+	Persistent bool   `json:"persistent"`
+	Location   string `json:"location"`
+	Fallback   string `json:"fallbackReason,omitempty"`
+	AuthRecord string `json:"authRecord,omitempty"`
+	SignedIn   bool   `json:"signedIn"`
+	Account    string `json:"account,omitempty"`
+	TenantID   string `json:"tenantId,omitempty"`
+}
+
+// PrintStatus reports which token store cfg resolves to and whether an auth
+// record exists, without signing in or contacting Graph.
+func PrintStatus(cfg Config, jsonOutput bool) error {
+	if cfg.Mode == ModeManagedIdentity {
+		identity := cfg.ManagedIdentityID
+		if identity == "" {
+			identity = "system-assigned"
+		}
+		st := Status{
+			Mode:       ModeManagedIdentity,
+			Identity:   identity,
+			TokenStore: "none",
+			Location:   "tokens are requested from the Azure managed identity endpoint on every run",
+		}
+		if jsonOutput {
+			return printJSON(st)
+		}
+		fmt.Printf("Auth mode   : %s (%s)\n", st.Mode, st.Identity)
+		fmt.Printf("Token store : %s — %s\n", st.TokenStore, st.Location)
+		return nil
+	}
+	if cfg.Mode == ModeClientCredentials {
+		// The secret itself is never shown, only which kind is configured.
+		credential := "none (set CLIENT_CERT_PATH or CLIENT_SECRET)"
+		switch {
+		case cfg.ClientCertPath != "":
+			credential = "certificate " + cfg.ClientCertPath
+		case cfg.ClientSecret != "":
+			credential = "client secret"
+		}
+		st := Status{
+			Mode:       ModeClientCredentials,
+			Credential: credential,
+			TokenStore: "none",
+			Location:   "tokens are requested from Microsoft Entra ID on every run",
+			TenantID:   cfg.TenantID,
+		}
+		if jsonOutput {
+			return printJSON(st)
+		}
+		fmt.Printf("Auth mode   : %s (%s)\n", st.Mode, st.Credential)
+		fmt.Printf("Token store : %s — %s\n", st.TokenStore, st.Location)
+		fmt.Printf("Tenant      : %s\n", st.TenantID)
+		return nil
+	}
+
+	store, _, reason, err := resolveStore(cfg.TokenStore, cfg.Profile)
+	if err != nil {
+		return err
+	}
+	record, err := loadRecord(cfg.Profile)
+	if err != nil {
+		return fmt.Errorf("loading auth record: %w", err)
+	}
+	path, err := recordPath(cfg.Profile)
+	if err != nil {
+		return err
+	}
+
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModeDelegated
+	}
+	st := Status{
+		Mode:       mode,
+		TokenStore: store,
+		Persistent: store != StoreMemory,
+		Location:   storeLocation(store, cfg.Profile),
+		Fallback:   reason,
+		AuthRecord: path,
+		SignedIn:   record != (azidentity.AuthenticationRecord{}),
+		Account:    record.Username,
+		TenantID:   cfg.TenantID,
+	}
+
+	if jsonOutput {
+		return printJSON(st)
+	}
+
+	fmt.Printf("Auth mode   : %s\n", st.Mode)
+	fmt.Printf("Token store : %s\n", st.TokenStore)
+	fmt.Printf("Location    : %s\n", st.Location)
+	if st.Fallback != "" {
+		fmt.Printf("Fallback    : keychain unavailable (%s)\n", st.Fallback)
+	}
+	fmt.Printf("Tenant      : %s\n", st.TenantID)
+	fmt.Printf("Auth record : %s\n", st.AuthRecord)
+	if st.SignedIn {
+		fmt.Printf("Signed in   : %s\n", st.Account)
+	} else if mode == ModeDeviceCode {
+		fmt.Println("Signed in   : no (the next command prints a code to sign in with)")
+	} else {
+		fmt.Println("Signed in   : no (the next command opens the browser)")
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	return schema.Encode(os.Stdout, v)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+)
+
+// accessDeniedCodes are the Graph and Exchange error codes returned when the
+// token lacks a permission, as opposed to other kinds of 403.
+var accessDeniedCodes = []string{
+	"ErrorAccessDenied",
+	"Authorization_RequestDenied",
+	"AccessDenied",
+	"ErrorInsufficientPermissions",
+}
+
+// AccessDenied reports whether err is a Graph 403 caused by a missing
+// permission or consent.
+func AccessDenied(err error) bool {
+	var odataErr *odataerrors.ODataError
+	if errors.As(err, &odataErr) {
+		if detail := odataErr.GetErrorEscaped(); detail != nil {
+			code := deref(detail.GetCode())
+			for _, c := range accessDeniedCodes {
+				if strings.EqualFold(code, c) {
+					return true
+				}
+			}
+			if strings.Contains(strings.ToLower(deref(detail.GetMessage())), "insufficient privileges") {
+				return true
+			}
+		}
+		return odataErr.ResponseStatusCode == 403
+	}
+	var apiErr *abstractions.ApiError
+	return errors.As(err, &apiErr) && apiErr.ResponseStatusCode == 403
+}
+
+// consentErrors are the Microsoft Entra ID errors for a token refused because
+// a scope has not been consented to, such as one only an admin may grant.
+var consentErrors = []string{"AADSTS65001", "AADSTS90094", "consent_required"}
+
+// ConsentMissing reports whether err means a permission is missing: a Graph
+// 403, or a token that could not be issued for lack of consent.
+func ConsentMissing(err error) bool {
+	if err == nil {
+		return false
+	}
+	if AccessDenied(err) {
+		return true
+	}
+	for _, code := range consentErrors {
+		if strings.Contains(err.Error(), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsentHelp explains how to obtain permission, the Graph permission an
+// operation needs, for cfg's auth mode.
+func ConsentHelp(cfg Config, permission string) string {
+	var b strings.Builder
+	if cfg.AppOnly() {
+		fmt.Fprintf(&b, "This operation needs the Microsoft Graph application permission %s.\n", permission)
+		if cfg.Mode == ModeClientCredentials {
+			b.WriteString("Add it to the app registration under API permissions > Application permissions and grant admin consent\n")
+			b.WriteString("(see setup.md, \"Running as an App Registration\"),\n")
+		} else {
+			b.WriteString("Assign it to the managed identity as an app role (see setup.md, \"Running on Azure with a Managed Identity\"),\n")
+		}
+		b.WriteString("and check that no Exchange application access policy excludes the target mailbox.")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "This operation needs the Microsoft Graph delegated permission %s.\n", permission)
+	b.WriteString("1. Ask a tenant admin to grant consent by opening:\n")
+	fmt.Fprintf(&b, "   %s\n", adminConsentURL(cfg, permission))
+	b.WriteString("2. Then sign in again so the new permission is included in your token:\n")
+	if path, err := recordPath(cfg.Profile); err == nil {
+		fmt.Fprintf(&b, "   rm %s", path)
+	}
+	if cfg.SharedMailbox {
+		b.WriteString("\nIf consent is already granted, ask the mailbox owner or an Exchange admin to give you\n")
+		b.WriteString("Full Access to the mailbox, or delegate access to the folders you need.")
+	}
+	return b.String()
+}
+
+// adminConsentURL returns the v2.0 admin consent URL granting permission plus
+// every scope the tool already requests.
+func adminConsentURL(cfg Config, permission string) string {
+	all := append([]string{}, cfg.delegatedScopes()...)
+	found := false
+	for _, s := range all {
+		if strings.EqualFold(s, permission) {
+			found = true
+		}
+	}
+	if !found {
+		all = append(all, permission)
+	}
+	qualified := make([]string, len(all))
+	for i, s := range all {
+		qualified[i] = "https://graph.microsoft.com/" + s
+	}
+
+	q := url.Values{}
+	q.Set("client_id", cfg.ClientID)
+	q.Set("scope", strings.Join(qualified, " "))
+	q.Set("redirect_uri", redirectURL)
+	return fmt.Sprintf("https://%s/%s/v2.0/adminconsent?%s", authorityHost, url.PathEscape(cfg.TenantID), q.Encode())
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
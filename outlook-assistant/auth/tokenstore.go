@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity/cache"
+	extcache "github.com/AzureAD/microsoft-authentication-extensions-for-go/cache"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/public"
+)
+
+// Token stores accepted by --token-store.
+const (
+	// StoreAuto uses the keychain when available and memory otherwise.
+	StoreAuto = "auto"
+	// StoreKeychain uses the platform credential store: Keychain on macOS,
+	// DPAPI on Windows, the kernel user keyring on Linux. It fails rather than
+	// falling back when that store is unavailable.
+	StoreKeychain = "keychain"
+	// StoreFile writes an AES-256-GCM encrypted file whose key is derived from
+	// the passphrase in TokenKeyEnv.
+	StoreFile = "file"
+	// StoreMemory keeps tokens only for the current invocation.
+	StoreMemory = "memory"
+)
+
+// TokenKeyEnv names the environment variable holding the passphrase for StoreFile.
+const TokenKeyEnv = "OUTLOOK_ASSISTANT_TOKEN_KEY"
+
+const (
+	redirectURL   = "http://localhost:4321"
+	tokenFile     = ".outlook-assistant-tokens"
+	keyIterations = 210000
+	saltSize      = 16
+	authorityHost = "login.microsoftonline.com"
+)
+
+// resolveStore validates store and, for StoreAuto and StoreKeychain, opens the
+// keychain-backed cache. The returned store is never StoreAuto. reason explains
+// why auto fell back to memory.
+func resolveStore(store, profile string) (resolved string, keychain azidentity.Cache, reason string, err error) {
+	switch store {
+	case "", StoreAuto, StoreKeychain:
+		var opts *cache.Options
+		if profile != "" {
+			opts = &cache.Options{Name: "outlook-assistant." + profileName(profile)}
+		}
+		c, err := cache.New(opts)
+		if err == nil {
+			return StoreKeychain, c, "", nil
+		}
+		if store == StoreKeychain {
+			return "", azidentity.Cache{}, "", fmt.Errorf("keychain token store unavailable: %w", err)
+		}
+		return StoreMemory, azidentity.Cache{}, err.Error(), nil
+	case StoreFile, StoreMemory:
+		return store, azidentity.Cache{}, "", nil
+	default:
+		return "", azidentity.Cache{}, "", fmt.Errorf("unknown token store %q — valid stores: auto, keychain, file, memory", store)
+	}
+}
+
+// storeLocation describes where a resolved store keeps tokens.
+func storeLocation(store, profile string) string {
+	switch store {
+	case StoreKeychain:
+		switch runtime.GOOS {
+		case "darwin":
+			return "macOS Keychain"
+		case "windows":
+			return `DPAPI-encrypted file under %LOCALAPPDATA%\.IdentityService`
+		default:
+			return "~/.IdentityService, encrypted with a key on the user keyring"
+		}
+	case StoreFile:
+		if path, err := tokenFilePath(profile); err == nil {
+			return path
+		}
+		return "~/" + tokenFile
+	default:
+		return "process memory (not persisted)"
+	}
+}
+
+func tokenFilePath(profile string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find home directory: %w", err)
+	}
+	name := tokenFile
+	if profile != "" {
+		name += "." + profileName(profile)
+	}
+	return filepath.Join(home, name+".bin"), nil
+}
+
+// ---------- azidentity credential (keychain, memory) ----------
+
+// userCredential is a credential that signs a user in and can do so up front,
+// returning the record that lets later runs sign in silently.
+type userCredential interface {
+	azcore.TokenCredential
+	Authenticate(ctx context.Context, opts *policy.TokenRequestOptions) (azidentity.AuthenticationRecord, error)
+}
+
+// newIdentityCredential returns an interactive browser credential, or with
+// ModeDeviceCode a device code credential, using c as its token cache, signing
+// in now if there is no auth record yet.
+func newIdentityCredential(cfg Config, record azidentity.AuthenticationRecord, c azidentity.Cache) (azcore.TokenCredential, error) {
+	var cred userCredential
+	var err error
+	if cfg.Mode == ModeDeviceCode {
+		cred, err = azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
+			ClientID:             cfg.ClientID,
+			TenantID:             cfg.TenantID,
+			AuthenticationRecord: record,
+			Cache:                c,
+			UserPrompt: func(_ context.Context, msg azidentity.DeviceCodeMessage) error {
+				promptDeviceCode(msg.Message)
+				return nil
+			},
+		})
+	} else {
+		cred, err = azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
+			ClientID:             cfg.ClientID,
+			TenantID:             cfg.TenantID,
+			RedirectURL:          redirectURL,
+			AuthenticationRecord: record,
+			Cache:                c,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating credential: %w", err)
+	}
+
+	// If no record was stored, authenticate now and save the record so future
+	// invocations skip the sign-in entirely.
+	if record == (azidentity.AuthenticationRecord{}) {
+		announceSignIn(cfg.Mode)
+		newRecord, authErr := cred.Authenticate(context.Background(), &policy.TokenRequestOptions{
+			Scopes: cfg.signInScopes(),
+		})
+		if authErr != nil {
+			return nil, fmt.Errorf("authenticating: %w", authErr)
+		}
+		if saveErr := saveRecord(cfg.Profile, newRecord); saveErr != nil {
+			slog.Warn("Could not save auth record", "error", saveErr)
+		}
+	}
+	return cred, nil
+}
+
+// announceSignIn says how the user is about to be asked to sign in.
+func announceSignIn(mode string) {
+	if mode == ModeDeviceCode {
+		slog.Info("Signing in with a device code")
+	} else {
+		slog.Info("Opening browser for authentication")
+	}
+}
+
+// promptDeviceCode logs the sign-in URL and code, keeping stdout clean for
+// --json. It logs at warning level, so the code is shown whatever --log-level
+// says short of error; the sign-in then waits until it has been entered.
+func promptDeviceCode(message string) {
+	slog.Warn(message)
+}
+
+// ---------- MSAL credential (encrypted file) ----------
+
+// fileCredential is a TokenCredential backed by an MSAL public client whose
+// cache lives in an encryptedFile. azidentity only accepts its own cache
+// implementations, so the file store talks to MSAL directly.
+type fileCredential struct {
+	app        public.Client
+	account    public.Account
+	deviceCode bool // sign in with a device code instead of the browser
+}
+
+func newFileCredential(cfg Config, record azidentity.AuthenticationRecord) (azcore.TokenCredential, error) {
+	passphrase := os.Getenv(TokenKeyEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("--token-store=file requires %s to be set to a passphrase", TokenKeyEnv)
+	}
+	path, err := tokenFilePath(cfg.Profile)
+	if err != nil {
+		return nil, err
+	}
+	store, err := extcache.New(&encryptedFile{path: path, passphrase: passphrase}, path)
+	if err != nil {
+		return nil, fmt.Errorf("opening token file: %w", err)
+	}
+	app, err := public.New(cfg.ClientID,
+		public.WithAuthority("https://"+authorityHost+"/"+cfg.TenantID),
+		public.WithCache(store))
+	if err != nil {
+		return nil, fmt.Errorf("creating credential: %w", err)
+	}
+
+	c := &fileCredential{app: app, deviceCode: cfg.Mode == ModeDeviceCode}
+	accounts, err := app.Accounts(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("reading token file: %w", err)
+	}
+	for _, a := range accounts {
+		if record.HomeAccountID == "" || a.HomeAccountID == record.HomeAccountID {
+			c.account = a
+			break
+		}
+	}
+	if c.account.IsZero() {
+		announceSignIn(cfg.Mode)
+		if _, err := c.interactive(context.Background(), cfg.signInScopes()); err != nil {
+			return nil, fmt.Errorf("authenticating: %w", err)
+		}
+		newRecord := azidentity.AuthenticationRecord{
+			Authority:     authorityHost,
+			ClientID:      cfg.ClientID,
+			HomeAccountID: c.account.HomeAccountID,
+			TenantID:      c.account.Realm,
+			Username:      c.account.PreferredUsername,
+			Version:       "1.0",
+		}
+		if saveErr := saveRecord(cfg.Profile, newRecord); saveErr != nil {
+			slog.Warn("Could not save auth record", "error", saveErr)
+		}
+	}
+	return c, nil
+}
+
+// GetToken implements azcore.TokenCredential, prompting the user to sign in
+// again only when no usable token or refresh token is cached.
+func (c *fileCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	res, err := c.app.AcquireTokenSilent(ctx, opts.Scopes, public.WithSilentAccount(c.account))
+	if err != nil {
+		if res, err = c.interactive(ctx, opts.Scopes); err != nil {
+			return azcore.AccessToken{}, err
+		}
+	}
+	return azcore.AccessToken{Token: res.AccessToken, ExpiresOn: res.ExpiresOn}, nil
+}
+
+// interactive signs the user in through the browser, or with a device code.
+func (c *fileCredential) interactive(ctx context.Context, s []string) (public.AuthResult, error) {
+	if c.deviceCode {
+		dc, err := c.app.AcquireTokenByDeviceCode(ctx, s)
+		if err != nil {
+			return public.AuthResult{}, err
+		}
+		promptDeviceCode(dc.Result.Message)
+		res, err := dc.AuthenticationResult(ctx)
+		if err != nil {
+			return res, err
+		}
+		c.account = res.Account
+		return res, nil
+	}
+
+	opts := []public.AcquireInteractiveOption{public.WithRedirectURI(redirectURL)}
+	if c.account.PreferredUsername != "" {
+		opts = append(opts, public.WithLoginHint(c.account.PreferredUsername))
+	}
+	res, err := c.app.AcquireTokenInteractive(ctx, s, opts...)
+	if err != nil {
+		return res, err
+	}
+	c.account = res.Account
+	return res, nil
+}
+
+// encryptedFile is an MSAL cache accessor storing data as
+// salt || nonce || AES-256-GCM ciphertext, keyed with PBKDF2-SHA256 of a passphrase.
+type encryptedFile struct {
+	path       string
+	passphrase string
+
+	mu   sync.Mutex
+	salt []byte
+	key  []byte
+}
+
+func (f *encryptedFile) Read(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("token file %s is corrupt", f.path)
+	}
+	gcm, err := f.cipher(data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("token file %s is corrupt", f.path)
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting token file %s (wrong %s?): %w", f.path, TokenKeyEnv, err)
+	}
+	return plain, nil
+}
+
+func (f *encryptedFile) Write(_ context.Context, data []byte) error {
+	f.mu.Lock()
+	salt := f.salt
+	f.mu.Unlock()
+	if salt == nil {
+		salt = make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := f.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	out := append(append(append([]byte{}, salt...), nonce...), gcm.Seal(nil, nonce, data, nil)...)
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, out, 0600)
+}
+
+func (f *encryptedFile) Delete(context.Context) error {
+	err := os.Remove(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// cipher returns an AES-GCM cipher for salt, deriving the key only when the
+// salt differs from the one last used since PBKDF2 is deliberately slow.
+func (f *encryptedFile) cipher(salt []byte) (cipher.AEAD, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil || string(f.salt) != string(salt) {
+		key, err := pbkdf2.Key(sha256.New, f.passphrase, salt, keyIterations, 32)
+		if err != nil {
+			return nil, err
+		}
+		f.salt, f.key = append([]byte{}, salt...), key
+	}
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
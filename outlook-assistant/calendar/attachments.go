@@ -0,0 +1,231 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/progress"
+)
+
+// ---------- Attachments ----------
+
+const (
+	// inlineAttachmentLimit is the largest file that can be sent in a single
+	// request; larger files are uploaded in slices through an upload session.
+	inlineAttachmentLimit = 3 * 1024 * 1024
+	// maxAttachmentSize is the largest attachment Outlook accepts.
+	maxAttachmentSize = 150 * 1024 * 1024
+	// uploadSliceSize must be a multiple of 320 KiB.
+	uploadSliceSize = 10 * 320 * 1024
+)
+
+// AttachmentInfo is the JSON representation of an event attachment. File is
+// set when the attachment was saved with --out.
+type AttachmentInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"` // file, item, or reference
+	ContentType string `json:"contentType,omitempty"`
+	Size        int32  `json:"size"`
+	IsInline    bool   `json:"isInline,omitempty"`
+	File        string `json:"file,omitempty"`
+}
+
+// eventAttachments lists the attachments of an event. With outDir, file
+// attachments are also saved there under their own names; attached Outlook
+// items and cloud links have no file content and are only listed.
+func eventAttachments(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, eventID, outDir string) ([]AttachmentInfo, error) {
+	builder := mailbox.Of(client).Events().ByEventId(eventID).Attachments()
+	result, err := builder.Get(ctx, &users.ItemEventsItemAttachmentsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsItemAttachmentsRequestBuilderGetQueryParameters{
+			Select: []string{"id", "name", "contentType", "size", "isInline"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments: %w", err)
+	}
+
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0700); err != nil {
+			return nil, fmt.Errorf("creating %s: %w", outDir, err)
+		}
+	}
+
+	infos := []AttachmentInfo{}
+	used := map[string]bool{}
+	for i, a := range result.GetValue() {
+		info := AttachmentInfo{
+			ID:          deref(a.GetId(), ""),
+			Name:        deref(a.GetName(), ""),
+			Kind:        attachmentKind(a),
+			ContentType: deref(a.GetContentType(), ""),
+			IsInline:    a.GetIsInline() != nil && *a.GetIsInline(),
+		}
+		if a.GetSize() != nil {
+			info.Size = *a.GetSize()
+		}
+
+		if outDir != "" && info.Kind == "file" {
+			full, err := builder.ByAttachmentId(info.ID).Get(ctx, nil)
+			if err != nil {
+				return nil, fmt.Errorf("downloading %s: %w", info.Name, err)
+			}
+			file, ok := full.(models.FileAttachmentable)
+			if !ok {
+				return nil, fmt.Errorf("downloading %s: not a file attachment", info.Name)
+			}
+			path := filepath.Join(outDir, uniqueName(info.Name, i+1, used))
+			if err := os.WriteFile(path, file.GetContentBytes(), 0600); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", path, err)
+			}
+			info.File = path
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// fileAttachments reads the comma-separated paths for --attach. Files small
+// enough to send in one request are returned as attachments for the event
+// body; the paths of larger ones are returned for attachLarge.
+func fileAttachments(paths string) ([]models.Attachmentable, []string, error) {
+	var small []models.Attachmentable
+	var large []string
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if st.Size() > maxAttachmentSize {
+			return nil, nil, fmt.Errorf("%s is %d bytes; event attachments are limited to 150 MB", path, st.Size())
+		}
+		if st.Size() > inlineAttachmentLimit {
+			large = append(large, path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		name := filepath.Base(path)
+		contentType := contentTypeOf(name)
+		a := models.NewFileAttachment()
+		a.SetName(&name)
+		a.SetContentType(&contentType)
+		a.SetContentBytes(data)
+		small = append(small, a)
+	}
+	return small, large, nil
+}
+
+// addAttachments attaches files to an existing event: small ones by posting
+// them directly, larger ones through upload sessions.
+func addAttachments(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, eventID string, small []models.Attachmentable, large []string) error {
+	builder := mailbox.Of(client).Events().ByEventId(eventID).Attachments()
+	for _, a := range small {
+		if _, err := builder.Post(ctx, a, nil); err != nil {
+			return fmt.Errorf("attaching %s: %w", deref(a.GetName(), ""), err)
+		}
+	}
+	for _, path := range large {
+		if err := attachLarge(ctx, client, eventID, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachLarge uploads a file of more than 3 MB in slices.
+func attachLarge(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, eventID, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	name := filepath.Base(path)
+	contentType := contentTypeOf(name)
+	size := st.Size()
+	attachmentType := models.FILE_ATTACHMENTTYPE
+	item := models.NewAttachmentItem()
+	item.SetAttachmentType(&attachmentType)
+	item.SetName(&name)
+	item.SetContentType(&contentType)
+	item.SetSize(&size)
+	body := users.NewItemEventsItemAttachmentsCreateUploadSessionPostRequestBody()
+	body.SetAttachmentItem(item)
+
+	session, err := mailbox.Of(client).Events().ByEventId(eventID).Attachments().CreateUploadSession().Post(ctx, body, nil)
+	if err != nil {
+		return fmt.Errorf("starting upload of %s: %w", name, err)
+	}
+	task := fileuploader.NewLargeFileUploadTask[models.Attachmentable](
+		client.GetAdapter(), session, f, uploadSliceSize,
+		models.CreateAttachmentFromDiscriminatorValue,
+		abstractions.ErrorMappings{"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue},
+	)
+	report := progress.New("Uploading attachment")
+	result := task.Upload(func(current, total int64) {
+		report.Report("file", name, "percent", current*100/total)
+	})
+	if !result.GetUploadSucceeded() {
+		return fmt.Errorf("uploading %s: %w", name, errors.Join(result.GetResponseErrors()...))
+	}
+	return nil
+}
+
+func attachmentKind(a models.Attachmentable) string {
+	switch a.(type) {
+	case models.FileAttachmentable:
+		return "file"
+	case models.ItemAttachmentable:
+		return "item"
+	case models.ReferenceAttachmentable:
+		return "reference"
+	}
+	return strings.TrimPrefix(deref(a.GetOdataType(), ""), "#microsoft.graph.")
+}
+
+func contentTypeOf(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// uniqueName returns a safe file name for an attachment, numbering repeats so
+// two attachments with the same name don't overwrite each other.
+func uniqueName(name string, n int, used map[string]bool) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = fmt.Sprintf("attachment-%d", n)
+	}
+	ext := filepath.Ext(name)
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
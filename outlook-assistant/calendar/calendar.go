@@ -6,244 +6,903 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	abstractions "github.com/microsoft/kiota-abstractions-go"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	"github.com/microsoftgraph/msgraph-sdk-go/users"
 
 	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/charset"
+	"outlook-assistant/mailbox"
+	"outlook-assistant/reldate"
+	"outlook-assistant/schema"
 )
 
 // ---------- JSON output types ----------
 
 // EventSummary is the JSON representation of a calendar event.
 type EventSummary struct {
-	Index    int    `json:"index"`
-	ID       string `json:"id"`
-	Subject  string `json:"subject"`
-	Start    string `json:"start"`
-	End      string `json:"end"`
-	Location string `json:"location"`
-	IsAllDay bool   `json:"isAllDay"`
-	Organizer string `json:"organizer"`
+	Index          int            `json:"index"`
+	ID             string         `json:"id"`
+	ICalUID        string         `json:"iCalUId,omitempty"`
+	Subject        string         `json:"subject"`
+	Start          string         `json:"start"`
+	End            string         `json:"end"`
+	TimeZone       string         `json:"timeZone"` // the zone start and end are shown in
+	Location       string         `json:"location"`
+	IsAllDay       bool           `json:"isAllDay"`
+	Organizer      string         `json:"organizer"`
+	ShowAs         string         `json:"showAs,omitempty"`
+	Locations      []LocationInfo `json:"locations,omitempty"`
+	Type           string         `json:"type,omitempty"` // singleInstance, occurrence, exception, or seriesMaster
+	SeriesMasterID string         `json:"seriesMasterId,omitempty"`
+	Recurrence     *Recurrence    `json:"recurrence,omitempty"`
+}
+
+// EventDetail is the JSON representation of a single event read in full.
+type EventDetail struct {
+	ID              string             `json:"id"`
+	ICalUID         string             `json:"iCalUId,omitempty"`
+	Subject         string             `json:"subject"`
+	Start           string             `json:"start"`
+	End             string             `json:"end"`
+	TimeZone        string             `json:"timeZone"` // the zone start and end are shown in
+	Location        string             `json:"location"`
+	Locations       []LocationInfo     `json:"locations,omitempty"`
+	IsAllDay        bool               `json:"isAllDay"`
+	Organizer       string             `json:"organizer"`
+	Attendees       []string           `json:"attendees"`
+	Responses       []AttendeeResponse `json:"responses"` // each attendee's type and answer
+	ShowAs          string             `json:"showAs,omitempty"`
+	IsReminderOn    bool               `json:"isReminderOn"`
+	ReminderMinutes int32              `json:"reminderMinutesBeforeStart"` // when isReminderOn
+	WebLink         string             `json:"webLink,omitempty"`
+	Body            string             `json:"body"`
+	Attachments     []AttachmentInfo   `json:"attachments"`
 }
 
 // EventCreated is the JSON response after creating an event.
 type EventCreated struct {
-	ID      string `json:"id"`
-	Subject string `json:"subject"`
-	WebLink string `json:"webLink"`
+	ID        string         `json:"id"`
+	ICalUID   string         `json:"iCalUId,omitempty"`
+	Subject   string         `json:"subject"`
+	WebLink   string         `json:"webLink"`
+	Conflicts []EventSummary `json:"conflicts,omitempty"` // events it overlaps, created with --force
+}
+
+// ---------- ID cache (stored in home directory) ----------
+
+func idCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, mailbox.CacheFile(".outlook-assistant-calendar-cache.json"))
+}
+
+func saveIDCache(ids []string) {
+	data, _ := json.Marshal(ids)
+	_ = os.WriteFile(idCachePath(), data, 0600)
+}
+
+func loadIDCache() []string {
+	data, err := os.ReadFile(idCachePath())
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	_ = json.Unmarshal(data, &ids)
+	return ids
+}
+
+func resolveEventID(ref string) (string, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		ids := loadIDCache()
+		if ids == nil {
+			return "", fmt.Errorf("no cached event list — run `calendar list` first")
+		}
+		if n < 1 || n > len(ids) {
+			return "", fmt.Errorf("index %d out of range (last list had %d events)", n, len(ids))
+		}
+		return ids[n-1], nil
+	}
+	return ref, nil
 }
 
 // ---------- List ----------
 
-// List prints calendar events within a time range.
-// since and before are optional ISO date strings (YYYY-MM-DD or YYYY-MM-DD HH:MM).
-// Default range: 30 days ago → 30 days from now.
-func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count int32, since, before string, jsonOutput bool) error {
+// List prints calendar events within a time range and caches their IDs so
+// later commands can refer to them by index.
+// since and before are optional ISO date strings (YYYY-MM-DD or YYYY-MM-DD HH:MM)
+// or relative dates such as yesterday or last monday.
+// newerThan and olderThan are ages such as 7d that stand in for them.
+// Default range: 30 days ago → 30 days from now; with only an upper bound in
+// the past, the 30 days before it.
+//
+// expand is "occurrences" (the default) to list each instance of a recurring
+// meeting, or "masters" to list each series once, as its series master with
+// its recurrence rule, alongside the single events in the range.
+func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count int32, since, before, newerThan, olderThan, expand string, jsonOutput bool) error {
+	if expand != "" && expand != "occurrences" && expand != "masters" {
+		return fmt.Errorf("unknown --expand %q — valid values: occurrences, masters", expand)
+	}
+	if newerThan != "" && since != "" {
+		return fmt.Errorf("--newer-than and --since cannot be combined")
+	}
+	if olderThan != "" && before != "" {
+		return fmt.Errorf("--older-than and --before cannot be combined")
+	}
 	var startTime, endTime time.Time
 
-	if since != "" {
-		t, err := parseDateTime(since)
+	switch {
+	case newerThan != "":
+		t, err := parseAge(newerThan)
+		if err != nil {
+			return fmt.Errorf("invalid --newer-than: %w", err)
+		}
+		startTime = t.UTC()
+	case since != "":
+		t, err := parseBound(since)
 		if err != nil {
 			return fmt.Errorf("invalid --since: %w", err)
 		}
 		startTime = t.UTC()
-	} else {
-		startTime = time.Now().UTC().AddDate(0, 0, -30)
 	}
 
-	if before != "" {
-		t, err := parseDateTime(before)
+	switch {
+	case olderThan != "":
+		t, err := parseAge(olderThan)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+		endTime = t.UTC()
+	case before != "":
+		t, err := parseBound(before)
 		if err != nil {
 			return fmt.Errorf("invalid --before: %w", err)
 		}
 		endTime = t.UTC()
-	} else {
+	default:
 		endTime = time.Now().UTC().AddDate(0, 0, 30)
 	}
 
-	startStr := startTime.Format(time.RFC3339)
-	endStr := endTime.Format(time.RFC3339)
-
-	requestParams := &users.ItemCalendarViewRequestBuilderGetQueryParameters{
-		StartDateTime: &startStr,
-		EndDateTime:   &endStr,
-		Select:        []string{"id", "subject", "start", "end", "location", "organizer", "isAllDay"},
-		Top:           &count,
-		Orderby:       []string{"start/dateTime ASC"},
-	}
-	config := &users.ItemCalendarViewRequestBuilderGetRequestConfiguration{
-		QueryParameters: requestParams,
+	if startTime.IsZero() {
+		startTime = time.Now().UTC().AddDate(0, 0, -30)
+		if endTime.Before(time.Now()) {
+			startTime = endTime.AddDate(0, 0, -30)
+		}
 	}
 
-	result, err := client.Me().CalendarView().Get(ctx, config)
-	if err != nil {
-		return fmt.Errorf("listing calendar events: %w", err)
+	fields := []string{"id", "iCalUId", "subject", "start", "end", "location", "locations", "organizer", "isAllDay", "showAs"}
+	var events []models.Eventable
+	if expand == "masters" {
+		var err error
+		events, err = seriesMasters(ctx, client, startTime, endTime, fields, int(count))
+		if err != nil {
+			return fmt.Errorf("listing calendar events: %w", err)
+		}
+	} else {
+		startStr := startTime.Format(time.RFC3339)
+		endStr := endTime.Format(time.RFC3339)
+
+		requestParams := &users.ItemCalendarViewRequestBuilderGetQueryParameters{
+			StartDateTime: &startStr,
+			EndDateTime:   &endStr,
+			Select:        append(fields, "type", "seriesMasterId"),
+			Top:           &count,
+			Orderby:       []string{"start/dateTime ASC"},
+		}
+		config := &users.ItemCalendarViewRequestBuilderGetRequestConfiguration{
+			QueryParameters: requestParams,
+		}
+
+		result, err := mailbox.Of(client).CalendarView().Get(ctx, config)
+		if err != nil {
+			return fmt.Errorf("listing calendar events: %w", err)
+		}
+		events = result.GetValue()
 	}
 
-	events := result.GetValue()
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, deref(event.GetId(), ""))
+	}
+	saveIDCache(ids)
 
 	if jsonOutput {
-		summaries := make([]EventSummary, 0, len(events))
-		for i, event := range events {
-			location := ""
-			if event.GetLocation() != nil {
-				location = deref(event.GetLocation().GetDisplayName(), "")
-			}
-			organizer := ""
-			if event.GetOrganizer() != nil && event.GetOrganizer().GetEmailAddress() != nil {
-				organizer = deref(event.GetOrganizer().GetEmailAddress().GetAddress(), "")
-			}
-			isAllDay := event.GetIsAllDay() != nil && *event.GetIsAllDay()
-			summaries = append(summaries, EventSummary{
-				Index:     i + 1,
-				ID:        deref(event.GetId(), ""),
-				Subject:   deref(event.GetSubject(), ""),
-				Start:     formatEventTime(event.GetStart()),
-				End:       formatEventTime(event.GetEnd()),
-				Location:  location,
-				IsAllDay:  isAllDay,
-				Organizer: organizer,
-			})
-		}
-		return printJSON(summaries)
+		return printJSON(eventSummaries(events))
 	}
 
 	if len(events) == 0 {
 		fmt.Println("No events found in the specified date range.")
 		return nil
 	}
+	printEventTable(events)
+	return nil
+}
+
+// eventSummaries converts events for JSON output, numbered from 1.
+func eventSummaries(events []models.Eventable) []EventSummary {
+	summaries := make([]EventSummary, 0, len(events))
+	for i, event := range events {
+		location := ""
+		if event.GetLocation() != nil {
+			location = deref(event.GetLocation().GetDisplayName(), "")
+		}
+		organizer := ""
+		if event.GetOrganizer() != nil && event.GetOrganizer().GetEmailAddress() != nil {
+			organizer = deref(event.GetOrganizer().GetEmailAddress().GetAddress(), "")
+		}
+		allDay := isAllDay(event)
+		summaries = append(summaries, EventSummary{
+			Index:          i + 1,
+			ID:             deref(event.GetId(), ""),
+			ICalUID:        deref(event.GetICalUId(), ""),
+			Subject:        deref(event.GetSubject(), ""),
+			Start:          formatEventTime(event.GetStart(), allDay),
+			End:            formatEventTime(event.GetEnd(), allDay),
+			TimeZone:       zoneLabel(),
+			Location:       location,
+			IsAllDay:       allDay,
+			Organizer:      organizer,
+			ShowAs:         showAsOf(event),
+			Locations:      locationsOf(event),
+			Type:           eventTypeOf(event),
+			SeriesMasterID: deref(event.GetSeriesMasterId(), ""),
+			Recurrence:     recurrenceOf(event),
+		})
+	}
+	return summaries
+}
 
-	fmt.Printf("\n%-3s  %-40s  %-20s  %-20s  %s\n", "#", "Subject", "Start", "End", "Location")
-	fmt.Println(strings.Repeat("-", 110))
+func printEventTable(events []models.Eventable) {
+	fmt.Printf("\n%-3s  %-40s  %-20s  %-20s  %-10s  %s\n", "#", "Subject", "Start", "End", "Show As", "Location")
+	fmt.Println(strings.Repeat("-", 122))
 	for i, event := range events {
 		location := ""
 		if event.GetLocation() != nil {
 			location = deref(event.GetLocation().GetDisplayName(), "")
 		}
-		fmt.Printf("%-3d  %-40s  %-20s  %-20s  %s\n",
+		subject := deref(event.GetSubject(), "(no subject)")
+		if eventTypeOf(event) == "seriesMaster" {
+			subject = "[series] " + subject
+		}
+		fmt.Printf("%-3d  %-40s  %-20s  %-20s  %-10s  %s\n",
 			i+1,
-			truncate(deref(event.GetSubject(), "(no subject)"), 40),
-			formatEventTime(event.GetStart()),
-			formatEventTime(event.GetEnd()),
+			truncate(subject, 40),
+			formatEventTime(event.GetStart(), isAllDay(event)),
+			formatEventTime(event.GetEnd(), isAllDay(event)),
+			truncate(showAsOf(event), 10),
 			truncate(location, 30),
 		)
 	}
+	fmt.Printf("\n(times in %s)\n", zoneLabel())
+}
+
+// ---------- Find by iCalUId ----------
 
+// FindUID prints the events whose iCalUId is uid — the UID an .ics file or
+// another calendar system knows the meeting by — and caches their IDs like
+// List does. A series is found by the UID of its series master.
+func FindUID(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, uid string, jsonOutput bool) error {
+	if uid == "" {
+		return fmt.Errorf("--uid is required")
+	}
+	filter := fmt.Sprintf("iCalUId eq '%s'", strings.ReplaceAll(uid, "'", "''"))
+	result, err := mailbox.Of(client).Events().Get(ctx, &users.ItemEventsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsRequestBuilderGetQueryParameters{
+			Filter: &filter,
+			Select: []string{"id", "iCalUId", "subject", "start", "end", "location", "locations", "organizer",
+				"isAllDay", "showAs", "type", "seriesMasterId", "recurrence"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("finding event: %w", err)
+	}
+	events := result.GetValue()
+
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, deref(event.GetId(), ""))
+	}
+	if len(ids) > 0 {
+		saveIDCache(ids)
+	}
+
+	if jsonOutput {
+		return printJSON(eventSummaries(events))
+	}
+	if len(events) == 0 {
+		fmt.Printf("No event has iCalUId %s.\n", uid)
+		return nil
+	}
+	printEventTable(events)
+	return nil
+}
+
+// ---------- Read ----------
+
+// Read prints the full details of the event identified by ref (list index or
+// Graph ID), including its attendees, body as plain text, and attachments.
+// With out, file attachments are saved to that directory.
+func Read(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, out string, jsonOutput bool) error {
+	id, err := resolveEventID(ref)
+	if err != nil {
+		return err
+	}
+	config := &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		Headers: abstractions.NewRequestHeaders(),
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "iCalUId", "subject", "start", "end", "location", "locations", "isAllDay", "organizer",
+				"attendees", "showAs", "isReminderOn", "reminderMinutesBeforeStart", "webLink", "body"},
+		},
+	}
+	config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+	event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, config)
+	if err != nil {
+		return fmt.Errorf("reading event: %w", err)
+	}
+
+	detail := EventDetail{
+		ID:        deref(event.GetId(), ""),
+		ICalUID:   deref(event.GetICalUId(), ""),
+		Subject:   deref(event.GetSubject(), ""),
+		Start:     formatEventTime(event.GetStart(), isAllDay(event)),
+		End:       formatEventTime(event.GetEnd(), isAllDay(event)),
+		TimeZone:  zoneLabel(),
+		IsAllDay:  isAllDay(event),
+		Locations: locationsOf(event),
+		Attendees: []string{},
+		ShowAs:    showAsOf(event),
+		WebLink:   deref(event.GetWebLink(), ""),
+	}
+	if event.GetIsReminderOn() != nil {
+		detail.IsReminderOn = *event.GetIsReminderOn()
+	}
+	if event.GetReminderMinutesBeforeStart() != nil {
+		detail.ReminderMinutes = *event.GetReminderMinutesBeforeStart()
+	}
+	if event.GetLocation() != nil {
+		detail.Location = deref(event.GetLocation().GetDisplayName(), "")
+	}
+	if event.GetOrganizer() != nil && event.GetOrganizer().GetEmailAddress() != nil {
+		detail.Organizer = deref(event.GetOrganizer().GetEmailAddress().GetAddress(), "")
+	}
+	detail.Responses = attendeeResponses(event)
+	for _, a := range detail.Responses {
+		detail.Attendees = append(detail.Attendees, a.Address)
+	}
+	if event.GetBody() != nil {
+		detail.Body = strings.TrimSpace(charset.Repair(deref(event.GetBody().GetContent(), "")))
+	}
+	detail.Attachments, err = eventAttachments(ctx, client, id, out)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(detail)
+	}
+
+	fmt.Printf("\nSubject   : %s\n", deref(event.GetSubject(), "(no subject)"))
+	fmt.Printf("When      : %s → %s (%s)\n", detail.Start, detail.End, detail.TimeZone)
+	if detail.Location != "" {
+		fmt.Printf("Location  : %s\n", detail.Location)
+	}
+	fmt.Printf("Organizer : %s\n", detail.Organizer)
+	if len(detail.Responses) > 0 {
+		var attendees []string
+		for _, a := range detail.Responses {
+			note := responseLabel(a.Response)
+			if a.Type != "required" {
+				note = a.Type + ", " + note
+			}
+			attendees = append(attendees, fmt.Sprintf("%s (%s)", a.Address, note))
+		}
+		fmt.Printf("Attendees : %s\n", strings.Join(attendees, ", "))
+	}
+	if detail.ShowAs != "" {
+		fmt.Printf("Show as   : %s\n", detail.ShowAs)
+	}
+	fmt.Printf("Reminder  : %s\n", reminderText(detail.IsReminderOn, detail.ReminderMinutes))
+	for _, a := range detail.Attachments {
+		line := fmt.Sprintf("%s (%s, %d KB)", a.Name, a.Kind, (a.Size+1023)/1024)
+		if a.File != "" {
+			line += " → " + a.File
+		}
+		fmt.Printf("Attachment: %s\n", line)
+	}
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Println(detail.Body)
 	return nil
 }
 
 // ---------- Create ----------
 
 // Create creates a new calendar event from explicit arguments — no interactive prompts.
-// startStr and endStr accept: "2006-01-02 15:04" or "2006-01-02T15:04".
-// attendees is a comma-separated list of email addresses (may be empty).
+// startStr and endStr accept "2006-01-02 15:04", "2006-01-02T15:04", or a
+// phrase such as "tomorrow 2pm"; startStr may give a range such as "next
+// tuesday 09:00-09:30" instead, and duration may stand in for endStr.
+// attendees is a comma-separated list of email addresses (may be empty), and
+// optional likewise lists the optional attendees.
+// showAs is the free/busy status shown to others (default: busy).
+// reminder is how long before the start Outlook reminds, such as 15m or 1h,
+// or none; empty leaves Outlook's default.
+// location may name several locations separated by semicolons; address, room,
+// and coordinates add structure to them as described at setLocations.
+// attach is a comma-separated list of files to attach.
+//
+// An event that would overlap time shown as busy, tentative, or out of office
+// is refused, with the events it overlaps, unless force is set.
 func Create(
 	ctx context.Context,
 	client *msgraphsdkgo.GraphServiceClient,
-	title, startStr, endStr, location, attendees string,
-	jsonOutput bool,
+	title, startStr, endStr string,
+	duration time.Duration,
+	location, attendees, optional, showAs, reminder string,
+	address, room, coordinates, attach string,
+	force, jsonOutput bool,
 ) error {
 	if title == "" {
 		return fmt.Errorf("--title is required")
 	}
 	if startStr == "" {
-		return fmt.Errorf("--start is required (format: 2006-01-02 15:04)")
-	}
-	if endStr == "" {
-		return fmt.Errorf("--end is required (format: 2006-01-02 15:04)")
+		return fmt.Errorf("--start is required (format: 2006-01-02 15:04, or e.g. \"tomorrow 2pm\")")
 	}
 
-	startTime, err := parseDateTime(startStr)
+	event, err := buildEvent(title, startStr, endStr, duration, "", attendees)
 	if err != nil {
-		return fmt.Errorf("invalid --start: %w", err)
+		return err
+	}
+	if optional != "" {
+		event.SetAttendees(append(event.GetAttendees(), attendeeList(optional, models.OPTIONAL_ATTENDEETYPE)...))
+	}
+	if err := setLocations(event, location, address, room, coordinates); err != nil {
+		return err
+	}
+	if showAs != "" {
+		status, err := parseShowAs(showAs)
+		if err != nil {
+			return err
+		}
+		event.SetShowAs(&status)
+	}
+	if reminder != "" {
+		if err := setReminder(event, reminder); err != nil {
+			return err
+		}
+	}
+	// An event shown as free or working elsewhere blocks no time, so it
+	// cannot double-book.
+	var conflicts []models.Eventable
+	if status := event.GetShowAs(); status == nil || (*status != models.FREE_FREEBUSYSTATUS && *status != models.WORKINGELSEWHERE_FREEBUSYSTATUS) {
+		conflicts, err = conflictsWith(ctx, client, parseEventTime(event.GetStart()), parseEventTime(event.GetEnd()))
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 && !force {
+			if jsonOutput {
+				if err := printJSON(ConflictReport{
+					Subject:   title,
+					Start:     formatEventTime(event.GetStart(), false),
+					End:       formatEventTime(event.GetEnd(), false),
+					Conflicts: eventSummaries(conflicts),
+				}); err != nil {
+					return err
+				}
+			}
+			return fmt.Errorf("%q overlaps %d events:\n  %s\nchoose another time, or give --force to create it anyway", title, len(conflicts), conflictLines(conflicts))
+		}
 	}
-	endTime, err := parseDateTime(endStr)
+	// Small files go in the create request itself; larger ones can only be
+	// uploaded once the event exists.
+	small, large, err := fileAttachments(attach)
 	if err != nil {
-		return fmt.Errorf("invalid --end: %w", err)
+		return err
+	}
+	if len(small) > 0 {
+		event.SetAttachments(small)
 	}
 
-	event := models.NewEvent()
-	event.SetSubject(&title)
+	created, err := mailbox.Of(client).Events().Post(ctx, event, nil)
+	if err != nil {
+		return fmt.Errorf("creating event: %w", err)
+	}
+	if err := addAttachments(ctx, client, deref(created.GetId(), ""), nil, large); err != nil {
+		return fmt.Errorf("event created, but %w", err)
+	}
 
-	tz := "UTC"
-	startDT := models.NewDateTimeTimeZone()
-	startFormatted := startTime.Format("2006-01-02T15:04:05")
-	startDT.SetDateTime(&startFormatted)
-	startDT.SetTimeZone(&tz)
-	event.SetStart(startDT)
+	if len(conflicts) > 0 {
+		slog.Warn("Event overlaps other events", "count", len(conflicts))
+	}
+	if jsonOutput {
+		result := EventCreated{
+			ID:      deref(created.GetId(), ""),
+			ICalUID: deref(created.GetICalUId(), ""),
+			Subject: deref(created.GetSubject(), title),
+			WebLink: deref(created.GetWebLink(), ""),
+		}
+		if len(conflicts) > 0 {
+			result.Conflicts = eventSummaries(conflicts)
+		}
+		return printJSON(result)
+	}
 
-	endDT := models.NewDateTimeTimeZone()
-	endFormatted := endTime.Format("2006-01-02T15:04:05")
-	endDT.SetDateTime(&endFormatted)
-	endDT.SetTimeZone(&tz)
-	event.SetEnd(endDT)
+	slog.Info("Event created", "subject", deref(created.GetSubject(), title), "webLink", deref(created.GetWebLink(), ""))
+	return nil
+}
 
-	if location != "" {
-		loc := models.NewLocation()
-		loc.SetDisplayName(&location)
-		event.SetLocation(loc)
+// ---------- Update ----------
+
+// Update changes the event identified by ref (list index or Graph ID). Only
+// non-empty arguments are sent; everything else about the event is kept.
+// startStr, endStr, and duration are read as Create reads them, except that a
+// new start alone keeps the event's length, and a duration alone counts from
+// its current start. location may name several locations separated by
+// semicolons, and replaces them all.
+// attendees, when given, replaces the required attendees and optional the
+// optional ones; the other kind and booked rooms are kept. Exchange sends
+// updates to the attendees. attach is a comma-separated list of files to add
+// to its attachments.
+func Update(
+	ctx context.Context,
+	client *msgraphsdkgo.GraphServiceClient,
+	ref, title, startStr, endStr string,
+	duration time.Duration,
+	location, attendees, optional, showAs, reminder, attach string,
+	jsonOutput bool,
+) error {
+	id, err := resolveEventID(ref)
+	if err != nil {
+		return err
 	}
 
-	if attendees != "" {
-		var attendeeList []models.Attendeeable
-		for _, email := range strings.Split(attendees, ",") {
-			email = strings.TrimSpace(email)
-			if email == "" {
-				continue
-			}
-			addr := models.NewEmailAddress()
-			addr.SetAddress(&email)
-			attendee := models.NewAttendee()
-			attendee.SetEmailAddress(addr)
-			attendeeType := models.REQUIRED_ATTENDEETYPE
-			attendee.SetTypeEscaped(&attendeeType)
-			attendeeList = append(attendeeList, attendee)
+	patch := models.NewEvent()
+	changed := false
+	if title != "" {
+		patch.SetSubject(&title)
+		changed = true
+	}
+	if startStr != "" || endStr != "" || duration != 0 {
+		start, end, err := updatedTimes(ctx, client, id, startStr, endStr, duration)
+		if err != nil {
+			return err
+		}
+		if !start.IsZero() {
+			patch.SetStart(dateTimeTimeZone(start))
+		}
+		patch.SetEnd(dateTimeTimeZone(end))
+		changed = true
+	}
+	if location != "" {
+		if err := setLocations(patch, location, "", "", ""); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if attendees != "" || optional != "" {
+		list, err := replaceAttendees(ctx, client, id, attendees, optional)
+		if err != nil {
+			return err
+		}
+		patch.SetAttendees(list)
+		changed = true
+	}
+	if showAs != "" {
+		status, err := parseShowAs(showAs)
+		if err != nil {
+			return err
+		}
+		patch.SetShowAs(&status)
+		changed = true
+	}
+	if reminder != "" {
+		if err := setReminder(patch, reminder); err != nil {
+			return err
 		}
-		event.SetAttendees(attendeeList)
+		changed = true
+	}
+	small, large, err := fileAttachments(attach)
+	if err != nil {
+		return err
+	}
+	if !changed && len(small)+len(large) == 0 {
+		return fmt.Errorf("nothing to update — give at least one of --title, --start, --end, --duration, --location, --attendees, --optional-attendees, --show-as, --reminder, --attach")
 	}
 
-	created, err := client.Me().Events().Post(ctx, event, nil)
+	if err := addAttachments(ctx, client, id, small, large); err != nil {
+		return err
+	}
+	var updated models.Eventable
+	if changed {
+		updated, err = mailbox.Of(client).Events().ByEventId(id).Patch(ctx, patch, nil)
+	} else {
+		updated, err = mailbox.Of(client).Events().ByEventId(id).Get(ctx, nil)
+	}
 	if err != nil {
-		return fmt.Errorf("creating event: %w", err)
+		return fmt.Errorf("updating event: %w", err)
 	}
 
 	if jsonOutput {
 		return printJSON(EventCreated{
-			ID:      deref(created.GetId(), ""),
-			Subject: deref(created.GetSubject(), title),
-			WebLink: deref(created.GetWebLink(), ""),
+			ID:      deref(updated.GetId(), id),
+			ICalUID: deref(updated.GetICalUId(), ""),
+			Subject: deref(updated.GetSubject(), ""),
+			WebLink: deref(updated.GetWebLink(), ""),
 		})
 	}
+	slog.Info("Event updated", "subject", deref(updated.GetSubject(), ""))
+	return nil
+}
+
+// updatedTimes works out an event's new start and end for Update; a zero
+// start leaves the start as it is. An --end alone moves only the end. A
+// --start with --end or --duration, or with a time range, is read as Create
+// reads it. Otherwise the event's current times fill in: a new start keeps its
+// length, and a duration alone counts from its current start.
+func updatedTimes(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, id, startStr, endStr string, duration time.Duration) (time.Time, time.Time, error) {
+	now := time.Now()
+	switch {
+	case startStr == "" && duration == 0:
+		end, err := parseWhen(endStr, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --end: %w", err)
+		}
+		return time.Time{}, end, nil
+	case startStr != "" && (endStr != "" || duration != 0 || rangePattern.MatchString(strings.TrimSpace(startStr)) && !isDate(startStr)):
+		return eventTimes(startStr, endStr, duration)
+	case endStr != "":
+		return time.Time{}, time.Time{}, fmt.Errorf("use either --end or --duration, not both")
+	case duration < 0:
+		return time.Time{}, time.Time{}, fmt.Errorf("--duration must be positive")
+	}
+
+	current, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"start", "end"},
+		},
+	})
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("reading event: %w", err)
+	}
+	currentStart := parseEventTime(current.GetStart())
+	if startStr == "" {
+		return time.Time{}, currentStart.Add(duration), nil
+	}
+	start, err := parseWhen(startStr, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %w", err)
+	}
+	return start, start.Add(parseEventTime(current.GetEnd()).Sub(currentStart)), nil
+}
+
+// ---------- Delete ----------
+
+// Delete removes the event identified by ref (list index or Graph ID). A
+// meeting you organize is cancelled instead, so its attendees are told and
+// message, if given, is sent to them with the cancellation. Any other event
+// is deleted from your calendar only, and message is refused because nobody
+// would receive it.
+func Delete(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, message string) error {
+	id, err := resolveEventID(ref)
+	if err != nil {
+		return err
+	}
+
+	event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject", "isOrganizer", "attendees"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading event: %w", err)
+	}
+	subject := deref(event.GetSubject(), "")
+
+	isOrganizer := event.GetIsOrganizer() != nil && *event.GetIsOrganizer()
+	if !isOrganizer || len(event.GetAttendees()) == 0 {
+		if message != "" {
+			return fmt.Errorf("--body is only sent when cancelling a meeting you organize with attendees, and %q is not one", subject)
+		}
+		if err := mailbox.Of(client).Events().ByEventId(id).Delete(ctx, nil); err != nil {
+			return fmt.Errorf("deleting event: %w", err)
+		}
+		slog.Info("Event deleted", "subject", subject)
+		return nil
+	}
 
-	fmt.Fprintf(os.Stderr, "Event created: %s\n", deref(created.GetSubject(), title))
-	if created.GetWebLink() != nil {
-		fmt.Fprintf(os.Stderr, "Open in Outlook: %s\n", deref(created.GetWebLink(), ""))
+	cancel := users.NewItemEventsItemCancelPostRequestBody()
+	if message != "" {
+		cancel.SetComment(&message)
 	}
+	if err := mailbox.Of(client).Events().ByEventId(id).Cancel().Post(ctx, cancel, nil); err != nil {
+		return fmt.Errorf("cancelling event: %w", err)
+	}
+	slog.Info("Event cancelled", "subject", subject, "attendees", len(event.GetAttendees()))
 	return nil
 }
 
 // ---------- Helpers ----------
 
-func formatEventTime(dt models.DateTimeTimeZoneable) string {
-	if dt == nil {
+// showAsValues maps --show-as values to free/busy statuses.
+var showAsValues = map[string]models.FreeBusyStatus{
+	"free":             models.FREE_FREEBUSYSTATUS,
+	"tentative":        models.TENTATIVE_FREEBUSYSTATUS,
+	"busy":             models.BUSY_FREEBUSYSTATUS,
+	"oof":              models.OOF_FREEBUSYSTATUS,
+	"workingelsewhere": models.WORKINGELSEWHERE_FREEBUSYSTATUS,
+}
+
+func parseShowAs(s string) (models.FreeBusyStatus, error) {
+	status, ok := showAsValues[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return 0, fmt.Errorf("unknown --show-as %q — valid values: busy, free, tentative, oof, workingElsewhere", s)
+	}
+	return status, nil
+}
+
+// setReminder sets when Outlook reminds of event from a --reminder value: a
+// duration before the start such as 15m, 1h, 2d, or 1w, 0 for at the start,
+// or none for no reminder.
+func setReminder(event models.Eventable, reminder string) error {
+	on := false
+	s := strings.ToLower(strings.TrimSpace(reminder))
+	if s == "none" || s == "off" {
+		event.SetIsReminderOn(&on)
+		return nil
+	}
+	var d time.Duration
+	var err error
+	switch {
+	case s == "0":
+	case strings.HasSuffix(s, "d") || strings.HasSuffix(s, "w"):
+		n, convErr := strconv.Atoi(s[:len(s)-1])
+		if convErr != nil {
+			err = convErr
+			break
+		}
+		d = time.Duration(n) * 24 * time.Hour
+		if strings.HasSuffix(s, "w") {
+			d *= 7
+		}
+	default:
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < 0 || d%time.Minute != 0 || d > 4*7*24*time.Hour {
+		return fmt.Errorf("invalid --reminder %q — use a time before the start such as 15m, 1h, or 1d (up to 4w), 0, or none", reminder)
+	}
+	on = true
+	minutes := int32(d / time.Minute)
+	event.SetIsReminderOn(&on)
+	event.SetReminderMinutesBeforeStart(&minutes)
+	return nil
+}
+
+// reminderText describes a reminder setting: "15 minutes before", "1 day
+// before", "at start", or "none".
+func reminderText(on bool, minutes int32) string {
+	if !on {
+		return "none"
+	}
+	unit, n := "minute", minutes
+	switch {
+	case minutes == 0:
+		return "at start"
+	case minutes%(7*24*60) == 0:
+		unit, n = "week", minutes/(7*24*60)
+	case minutes%(24*60) == 0:
+		unit, n = "day", minutes/(24*60)
+	case minutes%60 == 0:
+		unit, n = "hour", minutes/60
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s before", n, unit)
+}
+
+func eventTypeOf(event models.Eventable) string {
+	if event.GetTypeEscaped() == nil {
 		return ""
 	}
-	s := deref(dt.GetDateTime(), "")
-	if s == "" {
+	return event.GetTypeEscaped().String()
+}
+
+func isAllDay(event models.Eventable) bool {
+	return event.GetIsAllDay() != nil && *event.GetIsAllDay()
+}
+
+func showAsOf(event models.Eventable) string {
+	if event.GetShowAs() == nil {
 		return ""
 	}
-	t, err := time.Parse("2006-01-02T15:04:05.9999999", s)
-	if err != nil {
-		t, err = time.Parse("2006-01-02T15:04:05", s)
+	return event.GetShowAs().String()
+}
+
+// eventsBetween returns every event occurrence overlapping [start, end), in
+// start order, following @odata.nextLink until the last page. Bodies, when
+// selected, come as plain text.
+func eventsBetween(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, start, end time.Time, fields []string) ([]models.Eventable, error) {
+	startStr := start.UTC().Format(time.RFC3339)
+	endStr := end.UTC().Format(time.RFC3339)
+	pageSize := int32(100)
+	config := &users.ItemCalendarViewRequestBuilderGetRequestConfiguration{
+		Headers: abstractions.NewRequestHeaders(),
+		QueryParameters: &users.ItemCalendarViewRequestBuilderGetQueryParameters{
+			StartDateTime: &startStr,
+			EndDateTime:   &endStr,
+			Select:        fields,
+			Top:           &pageSize,
+			Orderby:       []string{"start/dateTime ASC"},
+		},
+	}
+	config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+
+	var events []models.Eventable
+	builder := mailbox.Of(client).CalendarView()
+	for {
+		result, err := builder.Get(ctx, config)
 		if err != nil {
-			return s
+			return nil, err
 		}
+		events = append(events, result.GetValue()...)
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			return events, nil
+		}
+		builder = builder.WithUrl(*next)
+		config = &users.ItemCalendarViewRequestBuilderGetRequestConfiguration{Headers: config.Headers}
+	}
+}
+
+// buildEvent assembles an event from the same arguments Create takes.
+// attendees may be separated by commas or semicolons.
+func buildEvent(title, startStr, endStr string, duration time.Duration, location, attendees string) (models.Eventable, error) {
+	startTime, endTime, err := eventTimes(startStr, endStr, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	event := models.NewEvent()
+	event.SetSubject(&title)
+
+	event.SetStart(dateTimeTimeZone(startTime))
+	event.SetEnd(dateTimeTimeZone(endTime))
+
+	if location != "" {
+		loc := models.NewLocation()
+		loc.SetDisplayName(&location)
+		event.SetLocation(loc)
+	}
+
+	if attendees != "" {
+		event.SetAttendees(attendeeList(attendees, models.REQUIRED_ATTENDEETYPE))
+	}
+	return event, nil
+}
+
+// attendeeList turns email addresses separated by commas or semicolons into
+// attendees of the given type, required or optional.
+func attendeeList(attendees string, attendeeType models.AttendeeType) []models.Attendeeable {
+	var list []models.Attendeeable
+	for _, email := range strings.FieldsFunc(attendees, func(r rune) bool { return r == ',' || r == ';' }) {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		addr := models.NewEmailAddress()
+		addr.SetAddress(&email)
+		attendee := models.NewAttendee()
+		attendee.SetEmailAddress(addr)
+		attendee.SetTypeEscaped(&attendeeType)
+		list = append(list, attendee)
 	}
-	return t.Format("Jan 02 15:04")
+	return list
 }
 
 func parseDateTime(s string) (time.Time, error) {
@@ -255,17 +914,41 @@ func parseDateTime(s string) (time.Time, error) {
 		"02/01/2006 15:04",
 	}
 	for _, f := range formats {
-		if t, err := time.Parse(f, s); err == nil {
+		if t, err := time.ParseInLocation(f, s, time.Local); err == nil {
 			return t, nil
 		}
 	}
 	return time.Time{}, fmt.Errorf("could not parse %q — use format: 2006-01-02 15:04", s)
 }
 
+// parseAge returns the time that lies the age s before now.
+func parseAge(s string) (time.Time, error) {
+	t, ok := reldate.Age(s, time.Now())
+	if !ok {
+		return time.Time{}, fmt.Errorf("could not parse %q — use an age such as 12h, 7d, 3w, or 2mo", s)
+	}
+	return t, nil
+}
+
+// parseBound parses a --since or --before date: a date as parseDateTime
+// reads it, or a relative date such as 7d, yesterday, or last monday.
+func parseBound(s string) (time.Time, error) {
+	if t, ok := reldate.Parse(s, time.Now()); ok {
+		return t, nil
+	}
+	t, err := parseDateTime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse %q — use format: 2006-01-02 15:04, or %s", s, reldate.Forms)
+	}
+	return t, nil
+}
+
 func printJSON(v interface{}) error {
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(v)
+	return writeJSON(os.Stdout, v)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	return schema.Encode(w, v)
 }
 
 func deref(s *string, fallback string) string {
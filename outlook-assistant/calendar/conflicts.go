@@ -0,0 +1,60 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// ---------- Conflicts ----------
+
+// ConflictReport is the JSON representation of an event that was not created
+// because it overlaps others.
+type ConflictReport struct {
+	Created   bool           `json:"created"`
+	Subject   string         `json:"subject"`
+	Start     string         `json:"start"`
+	End       string         `json:"end"`
+	Conflicts []EventSummary `json:"conflicts"`
+}
+
+// conflictsWith returns the events that would overlap an event from start to
+// end: those shown as busy, tentative, or out of office. Events shown as free
+// or working elsewhere, and cancelled ones, leave the time open.
+func conflictsWith(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, start, end time.Time) ([]models.Eventable, error) {
+	events, err := localEventsBetween(ctx, client, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("checking for conflicts: %w", err)
+	}
+	var conflicts []models.Eventable
+	for _, e := range events {
+		if !e.start.Before(end) || !e.end.After(start) {
+			continue
+		}
+		showAs := e.event.GetShowAs()
+		if showAs == nil || *showAs == models.FREE_FREEBUSYSTATUS || *showAs == models.WORKINGELSEWHERE_FREEBUSYSTATUS {
+			continue
+		}
+		conflicts = append(conflicts, e.event)
+	}
+	return conflicts, nil
+}
+
+// conflictLines describes conflicting events one per line, for messages.
+func conflictLines(conflicts []models.Eventable) string {
+	lines := make([]string, 0, len(conflicts))
+	for _, event := range conflicts {
+		allDay := isAllDay(event)
+		lines = append(lines, fmt.Sprintf("%s  %s → %s  (%s)",
+			truncate(deref(event.GetSubject(), "(no subject)"), 40),
+			formatEventTime(event.GetStart(), allDay),
+			formatEventTime(event.GetEnd(), allDay),
+			showAsOf(event)))
+	}
+	return strings.Join(lines, "\n  ")
+}
@@ -0,0 +1,185 @@
+package calendar
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// ---------- Export ----------
+
+// exportColumns are always present; optional columns named by --include are
+// appended after them in the order given.
+var exportColumns = []string{
+	"id", "subject", "start", "end", "durationMinutes", "isAllDay",
+	"location", "organizer", "isOrganizer", "response", "showAs", "isCancelled",
+}
+
+// exportIncludes maps each --include value to its columns.
+var exportIncludes = map[string][]string{
+	"attendees":  {"attendeeCount", "attendees"},
+	"categories": {"categories"},
+}
+
+// Export writes one flat row per event between since and before, for
+// time-tracking and utilization reports. Recurring meetings are expanded into
+// their occurrences. Times are UTC, formatted "2006-01-02 15:04".
+//
+// include is a comma-separated list of optional column groups: attendees,
+// categories. With jsonOutput each row is an object keyed by column name;
+// otherwise rows are CSV with a header. out is a file path, or stdout when empty.
+func Export(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, since, before, include, out string, jsonOutput bool) error {
+	if since == "" || before == "" {
+		return fmt.Errorf("--since and --before are required for calendar export")
+	}
+	startTime, err := parseBound(since)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	endTime, err := parseBound(before)
+	if err != nil {
+		return fmt.Errorf("invalid --before: %w", err)
+	}
+
+	columns := append([]string{}, exportColumns...)
+	selectFields := []string{"id", "subject", "start", "end", "isAllDay", "location",
+		"organizer", "isOrganizer", "responseStatus", "showAs", "isCancelled"}
+	for _, name := range splitList(include) {
+		extra, ok := exportIncludes[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown --include %q — valid values: attendees, categories", name)
+		}
+		columns = append(columns, extra...)
+		selectFields = append(selectFields, strings.ToLower(name))
+	}
+
+	events, err := eventsBetween(ctx, client, startTime, endTime, selectFields)
+	if err != nil {
+		return fmt.Errorf("exporting calendar events: %w", err)
+	}
+	rows := make([]map[string]string, 0, len(events))
+	for _, event := range events {
+		rows = append(rows, exportRow(event))
+	}
+
+	w := os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if jsonOutput {
+		records := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			record := make(map[string]string, len(columns))
+			for _, c := range columns {
+				record[c] = row[c]
+			}
+			records = append(records, record)
+		}
+		if err := writeJSON(w, records); err != nil {
+			return err
+		}
+	} else {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		record := make([]string, len(columns))
+		for _, row := range rows {
+			for i, c := range columns {
+				record[i] = row[c]
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("writing CSV: %w", err)
+		}
+	}
+
+	if out != "" {
+		slog.Info("Exported events", "count", len(rows), "path", out)
+	} else {
+		slog.Info("Exported events", "count", len(rows))
+	}
+	return nil
+}
+
+// exportRow flattens an event into every export column, optional or not.
+func exportRow(event models.Eventable) map[string]string {
+	start := parseEventTime(event.GetStart())
+	end := parseEventTime(event.GetEnd())
+	duration := ""
+	if !start.IsZero() && !end.IsZero() {
+		duration = strconv.Itoa(int(end.Sub(start).Minutes()))
+	}
+
+	row := map[string]string{
+		"id":              deref(event.GetId(), ""),
+		"subject":         deref(event.GetSubject(), ""),
+		"start":           formatExportTime(start),
+		"end":             formatExportTime(end),
+		"durationMinutes": duration,
+		"isAllDay":        strconv.FormatBool(event.GetIsAllDay() != nil && *event.GetIsAllDay()),
+		"isOrganizer":     strconv.FormatBool(event.GetIsOrganizer() != nil && *event.GetIsOrganizer()),
+		"isCancelled":     strconv.FormatBool(event.GetIsCancelled() != nil && *event.GetIsCancelled()),
+	}
+	if event.GetLocation() != nil {
+		row["location"] = deref(event.GetLocation().GetDisplayName(), "")
+	}
+	if event.GetOrganizer() != nil && event.GetOrganizer().GetEmailAddress() != nil {
+		row["organizer"] = deref(event.GetOrganizer().GetEmailAddress().GetAddress(), "")
+	}
+	if rs := event.GetResponseStatus(); rs != nil && rs.GetResponse() != nil {
+		row["response"] = rs.GetResponse().String()
+	}
+	if event.GetShowAs() != nil {
+		row["showAs"] = event.GetShowAs().String()
+	}
+
+	var attendees []string
+	for _, a := range event.GetAttendees() {
+		if a.GetEmailAddress() != nil {
+			if addr := deref(a.GetEmailAddress().GetAddress(), ""); addr != "" {
+				attendees = append(attendees, addr)
+			}
+		}
+	}
+	row["attendeeCount"] = strconv.Itoa(len(attendees))
+	row["attendees"] = strings.Join(attendees, ";")
+	row["categories"] = strings.Join(event.GetCategories(), ";")
+	return row
+}
+
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
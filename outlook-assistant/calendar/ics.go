@@ -0,0 +1,1182 @@
+package calendar
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- iCalendar export ----------
+
+// icsFields are the event properties an iCalendar file carries.
+var icsFields = []string{"id", "iCalUId", "subject", "body", "start", "end", "isAllDay", "location",
+	"organizer", "attendees", "showAs", "sensitivity", "categories", "isCancelled",
+	"originalStartTimeZone", "createdDateTime", "lastModifiedDateTime"}
+
+// ICSExport is the JSON result of an iCalendar export.
+type ICSExport struct {
+	File   string `json:"file"`
+	Events int    `json:"events"`
+}
+
+// ExportICS writes events to out as an RFC 5545 iCalendar file, or to stdout
+// when out is "-". With ref it writes that one event; otherwise every event
+// between since and before, each recurring series once with its RRULE, so
+// another calendar recreates the whole series.
+func ExportICS(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, since, before, out string, jsonOutput bool) error {
+	var events []models.Eventable
+	if ref != "" {
+		if since != "" || before != "" {
+			return fmt.Errorf("use either --ref or --since/--before, not both")
+		}
+		id, err := resolveEventID(ref)
+		if err != nil {
+			return err
+		}
+		config := &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+			Headers: abstractions.NewRequestHeaders(),
+			QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+				Select: append(icsFields, "type", "recurrence"),
+			},
+		}
+		config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+		event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, config)
+		if err != nil {
+			return fmt.Errorf("reading event: %w", err)
+		}
+		events = append(events, event)
+	} else {
+		if since == "" || before == "" {
+			return fmt.Errorf("--ref, or --since and --before, are required for an iCalendar export")
+		}
+		startTime, err := parseBound(since)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		endTime, err := parseBound(before)
+		if err != nil {
+			return fmt.Errorf("invalid --before: %w", err)
+		}
+		if events, err = seriesMasters(ctx, client, startTime, endTime, icsFields, math.MaxInt); err != nil {
+			return fmt.Errorf("exporting calendar events: %w", err)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := io.WriteString(w, icsCalendar(events, time.Now())); err != nil {
+		return fmt.Errorf("writing %s: %w", out, err)
+	}
+
+	if out == "-" {
+		return nil
+	}
+	if jsonOutput {
+		return printJSON(ICSExport{File: out, Events: len(events)})
+	}
+	slog.Info("Exported events", "count", len(events), "path", out)
+	return nil
+}
+
+// icsCalendar renders events as a VCALENDAR, with a VTIMEZONE for each zone a
+// recurring series is pinned to. Lines end in CRLF and are folded at 75
+// octets, as RFC 5545 requires.
+func icsCalendar(events []models.Eventable, now time.Time) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(foldICS(s)) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//outlook-assistant//calendar export//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	zones := map[string]bool{}
+	for _, event := range events {
+		if loc := seriesZone(event); loc != nil && !zones[loc.String()] {
+			zones[loc.String()] = true
+			for _, l := range vtimezone(loc, now.Year()) {
+				line(l)
+			}
+		}
+	}
+	for _, event := range events {
+		for _, l := range vevent(event, now) {
+			line(l)
+		}
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// seriesZone returns the zone a timed recurring series repeats in, so that
+// its occurrences keep their wall-clock time across daylight-saving changes,
+// or nil when the event is a single one, all-day, or in UTC.
+func seriesZone(event models.Eventable) *time.Location {
+	r := recurrenceOf(event)
+	if r == nil || isAllDay(event) {
+		return nil
+	}
+	name := r.TimeZone
+	if name == "" {
+		name = deref(event.GetOriginalStartTimeZone(), "")
+	}
+	if name == "" && event.GetStart() != nil {
+		name = deref(event.GetStart().GetTimeZone(), "")
+	}
+	if name == "" || strings.EqualFold(name, "UTC") {
+		return nil
+	}
+	loc, err := loadZone(name)
+	if err != nil || loc == time.UTC {
+		return nil
+	}
+	return loc
+}
+
+// vevent renders one event as the unfolded lines of a VEVENT.
+func vevent(event models.Eventable, now time.Time) []string {
+	lines := []string{"BEGIN:VEVENT"}
+	add := func(s string) { lines = append(lines, s) }
+
+	uid := deref(event.GetICalUId(), "")
+	if uid == "" {
+		uid = deref(event.GetId(), "")
+	}
+	add("UID:" + escapeICS(uid))
+	add("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+	if t := event.GetCreatedDateTime(); t != nil {
+		add("CREATED:" + t.UTC().Format("20060102T150405Z"))
+	}
+	if t := event.GetLastModifiedDateTime(); t != nil {
+		add("LAST-MODIFIED:" + t.UTC().Format("20060102T150405Z"))
+	}
+
+	start, end := parseEventTime(event.GetStart()), parseEventTime(event.GetEnd())
+	zone := seriesZone(event)
+	switch {
+	case isAllDay(event):
+		add("DTSTART;VALUE=DATE:" + start.Format("20060102"))
+		add("DTEND;VALUE=DATE:" + end.Format("20060102"))
+	case zone != nil:
+		add("DTSTART;TZID=" + zone.String() + ":" + start.In(zone).Format("20060102T150405"))
+		add("DTEND;TZID=" + zone.String() + ":" + end.In(zone).Format("20060102T150405"))
+	default:
+		add("DTSTART:" + start.UTC().Format("20060102T150405Z"))
+		add("DTEND:" + end.UTC().Format("20060102T150405Z"))
+	}
+	if r := recurrenceOf(event); r != nil {
+		if rule := rrule(r, isAllDay(event), zone); rule != "" {
+			add("RRULE:" + rule)
+		}
+	}
+
+	add("SUMMARY:" + escapeICS(deref(event.GetSubject(), "")))
+	if event.GetLocation() != nil {
+		if loc := deref(event.GetLocation().GetDisplayName(), ""); loc != "" {
+			add("LOCATION:" + escapeICS(loc))
+		}
+	}
+	if event.GetBody() != nil {
+		if body := strings.TrimSpace(deref(event.GetBody().GetContent(), "")); body != "" {
+			add("DESCRIPTION:" + escapeICS(body))
+		}
+	}
+	if o := event.GetOrganizer(); o != nil && o.GetEmailAddress() != nil {
+		if addr := deref(o.GetEmailAddress().GetAddress(), ""); addr != "" {
+			add("ORGANIZER" + icsName(deref(o.GetEmailAddress().GetName(), "")) + ":mailto:" + addr)
+		}
+	}
+	for _, a := range event.GetAttendees() {
+		if a.GetEmailAddress() == nil {
+			continue
+		}
+		addr := deref(a.GetEmailAddress().GetAddress(), "")
+		if addr == "" {
+			continue
+		}
+		params := icsName(deref(a.GetEmailAddress().GetName(), ""))
+		switch {
+		case a.GetTypeEscaped() != nil && *a.GetTypeEscaped() == models.RESOURCE_ATTENDEETYPE:
+			params += ";CUTYPE=RESOURCE;ROLE=NON-PARTICIPANT"
+		case a.GetTypeEscaped() != nil && *a.GetTypeEscaped() == models.OPTIONAL_ATTENDEETYPE:
+			params += ";ROLE=OPT-PARTICIPANT"
+		default:
+			params += ";ROLE=REQ-PARTICIPANT"
+		}
+		partstat := "NEEDS-ACTION"
+		if s := a.GetStatus(); s != nil && s.GetResponse() != nil {
+			switch *s.GetResponse() {
+			case models.ACCEPTED_RESPONSETYPE, models.ORGANIZER_RESPONSETYPE:
+				partstat = "ACCEPTED"
+			case models.TENTATIVELYACCEPTED_RESPONSETYPE:
+				partstat = "TENTATIVE"
+			case models.DECLINED_RESPONSETYPE:
+				partstat = "DECLINED"
+			}
+		}
+		params += ";PARTSTAT=" + partstat
+		if partstat == "NEEDS-ACTION" {
+			params += ";RSVP=TRUE"
+		}
+		add("ATTENDEE" + params + ":mailto:" + addr)
+	}
+
+	if cats := event.GetCategories(); len(cats) > 0 {
+		escaped := make([]string, len(cats))
+		for i, c := range cats {
+			escaped[i] = escapeICS(c)
+		}
+		add("CATEGORIES:" + strings.Join(escaped, ","))
+	}
+	switch {
+	case event.GetIsCancelled() != nil && *event.GetIsCancelled():
+		add("STATUS:CANCELLED")
+	case event.GetShowAs() != nil && *event.GetShowAs() == models.TENTATIVE_FREEBUSYSTATUS:
+		add("STATUS:TENTATIVE")
+	default:
+		add("STATUS:CONFIRMED")
+	}
+	if event.GetShowAs() != nil && *event.GetShowAs() == models.FREE_FREEBUSYSTATUS {
+		add("TRANSP:TRANSPARENT")
+	} else {
+		add("TRANSP:OPAQUE")
+	}
+	if s := event.GetSensitivity(); s != nil {
+		switch *s {
+		case models.PRIVATE_SENSITIVITY:
+			add("CLASS:PRIVATE")
+		case models.CONFIDENTIAL_SENSITIVITY:
+			add("CLASS:CONFIDENTIAL")
+		}
+	}
+	return append(lines, "END:VEVENT")
+}
+
+// icsName returns the CN parameter for a display name, or "" for none.
+// Double quotes cannot appear in a quoted parameter value, so they are dropped.
+func icsName(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, `"`, ""))
+	if name == "" {
+		return ""
+	}
+	return `;CN="` + name + `"`
+}
+
+// icsDays maps Graph day names to RRULE weekdays.
+var icsDays = map[string]string{
+	"sunday": "SU", "monday": "MO", "tuesday": "TU", "wednesday": "WE",
+	"thursday": "TH", "friday": "FR", "saturday": "SA",
+}
+
+// icsIndex maps Graph week indexes to BYSETPOS values.
+var icsIndex = map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "last": -1}
+
+// rrule renders a Graph recurrence as an RRULE value, or "" for a pattern it
+// does not know. An end date becomes an UNTIL at the end of that day, in zone
+// for a timed series and as a date for an all-day one.
+func rrule(r *Recurrence, allDay bool, zone *time.Location) string {
+	var parts []string
+	days := func() string {
+		var out []string
+		for _, d := range r.DaysOfWeek {
+			out = append(out, icsDays[strings.ToLower(d)])
+		}
+		return strings.Join(out, ",")
+	}
+	switch r.Pattern {
+	case "daily":
+		parts = append(parts, "FREQ=DAILY")
+	case "weekly":
+		parts = append(parts, "FREQ=WEEKLY")
+		if d := days(); d != "" {
+			parts = append(parts, "BYDAY="+d)
+		}
+		if wkst, ok := icsDays[strings.ToLower(r.FirstDayOfWeek)]; ok {
+			parts = append(parts, "WKST="+wkst)
+		}
+	case "absoluteMonthly":
+		parts = append(parts, "FREQ=MONTHLY", fmt.Sprintf("BYMONTHDAY=%d", r.DayOfMonth))
+	case "relativeMonthly":
+		parts = append(parts, "FREQ=MONTHLY", "BYDAY="+days())
+		if pos, ok := icsIndex[r.Index]; ok {
+			parts = append(parts, fmt.Sprintf("BYSETPOS=%d", pos))
+		}
+	case "absoluteYearly":
+		parts = append(parts, "FREQ=YEARLY", fmt.Sprintf("BYMONTH=%d", r.Month), fmt.Sprintf("BYMONTHDAY=%d", r.DayOfMonth))
+	case "relativeYearly":
+		parts = append(parts, "FREQ=YEARLY", fmt.Sprintf("BYMONTH=%d", r.Month), "BYDAY="+days())
+		if pos, ok := icsIndex[r.Index]; ok {
+			parts = append(parts, fmt.Sprintf("BYSETPOS=%d", pos))
+		}
+	default:
+		return ""
+	}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	switch r.Range {
+	case "numbered":
+		if r.Occurrences > 0 {
+			parts = append(parts, fmt.Sprintf("COUNT=%d", r.Occurrences))
+		}
+	case "endDate":
+		loc := zone
+		if loc == nil {
+			loc = time.UTC
+		}
+		if until, err := time.ParseInLocation("2006-01-02", r.EndDate, loc); err == nil {
+			if allDay {
+				parts = append(parts, "UNTIL="+until.Format("20060102"))
+			} else {
+				parts = append(parts, "UNTIL="+until.AddDate(0, 0, 1).Add(-time.Second).UTC().Format("20060102T150405Z"))
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// vtimezone describes loc as the unfolded lines of a VTIMEZONE, with its
+// standard and daylight-saving offsets and the yearly rules that switch
+// between them, worked out from the transitions in year.
+func vtimezone(loc *time.Location, year int) []string {
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+	transitions := zoneTransitions(loc, year)
+	if len(transitions) == 0 {
+		_, offset := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+		lines = append(lines, "BEGIN:STANDARD", "DTSTART:19700101T000000",
+			"TZOFFSETFROM:"+icsOffset(offset), "TZOFFSETTO:"+icsOffset(offset), "END:STANDARD")
+		return append(lines, "END:VTIMEZONE")
+	}
+	for _, t := range transitions {
+		_, before := t.Add(-time.Second).Zone()
+		name, after := t.Zone()
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		// DTSTART is the wall-clock time, in the offset before the change, at
+		// which the change happens.
+		local := t.In(time.FixedZone("", before))
+		nth := (local.Day()-1)/7 + 1
+		if local.AddDate(0, 0, 7).Month() != local.Month() {
+			nth = -1
+		}
+		lines = append(lines,
+			"BEGIN:"+kind,
+			"DTSTART:"+nthWeekday(1970, local.Month(), local.Weekday(), nth).Format("20060102")+local.Format("T150405"),
+			fmt.Sprintf("RRULE:FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(local.Month()), nth, icsDays[strings.ToLower(local.Weekday().String())]),
+			"TZOFFSETFROM:"+icsOffset(before),
+			"TZOFFSETTO:"+icsOffset(after),
+		)
+		if name != "" && !strings.HasPrefix(name, "+") && !strings.HasPrefix(name, "-") {
+			lines = append(lines, "TZNAME:"+name)
+		}
+		lines = append(lines, "END:"+kind)
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+// zoneTransitions returns the moments in year at which loc's UTC offset
+// changes, found by scanning the year a day at a time and narrowing each
+// change down to the second.
+func zoneTransitions(loc *time.Location, year int) []time.Time {
+	var out []time.Time
+	t := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := t.AddDate(1, 0, 0)
+	_, offset := t.Zone()
+	for t.Before(end) {
+		next := t.Add(24 * time.Hour)
+		if _, o := next.Zone(); o != offset {
+			lo, hi := t, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, o := mid.Zone(); o == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			out = append(out, hi)
+			offset = o
+		}
+		t = next
+	}
+	return out
+}
+
+// nthWeekday returns the nth given weekday of month in year, counting from
+// the end of the month when nth is -1.
+func nthWeekday(year int, month time.Month, day time.Weekday, nth int) time.Time {
+	if nth < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(day) + 7) % 7))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, (int(day)-int(first.Weekday())+7)%7+7*(nth-1))
+}
+
+// icsOffset formats a UTC offset in seconds as +HHMM.
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// escapeICS escapes a TEXT value: backslashes, semicolons, commas, and line
+// breaks.
+func escapeICS(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// foldICS ends a content line with CRLF, folding it into lines of at most 75
+// octets, each continuation starting with a space. It never splits a UTF-8
+// sequence.
+func foldICS(s string) string {
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// ---------- iCalendar import ----------
+
+// icsProperty is one content line: NAME;PARAM=VALUE:value. Names and
+// parameter names are upper-cased; parameter values lose their quotes.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsComponent is a BEGIN/END block, such as a VEVENT or VTIMEZONE, with the
+// components nested in it.
+type icsComponent struct {
+	name       string
+	props      []icsProperty
+	components []*icsComponent
+}
+
+// prop returns the first property called name, or a zero property.
+func (c *icsComponent) prop(name string) icsProperty {
+	for _, p := range c.props {
+		if p.name == name {
+			return p
+		}
+	}
+	return icsProperty{params: map[string]string{}}
+}
+
+// icsWhen is a DTSTART or DTEND. zone is the Graph time zone it is sent in,
+// or "" for a floating time, which is read in time.Local.
+type icsWhen struct {
+	t      time.Time
+	allDay bool
+	zone   string
+}
+
+// ICSImportResult is the JSON representation of one imported VEVENT.
+type ICSImportResult struct {
+	Event   int    `json:"event"`
+	Title   string `json:"title"`
+	UID     string `json:"uid,omitempty"`
+	ID      string `json:"id,omitempty"`
+	ICalUID string `json:"iCalUId,omitempty"`
+	WebLink string `json:"webLink,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ImportICS creates an event from each VEVENT in an iCalendar file, such as
+// an invitation from a booking system: its times in their own time zone, its
+// recurrence rule, attendees, location, and description. An event that fails
+// is reported and skipped. Changes to single occurrences of a series
+// (RECURRENCE-ID) are skipped, as Graph creates a series whole.
+func ImportICS(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, file string, jsonOutput bool) error {
+	if file == "" {
+		return fmt.Errorf("--file is required")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", file, err)
+	}
+	defer f.Close()
+	calendars, err := parseICS(f)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	var vevents []*icsComponent
+	zones := map[string]*time.Location{}
+	for _, cal := range calendars {
+		for _, c := range cal.components {
+			switch c.name {
+			case "VEVENT":
+				vevents = append(vevents, c)
+			case "VTIMEZONE":
+				if tzid := c.prop("TZID").value; tzid != "" {
+					zones[tzid] = vtimezoneLocation(c)
+				}
+			}
+		}
+	}
+	if len(vevents) == 0 {
+		return fmt.Errorf("no events (VEVENT) found in %s", file)
+	}
+
+	results := make([]ICSImportResult, 0, len(vevents))
+	failed := 0
+	for i, vevent := range vevents {
+		res := ICSImportResult{
+			Event: i + 1,
+			Title: unescapeICS(vevent.prop("SUMMARY").value),
+			UID:   vevent.prop("UID").value,
+		}
+		event, err := eventFromICS(vevent, zones)
+		if err == nil {
+			var created models.Eventable
+			if created, err = mailbox.Of(client).Events().Post(ctx, event, nil); err != nil {
+				err = fmt.Errorf("creating event: %w", err)
+			} else {
+				res.ID = deref(created.GetId(), "")
+				res.ICalUID = deref(created.GetICalUId(), "")
+				res.WebLink = deref(created.GetWebLink(), "")
+			}
+		}
+		if err != nil {
+			res.Error = err.Error()
+			failed++
+		}
+		results = append(results, res)
+	}
+
+	if jsonOutput {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("event %-4d  FAILED   %-40s  %s\n", r.Event, truncate(r.Title, 40), r.Error)
+			} else {
+				fmt.Printf("event %-4d  created  %s\n", r.Event, truncate(r.Title, 40))
+			}
+		}
+	}
+
+	slog.Info("Imported events", "imported", len(vevents)-failed, "total", len(vevents))
+	if failed > 0 {
+		return fmt.Errorf("%d events could not be imported", failed)
+	}
+	return nil
+}
+
+// parseICS reads the VCALENDAR components in r.
+func parseICS(r io.Reader) ([]*icsComponent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	// Unfold: a line starting with a space or tab continues the previous one.
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var calendars []*icsComponent
+	var stack []*icsComponent
+	for n, line := range lines {
+		p, ok := parseICSProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: not an iCalendar property: %q", n+1, truncate(line, 40))
+		}
+		switch p.name {
+		case "BEGIN":
+			c := &icsComponent{name: strings.ToUpper(p.value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, c)
+			} else if c.name == "VCALENDAR" {
+				calendars = append(calendars, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("line %d: END:%s does not close an open component", n+1, p.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) > 0 {
+				c := stack[len(stack)-1]
+				c.props = append(c.props, p)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s is not closed with END:%s", stack[len(stack)-1].name, stack[len(stack)-1].name)
+	}
+	return calendars, nil
+}
+
+// parseICSProperty splits NAME[;params]:value, honouring quoted parameter
+// values that may contain ':' or ';'.
+func parseICSProperty(line string) (icsProperty, bool) {
+	colon, quoted := -1, false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return icsProperty{}, false
+	}
+	head := splitUnquoted(line[:colon], ';')
+	p := icsProperty{name: strings.ToUpper(head[0]), params: map[string]string{}, value: line[colon+1:]}
+	for _, param := range head[1:] {
+		if key, value, found := strings.Cut(param, "="); found {
+			p.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return p, true
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitICS splits a TEXT list on commas that are not backslash-escaped, then
+// unescapes each part.
+func splitICS(s string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+		case s[i] == ',':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+func unescapeICS(s string) string {
+	return strings.Join(splitICS(s), ",")
+}
+
+// vtimezoneLocation returns the location a VTIMEZONE stands for: the zone its
+// TZID names when Go knows it (an IANA name, or a Windows name as Outlook
+// writes), or else its standard-time offset, without daylight saving.
+func vtimezoneLocation(c *icsComponent) *time.Location {
+	tzid := c.prop("TZID").value
+	if loc, err := icsZone(tzid); err == nil {
+		return loc
+	}
+	for _, sub := range c.components {
+		if sub.name != "STANDARD" {
+			continue
+		}
+		if offset, ok := parseICSOffset(sub.prop("TZOFFSETTO").value); ok {
+			return time.FixedZone(tzid, offset)
+		}
+	}
+	return nil
+}
+
+// icsZone loads a TZID, which some calendars write with a leading slash.
+func icsZone(tzid string) (*time.Location, error) {
+	return loadZone(strings.TrimPrefix(tzid, "/"))
+}
+
+// parseICSOffset parses a UTC offset such as +0100 or -053000 into seconds.
+func parseICSOffset(s string) (int, bool) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, false
+	}
+	var h, m, sec int
+	if _, err := fmt.Sscanf(s[1:5], "%02d%02d", &h, &m); err != nil {
+		return 0, false
+	}
+	if len(s) == 7 {
+		if _, err := fmt.Sscanf(s[5:], "%02d", &sec); err != nil {
+			return 0, false
+		}
+	}
+	offset := h*3600 + m*60 + sec
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// parseICSTime reads a DATE or DATE-TIME property: a date for an all-day
+// event, a UTC time ending in Z, a time in the zone its TZID names, or a
+// floating time, read in time.Local.
+func parseICSTime(p icsProperty, zones map[string]*time.Location) (icsWhen, error) {
+	value := strings.TrimSpace(p.value)
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.UTC)
+		if err != nil {
+			return icsWhen{}, fmt.Errorf("invalid %s date %q", p.name, value)
+		}
+		return icsWhen{t: t, allDay: true}, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return icsWhen{}, fmt.Errorf("invalid %s %q", p.name, value)
+		}
+		return icsWhen{t: t, zone: "UTC"}, nil
+	}
+	loc, zone := time.Local, ""
+	if tzid := p.params["TZID"]; tzid != "" {
+		loc = zones[tzid]
+		if loc == nil {
+			l, err := icsZone(tzid)
+			if err != nil {
+				return icsWhen{}, fmt.Errorf("%s: %w", p.name, err)
+			}
+			loc = l
+		}
+		// A zone known only by its VTIMEZONE offset has no name Graph accepts.
+		if zone = loc.String(); zone == tzid {
+			if _, err := icsZone(tzid); err != nil {
+				zone = "UTC"
+			}
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return icsWhen{}, fmt.Errorf("invalid %s %q", p.name, value)
+	}
+	if zone == "UTC" {
+		t = t.UTC()
+	}
+	return icsWhen{t: t, zone: zone}, nil
+}
+
+// graphTime converts a DTSTART or DTEND into a Graph dateTime. All-day
+// events are sent as midnight in the local zone, as Graph requires.
+func graphTime(w icsWhen) models.DateTimeTimeZoneable {
+	if w.allDay {
+		tz := zoneName(time.Local)
+		if tz == "" {
+			tz = "UTC"
+		}
+		formatted := w.t.Format("2006-01-02") + "T00:00:00"
+		dt := models.NewDateTimeTimeZone()
+		dt.SetDateTime(&formatted)
+		dt.SetTimeZone(&tz)
+		return dt
+	}
+	if w.zone == "" {
+		return dateTimeTimeZone(w.t)
+	}
+	formatted := w.t.Format("2006-01-02T15:04:05")
+	dt := models.NewDateTimeTimeZone()
+	dt.SetDateTime(&formatted)
+	dt.SetTimeZone(&w.zone)
+	return dt
+}
+
+// eventFromICS builds the Graph event for a VEVENT.
+func eventFromICS(c *icsComponent, zones map[string]*time.Location) (models.Eventable, error) {
+	if c.prop("RECURRENCE-ID").value != "" {
+		return nil, fmt.Errorf("changes one occurrence of a series (RECURRENCE-ID), which cannot be imported on its own")
+	}
+	if c.prop("DTSTART").value == "" {
+		return nil, fmt.Errorf("no DTSTART")
+	}
+	start, err := parseICSTime(c.prop("DTSTART"), zones)
+	if err != nil {
+		return nil, err
+	}
+	var end icsWhen
+	switch {
+	case c.prop("DTEND").value != "":
+		if end, err = parseICSTime(c.prop("DTEND"), zones); err != nil {
+			return nil, err
+		}
+	case c.prop("DURATION").value != "":
+		days, d, err := parseICSDuration(c.prop("DURATION").value)
+		if err != nil {
+			return nil, err
+		}
+		end = start
+		end.t = start.t.AddDate(0, 0, days).Add(d)
+	case start.allDay:
+		end = start
+		end.t = start.t.AddDate(0, 0, 1)
+	default:
+		end = start
+	}
+	if end.t.Before(start.t) {
+		return nil, fmt.Errorf("ends before it starts")
+	}
+
+	event := models.NewEvent()
+	subject := unescapeICS(c.prop("SUMMARY").value)
+	event.SetSubject(&subject)
+	event.SetStart(graphTime(start))
+	event.SetEnd(graphTime(end))
+	if start.allDay {
+		allDay := true
+		event.SetIsAllDay(&allDay)
+	}
+	if text := unescapeICS(c.prop("DESCRIPTION").value); strings.TrimSpace(text) != "" {
+		body := models.NewItemBody()
+		contentType := models.TEXT_BODYTYPE
+		body.SetContentType(&contentType)
+		body.SetContent(&text)
+		event.SetBody(body)
+	}
+	if where := unescapeICS(c.prop("LOCATION").value); where != "" {
+		loc := models.NewLocation()
+		loc.SetDisplayName(&where)
+		event.SetLocation(loc)
+	}
+
+	var attendees []models.Attendeeable
+	var categories []string
+	for _, p := range c.props {
+		switch p.name {
+		case "ATTENDEE":
+			email := p.value
+			if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+				email = email[7:]
+			}
+			if email = strings.TrimSpace(email); email == "" {
+				continue
+			}
+			addr := models.NewEmailAddress()
+			addr.SetAddress(&email)
+			if name := p.params["CN"]; name != "" {
+				addr.SetName(&name)
+			}
+			attendeeType := models.REQUIRED_ATTENDEETYPE
+			switch {
+			case strings.EqualFold(p.params["CUTYPE"], "RESOURCE"), strings.EqualFold(p.params["CUTYPE"], "ROOM"),
+				strings.EqualFold(p.params["ROLE"], "NON-PARTICIPANT"):
+				attendeeType = models.RESOURCE_ATTENDEETYPE
+			case strings.EqualFold(p.params["ROLE"], "OPT-PARTICIPANT"):
+				attendeeType = models.OPTIONAL_ATTENDEETYPE
+			}
+			attendee := models.NewAttendee()
+			attendee.SetEmailAddress(addr)
+			attendee.SetTypeEscaped(&attendeeType)
+			attendees = append(attendees, attendee)
+		case "CATEGORIES":
+			for _, cat := range splitICS(p.value) {
+				if cat = strings.TrimSpace(cat); cat != "" {
+					categories = append(categories, cat)
+				}
+			}
+		}
+	}
+	if len(attendees) > 0 {
+		event.SetAttendees(attendees)
+	}
+	if len(categories) > 0 {
+		event.SetCategories(categories)
+	}
+
+	if strings.EqualFold(c.prop("TRANSP").value, "TRANSPARENT") {
+		showAs := models.FREE_FREEBUSYSTATUS
+		event.SetShowAs(&showAs)
+	}
+	switch strings.ToUpper(c.prop("CLASS").value) {
+	case "PRIVATE":
+		sensitivity := models.PRIVATE_SENSITIVITY
+		event.SetSensitivity(&sensitivity)
+	case "CONFIDENTIAL":
+		sensitivity := models.CONFIDENTIAL_SENSITIVITY
+		event.SetSensitivity(&sensitivity)
+	}
+
+	if rule := c.prop("RRULE").value; rule != "" {
+		recurrence, err := recurrenceFromRRULE(rule, start, zones)
+		if err != nil {
+			return nil, err
+		}
+		event.SetRecurrence(recurrence)
+	}
+	return event, nil
+}
+
+// icsDuration matches a DURATION value such as PT30M, P1D, or P1DT2H.
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICSDuration splits a DURATION into whole days, which keep their
+// wall-clock time across daylight-saving changes, and the rest.
+func parseICSDuration(s string) (days int, d time.Duration, err error) {
+	m := icsDuration.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil || m[1] == "-" {
+		return 0, 0, fmt.Errorf("invalid DURATION %q", s)
+	}
+	n := func(i int) int {
+		v, _ := strconv.Atoi(m[i])
+		return v
+	}
+	days = 7*n(2) + n(3)
+	d = time.Duration(n(4))*time.Hour + time.Duration(n(5))*time.Minute + time.Duration(n(6))*time.Second
+	return days, d, nil
+}
+
+// icsWeekday matches a BYDAY entry: a weekday, optionally after its position
+// in the month or year, as in 2TU or -1FR.
+var icsWeekday = regexp.MustCompile(`^([+-]?\d{1,2})?(SU|MO|TU|WE|TH|FR|SA)$`)
+
+// icsDayOfWeek returns the Graph day for an RRULE weekday such as MO.
+func icsDayOfWeek(code string) models.DayOfWeek {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if icsDays[strings.ToLower(d.String())] == code {
+			return models.DayOfWeek(d)
+		}
+	}
+	return models.SUNDAY_DAYOFWEEK
+}
+
+// icsWeekIndexes maps BYDAY and BYSETPOS positions to Graph week indexes.
+var icsWeekIndexes = map[int]models.WeekIndex{
+	1: models.FIRST_WEEKINDEX, 2: models.SECOND_WEEKINDEX, 3: models.THIRD_WEEKINDEX,
+	4: models.FOURTH_WEEKINDEX, -1: models.LAST_WEEKINDEX,
+}
+
+// recurrenceFromRRULE turns an RRULE into a Graph recurrence for a series
+// starting at start. Graph patterns cover the rules calendars write for
+// meetings; others, such as hourly rules or several days of the month, are
+// refused rather than approximated.
+func recurrenceFromRRULE(rule string, start icsWhen, zones map[string]*time.Location) (models.PatternedRecurrenceable, error) {
+	parts := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		parts[strings.ToUpper(strings.TrimSpace(key))] = strings.ToUpper(strings.TrimSpace(value))
+	}
+	for key := range parts {
+		switch key {
+		case "FREQ", "INTERVAL", "COUNT", "UNTIL", "BYDAY", "BYMONTHDAY", "BYMONTH", "BYSETPOS", "WKST":
+		default:
+			return nil, fmt.Errorf("RRULE %s is not supported", key)
+		}
+	}
+
+	pattern := models.NewRecurrencePattern()
+	interval := int32(1)
+	if v := parts["INTERVAL"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid RRULE INTERVAL %q", v)
+		}
+		interval = int32(n)
+	}
+	pattern.SetInterval(&interval)
+
+	// BYDAY: the weekdays, and the one position they may all share.
+	var days []models.DayOfWeek
+	position := 0
+	if v := parts["BYDAY"]; v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			m := icsWeekday.FindStringSubmatch(entry)
+			if m == nil {
+				return nil, fmt.Errorf("invalid RRULE BYDAY %q", entry)
+			}
+			days = append(days, icsDayOfWeek(m[2]))
+			if m[1] != "" {
+				n, _ := strconv.Atoi(m[1])
+				if position != 0 && n != position {
+					return nil, fmt.Errorf("RRULE BYDAY %q mixes positions, which Graph cannot repeat", v)
+				}
+				position = n
+			}
+		}
+	}
+	if v := parts["BYSETPOS"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("RRULE BYSETPOS %q is not supported", v)
+		}
+		position = n
+	}
+	dayOfMonth := int32(start.t.Day())
+	if v := parts["BYMONTHDAY"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 31 {
+			return nil, fmt.Errorf("RRULE BYMONTHDAY %q is not supported", v)
+		}
+		dayOfMonth = int32(n)
+	}
+	month := int32(start.t.Month())
+	if v := parts["BYMONTH"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 12 {
+			return nil, fmt.Errorf("RRULE BYMONTH %q is not supported", v)
+		}
+		month = int32(n)
+	}
+	relative := func(patternType models.RecurrencePatternType) error {
+		index, ok := icsWeekIndexes[position]
+		if !ok {
+			return fmt.Errorf("RRULE position %d is not supported — Graph repeats on the first to fourth or last weekday", position)
+		}
+		pattern.SetTypeEscaped(&patternType)
+		pattern.SetDaysOfWeek(days)
+		pattern.SetIndex(&index)
+		return nil
+	}
+
+	var patternType models.RecurrencePatternType
+	switch freq := parts["FREQ"]; freq {
+	case "DAILY", "WEEKLY":
+		if freq == "DAILY" && len(days) == 0 {
+			patternType = models.DAILY_RECURRENCEPATTERNTYPE
+			pattern.SetTypeEscaped(&patternType)
+			break
+		}
+		patternType = models.WEEKLY_RECURRENCEPATTERNTYPE
+		pattern.SetTypeEscaped(&patternType)
+		if len(days) == 0 {
+			days = []models.DayOfWeek{models.DayOfWeek(start.t.Weekday())}
+		}
+		pattern.SetDaysOfWeek(days)
+		if wkst := parts["WKST"]; icsWeekday.MatchString(wkst) && len(wkst) == 2 {
+			first := icsDayOfWeek(wkst)
+			pattern.SetFirstDayOfWeek(&first)
+		}
+	case "MONTHLY":
+		if len(days) > 0 {
+			if err := relative(models.RELATIVEMONTHLY_RECURRENCEPATTERNTYPE); err != nil {
+				return nil, err
+			}
+			break
+		}
+		patternType = models.ABSOLUTEMONTHLY_RECURRENCEPATTERNTYPE
+		pattern.SetTypeEscaped(&patternType)
+		pattern.SetDayOfMonth(&dayOfMonth)
+	case "YEARLY":
+		pattern.SetMonth(&month)
+		if len(days) > 0 {
+			if err := relative(models.RELATIVEYEARLY_RECURRENCEPATTERNTYPE); err != nil {
+				return nil, err
+			}
+			break
+		}
+		patternType = models.ABSOLUTEYEARLY_RECURRENCEPATTERNTYPE
+		pattern.SetTypeEscaped(&patternType)
+		pattern.SetDayOfMonth(&dayOfMonth)
+	default:
+		return nil, fmt.Errorf("RRULE FREQ=%s is not supported — use DAILY, WEEKLY, MONTHLY, or YEARLY", freq)
+	}
+
+	rng := models.NewRecurrenceRange()
+	rng.SetStartDate(serialization.NewDateOnly(start.t))
+	rangeType := models.NOEND_RECURRENCERANGETYPE
+	if v := parts["COUNT"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid RRULE COUNT %q", v)
+		}
+		count := int32(n)
+		rng.SetNumberOfOccurrences(&count)
+		rangeType = models.NUMBERED_RECURRENCERANGETYPE
+	} else if v := parts["UNTIL"]; v != "" {
+		until, err := parseICSTime(icsProperty{name: "UNTIL", params: map[string]string{}, value: v}, zones)
+		if err != nil {
+			return nil, err
+		}
+		// The last day is the one UNTIL falls on where the series repeats.
+		last := until.t
+		if !until.allDay {
+			loc := time.Local
+			if start.zone != "" {
+				if l, err := loadZone(start.zone); err == nil {
+					loc = l
+				}
+			}
+			last = last.In(loc)
+		}
+		rng.SetEndDate(serialization.NewDateOnly(last))
+		rangeType = models.ENDDATE_RECURRENCERANGETYPE
+	}
+	rng.SetTypeEscaped(&rangeType)
+	tz := start.zone
+	if tz == "" || start.allDay {
+		tz = zoneName(time.Local)
+	}
+	if tz != "" {
+		rng.SetRecurrenceTimeZone(&tz)
+	}
+
+	recurrence := models.NewPatternedRecurrence()
+	recurrence.SetPattern(pattern)
+	recurrence.SetRangeEscaped(rng)
+	return recurrence, nil
+}
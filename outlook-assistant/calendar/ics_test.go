@@ -0,0 +1,550 @@
+package calendar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFoldICS(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"empty", ""},
+		{"short", "SUMMARY:Standup"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"76 octets", "SUMMARY:" + strings.Repeat("a", 68)},
+		{"several folds", "DESCRIPTION:" + strings.Repeat("0123456789", 30)},
+		// Three-byte runes never line up with the 75- and 74-octet limits.
+		{"multi-byte runes", "SUMMARY:" + strings.Repeat("€", 60)},
+		{"four-byte runes", "LOCATION:" + strings.Repeat("😀", 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := foldICS(tt.s)
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("foldICS(%q) = %q; want it to end in CRLF", tt.s, folded)
+			}
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			var unfolded strings.Builder
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets: %q", i, len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, line)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(line, " ") {
+						t.Fatalf("continuation %d does not start with a space: %q", i, line)
+					}
+					line = line[1:]
+				}
+				unfolded.WriteString(line)
+			}
+			if unfolded.String() != tt.s {
+				t.Errorf("unfolding gives %q; want %q", unfolded.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestEscapeICS(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{`C:\path`, `C:\\path`},
+		{"a;b,c", `a\;b\,c`},
+		{"line one\r\nline two\nthree", `line one\nline two\nthree`},
+		{"stray\rreturn", "strayreturn"},
+	}
+	for _, tt := range tests {
+		got := escapeICS(tt.s)
+		if got != tt.want {
+			t.Errorf("escapeICS(%q) = %q; want %q", tt.s, got, tt.want)
+		}
+		if back := unescapeICS(got); back != strings.NewReplacer("\r\n", "\n", "\r", "").Replace(tt.s) {
+			t.Errorf("unescapeICS(%q) = %q; want %q back", got, back, tt.s)
+		}
+	}
+}
+
+func TestSplitICS(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", []string{""}},
+		{"Work", []string{"Work"}},
+		{"Work,Travel", []string{"Work", "Travel"}},
+		{`Smith\, John,Jane`, []string{"Smith, John", "Jane"}},
+		{`a\nb\Nc`, []string{"a\nb\nc"}},
+		{`a\;b`, []string{"a;b"}},
+		{`a,,b,`, []string{"a", "", "b", ""}},
+		{`trailing\`, []string{`trailing\`}},
+	}
+	for _, tt := range tests {
+		if got := splitICS(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitICS(%q) = %q; want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseICSProperty(t *testing.T) {
+	tests := []struct {
+		line string
+		want icsProperty
+		ok   bool
+	}{
+		{"SUMMARY:Standup", icsProperty{name: "SUMMARY", params: map[string]string{}, value: "Standup"}, true},
+		{"summary:", icsProperty{name: "SUMMARY", params: map[string]string{}, value: ""}, true},
+		{"DESCRIPTION:Call at 10:30", icsProperty{name: "DESCRIPTION", params: map[string]string{}, value: "Call at 10:30"}, true},
+		{"DTSTART;TZID=Europe/Berlin:20260520T090000",
+			icsProperty{name: "DTSTART", params: map[string]string{"TZID": "Europe/Berlin"}, value: "20260520T090000"}, true},
+		{`ATTENDEE;cn="Doe; Jane: PM";ROLE=OPT-PARTICIPANT:mailto:jane@example.com`,
+			icsProperty{name: "ATTENDEE", params: map[string]string{"CN": "Doe; Jane: PM", "ROLE": "OPT-PARTICIPANT"}, value: "mailto:jane@example.com"}, true},
+		{"X-FLAG;NOVALUE:1", icsProperty{name: "X-FLAG", params: map[string]string{}, value: "1"}, true},
+		{"", icsProperty{}, false},
+		{"no colon", icsProperty{}, false},
+		{":value", icsProperty{}, false},
+		{`X;P="unterminated:value`, icsProperty{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseICSProperty(tt.line)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseICSProperty(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		events  int
+		summary string
+		wantErr string
+	}{
+		{name: "empty", input: ""},
+		{name: "blank lines", input: "\r\n\r\n"},
+		{name: "one event", events: 1, summary: "Standup",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Standup\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "LF line ends", events: 1, summary: "Standup",
+			input: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Standup\nEND:VEVENT\nEND:VCALENDAR\n"},
+		{name: "folded with a space", events: 1, summary: "Quarterly planning",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Quarterly pl\r\n anning\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "folded with a tab", events: 1, summary: "Quarterly planning",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Quarterly\r\n\t planning\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "lower-case component names", events: 1, summary: "Standup",
+			input: "BEGIN:vcalendar\r\nBEGIN:vevent\r\nSUMMARY:Standup\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "nested alarm", events: 1, summary: "Standup",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Standup\r\nBEGIN:VALARM\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "not a property", wantErr: "line 3",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\ngarbage\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "mismatched END", wantErr: "END:VTODO",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"},
+		{name: "END without BEGIN", wantErr: "does not close",
+			input: "END:VCALENDAR\r\n"},
+		{name: "not closed", wantErr: "VEVENT is not closed",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Standup\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendars, err := parseICS(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseICS = %v; want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var events []*icsComponent
+			for _, c := range calendars {
+				for _, sub := range c.components {
+					if sub.name == "VEVENT" {
+						events = append(events, sub)
+					}
+				}
+			}
+			if len(events) != tt.events {
+				t.Fatalf("got %d events; want %d", len(events), tt.events)
+			}
+			if tt.events > 0 {
+				if got := events[0].prop("SUMMARY").value; got != tt.summary {
+					t.Errorf("SUMMARY = %q; want %q", got, tt.summary)
+				}
+			}
+		})
+	}
+}
+
+func TestParseICSOffset(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+		ok   bool
+	}{
+		{"+0100", 3600, true},
+		{"-0500", -5 * 3600, true},
+		{"+0530", 5*3600 + 30*60, true},
+		{"-003000", -30 * 60, true},
+		{"+0000", 0, true},
+		{"", 0, false},
+		{"0100", 0, false},
+		{"+01", 0, false},
+		{"+01:00", 0, false},
+		{"+ab00", 0, false},
+		{"+010", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseICSOffset(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseICSOffset(%q) = %d, %v; want %d, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	tests := []struct {
+		s       string
+		days    int
+		d       time.Duration
+		wantErr bool
+	}{
+		{"PT30M", 0, 30 * time.Minute, false},
+		{"P1D", 1, 0, false},
+		{"P1DT2H", 1, 2 * time.Hour, false},
+		{"P2W", 14, 0, false},
+		{"+PT1H30M15S", 0, time.Hour + 30*time.Minute + 15*time.Second, false},
+		{" pt45m ", 0, 45 * time.Minute, false},
+		{"", 0, 0, true},
+		{"-PT15M", 0, 0, true},
+		{"30M", 0, 0, true},
+		{"P1H", 0, 0, true},
+		{"PT1.5H", 0, 0, true},
+	}
+	for _, tt := range tests {
+		days, d, err := parseICSDuration(tt.s)
+		if (err != nil) != tt.wantErr || days != tt.days || d != tt.d {
+			t.Errorf("parseICSDuration(%q) = %d, %v, %v; want %d, %v, error %v", tt.s, days, d, err, tt.days, tt.d, tt.wantErr)
+		}
+	}
+}
+
+func TestParseICSTime(t *testing.T) {
+	ny := inZone(t, "America/New_York")
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := time.FixedZone("Custom Zone", 2*3600)
+	zones := map[string]*time.Location{"Custom Zone": custom}
+	prop := func(value string, params ...string) icsProperty {
+		p := icsProperty{name: "DTSTART", params: map[string]string{}, value: value}
+		for i := 0; i+1 < len(params); i += 2 {
+			p.params[params[i]] = params[i+1]
+		}
+		return p
+	}
+	tests := []struct {
+		name    string
+		p       icsProperty
+		want    icsWhen
+		wantErr bool
+	}{
+		{"date", prop("20260520", "VALUE", "DATE"), icsWhen{t: time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC), allDay: true}, false},
+		{"date without VALUE", prop("20260520"), icsWhen{t: time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC), allDay: true}, false},
+		{"UTC", prop("20260520T130000Z"), icsWhen{t: time.Date(2026, 5, 20, 13, 0, 0, 0, time.UTC), zone: "UTC"}, false},
+		{"TZID", prop("20260520T090000", "TZID", "Europe/Berlin"), icsWhen{t: time.Date(2026, 5, 20, 9, 0, 0, 0, berlin), zone: "Europe/Berlin"}, false},
+		{"TZID with a slash", prop("20260520T090000", "TZID", "/Europe/Berlin"), icsWhen{t: time.Date(2026, 5, 20, 9, 0, 0, 0, berlin), zone: "Europe/Berlin"}, false},
+		{"Windows TZID", prop("20260520T090000", "TZID", "W. Europe Standard Time"), icsWhen{t: time.Date(2026, 5, 20, 9, 0, 0, 0, berlin), zone: "Europe/Berlin"}, false},
+		{"VTIMEZONE offset", prop("20260520T090000", "TZID", "Custom Zone"), icsWhen{t: time.Date(2026, 5, 20, 7, 0, 0, 0, time.UTC), zone: "UTC"}, false},
+		{"floating", prop("20260520T090000"), icsWhen{t: time.Date(2026, 5, 20, 9, 0, 0, 0, ny)}, false},
+		{"floating after fall back", prop("20261101T013000"), icsWhen{t: time.Date(2026, 11, 1, 1, 30, 0, 0, ny)}, false},
+		{"spaces", prop(" 20260520T130000Z "), icsWhen{t: time.Date(2026, 5, 20, 13, 0, 0, 0, time.UTC), zone: "UTC"}, false},
+		{"unknown TZID", prop("20260520T090000", "TZID", "Mars/Olympus"), icsWhen{}, true},
+		{"bad date", prop("20261332", "VALUE", "DATE"), icsWhen{}, true},
+		{"bad UTC", prop("20260520T25000Z"), icsWhen{}, true},
+		{"bad local", prop("2026-05-20T09:00"), icsWhen{}, true},
+		{"empty", prop(""), icsWhen{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseICSTime(tt.p, zones)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseICSTime(%q) = %+v; want an error", tt.p.value, got)
+				}
+				return
+			}
+			if err != nil || !got.t.Equal(tt.want.t) || got.allDay != tt.want.allDay || got.zone != tt.want.zone {
+				t.Fatalf("parseICSTime(%q) = %+v, %v; want %+v", tt.p.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVtimezone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want []string
+	}{
+		{"daylight saving", ny, []string{
+			"BEGIN:VTIMEZONE", "TZID:America/New_York",
+			"BEGIN:DAYLIGHT", "DTSTART:19700308T020000", "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU",
+			"TZOFFSETFROM:-0500", "TZOFFSETTO:-0400", "TZNAME:EDT", "END:DAYLIGHT",
+			"BEGIN:STANDARD", "DTSTART:19701101T020000", "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+			"TZOFFSETFROM:-0400", "TZOFFSETTO:-0500", "TZNAME:EST", "END:STANDARD",
+			"END:VTIMEZONE",
+		}},
+		{"no daylight saving", tokyo, []string{
+			"BEGIN:VTIMEZONE", "TZID:Asia/Tokyo",
+			"BEGIN:STANDARD", "DTSTART:19700101T000000", "TZOFFSETFROM:+0900", "TZOFFSETTO:+0900", "END:STANDARD",
+			"END:VTIMEZONE",
+		}},
+	}
+	for _, tt := range tests {
+		if got := vtimezone(tt.loc, 2026); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("vtimezone(%s) = %q; want %q", tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestNthWeekday(t *testing.T) {
+	tests := []struct {
+		month time.Month
+		day   time.Weekday
+		nth   int
+		want  int
+	}{
+		{time.March, time.Sunday, 2, 8},
+		{time.November, time.Sunday, 1, 1},
+		{time.March, time.Sunday, -1, 29},
+		{time.October, time.Sunday, -1, 25},
+		{time.February, time.Saturday, -1, 28},
+		{time.May, time.Friday, 1, 1},
+	}
+	for _, tt := range tests {
+		got := nthWeekday(2026, tt.month, tt.day, tt.nth)
+		if got.Month() != tt.month || got.Day() != tt.want || got.Weekday() != tt.day {
+			t.Errorf("nthWeekday(2026, %s, %s, %d) = %s; want day %d", tt.month, tt.day, tt.nth, got.Format("2006-01-02 Mon"), tt.want)
+		}
+	}
+}
+
+func TestRRULE(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		r      Recurrence
+		allDay bool
+		zone   *time.Location
+		want   string
+	}{
+		{"daily", Recurrence{Pattern: "daily", Interval: 1, Range: "noEnd"}, false, nil, "FREQ=DAILY"},
+		{"every other week", Recurrence{Pattern: "weekly", Interval: 2, DaysOfWeek: []string{"monday", "Thursday"}, FirstDayOfWeek: "sunday", Range: "numbered", Occurrences: 10}, false, nil,
+			"FREQ=WEEKLY;BYDAY=MO,TH;WKST=SU;INTERVAL=2;COUNT=10"},
+		{"monthly on a day", Recurrence{Pattern: "absoluteMonthly", Interval: 1, DayOfMonth: 31}, false, nil, "FREQ=MONTHLY;BYMONTHDAY=31"},
+		{"last friday", Recurrence{Pattern: "relativeMonthly", DaysOfWeek: []string{"friday"}, Index: "last"}, false, nil, "FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1"},
+		{"yearly", Recurrence{Pattern: "absoluteYearly", Month: 2, DayOfMonth: 29}, false, nil, "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29"},
+		{"relative yearly", Recurrence{Pattern: "relativeYearly", Month: 11, DaysOfWeek: []string{"thursday"}, Index: "fourth"}, false, nil, "FREQ=YEARLY;BYMONTH=11;BYDAY=TH;BYSETPOS=4"},
+		{"until in a zone", Recurrence{Pattern: "daily", Range: "endDate", EndDate: "2026-03-08"}, false, ny, "FREQ=DAILY;UNTIL=20260309T035959Z"},
+		{"until in UTC", Recurrence{Pattern: "daily", Range: "endDate", EndDate: "2026-03-08"}, false, nil, "FREQ=DAILY;UNTIL=20260308T235959Z"},
+		{"until all day", Recurrence{Pattern: "daily", Range: "endDate", EndDate: "2026-03-08"}, true, ny, "FREQ=DAILY;UNTIL=20260308"},
+		{"bad end date", Recurrence{Pattern: "daily", Range: "endDate", EndDate: "March 8"}, false, nil, "FREQ=DAILY"},
+		{"zero count", Recurrence{Pattern: "daily", Range: "numbered"}, false, nil, "FREQ=DAILY"},
+		{"unknown pattern", Recurrence{Pattern: "hourly"}, false, nil, ""},
+		{"empty", Recurrence{}, false, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := rrule(&tt.r, tt.allDay, tt.zone); got != tt.want {
+			t.Errorf("%s: rrule = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecurrenceFromRRULE(t *testing.T) {
+	inZone(t, "America/New_York")
+	start := icsWhen{t: time.Date(2026, 5, 20, 9, 0, 0, 0, time.UTC), zone: "UTC"}
+	tests := []struct {
+		rule    string
+		pattern string
+		rng     string
+		wantErr string
+	}{
+		{rule: "FREQ=DAILY", pattern: "daily", rng: "noEnd"},
+		{rule: "freq=daily;count=5", pattern: "daily", rng: "numbered"},
+		{rule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", pattern: "weekly", rng: "noEnd"},
+		{rule: "FREQ=WEEKLY;UNTIL=20261231", pattern: "weekly", rng: "endDate"},
+		{rule: "FREQ=MONTHLY;BYDAY=-1FR", pattern: "relativeMonthly", rng: "noEnd"},
+		{rule: "FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=2", pattern: "relativeMonthly", rng: "noEnd"},
+		{rule: "FREQ=MONTHLY", pattern: "absoluteMonthly", rng: "noEnd"},
+		{rule: "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", pattern: "relativeYearly", rng: "noEnd"},
+		{rule: "FREQ=YEARLY", pattern: "absoluteYearly", rng: "noEnd"},
+		{rule: "FREQ=", wantErr: "FREQ= is not supported"},
+		{rule: "COUNT=2", wantErr: "FREQ= is not supported"},
+		{rule: "FREQ=HOURLY", wantErr: "FREQ=HOURLY"},
+		{rule: "FREQ=DAILY;BYHOUR=9", wantErr: "BYHOUR"},
+		{rule: "FREQ=DAILY;INTERVAL=0", wantErr: "INTERVAL"},
+		{rule: "FREQ=DAILY;COUNT=x", wantErr: "COUNT"},
+		{rule: "FREQ=DAILY;UNTIL=tomorrow", wantErr: "UNTIL"},
+		{rule: "FREQ=WEEKLY;BYDAY=XX", wantErr: "BYDAY"},
+		{rule: "FREQ=MONTHLY;BYDAY=1MO,2TU", wantErr: "mixes positions"},
+		{rule: "FREQ=MONTHLY;BYDAY=5MO", wantErr: "position 5"},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=1,15", wantErr: "BYMONTHDAY"},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=-1", wantErr: "BYMONTHDAY"},
+		{rule: "FREQ=YEARLY;BYMONTH=13", wantErr: "BYMONTH"},
+	}
+	for _, tt := range tests {
+		got, err := recurrenceFromRRULE(tt.rule, start, nil)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("recurrenceFromRRULE(%q) = %v; want an error containing %q", tt.rule, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("recurrenceFromRRULE(%q): %v", tt.rule, err)
+			continue
+		}
+		if p := got.GetPattern().GetTypeEscaped().String(); p != tt.pattern {
+			t.Errorf("recurrenceFromRRULE(%q) pattern = %s; want %s", tt.rule, p, tt.pattern)
+		}
+		if r := got.GetRangeEscaped().GetTypeEscaped().String(); r != tt.rng {
+			t.Errorf("recurrenceFromRRULE(%q) range = %s; want %s", tt.rule, r, tt.rng)
+		}
+	}
+}
+
+func TestEventFromICS(t *testing.T) {
+	inZone(t, "America/New_York")
+	event := func(lines ...string) *icsComponent {
+		c := &icsComponent{name: "VEVENT"}
+		for _, line := range lines {
+			p, ok := parseICSProperty(line)
+			if !ok {
+				t.Fatalf("bad test line %q", line)
+			}
+			c.props = append(c.props, p)
+		}
+		return c
+	}
+	tests := []struct {
+		name       string
+		c          *icsComponent
+		start, end string
+		wantErr    string
+	}{
+		{name: "DTEND", c: event("DTSTART:20260520T130000Z", "DTEND:20260520T140000Z"),
+			start: "2026-05-20T13:00:00", end: "2026-05-20T14:00:00"},
+		{name: "no end", c: event("DTSTART:20260520T130000Z"),
+			start: "2026-05-20T13:00:00", end: "2026-05-20T13:00:00"},
+		{name: "floating", c: event("DTSTART:20260520T090000", "DTEND:20260520T100000"),
+			start: "2026-05-20T09:00:00", end: "2026-05-20T10:00:00"},
+		{name: "all day", c: event("DTSTART;VALUE=DATE:20260520"),
+			start: "2026-05-20T00:00:00", end: "2026-05-21T00:00:00"},
+		// A day's DURATION keeps the wall clock across the change; hours do not.
+		{name: "day across spring forward", c: event("DTSTART;TZID=America/New_York:20260307T090000", "DURATION:P1D"),
+			start: "2026-03-07T09:00:00", end: "2026-03-08T09:00:00"},
+		{name: "hours across spring forward", c: event("DTSTART;TZID=America/New_York:20260307T090000", "DURATION:PT24H"),
+			start: "2026-03-07T09:00:00", end: "2026-03-08T10:00:00"},
+		{name: "no DTSTART", c: event("SUMMARY:Nothing"), wantErr: "no DTSTART"},
+		{name: "empty DTSTART", c: event("DTSTART:"), wantErr: "no DTSTART"},
+		{name: "single occurrence", c: event("DTSTART:20260520T130000Z", "RECURRENCE-ID:20260520T130000Z"), wantErr: "RECURRENCE-ID"},
+		{name: "ends first", c: event("DTSTART:20260520T130000Z", "DTEND:20260520T120000Z"), wantErr: "ends before it starts"},
+		{name: "bad DTEND", c: event("DTSTART:20260520T130000Z", "DTEND:soon"), wantErr: "invalid DTEND"},
+		{name: "bad DURATION", c: event("DTSTART:20260520T130000Z", "DURATION:-PT1H"), wantErr: "DURATION"},
+		{name: "bad RRULE", c: event("DTSTART:20260520T130000Z", "RRULE:FREQ=SECONDLY"), wantErr: "SECONDLY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := eventFromICS(tt.c, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("eventFromICS = %v; want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := deref(got.GetStart().GetDateTime(), ""); s != tt.start {
+				t.Errorf("start = %s; want %s", s, tt.start)
+			}
+			if e := deref(got.GetEnd().GetDateTime(), ""); e != tt.end {
+				t.Errorf("end = %s; want %s", e, tt.end)
+			}
+		})
+	}
+}
+
+func TestEventFromICSFields(t *testing.T) {
+	inZone(t, "America/New_York")
+	calendars, err := parseICS(strings.NewReader(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART:20260520T130000Z",
+		`SUMMARY:Budget\, Q3`,
+		`DESCRIPTION:Agenda:\n1. Numbers\n2. Plans`,
+		"LOCATION:Room 4",
+		`ATTENDEE;CN="Doe, Jane";ROLE=OPT-PARTICIPANT:MAILTO:jane@example.com`,
+		"ATTENDEE;CUTYPE=ROOM:mailto:room4@example.com",
+		"ATTENDEE:mailto:",
+		`CATEGORIES:Work,Finance\, Q3, `,
+		"TRANSP:TRANSPARENT",
+		"CLASS:private",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := eventFromICS(calendars[0].components[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deref(event.GetSubject(), ""); got != "Budget, Q3" {
+		t.Errorf("subject = %q", got)
+	}
+	if got := deref(event.GetBody().GetContent(), ""); got != "Agenda:\n1. Numbers\n2. Plans" {
+		t.Errorf("body = %q", got)
+	}
+	if got := deref(event.GetLocation().GetDisplayName(), ""); got != "Room 4" {
+		t.Errorf("location = %q", got)
+	}
+	var attendees []string
+	for _, a := range event.GetAttendees() {
+		attendees = append(attendees, deref(a.GetEmailAddress().GetName(), "")+" <"+deref(a.GetEmailAddress().GetAddress(), "")+"> "+a.GetTypeEscaped().String())
+	}
+	if want := []string{"Doe, Jane <jane@example.com> optional", " <room4@example.com> resource"}; !reflect.DeepEqual(attendees, want) {
+		t.Errorf("attendees = %q; want %q", attendees, want)
+	}
+	if want := []string{"Work", "Finance, Q3"}; !reflect.DeepEqual(event.GetCategories(), want) {
+		t.Errorf("categories = %q; want %q", event.GetCategories(), want)
+	}
+	if got := event.GetShowAs().String(); got != "free" {
+		t.Errorf("showAs = %s; want free", got)
+	}
+	if got := event.GetSensitivity().String(); got != "private" {
+		t.Errorf("sensitivity = %s; want private", got)
+	}
+}
@@ -0,0 +1,252 @@
+package calendar
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Bulk import ----------
+
+// EventRow is one event to import. CSV files need a header row naming these
+// columns (in any order); JSON files hold an array of objects with these keys.
+type EventRow struct {
+	Title      string `json:"title"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Attendees  string `json:"attendees"`
+	Location   string `json:"location"`
+	Recurrence string `json:"recurrence"`
+}
+
+// ImportResult is the JSON representation of one imported row.
+type ImportResult struct {
+	Row     int    `json:"row"`
+	Title   string `json:"title"`
+	ID      string `json:"id,omitempty"`
+	ICalUID string `json:"iCalUId,omitempty"`
+	WebLink string `json:"webLink,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ImportBulk creates one event per row of a CSV or JSON file (chosen by
+// extension). A bad row is reported and skipped; the others are still created.
+// Rows are numbered from 1 as data rows, not counting a CSV header.
+//
+// recurrence is empty for a single event, or
+// daily|weekdays|weekly|monthly[;interval=N][;count=N|;until=YYYY-MM-DD].
+// Weekly repeats on the start date's weekday, monthly on its day of month.
+func ImportBulk(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, file string, jsonOutput bool) error {
+	if file == "" {
+		return fmt.Errorf("--file is required")
+	}
+	rows, err := readEventRows(file)
+	if err != nil {
+		return err
+	}
+
+	results := make([]ImportResult, 0, len(rows))
+	failed := 0
+	for i, row := range rows {
+		res := ImportResult{Row: i + 1, Title: row.Title}
+		if created, err := importRow(ctx, client, row); err != nil {
+			res.Error = err.Error()
+			failed++
+		} else {
+			res.ID = deref(created.GetId(), "")
+			res.ICalUID = deref(created.GetICalUId(), "")
+			res.WebLink = deref(created.GetWebLink(), "")
+		}
+		results = append(results, res)
+	}
+
+	if jsonOutput {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("row %-4d  FAILED   %-40s  %s\n", r.Row, truncate(r.Title, 40), r.Error)
+			} else {
+				fmt.Printf("row %-4d  created  %s\n", r.Row, truncate(r.Title, 40))
+			}
+		}
+	}
+
+	slog.Info("Imported events", "imported", len(rows)-failed, "total", len(rows))
+	if failed > 0 {
+		return fmt.Errorf("%d rows could not be imported", failed)
+	}
+	return nil
+}
+
+func importRow(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, row EventRow) (models.Eventable, error) {
+	if row.Title == "" || row.Start == "" || row.End == "" {
+		return nil, fmt.Errorf("title, start, and end are required")
+	}
+	event, err := buildEvent(row.Title, row.Start, row.End, 0, row.Location, row.Attendees)
+	if err != nil {
+		return nil, err
+	}
+	if row.Recurrence != "" {
+		start, _ := parseWhen(row.Start, time.Now())
+		recurrence, err := parseRecurrence(row.Recurrence, start.Format("2006-01-02"))
+		if err != nil {
+			return nil, err
+		}
+		event.SetRecurrence(recurrence)
+	}
+	created, err := mailbox.Of(client).Events().Post(ctx, event, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating event: %w", err)
+	}
+	return created, nil
+}
+
+// readEventRows parses a .json file as an array of EventRow and anything else as CSV.
+func readEventRows(file string) ([]EventRow, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", file, err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		var rows []EventRow
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		return rows, nil
+	}
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header from %s: %w", file, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"title", "start", "end"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header in %s has no %q column", file, required)
+		}
+	}
+
+	var rows []EventRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, EventRow{
+			Title:      field("title"),
+			Start:      field("start"),
+			End:        field("end"),
+			Attendees:  field("attendees"),
+			Location:   field("location"),
+			Recurrence: field("recurrence"),
+		})
+	}
+	return rows, nil
+}
+
+// parseRecurrence turns a recurrence spec into a Graph recurrence starting on startDate.
+func parseRecurrence(spec, startDate string) (models.PatternedRecurrenceable, error) {
+	parts := strings.Split(spec, ";")
+	start, err := parseDateTime(startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := models.NewRecurrencePattern()
+	interval := int32(1)
+	var patternType models.RecurrencePatternType
+	switch strings.ToLower(strings.TrimSpace(parts[0])) {
+	case "daily":
+		patternType = models.DAILY_RECURRENCEPATTERNTYPE
+	case "weekdays":
+		patternType = models.WEEKLY_RECURRENCEPATTERNTYPE
+		pattern.SetDaysOfWeek([]models.DayOfWeek{
+			models.MONDAY_DAYOFWEEK, models.TUESDAY_DAYOFWEEK, models.WEDNESDAY_DAYOFWEEK,
+			models.THURSDAY_DAYOFWEEK, models.FRIDAY_DAYOFWEEK,
+		})
+	case "weekly":
+		patternType = models.WEEKLY_RECURRENCEPATTERNTYPE
+		pattern.SetDaysOfWeek([]models.DayOfWeek{models.DayOfWeek(start.Weekday())})
+	case "monthly":
+		patternType = models.ABSOLUTEMONTHLY_RECURRENCEPATTERNTYPE
+		day := int32(start.Day())
+		pattern.SetDayOfMonth(&day)
+	default:
+		return nil, fmt.Errorf("unknown recurrence %q — use daily, weekdays, weekly, or monthly", parts[0])
+	}
+
+	rng := models.NewRecurrenceRange()
+	rng.SetStartDate(serialization.NewDateOnly(start))
+	rangeType := models.NOEND_RECURRENCERANGETYPE
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch strings.ToLower(key) {
+		case "interval":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence interval %q", value)
+			}
+			interval = int32(n)
+		case "count":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence count %q", value)
+			}
+			count := int32(n)
+			rng.SetNumberOfOccurrences(&count)
+			rangeType = models.NUMBERED_RECURRENCERANGETYPE
+		case "until":
+			t, err := parseDateTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recurrence until: %w", err)
+			}
+			rng.SetEndDate(serialization.NewDateOnly(t))
+			rangeType = models.ENDDATE_RECURRENCERANGETYPE
+		default:
+			return nil, fmt.Errorf("unknown recurrence option %q", opt)
+		}
+	}
+	pattern.SetTypeEscaped(&patternType)
+	pattern.SetInterval(&interval)
+	rng.SetTypeEscaped(&rangeType)
+
+	recurrence := models.NewPatternedRecurrence()
+	recurrence.SetPattern(pattern)
+	recurrence.SetRangeEscaped(rng)
+	return recurrence, nil
+}
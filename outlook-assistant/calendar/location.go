@@ -0,0 +1,178 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// ---------- Structured locations ----------
+
+// Address is the JSON representation of a location's street address.
+type Address struct {
+	Street     string `json:"street,omitempty"`
+	City       string `json:"city,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+// Coordinates is the JSON representation of a location's position.
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// LocationInfo is the JSON representation of one of an event's locations.
+type LocationInfo struct {
+	DisplayName string       `json:"displayName"`
+	Type        string       `json:"type,omitempty"`
+	Email       string       `json:"email,omitempty"`
+	Address     *Address     `json:"address,omitempty"`
+	Coordinates *Coordinates `json:"coordinates,omitempty"`
+}
+
+// setLocations fills in an event's locations from the create flags.
+//
+// location is one or more display names separated by semicolons. address
+// ("street, city, state, postal code, country"; trailing parts may be
+// omitted) and coordinates ("latitude,longitude") describe the first of them.
+// room is the email address of a room mailbox; it is added as a further
+// location and invited as a resource attendee so the room is booked.
+func setLocations(event models.Eventable, location, address, room, coordinates string) error {
+	var locations []models.Locationable
+	for _, name := range strings.Split(location, ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			loc := models.NewLocation()
+			loc.SetDisplayName(&name)
+			locations = append(locations, loc)
+		}
+	}
+
+	if address != "" || coordinates != "" {
+		if len(locations) == 0 {
+			name := strings.TrimSpace(address)
+			if name == "" {
+				name = strings.TrimSpace(coordinates)
+			}
+			loc := models.NewLocation()
+			loc.SetDisplayName(&name)
+			locations = append(locations, loc)
+		}
+		primary := locations[0]
+		if address != "" {
+			primary.SetAddress(parseAddress(address))
+			locType := models.STREETADDRESS_LOCATIONTYPE
+			primary.SetLocationType(&locType)
+		}
+		if coordinates != "" {
+			geo, err := parseCoordinates(coordinates)
+			if err != nil {
+				return err
+			}
+			primary.SetCoordinates(geo)
+		}
+	}
+
+	if room != "" {
+		room = strings.TrimSpace(room)
+		if !strings.Contains(room, "@") {
+			return fmt.Errorf("--room must be the room's email address, got %q", room)
+		}
+		loc := models.NewLocation()
+		loc.SetDisplayName(&room)
+		loc.SetLocationEmailAddress(&room)
+		locType := models.CONFERENCEROOM_LOCATIONTYPE
+		loc.SetLocationType(&locType)
+		locations = append(locations, loc)
+
+		addr := models.NewEmailAddress()
+		addr.SetAddress(&room)
+		attendee := models.NewAttendee()
+		attendee.SetEmailAddress(addr)
+		attendeeType := models.RESOURCE_ATTENDEETYPE
+		attendee.SetTypeEscaped(&attendeeType)
+		event.SetAttendees(append(event.GetAttendees(), attendee))
+	}
+
+	if len(locations) == 0 {
+		return nil
+	}
+	event.SetLocation(locations[0])
+	event.SetLocations(locations)
+	return nil
+}
+
+// parseAddress splits "street, city, state, postal code, country" into its
+// parts; missing trailing parts are left empty.
+func parseAddress(s string) models.PhysicalAddressable {
+	parts := strings.SplitN(s, ",", 5)
+	for len(parts) < 5 {
+		parts = append(parts, "")
+	}
+	field := func(i int) *string {
+		v := strings.TrimSpace(parts[i])
+		return &v
+	}
+	addr := models.NewPhysicalAddress()
+	addr.SetStreet(field(0))
+	addr.SetCity(field(1))
+	addr.SetState(field(2))
+	addr.SetPostalCode(field(3))
+	addr.SetCountryOrRegion(field(4))
+	return addr
+}
+
+func parseCoordinates(s string) (models.OutlookGeoCoordinatesable, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid --coordinates %q — use latitude,longitude", s)
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, fmt.Errorf("invalid --coordinates %q — use latitude,longitude in decimal degrees", s)
+	}
+	geo := models.NewOutlookGeoCoordinates()
+	geo.SetLatitude(&lat)
+	geo.SetLongitude(&lon)
+	return geo, nil
+}
+
+// locationsOf converts an event's locations, falling back to its single
+// location for events that have no locations collection.
+func locationsOf(event models.Eventable) []LocationInfo {
+	locs := event.GetLocations()
+	if len(locs) == 0 && event.GetLocation() != nil && deref(event.GetLocation().GetDisplayName(), "") != "" {
+		locs = []models.Locationable{event.GetLocation()}
+	}
+	var out []LocationInfo
+	for _, l := range locs {
+		info := LocationInfo{
+			DisplayName: deref(l.GetDisplayName(), ""),
+			Email:       deref(l.GetLocationEmailAddress(), ""),
+		}
+		if t := l.GetLocationType(); t != nil && *t != models.DEFAULT_LOCATIONTYPE {
+			info.Type = t.String()
+		}
+		if a := l.GetAddress(); a != nil {
+			addr := Address{
+				Street:     deref(a.GetStreet(), ""),
+				City:       deref(a.GetCity(), ""),
+				State:      deref(a.GetState(), ""),
+				PostalCode: deref(a.GetPostalCode(), ""),
+				Country:    deref(a.GetCountryOrRegion(), ""),
+			}
+			if addr != (Address{}) {
+				info.Address = &addr
+			}
+		}
+		if c := l.GetCoordinates(); c != nil && c.GetLatitude() != nil && c.GetLongitude() != nil {
+			info.Coordinates = &Coordinates{Latitude: *c.GetLatitude(), Longitude: *c.GetLongitude()}
+		}
+		out = append(out, info)
+	}
+	return out
+}
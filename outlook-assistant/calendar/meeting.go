@@ -0,0 +1,261 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Online meeting details ----------
+
+// MeetingPhone is a dial-in number for an online meeting.
+type MeetingPhone struct {
+	Number   string `json:"number"`
+	Type     string `json:"type,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// MeetingArtifact is a recording or transcript of a meeting.
+type MeetingArtifact struct {
+	ID         string `json:"id"`
+	Created    string `json:"created,omitempty"`
+	ContentURL string `json:"contentUrl,omitempty"`
+}
+
+// OnlineMeeting is the JSON representation of an event's online meeting.
+type OnlineMeeting struct {
+	EventID             string            `json:"eventId"`
+	Subject             string            `json:"subject"`
+	Start               string            `json:"start"`
+	End                 string            `json:"end"`
+	Provider            string            `json:"provider,omitempty"`
+	JoinURL             string            `json:"joinUrl,omitempty"`
+	ConferenceID        string            `json:"conferenceId,omitempty"`
+	TollNumber          string            `json:"tollNumber,omitempty"`
+	TollFreeNumbers     []string          `json:"tollFreeNumbers,omitempty"`
+	Phones              []MeetingPhone    `json:"phones,omitempty"`
+	QuickDial           string            `json:"quickDial,omitempty"`
+	DialInURL           string            `json:"dialInUrl,omitempty"`
+	MeetingOptionsURL   string            `json:"meetingOptionsUrl,omitempty"`
+	LobbyBypass         string            `json:"lobbyBypass,omitempty"`
+	DialInBypassesLobby *bool             `json:"dialInBypassesLobby,omitempty"`
+	AllowedPresenters   string            `json:"allowedPresenters,omitempty"`
+	RecordAutomatically *bool             `json:"recordAutomatically,omitempty"`
+	Recordings          []MeetingArtifact `json:"recordings,omitempty"`
+	Transcripts         []MeetingArtifact `json:"transcripts,omitempty"`
+	Notes               []string          `json:"notes,omitempty"`
+}
+
+// ArtifactSource reads a Teams meeting's recordings and transcripts. Their
+// permissions need admin consent, so they are read with a client of their own
+// that asks for them; without consent the rest of the meeting is still shown.
+type ArtifactSource struct {
+	// Client returns the client that reads recordings and transcripts.
+	Client func() (*msgraphsdkgo.GraphServiceClient, error)
+	// Help explains err when it means permission is missing, or returns "".
+	Help func(err error, permission string) string
+}
+
+// MeetingInfo prints the online meeting details of the event identified by
+// ref (list index or Graph ID): join link, dial-in numbers, and conference ID
+// from the event, plus lobby settings and any recordings and transcripts from
+// the Teams meeting itself. Details that cannot be read — the meeting is not
+// on Teams, only its organizer may see them, or the permission to read
+// recordings and transcripts was not granted — are explained in Notes.
+func MeetingInfo(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, artifacts ArtifactSource, ref string, jsonOutput bool) error {
+	id, err := resolveEventID(ref)
+	if err != nil {
+		return err
+	}
+	event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject", "start", "end", "isAllDay", "isOnlineMeeting", "onlineMeeting", "onlineMeetingProvider", "onlineMeetingUrl"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("fetching event: %w", err)
+	}
+
+	info := OnlineMeeting{
+		EventID: id,
+		Subject: deref(event.GetSubject(), ""),
+		Start:   formatEventTime(event.GetStart(), isAllDay(event)),
+		End:     formatEventTime(event.GetEnd(), isAllDay(event)),
+		JoinURL: deref(event.GetOnlineMeetingUrl(), ""),
+	}
+	teams := false
+	if p := event.GetOnlineMeetingProvider(); p != nil {
+		info.Provider = p.String()
+		teams = *p == models.TEAMSFORBUSINESS_ONLINEMEETINGPROVIDERTYPE
+	}
+	if om := event.GetOnlineMeeting(); om != nil {
+		if u := deref(om.GetJoinUrl(), ""); u != "" {
+			info.JoinURL = u
+		}
+		info.ConferenceID = deref(om.GetConferenceId(), "")
+		info.TollNumber = deref(om.GetTollNumber(), "")
+		info.TollFreeNumbers = om.GetTollFreeNumbers()
+		info.QuickDial = deref(om.GetQuickDial(), "")
+		for _, p := range om.GetPhones() {
+			phone := MeetingPhone{
+				Number:   deref(p.GetNumber(), ""),
+				Region:   deref(p.GetRegion(), ""),
+				Language: deref(p.GetLanguage(), ""),
+			}
+			if t := p.GetTypeEscaped(); t != nil {
+				phone.Type = t.String()
+			}
+			info.Phones = append(info.Phones, phone)
+		}
+	}
+	isOnline := event.GetIsOnlineMeeting() != nil && *event.GetIsOnlineMeeting()
+	if !isOnline && info.JoinURL == "" {
+		return fmt.Errorf("%q is not an online meeting", info.Subject)
+	}
+
+	if teams && info.JoinURL != "" {
+		addTeamsDetails(ctx, client, artifacts, &info)
+	} else {
+		info.Notes = append(info.Notes, "lobby settings, recordings, and transcripts are only available for Teams meetings")
+	}
+
+	if jsonOutput {
+		return printJSON(info)
+	}
+	printMeetingInfo(info)
+	return nil
+}
+
+// addTeamsDetails looks the meeting up by its join URL and adds its audio
+// conferencing, lobby settings, recordings, and transcripts.
+func addTeamsDetails(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, artifacts ArtifactSource, info *OnlineMeeting) {
+	filter := fmt.Sprintf("JoinWebUrl eq '%s'", strings.ReplaceAll(info.JoinURL, "'", "''"))
+	result, err := mailbox.Of(client).OnlineMeetings().Get(ctx, &users.ItemOnlineMeetingsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemOnlineMeetingsRequestBuilderGetQueryParameters{Filter: &filter},
+	})
+	if err != nil {
+		info.Notes = append(info.Notes, fmt.Sprintf("could not read the Teams meeting: %v", err))
+		return
+	}
+	if len(result.GetValue()) == 0 {
+		info.Notes = append(info.Notes, "the Teams meeting was not found; meetings organized by someone else are visible only to their organizer")
+		return
+	}
+	meeting := result.GetValue()[0]
+	meetingID := deref(meeting.GetId(), "")
+
+	info.MeetingOptionsURL = deref(meeting.GetMeetingOptionsWebUrl(), "")
+	info.RecordAutomatically = meeting.GetRecordAutomatically()
+	if ac := meeting.GetAudioConferencing(); ac != nil {
+		if info.ConferenceID == "" {
+			info.ConferenceID = deref(ac.GetConferenceId(), "")
+		}
+		if info.TollNumber == "" {
+			info.TollNumber = deref(ac.GetTollNumber(), "")
+		}
+		if len(info.TollFreeNumbers) == 0 {
+			info.TollFreeNumbers = ac.GetTollFreeNumbers()
+		}
+		info.DialInURL = deref(ac.GetDialinUrl(), "")
+	}
+	if lobby := meeting.GetLobbyBypassSettings(); lobby != nil {
+		if s := lobby.GetScope(); s != nil {
+			info.LobbyBypass = s.String()
+		}
+		info.DialInBypassesLobby = lobby.GetIsDialInBypassEnabled()
+	}
+	if p := meeting.GetAllowedPresenters(); p != nil {
+		info.AllowedPresenters = p.String()
+	}
+
+	artifactClient, err := artifacts.Client()
+	if err != nil {
+		info.Notes = append(info.Notes, fmt.Sprintf("recordings and transcripts unavailable: %v", err))
+		return
+	}
+	unavailable := func(what, permission string, err error) {
+		info.Notes = append(info.Notes, fmt.Sprintf("%s unavailable: %v", what, err))
+		if help := artifacts.Help(err, permission); help != "" {
+			info.Notes = append(info.Notes, help)
+		}
+	}
+	meetingBuilder := mailbox.Of(artifactClient).OnlineMeetings().ByOnlineMeetingId(meetingID)
+	if recordings, err := meetingBuilder.Recordings().Get(ctx, nil); err != nil {
+		unavailable("recordings", "OnlineMeetingRecording.Read.All", err)
+	} else {
+		for _, r := range recordings.GetValue() {
+			info.Recordings = append(info.Recordings, MeetingArtifact{
+				ID:         deref(r.GetId(), ""),
+				Created:    formatTimestamp(r.GetCreatedDateTime()),
+				ContentURL: deref(r.GetRecordingContentUrl(), ""),
+			})
+		}
+	}
+	if transcripts, err := meetingBuilder.Transcripts().Get(ctx, nil); err != nil {
+		unavailable("transcripts", "OnlineMeetingTranscript.Read.All", err)
+	} else {
+		for _, t := range transcripts.GetValue() {
+			info.Transcripts = append(info.Transcripts, MeetingArtifact{
+				ID:         deref(t.GetId(), ""),
+				Created:    formatTimestamp(t.GetCreatedDateTime()),
+				ContentURL: deref(t.GetTranscriptContentUrl(), ""),
+			})
+		}
+	}
+}
+
+func printMeetingInfo(info OnlineMeeting) {
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Printf("%-20s %s\n", label+":", value)
+		}
+	}
+	fmt.Println()
+	row("Subject", info.Subject)
+	row("When", info.Start+" → "+info.End)
+	row("Provider", info.Provider)
+	row("Join", info.JoinURL)
+	row("Conference ID", info.ConferenceID)
+	row("Dial-in", info.TollNumber)
+	row("Toll-free", strings.Join(info.TollFreeNumbers, ", "))
+	for _, p := range info.Phones {
+		row("Phone ("+p.Type+")", strings.TrimSpace(p.Number+" "+p.Region))
+	}
+	row("Quick dial", info.QuickDial)
+	row("Local numbers", info.DialInURL)
+	row("Meeting options", info.MeetingOptionsURL)
+	row("Lobby bypass", info.LobbyBypass)
+	if info.DialInBypassesLobby != nil {
+		row("Dial-in skips lobby", fmt.Sprintf("%t", *info.DialInBypassesLobby))
+	}
+	row("Presenters", info.AllowedPresenters)
+	if info.RecordAutomatically != nil {
+		row("Auto-record", fmt.Sprintf("%t", *info.RecordAutomatically))
+	}
+	for _, r := range info.Recordings {
+		row("Recording", r.Created+"  "+r.ContentURL)
+	}
+	for _, t := range info.Transcripts {
+		row("Transcript", t.Created+"  "+t.ContentURL)
+	}
+	for _, n := range info.Notes {
+		slog.Info(n)
+	}
+}
+
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
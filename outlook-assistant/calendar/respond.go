@@ -0,0 +1,79 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Respond ----------
+
+// Respond answers the meeting invitation identified by ref (list index or
+// Graph ID). response is accept, decline or tentative. comment, if given, is
+// included in the reply to the organizer; with sendResponse false nothing is
+// sent and only your calendar is updated. A declined meeting is removed from
+// the calendar by Exchange.
+func Respond(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, response, comment string, sendResponse bool) error {
+	id, err := resolveEventID(ref)
+	if err != nil {
+		return err
+	}
+	response = strings.ToLower(strings.TrimSpace(response))
+	switch response {
+	case "accept", "decline", "tentative":
+	default:
+		return fmt.Errorf("invalid --response %q (use accept, decline, or tentative)", response)
+	}
+	if comment != "" && !sendResponse {
+		return fmt.Errorf("--comment is sent with the response, so it cannot be combined with --send-response=false")
+	}
+
+	event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject", "isOrganizer"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading event: %w", err)
+	}
+	subject := deref(event.GetSubject(), "")
+	if event.GetIsOrganizer() != nil && *event.GetIsOrganizer() {
+		return fmt.Errorf("%q is your own meeting — only invitations from others can be answered", subject)
+	}
+
+	var commentPtr *string
+	if comment != "" {
+		commentPtr = &comment
+	}
+	item := mailbox.Of(client).Events().ByEventId(id)
+	switch response {
+	case "accept":
+		body := users.NewItemEventsItemAcceptPostRequestBody()
+		body.SetComment(commentPtr)
+		body.SetSendResponse(&sendResponse)
+		err = item.Accept().Post(ctx, body, nil)
+	case "decline":
+		body := users.NewItemEventsItemDeclinePostRequestBody()
+		body.SetComment(commentPtr)
+		body.SetSendResponse(&sendResponse)
+		err = item.Decline().Post(ctx, body, nil)
+	case "tentative":
+		body := users.NewItemEventsItemTentativelyAcceptPostRequestBody()
+		body.SetComment(commentPtr)
+		body.SetSendResponse(&sendResponse)
+		err = item.TentativelyAccept().Post(ctx, body, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("responding to event: %w", err)
+	}
+
+	slog.Info("Response recorded", "subject", subject, "response", response, "sent", sendResponse)
+	return nil
+}
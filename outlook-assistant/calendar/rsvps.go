@@ -0,0 +1,182 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Attendees and responses ----------
+
+// AttendeeResponse is the JSON representation of an attendee and their
+// answer to the invitation.
+type AttendeeResponse struct {
+	Name     string `json:"name,omitempty"`
+	Address  string `json:"address"`
+	Type     string `json:"type"`           // required, optional, or resource
+	Response string `json:"response"`       // accepted, tentativelyAccepted, declined, none, or notResponded
+	Time     string `json:"time,omitempty"` // when they answered, in local time
+}
+
+// RSVPReport is the JSON representation of who has answered a meeting
+// invitation, and how.
+type RSVPReport struct {
+	ID         string             `json:"id"`
+	Subject    string             `json:"subject"`
+	Start      string             `json:"start"`
+	TimeZone   string             `json:"timeZone"` // the zone start and answer times are shown in
+	Organizer  string             `json:"organizer"`
+	Accepted   int                `json:"accepted"`
+	Tentative  int                `json:"tentative"`
+	Declined   int                `json:"declined"`
+	NoResponse int                `json:"noResponse"`
+	Attendees  []AttendeeResponse `json:"attendees"`
+}
+
+// attendeeResponses lists an event's attendees with their responses.
+func attendeeResponses(event models.Eventable) []AttendeeResponse {
+	out := []AttendeeResponse{}
+	for _, a := range event.GetAttendees() {
+		if a.GetEmailAddress() == nil {
+			continue
+		}
+		r := AttendeeResponse{
+			Name:     deref(a.GetEmailAddress().GetName(), ""),
+			Address:  deref(a.GetEmailAddress().GetAddress(), ""),
+			Type:     "required",
+			Response: "none",
+		}
+		if a.GetTypeEscaped() != nil {
+			r.Type = a.GetTypeEscaped().String()
+		}
+		if status := a.GetStatus(); status != nil {
+			if status.GetResponse() != nil {
+				r.Response = status.GetResponse().String()
+			}
+			// Graph sets the time to year 1 until there is an answer.
+			if t := status.GetTime(); t != nil && t.Year() > 1 {
+				r.Time = t.Local().Format("2006-01-02 15:04")
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// responseLabel is how a response reads in text output.
+func responseLabel(response string) string {
+	switch response {
+	case "tentativelyAccepted":
+		return "tentative"
+	case "none", "notResponded", "":
+		return "no response"
+	}
+	return response
+}
+
+// RSVPs reports who has accepted, tentatively accepted, or declined the
+// meeting identified by ref (list index or Graph ID), and who has not
+// answered. Exchange tracks the answers on the organizer's copy of the
+// meeting, so for a meeting someone else organized every attendee shows as
+// not having responded.
+func RSVPs(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, jsonOutput bool) error {
+	id, err := resolveEventID(ref)
+	if err != nil {
+		return err
+	}
+	event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject", "start", "isAllDay", "organizer", "isOrganizer", "attendees"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading event: %w", err)
+	}
+
+	report := RSVPReport{
+		ID:        deref(event.GetId(), ""),
+		Subject:   deref(event.GetSubject(), ""),
+		Start:     formatEventTime(event.GetStart(), isAllDay(event)),
+		TimeZone:  zoneLabel(),
+		Attendees: attendeeResponses(event),
+	}
+	if event.GetOrganizer() != nil && event.GetOrganizer().GetEmailAddress() != nil {
+		report.Organizer = deref(event.GetOrganizer().GetEmailAddress().GetAddress(), "")
+	}
+	for _, a := range report.Attendees {
+		switch a.Response {
+		case "accepted":
+			report.Accepted++
+		case "tentativelyAccepted":
+			report.Tentative++
+		case "declined":
+			report.Declined++
+		default:
+			report.NoResponse++
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\n%s — %s (%s)\n", deref(event.GetSubject(), "(no subject)"), report.Start, report.TimeZone)
+	fmt.Printf("%d accepted, %d tentative, %d declined, %d no response\n\n",
+		report.Accepted, report.Tentative, report.Declined, report.NoResponse)
+	fmt.Printf("%-40s  %-9s  %-12s  %s\n", "Attendee", "Type", "Response", "Answered")
+	fmt.Println(strings.Repeat("-", 84))
+	for _, a := range report.Attendees {
+		who := a.Address
+		if a.Name != "" && a.Name != a.Address {
+			who = a.Name + " <" + a.Address + ">"
+		}
+		fmt.Printf("%-40s  %-9s  %-12s  %s\n", truncate(who, 40), a.Type, responseLabel(a.Response), a.Time)
+	}
+	if event.GetIsOrganizer() != nil && !*event.GetIsOrganizer() {
+		fmt.Println("\n(responses are tracked only on the organizer's copy of the meeting)")
+	}
+	return nil
+}
+
+// replaceAttendees returns the event's attendee list with the required
+// attendees replaced by required and the optional ones by optional, where
+// given. Resources such as booked rooms are kept.
+func replaceAttendees(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, id, required, optional string) ([]models.Attendeeable, error) {
+	newRequired := attendeeList(required, models.REQUIRED_ATTENDEETYPE)
+	newOptional := attendeeList(optional, models.OPTIONAL_ATTENDEETYPE)
+	if required != "" && len(newRequired) == 0 {
+		return nil, fmt.Errorf("--attendees lists no addresses")
+	}
+	if optional != "" && len(newOptional) == 0 {
+		return nil, fmt.Errorf("--optional-attendees lists no addresses")
+	}
+
+	event, err := mailbox.Of(client).Events().ByEventId(id).Get(ctx, &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+			Select: []string{"attendees"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading attendees: %w", err)
+	}
+	var list []models.Attendeeable
+	for _, a := range event.GetAttendees() {
+		kind := models.REQUIRED_ATTENDEETYPE
+		if a.GetTypeEscaped() != nil {
+			kind = *a.GetTypeEscaped()
+		}
+		if (kind == models.REQUIRED_ATTENDEETYPE && required != "") || (kind == models.OPTIONAL_ATTENDEETYPE && optional != "") {
+			continue
+		}
+		list = append(list, a)
+	}
+	list = append(list, newRequired...)
+	return append(list, newOptional...), nil
+}
@@ -0,0 +1,120 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Series masters ----------
+
+// Recurrence is the JSON representation of a series master's recurrence rule,
+// field for field, so it can be reproduced in another calendar.
+type Recurrence struct {
+	Pattern        string   `json:"pattern"` // daily, weekly, absoluteMonthly, relativeMonthly, absoluteYearly, relativeYearly
+	Interval       int32    `json:"interval"`
+	DaysOfWeek     []string `json:"daysOfWeek,omitempty"`
+	DayOfMonth     int32    `json:"dayOfMonth,omitempty"`
+	Month          int32    `json:"month,omitempty"`
+	Index          string   `json:"index,omitempty"` // first..last, for relative patterns
+	FirstDayOfWeek string   `json:"firstDayOfWeek,omitempty"`
+	Range          string   `json:"range"` // noEnd, endDate, or numbered
+	StartDate      string   `json:"startDate,omitempty"`
+	EndDate        string   `json:"endDate,omitempty"`
+	Occurrences    int32    `json:"occurrences,omitempty"`
+	TimeZone       string   `json:"timeZone,omitempty"`
+}
+
+// seriesMasters returns the events overlapping [start, end) with each
+// recurring occurrence replaced by its series master, listed once at the
+// position of its first occurrence. At most count events are returned.
+func seriesMasters(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, start, end time.Time, fields []string, count int) ([]models.Eventable, error) {
+	occurrences, err := eventsBetween(ctx, client, start, end, append(fields, "type", "seriesMasterId"))
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.Eventable
+	seen := map[string]bool{}
+	for _, event := range occurrences {
+		if len(events) >= count {
+			break
+		}
+		masterID := deref(event.GetSeriesMasterId(), "")
+		if masterID == "" {
+			events = append(events, event)
+			continue
+		}
+		if seen[masterID] {
+			continue
+		}
+		seen[masterID] = true
+		config := &users.ItemEventsEventItemRequestBuilderGetRequestConfiguration{
+			Headers: abstractions.NewRequestHeaders(),
+			QueryParameters: &users.ItemEventsEventItemRequestBuilderGetQueryParameters{
+				Select: append(fields, "type", "recurrence"),
+			},
+		}
+		config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+		master, err := mailbox.Of(client).Events().ByEventId(masterID).Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("fetching series master of %q: %w", deref(event.GetSubject(), ""), err)
+		}
+		events = append(events, master)
+	}
+	return events, nil
+}
+
+func recurrenceOf(event models.Eventable) *Recurrence {
+	pr := event.GetRecurrence()
+	if pr == nil || pr.GetPattern() == nil {
+		return nil
+	}
+	r := &Recurrence{}
+	p := pr.GetPattern()
+	if t := p.GetTypeEscaped(); t != nil {
+		r.Pattern = t.String()
+	}
+	if p.GetInterval() != nil {
+		r.Interval = *p.GetInterval()
+	}
+	for _, d := range p.GetDaysOfWeek() {
+		r.DaysOfWeek = append(r.DaysOfWeek, d.String())
+	}
+	if p.GetDayOfMonth() != nil {
+		r.DayOfMonth = *p.GetDayOfMonth()
+	}
+	if p.GetMonth() != nil {
+		r.Month = *p.GetMonth()
+	}
+	if p.GetIndex() != nil && (r.Pattern == "relativeMonthly" || r.Pattern == "relativeYearly") {
+		r.Index = p.GetIndex().String()
+	}
+	if p.GetFirstDayOfWeek() != nil && r.Pattern == "weekly" {
+		r.FirstDayOfWeek = p.GetFirstDayOfWeek().String()
+	}
+	if rng := pr.GetRangeEscaped(); rng != nil {
+		if t := rng.GetTypeEscaped(); t != nil {
+			r.Range = t.String()
+		}
+		if d := rng.GetStartDate(); d != nil {
+			r.StartDate = d.String()
+		}
+		if d := rng.GetEndDate(); d != nil && r.Range == "endDate" {
+			r.EndDate = d.String()
+		}
+		if n := rng.GetNumberOfOccurrences(); n != nil && r.Range == "numbered" {
+			r.Occurrences = *n
+		}
+		r.TimeZone = deref(rng.GetRecurrenceTimeZone(), "")
+	}
+	return r
+}
@@ -0,0 +1,446 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Mailbox settings ----------
+
+// settingsMaxAge is how long cached mailbox settings are used before calendar
+// commands read them from Graph again; settings mailbox always does.
+const settingsMaxAge = 24 * time.Hour
+
+// WorkingHours is the JSON representation of the mailbox's working hours.
+// Start and End are times of day (HH:MM) in TimeZone, which may differ from
+// the mailbox's own time zone.
+type WorkingHours struct {
+	Days     []string `json:"daysOfWeek"`
+	Start    string   `json:"startTime"`
+	End      string   `json:"endTime"`
+	TimeZone string   `json:"timeZone,omitempty"`
+}
+
+// MailboxSettings is the JSON representation of the mailbox's regional
+// settings and working hours, as settings mailbox shows them and as calendar
+// commands read them from the local cache. DateFormat and TimeFormat are
+// patterns such as dd.MM.yyyy and HH:mm.
+type MailboxSettings struct {
+	TimeZone     string       `json:"timeZone"`
+	WorkingHours WorkingHours `json:"workingHours"`
+	DateFormat   string       `json:"dateFormat"`
+	TimeFormat   string       `json:"timeFormat"`
+	Language     string       `json:"language"` // locale, such as en-US
+	LanguageName string       `json:"languageName,omitempty"`
+}
+
+// SettingsUpdate lists the changes requested by `settings mailbox`. Empty
+// fields are left as they are.
+type SettingsUpdate struct {
+	TimeZone   string // IANA or Windows name
+	WorkDays   string // mon-fri, or mon,tue,thu
+	WorkHours  string // 09:00-17:30
+	DateFormat string
+	TimeFormat string
+	Language   string
+}
+
+func (u SettingsUpdate) empty() bool {
+	return u.TimeZone == "" && u.WorkDays == "" && u.WorkHours == "" &&
+		u.DateFormat == "" && u.TimeFormat == "" && u.Language == ""
+}
+
+// localePattern matches a language tag such as en, en-US, or sr-Latn-RS.
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ShowSettings applies any requested changes to the mailbox settings and then
+// prints them. Either way the settings are saved to the local cache that
+// calendar commands read their time zone and working hours from.
+func ShowSettings(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, update SettingsUpdate, jsonOutput bool) error {
+	current, err := fetchSettings(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if !update.empty() {
+		patch, err := applySettings(&current, update)
+		if err != nil {
+			return err
+		}
+		if _, err := mailbox.Of(client).MailboxSettings().Patch(ctx, patch, nil); err != nil {
+			return fmt.Errorf("updating mailbox settings: %w", err)
+		}
+		slog.Info("Mailbox settings updated", "timeZone", current.TimeZone)
+	}
+	saveSettingsCache(current)
+
+	if jsonOutput {
+		return printJSON(current)
+	}
+
+	language := orNone(current.Language)
+	if current.LanguageName != "" {
+		language += " (" + current.LanguageName + ")"
+	}
+	hours := workingHoursText(current.WorkingHours)
+	if current.WorkingHours.TimeZone != "" && current.WorkingHours.TimeZone != current.TimeZone {
+		hours += " (" + current.WorkingHours.TimeZone + ")"
+	}
+	fmt.Printf("\nTime zone     : %s\n", orNone(current.TimeZone))
+	fmt.Printf("Working hours : %s\n", hours)
+	fmt.Printf("Date format   : %s\n", orNone(current.DateFormat))
+	fmt.Printf("Time format   : %s\n", orNone(current.TimeFormat))
+	fmt.Printf("Language      : %s\n", language)
+	return nil
+}
+
+// applySettings merges update into s and returns the patch that makes the same
+// changes in Graph. Changing the time zone moves the working hours to it too,
+// keeping their times of day, as when Outlook is set up in a new place.
+func applySettings(s *MailboxSettings, update SettingsUpdate) (models.MailboxSettingsable, error) {
+	patch := models.NewMailboxSettings()
+	hours := s.WorkingHours
+
+	if update.TimeZone != "" {
+		if _, err := loadZone(update.TimeZone); err != nil {
+			return nil, fmt.Errorf("--mailbox-timezone: %w", err)
+		}
+		s.TimeZone = update.TimeZone
+		hours.TimeZone = update.TimeZone
+		patch.SetTimeZone(&s.TimeZone)
+	}
+	if update.WorkDays != "" {
+		days, err := parseWorkDays(update.WorkDays)
+		if err != nil {
+			return nil, err
+		}
+		hours.Days = days
+	}
+	if update.WorkHours != "" {
+		start, end, err := parseWorkHours(update.WorkHours)
+		if err != nil {
+			return nil, err
+		}
+		hours.Start, hours.End = start, end
+	}
+	if update.TimeZone != "" || update.WorkDays != "" || update.WorkHours != "" {
+		wh, err := workingHoursModel(hours)
+		if err != nil {
+			return nil, err
+		}
+		patch.SetWorkingHours(wh)
+		s.WorkingHours = hours
+	}
+
+	if update.DateFormat != "" {
+		s.DateFormat = update.DateFormat
+		patch.SetDateFormat(&s.DateFormat)
+	}
+	if update.TimeFormat != "" {
+		s.TimeFormat = update.TimeFormat
+		patch.SetTimeFormat(&s.TimeFormat)
+	}
+	if update.Language != "" {
+		if !localePattern.MatchString(update.Language) {
+			return nil, fmt.Errorf("invalid --language %q — use a locale such as en-US or de-DE", update.Language)
+		}
+		s.Language, s.LanguageName = update.Language, ""
+		locale := models.NewLocaleInfo()
+		locale.SetLocale(&s.Language)
+		patch.SetLanguage(locale)
+	}
+	return patch, nil
+}
+
+// workingHoursModel builds the Graph working hours from h. Graph replaces the
+// working hours as a whole, so every field is sent.
+func workingHoursModel(h WorkingHours) (models.WorkingHoursable, error) {
+	wh := models.NewWorkingHours()
+	days := make([]models.DayOfWeek, 0, len(h.Days))
+	for _, name := range h.Days {
+		d, err := parseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, models.DayOfWeek(d))
+	}
+	wh.SetDaysOfWeek(days)
+	for _, t := range []struct {
+		value string
+		set   func(*serialization.TimeOnly)
+	}{
+		{h.Start, wh.SetStartTime},
+		{h.End, wh.SetEndTime},
+	} {
+		clock, err := time.Parse("15:04", t.value)
+		if err != nil {
+			return nil, fmt.Errorf("the mailbox has no working hours set — give --work-hours too")
+		}
+		t.set(serialization.NewTimeOnly(clock))
+	}
+	if h.TimeZone != "" {
+		zone := models.NewTimeZoneBase()
+		zone.SetName(&h.TimeZone)
+		wh.SetTimeZone(zone)
+	}
+	return wh, nil
+}
+
+// parseWorkDays reads --work-days: a range such as mon-fri or sun-thu, or
+// days separated by commas. It returns Graph's day names in week order from
+// the first day given.
+func parseWorkDays(s string) ([]string, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := parseWeekday(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --work-days: %w", err)
+		}
+		last := first
+		if isRange {
+			if last, err = parseWeekday(to); err != nil {
+				return nil, fmt.Errorf("invalid --work-days: %w", err)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("--work-days needs at least one day, such as mon-fri")
+	}
+	seen := map[time.Weekday]bool{}
+	var names []string
+	for _, d := range days {
+		if !seen[d] {
+			seen[d] = true
+			names = append(names, strings.ToLower(d.String()))
+		}
+	}
+	return names, nil
+}
+
+// hoursPattern separates the two times of --work-hours.
+var hoursPattern = regexp.MustCompile(`\s*(?:-|–|\bto\b)\s*`)
+
+// parseWorkHours reads --work-hours, two times of day such as 09:00-17:30 or
+// 9am-5pm, and returns them as HH:MM.
+func parseWorkHours(s string) (string, string, error) {
+	parts := hoursPattern.Split(strings.TrimSpace(s), -1)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid --work-hours %q — use e.g. 09:00-17:30", s)
+	}
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	start, err := atClock(day, parts[0])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid --work-hours: %w", err)
+	}
+	end, err := atClock(day, parts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid --work-hours: %w", err)
+	}
+	if !end.After(start) {
+		return "", "", fmt.Errorf("--work-hours must end after they start")
+	}
+	return start.Format("15:04"), end.Format("15:04"), nil
+}
+
+// workingHoursText describes working hours in words: "Mon–Fri 09:00–17:00".
+func workingHoursText(h WorkingHours) string {
+	if len(h.Days) == 0 || h.Start == "" {
+		return "(none)"
+	}
+	var days []time.Weekday
+	for _, name := range h.Days {
+		if d, err := parseWeekday(name); err == nil {
+			days = append(days, d)
+		}
+	}
+	short := func(d time.Weekday) string { return d.String()[:3] }
+	consecutive := len(days) > 2
+	for i := 1; i < len(days); i++ {
+		if days[i] != (days[i-1]+1)%7 {
+			consecutive = false
+		}
+	}
+	var text string
+	if consecutive {
+		text = short(days[0]) + "–" + short(days[len(days)-1])
+	} else {
+		names := make([]string, len(days))
+		for i, d := range days {
+			names[i] = short(d)
+		}
+		text = strings.Join(names, ", ")
+	}
+	return text + " " + h.Start + "–" + h.End
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// fetchSettings reads the mailbox settings from Graph.
+func fetchSettings(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) (MailboxSettings, error) {
+	settings, err := mailbox.Of(client).MailboxSettings().Get(ctx, &users.ItemMailboxSettingsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailboxSettingsRequestBuilderGetQueryParameters{
+			Select: []string{"timeZone", "workingHours", "dateFormat", "timeFormat", "language"},
+		},
+	})
+	if err != nil {
+		return MailboxSettings{}, fmt.Errorf("reading mailbox settings: %w", err)
+	}
+	out := MailboxSettings{
+		TimeZone:     deref(settings.GetTimeZone(), ""),
+		WorkingHours: WorkingHours{Days: []string{}},
+		DateFormat:   deref(settings.GetDateFormat(), ""),
+		TimeFormat:   deref(settings.GetTimeFormat(), ""),
+	}
+	if wh := settings.GetWorkingHours(); wh != nil {
+		for _, d := range wh.GetDaysOfWeek() {
+			out.WorkingHours.Days = append(out.WorkingHours.Days, d.String())
+		}
+		if t := wh.GetStartTime(); t != nil {
+			out.WorkingHours.Start = t.String()[:5]
+		}
+		if t := wh.GetEndTime(); t != nil {
+			out.WorkingHours.End = t.String()[:5]
+		}
+		if tz := wh.GetTimeZone(); tz != nil {
+			out.WorkingHours.TimeZone = deref(tz.GetName(), "")
+		}
+	}
+	if l := settings.GetLanguage(); l != nil {
+		out.Language = deref(l.GetLocale(), "")
+		out.LanguageName = deref(l.GetDisplayName(), "")
+	}
+	return out, nil
+}
+
+// ---------- Settings cache (stored in home directory) ----------
+
+// settingsCache is the file calendar commands read mailbox settings from, so
+// that they need not ask Graph on every run.
+type settingsCache struct {
+	Fetched  time.Time       `json:"fetched"`
+	Settings MailboxSettings `json:"settings"`
+}
+
+func settingsCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, mailbox.CacheFile(".outlook-assistant-settings.json"))
+}
+
+func saveSettingsCache(s MailboxSettings) {
+	data, _ := json.Marshal(settingsCache{Fetched: time.Now().UTC(), Settings: s})
+	_ = os.WriteFile(settingsCachePath(), data, 0600)
+}
+
+// cachedSettings returns the mailbox settings from the local cache, reading
+// them from Graph when the cache is missing or older than settingsMaxAge.
+func cachedSettings(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) (MailboxSettings, error) {
+	if data, err := os.ReadFile(settingsCachePath()); err == nil {
+		var cache settingsCache
+		if json.Unmarshal(data, &cache) == nil && time.Since(cache.Fetched) < settingsMaxAge {
+			return cache.Settings, nil
+		}
+	}
+	s, err := fetchSettings(ctx, client)
+	if err != nil {
+		return MailboxSettings{}, err
+	}
+	saveSettingsCache(s)
+	return s, nil
+}
+
+// ---------- Working hours in views ----------
+
+// workWeek holds the working hours as calendar views use them. The zero value
+// means none are known.
+type workWeek struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration // since midnight in loc
+	loc        *time.Location
+}
+
+// workWeekOf reads the working hours from the cached settings, or returns the
+// zero workWeek when they cannot be read or are unset.
+func workWeekOf(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) (workWeek, WorkingHours) {
+	s, err := cachedSettings(ctx, client)
+	if err != nil {
+		slog.Debug("Working hours unknown", "reason", err)
+		return workWeek{}, WorkingHours{}
+	}
+	h := s.WorkingHours
+	start, errStart := time.Parse("15:04", h.Start)
+	end, errEnd := time.Parse("15:04", h.End)
+	if len(h.Days) == 0 || errStart != nil || errEnd != nil {
+		return workWeek{}, WorkingHours{}
+	}
+	w := workWeek{
+		days:  map[time.Weekday]bool{},
+		start: time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+		end:   time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+		loc:   time.Local,
+	}
+	if h.TimeZone != "" {
+		if loc, err := loadZone(h.TimeZone); err == nil {
+			w.loc = loc
+		}
+	}
+	for _, name := range h.Days {
+		if d, err := parseWeekday(name); err == nil {
+			w.days[d] = true
+		}
+	}
+	return w, h
+}
+
+// firstDay is the day a week starts on in views: the first working day after a
+// day off, so Monday for mon-fri and Sunday for sun-thu. Without working
+// hours, or with every day worked, it is Monday.
+func (w workWeek) firstDay() time.Weekday {
+	for d := time.Monday; d < time.Monday+7; d++ {
+		if w.days[d%7] && !w.days[(d+6)%7] {
+			return d % 7
+		}
+	}
+	return time.Monday
+}
+
+// outside reports whether a timed event from start to end falls outside the
+// working hours, in whole or in part. It is false when none are known.
+func (w workWeek) outside(start, end time.Time) bool {
+	if w.days == nil {
+		return false
+	}
+	start, end = start.In(w.loc), end.In(w.loc)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, w.loc)
+	return !w.days[start.Weekday()] || start.Before(day.Add(w.start)) || end.After(day.Add(w.end))
+}
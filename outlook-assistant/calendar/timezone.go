@@ -0,0 +1,222 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// ---------- Time zone ----------
+
+// Event times given on the command line are read in time.Local, and event
+// times from Graph are shown in it. main sets time.Local from --timezone or,
+// for calendar commands without it, from MailboxTimeZone.
+
+// MailboxTimeZone returns the time zone set in the mailbox's Outlook settings,
+// the one Outlook shows the calendar in, as cached by cachedSettings. Exchange
+// names it either way: "W. Europe Standard Time" or "Europe/Berlin".
+func MailboxTimeZone(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) (*time.Location, error) {
+	settings, err := cachedSettings(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if settings.TimeZone == "" {
+		return nil, fmt.Errorf("the mailbox has no time zone set")
+	}
+	return loadZone(settings.TimeZone)
+}
+
+// loadZone returns the location for an IANA or Windows time zone name, as
+// Graph uses both.
+func loadZone(name string) (*time.Location, error) {
+	if iana, ok := windowsZones[strings.ToLower(name)]; ok {
+		name = iana
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// zoneName returns the name Graph is sent for loc, or "" for the system zone,
+// which Go knows only as "Local".
+func zoneName(loc *time.Location) string {
+	if name := loc.String(); name != "Local" {
+		return name
+	}
+	return ""
+}
+
+// zoneLabel names the zone event times are shown in: its IANA name, or the
+// system zone's abbreviation, such as CET.
+func zoneLabel() string {
+	if name := zoneName(time.Local); name != "" {
+		return name
+	}
+	abbr, _ := time.Now().Zone()
+	return abbr
+}
+
+// dateTimeTimeZone converts a time parsed from a flag into a Graph dateTime in
+// the local time zone, so the event keeps its wall-clock time there across
+// daylight-saving changes. The system zone has no name to send, so its times
+// go as UTC.
+func dateTimeTimeZone(t time.Time) models.DateTimeTimeZoneable {
+	tz := zoneName(time.Local)
+	if tz == "" {
+		t, tz = t.UTC(), "UTC"
+	} else {
+		t = t.In(time.Local)
+	}
+	formatted := t.Format("2006-01-02T15:04:05")
+	dt := models.NewDateTimeTimeZone()
+	dt.SetDateTime(&formatted)
+	dt.SetTimeZone(&tz)
+	return dt
+}
+
+// parseEventTime parses a Graph dateTime in its own time zone, UTC unless a
+// Prefer header or the event says otherwise. It is zero when unreadable.
+func parseEventTime(dt models.DateTimeTimeZoneable) time.Time {
+	if dt == nil {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if name := deref(dt.GetTimeZone(), ""); name != "" && !strings.EqualFold(name, "UTC") {
+		if l, err := loadZone(name); err == nil {
+			loc = l
+		}
+	}
+	s := deref(dt.GetDateTime(), "")
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.9999999", s, loc)
+	if err != nil {
+		if t, err = time.ParseInLocation("2006-01-02T15:04:05", s, loc); err != nil {
+			return time.Time{}
+		}
+	}
+	return t
+}
+
+// formatEventTime shows a Graph dateTime in local time. All-day events keep
+// their date, which is the same in every zone.
+func formatEventTime(dt models.DateTimeTimeZoneable, allDay bool) string {
+	t := parseEventTime(dt)
+	if t.IsZero() {
+		if dt == nil {
+			return ""
+		}
+		return deref(dt.GetDateTime(), "")
+	}
+	if !allDay {
+		t = t.Local()
+	}
+	return t.Format("Jan 02 15:04")
+}
+
+// windowsZones maps the Windows time zone names Exchange uses to IANA names,
+// following the CLDR table for each zone's main region. Keys are lower case.
+var windowsZones = map[string]string{
+	"dateline standard time":          "Etc/GMT+12",
+	"utc-11":                          "Etc/GMT+11",
+	"hawaiian standard time":          "Pacific/Honolulu",
+	"alaskan standard time":           "America/Anchorage",
+	"pacific standard time (mexico)":  "America/Tijuana",
+	"pacific standard time":           "America/Los_Angeles",
+	"us mountain standard time":       "America/Phoenix",
+	"mountain standard time (mexico)": "America/Mazatlan",
+	"mountain standard time":          "America/Denver",
+	"central america standard time":   "America/Guatemala",
+	"central standard time":           "America/Chicago",
+	"central standard time (mexico)":  "America/Mexico_City",
+	"canada central standard time":    "America/Regina",
+	"sa pacific standard time":        "America/Bogota",
+	"eastern standard time (mexico)":  "America/Cancun",
+	"eastern standard time":           "America/New_York",
+	"us eastern standard time":        "America/Indianapolis",
+	"venezuela standard time":         "America/Caracas",
+	"paraguay standard time":          "America/Asuncion",
+	"atlantic standard time":          "America/Halifax",
+	"central brazilian standard time": "America/Cuiaba",
+	"sa western standard time":        "America/La_Paz",
+	"pacific sa standard time":        "America/Santiago",
+	"newfoundland standard time":      "America/St_Johns",
+	"e. south america standard time":  "America/Sao_Paulo",
+	"argentina standard time":         "America/Buenos_Aires",
+	"sa eastern standard time":        "America/Cayenne",
+	"greenland standard time":         "America/Godthab",
+	"montevideo standard time":        "America/Montevideo",
+	"utc-02":                          "Etc/GMT+2",
+	"azores standard time":            "Atlantic/Azores",
+	"cape verde standard time":        "Atlantic/Cape_Verde",
+	"gmt standard time":               "Europe/London",
+	"greenwich standard time":         "Atlantic/Reykjavik",
+	"morocco standard time":           "Africa/Casablanca",
+	"w. europe standard time":         "Europe/Berlin",
+	"central europe standard time":    "Europe/Budapest",
+	"romance standard time":           "Europe/Paris",
+	"central european standard time":  "Europe/Warsaw",
+	"w. central africa standard time": "Africa/Lagos",
+	"jordan standard time":            "Asia/Amman",
+	"gtb standard time":               "Europe/Bucharest",
+	"middle east standard time":       "Asia/Beirut",
+	"egypt standard time":             "Africa/Cairo",
+	"e. europe standard time":         "Europe/Chisinau",
+	"syria standard time":             "Asia/Damascus",
+	"south africa standard time":      "Africa/Johannesburg",
+	"fle standard time":               "Europe/Kiev",
+	"israel standard time":            "Asia/Jerusalem",
+	"kaliningrad standard time":       "Europe/Kaliningrad",
+	"arabic standard time":            "Asia/Baghdad",
+	"turkey standard time":            "Europe/Istanbul",
+	"arab standard time":              "Asia/Riyadh",
+	"belarus standard time":           "Europe/Minsk",
+	"russian standard time":           "Europe/Moscow",
+	"e. africa standard time":         "Africa/Nairobi",
+	"iran standard time":              "Asia/Tehran",
+	"arabian standard time":           "Asia/Dubai",
+	"azerbaijan standard time":        "Asia/Baku",
+	"georgian standard time":          "Asia/Tbilisi",
+	"caucasus standard time":          "Asia/Yerevan",
+	"afghanistan standard time":       "Asia/Kabul",
+	"west asia standard time":         "Asia/Tashkent",
+	"ekaterinburg standard time":      "Asia/Yekaterinburg",
+	"pakistan standard time":          "Asia/Karachi",
+	"india standard time":             "Asia/Calcutta",
+	"sri lanka standard time":         "Asia/Colombo",
+	"nepal standard time":             "Asia/Katmandu",
+	"central asia standard time":      "Asia/Almaty",
+	"bangladesh standard time":        "Asia/Dhaka",
+	"myanmar standard time":           "Asia/Rangoon",
+	"se asia standard time":           "Asia/Bangkok",
+	"n. central asia standard time":   "Asia/Novosibirsk",
+	"china standard time":             "Asia/Shanghai",
+	"north asia standard time":        "Asia/Krasnoyarsk",
+	"singapore standard time":         "Asia/Singapore",
+	"w. australia standard time":      "Australia/Perth",
+	"taipei standard time":            "Asia/Taipei",
+	"ulaanbaatar standard time":       "Asia/Ulaanbaatar",
+	"north asia east standard time":   "Asia/Irkutsk",
+	"tokyo standard time":             "Asia/Tokyo",
+	"korea standard time":             "Asia/Seoul",
+	"yakutsk standard time":           "Asia/Yakutsk",
+	"cen. australia standard time":    "Australia/Adelaide",
+	"aus central standard time":       "Australia/Darwin",
+	"e. australia standard time":      "Australia/Brisbane",
+	"aus eastern standard time":       "Australia/Sydney",
+	"west pacific standard time":      "Pacific/Port_Moresby",
+	"tasmania standard time":          "Australia/Hobart",
+	"vladivostok standard time":       "Asia/Vladivostok",
+	"central pacific standard time":   "Pacific/Guadalcanal",
+	"new zealand standard time":       "Pacific/Auckland",
+	"utc+12":                          "Etc/GMT-12",
+	"fiji standard time":              "Pacific/Fiji",
+	"tonga standard time":             "Pacific/Tongatapu",
+	"samoa standard time":             "Pacific/Apia",
+	"line islands standard time":      "Pacific/Kiritimati",
+}
@@ -0,0 +1,416 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// ---------- Week view ----------
+
+// weekCellWidth is the width of one day column in the week grid; seven
+// columns and their separators fit a 120-column terminal.
+const weekCellWidth = 16
+
+// DayEvent is the JSON representation of an event within one day of a view.
+// Start and End are local times; Continued marks an event that began on an
+// earlier day.
+type DayEvent struct {
+	ID        string `json:"id"`
+	Subject   string `json:"subject"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	IsAllDay  bool   `json:"isAllDay"`
+	Location  string `json:"location,omitempty"`
+	Continued bool   `json:"continued,omitempty"`
+	// OutsideWorkingHours marks a timed event not wholly within the
+	// mailbox's working hours, when those are known.
+	OutsideWorkingHours bool `json:"outsideWorkingHours,omitempty"`
+}
+
+// DayView is the JSON representation of one day of a view.
+type DayView struct {
+	Date    string     `json:"date"`
+	Weekday string     `json:"weekday"`
+	Events  []DayEvent `json:"events"`
+}
+
+// WeekView is the JSON response for calendar week.
+type WeekView struct {
+	WeekStart    string        `json:"weekStart"`
+	WorkingHours *WorkingHours `json:"workingHours,omitempty"`
+	Days         []DayView     `json:"days"`
+}
+
+// localEvent is an event with its span converted to local time. All-day
+// events span whole local dates regardless of the time zone they were stored in.
+type localEvent struct {
+	event      models.Eventable
+	start, end time.Time
+	allDay     bool
+}
+
+// Week prints a seven-column grid of the week containing since (default:
+// today), starting on firstDay (default: the start of the mailbox's working
+// week, or Monday). Events are shown in local time; an event running past
+// midnight is repeated on each later day it covers, prefixed with "…". Timed
+// events outside the working hours are marked with "~".
+func Week(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, firstDay, since string, jsonOutput bool) error {
+	work, hours := workWeekOf(ctx, client)
+	weekday := work.firstDay()
+	if firstDay != "" {
+		d, err := parseWeekday(firstDay)
+		if err != nil {
+			return err
+		}
+		weekday = d
+	}
+	anchor := time.Now()
+	if since != "" {
+		t, err := parseBound(since)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		anchor = t
+	}
+	day := localMidnight(anchor)
+	weekStart := day.AddDate(0, 0, -((int(day.Weekday()) - int(weekday) + 7) % 7))
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	events, err := localEventsBetween(ctx, client, weekStart, weekEnd)
+	if err != nil {
+		return fmt.Errorf("listing calendar events: %w", err)
+	}
+
+	view := WeekView{WeekStart: weekStart.Format("2006-01-02")}
+	if work.days != nil {
+		view.WorkingHours = &hours
+	}
+	for i := 0; i < 7; i++ {
+		view.Days = append(view.Days, dayView(weekStart.AddDate(0, 0, i), events, work))
+	}
+
+	if jsonOutput {
+		return printJSON(view)
+	}
+
+	sep := strings.Repeat("-", weekCellWidth)
+	line := func(cells []string) {
+		fmt.Println(strings.TrimRight(strings.Join(cells, " "), " "))
+	}
+	cells := make([]string, 7)
+	rows := 0
+	for i, d := range view.Days {
+		date, _ := time.ParseInLocation("2006-01-02", d.Date, time.Local)
+		cells[i] = cell(date.Format("Mon Jan 02"), weekCellWidth)
+		if len(d.Events) > rows {
+			rows = len(d.Events)
+		}
+	}
+	fmt.Println()
+	line(cells)
+	for i := range cells {
+		cells[i] = sep
+	}
+	line(cells)
+	for r := 0; r < rows; r++ {
+		for i, d := range view.Days {
+			cells[i] = cell("", weekCellWidth)
+			if r < len(d.Events) {
+				cells[i] = cell(weekLabel(d.Events[r]), weekCellWidth)
+			}
+		}
+		line(cells)
+	}
+	if rows == 0 {
+		fmt.Println("No events this week.")
+	}
+	if view.WorkingHours != nil {
+		zone := hours.TimeZone
+		if zone == "" {
+			zone = zoneLabel()
+		}
+		fmt.Printf("\nWorking hours: %s (%s)\n", workingHoursText(hours), zone)
+	}
+	return nil
+}
+
+// weekLabel is an event's text in a week grid cell: its start time, or a
+// marker for all-day and continued events, then its subject; "~" in place of
+// the space marks an event outside the working hours.
+func weekLabel(e DayEvent) string {
+	subject := e.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	switch {
+	case e.Continued:
+		return "…" + subject
+	case e.IsAllDay:
+		return "* " + subject
+	case e.OutsideWorkingHours:
+		return e.Start[len(e.Start)-5:] + "~" + subject
+	default:
+		return e.Start[len(e.Start)-5:] + " " + subject
+	}
+}
+
+// ---------- Month view ----------
+
+// monthCellWidth is the width of one day column in the month grid.
+const monthCellWidth = 10
+
+// heatShades mark a day's busy hours in the month grid, lightest first: none,
+// then up to two, four, six, and more than six hours.
+var heatShades = []string{"·", "░", "▒", "▓", "█"}
+
+// MonthDay is the JSON representation of one day of calendar month.
+// BusyHours counts time shown as busy, tentative, or out of office in timed
+// events, with overlapping meetings counted once.
+type MonthDay struct {
+	Date         string  `json:"date"`
+	Weekday      string  `json:"weekday"`
+	BusyHours    float64 `json:"busyHours"`
+	Events       int     `json:"events"`
+	AllDayEvents int     `json:"allDayEvents"`
+}
+
+// MonthView is the JSON response for calendar month.
+type MonthView struct {
+	Month     string     `json:"month"`
+	BusyHours float64    `json:"busyHours"`
+	Events    int        `json:"events"`
+	Days      []MonthDay `json:"days"`
+}
+
+// Month prints a grid of month (YYYY-MM, default: this month) with each day's
+// busy hours and event count, shaded by how busy the day is, so light days
+// stand out. Weeks start on firstDay (default: as in Week).
+func Month(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, month, firstDay string, jsonOutput bool) error {
+	work, _ := workWeekOf(ctx, client)
+	weekday := work.firstDay()
+	if firstDay != "" {
+		d, err := parseWeekday(firstDay)
+		if err != nil {
+			return err
+		}
+		weekday = d
+	}
+	first := localMidnight(time.Now()).AddDate(0, 0, 1-time.Now().Day())
+	if month != "" {
+		t, err := time.ParseInLocation("2006-01", month, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --month %q — use format: 2006-01", month)
+		}
+		first = t
+	}
+	next := first.AddDate(0, 1, 0)
+
+	events, err := localEventsBetween(ctx, client, first, next)
+	if err != nil {
+		return fmt.Errorf("listing calendar events: %w", err)
+	}
+
+	view := MonthView{Month: first.Format("2006-01"), Days: []MonthDay{}}
+	for day := first; day.Before(next); day = day.AddDate(0, 0, 1) {
+		d := monthDay(day, events)
+		view.BusyHours += d.BusyHours
+		view.Days = append(view.Days, d)
+	}
+	view.BusyHours = math.Round(view.BusyHours*10) / 10
+	view.Events = len(events)
+
+	if jsonOutput {
+		return printJSON(view)
+	}
+
+	line := func(cells []string) {
+		fmt.Println(strings.TrimRight(strings.Join(cells, " "), " "))
+	}
+	fmt.Printf("\n%s\n\n", first.Format("January 2006"))
+	header := make([]string, 7)
+	for i := range header {
+		header[i] = cell(time.Weekday((int(weekday) + i) % 7).String()[:3], monthCellWidth)
+	}
+	line(header)
+
+	// Blank cells pad the first week back to firstDay.
+	lead := (int(first.Weekday()) - int(weekday) + 7) % 7
+	for w := -lead; w < len(view.Days); w += 7 {
+		top := make([]string, 7)
+		bottom := make([]string, 7)
+		for i := range top {
+			top[i], bottom[i] = cell("", monthCellWidth), cell("", monthCellWidth)
+			if n := w + i; n >= 0 && n < len(view.Days) {
+				d := view.Days[n]
+				top[i] = cell(fmt.Sprintf("%2d %s", n+1, strings.Repeat(heatShade(d.BusyHours), 3)), monthCellWidth)
+				if d.Events > 0 {
+					bottom[i] = cell(fmt.Sprintf("%4.1fh %2d", d.BusyHours, d.Events), monthCellWidth)
+				}
+			}
+		}
+		line(top)
+		line(bottom)
+	}
+	fmt.Printf("\n%s ≤2h  %s ≤4h  %s ≤6h  %s >6h   (busy hours, event count)\n",
+		heatShades[1], heatShades[2], heatShades[3], heatShades[4])
+	fmt.Printf("%.1f busy hours across %d events\n", view.BusyHours, view.Events)
+	return nil
+}
+
+// monthDay totals the events overlapping the local day starting at day.
+func monthDay(day time.Time, events []localEvent) MonthDay {
+	next := day.AddDate(0, 0, 1)
+	d := MonthDay{Date: day.Format("2006-01-02"), Weekday: day.Weekday().String()}
+	type span struct{ start, end time.Time }
+	var busy []span
+	for _, e := range events {
+		if !e.start.Before(next) || !e.end.After(day) {
+			continue
+		}
+		d.Events++
+		if e.allDay {
+			d.AllDayEvents++
+			continue
+		}
+		showAs := e.event.GetShowAs()
+		if showAs == nil || *showAs == models.FREE_FREEBUSYSTATUS || *showAs == models.WORKINGELSEWHERE_FREEBUSYSTATUS {
+			continue
+		}
+		s := span{e.start, e.end}
+		if s.start.Before(day) {
+			s.start = day
+		}
+		if s.end.After(next) {
+			s.end = next
+		}
+		busy = append(busy, s)
+	}
+
+	// Merge overlapping meetings so double-booked time is counted once.
+	sort.Slice(busy, func(i, j int) bool { return busy[i].start.Before(busy[j].start) })
+	var total time.Duration
+	var cur span
+	for i, s := range busy {
+		switch {
+		case i == 0:
+			cur = s
+		case s.start.After(cur.end):
+			total += cur.end.Sub(cur.start)
+			cur = s
+		case s.end.After(cur.end):
+			cur.end = s.end
+		}
+	}
+	if len(busy) > 0 {
+		total += cur.end.Sub(cur.start)
+	}
+	d.BusyHours = math.Round(total.Hours()*10) / 10
+	return d
+}
+
+func heatShade(hours float64) string {
+	i := int(math.Ceil(hours / 2))
+	if i >= len(heatShades) {
+		i = len(heatShades) - 1
+	}
+	return heatShades[i]
+}
+
+// ---------- Helpers ----------
+
+// localEventsBetween fetches the events overlapping [start, end) and converts
+// their times to local time.
+func localEventsBetween(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, start, end time.Time) ([]localEvent, error) {
+	events, err := eventsBetween(ctx, client, start, end,
+		[]string{"id", "subject", "start", "end", "isAllDay", "location", "showAs", "isCancelled"})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]localEvent, 0, len(events))
+	for _, event := range events {
+		if event.GetIsCancelled() != nil && *event.GetIsCancelled() {
+			continue
+		}
+		le := localEvent{
+			event:  event,
+			start:  parseEventTime(event.GetStart()),
+			end:    parseEventTime(event.GetEnd()),
+			allDay: event.GetIsAllDay() != nil && *event.GetIsAllDay(),
+		}
+		if le.start.IsZero() || le.end.IsZero() {
+			continue
+		}
+		if le.allDay {
+			// All-day events are dates, not instants: keep the calendar date.
+			le.start = time.Date(le.start.Year(), le.start.Month(), le.start.Day(), 0, 0, 0, 0, time.Local)
+			le.end = time.Date(le.end.Year(), le.end.Month(), le.end.Day(), 0, 0, 0, 0, time.Local)
+		} else {
+			le.start, le.end = le.start.Local(), le.end.Local()
+		}
+		out = append(out, le)
+	}
+	return out, nil
+}
+
+// dayView collects the events overlapping the local day starting at day,
+// marking those outside the working hours of work.
+func dayView(day time.Time, events []localEvent, work workWeek) DayView {
+	next := day.AddDate(0, 0, 1)
+	d := DayView{Date: day.Format("2006-01-02"), Weekday: day.Weekday().String(), Events: []DayEvent{}}
+	for _, e := range events {
+		if !e.start.Before(next) || !e.end.After(day) {
+			continue
+		}
+		de := DayEvent{
+			ID:        deref(e.event.GetId(), ""),
+			Subject:   deref(e.event.GetSubject(), ""),
+			Start:     e.start.Format("2006-01-02 15:04"),
+			End:       e.end.Format("2006-01-02 15:04"),
+			IsAllDay:  e.allDay,
+			Continued: e.start.Before(day),
+		}
+		if !e.allDay {
+			de.OutsideWorkingHours = work.outside(e.start, e.end)
+		}
+		if e.event.GetLocation() != nil {
+			de.Location = deref(e.event.GetLocation().GetDisplayName(), "")
+		}
+		d.Events = append(d.Events, de)
+	}
+	return d
+}
+
+func localMidnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q — use a day name such as monday or sun", s)
+}
+
+// cell pads or truncates s to exactly width runes, so that multi-byte
+// characters don't misalign the grid.
+func cell(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		r := []rune(s)
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,174 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"outlook-assistant/reldate"
+)
+
+// ---------- Event start and end ----------
+
+// clockPattern matches a time of day: 14:00, 9:30am, 2pm, or 2 pm.
+var clockPattern = regexp.MustCompile(`(?i)^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+// rangePattern splits a phrase ending in a time range, such as "next tuesday
+// 09:00–09:30" or "tomorrow 2-3pm", into the day and the two times.
+var rangePattern = regexp.MustCompile(`(?i)^(.*?)\s*\b(\d{1,2}(?::\d{2})?\s*(?:am|pm)?)\s*(?:-|–|—|\bto\b)\s*(\d{1,2}(?::\d{2})?\s*(?:am|pm)?)$`)
+
+// eventTimes works out when an event starts and ends. startStr is a date and
+// time as parseWhen reads it, or a day with a time range such as "next
+// tuesday 09:00–09:30", which sets the end too. Otherwise the end is endStr,
+// which may be a time alone on the start's day, or start plus duration.
+func eventTimes(startStr, endStr string, duration time.Duration) (time.Time, time.Time, error) {
+	now := time.Now()
+	var start, end time.Time
+	if m := rangePattern.FindStringSubmatch(strings.TrimSpace(startStr)); m != nil && !isDate(startStr) {
+		if endStr != "" || duration != 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("--start %q already gives the end; leave out --end and --duration", startStr)
+		}
+		day, err := parseDay(m[1], now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %w", err)
+		}
+		from, to := m[2], m[3]
+		if suffix := meridiem(to); meridiem(from) == "" && !strings.Contains(from, ":") && suffix != "" {
+			from += suffix // 2-3pm is 2pm to 3pm
+		}
+		if start, err = atClock(day, from); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %w", err)
+		}
+		if end, err = atClock(day, to); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %w", err)
+		}
+	} else {
+		var err error
+		if start, err = parseWhen(startStr, now); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %w", err)
+		}
+		switch {
+		case endStr != "" && duration != 0:
+			return time.Time{}, time.Time{}, fmt.Errorf("use either --end or --duration, not both")
+		case duration < 0:
+			return time.Time{}, time.Time{}, fmt.Errorf("--duration must be positive")
+		case duration > 0:
+			end = start.Add(duration)
+		case endStr == "":
+			return time.Time{}, time.Time{}, fmt.Errorf("--end or --duration is required, unless --start gives a range such as \"tomorrow 14:00-15:00\"")
+		default:
+			if clockPattern.MatchString(strings.TrimSpace(endStr)) {
+				end, err = atClock(start, endStr)
+			} else {
+				end, err = parseWhen(endStr, now)
+			}
+			if err != nil {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid --end: %w", err)
+			}
+		}
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the event ends (%s) before it starts (%s)", end.Format("2006-01-02 15:04"), start.Format("2006-01-02 15:04"))
+	}
+	return start, end, nil
+}
+
+// parseWhen parses a date and time as parseDateTime does, or as a phrase: a
+// day such as today, tomorrow, friday, or next tuesday, then optionally "at"
+// and a time such as 2pm or 14:30; "tomorrow 2pm", "mon at 9:30am". A time
+// alone is today. Like parseDateTime, it reads the time in time.Local.
+func parseWhen(s string, now time.Time) (time.Time, error) {
+	if t, err := parseDateTime(s); err == nil {
+		return t, nil
+	}
+	words := strings.Fields(strings.ToLower(s))
+	if n := len(words); n >= 2 && words[n-2] == "at" {
+		words = append(words[:n-2], words[n-1])
+	}
+	// The time is the last word, or the last two for "2 pm".
+	for split := len(words); split >= len(words)-2 && split >= 0; split-- {
+		clock := strings.Join(words[split:], " ")
+		if clock != "" && !clockPattern.MatchString(clock) {
+			continue
+		}
+		day, err := parseDay(strings.Join(words[:split], " "), now)
+		if err != nil {
+			continue
+		}
+		if clock == "" {
+			return day, nil
+		}
+		return atClock(day, clock)
+	}
+	return time.Time{}, fmt.Errorf("could not parse %q — use format: 2006-01-02 15:04, or a phrase such as \"tomorrow 2pm\" or \"next tuesday 09:00\"", s)
+}
+
+// parseDay parses the day part of a phrase: empty for today, a date, one of
+// the days reldate names, or a weekday alone for the next such day. It
+// returns midnight of that day in time.Local.
+func parseDay(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		s = "today"
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if _, err := parseWeekday(s); err == nil {
+		s = "next " + s
+	}
+	d, ok := reldate.Day(s, now)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown day %q — use a date, today, tomorrow, a weekday, or next <weekday>", s)
+	}
+	return d, nil
+}
+
+// atClock returns day at the time of day clock, such as 14:30 or 2:30pm.
+func atClock(day time.Time, clock string) (time.Time, error) {
+	m := clockPattern.FindStringSubmatch(strings.TrimSpace(clock))
+	if m == nil {
+		return time.Time{}, fmt.Errorf("unknown time %q — use e.g. 14:30 or 2:30pm", clock)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	if hour > 23 || minute > 59 || (m[3] != "" && (hour < 1 || hour > 12)) {
+		return time.Time{}, fmt.Errorf("unknown time %q — use e.g. 14:30 or 2:30pm", clock)
+	}
+	switch strings.ToLower(m[3]) {
+	case "am":
+		hour %= 12
+	case "pm":
+		hour = hour%12 + 12
+	}
+	t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	if t.Hour() != hour || t.Minute() != minute {
+		// The clock falls in the hour skipped when daylight saving time
+		// starts, which time.Date resolves backwards; move it on past the
+		// gap, as Outlook does.
+		_, before := t.Zone()
+		_, after := t.Add(24 * time.Hour).Zone()
+		t = t.Add(time.Duration(after-before) * time.Second)
+	}
+	return t, nil
+}
+
+// meridiem returns the am or pm that ends clock, or "".
+func meridiem(clock string) string {
+	clock = strings.ToLower(strings.TrimSpace(clock))
+	for _, suffix := range []string{"am", "pm"} {
+		if strings.HasSuffix(clock, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// isDate reports whether s is a date and time parseDateTime reads, whose
+// dashes must not be taken for a time range.
+func isDate(s string) bool {
+	_, err := parseDateTime(s)
+	return err == nil
+}
@@ -0,0 +1,197 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// inZone makes name the local zone for the rest of the test, as --timezone
+// does for a command. America/New_York has daylight saving time: 2026-03-08
+// 02:00 EST is 03:00 EDT, and 2026-11-01 02:00 EDT is 01:00 EST.
+func inZone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+	return loc
+}
+
+func TestParseWhen(t *testing.T) {
+	ny := inZone(t, "America/New_York")
+	// Wednesday, 2026-05-13.
+	now := time.Date(2026, 5, 13, 15, 30, 0, 0, ny)
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2026-05-20 09:15", time.Date(2026, 5, 20, 9, 15, 0, 0, ny), false},
+		{"2026-05-20T09:15", time.Date(2026, 5, 20, 9, 15, 0, 0, ny), false},
+		{"2026-05-20", time.Date(2026, 5, 20, 0, 0, 0, 0, ny), false},
+		{"20/05/2026 09:15", time.Date(2026, 5, 20, 9, 15, 0, 0, ny), false},
+		{"tomorrow 2pm", time.Date(2026, 5, 14, 14, 0, 0, 0, ny), false},
+		{"tomorrow 2 pm", time.Date(2026, 5, 14, 14, 0, 0, 0, ny), false},
+		{"mon at 9:30am", time.Date(2026, 5, 18, 9, 30, 0, 0, ny), false},
+		{"Next Tuesday 09:00", time.Date(2026, 5, 19, 9, 0, 0, 0, ny), false},
+		{"wednesday 10:00", time.Date(2026, 5, 20, 10, 0, 0, 0, ny), false},
+		{"2026-06-01 at 12am", time.Date(2026, 6, 1, 0, 0, 0, 0, ny), false},
+		{"12pm", time.Date(2026, 5, 13, 12, 0, 0, 0, ny), false},
+		{"friday", time.Date(2026, 5, 15, 0, 0, 0, 0, ny), false},
+		{"today", time.Date(2026, 5, 13, 0, 0, 0, 0, ny), false},
+		// 02:30 does not exist on the spring-forward day, so it moves on
+		// past the gap; 01:30 happens twice on the fall-back day, and is
+		// the first.
+		{"2026-03-08 at 2:30am", time.Date(2026, 3, 8, 3, 30, 0, 0, ny), false},
+		{"2026-11-01 at 1:30am", time.Date(2026, 11, 1, 1, 30, 0, 0, ny), false},
+		{"2026-05-20 9:15", time.Date(2026, 5, 20, 9, 15, 0, 0, ny), false},
+		{"at", time.Time{}, true},
+		{"tomorrow at", time.Time{}, true},
+		{"tomorrow 25:00", time.Time{}, true},
+		{"tomorrow 13pm", time.Time{}, true},
+		{"tomorrow 0am", time.Time{}, true},
+		{"tomorrow 9:60", time.Time{}, true},
+		{"someday 2pm", time.Time{}, true},
+		{"2026-02-30 10:00", time.Time{}, true},
+		{"2026-05-20 09:15 tomorrow", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWhen(tt.s, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseWhen(%q) = %v, %v; want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAtClock(t *testing.T) {
+	day := time.Date(2026, 5, 13, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		clock   string
+		hour    int
+		minute  int
+		wantErr bool
+	}{
+		{"14:30", 14, 30, false},
+		{"0:00", 0, 0, false},
+		{"23:59", 23, 59, false},
+		{"9", 9, 0, false},
+		{"2:30pm", 14, 30, false},
+		{"2:30 PM", 14, 30, false},
+		{"12am", 0, 0, false},
+		{"12:15am", 0, 15, false},
+		{"12pm", 12, 0, false},
+		{"  7am ", 7, 0, false},
+		{"", 0, 0, true},
+		{"24:00", 0, 0, true},
+		{"10:5", 0, 0, true},
+		{"10:60", 0, 0, true},
+		{"0pm", 0, 0, true},
+		{"13am", 0, 0, true},
+		{"noon", 0, 0, true},
+		{"10:30:00", 0, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := atClock(day, tt.clock)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("atClock(%q) = %v; want an error", tt.clock, got)
+			}
+			continue
+		}
+		want := time.Date(2026, 5, 13, tt.hour, tt.minute, 0, 0, time.UTC)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("atClock(%q) = %v, %v; want %v", tt.clock, got, err, want)
+		}
+	}
+}
+
+func TestParseDay(t *testing.T) {
+	ny := inZone(t, "America/New_York")
+	now := time.Date(2026, 5, 13, 15, 30, 0, 0, ny)
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Date(2026, 5, 13, 0, 0, 0, 0, ny), false},
+		{"  ", time.Date(2026, 5, 13, 0, 0, 0, 0, ny), false},
+		{"2026-12-31", time.Date(2026, 12, 31, 0, 0, 0, 0, ny), false},
+		{"yesterday", time.Date(2026, 5, 12, 0, 0, 0, 0, ny), false},
+		{"wed", time.Date(2026, 5, 20, 0, 0, 0, 0, ny), false},
+		{"thu", time.Date(2026, 5, 14, 0, 0, 0, 0, ny), false},
+		{"last friday", time.Date(2026, 5, 8, 0, 0, 0, 0, ny), false},
+		{"2026-13-01", time.Time{}, true},
+		{"next week", time.Time{}, true},
+		{"thursday next", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDay(tt.s, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseDay(%q) = %v, %v; want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEventTimes(t *testing.T) {
+	ny := inZone(t, "America/New_York")
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		duration time.Duration
+		from, to time.Time
+		wantErr  string
+	}{
+		{name: "end time", start: "2026-05-20 09:00", end: "2026-05-20 10:30",
+			from: time.Date(2026, 5, 20, 9, 0, 0, 0, ny), to: time.Date(2026, 5, 20, 10, 30, 0, 0, ny)},
+		{name: "end clock on the start's day", start: "2026-05-20 09:00", end: "11am",
+			from: time.Date(2026, 5, 20, 9, 0, 0, 0, ny), to: time.Date(2026, 5, 20, 11, 0, 0, 0, ny)},
+		{name: "duration", start: "2026-05-20 23:30", duration: time.Hour,
+			from: time.Date(2026, 5, 20, 23, 30, 0, 0, ny), to: time.Date(2026, 5, 21, 0, 30, 0, 0, ny)},
+		{name: "range", start: "2026-05-20 09:00–09:30",
+			from: time.Date(2026, 5, 20, 9, 0, 0, 0, ny), to: time.Date(2026, 5, 20, 9, 30, 0, 0, ny)},
+		{name: "range with to", start: "2026-05-20 9am to 10am",
+			from: time.Date(2026, 5, 20, 9, 0, 0, 0, ny), to: time.Date(2026, 5, 20, 10, 0, 0, 0, ny)},
+		{name: "range sharing pm", start: "2026-05-20 2-3pm",
+			from: time.Date(2026, 5, 20, 14, 0, 0, 0, ny), to: time.Date(2026, 5, 20, 15, 0, 0, 0, ny)},
+		{name: "range across noon", start: "2026-05-20 11-1pm",
+			wantErr: "ends"},
+		// The wall clock says two hours, but an hour is skipped.
+		{name: "range across spring forward", start: "2026-03-08 1-3am",
+			from: time.Date(2026, 3, 8, 1, 0, 0, 0, ny), to: time.Date(2026, 3, 8, 3, 0, 0, 0, ny)},
+		{name: "duration across fall back", start: "2026-11-01 00:30", duration: 2 * time.Hour,
+			from: time.Date(2026, 11, 1, 0, 30, 0, 0, ny), to: time.Date(2026, 11, 1, 1, 30, 0, 0, time.FixedZone("EST", -5*3600))},
+		{name: "date alone is not a range", start: "2026-05-20", duration: 24 * time.Hour,
+			from: time.Date(2026, 5, 20, 0, 0, 0, 0, ny), to: time.Date(2026, 5, 21, 0, 0, 0, 0, ny)},
+		{name: "range and end", start: "2026-05-20 9-10am", end: "11am", wantErr: "already gives the end"},
+		{name: "range and duration", start: "2026-05-20 9-10am", duration: time.Hour, wantErr: "already gives the end"},
+		{name: "end and duration", start: "2026-05-20 09:00", end: "10am", duration: time.Hour, wantErr: "not both"},
+		{name: "negative duration", start: "2026-05-20 09:00", duration: -time.Hour, wantErr: "positive"},
+		{name: "no end", start: "2026-05-20 09:00", wantErr: "required"},
+		{name: "bad start", start: "whenever", duration: time.Hour, wantErr: "invalid --start"},
+		{name: "bad end", start: "2026-05-20 09:00", end: "later", wantErr: "invalid --end"},
+		{name: "bad range day", start: "someday 9-10am", wantErr: "invalid --start"},
+		{name: "bad range clock", start: "2026-05-20 9-25", wantErr: "invalid --start"},
+		{name: "end before start", start: "2026-05-20 09:00", end: "8am", wantErr: "before it starts"},
+		{name: "end at start", start: "2026-05-20 09:00", end: "09:00", wantErr: "before it starts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := eventTimes(tt.start, tt.end, tt.duration)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("eventTimes(%q, %q, %v) = %v, %v, %v; want an error containing %q", tt.start, tt.end, tt.duration, from, to, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Fatalf("eventTimes(%q, %q, %v) = %v, %v, %v; want %v, %v", tt.start, tt.end, tt.duration, from, to, err, tt.from, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Package charset repairs message text that reached Graph in the wrong
+// character set. Exchange hands every body over as Unicode, but when a sender
+// labels a message wrongly or not at all, older corporate gateways and some
+// regional mail systems among them, the bytes are read as Windows-1252 and
+// the text arrives as mojibake: "CafÃ©" for "Café", or "‚±‚ñ‚É‚¿‚Í" for
+// "こんにちは". The damage is reversible, because every byte of the original
+// survives as one Windows-1252 character.
+package charset
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// metaCharset finds the charset an HTML body declares for itself, as in
+// <meta charset="iso-8859-2"> or <meta http-equiv="Content-Type"
+// content="text/html; charset=iso-8859-2">.
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_.:-]+)`)
+
+// Repair returns text with legacy-charset mojibake undone, or text unchanged
+// when it does not look mis-decoded. Recovered bytes are read as, in order:
+// UTF-8; the charset an HTML body declares, such as ISO-8859-2; Shift-JIS.
+func Repair(text string) string {
+	if isASCII(text) {
+		return text
+	}
+	raw, ok := windows1252Bytes(text)
+	if !ok {
+		// A character Windows-1252 cannot hold was decoded correctly.
+		return text
+	}
+	if utf8.Valid(raw) {
+		return string(raw)
+	}
+	if m := metaCharset.FindStringSubmatch(text); m != nil {
+		if enc, err := htmlindex.Get(m[1]); err == nil && !sameAs1252(enc) {
+			// Text this charset can encode was most likely decoded with it
+			// already; only reinterpret text it could not have produced, and
+			// only when that turns stray symbols back into letters. A lone ©
+			// under a stale declaration is not enough.
+			if _, err := enc.NewEncoder().String(text); err != nil {
+				if s, ok := decode(enc, raw); ok && !hasControls(s) && symbols(s)+2 <= symbols(text) {
+					return s
+				}
+			}
+		}
+	}
+	if s, ok := decode(japanese.ShiftJIS, raw); ok && isJapanese(s) {
+		return s
+	}
+	return text
+}
+
+// windows1252Bytes returns the bytes text was decoded from, assuming it was
+// read as Windows-1252. Runes U+0080 to U+009F, which a decoder following
+// ISO-8859-1 instead leaves in place, stand for their own byte values.
+func windows1252Bytes(text string) ([]byte, bool) {
+	raw := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0xA0:
+			raw = append(raw, byte(r))
+		default:
+			b, ok := charmap.Windows1252.EncodeRune(r)
+			if !ok {
+				return nil, false
+			}
+			raw = append(raw, b)
+		}
+	}
+	return raw, true
+}
+
+// sameAs1252 reports whether enc is Windows-1252 itself, which is also what
+// HTML means by ISO-8859-1 and US-ASCII; reading the bytes that way again
+// changes nothing.
+func sameAs1252(enc encoding.Encoding) bool {
+	name, _ := htmlindex.Name(enc)
+	return name == "windows-1252"
+}
+
+// decode reads raw as enc, failing if any byte sequence is invalid there.
+func decode(enc encoding.Encoding, raw []byte) (string, bool) {
+	s, err := enc.NewDecoder().Bytes(raw)
+	if err != nil || !utf8.Valid(s) || strings.ContainsRune(string(s), utf8.RuneError) {
+		return "", false
+	}
+	return string(s), true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// hasControls reports whether s holds a C1 control character. The ISO-8859
+// charsets put those at 0x80 to 0x9F, where Windows-1252 has punctuation, so
+// real text read the wrong way round turns up one quickly.
+func hasControls(s string) bool {
+	for _, r := range s {
+		if r >= 0x80 && r <= 0x9F {
+			return true
+		}
+	}
+	return false
+}
+
+// symbols counts the non-ASCII characters of s that are not letters, such as
+// the ³ and ¿ that Polish ł and ż become when ISO-8859-2 is read as
+// Windows-1252.
+func symbols(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= utf8.RuneSelf && !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// isJapanese reports whether every non-ASCII character of s is Japanese
+// script or full-width punctuation, as in a Shift-JIS message. Western text
+// almost never decodes as Shift-JIS to that alone.
+func isJapanese(s string) bool {
+	found := false
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han),
+			r >= 0x3000 && r <= 0x303F, // CJK symbols and punctuation
+			r >= 0xFF00 && r <= 0xFFEF: // half- and full-width forms
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
@@ -0,0 +1,173 @@
+// Package config reads ~/.outlook-assistant.yaml, whose settings are default
+// values for the command-line flags, so a caller need not restate the same
+// flags on every call:
+//
+//	folder: inbox
+//	n: 50
+//	format: md
+//	timezone: Europe/Berlin
+//	signature: "Alex Wilber, Contoso"
+//	profile: work
+//	profiles:
+//	  work:
+//	    mailbox: team@contoso.example
+//	  personal:
+//	    tenant: consumers
+//
+// A flag given on the command line always wins over the file, and a setting
+// fills in only a flag the command takes, does not require, and accepts the
+// value for, so format: md does not reach mail export.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the name of the config file in the home directory.
+const File = ".outlook-assistant.yaml"
+
+// reserved are flags that select what to run or which settings to use, so a
+// config file cannot set them.
+var reserved = map[string]bool{
+	"action": true, "config": true, "describe": true, "group": true, "serve": true,
+}
+
+// DefaultPath is the config file read when --config is not given.
+func DefaultPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, File)
+}
+
+// file is the layout of the config file: flag settings at the top level, and
+// named sets of them under profiles.
+type file struct {
+	Settings map[string]interface{}            `yaml:",inline"`
+	Profile  string                            `yaml:"profile"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// Load reads the settings in path as flag values by flag name. The settings
+// of profile, or else of the profile the file names, replace the top-level
+// ones. A missing file has no settings unless required is set, as when the
+// path was given with --config.
+func Load(path, profile string, required bool) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		if profile != "" {
+			return nil, fmt.Errorf("--profile=%s: there is no config file %s", profile, path)
+		}
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	if err := merge(settings, f.Settings, path); err != nil {
+		return nil, err
+	}
+	if profile == "" {
+		profile = f.Profile
+	}
+	if profile == "" {
+		return settings, nil
+	}
+	chosen, ok := f.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q is not in %s — profiles there: %s", profile, path, names(f.Profiles))
+	}
+	if err := merge(settings, chosen, path+" profile "+profile); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// merge adds values to settings as flag values, replacing any already there.
+func merge(settings map[string]string, values map[string]interface{}, where string) error {
+	for name, v := range values {
+		if reserved[name] || name == "profile" || name == "profiles" {
+			return fmt.Errorf("%s: %q cannot be set in a config file", where, name)
+		}
+		value, err := flagValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", where, name, err)
+		}
+		settings[name] = value
+	}
+	return nil
+}
+
+// flagValue renders a YAML value as a flag value: a list as a comma-separated
+// value, as flags such as --to take.
+func flagValue(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, float64:
+		return fmt.Sprint(x), nil
+	case []interface{}:
+		parts := make([]string, 0, len(x))
+		for _, item := range x {
+			p, err := flagValue(item)
+			if err != nil {
+				return "", fmt.Errorf("a list may only hold strings, numbers, and booleans")
+			}
+			parts = append(parts, p)
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", fmt.Errorf("want a string, number, boolean, or list")
+}
+
+func names(profiles map[string]map[string]interface{}) string {
+	if len(profiles) == 0 {
+		return "none"
+	}
+	list := make([]string, 0, len(profiles))
+	for name := range profiles {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// Apply sets each flag of fs, the command being run, that was not given on
+// the command line to its value in settings, when settable allows that value
+// for the command. A setting that known reports is no flag of any command is
+// an error, so a typo does not go unnoticed; one for a flag fs does not have,
+// or that settable refuses, is left out, so it neither stands in for a flag
+// the command requires nor sets a value the command rejects.
+func Apply(fs *flag.FlagSet, settings map[string]string, path string, known func(name string) bool, settable func(name, value string) bool) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	keys := make([]string, 0, len(settings))
+	for name := range settings {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		if !known(name) {
+			return fmt.Errorf("%s: unknown setting %q — settings are flag names without the dashes, such as folder or n", path, name)
+		}
+		if given[name] || fs.Lookup(name) == nil || !settable(name, settings[name]) {
+			continue
+		}
+		if err := fs.Set(name, settings[name]); err != nil {
+			return fmt.Errorf("%s: invalid value %q for %s: %w", path, settings[name], name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package contacts provides functions for interacting with Outlook contacts
+// via the Microsoft Graph API.
+package contacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/schema"
+)
+
+// ---------- JSON output types ----------
+
+// ContactSummary is the JSON representation of a contact.
+type ContactSummary struct {
+	Index   int      `json:"index,omitempty"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Company string   `json:"company,omitempty"`
+	Emails  []string `json:"emails"`
+	Phones  []string `json:"phones"`
+}
+
+// ---------- ID cache (stored in home directory) ----------
+
+func idCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, mailbox.CacheFile(".outlook-assistant-contacts-cache.json"))
+}
+
+func saveIDCache(ids []string) {
+	data, _ := json.Marshal(ids)
+	_ = os.WriteFile(idCachePath(), data, 0600)
+}
+
+func loadIDCache() []string {
+	data, err := os.ReadFile(idCachePath())
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	_ = json.Unmarshal(data, &ids)
+	return ids
+}
+
+func resolveContactID(ref string) (string, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		ids := loadIDCache()
+		if ids == nil {
+			return "", fmt.Errorf("no cached contact list — run `contacts list` first")
+		}
+		if n < 1 || n > len(ids) {
+			return "", fmt.Errorf("index %d out of range (last list had %d contacts)", n, len(ids))
+		}
+		return ids[n-1], nil
+	}
+	return ref, nil
+}
+
+// ---------- List ----------
+
+// List prints contacts in the default contacts folder, sorted by display name.
+func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count int32, jsonOutput bool) error {
+	result, err := mailbox.Of(client).Contacts().Get(ctx, &users.ItemContactsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemContactsRequestBuilderGetQueryParameters{
+			Select:  []string{"id", "displayName", "companyName", "emailAddresses", "mobilePhone", "businessPhones", "homePhones"},
+			Top:     &count,
+			Orderby: []string{"displayName"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("listing contacts: %w", err)
+	}
+
+	list := result.GetValue()
+	ids := make([]string, 0, len(list))
+	summaries := make([]ContactSummary, 0, len(list))
+	for i, c := range list {
+		ids = append(ids, deref(c.GetId(), ""))
+		s := contactSummary(c)
+		s.Index = i + 1
+		summaries = append(summaries, s)
+	}
+	saveIDCache(ids)
+
+	if jsonOutput {
+		return printJSON(summaries)
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No contacts found.")
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-30s  %-35s  %s\n", "#", "Name", "Email", "Phone")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, s := range summaries {
+		fmt.Printf("%-3d  %-30s  %-35s  %s\n",
+			s.Index, truncate(s.Name, 30), truncate(first(s.Emails), 35), first(s.Phones))
+	}
+	return nil
+}
+
+// ---------- Helpers ----------
+
+// allContacts fetches every contact in the default contacts folder, following
+// @odata.nextLink until the last page.
+func allContacts(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) ([]models.Contactable, error) {
+	top := int32(100)
+	config := &users.ItemContactsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemContactsRequestBuilderGetQueryParameters{Top: &top},
+	}
+	var all []models.Contactable
+	builder := mailbox.Of(client).Contacts()
+	for {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("listing contacts: %w", err)
+		}
+		all = append(all, result.GetValue()...)
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			return all, nil
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+}
+
+func contactSummary(c models.Contactable) ContactSummary {
+	return ContactSummary{
+		ID:      deref(c.GetId(), ""),
+		Name:    displayName(c),
+		Company: deref(c.GetCompanyName(), ""),
+		Emails:  emailsOf(c),
+		Phones:  phonesOf(c),
+	}
+}
+
+// displayName falls back to given and family name, then the first email.
+func displayName(c models.Contactable) string {
+	if name := deref(c.GetDisplayName(), ""); name != "" {
+		return name
+	}
+	name := strings.TrimSpace(deref(c.GetGivenName(), "") + " " + deref(c.GetSurname(), ""))
+	if name == "" {
+		name = first(emailsOf(c))
+	}
+	return name
+}
+
+func emailsOf(c models.Contactable) []string {
+	emails := []string{}
+	for _, e := range c.GetEmailAddresses() {
+		if addr := deref(e.GetAddress(), ""); addr != "" {
+			emails = append(emails, addr)
+		}
+	}
+	return emails
+}
+
+// phonesOf returns mobile, business, then home numbers.
+func phonesOf(c models.Contactable) []string {
+	phones := []string{}
+	if m := deref(c.GetMobilePhone(), ""); m != "" {
+		phones = append(phones, m)
+	}
+	for _, p := range append(c.GetBusinessPhones(), c.GetHomePhones()...) {
+		if p != "" {
+			phones = append(phones, p)
+		}
+	}
+	return phones
+}
+
+func first(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
+
+func printJSON(v interface{}) error {
+	return schema.Encode(os.Stdout, v)
+}
+
+func deref(s *string, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+	return *s
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-1] + "…"
+}
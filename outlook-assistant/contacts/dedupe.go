@@ -0,0 +1,595 @@
+package contacts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Dedupe ----------
+
+// Outlook stores at most three email addresses, three IM addresses, two
+// business phones, and two home phones per contact. Values that do not fit are
+// kept in the merged contact's notes rather than dropped.
+const (
+	maxEmails         = 3
+	maxIMAddresses    = 3
+	maxBusinessPhones = 2
+	maxHomePhones     = 2
+)
+
+// DuplicateCluster is the JSON representation of one group of duplicates.
+type DuplicateCluster struct {
+	Primary    ContactSummary   `json:"primary"`
+	Duplicates []ContactSummary `json:"duplicates"`
+	Reasons    []string         `json:"reasons"`
+	Result     *ContactSummary  `json:"result,omitempty"`
+	Overflow   []string         `json:"overflow,omitempty"`
+	Merged     bool             `json:"merged"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// Dedupe groups contacts that share an email address, a phone number, or a
+// full name, and reports each group. Phone numbers match on their last nine
+// digits so "+44 7700 900123" and "07700 900123" are the same number. Names
+// match regardless of word order, case, and punctuation, or within one typo
+// for longer names; single-word names are never matched on their own.
+//
+// With merge, the most complete contact in each group is kept and given the
+// union of every field; the others are deleted. dryRun shows the merged result
+// without changing anything.
+func Dedupe(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, merge, dryRun, jsonOutput bool) error {
+	all, err := allContacts(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	clusters := findDuplicates(all)
+	out := make([]DuplicateCluster, 0, len(clusters))
+	failed := 0
+	for _, cl := range clusters {
+		primary, others := cl.members[0], cl.members[1:]
+		dc := DuplicateCluster{
+			Primary: contactSummary(primary),
+			Reasons: cl.reasons,
+		}
+		for _, o := range others {
+			dc.Duplicates = append(dc.Duplicates, contactSummary(o))
+		}
+
+		if merge || dryRun {
+			patch, result, overflow := mergeContacts(primary, others)
+			summary := contactSummary(result)
+			dc.Result, dc.Overflow = &summary, overflow
+			if merge && !dryRun {
+				if err := applyMerge(ctx, client, primary, others, patch); err != nil {
+					dc.Error = err.Error()
+					failed++
+				} else {
+					dc.Merged = true
+				}
+			}
+		}
+		out = append(out, dc)
+	}
+
+	if jsonOutput {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		printClusters(out)
+	}
+
+	duplicates := 0
+	for _, dc := range out {
+		duplicates += len(dc.Duplicates)
+	}
+	switch {
+	case merge && !dryRun:
+		slog.Info("Merged duplicate groups", "merged", len(out)-failed, "groups", len(out), "scanned", len(all))
+	case len(out) > 0:
+		msg := "Found duplicates — run with --merge to combine them"
+		if dryRun {
+			msg = "Found duplicates"
+		}
+		slog.Info(msg, "groups", len(out), "duplicates", duplicates, "scanned", len(all))
+	default:
+		slog.Info("No duplicates", "scanned", len(all))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d groups could not be merged", failed)
+	}
+	return nil
+}
+
+func printClusters(clusters []DuplicateCluster) {
+	for i, dc := range clusters {
+		fmt.Printf("\nGroup %d (%s)\n", i+1, strings.Join(dc.Reasons, ", "))
+		fmt.Printf("  keep    %-30s  %s\n", truncate(dc.Primary.Name, 30), strings.Join(append(dc.Primary.Emails, dc.Primary.Phones...), ", "))
+		for _, d := range dc.Duplicates {
+			fmt.Printf("  remove  %-30s  %s\n", truncate(d.Name, 30), strings.Join(append(d.Emails, d.Phones...), ", "))
+		}
+		if dc.Result != nil {
+			fmt.Printf("  result  %-30s  %s\n", truncate(dc.Result.Name, 30), strings.Join(append(dc.Result.Emails, dc.Result.Phones...), ", "))
+		}
+		for _, o := range dc.Overflow {
+			fmt.Printf("  notes   %s\n", o)
+		}
+		switch {
+		case dc.Error != "":
+			fmt.Printf("  FAILED  %s\n", dc.Error)
+		case dc.Merged:
+			fmt.Println("  merged")
+		}
+	}
+}
+
+// ---------- Clustering ----------
+
+type cluster struct {
+	members []models.Contactable // most complete first
+	reasons []string
+}
+
+// findDuplicates clusters contacts with union-find over shared keys. Clusters
+// are returned in the order their first member appears.
+func findDuplicates(all []models.Contactable) []cluster {
+	parent := make([]int, len(all))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	reasons := map[int]map[string]bool{}
+	union := func(a, b int, reason string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			if rb < ra {
+				ra, rb = rb, ra
+			}
+			parent[rb] = ra
+			if reasons[ra] == nil {
+				reasons[ra] = map[string]bool{}
+			}
+			for r := range reasons[rb] {
+				reasons[ra][r] = true
+			}
+			delete(reasons, rb)
+		}
+		if reasons[ra] == nil {
+			reasons[ra] = map[string]bool{}
+		}
+		reasons[ra][reason] = true
+	}
+
+	seen := map[string]int{}
+	names := make([]string, len(all))
+	for i, c := range all {
+		for _, e := range emailsOf(c) {
+			key := "email:" + strings.ToLower(strings.TrimSpace(e))
+			if j, ok := seen[key]; ok {
+				union(j, i, "same email")
+			} else {
+				seen[key] = i
+			}
+		}
+		for _, p := range phonesOf(c) {
+			digits := phoneKey(p)
+			if digits == "" {
+				continue
+			}
+			key := "phone:" + digits
+			if j, ok := seen[key]; ok {
+				union(j, i, "same phone")
+			} else {
+				seen[key] = i
+			}
+		}
+		names[i] = nameKey(displayName(c))
+		if strings.Contains(names[i], " ") {
+			key := "name:" + names[i]
+			if j, ok := seen[key]; ok {
+				union(j, i, "same name")
+			} else {
+				seen[key] = i
+			}
+		}
+	}
+
+	// Near-identical names: one edit apart, for names long enough that a
+	// single edit is more likely a typo than a different person.
+	for i := range all {
+		if len(names[i]) < 10 || !strings.Contains(names[i], " ") {
+			continue
+		}
+		for j := i + 1; j < len(all); j++ {
+			if d := len(names[i]) - len(names[j]); d < -1 || d > 1 || names[i] == names[j] {
+				continue
+			}
+			if editDistance(names[i], names[j]) == 1 {
+				union(i, j, "similar name")
+			}
+		}
+	}
+
+	groups := map[int][]models.Contactable{}
+	var roots []int
+	for i, c := range all {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], c)
+	}
+
+	var clusters []cluster
+	for _, r := range roots {
+		members := groups[r]
+		if len(members) < 2 {
+			continue
+		}
+		sort.SliceStable(members, func(a, b int) bool {
+			return completeness(members[a]) > completeness(members[b])
+		})
+		var rs []string
+		for reason := range reasons[r] {
+			rs = append(rs, reason)
+		}
+		sort.Strings(rs)
+		clusters = append(clusters, cluster{members: members, reasons: rs})
+	}
+	return clusters
+}
+
+// phoneKey reduces a phone number to its last nine digits, ignoring country
+// codes and trunk prefixes. Numbers shorter than seven digits are ignored.
+func phoneKey(p string) string {
+	var digits []rune
+	for _, r := range p {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) < 7 {
+		return ""
+	}
+	if len(digits) > 9 {
+		digits = digits[len(digits)-9:]
+	}
+	return string(digits)
+}
+
+// nameKey lowercases a name, drops punctuation, and sorts its words so that
+// "Smith, John" and "john smith" compare equal.
+func nameKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// completeness counts a contact's populated fields to pick which one to keep.
+func completeness(c models.Contactable) int {
+	n := len(emailsOf(c)) + len(phonesOf(c)) + len(c.GetImAddresses()) + len(c.GetCategories())
+	for _, f := range scalarFields {
+		if deref(f.get(c), "") != "" {
+			n++
+		}
+	}
+	for _, a := range []models.PhysicalAddressable{c.GetHomeAddress(), c.GetBusinessAddress(), c.GetOtherAddress()} {
+		if !emptyAddress(a) {
+			n++
+		}
+	}
+	if c.GetBirthday() != nil {
+		n++
+	}
+	return n
+}
+
+// ---------- Merging ----------
+
+type scalarField struct {
+	get func(models.Contactable) *string
+	set func(models.Contactable, *string)
+}
+
+// scalarFields are single-valued contact fields: the primary's value is kept
+// and an empty one is filled from the first duplicate that has it.
+var scalarFields = []scalarField{
+	{models.Contactable.GetDisplayName, models.Contactable.SetDisplayName},
+	{models.Contactable.GetGivenName, models.Contactable.SetGivenName},
+	{models.Contactable.GetMiddleName, models.Contactable.SetMiddleName},
+	{models.Contactable.GetSurname, models.Contactable.SetSurname},
+	{models.Contactable.GetNickName, models.Contactable.SetNickName},
+	{models.Contactable.GetTitle, models.Contactable.SetTitle},
+	{models.Contactable.GetGeneration, models.Contactable.SetGeneration},
+	{models.Contactable.GetInitials, models.Contactable.SetInitials},
+	{models.Contactable.GetFileAs, models.Contactable.SetFileAs},
+	{models.Contactable.GetCompanyName, models.Contactable.SetCompanyName},
+	{models.Contactable.GetDepartment, models.Contactable.SetDepartment},
+	{models.Contactable.GetJobTitle, models.Contactable.SetJobTitle},
+	{models.Contactable.GetOfficeLocation, models.Contactable.SetOfficeLocation},
+	{models.Contactable.GetProfession, models.Contactable.SetProfession},
+	{models.Contactable.GetManager, models.Contactable.SetManager},
+	{models.Contactable.GetAssistantName, models.Contactable.SetAssistantName},
+	{models.Contactable.GetSpouseName, models.Contactable.SetSpouseName},
+	{models.Contactable.GetBusinessHomePage, models.Contactable.SetBusinessHomePage},
+	{models.Contactable.GetYomiGivenName, models.Contactable.SetYomiGivenName},
+	{models.Contactable.GetYomiSurname, models.Contactable.SetYomiSurname},
+	{models.Contactable.GetYomiCompanyName, models.Contactable.SetYomiCompanyName},
+}
+
+// mergeContacts combines others into primary. patch holds only the fields that
+// change; result is the primary as it will look after the merge. overflow
+// lists values that did not fit Outlook's per-field limits and were appended
+// to the notes instead.
+func mergeContacts(primary models.Contactable, others []models.Contactable) (patch, result models.Contactable, overflow []string) {
+	patch = models.NewContact()
+	result = models.NewContact()
+	result.SetId(primary.GetId())
+	members := append([]models.Contactable{primary}, others...)
+
+	for _, f := range scalarFields {
+		value := f.get(primary)
+		if deref(value, "") == "" {
+			for _, o := range others {
+				if v := f.get(o); deref(v, "") != "" {
+					value = v
+					f.set(patch, v)
+					break
+				}
+			}
+		}
+		f.set(result, value)
+	}
+
+	if primary.GetBirthday() != nil {
+		result.SetBirthday(primary.GetBirthday())
+	} else {
+		for _, o := range others {
+			if b := o.GetBirthday(); b != nil {
+				patch.SetBirthday(b)
+				result.SetBirthday(b)
+				break
+			}
+		}
+	}
+
+	addresses := []struct {
+		get func(models.Contactable) models.PhysicalAddressable
+		set func(models.Contactable, models.PhysicalAddressable)
+	}{
+		{models.Contactable.GetHomeAddress, models.Contactable.SetHomeAddress},
+		{models.Contactable.GetBusinessAddress, models.Contactable.SetBusinessAddress},
+		{models.Contactable.GetOtherAddress, models.Contactable.SetOtherAddress},
+	}
+	for _, a := range addresses {
+		value := a.get(primary)
+		if emptyAddress(value) {
+			for _, o := range others {
+				if v := a.get(o); !emptyAddress(v) {
+					value = v
+					a.set(patch, v)
+					break
+				}
+			}
+		}
+		a.set(result, value)
+	}
+
+	// Emails, deduplicated case-insensitively.
+	var emails []models.EmailAddressable
+	seenEmail := map[string]bool{}
+	for _, m := range members {
+		for _, e := range m.GetEmailAddresses() {
+			key := strings.ToLower(strings.TrimSpace(deref(e.GetAddress(), "")))
+			if key == "" || seenEmail[key] {
+				continue
+			}
+			seenEmail[key] = true
+			if len(emails) == maxEmails {
+				overflow = append(overflow, "email: "+deref(e.GetAddress(), ""))
+				continue
+			}
+			emails = append(emails, e)
+		}
+	}
+	result.SetEmailAddresses(emails)
+	if len(emails) != len(primary.GetEmailAddresses()) {
+		patch.SetEmailAddresses(emails)
+	}
+
+	// Phones, deduplicated by phoneKey. The primary's mobile is kept; other
+	// mobiles go to home phones, then business phones, then the notes.
+	seenPhone := map[string]bool{}
+	isNew := func(p string) bool {
+		key := phoneKey(p)
+		if key == "" {
+			key = p
+		}
+		if p == "" || seenPhone[key] {
+			return false
+		}
+		seenPhone[key] = true
+		return true
+	}
+	mobile := deref(primary.GetMobilePhone(), "")
+	isNew(mobile)
+	var business, home, extra []string
+	for _, m := range members {
+		for _, p := range m.GetBusinessPhones() {
+			if isNew(p) {
+				if len(business) < maxBusinessPhones {
+					business = append(business, p)
+				} else {
+					extra = append(extra, p)
+				}
+			}
+		}
+		for _, p := range m.GetHomePhones() {
+			if isNew(p) {
+				if len(home) < maxHomePhones {
+					home = append(home, p)
+				} else {
+					extra = append(extra, p)
+				}
+			}
+		}
+	}
+	for _, o := range others {
+		if p := deref(o.GetMobilePhone(), ""); isNew(p) {
+			if mobile == "" {
+				mobile = p
+				patch.SetMobilePhone(&mobile)
+			} else {
+				extra = append(extra, p)
+			}
+		}
+	}
+	for _, p := range extra {
+		switch {
+		case len(home) < maxHomePhones:
+			home = append(home, p)
+		case len(business) < maxBusinessPhones:
+			business = append(business, p)
+		default:
+			overflow = append(overflow, "phone: "+p)
+		}
+	}
+	if mobile != "" {
+		result.SetMobilePhone(&mobile)
+	}
+	result.SetBusinessPhones(business)
+	result.SetHomePhones(home)
+	if len(business) != len(primary.GetBusinessPhones()) {
+		patch.SetBusinessPhones(business)
+	}
+	if len(home) != len(primary.GetHomePhones()) {
+		patch.SetHomePhones(home)
+	}
+
+	ims, imOverflow := unionStrings(members, models.Contactable.GetImAddresses, maxIMAddresses)
+	for _, im := range imOverflow {
+		overflow = append(overflow, "IM: "+im)
+	}
+	result.SetImAddresses(ims)
+	if len(ims) != len(primary.GetImAddresses()) {
+		patch.SetImAddresses(ims)
+	}
+	categories, _ := unionStrings(members, models.Contactable.GetCategories, 0)
+	result.SetCategories(categories)
+	if len(categories) != len(primary.GetCategories()) {
+		patch.SetCategories(categories)
+	}
+	children, _ := unionStrings(members, models.Contactable.GetChildren, 0)
+	result.SetChildren(children)
+	if len(children) != len(primary.GetChildren()) {
+		patch.SetChildren(children)
+	}
+
+	// Notes: every distinct note, followed by anything that did not fit.
+	var notes []string
+	seenNote := map[string]bool{}
+	for _, m := range members {
+		if n := strings.TrimSpace(deref(m.GetPersonalNotes(), "")); n != "" && !seenNote[n] {
+			seenNote[n] = true
+			notes = append(notes, n)
+		}
+	}
+	if len(overflow) > 0 {
+		notes = append(notes, "Merged from duplicates:\n"+strings.Join(overflow, "\n"))
+	}
+	merged := strings.Join(notes, "\n\n")
+	result.SetPersonalNotes(&merged)
+	if merged != strings.TrimSpace(deref(primary.GetPersonalNotes(), "")) {
+		patch.SetPersonalNotes(&merged)
+	}
+	return patch, result, overflow
+}
+
+// applyMerge updates the primary contact, then deletes the duplicates. Nothing
+// is deleted if the update fails.
+func applyMerge(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, primary models.Contactable, others []models.Contactable, patch models.Contactable) error {
+	_, err := mailbox.Of(client).Contacts().ByContactId(deref(primary.GetId(), "")).Patch(ctx, patch, nil)
+	if err != nil {
+		return fmt.Errorf("updating %s: %w", displayName(primary), err)
+	}
+	for _, o := range others {
+		if err := mailbox.Of(client).Contacts().ByContactId(deref(o.GetId(), "")).Delete(ctx, nil); err != nil {
+			return fmt.Errorf("deleting duplicate %s: %w", displayName(o), err)
+		}
+	}
+	return nil
+}
+
+// unionStrings collects the distinct values of a list field across members,
+// case-insensitively. With limit > 0, values beyond it are returned separately.
+func unionStrings(members []models.Contactable, get func(models.Contactable) []string, limit int) (kept, rest []string) {
+	seen := map[string]bool{}
+	kept = []string{}
+	for _, m := range members {
+		for _, v := range get(m) {
+			key := strings.ToLower(strings.TrimSpace(v))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if limit > 0 && len(kept) == limit {
+				rest = append(rest, v)
+			} else {
+				kept = append(kept, v)
+			}
+		}
+	}
+	return kept, rest
+}
+
+func emptyAddress(a models.PhysicalAddressable) bool {
+	if a == nil {
+		return true
+	}
+	for _, s := range []*string{a.GetStreet(), a.GetCity(), a.GetState(), a.GetPostalCode(), a.GetCountryOrRegion()} {
+		if deref(s, "") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,249 @@
+package contacts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Search ----------
+
+// Search prints the contacts whose name, company, email address, or phone
+// number contains query, ignoring case, and caches their indexes for --ref
+// like List. Graph cannot search inside the email address collection, so
+// every contact is fetched and matched locally.
+func Search(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, query string, count int, jsonOutput bool) error {
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("--query is required for contacts search")
+	}
+	all, err := allContacts(ctx, client)
+	if err != nil {
+		return err
+	}
+	needle := strings.ToLower(strings.TrimSpace(query))
+
+	var matches []ContactSummary
+	for _, c := range all {
+		s := contactSummary(c)
+		fields := append([]string{s.Name, s.Company}, s.Emails...)
+		fields = append(fields, s.Phones...)
+		for _, f := range fields {
+			if strings.Contains(strings.ToLower(f), needle) {
+				matches = append(matches, s)
+				break
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i].Name) < strings.ToLower(matches[j].Name)
+	})
+	if count > 0 && len(matches) > count {
+		matches = matches[:count]
+	}
+
+	ids := make([]string, 0, len(matches))
+	for i := range matches {
+		matches[i].Index = i + 1
+		ids = append(ids, matches[i].ID)
+	}
+	saveIDCache(ids)
+
+	if jsonOutput {
+		return printJSON(nonNil(matches))
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No contacts match %q.\n", query)
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-30s  %-35s  %s\n", "#", "Name", "Email", "Phone")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, s := range matches {
+		fmt.Printf("%-3d  %-30s  %-35s  %s\n",
+			s.Index, truncate(s.Name, 30), truncate(first(s.Emails), 35), first(s.Phones))
+	}
+	return nil
+}
+
+// FindByName returns the contacts a recipient name could mean: those whose
+// display name equals name, ignoring case, or failing that, those whose
+// display name or any word of it starts with name. It lets a contact that is
+// not in the directory, such as someone outside the organization, be
+// addressed by name.
+func FindByName(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name string) ([]ContactSummary, error) {
+	all, err := allContacts(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	var exact, prefix []ContactSummary
+	for _, c := range all {
+		s := contactSummary(c)
+		if len(s.Emails) == 0 {
+			continue
+		}
+		lower := strings.ToLower(s.Name)
+		switch {
+		case lower == name:
+			exact = append(exact, s)
+		case strings.HasPrefix(lower, name):
+			prefix = append(prefix, s)
+		default:
+			for _, word := range strings.Fields(lower) {
+				if strings.HasPrefix(word, name) {
+					prefix = append(prefix, s)
+					break
+				}
+			}
+		}
+	}
+	if len(exact) > 0 {
+		return exact, nil
+	}
+	return prefix, nil
+}
+
+// ---------- Create / update / delete ----------
+
+// ContactSpec holds the fields given for contacts create or update. Empty
+// fields are left out; Emails is comma-separated and replaces the contact's
+// addresses.
+type ContactSpec struct {
+	Name    string
+	Emails  string
+	Phone   string
+	Company string
+}
+
+func (s ContactSpec) empty() bool {
+	return s.Name == "" && s.Emails == "" && s.Phone == "" && s.Company == ""
+}
+
+// Create adds a contact to the default contacts folder and caches it as index
+// 1, so --ref=1 acts on it straight away.
+func Create(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, spec ContactSpec, jsonOutput bool) error {
+	if spec.Name == "" && spec.Emails == "" {
+		return fmt.Errorf("--name or --email is required for contacts create")
+	}
+	contact, err := contactFromSpec(spec)
+	if err != nil {
+		return err
+	}
+	created, err := mailbox.Of(client).Contacts().Post(ctx, contact, nil)
+	if err != nil {
+		return fmt.Errorf("creating contact: %w", err)
+	}
+	saveIDCache([]string{deref(created.GetId(), "")})
+
+	s := contactSummary(created)
+	s.Index = 1
+	if jsonOutput {
+		return printJSON(s)
+	}
+	slog.Info("Contact created", "name", s.Name, "id", s.ID)
+	return nil
+}
+
+// Update changes the fields given in spec on the contact identified by ref
+// (list index or Graph ID).
+func Update(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, spec ContactSpec, jsonOutput bool) error {
+	id, err := resolveContactID(ref)
+	if err != nil {
+		return err
+	}
+	if spec.empty() {
+		return fmt.Errorf("nothing to change — give --name, --email, --phone, or --company")
+	}
+	patch, err := contactFromSpec(spec)
+	if err != nil {
+		return err
+	}
+	updated, err := mailbox.Of(client).Contacts().ByContactId(id).Patch(ctx, patch, nil)
+	if err != nil {
+		return fmt.Errorf("updating contact: %w", err)
+	}
+
+	s := contactSummary(updated)
+	if jsonOutput {
+		return printJSON(s)
+	}
+	slog.Info("Contact updated", "name", s.Name)
+	return nil
+}
+
+// Delete moves the contact identified by ref (list index or Graph ID) to
+// Deleted Items.
+func Delete(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
+	id, err := resolveContactID(ref)
+	if err != nil {
+		return err
+	}
+	contact, err := mailbox.Of(client).Contacts().ByContactId(id).Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("reading contact: %w", err)
+	}
+	if err := mailbox.Of(client).Contacts().ByContactId(id).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("deleting contact: %w", err)
+	}
+	slog.Info("Contact deleted", "name", displayName(contact))
+	return nil
+}
+
+// contactFromSpec builds a contact holding only the fields given in spec. The
+// name is split into given name and surname at the last space, as Outlook
+// does when a contact is typed in as one name.
+func contactFromSpec(spec ContactSpec) (models.Contactable, error) {
+	c := models.NewContact()
+	if name := strings.TrimSpace(spec.Name); name != "" {
+		c.SetDisplayName(&name)
+		given, surname := name, ""
+		if i := strings.LastIndex(name, " "); i > 0 {
+			given, surname = name[:i], name[i+1:]
+		}
+		c.SetGivenName(&given)
+		c.SetSurname(&surname)
+	}
+	if spec.Emails != "" {
+		var emails []models.EmailAddressable
+		for _, addr := range strings.Split(spec.Emails, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr == "" {
+				continue
+			}
+			if !strings.Contains(addr, "@") {
+				return nil, fmt.Errorf("invalid --email %q", addr)
+			}
+			e := models.NewEmailAddress()
+			e.SetAddress(&addr)
+			emails = append(emails, e)
+		}
+		// Outlook keeps at most three addresses per contact.
+		if len(emails) > 3 {
+			return nil, fmt.Errorf("a contact holds at most 3 email addresses, got %d", len(emails))
+		}
+		c.SetEmailAddresses(emails)
+	}
+	if spec.Phone != "" {
+		c.SetMobilePhone(&spec.Phone)
+	}
+	if spec.Company != "" {
+		c.SetCompanyName(&spec.Company)
+	}
+	return c, nil
+}
+
+// nonNil returns s, or an empty slice for nil so JSON shows [] not null.
+func nonNil(s []ContactSummary) []ContactSummary {
+	if s == nil {
+		return []ContactSummary{}
+	}
+	return s
+}
@@ -0,0 +1,133 @@
+package contacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Photo ----------
+
+// maxPhotoSize is the largest photo Exchange accepts for a contact.
+const maxPhotoSize = 4 * 1024 * 1024
+
+// PhotoInfo is the JSON representation of a contact's photo.
+type PhotoInfo struct {
+	ContactID   string `json:"contactId"`
+	Name        string `json:"name"`
+	HasPhoto    bool   `json:"hasPhoto"`
+	Width       int32  `json:"width,omitempty"`
+	Height      int32  `json:"height,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Bytes       int    `json:"bytes,omitempty"`
+	File        string `json:"file,omitempty"`
+}
+
+// Photo downloads, uploads, or describes the photo of the contact identified
+// by ref (list index or Graph ID). With out, the photo is saved to that file;
+// with set, the JPEG or PNG at that path replaces it. With neither, only its
+// dimensions are shown.
+func Photo(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, out, set string, jsonOutput bool) error {
+	if out != "" && set != "" {
+		return fmt.Errorf("use either --out or --set, not both")
+	}
+	id, err := resolveContactID(ref)
+	if err != nil {
+		return err
+	}
+	contact := mailbox.Of(client).Contacts().ByContactId(id)
+	c, err := contact.Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("fetching contact: %w", err)
+	}
+	info := PhotoInfo{ContactID: id, Name: displayName(c)}
+
+	if set != "" {
+		data, err := os.ReadFile(set)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", set, err)
+		}
+		if len(data) > maxPhotoSize {
+			return fmt.Errorf("%s is %d bytes; contact photos are limited to 4 MB", set, len(data))
+		}
+		contentType := http.DetectContentType(data)
+		if contentType != "image/jpeg" && contentType != "image/png" {
+			return fmt.Errorf("%s is %s; contact photos must be JPEG or PNG", set, contentType)
+		}
+
+		// The SDK uploads photos as application/octet-stream, which Exchange
+		// rejects, so set the real image type on its request.
+		req, err := contact.Photo().Content().ToPutRequestInformation(ctx, data, nil)
+		if err != nil {
+			return err
+		}
+		req.SetStreamContentAndContentType(data, contentType)
+		err = client.GetAdapter().SendNoContent(ctx, req, abstractions.ErrorMappings{
+			"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue,
+		})
+		if err != nil {
+			return fmt.Errorf("uploading photo: %w", err)
+		}
+		info.HasPhoto, info.ContentType, info.Bytes, info.File = true, contentType, len(data), set
+		if jsonOutput {
+			return printJSON(info)
+		}
+		slog.Info("Photo set", "contact", info.Name, "file", set)
+		return nil
+	}
+
+	meta, err := contact.Photo().Get(ctx, nil)
+	if err != nil {
+		if isNotFound(err) {
+			if jsonOutput {
+				return printJSON(info)
+			}
+			fmt.Printf("%s has no photo.\n", info.Name)
+			return nil
+		}
+		return fmt.Errorf("fetching photo: %w", err)
+	}
+	info.HasPhoto = true
+	if w := meta.GetWidth(); w != nil {
+		info.Width = *w
+	}
+	if h := meta.GetHeight(); h != nil {
+		info.Height = *h
+	}
+
+	if out != "" {
+		data, err := contact.Photo().Content().Get(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("downloading photo: %w", err)
+		}
+		if err := os.WriteFile(out, data, 0600); err != nil {
+			return fmt.Errorf("writing %s: %w", out, err)
+		}
+		info.ContentType, info.Bytes, info.File = http.DetectContentType(data), len(data), out
+		if !jsonOutput {
+			slog.Info("Photo saved", "contact", info.Name, "path", out)
+			return nil
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(info)
+	}
+	fmt.Printf("%s has a %dx%d photo.\n", info.Name, info.Width, info.Height)
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var odataErr *odataerrors.ODataError
+	return errors.As(err, &odataErr) && odataErr.ResponseStatusCode == http.StatusNotFound
+}
@@ -0,0 +1,548 @@
+package contacts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- vCard export ----------
+
+// Export writes every contact as a vCard to out, or to stdout when out is
+// empty. version is "3.0" (the default, read by nearly every phone and mail
+// client) or "4.0".
+func Export(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, out, version string) error {
+	switch version {
+	case "":
+		version = "3.0"
+	case "3.0", "4.0":
+	default:
+		return fmt.Errorf("unsupported vCard version %q — use 3.0 or 4.0", version)
+	}
+
+	all, err := allContacts(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	for _, c := range all {
+		writeVCard(bw, c, version)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing vCards: %w", err)
+	}
+
+	if out != "" {
+		slog.Info("Exported contacts", "count", len(all), "path", out)
+	} else {
+		slog.Info("Exported contacts", "count", len(all))
+	}
+	return nil
+}
+
+func writeVCard(w *bufio.Writer, c models.Contactable, version string) {
+	v4 := version == "4.0"
+	typ := func(v3, v4type string) string {
+		if v4 {
+			return v4type
+		}
+		return v3
+	}
+	prop := func(name, value string) {
+		writeFolded(w, name+":"+value)
+	}
+
+	prop("BEGIN", "VCARD")
+	prop("VERSION", version)
+	if id := deref(c.GetId(), ""); id != "" {
+		prop("UID", escapeText(id))
+	}
+	prop("FN", escapeText(displayName(c)))
+	prop("N", structured(
+		deref(c.GetSurname(), ""), deref(c.GetGivenName(), ""), deref(c.GetMiddleName(), ""),
+		deref(c.GetTitle(), ""), deref(c.GetGeneration(), "")))
+	if nick := deref(c.GetNickName(), ""); nick != "" {
+		prop("NICKNAME", escapeText(nick))
+	}
+	if company, dept := deref(c.GetCompanyName(), ""), deref(c.GetDepartment(), ""); company != "" || dept != "" {
+		prop("ORG", structured(company, dept))
+	}
+	if title := deref(c.GetJobTitle(), ""); title != "" {
+		prop("TITLE", escapeText(title))
+	}
+	if role := deref(c.GetProfession(), ""); role != "" {
+		prop("ROLE", escapeText(role))
+	}
+	for _, e := range emailsOf(c) {
+		prop("EMAIL"+typ(";TYPE=INTERNET", ""), escapeText(e))
+	}
+	if m := deref(c.GetMobilePhone(), ""); m != "" {
+		prop("TEL;TYPE="+typ("CELL", "cell"), escapeText(m))
+	}
+	for _, p := range c.GetBusinessPhones() {
+		prop("TEL;TYPE="+typ("WORK,VOICE", "work,voice"), escapeText(p))
+	}
+	for _, p := range c.GetHomePhones() {
+		prop("TEL;TYPE="+typ("HOME,VOICE", "home,voice"), escapeText(p))
+	}
+	for _, a := range []struct {
+		params string
+		addr   models.PhysicalAddressable
+	}{
+		{";TYPE=" + typ("HOME", "home"), c.GetHomeAddress()},
+		{";TYPE=" + typ("WORK", "work"), c.GetBusinessAddress()},
+		{"", c.GetOtherAddress()},
+	} {
+		if !emptyAddress(a.addr) {
+			prop("ADR"+a.params, structured("", "",
+				deref(a.addr.GetStreet(), ""), deref(a.addr.GetCity(), ""), deref(a.addr.GetState(), ""),
+				deref(a.addr.GetPostalCode(), ""), deref(a.addr.GetCountryOrRegion(), "")))
+		}
+	}
+	if b := c.GetBirthday(); b != nil {
+		prop("BDAY", b.UTC().Format(typ("2006-01-02", "20060102")))
+	}
+	if url := deref(c.GetBusinessHomePage(), ""); url != "" {
+		prop("URL", url)
+	}
+	for _, im := range c.GetImAddresses() {
+		prop("IMPP", escapeText(im))
+	}
+	if cats := c.GetCategories(); len(cats) > 0 {
+		escaped := make([]string, len(cats))
+		for i, cat := range cats {
+			escaped[i] = escapeText(cat)
+		}
+		prop("CATEGORIES", strings.Join(escaped, ","))
+	}
+	if note := deref(c.GetPersonalNotes(), ""); note != "" {
+		prop("NOTE", escapeText(note))
+	}
+	prop("END", "VCARD")
+}
+
+// writeFolded writes a content line, folding it at 75 octets without
+// splitting a UTF-8 sequence, as RFC 6350 section 3.2 requires.
+func writeFolded(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // the leading space counts
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func structured(parts ...string) string {
+	for i, p := range parts {
+		parts[i] = escapeText(p)
+	}
+	return strings.Join(parts, ";")
+}
+
+// ---------- vCard import ----------
+
+// vcardProperty is one content line: NAME;PARAM=VALUE:value. Parameter names
+// are upper-cased; TYPE values from every form of the parameter are collected
+// in types, lower-cased.
+type vcardProperty struct {
+	name  string
+	types map[string]bool
+	value string
+}
+
+// ImportResult is the JSON representation of one imported vCard.
+type ImportResult struct {
+	Card  int    `json:"card"`
+	Name  string `json:"name"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Import creates a contact from each vCard (3.0 or 4.0) in file. A card that
+// fails is reported and skipped. Values Outlook has no room for (a fourth
+// email address, a fax number, a birthday without a year) are kept in the
+// contact's notes.
+// Run `contacts dedupe` afterwards to fold imported cards into existing contacts.
+func Import(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, file string, jsonOutput bool) error {
+	if file == "" {
+		return fmt.Errorf("--file is required")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", file, err)
+	}
+	defer f.Close()
+	cards, err := parseVCards(f)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+	if len(cards) == 0 {
+		return fmt.Errorf("no vCards found in %s", file)
+	}
+
+	results := make([]ImportResult, 0, len(cards))
+	failed := 0
+	for i, card := range cards {
+		contact := contactFromVCard(card)
+		res := ImportResult{Card: i + 1, Name: displayName(contact)}
+		created, err := mailbox.Of(client).Contacts().Post(ctx, contact, nil)
+		if err != nil {
+			res.Error = fmt.Sprintf("creating contact: %v", err)
+			failed++
+		} else {
+			res.ID = deref(created.GetId(), "")
+		}
+		results = append(results, res)
+	}
+
+	if jsonOutput {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("card %-4d  FAILED    %-30s  %s\n", r.Card, truncate(r.Name, 30), r.Error)
+			} else {
+				fmt.Printf("card %-4d  imported  %s\n", r.Card, truncate(r.Name, 30))
+			}
+		}
+	}
+
+	slog.Info("Imported contacts", "imported", len(cards)-failed, "total", len(cards))
+	if failed > 0 {
+		return fmt.Errorf("%d vCards could not be imported", failed)
+	}
+	return nil
+}
+
+// parseVCards splits r into cards, each a list of properties between
+// BEGIN:VCARD and END:VCARD.
+func parseVCards(r io.Reader) ([][]vcardProperty, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	// Unfold: a line starting with a space or tab continues the previous one.
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var cards [][]vcardProperty
+	var current []vcardProperty
+	inCard := false
+	for n, line := range lines {
+		p, ok := parseProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: not a vCard property: %q", n+1, truncate(line, 40))
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			inCard, current = true, nil
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if inCard {
+				cards = append(cards, current)
+			}
+			inCard = false
+		case inCard:
+			current = append(current, p)
+		}
+	}
+	return cards, nil
+}
+
+// parseProperty splits [group.]NAME[;params]:value, honouring quoted
+// parameter values that may contain ':' or ';'.
+func parseProperty(line string) (vcardProperty, bool) {
+	colon, quoted := -1, false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return vcardProperty{}, false
+	}
+	head := splitUnquoted(line[:colon], ';')
+	name := strings.ToUpper(head[0])
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	p := vcardProperty{name: name, types: map[string]bool{}, value: line[colon+1:]}
+	for _, param := range head[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			// vCard 2.1 style bare type: TEL;CELL:...
+			p.types[strings.ToLower(key)] = true
+			continue
+		}
+		if strings.EqualFold(key, "TYPE") {
+			for _, t := range strings.Split(strings.Trim(value, `"`), ",") {
+				p.types[strings.ToLower(strings.TrimSpace(t))] = true
+			}
+		}
+	}
+	return p, true
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitEscaped splits a vCard value on sep where it is not backslash-escaped,
+// then unescapes each part.
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+		case s[i] == sep:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+func unescapeText(s string) string {
+	return strings.Join(splitEscaped(s, 0), "")
+}
+
+// component returns the i'th part of a structured value, or "".
+func component(parts []string, i int) string {
+	if i < len(parts) {
+		return strings.TrimSpace(parts[i])
+	}
+	return ""
+}
+
+func contactFromVCard(props []vcardProperty) models.Contactable {
+	c := models.NewContact()
+	set := func(setter func(*string), value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			setter(&value)
+		}
+	}
+
+	var emails []models.EmailAddressable
+	var business, home, ims, categories, overflow []string
+	var notes []string
+	fn := ""
+	for _, p := range props {
+		switch p.name {
+		case "FN":
+			fn = unescapeText(p.value)
+			set(c.SetDisplayName, fn)
+		case "N":
+			n := splitEscaped(p.value, ';')
+			set(c.SetSurname, component(n, 0))
+			set(c.SetGivenName, component(n, 1))
+			set(c.SetMiddleName, component(n, 2))
+			set(c.SetTitle, component(n, 3))
+			set(c.SetGeneration, component(n, 4))
+		case "NICKNAME":
+			set(c.SetNickName, component(splitEscaped(p.value, ','), 0))
+		case "ORG":
+			org := splitEscaped(p.value, ';')
+			set(c.SetCompanyName, component(org, 0))
+			set(c.SetDepartment, component(org, 1))
+		case "TITLE":
+			set(c.SetJobTitle, unescapeText(p.value))
+		case "ROLE":
+			set(c.SetProfession, unescapeText(p.value))
+		case "EMAIL":
+			addr := strings.TrimSpace(unescapeText(p.value))
+			if len(addr) >= 7 && strings.EqualFold(addr[:7], "mailto:") {
+				addr = addr[7:]
+			}
+			if addr == "" {
+				continue
+			}
+			if len(emails) == maxEmails {
+				overflow = append(overflow, "email: "+addr)
+				continue
+			}
+			e := models.NewEmailAddress()
+			e.SetAddress(&addr)
+			emails = append(emails, e)
+		case "TEL":
+			number := strings.TrimPrefix(strings.TrimSpace(unescapeText(p.value)), "tel:")
+			switch {
+			case number == "" || p.types["fax"] || p.types["pager"]:
+				if number != "" {
+					overflow = append(overflow, "phone: "+number)
+				}
+			case (p.types["cell"] || p.types["mobile"]) && c.GetMobilePhone() == nil:
+				c.SetMobilePhone(&number)
+			case p.types["work"] && len(business) < maxBusinessPhones:
+				business = append(business, number)
+			case len(home) < maxHomePhones:
+				home = append(home, number)
+			case len(business) < maxBusinessPhones:
+				business = append(business, number)
+			default:
+				overflow = append(overflow, "phone: "+number)
+			}
+		case "ADR":
+			a := splitEscaped(p.value, ';')
+			addr := models.NewPhysicalAddress()
+			var street []string
+			for _, part := range []string{component(a, 0), component(a, 1), component(a, 2)} {
+				if part != "" {
+					street = append(street, part)
+				}
+			}
+			setAddr := func(setter func(*string), value string) {
+				if value != "" {
+					setter(&value)
+				}
+			}
+			setAddr(addr.SetStreet, strings.Join(street, "\n"))
+			setAddr(addr.SetCity, component(a, 3))
+			setAddr(addr.SetState, component(a, 4))
+			setAddr(addr.SetPostalCode, component(a, 5))
+			setAddr(addr.SetCountryOrRegion, component(a, 6))
+			switch {
+			case p.types["home"] && c.GetHomeAddress() == nil:
+				c.SetHomeAddress(addr)
+			case p.types["work"] && c.GetBusinessAddress() == nil:
+				c.SetBusinessAddress(addr)
+			case c.GetOtherAddress() == nil:
+				c.SetOtherAddress(addr)
+			default:
+				overflow = append(overflow, "address: "+strings.Join(strings.Fields(strings.Join(a, " ")), " "))
+			}
+		case "BDAY":
+			if b, ok := parseBirthday(p.value); ok {
+				c.SetBirthday(&b)
+			} else if v := strings.TrimSpace(p.value); v != "" {
+				overflow = append(overflow, "birthday: "+v)
+			}
+		case "URL":
+			set(c.SetBusinessHomePage, unescapeText(p.value))
+		case "IMPP", "X-AIM", "X-JABBER", "X-SKYPE", "X-MSN":
+			if im := strings.TrimSpace(unescapeText(p.value)); im != "" {
+				if len(ims) == maxIMAddresses {
+					overflow = append(overflow, "IM: "+im)
+				} else {
+					ims = append(ims, im)
+				}
+			}
+		case "CATEGORIES":
+			for _, cat := range splitEscaped(p.value, ',') {
+				if cat = strings.TrimSpace(cat); cat != "" {
+					categories = append(categories, cat)
+				}
+			}
+		case "NOTE":
+			if note := strings.TrimSpace(unescapeText(p.value)); note != "" {
+				notes = append(notes, note)
+			}
+		}
+	}
+
+	if fn != "" {
+		for _, e := range emails {
+			e.SetName(&fn)
+		}
+	}
+	if len(emails) > 0 {
+		c.SetEmailAddresses(emails)
+	}
+	c.SetBusinessPhones(business)
+	c.SetHomePhones(home)
+	if len(ims) > 0 {
+		c.SetImAddresses(ims)
+	}
+	if len(categories) > 0 {
+		c.SetCategories(categories)
+	}
+	if len(overflow) > 0 {
+		notes = append(notes, "Also in vCard:\n"+strings.Join(overflow, "\n"))
+	}
+	set(c.SetPersonalNotes, strings.Join(notes, "\n\n"))
+	return c
+}
+
+// parseBirthday accepts the full dates used by vCard 3.0 and 4.0. Dates
+// without a year (--MMDD) cannot be stored by Outlook and go to the notes. The
+// time is set to 11:59 UTC, as Outlook does, so the date is the same in every
+// time zone.
+func parseBirthday(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 10 {
+		if i := strings.IndexByte(s, 'T'); i > 0 {
+			s = s[:i]
+		}
+	}
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Add(11*time.Hour + 59*time.Minute), true
+		}
+	}
+	return time.Time{}, false
+}
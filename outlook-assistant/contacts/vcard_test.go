@@ -0,0 +1,362 @@
+package contacts
+
+import (
+	"bufio"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+func TestWriteFolded(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"empty", ""},
+		{"short", "FN:Jane Doe"},
+		{"exactly 75 octets", "NOTE:" + strings.Repeat("a", 70)},
+		{"76 octets", "NOTE:" + strings.Repeat("a", 71)},
+		{"several folds", "NOTE:" + strings.Repeat("0123456789", 30)},
+		{"multi-byte runes", "NOTE:" + strings.Repeat("é", 100)},
+		{"four-byte runes", "NOTE:" + strings.Repeat("😀", 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			w := bufio.NewWriter(&b)
+			writeFolded(w, tt.line)
+			w.Flush()
+			out := b.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("writeFolded(%q) = %q; want it to end in CRLF", tt.line, out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			var unfolded strings.Builder
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets: %q", i, len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, line)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(line, " ") {
+						t.Fatalf("continuation %d does not start with a space: %q", i, line)
+					}
+					line = line[1:]
+				}
+				unfolded.WriteString(line)
+			}
+			if unfolded.String() != tt.line {
+				t.Errorf("unfolding gives %q; want %q", unfolded.String(), tt.line)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"", ""},
+		{"Jane Doe", "Jane Doe"},
+		{`C:\path`, `C:\\path`},
+		{"Doe, Jane; PhD", `Doe\, Jane\; PhD`},
+		{"line one\r\nline two\nthree", `line one\nline two\nthree`},
+	}
+	for _, tt := range tests {
+		got := escapeText(tt.s)
+		if got != tt.want {
+			t.Errorf("escapeText(%q) = %q; want %q", tt.s, got, tt.want)
+		}
+		if back := unescapeText(got); back != strings.ReplaceAll(tt.s, "\r\n", "\n") {
+			t.Errorf("unescapeText(%q) = %q; want %q back", got, back, tt.s)
+		}
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	tests := []struct {
+		s    string
+		sep  byte
+		want []string
+	}{
+		{"", ';', []string{""}},
+		{"Doe;Jane;;;", ';', []string{"Doe", "Jane", "", "", ""}},
+		{`Doe\;Smith;Jane`, ';', []string{"Doe;Smith", "Jane"}},
+		{`Acme\, Inc.;Sales`, ';', []string{"Acme, Inc.", "Sales"}},
+		{`Work,Friends\, close`, ',', []string{"Work", "Friends, close"}},
+		{`a\nb\Nc`, ';', []string{"a\nb\nc"}},
+		{`trailing\`, ';', []string{`trailing\`}},
+	}
+	for _, tt := range tests {
+		if got := splitEscaped(tt.s, tt.sep); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitEscaped(%q, %q) = %q; want %q", tt.s, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestParseProperty(t *testing.T) {
+	tests := []struct {
+		line  string
+		name  string
+		types []string
+		value string
+		ok    bool
+	}{
+		{"FN:Jane Doe", "FN", nil, "Jane Doe", true},
+		{"fn:", "FN", nil, "", true},
+		{"item1.EMAIL;TYPE=INTERNET:jane@example.com", "EMAIL", []string{"internet"}, "jane@example.com", true},
+		{"TEL;TYPE=WORK,VOICE:+1 555 0100", "TEL", []string{"voice", "work"}, "+1 555 0100", true},
+		{`TEL;TYPE="cell,voice":+1 555 0101`, "TEL", []string{"cell", "voice"}, "+1 555 0101", true},
+		{"TEL;type=home;type=fax:+1 555 0102", "TEL", []string{"fax", "home"}, "+1 555 0102", true},
+		{"TEL;CELL:+1 555 0103", "TEL", []string{"cell"}, "+1 555 0103", true},
+		{`ADR;LABEL="1 Main St; Springfield: USA";TYPE=home:;;1 Main St;Springfield;;;USA`, "ADR", []string{"home"}, ";;1 Main St;Springfield;;;USA", true},
+		{"URL:https://example.com:8443/path", "URL", nil, "https://example.com:8443/path", true},
+		{"", "", nil, "", false},
+		{"no colon", "", nil, "", false},
+		{":value", "", nil, "", false},
+		{`NOTE;X="open:value`, "", nil, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseProperty(tt.line)
+		if ok != tt.ok {
+			t.Errorf("parseProperty(%q) ok = %v; want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		var types []string
+		for typ := range got.types {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		if got.name != tt.name || got.value != tt.value || !reflect.DeepEqual(types, tt.types) {
+			t.Errorf("parseProperty(%q) = %s %q %q; want %s %q %q", tt.line, got.name, types, got.value, tt.name, tt.types, tt.value)
+		}
+	}
+}
+
+func TestParseVCards(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		cards   int
+		fn      string
+		wantErr string
+	}{
+		{name: "empty", input: ""},
+		{name: "one card", cards: 1, fn: "Jane Doe",
+			input: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Jane Doe\r\nEND:VCARD\r\n"},
+		{name: "two cards, LF line ends", cards: 2, fn: "Jane Doe",
+			input: "BEGIN:VCARD\nFN:Jane Doe\nEND:VCARD\nBEGIN:VCARD\nFN:John Roe\nEND:VCARD\n"},
+		{name: "folded with a space", cards: 1, fn: "Jane Doe-Smith",
+			input: "BEGIN:VCARD\r\nFN:Jane Doe-\r\n Smith\r\nEND:VCARD\r\n"},
+		{name: "folded with a tab", cards: 1, fn: "Jane Doe",
+			input: "BEGIN:VCARD\r\nFN:Jane\r\n\t Doe\r\nEND:VCARD\r\n"},
+		{name: "lower case", cards: 1, fn: "Jane Doe",
+			input: "begin:vcard\r\nfn:Jane Doe\r\nend:vcard\r\n"},
+		{name: "blank lines", cards: 1, fn: "Jane Doe",
+			input: "\r\nBEGIN:VCARD\r\n\r\nFN:Jane Doe\r\nEND:VCARD\r\n\r\n"},
+		{name: "lines outside a card", cards: 1, fn: "Jane Doe",
+			input: "X-NOTE:ignored\r\nBEGIN:VCARD\r\nFN:Jane Doe\r\nEND:VCARD\r\nFN:Stray\r\n"},
+		{name: "not closed", cards: 0,
+			input: "BEGIN:VCARD\r\nFN:Jane Doe\r\n"},
+		{name: "not a property", wantErr: "line 2",
+			input: "BEGIN:VCARD\r\nJane Doe\r\nEND:VCARD\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, err := parseVCards(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseVCards = %v; want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != tt.cards {
+				t.Fatalf("got %d cards; want %d", len(cards), tt.cards)
+			}
+			if tt.cards > 0 {
+				if fn := deref(contactFromVCard(cards[0]).GetDisplayName(), ""); fn != tt.fn {
+					t.Errorf("FN = %q; want %q", fn, tt.fn)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBirthday(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+		ok   bool
+	}{
+		{"1990-07-14", "1990-07-14T11:59:00Z", true},
+		{"19900714", "1990-07-14T11:59:00Z", true},
+		{" 1990-07-14 ", "1990-07-14T11:59:00Z", true},
+		{"1990-07-14T00:00:00Z", "1990-07-14T11:59:00Z", true},
+		{"19900714T000000", "1990-07-14T11:59:00Z", true},
+		{"2000-02-29", "2000-02-29T11:59:00Z", true},
+		{"", "", false},
+		{"--0714", "", false},
+		{"1990-02-30", "", false},
+		{"14/07/1990", "", false},
+		{"1990", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseBirthday(tt.s)
+		if ok != tt.ok || (ok && got.Format(time.RFC3339) != tt.want) {
+			t.Errorf("parseBirthday(%q) = %v, %v; want %s, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestContactFromVCard(t *testing.T) {
+	cards, err := parseVCards(strings.NewReader(strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		`FN:Jane Doe\, PhD`,
+		"N:Doe;Jane;Q;Dr.;PhD",
+		"NICKNAME:JD,Janie",
+		`ORG:Acme\, Inc.;Sales`,
+		"TITLE:Director",
+		"EMAIL;TYPE=INTERNET:jane@example.com",
+		"EMAIL:MAILTO:jane@work.example.com",
+		"EMAIL:",
+		"EMAIL:three@example.com",
+		"EMAIL:four@example.com",
+		"TEL;TYPE=CELL:+1 555 0100",
+		"TEL;TYPE=CELL:+1 555 0101",
+		"TEL;TYPE=WORK:+1 555 0102",
+		"TEL;TYPE=FAX:+1 555 0103",
+		"TEL:tel:+1 555 0104",
+		"TEL:",
+		"ADR;TYPE=HOME:;Apt 4;1 Main St;Springfield;IL;62701;USA",
+		"ADR;TYPE=HOME:;;2 Side St;Shelbyville;;;",
+		"BDAY:--0714",
+		"CATEGORIES:Work, ,Friends\\, close",
+		`NOTE:First line\nSecond line`,
+		"END:VCARD",
+	}, "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := contactFromVCard(cards[0])
+	checks := []struct {
+		field, got, want string
+	}{
+		{"displayName", deref(c.GetDisplayName(), ""), "Jane Doe, PhD"},
+		{"surname", deref(c.GetSurname(), ""), "Doe"},
+		{"givenName", deref(c.GetGivenName(), ""), "Jane"},
+		{"middleName", deref(c.GetMiddleName(), ""), "Q"},
+		{"title", deref(c.GetTitle(), ""), "Dr."},
+		{"generation", deref(c.GetGeneration(), ""), "PhD"},
+		{"nickName", deref(c.GetNickName(), ""), "JD"},
+		{"companyName", deref(c.GetCompanyName(), ""), "Acme, Inc."},
+		{"department", deref(c.GetDepartment(), ""), "Sales"},
+		{"jobTitle", deref(c.GetJobTitle(), ""), "Director"},
+		{"mobilePhone", deref(c.GetMobilePhone(), ""), "+1 555 0100"},
+		{"homeAddress street", deref(c.GetHomeAddress().GetStreet(), ""), "Apt 4\n1 Main St"},
+		{"homeAddress city", deref(c.GetHomeAddress().GetCity(), ""), "Springfield"},
+		{"homeAddress country", deref(c.GetHomeAddress().GetCountryOrRegion(), ""), "USA"},
+		{"otherAddress city", deref(c.GetOtherAddress().GetCity(), ""), "Shelbyville"},
+		{"personalNotes", deref(c.GetPersonalNotes(), ""), "First line\nSecond line\n\nAlso in vCard:\n" +
+			"email: four@example.com\nphone: +1 555 0103\nbirthday: --0714"},
+	}
+	for _, ch := range checks {
+		if ch.got != ch.want {
+			t.Errorf("%s = %q; want %q", ch.field, ch.got, ch.want)
+		}
+	}
+	var emails []string
+	for _, e := range c.GetEmailAddresses() {
+		emails = append(emails, deref(e.GetAddress(), "")+" "+deref(e.GetName(), ""))
+	}
+	if want := []string{"jane@example.com Jane Doe, PhD", "jane@work.example.com Jane Doe, PhD", "three@example.com Jane Doe, PhD"}; !reflect.DeepEqual(emails, want) {
+		t.Errorf("emails = %q; want %q", emails, want)
+	}
+	if want := []string{"+1 555 0102"}; !reflect.DeepEqual(c.GetBusinessPhones(), want) {
+		t.Errorf("businessPhones = %q; want %q", c.GetBusinessPhones(), want)
+	}
+	if want := []string{"+1 555 0101", "+1 555 0104"}; !reflect.DeepEqual(c.GetHomePhones(), want) {
+		t.Errorf("homePhones = %q; want %q", c.GetHomePhones(), want)
+	}
+	if want := []string{"Work", "Friends, close"}; !reflect.DeepEqual(c.GetCategories(), want) {
+		t.Errorf("categories = %q; want %q", c.GetCategories(), want)
+	}
+	if c.GetBirthday() != nil {
+		t.Errorf("birthday = %v; want none", c.GetBirthday())
+	}
+}
+
+func TestContactFromVCardEmpty(t *testing.T) {
+	c := contactFromVCard(nil)
+	if c.GetDisplayName() != nil || c.GetPersonalNotes() != nil || c.GetEmailAddresses() != nil {
+		t.Errorf("empty vCard gives %v, %v, %v; want no name, notes, or emails", c.GetDisplayName(), c.GetPersonalNotes(), c.GetEmailAddresses())
+	}
+}
+
+func TestVCardRoundTrip(t *testing.T) {
+	c := models.NewContact()
+	str := func(s string) *string { return &s }
+	c.SetGivenName(str("Jane"))
+	c.SetSurname(str("Doe; Smith"))
+	c.SetDisplayName(str("Jane Doe; Smith"))
+	c.SetCompanyName(str("Acme, Inc."))
+	c.SetMobilePhone(str("+1 555 0100"))
+	c.SetPersonalNotes(str("Met at the conference.\nLikes tea — ☕, not coffee. " + strings.Repeat(" More notes.", 10)))
+	c.SetCategories([]string{"Work", "Friends, close"})
+	birthday := time.Date(1990, 7, 14, 11, 59, 0, 0, time.UTC)
+	c.SetBirthday(&birthday)
+	email := models.NewEmailAddress()
+	email.SetAddress(str("jane@example.com"))
+	c.SetEmailAddresses([]models.EmailAddressable{email})
+
+	for _, version := range []string{"3.0", "4.0"} {
+		t.Run(version, func(t *testing.T) {
+			var b strings.Builder
+			w := bufio.NewWriter(&b)
+			writeVCard(w, c, version)
+			w.Flush()
+			cards, err := parseVCards(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != 1 {
+				t.Fatalf("got %d cards; want 1", len(cards))
+			}
+			got := contactFromVCard(cards[0])
+			for _, pair := range [][2]*string{
+				{got.GetGivenName(), c.GetGivenName()},
+				{got.GetSurname(), c.GetSurname()},
+				{got.GetDisplayName(), c.GetDisplayName()},
+				{got.GetCompanyName(), c.GetCompanyName()},
+				{got.GetMobilePhone(), c.GetMobilePhone()},
+				{got.GetPersonalNotes(), c.GetPersonalNotes()},
+			} {
+				if deref(pair[0], "") != deref(pair[1], "") {
+					t.Errorf("got %q; want %q", deref(pair[0], ""), deref(pair[1], ""))
+				}
+			}
+			if !reflect.DeepEqual(got.GetCategories(), c.GetCategories()) {
+				t.Errorf("categories = %q; want %q", got.GetCategories(), c.GetCategories())
+			}
+			if got.GetBirthday() == nil || !got.GetBirthday().Equal(birthday) {
+				t.Errorf("birthday = %v; want %v", got.GetBirthday(), birthday)
+			}
+			if len(got.GetEmailAddresses()) != 1 || deref(got.GetEmailAddresses()[0].GetAddress(), "") != "jane@example.com" {
+				t.Errorf("emails = %v; want jane@example.com", got.GetEmailAddresses())
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Package daemon serves the tool's actions to a long-running client over
+// stdio, so a caller making many calls pays for sign-in and connection setup
+// once instead of on every command. Each request names an action and gives
+// its flags:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"mail.list","params":{"n":5,"unread":true}}
+//
+// and the result carries the action's --json output and its status messages.
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"outlook-assistant/jsonrpc"
+)
+
+// Runner runs one command line and returns what it printed. err is set when
+// the command failed.
+type Runner func(ctx context.Context, args []string) (stdout, stderr []byte, err error)
+
+// Server answers "<group>.<action>" requests through Run.
+type Server struct {
+	Run Runner
+	// LongRunning names the actions, as "group.action", that run until
+	// interrupted. Calls answer one at a time, so those mapped to true, which
+	// return after one pass with --once, are always given it, and the rest
+	// are refused.
+	LongRunning map[string]bool
+}
+
+// Result is the answer to a call that succeeded. Output is the action's JSON
+// output, or its text when it printed something else, and null when it
+// printed nothing. Messages are its status messages as --log-format=json
+// objects.
+type Result struct {
+	Output   json.RawMessage   `json:"output"`
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// reserved are flags the server sets or that make no sense per call.
+var reserved = map[string]bool{
+	"group": true, "action": true, "serve": true, "describe": true, "log-format": true,
+}
+
+// Serve answers requests from in on out until in ends or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	return jsonrpc.Serve(ctx, in, out, s.handle)
+}
+
+func (s *Server) handle(ctx context.Context, method string, params json.RawMessage) (interface{}, *jsonrpc.Error) {
+	if method == "ping" {
+		return struct{}{}, nil
+	}
+	group, action, ok := strings.Cut(method, ".")
+	if !ok || group == "" || action == "" {
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: fmt.Sprintf("method %q is not <group>.<action>, such as mail.list", method)}
+	}
+	// The mock server runs until interrupted and would never answer.
+	if group == "devtools" {
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: "devtools actions are not available in a server"}
+	}
+	once, longRunning := s.LongRunning[method]
+	if longRunning && !once {
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: fmt.Sprintf("%s runs until interrupted and is not available in a server", method)}
+	}
+	args, err := commandLine(group, action, params)
+	if err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: err.Error()}
+	}
+	if once {
+		args = append(args, "--once")
+	}
+
+	stdout, stderr, runErr := s.Run(ctx, args)
+	result := Result{Output: output(stdout), Messages: messages(stderr)}
+	if runErr != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.ServerError, Message: runErr.Error(), Data: result}
+	}
+	return result, nil
+}
+
+// commandLine turns params, an object of flag values, into the command line
+// for group and action. --json is given unless params say otherwise, such as
+// {"csv": true} for calendar export.
+func commandLine(group, action string, params json.RawMessage) ([]string, error) {
+	var flags map[string]interface{}
+	if len(params) > 0 && string(params) != "null" {
+		decoder := json.NewDecoder(bytes.NewReader(params))
+		decoder.UseNumber()
+		if err := decoder.Decode(&flags); err != nil {
+			return nil, fmt.Errorf("params must be an object of flag values: %w", err)
+		}
+	}
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{group, action, "--log-format=json"}
+	format := false
+	for _, name := range names {
+		if reserved[name] {
+			return nil, fmt.Errorf("--%s cannot be given per call", name)
+		}
+		value, err := flagValue(flags[name])
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", name, err)
+		}
+		if value == nil {
+			continue
+		}
+		if name == "json" || name == "csv" {
+			format = true
+		}
+		args = append(args, "--"+name+"="+*value)
+	}
+	if !format {
+		args = append(args, "--json")
+	}
+	return args, nil
+}
+
+// flagValue renders a JSON value as a flag value: a list of values as a
+// comma-separated one, as flags such as --to take. null leaves the flag out.
+func flagValue(v interface{}) (*string, error) {
+	var s string
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = x
+	case json.Number:
+		s = x.String()
+	case bool:
+		s = fmt.Sprint(x)
+	case []interface{}:
+		parts := make([]string, 0, len(x))
+		for _, item := range x {
+			p, err := flagValue(item)
+			if err != nil || p == nil {
+				return nil, fmt.Errorf("a list may only hold strings, numbers, and booleans")
+			}
+			parts = append(parts, *p)
+		}
+		s = strings.Join(parts, ",")
+	default:
+		return nil, fmt.Errorf("want a string, number, boolean, or list")
+	}
+	return &s, nil
+}
+
+// output returns stdout as JSON: as is when it is JSON, as a string otherwise.
+func output(stdout []byte) json.RawMessage {
+	trimmed := bytes.TrimSpace(stdout)
+	if len(trimmed) == 0 {
+		return json.RawMessage("null")
+	}
+	if json.Valid(trimmed) {
+		return trimmed
+	}
+	s, _ := json.Marshal(string(stdout))
+	return s
+}
+
+// messages splits stderr into its JSON log records. Anything else printed
+// there, such as a sign-in prompt, is kept as a string.
+func messages(stderr []byte) []json.RawMessage {
+	out := []json.RawMessage{}
+	for _, line := range strings.Split(string(stderr), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case json.Valid([]byte(line)):
+			out = append(out, json.RawMessage(line))
+		default:
+			s, _ := json.Marshal(line)
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"outlook-assistant/retry"
+	"outlook-assistant/subscribe"
+)
+
+// Each action parses its command line with a FlagSet of its own: the flags
+// every command takes, and those the manifest lists for the action, bound to
+// the options of its group. A flag that means different things to different
+// actions, such as --out, gets the help text of the action it is given to.
+
+// globalOptions are the flags every command takes, and the deprecated --group
+// and --action.
+type globalOptions struct {
+	group, action string
+	describe      bool
+	serve         string
+
+	user, mailbox, auth, tokenStore, tenant string
+	config, profile, timezone               string
+
+	json, cache, stats  bool
+	logLevel, logFormat string
+	maxRetries          int
+	timeout             time.Duration
+}
+
+func (g *globalOptions) flags(fs *flag.FlagSet) {
+	fs.StringVar(&g.group, "group", "mail", "Deprecated: the command group as a flag; write `outlook-assistant <group> <action>` instead (default: mail)")
+	fs.StringVar(&g.action, "action", "", "Deprecated: the action as a flag; write `outlook-assistant <group> <action>` instead")
+	fs.BoolVar(&g.describe, "describe", false, "Print the tool manifest (actions and parameters, as in tool.yaml) and exit")
+	fs.StringVar(&g.serve, "serve", "", "Serve actions over stdio instead of running one: mcp (Model Context Protocol tools) | jsonrpc (<group>.<action> requests)")
+
+	fs.StringVar(&g.user, "user", "", "Mailbox owner UPN or object ID; required with app-only auth (--auth=managed-identity, client-credentials)")
+	fs.StringVar(&g.mailbox, "mailbox", "", "Shared or delegated mailbox to act on instead of your own, as UPN or object ID (same as --user)")
+	fs.StringVar(&g.auth, "auth", "", "Auth mode: delegated (browser sign-in, default) | device-code (sign in on another device) | managed-identity | client-credentials (app-only; env AUTH_MODE)")
+	fs.StringVar(&g.tokenStore, "token-store", "auto", "Token cache: auto | keychain | file | memory (file needs OUTLOOK_ASSISTANT_TOKEN_KEY)")
+	fs.StringVar(&g.tenant, "tenant", "", "Tenant ID or domain for this invocation only, overriding TENANT_ID (uses its own cached sign-in)")
+
+	fs.StringVar(&g.config, "config", "", "YAML file of default flag values; flags given on the command line win (default: ~/.outlook-assistant.yaml)")
+	fs.StringVar(&g.profile, "profile", "", "Named set of defaults from the profiles section of the config file")
+	fs.StringVar(&g.timezone, "timezone", "", "IANA time zone for local dates given and shown, e.g. Europe/Berlin (default: the system's; for calendar, the mailbox's)")
+
+	fs.BoolVar(&g.json, "json", false, "Output results as JSON to stdout")
+	fs.BoolVar(&g.cache, "cache", false, "Cache GET responses and revalidate them with If-None-Match, or a delta query for the folder list (~/.outlook-assistant-cache)")
+	fs.BoolVar(&g.stats, "stats", false, "Print Graph request statistics (requests, bytes, retries, throttling, latency) to stderr")
+	fs.StringVar(&g.logLevel, "log-level", "info", "Status messages on stderr: debug | info | warn | error")
+	fs.StringVar(&g.logFormat, "log-format", "text", "Status message format on stderr: text | json (one object per line)")
+	fs.IntVar(&g.maxRetries, "max-retries", retry.DefaultMaxRetries, "Times to re-send a throttled (429) or transiently failed (5xx) Graph request, honoring Retry-After; 0 turns retrying off")
+	fs.DurationVar(&g.timeout, "timeout", 0, "Give up on the command after this long, e.g. 30s or 5m, retries included (default: no limit)")
+}
+
+// groupOptions are the flags of one group's actions. flags defines those of
+// action on fs, with the action's help text; with action "", those of every
+// action.
+type groupOptions interface {
+	flags(fs *flag.FlagSet, action string)
+}
+
+// commandOptions holds the options of every group, so the command that runs
+// reads those of its own.
+type commandOptions struct {
+	mail       mailOptions
+	calendar   calendarOptions
+	contacts   contactsOptions
+	people     peopleOptions
+	settings   settingsOptions
+	rules      rulesOptions
+	categories categoriesOptions
+	subscribe  subscribeOptions
+	snippets   snippetsOptions
+	signature  signatureOptions
+	schema     schemaOptions
+	devtools   devtoolsOptions
+}
+
+// of returns the options of group, or nil for a group whose actions take only
+// the flags every command takes.
+func (c *commandOptions) of(group string) groupOptions {
+	switch group {
+	case "mail":
+		return &c.mail
+	case "calendar":
+		return &c.calendar
+	case "contacts":
+		return &c.contacts
+	case "people":
+		return &c.people
+	case "settings":
+		return &c.settings
+	case "rules":
+		return &c.rules
+	case "categories":
+		return &c.categories
+	case "subscribe":
+		return &c.subscribe
+	case "snippets":
+		return &c.snippets
+	case "signature":
+		return &c.signature
+	case "schema":
+		return &c.schema
+	case "devtools":
+		return &c.devtools
+	}
+	return nil
+}
+
+// newFlagSet returns the FlagSet of group's action, binding the flags every
+// command takes to g and the action's own to its group's options in opts.
+// With every set, as for a command given with the deprecated --group and
+// --action, it takes the flags of every action of every group instead, and
+// ignores those the command does not read.
+func newFlagSet(group, action string, g *globalOptions, opts *commandOptions, every bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	g.flags(fs)
+
+	takes, _ := actionFlags(group, action)
+	add := func(group, action string) {
+		o := opts.of(group)
+		if o == nil {
+			return
+		}
+		own := flag.NewFlagSet(group, flag.ContinueOnError)
+		o.flags(own, action)
+		own.VisitAll(func(f *flag.Flag) {
+			if (every || takes[f.Name]) && fs.Lookup(f.Name) == nil {
+				fs.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+	}
+	if slices.Contains(groups, group) {
+		add(group, action)
+	}
+	if every {
+		for _, other := range groups {
+			add(other, "")
+		}
+	}
+	return fs
+}
+
+// bodyOf returns the --body of group, or nil for a group without one.
+func (c *commandOptions) bodyOf(group string) *bodyOptions {
+	switch group {
+	case "mail":
+		return &c.mail.bodyOptions
+	case "calendar":
+		return &c.calendar.bodyOptions
+	case "settings":
+		return &c.settings.bodyOptions
+	case "snippets":
+		return &c.snippets.bodyOptions
+	case "signature":
+		return &c.signature.bodyOptions
+	}
+	return nil
+}
+
+// bodyOptions are --body and, for the actions that take it, --body-file: a
+// text such as a message body.
+type bodyOptions struct {
+	body, bodyFile string
+}
+
+// readBody replaces --body with the text it names: stdin for --body=-, or
+// the contents of --body-file.
+func (b *bodyOptions) readBody(fromServer bool) error {
+	body, err := readBody(b.body, b.bodyFile, fromServer)
+	b.body = body
+	return err
+}
+
+// ── mail ──────────────────────────────────────────────────────────────────────
+
+// mailOptions are the flags of the mail actions.
+type mailOptions struct {
+	bodyOptions
+
+	ref, query, conversation string
+
+	clean, splitQuotes, stripQuotes, full, raw, permanent bool
+	as, saveDir, saveImages                               string
+	tab                                                   string // --as of classify
+
+	count, page                           int
+	since, before, from, domain, to       string
+	ccMe, unread, markRead, flagged       bool
+	focused, other, hasAttachments        bool
+	importance, subject, minSize, maxSize string
+	newerThan, olderThan, folder, out     string
+	tree, addRule, complete, clearFlag    bool
+	due, rule                             string
+	interval                              time.Duration
+	once                                  bool
+	maildir                               string
+	concurrency                           int
+	apply, toFolder, set, add, remove     string
+	confirm                               int
+	yes, dryRun                           bool
+	cc, bcc, format, snippet, vars        string
+	signature, sensitivity, attach        string
+	noSignature, queue, strict            bool
+	name, filter, address                 string
+	safe                                  bool
+}
+
+func (o *mailOptions) flags(fs *flag.FlagSet, action string) {
+	ref := "Message reference: list index (e.g. 3) or raw Graph message ID"
+	switch action {
+	case "read", "archive", "move", "categorize", "flag", "markread", "delete", "restore":
+		ref += ", or several, e.g. 1,3,5-9"
+	}
+	fs.StringVar(&o.ref, "ref", "", ref)
+	fs.StringVar(&o.query, "query", "", "Search query string")
+	fs.StringVar(&o.conversation, "conversation", "", "Message reference whose whole conversation is acted on")
+
+	fs.BoolVar(&o.clean, "clean", false, "Show only each message's new text, without quoted history, signatures, or disclaimers")
+	fs.BoolVar(&o.splitQuotes, "split-quotes", false, "With --json, also return each body split into newContent and quotedContent")
+	fs.BoolVar(&o.stripQuotes, "strip-quotes", false, "Drop the quoted history (earlier messages of the reply chain) from each body")
+	if action == "classify" {
+		fs.StringVar(&o.tab, "as", "", "Focused Inbox tab to move the message to: focused or other")
+	} else {
+		fs.StringVar(&o.as, "as", "", "How to show HTML bodies: text (default) or markdown")
+	}
+	saveDir := "Directory to download the message's attachments to"
+	if action == "attachments" {
+		saveDir = "Directory to download the attachments to, instead of listing them"
+	}
+	fs.StringVar(&o.saveDir, "save-dir", "", saveDir)
+	fs.StringVar(&o.saveImages, "save-images", "", "Directory to download inline images to; the body's cid: references then point at the files")
+	fs.BoolVar(&o.full, "full", false, "Show each message's whole body, quoted history included, instead of only the text it added")
+	fs.BoolVar(&o.raw, "raw", false, "Print the message's raw MIME, internet headers and all, instead of its body")
+	fs.BoolVar(&o.permanent, "permanent", false, "Purge the message past Recoverable Items, so it cannot be restored")
+
+	fs.IntVar(&o.count, "n", 20, "Number of messages to fetch")
+	fs.IntVar(&o.page, "page", 1, "Page number, 1-based")
+	since := "Only messages on or after date: YYYY-MM-DD, YYYY-MM-DD HH:MM, an age such as 7d, or a day such as yesterday or \"last monday\""
+	switch action {
+	case "needs-reply":
+		since = "Look at messages received since this date or age (default: 7d)"
+	case "awaiting-response":
+		since = "Look at messages sent since this date or age (default: 30d)"
+	case "watch":
+		since = "Start afresh from messages received since this date or age, instead of the last poll"
+	}
+	fs.StringVar(&o.since, "since", "", since)
+	fs.StringVar(&o.before, "before", "", "Only messages on or before date: YYYY-MM-DD, YYYY-MM-DD HH:MM, or a relative date as for --since")
+	fs.StringVar(&o.from, "from", "", "Only messages from this sender email address")
+	fs.StringVar(&o.domain, "domain", "", "Only messages from senders at this domain, e.g. vendor.com")
+	fs.BoolVar(&o.ccMe, "cc-me", false, "Only messages that have you on the Cc line")
+	unread := "Only unread messages"
+	if action == "markread" {
+		unread = "Mark as unread instead of read"
+	}
+	fs.BoolVar(&o.unread, "unread", false, unread)
+	fs.BoolVar(&o.markRead, "mark-read", false, "Mark the displayed messages as read once they are shown")
+	fs.BoolVar(&o.flagged, "flagged", false, "Only messages flagged for follow-up")
+	fs.BoolVar(&o.focused, "focused", false, "Only messages on the Focused tab of the inbox")
+	fs.BoolVar(&o.other, "other", false, "Only messages on the Other tab of the inbox")
+	fs.BoolVar(&o.hasAttachments, "has-attachments", false, "Only messages with file attachments")
+	importance := "Only messages of this importance: low, normal, or high"
+	switch action {
+	case "send", "reply", "reply-all", "forward":
+		importance = "Importance of the message: low, normal, or high"
+	}
+	fs.StringVar(&o.importance, "importance", "", importance)
+	subject := "Only messages whose subject contains this text"
+	if action == "send" {
+		subject = "Subject line"
+	}
+	fs.StringVar(&o.subject, "subject", "", subject)
+	minSize := "Only messages of at least this size, e.g. 500KB or 5MB"
+	if action == "largest" {
+		minSize += " (default: 1MB)"
+	}
+	fs.StringVar(&o.minSize, "min-size", "", minSize)
+	fs.StringVar(&o.maxSize, "max-size", "", "Only messages of at most this size, e.g. 100KB")
+	fs.StringVar(&o.newerThan, "newer-than", "", "Only messages newer than an age: 12h, 7d, 3w, or 2mo, in place of --since")
+	olderThan := "Only messages older than an age: 12h, 7d, 3w, or 2mo, in place of --before"
+	if action == "awaiting-response" {
+		olderThan = "Only sent messages at least this old, as an age or YYYY-MM-DD (default: 3d)"
+	}
+	fs.StringVar(&o.olderThan, "older-than", "", olderThan)
+
+	// A move must name its destination, or a message would land in the inbox.
+	folder, defaultFolder := "Folder name or well-known name, such as inbox or archive", "inbox"
+	switch action {
+	case "move":
+		folder, defaultFolder = "Folder to move the messages to", ""
+	case "restore":
+		folder = "Folder to move the message back to"
+	case "empty":
+		folder = "Folder to empty: deleteditems or junkemail"
+	case "searchfolder-create":
+		folder = "Folders to search, comma-separated, subfolders included"
+	}
+	fs.StringVar(&o.folder, "folder", defaultFolder, folder)
+	out := "JSON file to write every page of results to"
+	switch action {
+	case "export":
+		out = "File to write the message to (default: the subject, .eml)"
+	case "export-folder":
+		out = "mbox file to write the folder to"
+	}
+	fs.StringVar(&o.out, "out", "", out)
+
+	fs.BoolVar(&o.tree, "tree", false, "Show the full folder hierarchy including subfolders")
+	fs.BoolVar(&o.addRule, "add-rule", false, "Also create an inbox rule that files future messages in the thread")
+	fs.StringVar(&o.due, "due", "", "Date the follow-up is due, YYYY-MM-DD")
+	fs.BoolVar(&o.complete, "complete", false, "Mark the follow-up flag complete")
+	fs.BoolVar(&o.clearFlag, "clear", false, "Remove the follow-up flag")
+	fs.StringVar(&o.rule, "rule", "", "Inbox rule ID or path to a messageRule JSON file")
+
+	fs.DurationVar(&o.interval, "interval", time.Minute, "Time between polls, e.g. 30s or 5m")
+	fs.BoolVar(&o.once, "once", false, "Poll once, print what changed since the last run, and exit")
+	fs.StringVar(&o.maildir, "maildir", "", "Maildir directory to write the folder to, in place of an --out mbox file")
+	fs.IntVar(&o.concurrency, "concurrency", 4, "Messages downloaded at once (1-16)")
+
+	fs.StringVar(&o.apply, "apply", "", "What to do with each matching message: archive, move, delete, markread, or categorize")
+	fs.StringVar(&o.toFolder, "to-folder", "", "With --apply=move, the folder to move the messages to")
+	fs.IntVar(&o.confirm, "confirm", 0, "The number of messages to change, from --dry-run; a sweep matching more changes nothing")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "List the matching messages without changing them")
+	fs.BoolVar(&o.yes, "yes", false, "Confirm that every message in --folder is to be deleted")
+	set := "Comma-separated category names to apply, replacing the message's others; empty string clears all"
+	if action == "sweep" {
+		set = "With --apply=categorize, comma-separated category names to apply"
+	}
+	fs.StringVar(&o.set, "set", "", set)
+	fs.StringVar(&o.add, "add", "", "Comma-separated categories to add, keeping the message's others")
+	fs.StringVar(&o.remove, "remove", "", "Comma-separated categories to remove, keeping the message's others")
+
+	to := "Recipient address(es), comma-separated"
+	switch action {
+	case "list", "sweep":
+		to = "Only messages with this address on the To line"
+	case "validate":
+		to = "Addresses or names to check, comma-separated"
+	}
+	fs.StringVar(&o.to, "to", "", to)
+	fs.StringVar(&o.cc, "cc", "", "CC address(es), comma-separated")
+	fs.StringVar(&o.bcc, "bcc", "", "BCC address(es), comma-separated")
+	body := "Message body text; - reads it from stdin"
+	if action == "forward" {
+		body = "Comment above the forwarded message; - reads it from stdin"
+	}
+	fs.StringVar(&o.body, "body", "", body)
+	fs.StringVar(&o.bodyFile, "body-file", "", "File to read the message body from, in place of --body")
+	format := "Body format: text, md (Markdown), or html (raw HTML pass-through); without it md when the body looks like Markdown, else text"
+	if action == "export" {
+		format = "File format: eml"
+	}
+	fs.StringVar(&o.format, "format", "", format)
+	fs.StringVar(&o.snippet, "snippet", "", "Saved snippet to use as the body, sent as Markdown")
+	fs.StringVar(&o.vars, "vars", "", "Snippet placeholder values: \"key=value;key=value\"")
+	fs.StringVar(&o.signature, "signature", "", "Text appended to the body after a blank line, in the body's format, in place of the one saved with signature set; usually set in the config file")
+	fs.BoolVar(&o.noSignature, "no-signature", false, "Append no signature")
+	fs.StringVar(&o.sensitivity, "sensitivity", "", "Sensitivity of the message: personal, private, or confidential")
+	fs.StringVar(&o.attach, "attach", "", "Comma-separated files to attach")
+	fs.BoolVar(&o.queue, "queue", false, "Save to the local outbox instead of failing when offline or signed out")
+	fs.BoolVar(&o.strict, "strict", false, "Fail on suspected recipient typos instead of warning")
+
+	name := "Search folder display name"
+	if action == "searchfolder-delete" {
+		name = "Search folder name or ID"
+	}
+	fs.StringVar(&o.name, "name", "", name)
+	fs.StringVar(&o.filter, "filter", "", "OData $filter for the search folder, e.g. \"from/emailAddress/address eq 'cfo@x.com'\"")
+	fs.StringVar(&o.address, "address", "", "Sender address(es), comma-separated")
+	fs.BoolVar(&o.safe, "safe", false, "Act on the safe sender list instead of the blocked list")
+}
+
+// ── calendar ──────────────────────────────────────────────────────────────────
+
+// calendarOptions are the flags of the calendar actions.
+type calendarOptions struct {
+	bodyOptions // the cancellation message of delete
+
+	count                           int
+	ref                             string
+	since, before, newerThan        string
+	olderThan, expand, month, uid   string
+	title, start, end               string
+	duration                        time.Duration
+	location, attendees, optional   string
+	showAs, reminder, address, room string
+	coordinates, attach             string
+	force                           bool
+	response, comment               string
+	sendResponse                    bool
+	out, file, include              string
+	csv                             bool
+}
+
+func (o *calendarOptions) flags(fs *flag.FlagSet, action string) {
+	fs.IntVar(&o.count, "n", 20, "Number of events to fetch")
+	ref := "Event reference: list index or raw Graph event ID"
+	if action == "export" {
+		ref = "Event to export as iCalendar, as list index or raw Graph event ID"
+	}
+	fs.StringVar(&o.ref, "ref", "", ref)
+	since := "Only events on or after date: YYYY-MM-DD, YYYY-MM-DD HH:MM, an age such as 7d, or a day such as yesterday or \"last monday\""
+	if action == "week" {
+		since = "A day of the week to show (default: today)"
+	}
+	fs.StringVar(&o.since, "since", "", since)
+	fs.StringVar(&o.before, "before", "", "Only events on or before date: YYYY-MM-DD, YYYY-MM-DD HH:MM, or a relative date as for --since")
+	fs.StringVar(&o.newerThan, "newer-than", "", "Only events newer than an age: 12h, 7d, 3w, or 2mo, in place of --since")
+	fs.StringVar(&o.olderThan, "older-than", "", "Only events older than an age: 12h, 7d, 3w, or 2mo, in place of --before")
+	fs.StringVar(&o.expand, "expand", "", "occurrences | masters — list each instance of recurring meetings (default) or each series once")
+	fs.StringVar(&o.month, "month", "", "Month to show: YYYY-MM (default: this month)")
+	fs.StringVar(&o.uid, "uid", "", "Event iCalUId, as found in .ics files")
+
+	fs.StringVar(&o.title, "title", "", "Event title")
+	start := "Start date/time: \"2006-01-02 15:04\", or a phrase such as \"tomorrow 2pm\" or a range such as \"next tuesday 09:00-09:30\""
+	switch action {
+	case "week", "month":
+		start = "First day of the week, e.g. monday (default: the first working day)"
+	}
+	fs.StringVar(&o.start, "start", "", start)
+	fs.StringVar(&o.end, "end", "", "End date/time: \"2006-01-02 15:04\", a phrase as for --start, or a time alone on the start's day")
+	fs.DurationVar(&o.duration, "duration", 0, "How long the event lasts, e.g. 30m or 1h30m, in place of --end")
+	fs.StringVar(&o.location, "location", "", "Location string; separate several with ';'")
+	attendees, optional := "Comma-separated attendee emails or names", "Comma-separated optional attendee emails or names"
+	if action == "update" {
+		attendees, optional = "Comma-separated attendee emails or names, replacing the required attendees", "Comma-separated optional attendee emails or names, replacing the optional attendees"
+	}
+	fs.StringVar(&o.attendees, "attendees", "", attendees)
+	fs.StringVar(&o.optional, "optional-attendees", "", optional)
+	fs.StringVar(&o.showAs, "show-as", "", "busy | free | tentative | oof | workingElsewhere")
+	fs.StringVar(&o.reminder, "reminder", "", "Remind this long before the start, e.g. 15m, 1h, 1d, or none")
+	fs.StringVar(&o.address, "address", "", "Street address \"street, city, state, postal code, country\" of the event location")
+	fs.StringVar(&o.room, "room", "", "Room mailbox email address to book")
+	fs.StringVar(&o.coordinates, "coordinates", "", "Location latitude,longitude")
+	fs.StringVar(&o.attach, "attach", "", "Comma-separated files to attach")
+	fs.BoolVar(&o.force, "force", false, "Create the event even though it overlaps busy time")
+	fs.StringVar(&o.body, "body", "", "Cancellation message sent to the attendees; - reads it from stdin")
+
+	fs.StringVar(&o.response, "response", "", "accept | decline | tentative")
+	fs.StringVar(&o.comment, "comment", "", "Note to the organizer sent with the response")
+	fs.BoolVar(&o.sendResponse, "send-response", true, "Send the response to the organizer; =false only updates your calendar")
+
+	out := "iCalendar file to write, - for stdout"
+	if action == "read" {
+		out = "Directory to save the event's attachments in"
+	}
+	fs.StringVar(&o.out, "out", "", out)
+	file := "CSV or JSON file to write (default: stdout)"
+	switch action {
+	case "import-bulk":
+		file = "CSV or JSON file of events to read"
+	case "import":
+		file = ".ics file to read"
+	}
+	fs.StringVar(&o.file, "file", "", file)
+	fs.BoolVar(&o.csv, "csv", false, "Write CSV with a header row")
+	fs.StringVar(&o.include, "include", "", "Extra columns: attendees, categories")
+}
+
+// ── contacts ──────────────────────────────────────────────────────────────────
+
+// contactsOptions are the flags of the contacts actions.
+type contactsOptions struct {
+	count                       int
+	ref, query                  string
+	merge, dryRun               bool
+	file, out, vcardVersion     string
+	photo                       string // --set of photo
+	name, email, phone, company string
+}
+
+func (o *contactsOptions) flags(fs *flag.FlagSet, action string) {
+	fs.IntVar(&o.count, "n", 20, "Number of contacts to fetch")
+	fs.StringVar(&o.ref, "ref", "", "Contact reference: list index or raw Graph contact ID")
+	fs.StringVar(&o.query, "query", "", "Search query string")
+	fs.BoolVar(&o.merge, "merge", false, "Merge each group of duplicates into its most complete contact")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Show the merged result without changing anything")
+	fs.StringVar(&o.file, "file", "", "vCard file to read")
+	out := ".vcf file to write (default: stdout)"
+	if action == "photo" {
+		out = "Image file to save the contact's photo to"
+	}
+	fs.StringVar(&o.out, "out", "", out)
+	fs.StringVar(&o.vcardVersion, "vcard-version", "3.0", "vCard version to write: 3.0 | 4.0")
+	fs.StringVar(&o.photo, "set", "", "JPEG or PNG file to upload as the contact's photo")
+	fs.StringVar(&o.name, "name", "", "Contact's full name")
+	fs.StringVar(&o.email, "email", "", "Email address(es), comma-separated, at most 3")
+	fs.StringVar(&o.phone, "phone", "", "Mobile phone number")
+	fs.StringVar(&o.company, "company", "", "Company name")
+}
+
+// ── people ────────────────────────────────────────────────────────────────────
+
+// peopleOptions are the flags of the people actions.
+type peopleOptions struct {
+	list      string
+	recursive bool
+}
+
+func (o *peopleOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.list, "list", "", "Distribution list or group: email address, name, or ID")
+	fs.BoolVar(&o.recursive, "recursive", false, "Expand nested groups into the people who receive mail")
+}
+
+// ── settings ──────────────────────────────────────────────────────────────────
+
+// settingsOptions are the flags of the settings actions.
+type settingsOptions struct {
+	bodyOptions // the internal reply of autoreply
+
+	addDomain, removeDomain, trustContacts string
+	safe                                   bool
+
+	status, externalBody, audience, format, start, end string
+
+	mailboxTimezone, workDays, workHours string
+	dateFormat, timeFormat, language     string
+}
+
+func (o *settingsOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.addDomain, "add-domain", "", "Domain(s) to add to the blocked list, comma-separated (with --safe, the safe list)")
+	fs.StringVar(&o.removeDomain, "remove-domain", "", "Domain(s) to remove from the blocked list, comma-separated (with --safe, the safe list)")
+	fs.BoolVar(&o.safe, "safe", false, "Change the safe sender list instead of the blocked list")
+	fs.StringVar(&o.trustContacts, "trust-contacts", "", "on | off — not settable through Microsoft Graph; reported as an error")
+
+	fs.StringVar(&o.status, "status", "", "off | on | scheduled (--start/--end imply scheduled)")
+	fs.StringVar(&o.body, "body", "", "Reply sent to senders inside your organization, in --format; - reads it from stdin")
+	fs.StringVar(&o.externalBody, "external-body", "", "Reply sent to senders outside your organization, in --format")
+	fs.StringVar(&o.audience, "audience", "", "none | contacts | all — external senders who get a reply")
+	fs.StringVar(&o.format, "format", "", "Format of the replies: text, md (Markdown), or html")
+	fs.StringVar(&o.start, "start", "", "When the scheduled replies start, in local time")
+	fs.StringVar(&o.end, "end", "", "When the scheduled replies end, in local time")
+
+	fs.StringVar(&o.mailboxTimezone, "mailbox-timezone", "", "Time zone to set in the mailbox's Outlook settings, IANA or Windows name; the working hours move to it")
+	fs.StringVar(&o.workDays, "work-days", "", "Working days: a range such as mon-fri, or days such as mon,tue,thu")
+	fs.StringVar(&o.workHours, "work-hours", "", "Working hours, such as 09:00-17:30")
+	fs.StringVar(&o.dateFormat, "date-format", "", "Date format Outlook shows, such as dd.MM.yyyy or M/d/yyyy")
+	fs.StringVar(&o.timeFormat, "time-format", "", "Time format Outlook shows, such as HH:mm or h:mm tt")
+	fs.StringVar(&o.language, "language", "", "Mailbox language as a locale, such as en-US or de-DE")
+}
+
+// ── rules ─────────────────────────────────────────────────────────────────────
+
+// rulesOptions are the flags of the rules actions.
+type rulesOptions struct {
+	rule, name, from, subjectContains, moveTo, set, to string
+	hasAttachment, markRead                            bool
+}
+
+func (o *rulesOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.rule, "rule", "", "Rule ID or name")
+	fs.StringVar(&o.name, "name", "", "Rule name")
+	fs.StringVar(&o.from, "from", "", "Condition: the sender is one of these addresses, comma-separated")
+	fs.StringVar(&o.subjectContains, "subject-contains", "", "Condition: the subject contains any of these comma-separated words")
+	fs.BoolVar(&o.hasAttachment, "has-attachment", false, "Condition: the message has an attachment")
+	fs.StringVar(&o.moveTo, "move-to", "", "Action: folder to move matching messages to")
+	fs.StringVar(&o.set, "set", "", "Action: comma-separated categories to apply")
+	fs.BoolVar(&o.markRead, "mark-read", false, "Action: mark matching messages read")
+	fs.StringVar(&o.to, "to", "", "Action: forward matching messages to these addresses, comma-separated")
+}
+
+// ── categories ────────────────────────────────────────────────────────────────
+
+// categoriesOptions are the flags of the categories actions.
+type categoriesOptions struct {
+	name, newName, color string
+}
+
+func (o *categoriesOptions) flags(fs *flag.FlagSet, action string) {
+	name := "Category name or ID"
+	if action == "create" {
+		name = "Category name"
+	}
+	fs.StringVar(&o.name, "name", "", name)
+	fs.StringVar(&o.newName, "new-name", "", "New name for the category")
+	fs.StringVar(&o.color, "color", "", "Category color: red, orange, ..., darkcranberry, preset0-preset24, or none")
+}
+
+// ── subscribe ─────────────────────────────────────────────────────────────────
+
+// subscribeOptions are the flags of the subscribe actions.
+type subscribeOptions struct {
+	ref, folder, resource, changeType, notificationURL string
+	expires                                            time.Duration
+	listen, tlsCert, tlsKey, forward                   string
+}
+
+func (o *subscribeOptions) flags(fs *flag.FlagSet, action string) {
+	ref := "Subscription reference: list index or subscription ID"
+	if action == "renew" {
+		ref += " (default: every saved subscription)"
+	}
+	fs.StringVar(&o.ref, "ref", "", ref)
+	fs.StringVar(&o.folder, "folder", "inbox", "With --resource=mail, the folder to watch; --folder= watches every folder")
+	fs.StringVar(&o.resource, "resource", "", "mail | calendar — what the subscription watches")
+	fs.StringVar(&o.changeType, "change-type", "created,updated,deleted", "Comma-separated changes to be notified of: created, updated, deleted")
+	fs.StringVar(&o.notificationURL, "notification-url", "", "Public HTTPS URL that reaches subscribe listen, such as a tunnel's")
+	fs.DurationVar(&o.expires, "expires", subscribe.DefaultExpiry, "How long the subscription lasts from now, at most about 7 days")
+	fs.StringVar(&o.listen, "listen", "127.0.0.1:8765", "Address to listen on")
+	fs.StringVar(&o.tlsCert, "tls-cert", "", "Certificate file to serve HTTPS with (with --tls-key)")
+	fs.StringVar(&o.tlsKey, "tls-key", "", "Private key file for --tls-cert")
+	fs.StringVar(&o.forward, "forward", "", "POST each notification as JSON to this URL instead of printing it")
+}
+
+// ── snippets ──────────────────────────────────────────────────────────────────
+
+// snippetsOptions are the flags of the snippets actions.
+type snippetsOptions struct {
+	bodyOptions // the snippet's Markdown, for add
+
+	name, file, vars, ref string
+}
+
+func (o *snippetsOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.name, "name", "", "Snippet name")
+	fs.StringVar(&o.body, "body", "", "Snippet text in Markdown; - reads it from stdin")
+	fs.StringVar(&o.file, "file", "", "Markdown file to read the snippet from, in place of --body")
+	fs.StringVar(&o.vars, "vars", "", "Snippet placeholder values: \"key=value;key=value\"")
+	fs.StringVar(&o.ref, "ref", "", "Message being replied to, for the placeholders describing it, such as {{sender}}")
+}
+
+// ── signature ─────────────────────────────────────────────────────────────────
+
+// signatureOptions are the flags of the signature actions.
+type signatureOptions struct {
+	bodyOptions
+
+	format string
+}
+
+func (o *signatureOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.body, "body", "", "Signature text; - reads it from stdin")
+	fs.StringVar(&o.bodyFile, "body-file", "", "File to read the signature from, in place of --body")
+	fs.StringVar(&o.format, "format", "", "Signature format: text, md (Markdown), or html (default: html if the text starts with a tag, else md)")
+}
+
+// ── schema and devtools ───────────────────────────────────────────────────────
+
+// schemaOptions are the flags of the schema actions.
+type schemaOptions struct {
+	name string
+}
+
+func (o *schemaOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.name, "name", "", "Output type to show, such as MessageList (default: all)")
+}
+
+// devtoolsOptions are the flags of the devtools actions.
+type devtoolsOptions struct {
+	listen string
+}
+
+func (o *devtoolsOptions) flags(fs *flag.FlagSet, action string) {
+	fs.StringVar(&o.listen, "listen", "127.0.0.1:8765", "Address to listen on")
+}
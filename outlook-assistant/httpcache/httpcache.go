@@ -0,0 +1,252 @@
+// Package httpcache revalidates repeated Graph GET requests, so unchanged
+// messages, events, contacts, and folder lists are served from disk instead
+// of being downloaded again. Single items are revalidated with their ETags;
+// a folder list, which has none, with a delta query on the folders.
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	khttp "github.com/microsoft/kiota-http-go"
+)
+
+// maxEntrySize bounds the size of a single cached response body.
+const maxEntrySize = 4 << 20
+
+// entry is one cached response, stored as JSON in its own file. A single
+// item is revalidated with its ETag, a folder list with its DeltaLink.
+type entry struct {
+	ETag        string `json:"etag,omitempty"`
+	DeltaLink   string `json:"deltaLink,omitempty"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
+// Cache is a Kiota middleware that stores GET responses carrying an ETag and
+// sends If-None-Match when the same URL is requested again. A 304 reply is
+// turned back into a 200 with the stored body, so callers never see it.
+// Folder lists are stored with a delta link instead, and served from disk
+// while the delta query reports no change to any folder. Every request is
+// still revalidated with Graph; nothing is served stale.
+type Cache struct {
+	dir       string
+	namespace string
+}
+
+// New returns a Cache storing entries in dir. namespace (e.g. tenant and
+// mailbox) is mixed into every key so different accounts never share entries.
+func New(dir, namespace string) *Cache {
+	return &Cache{dir: dir, namespace: namespace}
+}
+
+// DefaultDir returns ~/.outlook-assistant-cache.
+func DefaultDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".outlook-assistant-cache")
+}
+
+// Intercept implements khttp.Middleware.
+func (c *Cache) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" {
+		return pipeline.Next(req, middlewareIndex)
+	}
+
+	path := c.path(req)
+	if delta := folderDelta(req.URL); delta != "" {
+		return c.interceptFolders(pipeline, middlewareIndex, req, path, delta)
+	}
+	cached, hasCached := c.load(path)
+	if hasCached {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := pipeline.Next(req, middlewareIndex)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		resp.Body.Close()
+		serve(resp, cached)
+		return resp, nil
+	}
+
+	body, ok := peekBody(resp)
+	if !ok {
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		// Single entities carry their ETag in the body even when the header is absent.
+		var probe struct {
+			ETag string `json:"@odata.etag"`
+		}
+		if json.Unmarshal(body, &probe) == nil {
+			etag = probe.ETag
+		}
+	}
+	if etag != "" {
+		c.store(path, entry{ETag: etag, ContentType: resp.Header.Get("Content-Type"), Body: body})
+	}
+	return resp, nil
+}
+
+// interceptFolders answers a folder list request from the entry at path
+// while the delta query says no folder has changed since it was stored, and
+// otherwise sends req and stores the reply with a fresh delta link. delta is
+// the URL that starts a delta query for the folders req lists.
+func (c *Cache) interceptFolders(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request, path, delta string) (*http.Response, error) {
+	link := ""
+	if cached, ok := c.load(path); ok && cached.DeltaLink != "" {
+		next, changed, err := c.sync(pipeline, middlewareIndex, req, cached.DeltaLink)
+		if err == nil && !changed {
+			cached.DeltaLink = next
+			c.store(path, cached)
+			resp := &http.Response{Proto: req.Proto, ProtoMajor: req.ProtoMajor, ProtoMinor: req.ProtoMinor, Header: http.Header{}, Request: req}
+			serve(resp, cached)
+			return resp, nil
+		}
+		if err == nil {
+			link = next
+		}
+	}
+	if link == "" {
+		// Taken before the list, so that a change made in between is
+		// reported the next time rather than lost.
+		var err error
+		if link, _, err = c.sync(pipeline, middlewareIndex, req, delta); err != nil {
+			return pipeline.Next(req, middlewareIndex)
+		}
+	}
+
+	resp, err := pipeline.Next(req, middlewareIndex)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if body, ok := peekBody(resp); ok {
+		c.store(path, entry{DeltaLink: link, ContentType: resp.Header.Get("Content-Type"), Body: body})
+	}
+	return resp, nil
+}
+
+// sync follows a delta query from link through all of its pages, and returns
+// the delta link to resume from and whether any folder was reported changed.
+// Its requests carry req's headers, and with them its authorization.
+func (c *Cache) sync(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request, link string) (next string, changed bool, err error) {
+	for link != "" {
+		r, err := http.NewRequestWithContext(req.Context(), http.MethodGet, link, nil)
+		if err != nil {
+			return "", false, err
+		}
+		r.Header = req.Header.Clone()
+		resp, err := pipeline.Next(r, middlewareIndex)
+		if err != nil {
+			return "", false, err
+		}
+		var page struct {
+			Value     []json.RawMessage `json:"value"`
+			NextLink  string            `json:"@odata.nextLink"`
+			DeltaLink string            `json:"@odata.deltaLink"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			// An expired delta link (410 Gone) included.
+			return "", false, fmt.Errorf("folder delta query: %s", resp.Status)
+		}
+		if err != nil {
+			return "", false, err
+		}
+		changed = changed || len(page.Value) > 0
+		link, next = page.NextLink, page.DeltaLink
+	}
+	if next == "" {
+		return "", false, fmt.Errorf("folder delta query returned no delta link")
+	}
+	return next, changed, nil
+}
+
+// folderDelta returns the URL that starts a delta query over the folders
+// that u lists, selecting the same properties, or "" when u does not list a
+// mailbox's top-level folders.
+func folderDelta(u *url.URL) string {
+	if !strings.HasSuffix(u.Path, "/mailFolders") || u.Query().Has("$filter") {
+		return ""
+	}
+	delta := *u
+	delta.Path += "/delta"
+	query := url.Values{}
+	if sel := u.Query().Get("$select"); sel != "" {
+		query.Set("$select", sel)
+	}
+	delta.RawQuery = query.Encode()
+	return delta.String()
+}
+
+// serve makes resp a 200 carrying the body stored in e.
+func serve(resp *http.Response, e entry) {
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.Header.Set("Content-Type", e.ContentType)
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(e.Body))
+	resp.Body = io.NopCloser(bytes.NewReader(e.Body))
+}
+
+// peekBody reads the body of a 200 response so it can be stored, and leaves
+// it in place for the caller. ok is false when there is nothing to store,
+// as for another status or a body over maxEntrySize.
+func peekBody(resp *http.Response) (body []byte, ok bool) {
+	if resp.StatusCode != http.StatusOK || resp.Body == nil {
+		return nil, false
+	}
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxEntrySize+1))
+	rest := resp.Body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), rest), rest}
+	return body, readErr == nil && len(body) <= maxEntrySize
+}
+
+// path returns the file holding the entry for req. The Prefer header is part
+// of the key because it changes the representation (e.g. text vs HTML bodies).
+func (c *Cache) path(req *http.Request) string {
+	sum := sha256.Sum256([]byte(c.namespace + "\n" + req.URL.String() + "\n" + req.Header.Get("Prefer")))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) load(path string) (entry, bool) {
+	var e entry
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &e) != nil || e.ETag == "" && e.DeltaLink == "" {
+		return entry{}, false
+	}
+	return e, true
+}
+
+// store writes an entry, ignoring failures: the cache is only an optimisation.
+func (c *Cache) store(path string, e entry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(c.dir, 0700) != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,108 @@
+// Package jsonrpc is the JSON-RPC 2.0 transport shared by the servers behind
+// --serve: one message per line on stdin, one response per line on stdout.
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// Error codes defined by JSON-RPC 2.0. ServerError is the first of the range
+// left to the application; the servers use it for a failed action.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	ServerError    = -32000
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Handler answers one request. params is empty when the request had none.
+type Handler func(ctx context.Context, method string, params json.RawMessage) (interface{}, *Error)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Serve reads requests from in and writes handle's responses to out until in
+// ends or ctx is cancelled. Requests are handled one at a time, in order:
+// actions share the index caches on disk, so a list and a --ref that follows
+// it must not run concurrently. Notifications, and responses the client sends
+// back, get no reply.
+func Serve(ctx context.Context, in io.Reader, out io.Writer, handle Handler) error {
+	reader := bufio.NewReader(in)
+	for ctx.Err() == nil {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if resp := dispatch(ctx, line, handle); resp != nil {
+				data, merr := json.Marshal(resp)
+				if merr != nil {
+					return merr
+				}
+				if _, werr := out.Write(append(data, '\n')); werr != nil {
+					return werr
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func dispatch(ctx context.Context, line []byte, handle Handler) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: ParseError, Message: "parse error: " + err.Error()}}
+	}
+	if len(req.ID) == 0 {
+		slog.Debug("JSON-RPC notification", "method", req.Method)
+		return nil
+	}
+	if req.Method == "" {
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" {
+		resp.Error = &Error{Code: InvalidRequest, Message: `jsonrpc must be "2.0"`}
+		return resp
+	}
+	result, rpcErr := handle(ctx, req.Method, req.Params)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+		return resp
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	resp.Result = result
+	return resp
+}
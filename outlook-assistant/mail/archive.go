@@ -0,0 +1,431 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/progress"
+)
+
+// ---------- Folder archive ----------
+
+// ArchiveOptions controls ExportFolder. Exactly one of Mbox and Maildir is
+// set.
+type ArchiveOptions struct {
+	Folder      string
+	Mbox        string // write the folder to this mbox file
+	Maildir     string // or to this maildir directory
+	Concurrency int    // messages downloaded at once
+}
+
+// ArchiveSummary is the JSON representation of a finished `mail
+// export-folder`.
+type ArchiveSummary struct {
+	Folder     string `json:"folder"`
+	Format     string `json:"format"` // mbox or maildir
+	Path       string `json:"path"`
+	Total      int    `json:"total"`    // messages in the folder
+	Exported   int    `json:"exported"` // written by this run
+	Skipped    int    `json:"skipped"`  // written by an earlier run
+	Failed     int    `json:"failed"`   // could not be downloaded; a rerun retries them
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+}
+
+// maxConcurrency caps the parallel downloads; Graph throttles a mailbox
+// that is sent more than a few requests at once.
+const maxConcurrency = 16
+
+// archiveMessage is a message to export, as listed from the folder.
+type archiveMessage struct {
+	id       string
+	from     string
+	received time.Time
+	read     bool
+}
+
+// downloaded is the MIME content of a message, or the error fetching it.
+type downloaded struct {
+	msg  archiveMessage
+	data []byte
+	err  error
+}
+
+// ExportFolder writes every message in a folder, as raw MIME, to an mbox file
+// or a maildir. Messages are downloaded opts.Concurrency at a time, and each
+// one written is recorded in a progress log beside the archive, so an
+// interrupted export resumes where it stopped, and a later run adds only the
+// messages that arrived since.
+func ExportFolder(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, opts ArchiveOptions, jsonOutput bool) error {
+	if (opts.Mbox == "") == (opts.Maildir == "") {
+		return fmt.Errorf("give exactly one of --out=<file.mbox> or --maildir=<dir>")
+	}
+	if opts.Concurrency < 1 || opts.Concurrency > maxConcurrency {
+		return fmt.Errorf("--concurrency must be between 1 and %d", maxConcurrency)
+	}
+	summary := ArchiveSummary{Folder: opts.Folder, StartedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	folderID, err := resolveFolderID(ctx, client, opts.Folder)
+	if err != nil {
+		return err
+	}
+	var w archiveWriter
+	if opts.Mbox != "" {
+		summary.Format, summary.Path = "mbox", opts.Mbox
+		w, err = openMbox(opts.Mbox, folderID)
+	} else {
+		summary.Format, summary.Path = "maildir", opts.Maildir
+		w, err = openMaildir(opts.Maildir, folderID)
+	}
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	messages, err := archiveList(ctx, client, folderID)
+	if err != nil {
+		return err
+	}
+	summary.Total = len(messages)
+	var todo []archiveMessage
+	for _, m := range messages {
+		if w.Done(m.id) {
+			summary.Skipped++
+			continue
+		}
+		todo = append(todo, m)
+	}
+	if summary.Skipped > 0 {
+		slog.Info("Resuming export", "done", summary.Skipped, "remaining", len(todo))
+	}
+
+	// work stops the downloads early if the archive cannot be written.
+	work, stop := context.WithCancel(ctx)
+	defer stop()
+	results := make(chan downloaded)
+	jobs := make(chan archiveMessage)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				data, err := messageMIME(work, client, m.id)
+				results <- downloaded{msg: m, data: data, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, m := range todo {
+			select {
+			case jobs <- m:
+			case <-work.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var writeErr error
+	report := progress.New("Exporting messages")
+	for r := range results {
+		if writeErr != nil {
+			continue // drain the workers
+		}
+		if r.err != nil {
+			if work.Err() == nil {
+				slog.Warn("Could not download a message", "id", r.msg.id, "error", r.err)
+				summary.Failed++
+			}
+			continue
+		}
+		if writeErr = w.Write(r.msg, r.data); writeErr != nil {
+			stop()
+			continue
+		}
+		summary.Exported++
+		report.Report("exported", summary.Skipped+summary.Exported, "of", summary.Total)
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("export interrupted after %d of %d messages — run it again to resume", summary.Skipped+summary.Exported, summary.Total)
+	}
+
+	summary.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	slog.Info("Folder exported", "path", summary.Path, "exported", summary.Exported, "skipped", summary.Skipped, "failed", summary.Failed)
+	if jsonOutput {
+		if err := printJSON(summary); err != nil {
+			return err
+		}
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d messages could not be downloaded — run the export again to retry them", summary.Failed, summary.Total)
+	}
+	return nil
+}
+
+// archiveList returns every message in a folder, oldest first, so an archive
+// reads in the order the mail arrived.
+func archiveList(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderID string) ([]archiveMessage, error) {
+	top := exportPageSize
+	config := &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+			Select:  []string{"id", "from", "receivedDateTime", "isRead"},
+			Orderby: []string{"receivedDateTime asc"},
+			Top:     &top,
+		},
+	}
+	var messages []archiveMessage
+	report := progress.New("Listing messages")
+	builder := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages()
+	for page := 1; ; page++ {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("listing messages (page %d): %w", page, err)
+		}
+		for _, msg := range result.GetValue() {
+			m := archiveMessage{id: deref(msg.GetId(), ""), from: senderAddress(msg)}
+			if t := msg.GetReceivedDateTime(); t != nil {
+				m.received = t.UTC()
+			}
+			m.read = msg.GetIsRead() != nil && *msg.GetIsRead()
+			messages = append(messages, m)
+		}
+		report.Report("listed", len(messages))
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+	return messages, nil
+}
+
+// archiveWriter adds messages to an archive and records them in its progress
+// log.
+type archiveWriter interface {
+	Done(id string) bool
+	Write(m archiveMessage, mime []byte) error
+	Close() error
+}
+
+// progressLog is the record of an export, one line per message written:
+// its ID and, for an mbox, the length of the file after it. The first line
+// names the folder, so an archive is not resumed from another one.
+type progressLog struct {
+	path string
+	log  *os.File
+	done map[string]bool
+	size int64 // the mbox length after the last message recorded
+}
+
+// openProgress reads the progress log at path, if any, and opens it for
+// appending.
+func openProgress(path, folderID string) (*progressLog, bool, error) {
+	p := &progressLog{path: path, done: map[string]bool{}}
+	data, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if existed {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for first := true; scanner.Scan(); first = false {
+			fields := strings.Split(scanner.Text(), "\t")
+			if first {
+				if len(fields) != 2 || fields[0] != "folder" || fields[1] != folderID {
+					return nil, false, fmt.Errorf("%s records an export of another folder — export to a new file or directory", path)
+				}
+				continue
+			}
+			// A line cut short by an interruption has no size, and its
+			// message is written again.
+			if len(fields) != 2 {
+				continue
+			}
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			p.done[fields[0]] = true
+			p.size = size
+		}
+	}
+	if p.log, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return nil, false, fmt.Errorf("opening %s: %w", path, err)
+	}
+	if !existed || len(data) == 0 {
+		if _, err := fmt.Fprintf(p.log, "folder\t%s\n", folderID); err != nil {
+			p.log.Close()
+			return nil, false, fmt.Errorf("writing %s: %w", path, err)
+		}
+	} else if !bytes.HasSuffix(data, []byte("\n")) {
+		p.log.WriteString("\n")
+	}
+	return p, existed, nil
+}
+
+// Done reports whether the message id was written by an earlier run.
+func (p *progressLog) Done(id string) bool { return p.done[id] }
+
+// record notes that id was written, leaving the mbox size after it.
+func (p *progressLog) record(id string, size int64) error {
+	if _, err := fmt.Fprintf(p.log, "%s\t%d\n", id, size); err != nil {
+		return fmt.Errorf("writing %s: %w", p.path, err)
+	}
+	p.done[id] = true
+	return nil
+}
+
+// ── mbox ──
+
+type mboxWriter struct {
+	*progressLog
+	file *os.File
+	size int64
+}
+
+// openMbox opens an mbox for writing. An mbox with a progress log is cut back
+// to the last message the log records, dropping one cut short; one without
+// is not touched, as it was not written by an export.
+func openMbox(path, folderID string) (*mboxWriter, error) {
+	progress, resumed, err := openProgress(path+".progress", folderID)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(path)
+	switch {
+	case err == nil && !resumed:
+		progress.log.Close()
+		os.Remove(progress.path)
+		return nil, fmt.Errorf("%s already exists — remove it, or choose another --out", path)
+	case resumed && (err != nil || st.Size() < progress.size):
+		progress.log.Close()
+		return nil, fmt.Errorf("%s is missing or shorter than %s records — remove both to start over", path, progress.path)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		progress.log.Close()
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	if err := file.Truncate(progress.size); err != nil {
+		file.Close()
+		progress.log.Close()
+		return nil, fmt.Errorf("resuming %s: %w", path, err)
+	}
+	if _, err := file.Seek(progress.size, 0); err != nil {
+		file.Close()
+		progress.log.Close()
+		return nil, fmt.Errorf("resuming %s: %w", path, err)
+	}
+	return &mboxWriter{progressLog: progress, file: file, size: progress.size}, nil
+}
+
+// Write appends a message in mboxrd form: a "From " separator line with the
+// sender and arrival time, then the message with LF line endings and every
+// line that starts with ">*From " quoted with one more ">".
+func (w *mboxWriter) Write(m archiveMessage, mime []byte) error {
+	from := m.from
+	if from == "" {
+		from = "MAILER-DAEMON"
+	}
+	received := m.received
+	if received.IsZero() {
+		received = time.Unix(0, 0).UTC()
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From %s %s\n", from, received.Format("Mon Jan _2 15:04:05 2006"))
+	body := bytes.ReplaceAll(mime, []byte("\r\n"), []byte("\n"))
+	for _, line := range bytes.SplitAfter(body, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			b.WriteByte('>')
+		}
+		b.Write(line)
+	}
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	n, err := w.file.Write(b.Bytes())
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", w.file.Name(), err)
+	}
+	return w.record(m.id, w.size)
+}
+
+func (w *mboxWriter) Close() error {
+	w.progressLog.log.Close()
+	return w.file.Close()
+}
+
+// ── maildir ──
+
+type maildirWriter struct {
+	*progressLog
+	dir string
+}
+
+// openMaildir creates the maildir's tmp, new, and cur directories as needed.
+// A maildir can hold mail from elsewhere, so an existing one is added to.
+func openMaildir(dir, folderID string) (*maildirWriter, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("creating %s: %w", dir, err)
+		}
+	}
+	progress, _, err := openProgress(filepath.Join(dir, ".outlook-assistant-progress"), folderID)
+	if err != nil {
+		return nil, err
+	}
+	return &maildirWriter{progressLog: progress, dir: dir}, nil
+}
+
+// Write stores a message in cur, through tmp as maildir readers expect. The
+// file name is made from the message ID, so writing a message again replaces
+// it; the S flag marks a message that was read.
+func (w *maildirWriter) Write(m archiveMessage, mime []byte) error {
+	sum := sha1.Sum([]byte(m.id))
+	name := fmt.Sprintf("%d.%s.outlook-assistant", m.received.Unix(), hex.EncodeToString(sum[:10]))
+	flags := ":2,"
+	if m.read {
+		flags += "S"
+	}
+	tmp := filepath.Join(w.dir, "tmp", name)
+	if err := os.WriteFile(tmp, bytes.ReplaceAll(mime, []byte("\r\n"), []byte("\n")), 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(w.dir, "cur", name+flags)); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return w.record(m.id, 0)
+}
+
+func (w *maildirWriter) Close() error {
+	return w.progressLog.log.Close()
+}
@@ -0,0 +1,435 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/progress"
+)
+
+// ---------- Attachments ----------
+
+const (
+	// inlineAttachmentLimit is the most file content sent in a single
+	// request. A sendMail request is limited to 4 MB, and base64 makes the
+	// files a third larger; anything more goes through a draft, with files
+	// above the limit uploaded in slices through upload sessions.
+	inlineAttachmentLimit = 3 * 1024 * 1024
+	// maxAttachmentSize is the largest attachment Outlook accepts.
+	maxAttachmentSize = 150 * 1024 * 1024
+	// uploadSliceSize must be a multiple of 320 KiB.
+	uploadSliceSize = 10 * 320 * 1024
+	// uploadResumes is how many times an upload that still has gaps after
+	// the per-slice retries is resumed from what the session has received.
+	uploadResumes = 3
+)
+
+// AttachmentInfo is the JSON representation of a message attachment. File is
+// set when the attachment was saved with --save-dir.
+type AttachmentInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"` // file, item, or reference
+	ContentType string `json:"contentType,omitempty"`
+	Size        int32  `json:"size"`
+	IsInline    bool   `json:"isInline,omitempty"`
+	ContentID   string `json:"contentId,omitempty"` // what the body's cid: references name, for an inline image
+	File        string `json:"file,omitempty"`
+}
+
+// Attachments lists the attachments of the message identified by ref (list
+// index or Graph ID). With saveDir, they are also downloaded there.
+func Attachments(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, saveDir string, jsonOutput bool) error {
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+	infos, err := messageAttachments(ctx, client, messageID, saveDir)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(infos)
+	}
+	if len(infos) == 0 {
+		fmt.Println("No attachments.")
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-45s  %-9s  %10s  %s\n", "#", "Name", "Kind", "Size", "Saved to")
+	fmt.Println(strings.Repeat("-", 100))
+	for i, a := range infos {
+		fmt.Printf("%-3d  %-45s  %-9s  %7d KB  %s\n", i+1, truncate(a.Name, 45), a.Kind, (a.Size+1023)/1024, a.File)
+	}
+	return nil
+}
+
+// messageAttachments lists the attachments of a message. With saveDir, each
+// is also saved there: a file under its own name, and an attached Outlook
+// item (a message, event, or contact) as a MIME .eml file. Cloud links have
+// no content of their own and are only listed.
+func messageAttachments(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, messageID, saveDir string) ([]AttachmentInfo, error) {
+	builder := mailbox.Of(client).Messages().ByMessageId(messageID).Attachments()
+	result, err := builder.Get(ctx, &users.ItemMessagesItemAttachmentsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesItemAttachmentsRequestBuilderGetQueryParameters{
+			Select: []string{"id", "name", "contentType", "size", "isInline"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments: %w", err)
+	}
+
+	if saveDir != "" {
+		if err := os.MkdirAll(saveDir, 0700); err != nil {
+			return nil, fmt.Errorf("creating %s: %w", saveDir, err)
+		}
+	}
+
+	infos := []AttachmentInfo{}
+	used := map[string]bool{}
+	for i, a := range result.GetValue() {
+		info := AttachmentInfo{
+			ID:          deref(a.GetId(), ""),
+			Name:        deref(a.GetName(), ""),
+			Kind:        attachmentKind(a),
+			ContentType: deref(a.GetContentType(), ""),
+			IsInline:    a.GetIsInline() != nil && *a.GetIsInline(),
+		}
+		if a.GetSize() != nil {
+			info.Size = *a.GetSize()
+		}
+
+		if saveDir != "" && (info.Kind == "file" || info.Kind == "item") {
+			var data []byte
+			name := info.Name
+			if info.Kind == "file" {
+				full, err := builder.ByAttachmentId(info.ID).Get(ctx, nil)
+				if err != nil {
+					return nil, fmt.Errorf("downloading %s: %w", info.Name, err)
+				}
+				file, ok := full.(models.FileAttachmentable)
+				if !ok {
+					return nil, fmt.Errorf("downloading %s: not a file attachment", info.Name)
+				}
+				data = file.GetContentBytes()
+			} else {
+				if data, err = attachmentMIME(ctx, client, messageID, info.ID); err != nil {
+					return nil, fmt.Errorf("downloading %s: %w", info.Name, err)
+				}
+				if !strings.EqualFold(filepath.Ext(name), ".eml") {
+					name += ".eml"
+				}
+			}
+			path := filepath.Join(saveDir, uniqueName(name, i+1, used))
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", path, err)
+			}
+			info.File = path
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// saveInlineImages saves the inline file attachments of a message, the images
+// an HTML body shows through cid: references, to dir. used holds the names
+// already taken there, so the images of several messages can share dir.
+func saveInlineImages(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, messageID, dir string, used map[string]bool) ([]AttachmentInfo, error) {
+	builder := mailbox.Of(client).Messages().ByMessageId(messageID).Attachments()
+	result, err := builder.Get(ctx, &users.ItemMessagesItemAttachmentsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesItemAttachmentsRequestBuilderGetQueryParameters{
+			Select: []string{"id", "name", "contentType", "size", "isInline"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments: %w", err)
+	}
+
+	infos := []AttachmentInfo{}
+	for i, a := range result.GetValue() {
+		if a.GetIsInline() == nil || !*a.GetIsInline() || attachmentKind(a) != "file" {
+			continue
+		}
+		if len(infos) == 0 {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return nil, fmt.Errorf("creating %s: %w", dir, err)
+			}
+		}
+		full, err := builder.ByAttachmentId(deref(a.GetId(), "")).Get(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("downloading %s: %w", deref(a.GetName(), ""), err)
+		}
+		file, ok := full.(models.FileAttachmentable)
+		if !ok {
+			return nil, fmt.Errorf("downloading %s: not a file attachment", deref(a.GetName(), ""))
+		}
+		info := AttachmentInfo{
+			ID:          deref(a.GetId(), ""),
+			Name:        deref(a.GetName(), ""),
+			Kind:        "file",
+			ContentType: deref(a.GetContentType(), ""),
+			IsInline:    true,
+			ContentID:   strings.Trim(deref(file.GetContentId(), ""), "<>"),
+		}
+		if a.GetSize() != nil {
+			info.Size = *a.GetSize()
+		}
+		path := filepath.Join(dir, uniqueName(info.Name, i+1, used))
+		if err := os.WriteFile(path, file.GetContentBytes(), 0600); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", path, err)
+		}
+		info.File = path
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// imagePaths maps the content IDs of saved inline images to their files.
+func imagePaths(images []AttachmentInfo) map[string]string {
+	paths := map[string]string{}
+	for _, img := range images {
+		if img.ContentID != "" && img.File != "" {
+			paths[strings.ToLower(img.ContentID)] = img.File
+		}
+	}
+	return paths
+}
+
+var (
+	imgTag = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	cidSrc = regexp.MustCompile(`(?is)\bsrc\s*=\s*(?:"cid:([^"]*)"|'cid:([^']*)'|cid:([^\s>]+))`)
+)
+
+// linkImages points the cid: images of an HTML body at the files in paths.
+// For Markdown the image keeps its tag with the file as its src; for plain
+// text, which drops images, the tag becomes an [image: <file>] line. Images
+// whose content ID is not in paths are left as they are.
+func linkImages(body string, paths map[string]string, markdown bool) string {
+	if len(paths) == 0 {
+		return body
+	}
+	return imgTag.ReplaceAllStringFunc(body, func(tag string) string {
+		m := cidSrc.FindStringSubmatch(tag)
+		if m == nil {
+			return tag
+		}
+		id := html.UnescapeString(m[1] + m[2] + m[3])
+		if unescaped, err := url.PathUnescape(id); err == nil {
+			id = unescaped
+		}
+		path, ok := paths[strings.ToLower(strings.Trim(id, "<>"))]
+		if !ok {
+			return tag
+		}
+		if !markdown {
+			return "\n[image: " + path + "]\n"
+		}
+		return strings.Replace(tag, m[0], `src="`+html.EscapeString(path)+`"`, 1)
+	})
+}
+
+// attachmentMIME returns the raw MIME content of an attachment, which for an
+// attached Outlook item is the item in RFC 822 form. The SDK has no request
+// builder for an attachment's $value, so the request is built by hand.
+func attachmentMIME(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, messageID, attachmentID string) ([]byte, error) {
+	adapter := client.GetAdapter()
+	u, err := url.Parse(adapter.GetBaseUrl() + "/" + mailbox.Path() + "/messages/" + url.PathEscape(messageID) +
+		"/attachments/" + url.PathEscape(attachmentID) + "/$value")
+	if err != nil {
+		return nil, err
+	}
+	info := abstractions.NewRequestInformation()
+	info.Method = abstractions.GET
+	info.SetUri(*u)
+
+	raw, err := adapter.SendPrimitive(ctx, info, "[]byte", abstractions.ErrorMappings{
+		"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, _ := raw.([]byte)
+	return data, nil
+}
+
+func attachmentKind(a models.Attachmentable) string {
+	switch a.(type) {
+	case models.FileAttachmentable:
+		return "file"
+	case models.ItemAttachmentable:
+		return "item"
+	case models.ReferenceAttachmentable:
+		return "reference"
+	}
+	return strings.TrimPrefix(deref(a.GetOdataType(), ""), "#microsoft.graph.")
+}
+
+// uniqueName returns a safe file name for an attachment, numbering repeats so
+// two attachments with the same name don't overwrite each other.
+func uniqueName(name string, n int, used map[string]bool) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = fmt.Sprintf("attachment-%d", n)
+	}
+	ext := filepath.Ext(name)
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// fileAttachments reads the comma-separated paths for --attach. Files small
+// enough to send in one request are returned as attachments, with their
+// combined size; the paths of larger ones are returned for attachLarge.
+func fileAttachments(paths string) ([]models.Attachmentable, []string, int64, error) {
+	var small []models.Attachmentable
+	var large []string
+	var smallSize int64
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if st.Size() > maxAttachmentSize {
+			return nil, nil, 0, fmt.Errorf("%s is %d bytes; attachments are limited to 150 MB", path, st.Size())
+		}
+		if st.Size() > inlineAttachmentLimit {
+			large = append(large, path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("reading %s: %w", path, err)
+		}
+		name := filepath.Base(path)
+		contentType := contentTypeOf(name)
+		a := models.NewFileAttachment()
+		a.SetName(&name)
+		a.SetContentType(&contentType)
+		a.SetContentBytes(data)
+		small = append(small, a)
+		smallSize += st.Size()
+	}
+	return small, large, smallSize, nil
+}
+
+// sendWithUploads sends a message whose attachments do not fit in one
+// sendMail request. It is saved as a draft, the files are attached one at a
+// time, the large ones through upload sessions, and the draft is sent. A
+// draft left by a failure is deleted, so a retry does not leave a second one.
+func sendWithUploads(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, message models.Messageable, small []models.Attachmentable, large []string) (err error) {
+	draft, err := mailbox.Of(client).Messages().Post(ctx, message, nil)
+	if err != nil {
+		return fmt.Errorf("creating draft: %w", err)
+	}
+	draftID := deref(draft.GetId(), "")
+	defer func() {
+		if err == nil {
+			return
+		}
+		if delErr := mailbox.Of(client).Messages().ByMessageId(draftID).Delete(context.Background(), nil); delErr != nil {
+			slog.Warn("Could not delete the unsent draft; remove it from Drafts", "error", delErr)
+		}
+	}()
+
+	builder := mailbox.Of(client).Messages().ByMessageId(draftID).Attachments()
+	for _, a := range small {
+		if _, err := builder.Post(ctx, a, nil); err != nil {
+			return fmt.Errorf("attaching %s: %w", deref(a.GetName(), ""), err)
+		}
+	}
+	for _, path := range large {
+		if err := attachLarge(ctx, client, draftID, path); err != nil {
+			return err
+		}
+	}
+
+	if err := mailbox.Of(client).Messages().ByMessageId(draftID).Send().Post(ctx, nil); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return nil
+}
+
+// attachLarge uploads a file of more than 3 MB to a draft in slices. Each
+// slice is retried on its own; if any still fail, the upload is resumed from
+// the ranges the session reports as missing rather than started over.
+func attachLarge(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, messageID, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	name := filepath.Base(path)
+	contentType := contentTypeOf(name)
+	size := st.Size()
+	attachmentType := models.FILE_ATTACHMENTTYPE
+	item := models.NewAttachmentItem()
+	item.SetAttachmentType(&attachmentType)
+	item.SetName(&name)
+	item.SetContentType(&contentType)
+	item.SetSize(&size)
+	body := users.NewItemMessagesItemAttachmentsCreateUploadSessionPostRequestBody()
+	body.SetAttachmentItem(item)
+
+	session, err := mailbox.Of(client).Messages().ByMessageId(messageID).Attachments().CreateUploadSession().Post(ctx, body, nil)
+	if err != nil {
+		return fmt.Errorf("starting upload of %s: %w", name, err)
+	}
+	task := fileuploader.NewLargeFileUploadTask[models.Attachmentable](
+		client.GetAdapter(), session, f, uploadSliceSize,
+		models.CreateAttachmentFromDiscriminatorValue,
+		abstractions.ErrorMappings{"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue},
+	)
+	report := progress.New("Uploading attachment")
+	uploaded := func(current, total int64) {
+		report.Report("file", name, "percent", (current+1)*100/total)
+	}
+	result := task.Upload(uploaded)
+	for attempt := 1; !result.GetUploadSucceeded() && attempt <= uploadResumes; attempt++ {
+		slog.Debug("Resuming upload", "file", name, "attempt", attempt, "error", errors.Join(result.GetResponseErrors()...))
+		resumed, err := task.Resume(uploaded)
+		if err != nil {
+			return fmt.Errorf("resuming upload of %s: %w", name, err)
+		}
+		result = resumed
+	}
+	if !result.GetUploadSucceeded() {
+		return fmt.Errorf("uploading %s: %w", name, errors.Join(result.GetResponseErrors()...))
+	}
+	return nil
+}
+
+func contentTypeOf(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,302 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Authentication check ----------
+
+// AuthResult is one SPF, DKIM, or DMARC outcome. Domain is the domain the
+// check was made for: smtp.mailfrom for SPF, header.d for DKIM, header.from
+// for DMARC.
+type AuthResult struct {
+	Result string `json:"result"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// DKIMSignature is the signing domain and selector of a DKIM-Signature header.
+type DKIMSignature struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector,omitempty"`
+}
+
+// AuthReport is the JSON representation of `mail authcheck`.
+type AuthReport struct {
+	ID          string          `json:"id"`
+	Subject     string          `json:"subject"`
+	From        string          `json:"from"`
+	FromName    string          `json:"fromName,omitempty"`
+	Sender      string          `json:"sender,omitempty"`
+	ReplyTo     []string        `json:"replyTo,omitempty"`
+	ReturnPath  string          `json:"returnPath,omitempty"`
+	SPF         *AuthResult     `json:"spf,omitempty"`
+	DKIM        []AuthResult    `json:"dkim,omitempty"`
+	DMARC       *AuthResult     `json:"dmarc,omitempty"`
+	CompAuth    string          `json:"compauth,omitempty"` // Microsoft's composite verdict
+	Signatures  []DKIMSignature `json:"signatures,omitempty"`
+	Verdict     string          `json:"verdict"` // pass, warn, or fail
+	Warnings    []string        `json:"warnings"`
+	HeaderCount int             `json:"headerCount"`
+}
+
+var (
+	// authMethod matches "spf=pass", "dkim=fail (…)", "dmarc=bestguesspass".
+	authMethod = regexp.MustCompile(`^\s*([a-z]+)\s*=\s*([a-z]+)`)
+	// authProperty matches "smtp.mailfrom=example.com", "header.d=example.com",
+	// and "action=none".
+	authProperty = regexp.MustCompile(`(smtp\.mailfrom|header\.d|header\.from|header\.i|action)=([^\s;()]+)`)
+	// emailInName finds an address inside a display name, a common spoof.
+	emailInName = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+	// dkimTag matches a "tag=value" pair in a DKIM-Signature header.
+	dkimTag = regexp.MustCompile(`(?:^|;)\s*([a-z]+)\s*=\s*([^;]*)`)
+)
+
+// AuthCheck reads a message's transport headers and reports its SPF, DKIM, and
+// DMARC outcomes as recorded by the receiving server, plus warnings for the
+// usual signs of spoofing: failed or missing checks, a From domain that the
+// checks do not align with, and Reply-To, Sender, or display-name addresses
+// that point somewhere else. It is a triage aid; Exchange's own verdict is in
+// compauth.
+func AuthCheck(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, jsonOutput bool) error {
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject", "from", "sender", "replyTo", "internetMessageHeaders"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading message: %w", err)
+	}
+
+	report := analyzeAuth(msg)
+	if jsonOutput {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nSubject : %s\n", report.Subject)
+	fmt.Printf("From    : %s <%s>\n", report.FromName, report.From)
+	if report.ReturnPath != "" {
+		fmt.Printf("Return  : %s\n", report.ReturnPath)
+	}
+	fmt.Println(strings.Repeat("-", 60))
+	show := func(label string, r *AuthResult) {
+		if r == nil {
+			fmt.Printf("%-6s  (not reported)\n", label)
+			return
+		}
+		fmt.Printf("%-6s  %-10s %s\n", label, r.Result, r.Domain)
+	}
+	show("SPF", report.SPF)
+	if len(report.DKIM) == 0 {
+		show("DKIM", nil)
+	}
+	for i := range report.DKIM {
+		show("DKIM", &report.DKIM[i])
+	}
+	show("DMARC", report.DMARC)
+	if report.CompAuth != "" {
+		fmt.Printf("%-6s  %s\n", "compauth", report.CompAuth)
+	}
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("Verdict : %s\n", strings.ToUpper(report.Verdict))
+	for _, w := range report.Warnings {
+		fmt.Printf("  ! %s\n", w)
+	}
+	return nil
+}
+
+// analyzeAuth builds the report for a message fetched with its headers.
+func analyzeAuth(msg models.Messageable) AuthReport {
+	report := AuthReport{
+		ID:       deref(msg.GetId(), ""),
+		Subject:  deref(msg.GetSubject(), ""),
+		From:     strings.ToLower(senderAddress(msg)),
+		Warnings: []string{},
+	}
+	if msg.GetFrom() != nil && msg.GetFrom().GetEmailAddress() != nil {
+		report.FromName = deref(msg.GetFrom().GetEmailAddress().GetName(), "")
+	}
+	if s := msg.GetSender(); s != nil && s.GetEmailAddress() != nil {
+		if addr := strings.ToLower(deref(s.GetEmailAddress().GetAddress(), "")); addr != report.From {
+			report.Sender = addr
+		}
+	}
+	for _, r := range msg.GetReplyTo() {
+		if r.GetEmailAddress() != nil {
+			report.ReplyTo = append(report.ReplyTo, strings.ToLower(deref(r.GetEmailAddress().GetAddress(), "")))
+		}
+	}
+
+	headers := msg.GetInternetMessageHeaders()
+	report.HeaderCount = len(headers)
+	authSeen := false
+	for _, h := range headers {
+		name, value := strings.ToLower(deref(h.GetName(), "")), deref(h.GetValue(), "")
+		switch name {
+		case "authentication-results":
+			// The receiving server adds its header on top; later ones were
+			// added by earlier hops and are not trusted.
+			if !authSeen {
+				authSeen = true
+				parseAuthResults(value, &report)
+			}
+		case "received-spf":
+			if report.SPF == nil {
+				if fields := strings.Fields(value); len(fields) > 0 {
+					report.SPF = &AuthResult{Result: strings.ToLower(fields[0])}
+				}
+			}
+		case "dkim-signature":
+			sig := DKIMSignature{}
+			for _, m := range dkimTag.FindAllStringSubmatch(value, -1) {
+				switch m[1] {
+				case "d":
+					sig.Domain = strings.ToLower(strings.TrimSpace(m[2]))
+				case "s":
+					sig.Selector = strings.TrimSpace(m[2])
+				}
+			}
+			if sig.Domain != "" {
+				report.Signatures = append(report.Signatures, sig)
+			}
+		case "return-path":
+			report.ReturnPath = strings.ToLower(strings.Trim(strings.TrimSpace(value), "<>"))
+		}
+	}
+
+	authWarnings(&report)
+	return report
+}
+
+// parseAuthResults reads an Authentication-Results header value:
+// "[authserv-id;] spf=pass (…) smtp.mailfrom=x.com; dkim=pass header.d=x.com; …".
+// Exchange Online leaves out the authserv-id; other servers put it first.
+func parseAuthResults(value string, report *AuthReport) {
+	for _, part := range strings.Split(value, ";") {
+		m := authMethod.FindStringSubmatch(strings.ToLower(part))
+		if m == nil {
+			continue
+		}
+		props := map[string]string{}
+		for _, p := range authProperty.FindAllStringSubmatch(strings.ToLower(part), -1) {
+			if v := strings.Trim(p[2], "\""); v != "none" {
+				props[p[1]] = v
+			}
+		}
+		switch m[1] {
+		case "spf":
+			report.SPF = &AuthResult{Result: m[2], Domain: props["smtp.mailfrom"]}
+			if _, domain, ok := strings.Cut(report.SPF.Domain, "@"); ok {
+				report.SPF.Domain = domain
+			}
+		case "dkim":
+			report.DKIM = append(report.DKIM, AuthResult{Result: m[2], Domain: props["header.d"]})
+		case "dmarc":
+			report.DMARC = &AuthResult{Result: m[2], Domain: props["header.from"]}
+		case "compauth":
+			report.CompAuth = m[2]
+		}
+	}
+}
+
+// authWarnings fills in the warnings and the verdict.
+func authWarnings(r *AuthReport) {
+	warn := func(format string, args ...interface{}) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+	}
+	fromDomain := domainOf(r.From)
+
+	if r.HeaderCount == 0 {
+		warn("no transport headers — the message was probably sent inside your organization or saved as a draft")
+	} else if r.SPF == nil && r.DKIM == nil && r.DMARC == nil {
+		warn("no Authentication-Results header — the receiving server did not record any checks")
+	}
+	if r.SPF != nil && r.SPF.Result != "pass" {
+		warn("SPF %s: the sending server is not authorized for %s", r.SPF.Result, orUnknown(r.SPF.Domain))
+	}
+	dkimPass := false
+	for _, d := range r.DKIM {
+		if d.Result == "pass" {
+			dkimPass = true
+			if !sameOrgDomain(d.Domain, fromDomain) && d.Domain != "" {
+				warn("DKIM signature is from %s, not the From domain %s", d.Domain, fromDomain)
+			}
+		}
+	}
+	if len(r.DKIM) > 0 && !dkimPass {
+		warn("DKIM %s: no valid signature", r.DKIM[0].Result)
+	}
+	if r.DMARC != nil && r.DMARC.Result != "pass" && r.DMARC.Result != "bestguesspass" {
+		warn("DMARC %s for %s", r.DMARC.Result, orUnknown(r.DMARC.Domain))
+	}
+	if r.SPF != nil && r.SPF.Domain != "" && !sameOrgDomain(r.SPF.Domain, fromDomain) {
+		warn("envelope sender domain %s does not match the From domain %s", r.SPF.Domain, fromDomain)
+	}
+	for _, addr := range r.ReplyTo {
+		if d := domainOf(addr); !sameOrgDomain(d, fromDomain) {
+			warn("replies go to %s, outside the From domain %s", addr, fromDomain)
+		}
+	}
+	if r.Sender != "" && !sameOrgDomain(domainOf(r.Sender), fromDomain) {
+		warn("sent by %s on behalf of %s", r.Sender, r.From)
+	}
+	if addr := strings.ToLower(emailInName.FindString(r.FromName)); addr != "" && addr != r.From {
+		warn("display name shows %s but the message is from %s", addr, r.From)
+	}
+
+	switch {
+	case r.CompAuth == "fail" || (r.DMARC != nil && r.DMARC.Result == "fail"):
+		r.Verdict = "fail"
+	case len(r.Warnings) > 0:
+		r.Verdict = "warn"
+	default:
+		r.Verdict = "pass"
+	}
+}
+
+func domainOf(addr string) string {
+	if _, domain, ok := strings.Cut(addr, "@"); ok {
+		return strings.ToLower(domain)
+	}
+	return strings.ToLower(addr)
+}
+
+// sameOrgDomain reports whether two domains share an organizational domain,
+// as DMARC relaxed alignment does: mail.example.com and example.com match.
+// Public suffixes are approximated: a two-letter country code preceded by a
+// short label (co.uk, com.au) counts as one suffix.
+func sameOrgDomain(a, b string) bool {
+	return a != "" && b != "" && orgDomain(a) == orgDomain(b)
+}
+
+func orgDomain(domain string) string {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(domain), "."), ".")
+	n := 2
+	if len(labels) >= 3 && len(labels[len(labels)-1]) == 2 && len(labels[len(labels)-2]) <= 3 {
+		n = 3
+	}
+	if len(labels) <= n {
+		return strings.Join(labels, ".")
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "an unknown domain"
+	}
+	return s
+}
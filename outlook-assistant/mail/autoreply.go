@@ -0,0 +1,284 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Automatic replies ----------
+
+// AutoReplySettings is the JSON representation of the mailbox's automatic
+// replies (out of office). The messages are HTML, as Outlook stores them.
+// ScheduledStart and ScheduledEnd are only set when Status is "scheduled" or
+// a schedule was kept from before; they are in TimeZone, usually UTC.
+type AutoReplySettings struct {
+	Status           string `json:"status"`           // disabled, alwaysEnabled, or scheduled
+	Active           bool   `json:"active"`           // replies are being sent right now
+	ExternalAudience string `json:"externalAudience"` // none, contactsOnly, or all
+	ScheduledStart   string `json:"scheduledStart,omitempty"`
+	ScheduledEnd     string `json:"scheduledEnd,omitempty"`
+	TimeZone         string `json:"timeZone,omitempty"`
+	InternalMessage  string `json:"internalMessage"`
+	ExternalMessage  string `json:"externalMessage"`
+}
+
+// AutoReplyUpdate lists the changes requested by `settings autoreply`. Empty
+// fields are left as they are. Start and End set the schedule.
+type AutoReplyUpdate struct {
+	Status          string // off, on, or scheduled
+	InternalMessage string
+	ExternalMessage string
+	Format          BodyFormat
+	Audience        string // none, contacts, or all
+	Start           string
+	End             string
+}
+
+func (u AutoReplyUpdate) empty() bool {
+	return u.Status == "" && u.InternalMessage == "" && u.ExternalMessage == "" &&
+		u.Audience == "" && u.Start == "" && u.End == ""
+}
+
+// AutoReply applies any requested changes to the automatic replies and then
+// prints them. The setting is read first, so a new start or end can be checked
+// against the half of the schedule that is kept.
+func AutoReply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, update AutoReplyUpdate, jsonOutput bool) error {
+	settings, err := mailbox.Of(client).MailboxSettings().Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("reading mailbox settings: %w", err)
+	}
+	current := settings.GetAutomaticRepliesSetting()
+	if current == nil {
+		current = models.NewAutomaticRepliesSetting()
+	}
+
+	if !update.empty() {
+		if err := applyAutoReply(current, update); err != nil {
+			return err
+		}
+		patch := models.NewMailboxSettings()
+		patch.SetAutomaticRepliesSetting(copyAutoReply(current))
+		if _, err := mailbox.Of(client).MailboxSettings().Patch(ctx, patch, nil); err != nil {
+			return fmt.Errorf("updating automatic replies: %w", err)
+		}
+	}
+
+	out := autoReplySettings(current)
+	if !update.empty() {
+		slog.Info("Automatic replies updated", "status", out.Status)
+	}
+	if jsonOutput {
+		return printJSON(out)
+	}
+
+	status := map[string]string{
+		"disabled":      "off",
+		"alwaysEnabled": "on",
+		"scheduled":     "scheduled",
+	}[out.Status]
+	if out.Status == "scheduled" {
+		status += " " + formatReplyTime(out.ScheduledStart, out.TimeZone) + " → " + formatReplyTime(out.ScheduledEnd, out.TimeZone)
+		if out.Active {
+			status += " (sending now)"
+		}
+	}
+	fmt.Printf("\nAutomatic replies: %s\n", status)
+	fmt.Printf("External audience: %s\n", out.ExternalAudience)
+	for _, m := range []struct{ title, html string }{
+		{"Reply inside your organization", out.InternalMessage},
+		{"Reply to external senders", out.ExternalMessage},
+	} {
+		fmt.Printf("\n%s\n", m.title)
+		fmt.Println(strings.Repeat("-", 40))
+		text := strings.TrimSpace(stripHTML(ExtractBodyContent(m.html)))
+		if text == "" {
+			text = "(none)"
+		}
+		fmt.Println(text)
+	}
+	return nil
+}
+
+// applyAutoReply merges update into s.
+func applyAutoReply(s models.AutomaticRepliesSettingable, update AutoReplyUpdate) error {
+	status := s.GetStatus()
+	switch strings.ToLower(update.Status) {
+	case "":
+		// Giving a schedule implies turning it on.
+		if update.Start != "" || update.End != "" {
+			v := models.SCHEDULED_AUTOMATICREPLIESSTATUS
+			status = &v
+		}
+	case "off":
+		v := models.DISABLED_AUTOMATICREPLIESSTATUS
+		status = &v
+	case "on":
+		v := models.ALWAYSENABLED_AUTOMATICREPLIESSTATUS
+		status = &v
+	case "scheduled":
+		v := models.SCHEDULED_AUTOMATICREPLIESSTATUS
+		status = &v
+	default:
+		return fmt.Errorf("invalid --status %q (use off, on, or scheduled)", update.Status)
+	}
+	scheduled := status != nil && *status == models.SCHEDULED_AUTOMATICREPLIESSTATUS
+	if (update.Start != "" || update.End != "") && !scheduled {
+		return fmt.Errorf("--start and --end set the schedule, so they only apply with --status=scheduled")
+	}
+	s.SetStatus(status)
+
+	for _, t := range []struct {
+		flag  string
+		value string
+		set   func(models.DateTimeTimeZoneable)
+	}{
+		{"--start", update.Start, s.SetScheduledStartDateTime},
+		{"--end", update.End, s.SetScheduledEndDateTime},
+	} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := parseFlexibleDate(t.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.flag, err)
+		}
+		t.set(utcDateTime(parsed))
+	}
+	if scheduled {
+		if s.GetScheduledStartDateTime() == nil || s.GetScheduledEndDateTime() == nil {
+			return fmt.Errorf("--status=scheduled needs --start and --end")
+		}
+		start, end := replyTime(s.GetScheduledStartDateTime()), replyTime(s.GetScheduledEndDateTime())
+		if !start.IsZero() && !end.IsZero() && !end.After(start) {
+			return fmt.Errorf("--end must be after --start")
+		}
+	}
+
+	if update.Audience != "" {
+		var audience models.ExternalAudienceScope
+		switch strings.ToLower(update.Audience) {
+		case "none":
+			audience = models.NONE_EXTERNALAUDIENCESCOPE
+		case "contacts", "contactsonly":
+			audience = models.CONTACTSONLY_EXTERNALAUDIENCESCOPE
+		case "all":
+			audience = models.ALL_EXTERNALAUDIENCESCOPE
+		default:
+			return fmt.Errorf("invalid --audience %q (use none, contacts, or all)", update.Audience)
+		}
+		s.SetExternalAudience(&audience)
+	}
+
+	if update.InternalMessage != "" {
+		html := RenderBodyInner(update.InternalMessage, update.Format)
+		s.SetInternalReplyMessage(&html)
+	}
+	if update.ExternalMessage != "" {
+		html := RenderBodyInner(update.ExternalMessage, update.Format)
+		s.SetExternalReplyMessage(&html)
+	}
+	return nil
+}
+
+// copyAutoReply returns a new setting with every field of s. The SDK only
+// serializes the fields changed since a model was read, and so would send an
+// empty setting for one that came from Graph.
+func copyAutoReply(s models.AutomaticRepliesSettingable) models.AutomaticRepliesSettingable {
+	c := models.NewAutomaticRepliesSetting()
+	c.SetStatus(s.GetStatus())
+	c.SetExternalAudience(s.GetExternalAudience())
+	c.SetInternalReplyMessage(s.GetInternalReplyMessage())
+	c.SetExternalReplyMessage(s.GetExternalReplyMessage())
+	for _, t := range []struct {
+		dt  models.DateTimeTimeZoneable
+		set func(models.DateTimeTimeZoneable)
+	}{
+		{s.GetScheduledStartDateTime(), c.SetScheduledStartDateTime},
+		{s.GetScheduledEndDateTime(), c.SetScheduledEndDateTime},
+	} {
+		if t.dt == nil {
+			continue
+		}
+		dt := models.NewDateTimeTimeZone()
+		dt.SetDateTime(t.dt.GetDateTime())
+		dt.SetTimeZone(t.dt.GetTimeZone())
+		t.set(dt)
+	}
+	return c
+}
+
+func autoReplySettings(s models.AutomaticRepliesSettingable) AutoReplySettings {
+	out := AutoReplySettings{
+		Status:           "disabled",
+		ExternalAudience: "all",
+		InternalMessage:  deref(s.GetInternalReplyMessage(), ""),
+		ExternalMessage:  deref(s.GetExternalReplyMessage(), ""),
+	}
+	if v := s.GetStatus(); v != nil {
+		out.Status = v.String()
+	}
+	if v := s.GetExternalAudience(); v != nil {
+		out.ExternalAudience = v.String()
+	}
+	if dt := s.GetScheduledStartDateTime(); dt != nil {
+		out.ScheduledStart = deref(dt.GetDateTime(), "")
+		out.TimeZone = deref(dt.GetTimeZone(), "")
+	}
+	if dt := s.GetScheduledEndDateTime(); dt != nil {
+		out.ScheduledEnd = deref(dt.GetDateTime(), "")
+	}
+
+	switch out.Status {
+	case "alwaysEnabled":
+		out.Active = true
+	case "scheduled":
+		now := time.Now()
+		start, end := replyTime(s.GetScheduledStartDateTime()), replyTime(s.GetScheduledEndDateTime())
+		out.Active = !start.After(now) && now.Before(end)
+	}
+	return out
+}
+
+// utcDateTime converts t for Graph, in UTC.
+func utcDateTime(t time.Time) models.DateTimeTimeZoneable {
+	formatted := t.UTC().Format("2006-01-02T15:04:05")
+	tz := "UTC"
+	dt := models.NewDateTimeTimeZone()
+	dt.SetDateTime(&formatted)
+	dt.SetTimeZone(&tz)
+	return dt
+}
+
+// replyTime parses a schedule time. Only UTC, which Graph returns by default,
+// is understood; anything else is zero.
+func replyTime(dt models.DateTimeTimeZoneable) time.Time {
+	if dt == nil || !strings.EqualFold(deref(dt.GetTimeZone(), "UTC"), "UTC") {
+		return time.Time{}
+	}
+	t, err := time.Parse("2006-01-02T15:04:05.9999999", deref(dt.GetDateTime(), ""))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// formatReplyTime shows a schedule time in local time when it is in UTC.
+func formatReplyTime(s, timeZone string) string {
+	if !strings.EqualFold(timeZone, "UTC") {
+		return s + " " + timeZone
+	}
+	t, err := time.Parse("2006-01-02T15:04:05.9999999", s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
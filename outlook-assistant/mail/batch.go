@@ -0,0 +1,191 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	msgraphgocore "github.com/microsoftgraph/msgraph-sdk-go-core"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/retry"
+)
+
+// ---------- $batch ----------
+
+// maxBatchSize is the maximum number of requests Graph accepts in one $batch call.
+const maxBatchSize = 20
+
+// runBatch executes requests via Graph $batch, splitting them into chunks of
+// maxBatchSize. handle is called once for every response received, with the
+// index of the originating request in steps.
+//
+// Graph throttles the requests inside a $batch one by one, answering 429 or
+// 503 for those it did not act on while the batch itself succeeds, so the
+// retry middleware never sees them. Those requests are sent again in a
+// batch of their own, after the longest Retry-After among them, up to the
+// retry limit of ctx; only the last answer reaches handle.
+func runBatch(
+	ctx context.Context,
+	client *msgraphsdkgo.GraphServiceClient,
+	steps []*abstractions.RequestInformation,
+	handle func(i int, resp msgraphgocore.BatchResponse, item msgraphgocore.BatchItem),
+) error {
+	adapter := client.GetAdapter()
+	limit := retry.Limit(ctx)
+
+	for chunkStart := 0; chunkStart < len(steps); chunkStart += maxBatchSize {
+		pending := make([]int, 0, maxBatchSize)
+		for i := chunkStart; i < min(chunkStart+maxBatchSize, len(steps)); i++ {
+			pending = append(pending, i)
+		}
+
+		for attempt := 1; len(pending) > 0; attempt++ {
+			answers, err := sendChunk(ctx, adapter, steps, pending)
+			if err != nil {
+				return err
+			}
+			pending = nil
+			var throttled []batchAnswer
+			var wait time.Duration
+			for _, a := range answers {
+				if attempt > limit || !throttledItem(a.item) {
+					handle(a.i, a.resp, a.item)
+					continue
+				}
+				throttled = append(throttled, a)
+				wait = max(wait, retry.Delay(itemHeader(a.item, "Retry-After"), attempt))
+			}
+			if len(throttled) == 0 {
+				break
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				slog.Warn("Graph throttled requests in a batch; not retrying, as the wait would pass the time limit", "count", len(throttled), "wait", wait.Round(100*time.Millisecond).String())
+				for _, a := range throttled {
+					handle(a.i, a.resp, a.item)
+				}
+				break
+			}
+			slog.Warn("Graph throttled requests in a batch; retrying them", "count", len(throttled), "attempt", attempt, "of", limit, "wait", wait.Round(100*time.Millisecond).String())
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			for _, a := range throttled {
+				pending = append(pending, a.i)
+			}
+		}
+	}
+	return nil
+}
+
+// batchAnswer is the response to steps[i] in a $batch.
+type batchAnswer struct {
+	i    int
+	resp msgraphgocore.BatchResponse
+	item msgraphgocore.BatchItem
+}
+
+// sendChunk sends the steps at indexes in one $batch and returns the
+// responses received.
+func sendChunk(ctx context.Context, adapter abstractions.RequestAdapter, steps []*abstractions.RequestInformation, indexes []int) ([]batchAnswer, error) {
+	batch := msgraphgocore.NewBatchRequest(adapter)
+	positions := make(map[string]int, len(indexes))
+	for _, i := range indexes {
+		item, err := batch.AddBatchRequestStep(*steps[i])
+		if err != nil {
+			return nil, fmt.Errorf("building batch request: %w", err)
+		}
+		positions[deref(item.GetId(), "")] = i
+	}
+
+	resp, err := batch.Send(ctx, adapter)
+	if err != nil {
+		return nil, fmt.Errorf("sending batch request: %w", err)
+	}
+	answers := make([]batchAnswer, 0, len(indexes))
+	for _, item := range resp.GetResponses() {
+		if i, ok := positions[deref(item.GetId(), "")]; ok {
+			answers = append(answers, batchAnswer{i: i, resp: resp, item: item})
+		}
+	}
+	return answers, nil
+}
+
+// throttledItem reports whether Graph turned a $batch request away without
+// acting on it, so it can be sent again.
+func throttledItem(item msgraphgocore.BatchItem) bool {
+	status := item.GetStatus()
+	return status != nil && (*status == http.StatusTooManyRequests || *status == http.StatusServiceUnavailable)
+}
+
+// itemHeader returns a response header of a $batch item, whatever its case.
+func itemHeader(item msgraphgocore.BatchItem, name string) string {
+	for k, v := range item.GetHeaders() {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// sendBatch executes requests via $batch and returns the HTTP status of each
+// in the same order as steps; a request that received no response is 0.
+func sendBatch(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, steps []*abstractions.RequestInformation) ([]int32, error) {
+	statuses := make([]int32, len(steps))
+	err := runBatch(ctx, client, steps, func(i int, _ msgraphgocore.BatchResponse, item msgraphgocore.BatchItem) {
+		if item.GetStatus() != nil {
+			statuses[i] = *item.GetStatus()
+		}
+	})
+	return statuses, err
+}
+
+// getBatch executes GET requests via $batch and parses each successful
+// response body with factory. Results are returned in the same order as
+// steps; requests that failed leave the zero value in their slot and a
+// non-2xx status in statuses.
+func getBatch[T serialization.Parsable](
+	ctx context.Context,
+	client *msgraphsdkgo.GraphServiceClient,
+	steps []*abstractions.RequestInformation,
+	factory serialization.ParsableFactory,
+) (results []T, statuses []int32, err error) {
+	results = make([]T, len(steps))
+	statuses = make([]int32, len(steps))
+	err = runBatch(ctx, client, steps, func(i int, resp msgraphgocore.BatchResponse, item msgraphgocore.BatchItem) {
+		if item.GetStatus() == nil {
+			return
+		}
+		statuses[i] = *item.GetStatus()
+		if statuses[i] < 200 || statuses[i] > 299 {
+			return
+		}
+		if v, perr := msgraphgocore.GetBatchResponseById[T](resp, deref(item.GetId(), ""), factory); perr == nil {
+			results[i] = v
+		} else {
+			statuses[i] = 0
+		}
+	})
+	return results, statuses, err
+}
+
+// countFailed returns how many statuses are not 2xx.
+func countFailed(statuses []int32) int {
+	failed := 0
+	for _, s := range statuses {
+		if s < 200 || s > 299 {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,234 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Blocked / safe sender lists ----------
+//
+// Graph v1.0 does not expose the Exchange junk-mail sender lists, so the tool
+// keeps its own lists as server-side inbox rules. Each list is one rule whose
+// condition holds every entry; the rules therefore persist at the mailbox
+// level and apply to mail arriving while no agent is running.
+
+// senderList describes one rule-backed list of senders.
+type senderList struct {
+	label    string // shown to the user
+	ruleName string // inbox rule display name
+	folder   string // well-known destination folder for matching mail
+	sequence int32  // rule order; safe lists run first
+	domains  bool   // entries are domains matched with senderContains
+}
+
+var (
+	blockedSenders = senderList{label: "blocked senders", ruleName: "outlook-assistant: blocked senders", folder: "junkemail", sequence: 3}
+	safeSenders    = senderList{label: "safe senders", ruleName: "outlook-assistant: safe senders", folder: "inbox", sequence: 1}
+	blockedDomains = senderList{label: "blocked domains", ruleName: "outlook-assistant: blocked domains", folder: "junkemail", sequence: 4, domains: true}
+	safeDomains    = senderList{label: "safe domains", ruleName: "outlook-assistant: safe domains", folder: "inbox", sequence: 2, domains: true}
+)
+
+// SenderLists is the JSON representation of the blocked and safe sender lists.
+type SenderLists struct {
+	Blocked []string `json:"blocked"`
+	Safe    []string `json:"safe"`
+}
+
+// BlocklistAdd adds comma-separated addresses to the blocked sender list, or
+// to the safe sender list when safe is true.
+func BlocklistAdd(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, addresses string, safe bool) error {
+	list := blockedSenders
+	if safe {
+		list = safeSenders
+	}
+	return list.update(ctx, client, splitList(addresses), nil)
+}
+
+// BlocklistRemove removes comma-separated addresses from the blocked sender
+// list, or from the safe sender list when safe is true.
+func BlocklistRemove(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, addresses string, safe bool) error {
+	list := blockedSenders
+	if safe {
+		list = safeSenders
+	}
+	return list.update(ctx, client, nil, splitList(addresses))
+}
+
+// Blocklist prints the blocked and safe sender lists.
+func Blocklist(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	blocked, _, err := blockedSenders.load(ctx, client)
+	if err != nil {
+		return err
+	}
+	safe, _, err := safeSenders.load(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(SenderLists{Blocked: nonNil(blocked), Safe: nonNil(safe)})
+	}
+
+	fmt.Printf("\nBlocked senders (%d)\n", len(blocked))
+	fmt.Println(strings.Repeat("-", 40))
+	for _, a := range blocked {
+		fmt.Println(a)
+	}
+	fmt.Printf("\nSafe senders (%d)\n", len(safe))
+	fmt.Println(strings.Repeat("-", 40))
+	for _, a := range safe {
+		fmt.Println(a)
+	}
+	return nil
+}
+
+// load returns the list's entries and its backing rule (nil if none exists yet).
+func (l senderList) load(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) ([]string, models.MessageRuleable, error) {
+	rules, err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().Get(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing inbox rules: %w", err)
+	}
+	for _, r := range rules.GetValue() {
+		if deref(r.GetDisplayName(), "") != l.ruleName {
+			continue
+		}
+		var entries []string
+		if c := r.GetConditions(); c != nil {
+			if l.domains {
+				for _, d := range c.GetSenderContains() {
+					entries = append(entries, strings.TrimPrefix(d, "@"))
+				}
+			} else {
+				entries = recipientAddresses(c.GetFromAddresses())
+			}
+		}
+		return entries, r, nil
+	}
+	return nil, nil, nil
+}
+
+// update adds and removes entries, then creates, patches, or deletes the
+// backing rule so that it matches the resulting list.
+func (l senderList) update(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, add, remove []string) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return fmt.Errorf("nothing to add to or remove from the %s list", l.label)
+	}
+
+	entries, existing, err := l.load(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]bool, len(entries)+len(add))
+	for _, e := range entries {
+		set[l.normalize(e)] = true
+	}
+	for _, e := range add {
+		set[l.normalize(e)] = true
+	}
+	for _, e := range remove {
+		delete(set, l.normalize(e))
+	}
+	result := make([]string, 0, len(set))
+	for e := range set {
+		result = append(result, e)
+	}
+	sort.Strings(result)
+
+	rules := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules()
+
+	if len(result) == 0 {
+		if existing != nil {
+			if err := rules.ByMessageRuleId(deref(existing.GetId(), "")).Delete(ctx, nil); err != nil {
+				return fmt.Errorf("deleting %s rule: %w", l.label, err)
+			}
+		}
+		slog.Info("Sender list is now empty", "list", l.label)
+		return nil
+	}
+
+	conditions := models.NewMessageRulePredicates()
+	if l.domains {
+		// Match "@domain" so that example.com does not also catch notexample.com.
+		patterns := make([]string, len(result))
+		for i, d := range result {
+			patterns[i] = "@" + d
+		}
+		conditions.SetSenderContains(patterns)
+	} else {
+		conditions.SetFromAddresses(parseRecipients(strings.Join(result, ",")))
+	}
+
+	folder, err := mailbox.Of(client).MailFolders().ByMailFolderId(l.folder).Get(ctx, &users.ItemMailFoldersMailFolderItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersMailFolderItemRequestBuilderGetQueryParameters{
+			Select: []string{"id"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading folder %q: %w", l.folder, err)
+	}
+	actions := models.NewMessageRuleActions()
+	actions.SetMoveToFolder(folder.GetId())
+	stop := true
+	actions.SetStopProcessingRules(&stop)
+
+	rule := models.NewMessageRule()
+	rule.SetConditions(conditions)
+	rule.SetActions(actions)
+
+	if existing != nil {
+		if _, err := rules.ByMessageRuleId(deref(existing.GetId(), "")).Patch(ctx, rule, nil); err != nil {
+			return fmt.Errorf("updating %s rule: %w", l.label, err)
+		}
+	} else {
+		name := l.ruleName
+		rule.SetDisplayName(&name)
+		sequence := l.sequence
+		rule.SetSequence(&sequence)
+		enabled := true
+		rule.SetIsEnabled(&enabled)
+		if _, err := rules.Post(ctx, rule, nil); err != nil {
+			return fmt.Errorf("creating %s rule: %w", l.label, err)
+		}
+	}
+
+	slog.Info("Sender list updated", "list", l.label, "entries", len(result))
+	return nil
+}
+
+// normalize lower-cases an entry and, for domain lists, strips a leading "@".
+func (l senderList) normalize(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if l.domains {
+		entry = strings.TrimPrefix(entry, "@")
+	}
+	return entry
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// ---------- Bulk ----------
+
+// resolveMessageIDs expands ref into message IDs. ref is a single list index
+// or raw Graph message ID, or a comma-separated mix of them and index ranges
+// such as 1,3,5-9. refs holds the reference each ID came from, for error
+// messages; a message named twice is only returned once.
+func resolveMessageIDs(ref string) (refs, ids []string, err error) {
+	seen := make(map[string]bool)
+	add := func(r string) error {
+		id, err := resolveMessageID(r)
+		if err != nil {
+			return err
+		}
+		if !seen[id] {
+			seen[id] = true
+			refs = append(refs, r)
+			ids = append(ids, id)
+		}
+		return nil
+	}
+
+	for _, part := range strings.Split(ref, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// A raw message ID may contain "-" too, so only digits on both sides
+		// make a range.
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err1 := strconv.Atoi(lo)
+		last, err2 := strconv.Atoi(hi)
+		if !isRange || err1 != nil || err2 != nil {
+			if err := add(part); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if first > last {
+			return nil, nil, fmt.Errorf("invalid range %q: %d is after %d", part, first, last)
+		}
+		for n := first; n <= last; n++ {
+			if err := add(strconv.Itoa(n)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil, fmt.Errorf("--ref names no messages")
+	}
+	return refs, ids, nil
+}
+
+// bulk sends one request per message, built by build, in a single $batch
+// (split only past maxBatchSize). done completes "could not be ..." in the
+// error, which lists the refs of the messages that failed.
+func bulk(
+	ctx context.Context,
+	client *msgraphsdkgo.GraphServiceClient,
+	refs, ids []string,
+	done string,
+	build func(id string) (*abstractions.RequestInformation, error),
+) error {
+	steps := make([]*abstractions.RequestInformation, 0, len(ids))
+	for _, id := range ids {
+		info, err := build(id)
+		if err != nil {
+			return fmt.Errorf("building batch request: %w", err)
+		}
+		steps = append(steps, info)
+	}
+
+	statuses, err := sendBatch(ctx, client, steps)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for i, s := range statuses {
+		if s < 200 || s > 299 {
+			failed = append(failed, refs[i])
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d messages could not be %s: --ref=%s", len(failed), len(steps), done, strings.Join(failed, ","))
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Category master list ----------
+
+// CategorySummary is the JSON representation of an entry in the master
+// category list.
+type CategorySummary struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Color  string `json:"color"`  // red, darkblue, ..., or none
+	Preset string `json:"preset"` // the Graph value: preset0 to preset24, or none
+}
+
+// presetColors names the Graph color presets in order: presetColors[i] is
+// preset<i>, as Outlook shows it.
+var presetColors = []string{
+	"red", "orange", "brown", "yellow", "green", "teal", "olive", "blue", "purple", "cranberry",
+	"steel", "darksteel", "gray", "darkgray", "black", "darkred", "darkorange", "darkbrown",
+	"darkyellow", "darkgreen", "darkteal", "darkolive", "darkblue", "darkpurple", "darkcranberry",
+}
+
+// Categories lists the master category list, the categories Outlook shows
+// with a color, by name.
+func Categories(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	categories, err := listCategories(ctx, client)
+	if err != nil {
+		return err
+	}
+	summaries := make([]CategorySummary, 0, len(categories))
+	for _, c := range categories {
+		summaries = append(summaries, categorySummary(c))
+	}
+	if jsonOutput {
+		return printJSON(summaries)
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No categories in the master list.")
+		return nil
+	}
+	fmt.Printf("\n%-35s  %-14s  %s\n", "Category", "Color", "Preset")
+	fmt.Println(strings.Repeat("-", 62))
+	for _, s := range summaries {
+		fmt.Printf("%-35s  %-14s  %s\n", truncate(s.Name, 35), s.Color, s.Preset)
+	}
+	return nil
+}
+
+// CreateCategory adds name to the master category list in color, a color
+// name such as red or a preset such as preset0. Without a color, the first
+// preset no other category uses is taken, as Outlook does.
+func CreateCategory(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name, color string, jsonOutput bool) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("--name is required for categories create")
+	}
+	categories, err := listCategories(ctx, client)
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		if strings.EqualFold(deref(c.GetDisplayName(), ""), name) {
+			return fmt.Errorf("category %q is already in the master list", deref(c.GetDisplayName(), name))
+		}
+	}
+	var preset models.CategoryColor
+	if color == "" {
+		preset = unusedColor(categories)
+	} else if preset, err = parseCategoryColor(color); err != nil {
+		return err
+	}
+
+	category := models.NewOutlookCategory()
+	category.SetDisplayName(&name)
+	category.SetColor(&preset)
+	created, err := mailbox.Of(client).Outlook().MasterCategories().Post(ctx, category, nil)
+	if err != nil {
+		return fmt.Errorf("creating category: %w", err)
+	}
+
+	summary := categorySummary(created)
+	if jsonOutput {
+		return printJSON(summary)
+	}
+	slog.Info("Category created", "category", summary.Name, "color", summary.Color)
+	return nil
+}
+
+// RenameCategory renames the category named by ref, a name or ID, to newName.
+// Graph cannot change a category's name, so the entry is replaced by one with
+// the new name and the same color, unless color gives another. As when a
+// category is renamed in Outlook, messages that carry the old name keep it.
+func RenameCategory(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, newName, color string, jsonOutput bool) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return fmt.Errorf("--new-name is required for categories rename")
+	}
+	categories, err := listCategories(ctx, client)
+	if err != nil {
+		return err
+	}
+	old, err := findCategory(categories, ref)
+	if err != nil {
+		return err
+	}
+	oldName := deref(old.GetDisplayName(), "")
+	if newName != oldName && strings.EqualFold(newName, oldName) {
+		return fmt.Errorf("category names are compared without case, so %q cannot be renamed to %q", oldName, newName)
+	}
+	for _, c := range categories {
+		if c != old && strings.EqualFold(deref(c.GetDisplayName(), ""), newName) {
+			return fmt.Errorf("category %q is already in the master list", deref(c.GetDisplayName(), newName))
+		}
+	}
+	preset := models.NONE_CATEGORYCOLOR
+	if old.GetColor() != nil {
+		preset = *old.GetColor()
+	}
+	if color != "" {
+		if preset, err = parseCategoryColor(color); err != nil {
+			return err
+		}
+	}
+
+	if newName == oldName {
+		// Only the color changes, which Graph can do in place.
+		patch := models.NewOutlookCategory()
+		patch.SetColor(&preset)
+		updated, err := mailbox.Of(client).Outlook().MasterCategories().ByOutlookCategoryId(deref(old.GetId(), "")).Patch(ctx, patch, nil)
+		if err != nil {
+			return fmt.Errorf("updating category: %w", err)
+		}
+		return printCategoryChange(updated, oldName, jsonOutput)
+	}
+	category := models.NewOutlookCategory()
+	category.SetDisplayName(&newName)
+	category.SetColor(&preset)
+	created, err := mailbox.Of(client).Outlook().MasterCategories().Post(ctx, category, nil)
+	if err != nil {
+		return fmt.Errorf("creating category %q: %w", newName, err)
+	}
+	if err := mailbox.Of(client).Outlook().MasterCategories().ByOutlookCategoryId(deref(old.GetId(), "")).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("category %q was created, but %q could not be deleted: %w", newName, oldName, err)
+	}
+	return printCategoryChange(created, oldName, jsonOutput)
+}
+
+// DeleteCategory removes the category named by ref, a name or ID, from the
+// master list. Messages that carry it keep the name, shown without a color.
+func DeleteCategory(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
+	categories, err := listCategories(ctx, client)
+	if err != nil {
+		return err
+	}
+	category, err := findCategory(categories, ref)
+	if err != nil {
+		return err
+	}
+	if err := mailbox.Of(client).Outlook().MasterCategories().ByOutlookCategoryId(deref(category.GetId(), "")).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("deleting category: %w", err)
+	}
+	slog.Info("Category deleted", "category", deref(category.GetDisplayName(), ref))
+	return nil
+}
+
+// listCategories returns the master category list sorted by name.
+func listCategories(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) ([]models.OutlookCategoryable, error) {
+	result, err := mailbox.Of(client).Outlook().MasterCategories().Get(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing categories: %w", err)
+	}
+	categories := result.GetValue()
+	sort.SliceStable(categories, func(i, j int) bool {
+		return strings.ToLower(deref(categories[i].GetDisplayName(), "")) < strings.ToLower(deref(categories[j].GetDisplayName(), ""))
+	})
+	return categories, nil
+}
+
+// findCategory returns the category whose ID is ref, or else whose name is
+// ref (case-insensitive, as Outlook compares them).
+func findCategory(categories []models.OutlookCategoryable, ref string) (models.OutlookCategoryable, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("--name is required")
+	}
+	for _, c := range categories {
+		if deref(c.GetId(), "") == ref {
+			return c, nil
+		}
+	}
+	for _, c := range categories {
+		if strings.EqualFold(deref(c.GetDisplayName(), ""), ref) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("category %q is not in the master list — use `categories list` to see them", ref)
+}
+
+// parseCategoryColor accepts a color name such as darkblue, a preset such as
+// preset22, or none.
+func parseCategoryColor(color string) (models.CategoryColor, error) {
+	c := strings.ToLower(strings.TrimSpace(color))
+	if i := slices.Index(presetColors, c); i >= 0 {
+		c = "preset" + strconv.Itoa(i)
+	}
+	if v, _ := models.ParseCategoryColor(c); v != nil {
+		return *v.(*models.CategoryColor), nil
+	}
+	return 0, fmt.Errorf("unknown --color %q — use none, preset0 to preset24, or one of: %s", color, strings.Join(presetColors, ", "))
+}
+
+// unusedColor returns the first preset no category in categories uses, or
+// preset0 when all are taken.
+func unusedColor(categories []models.OutlookCategoryable) models.CategoryColor {
+	used := map[models.CategoryColor]bool{}
+	for _, c := range categories {
+		if c.GetColor() != nil {
+			used[*c.GetColor()] = true
+		}
+	}
+	for c := models.PRESET0_CATEGORYCOLOR; c <= models.PRESET24_CATEGORYCOLOR; c++ {
+		if !used[c] {
+			return c
+		}
+	}
+	return models.PRESET0_CATEGORYCOLOR
+}
+
+// printCategoryChange reports a renamed or recolored category.
+func printCategoryChange(c models.OutlookCategoryable, oldName string, jsonOutput bool) error {
+	summary := categorySummary(c)
+	if jsonOutput {
+		return printJSON(summary)
+	}
+	if summary.Name == oldName {
+		slog.Info("Category color changed", "category", summary.Name, "color", summary.Color)
+	} else {
+		slog.Info("Category renamed", "from", oldName, "to", summary.Name, "color", summary.Color)
+	}
+	return nil
+}
+
+func categorySummary(c models.OutlookCategoryable) CategorySummary {
+	s := CategorySummary{
+		ID:     deref(c.GetId(), ""),
+		Name:   deref(c.GetDisplayName(), ""),
+		Color:  "none",
+		Preset: "none",
+	}
+	if c.GetColor() != nil {
+		s.Preset = c.GetColor().String()
+		if i := int(*c.GetColor()) - int(models.PRESET0_CATEGORYCOLOR); i >= 0 && i < len(presetColors) {
+			s.Color = presetColors[i]
+		}
+	}
+	return s
+}
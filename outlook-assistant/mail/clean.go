@@ -0,0 +1,188 @@
+package mail
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ---------- Clean text ----------
+
+// Heuristics for --clean, in the spirit of talon's quotation and signature
+// detection. Each pattern is matched against a single trimmed line.
+var (
+	// replyHeader starts quoted history: "On Mon, 3 Jun 2024, Ada <a@x> wrote:",
+	// "-----Original Message-----", Outlook's underscore rule, and the
+	// localized variants Outlook and Gmail produce most often.
+	replyHeader = regexp.MustCompile(`(?i)^(` +
+		`-{2,}\s*original message\s*-{2,}|` +
+		`_{10,}|` +
+		`on .{4,200} wrote:?|` +
+		`le .{4,200} a écrit\s*:?|` +
+		`am .{4,200} schrieb .{0,100}:?|` +
+		`el .{4,200} escribió:?|` +
+		`op .{4,200} schreef .{0,100}:?` +
+		`)$`)
+	// wroteTail catches "On … <a@x>" / "wrote:" split over two lines.
+	wroteTail = regexp.MustCompile(`(?i)^.{0,100}(wrote|a écrit|schrieb|escribió|schreef)\s*:$`)
+	// headerField matches the header block Outlook puts above quoted history;
+	// fromField is its first line.
+	headerField = regexp.MustCompile(`(?i)^\*{0,2}(from|sent|date|to|cc|subject|von|gesendet|an|betreff|de|envoyé|à|objet|enviado|para|asunto)\s*:\*{0,2}\s`)
+	fromField   = regexp.MustCompile(`(?i)^\*{0,2}(from|von|de)\s*:\*{0,2}\s`)
+	// signOff is a line that is nothing but a closing.
+	signOff = regexp.MustCompile(`(?i)^(thanks|thank you|many thanks|thanks again|cheers|regards|best regards|kind regards|warm regards|warmest regards|best wishes|all the best|best|sincerely|yours sincerely|yours truly|talk soon|br|mit freundlichen grüßen|viele grüße|cordialement|saludos)[\s,.!]*$`)
+	// mobileSignature is a client-added footer.
+	mobileSignature = regexp.MustCompile(`(?i)^(sent from my \w+( \w+)?|sent from (outlook|mail|yahoo mail|gmail) for \w+|get outlook for (ios|android))\.?$`)
+	// disclaimer marks a paragraph of legal or environmental boilerplate.
+	disclaimer = regexp.MustCompile(`(?i)(confidentiality notice|^disclaimer|this (e-?mail|message|communication)( and any (files|attachments)( transmitted with it)?)? (is|are|may be|may contain|contains?) (strictly )?(confidential|privileged|intended)|intended (solely|only|exclusively) for the (use of the )?(individual|addressee|named|person|recipient)|if you (are not|have received this).{0,40}(intended recipient|in error)|please consider the environment before printing|unauthori[sz]ed (use|disclosure|copying|distribution))`)
+)
+
+// cleanBody returns only the new content of a plain-text message body:
+// quoted history, the signature, and legal disclaimers are removed.
+func cleanBody(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	lines = lines[:quoteStart(lines)]
+	lines = dropDisclaimers(lines)
+	lines = lines[:signatureStart(lines)]
+
+	var out []string
+	blanks := 0
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t")
+		if strings.HasPrefix(strings.TrimSpace(l), ">") {
+			continue
+		}
+		if l == "" {
+			if blanks++; blanks > 1 {
+				continue
+			}
+		} else {
+			blanks = 0
+		}
+		out = append(out, l)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// splitQuotes splits a plain-text body into the new text and the quoted
+// history below it. Quoted history starts at a reply header (see quoteStart)
+// or, failing that, at a trailing block of ">" lines. Inline ">" quotes
+// between new paragraphs stay in the new text, where they give it context.
+func splitQuotes(text string) (newContent, quotedContent string) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	start := quoteStart(lines)
+	if start == len(lines) {
+		start = trailingQuote(lines)
+	}
+	return strings.TrimSpace(strings.Join(lines[:start], "\n")), strings.TrimSpace(strings.Join(lines[start:], "\n"))
+}
+
+// trailingQuote returns the index of the first line of a block of ">" lines
+// that runs to the end of lines, or len(lines) when the body does not end
+// with one.
+func trailingQuote(lines []string) int {
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, ">") {
+			start = i
+		} else if line != "" {
+			break
+		}
+	}
+	return start
+}
+
+// quoteStart returns the index of the first line of quoted history, or
+// len(lines) when there is none.
+func quoteStart(lines []string) int {
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if replyHeader.MatchString(line) {
+			return i
+		}
+		if i+1 < len(lines) && strings.HasPrefix(strings.ToLower(line), "on ") && wroteTail.MatchString(strings.TrimSpace(lines[i+1])) {
+			return i
+		}
+		// A From: line followed closely by Sent:/Date: and To:/Subject: is
+		// the header of a quoted or forwarded message.
+		if fromField.MatchString(line+" ") && fieldsAhead(lines[i:], 5) >= 3 {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// fieldsAhead counts header fields among the next n non-blank lines.
+func fieldsAhead(lines []string, n int) int {
+	count := 0
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if n--; n < 0 {
+			break
+		}
+		if headerField.MatchString(l + " ") {
+			count++
+		}
+	}
+	return count
+}
+
+// dropDisclaimers removes blank-line separated paragraphs that read as legal
+// or environmental boilerplate.
+func dropDisclaimers(lines []string) []string {
+	var out, para []string
+	flush := func() {
+		if !disclaimer.MatchString(strings.Join(para, " ")) {
+			out = append(out, para...)
+		}
+		para = nil
+	}
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			flush()
+			out = append(out, l)
+			continue
+		}
+		para = append(para, l)
+	}
+	flush()
+	return out
+}
+
+// signatureStart returns the index where the signature begins: the "-- "
+// delimiter, a mobile client footer, or a closing followed by no more than a
+// few short lines (name, title, phone). It returns len(lines) when none is
+// found.
+func signatureStart(lines []string) int {
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if raw == "-- " || line == "--" || mobileSignature.MatchString(line) {
+			return i
+		}
+		if signOff.MatchString(line) && i > 0 && shortTail(lines[i+1:]) {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// shortTail reports whether lines look like the rest of a signature block.
+func shortTail(lines []string) bool {
+	n := 0
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if n++; n > 8 || len(l) > 80 {
+			return false
+		}
+	}
+	return true
+}
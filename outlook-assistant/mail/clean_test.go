@@ -0,0 +1,35 @@
+package mail
+
+import "testing"
+
+func TestSplitQuotes(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		new    string
+		quoted string
+	}{
+		{"empty", "", "", ""},
+		{"no quote", "Hi", "Hi", ""},
+		{"reply header", "New\n\nOn Mon, 3 Jun 2024, Ada <a@x> wrote:\n> old", "New", "On Mon, 3 Jun 2024, Ada <a@x> wrote:\n> old"},
+		{"reply header over two lines", "Hi\nOn Mon, Ada\n<a@x> wrote:\n> old", "Hi", "On Mon, Ada\n<a@x> wrote:\n> old"},
+		{"localized reply header", "Hallo\n\nAm 3. Juni 2024 um 10:00 schrieb Ada <a@x>:\n> alt", "Hallo", "Am 3. Juni 2024 um 10:00 schrieb Ada <a@x>:\n> alt"},
+		{"original message", "Hi\n\n-----Original Message-----\nFrom: x", "Hi", "-----Original Message-----\nFrom: x"},
+		{"underscore rule", "Hi\n________________\nFrom: x", "Hi", "________________\nFrom: x"},
+		{"header block", "Hi\n\nFrom: Ada\nSent: Mon\nTo: Bob\nSubject: x\n\nold", "Hi", "From: Ada\nSent: Mon\nTo: Bob\nSubject: x\n\nold"},
+		{"bold header block", "Hi\n**From:** Ada\n**Sent:** Mon\n**To:** Bob\n\nold", "Hi", "**From:** Ada\n**Sent:** Mon\n**To:** Bob\n\nold"},
+		{"From: in the text", "Hi\nFrom: Ada is here", "Hi\nFrom: Ada is here", ""},
+		{"trailing > block, CRLF", "New\r\n> q1\r\n> q2\r\n", "New", "> q1\n> q2"},
+		{"inline quote kept", "Top\n> inline\nmore\n> tail", "Top\n> inline\nmore", "> tail"},
+		{"only a quote", "> only quote", "", "> only quote"},
+		{"empty quote lines", "text\n>\n> \n", "text", ">\n>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNew, gotQuoted := splitQuotes(tt.text)
+			if gotNew != tt.new || gotQuoted != tt.quoted {
+				t.Errorf("splitQuotes(%q) = %q, %q; want %q, %q", tt.text, gotNew, gotQuoted, tt.new, tt.quoted)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Conversations ----------
+
+// conversationMessages resolves ref to a message and returns every message that
+// shares its conversationId, across all folders and every page of results.
+// fields are selected in addition to id; bodies, if selected, are returned as
+// plain text unless htmlBody is set.
+func conversationMessages(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, htmlBody bool, fields ...string) ([]models.Messageable, error) {
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "conversationId"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	conversationID := deref(msg.GetConversationId(), "")
+	if conversationID == "" {
+		return nil, fmt.Errorf("message has no conversationId")
+	}
+
+	filter := fmt.Sprintf("conversationId eq '%s'", conversationID)
+	top := int32(100)
+	config := &users.ItemMessagesRequestBuilderGetRequestConfiguration{
+		Headers: abstractions.NewRequestHeaders(),
+		QueryParameters: &users.ItemMessagesRequestBuilderGetQueryParameters{
+			Filter: &filter,
+			Select: append([]string{"id"}, fields...),
+			Top:    &top,
+		},
+	}
+	if !htmlBody {
+		config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+	}
+	var messages []models.Messageable
+	builder := mailbox.Of(client).Messages()
+	for page := 1; ; page++ {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("listing conversation messages (page %d): %w", page, err)
+		}
+		messages = append(messages, result.GetValue()...)
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		// The next link carries the query, but not the body preference.
+		builder = builder.WithUrl(*next)
+		config = &users.ItemMessagesRequestBuilderGetRequestConfiguration{Headers: config.Headers}
+	}
+	return messages, nil
+}
+
+// Thread prints every message in the conversation containing ref, in every
+// folder, oldest first, and caches their indexes for --ref. With opts.Unique,
+// each message shows only the text it added (see uniqueText), so the earlier
+// messages are not repeated in every reply quoting them; with opts.Clean, its
+// signature and disclaimers are cut too (see cleanText), so a long thread
+// reads as the sequence of things actually said.
+func Thread(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, opts ReadOptions, jsonOutput bool) error {
+	fields := []string{"subject", "from", "toRecipients", "receivedDateTime", "body", "categories",
+		"conversationId", "conversationIndex", "internetMessageId"}
+	if opts.Clean || opts.Unique {
+		fields = append(fields, "uniqueBody")
+	}
+	messages, err := conversationMessages(ctx, client, ref, opts.Markdown, fields...)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i].GetReceivedDateTime(), messages[j].GetReceivedDateTime()
+		return a != nil && b != nil && a.Before(*b)
+	})
+
+	ids := make([]string, 0, len(messages))
+	details := make([]MessageDetail, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, deref(msg.GetId(), ""))
+		details = append(details, messageDetail(msg, opts))
+	}
+	saveIDCache(ids)
+
+	if jsonOutput {
+		return printJSON(details)
+	}
+	for i, msg := range messages {
+		fmt.Printf("\n[%d/%d]", i+1, len(messages))
+		printMessage(headerOf(msg), details[i].Body)
+	}
+	return nil
+}
+
+// MarkConversationRead sets or clears the isRead flag on every message in the
+// conversation containing ref, in every folder. Messages already in the
+// requested state are left untouched; the rest are patched in one $batch.
+func MarkConversationRead(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, isRead bool) error {
+	messages, err := conversationMessages(ctx, client, ref, false, "isRead")
+	if err != nil {
+		return err
+	}
+
+	state := "read"
+	if !isRead {
+		state = "unread"
+	}
+
+	var steps []*abstractions.RequestInformation
+	for _, msg := range messages {
+		if msg.GetIsRead() != nil && *msg.GetIsRead() == isRead {
+			continue
+		}
+		patch := models.NewMessage()
+		patch.SetIsRead(&isRead)
+		info, err := mailbox.Of(client).Messages().ByMessageId(deref(msg.GetId(), "")).ToPatchRequestInformation(ctx, patch, nil)
+		if err != nil {
+			return fmt.Errorf("building read state update: %w", err)
+		}
+		steps = append(steps, info)
+	}
+
+	if len(steps) == 0 {
+		slog.Info("All messages in conversation already marked", "count", len(messages), "state", state)
+		return nil
+	}
+
+	statuses, err := sendBatch(ctx, client, steps)
+	if err != nil {
+		return fmt.Errorf("updating read state: %w", err)
+	}
+	if failed := countFailed(statuses); failed > 0 {
+		return fmt.Errorf("%d of %d messages could not be marked as %s", failed, len(steps), state)
+	}
+
+	slog.Info("Marked messages in conversation", "count", len(steps), "state", state)
+	return nil
+}
+
+// MoveConversation moves every message in the conversation containing ref to
+// the named folder. Messages already in that folder are skipped.
+// If createRule is true, an inbox rule is also created that moves future
+// messages whose subject contains the conversation topic to the same folder.
+func MoveConversation(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, folderName string, createRule bool) error {
+	if folderName == "" {
+		return fmt.Errorf("--folder is required")
+	}
+
+	folderID, err := resolveFolderID(ctx, client, folderName)
+	if err != nil {
+		return err
+	}
+	// Well-known names are accepted by move, but parentFolderId and rule
+	// actions use the real folder ID, so look it up once.
+	folder, err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Get(ctx, &users.ItemMailFoldersMailFolderItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersMailFolderItemRequestBuilderGetQueryParameters{
+			Select: []string{"id"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading folder %q: %w", folderName, err)
+	}
+	destID := deref(folder.GetId(), folderID)
+
+	messages, err := conversationMessages(ctx, client, ref, false, "subject", "parentFolderId")
+	if err != nil {
+		return err
+	}
+
+	var steps []*abstractions.RequestInformation
+	for _, msg := range messages {
+		if deref(msg.GetParentFolderId(), "") == destID {
+			continue
+		}
+		moveBody := users.NewItemMessagesItemMovePostRequestBody()
+		moveBody.SetDestinationId(&destID)
+		info, err := mailbox.Of(client).Messages().ByMessageId(deref(msg.GetId(), "")).Move().ToPostRequestInformation(ctx, moveBody, nil)
+		if err != nil {
+			return fmt.Errorf("building move request: %w", err)
+		}
+		steps = append(steps, info)
+	}
+
+	if len(steps) == 0 {
+		slog.Info("All messages in conversation already in folder", "count", len(messages), "folder", folderName)
+	} else {
+		statuses, err := sendBatch(ctx, client, steps)
+		if err != nil {
+			return fmt.Errorf("moving messages: %w", err)
+		}
+		if failed := countFailed(statuses); failed > 0 {
+			return fmt.Errorf("%d of %d messages could not be moved to %q", failed, len(steps), folderName)
+		}
+		slog.Info("Moved messages in conversation", "count", len(steps), "folder", folderName)
+	}
+
+	if !createRule {
+		return nil
+	}
+
+	topic := ""
+	if len(messages) > 0 {
+		topic = conversationTopic(deref(messages[0].GetSubject(), ""))
+	}
+	if topic == "" {
+		return fmt.Errorf("cannot create rule: conversation has no subject")
+	}
+
+	rule := models.NewMessageRule()
+	name := fmt.Sprintf("Move %q to %s", topic, folderName)
+	rule.SetDisplayName(&name)
+	sequence := int32(1)
+	rule.SetSequence(&sequence)
+	enabled := true
+	rule.SetIsEnabled(&enabled)
+
+	conditions := models.NewMessageRulePredicates()
+	conditions.SetSubjectContains([]string{topic})
+	rule.SetConditions(conditions)
+
+	actions := models.NewMessageRuleActions()
+	actions.SetMoveToFolder(&destID)
+	rule.SetActions(actions)
+
+	if _, err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().Post(ctx, rule, nil); err != nil {
+		return fmt.Errorf("creating inbox rule: %w", err)
+	}
+
+	slog.Info("Inbox rule created", "rule", name)
+	return nil
+}
+
+// conversationTopic strips reply and forward prefixes (RE:, FW:, Fwd:, …)
+// from a subject line, leaving the topic shared by the whole conversation.
+func conversationTopic(subject string) string {
+	s := strings.TrimSpace(subject)
+	for {
+		lower := strings.ToLower(s)
+		stripped := false
+		for _, prefix := range []string{"re:", "fw:", "fwd:", "aw:", "wg:"} {
+			if strings.HasPrefix(lower, prefix) {
+				s = strings.TrimSpace(s[len(prefix):])
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			return s
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/progress"
+)
+
+// ---------- Empty folder ----------
+
+// EmptySummary is the JSON representation of a `mail empty`.
+type EmptySummary struct {
+	Folder  string `json:"folder"`
+	Found   int    `json:"found"`
+	Deleted int    `json:"deleted"`
+}
+
+// Empty deletes every message in Deleted Items or Junk Email, as Outlook's
+// "Empty folder" does: from Deleted Items they go to Recoverable Items, and
+// from Junk Email to Deleted Items, so mail restore can still bring them back
+// until they are purged. Subfolders are left alone.
+//
+// The messages are listed first, then deleted in $batch requests of
+// maxBatchSize, with the count so far on stderr. yes must be set: nothing
+// asks before the folder is emptied.
+func Empty(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderName string, yes, jsonOutput bool) error {
+	switch strings.ToLower(folderName) {
+	case "deleteditems", "junkemail":
+	default:
+		return fmt.Errorf("mail empty only empties --folder=deleteditems or --folder=junkemail, not %q", folderName)
+	}
+	if !yes {
+		return fmt.Errorf("mail empty deletes every message in %s: add --yes to go ahead", folderName)
+	}
+	folderID, err := resolveFolderID(ctx, client, folderName)
+	if err != nil {
+		return err
+	}
+
+	messages, err := sweepList(ctx, client, folderID, nil)
+	if err != nil {
+		return err
+	}
+	summary := EmptySummary{Folder: folderName, Found: len(messages)}
+
+	failed := 0
+	report := progress.New("Deleting messages")
+	for start := 0; start < len(messages); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(messages))
+		steps := make([]*abstractions.RequestInformation, 0, end-start)
+		for _, msg := range messages[start:end] {
+			info, err := mailbox.Of(client).Messages().ByMessageId(deref(msg.GetId(), "")).ToDeleteRequestInformation(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("building batch request: %w", err)
+			}
+			steps = append(steps, info)
+		}
+		statuses, err := sendBatch(ctx, client, steps)
+		if err != nil {
+			return fmt.Errorf("emptying %s after %d of %d messages: %w", folderName, summary.Deleted, summary.Found, err)
+		}
+		failed += countFailed(statuses)
+		summary.Deleted += len(steps) - countFailed(statuses)
+		report.Report("deleted", summary.Deleted, "of", summary.Found)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d messages in %s could not be deleted; run mail empty again to retry them", failed, summary.Found, folderName)
+	}
+
+	slog.Info("Folder emptied", "folder", folderName, "count", summary.Deleted)
+	if jsonOutput {
+		return printJSON(summary)
+	}
+	return nil
+}
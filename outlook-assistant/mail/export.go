@@ -0,0 +1,221 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/progress"
+	"outlook-assistant/schema"
+)
+
+// ---------- Export to file ----------
+
+// ResultExport is the file written by `mail list --out` and `mail search
+// --out`: a manifest describing the query, then every matching message.
+type ResultExport struct {
+	Manifest ExportManifest   `json:"manifest"`
+	Messages []MessageSummary `json:"messages"`
+}
+
+// ExportManifest records what was exported and when, so a file can be
+// checked for completeness without re-running the query.
+type ExportManifest struct {
+	Action     string            `json:"action"` // list or search
+	Mailbox    string            `json:"mailbox,omitempty"`
+	Folder     string            `json:"folder,omitempty"`
+	Query      string            `json:"query,omitempty"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	StartedAt  string            `json:"startedAt"`
+	FinishedAt string            `json:"finishedAt"`
+	Pages      int               `json:"pages"`
+	Count      int               `json:"count"`
+}
+
+// exportPageSize is the page size used when following every page.
+const exportPageSize = int32(100)
+
+// exportList follows every page of a folder listing and writes the result to
+// opts.Out. config is the request List built for the first page.
+func exportList(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderID string, config *users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration, opts ListOptions, jsonOutput bool) error {
+	manifest := ExportManifest{
+		Action:    "list",
+		Mailbox:   mailbox.User(),
+		Folder:    opts.Folder,
+		Filters:   listFilters(opts),
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	top := exportPageSize
+	config.QueryParameters.Top = &top
+	config.QueryParameters.Skip = nil
+
+	var messages []models.Messageable
+	report := progress.New("Fetching messages")
+	builder := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages()
+	for {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return fmt.Errorf("listing messages (page %d): %w", manifest.Pages+1, err)
+		}
+		manifest.Pages++
+		messages = append(messages, result.GetValue()...)
+		report.Report("fetched", len(messages))
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+
+	return finishExport(subjectContains(messages, opts.Subject), manifest, opts.Out, jsonOutput)
+}
+
+// exportSearch follows every page of a search and writes the result to
+// opts.Out. config is the request Search built for the first page.
+func exportSearch(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, query string, config *users.ItemMessagesRequestBuilderGetRequestConfiguration, opts SearchOptions, jsonOutput bool) error {
+	manifest := ExportManifest{
+		Action:    "search",
+		Mailbox:   mailbox.User(),
+		Query:     query,
+		Filters:   map[string]string{},
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if opts.Since != "" {
+		manifest.Filters["since"] = opts.Since
+	}
+	if opts.Before != "" {
+		manifest.Filters["before"] = opts.Before
+	}
+	top := exportPageSize
+	config.QueryParameters.Top = &top
+
+	var messages []models.Messageable
+	report := progress.New("Fetching messages")
+	builder := mailbox.Of(client).Messages()
+	for {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return fmt.Errorf("searching messages (page %d): %w", manifest.Pages+1, err)
+		}
+		manifest.Pages++
+		messages = append(messages, result.GetValue()...)
+		report.Report("fetched", len(messages))
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+
+	messages, err := importanceIs(receivedBetween(messages, opts.Since, opts.Before), opts.Importance)
+	if err != nil {
+		return err
+	}
+	return finishExport(messages, manifest, opts.Out, jsonOutput)
+}
+
+// finishExport writes the export, caches the message IDs so the file's
+// indexes work with --ref, and reports the manifest.
+func finishExport(messages []models.Messageable, manifest ExportManifest, path string, jsonOutput bool) error {
+	export := ResultExport{Messages: make([]MessageSummary, 0, len(messages))}
+	ids := make([]string, 0, len(messages))
+	for i, msg := range messages {
+		ids = append(ids, deref(msg.GetId(), ""))
+		export.Messages = append(export.Messages, messageSummary(i+1, msg))
+	}
+	manifest.Count = len(messages)
+	manifest.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	if len(manifest.Filters) == 0 {
+		manifest.Filters = nil
+	}
+	export.Manifest = manifest
+
+	if err := writeFileAtomic(path, export); err != nil {
+		return err
+	}
+	saveIDCache(ids)
+
+	slog.Info("Export written", "messages", manifest.Count, "pages", manifest.Pages, "path", path)
+	if jsonOutput {
+		return printJSON(manifest)
+	}
+	return nil
+}
+
+// writeFileAtomic writes v as indented JSON to a temporary file next to path
+// and renames it into place, so readers never see a partial export.
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := schema.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// listFilters records the filters of a list for the manifest.
+func listFilters(opts ListOptions) map[string]string {
+	filters := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			filters[key] = value
+		}
+	}
+	set("since", opts.Since)
+	set("before", opts.Before)
+	set("from", opts.From)
+	set("to", opts.To)
+	set("domain", opts.Domain)
+	set("subject", opts.Subject)
+	set("importance", opts.Importance)
+	set("classification", opts.Classification)
+	if opts.UnreadOnly {
+		filters["unread"] = "true"
+	}
+	if opts.Flagged {
+		filters["flagged"] = "true"
+	}
+	if opts.CcMe {
+		filters["ccMe"] = "true"
+	}
+	if opts.HasAttachments {
+		filters["hasAttachments"] = "true"
+	}
+	if opts.MinSize > 0 {
+		filters["minSize"] = strconv.FormatInt(opts.MinSize, 10)
+	}
+	if opts.MaxSize > 0 {
+		filters["maxSize"] = strconv.FormatInt(opts.MaxSize, 10)
+	}
+	return filters
+}
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Follow-up flags ----------
+
+// Flag values accepted by SetFlag.
+const (
+	FlagSet      = "flagged"
+	FlagComplete = "complete"
+	FlagClear    = "notFlagged"
+)
+
+// SetFlag sets the follow-up flag of the messages identified by ref (list
+// indexes, ranges, or Graph IDs) to status. due, a date, is when a flagged
+// message should be dealt with; Outlook shows it in the Flagged view and To
+// Do. Completing a flag keeps its dates and stamps the completion time.
+func SetFlag(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, status, due string) error {
+	refs, ids, err := resolveMessageIDs(ref)
+	if err != nil {
+		return err
+	}
+
+	flag := models.NewFollowupFlag()
+	switch status {
+	case FlagSet:
+		s := models.FLAGGED_FOLLOWUPFLAGSTATUS
+		flag.SetFlagStatus(&s)
+	case FlagComplete:
+		s := models.COMPLETE_FOLLOWUPFLAGSTATUS
+		flag.SetFlagStatus(&s)
+		flag.SetCompletedDateTime(flagDate(time.Now()))
+	case FlagClear:
+		s := models.NOTFLAGGED_FOLLOWUPFLAGSTATUS
+		flag.SetFlagStatus(&s)
+	default:
+		return fmt.Errorf("unknown flag status %q", status)
+	}
+	if due != "" {
+		if status != FlagSet {
+			return fmt.Errorf("--due sets when a flagged message is due, so it cannot be combined with --complete or --clear")
+		}
+		at, err := time.ParseInLocation("2006-01-02", due, time.UTC)
+		if err != nil {
+			return fmt.Errorf("invalid --due %q — use YYYY-MM-DD", due)
+		}
+		// Graph needs a start date with a due date; a flag due in the past
+		// starts then too.
+		start := time.Now().UTC().Truncate(24 * time.Hour)
+		if at.Before(start) {
+			start = at
+		}
+		flag.SetStartDateTime(flagDate(start))
+		flag.SetDueDateTime(flagDate(at))
+	}
+	patch := models.NewMessage()
+	patch.SetFlag(flag)
+
+	if len(ids) > 1 {
+		err := bulk(ctx, client, refs, ids, "flagged", func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).ToPatchRequestInformation(ctx, patch, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("flagging messages: %w", err)
+		}
+	} else if _, err := mailbox.Of(client).Messages().ByMessageId(ids[0]).Patch(ctx, patch, nil); err != nil {
+		return fmt.Errorf("flagging message: %w", err)
+	}
+
+	switch status {
+	case FlagComplete:
+		slog.Info("Flag completed", "count", len(ids))
+	case FlagClear:
+		slog.Info("Flag cleared", "count", len(ids))
+	default:
+		slog.Info("Flagged for follow-up", "due", due, "count", len(ids))
+	}
+	return nil
+}
+
+// flagDate is t as the UTC date-time Graph takes for a flag's dates.
+func flagDate(t time.Time) models.DateTimeTimeZoneable {
+	at := t.UTC().Format("2006-01-02T15:04:05")
+	utc := "UTC"
+	d := models.NewDateTimeTimeZone()
+	d.SetDateTime(&at)
+	d.SetTimeZone(&utc)
+	return d
+}
+
+// flagOf returns a message's flag status for MessageSummary, empty when it is
+// not flagged, and the date it is due.
+func flagOf(msg models.Messageable) (status, due string) {
+	f := msg.GetFlag()
+	if f == nil || f.GetFlagStatus() == nil {
+		return "", ""
+	}
+	status = f.GetFlagStatus().String()
+	if status == FlagClear {
+		return "", ""
+	}
+	if d := f.GetDueDateTime(); d != nil {
+		due = dateOf(deref(d.GetDateTime(), ""))
+	}
+	return status, due
+}
+
+// flagMark shows a flag in the list and search tables.
+func flagMark(status, due string) string {
+	switch {
+	case status == FlagComplete:
+		return "  ✓"
+	case status == FlagSet && due != "":
+		return "  ⚑ due " + due
+	case status == FlagSet:
+		return "  ⚑"
+	}
+	return ""
+}
+
+// dateOf is the date part of a Graph date-time such as
+// 2026-03-02T00:00:00.0000000.
+func dateOf(dateTime string) string {
+	date, _, _ := strings.Cut(dateTime, "T")
+	return date
+}
@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Focused Inbox ----------
+
+// The two tabs of the Focused Inbox, as Graph names a message's
+// inferenceClassification.
+const (
+	Focused = "focused"
+	Other   = "other"
+)
+
+// parseClassification reads --as for mail classify.
+func parseClassification(as string) (*models.InferenceClassificationType, error) {
+	v, err := models.ParseInferenceClassificationType(strings.ToLower(as))
+	if err != nil || v == nil {
+		return nil, fmt.Errorf("unknown --as %q for mail classify — use focused or other", as)
+	}
+	return v.(*models.InferenceClassificationType), nil
+}
+
+// Classify moves the message identified by ref (list index or Graph ID) to
+// the Focused or Other tab of the inbox, and adds an override for its sender,
+// so later mail from them goes to the same tab, as Outlook's "Always move to
+// Focused" and "Always move to Other" do. An override for the sender that
+// already exists is changed.
+func Classify(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, as string) error {
+	classifyAs, err := parseClassification(as)
+	if err != nil {
+		return err
+	}
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "from"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading message: %w", err)
+	}
+
+	patch := models.NewMessage()
+	patch.SetInferenceClassification(classifyAs)
+	if _, err := mailbox.Of(client).Messages().ByMessageId(messageID).Patch(ctx, patch, nil); err != nil {
+		return fmt.Errorf("classifying message: %w", err)
+	}
+
+	var sender models.EmailAddressable
+	if msg.GetFrom() != nil {
+		sender = msg.GetFrom().GetEmailAddress()
+	}
+	if sender == nil || deref(sender.GetAddress(), "") == "" {
+		slog.Warn("The message has no sender, so no override was added for later mail")
+		slog.Info("Message classified", "as", classifyAs.String())
+		return nil
+	}
+	override := models.NewInferenceClassificationOverride()
+	override.SetClassifyAs(classifyAs)
+	address := models.NewEmailAddress()
+	address.SetAddress(sender.GetAddress())
+	address.SetName(sender.GetName())
+	override.SetSenderEmailAddress(address)
+	if _, err := mailbox.Of(client).InferenceClassification().Overrides().Post(ctx, override, nil); err != nil {
+		return fmt.Errorf("message classified, but adding the override for %s failed: %w", deref(sender.GetAddress(), ""), err)
+	}
+	slog.Info("Message classified, and later mail from its sender will be too", "as", classifyAs.String(), "sender", deref(sender.GetAddress(), ""))
+	return nil
+}
+
+// classificationOf returns a message's Focused Inbox tab for MessageSummary.
+func classificationOf(msg models.Messageable) string {
+	if v := msg.GetInferenceClassification(); v != nil {
+		return v.String()
+	}
+	return ""
+}
@@ -0,0 +1,440 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Follow-up reports ----------
+
+// maxScanned caps how many messages a follow-up report reads from one folder.
+const maxScanned = 1000
+
+// PendingReply is the JSON representation of a message awaiting a reply.
+type PendingReply struct {
+	Index            int      `json:"index"`
+	ID               string   `json:"id"`
+	Subject          string   `json:"subject"`
+	From             string   `json:"from"`
+	ReceivedDateTime string   `json:"receivedDateTime"`
+	AgeDays          int      `json:"ageDays"`
+	Signals          []string `json:"signals"` // question, request
+	Preview          string   `json:"preview"`
+	ConversationID   string   `json:"conversationId"`
+}
+
+// AwaitingResponse is the JSON representation of a sent message nobody has
+// answered.
+type AwaitingResponse struct {
+	Index          int      `json:"index"`
+	ID             string   `json:"id"`
+	Subject        string   `json:"subject"`
+	To             []string `json:"to"`
+	SentDateTime   string   `json:"sentDateTime"`
+	AgeDays        int      `json:"ageDays"`
+	Preview        string   `json:"preview"`
+	ConversationID string   `json:"conversationId"`
+}
+
+// requestPattern matches phrases that ask the reader to do or answer something.
+var requestPattern = regexp.MustCompile(`(?i)\b(can|could|would|will) you\b|\bplease\b|\blet me know\b|\bany (thoughts|update|news)\b|\bwhat do you think\b|\bget back to me\b|\bby (monday|tuesday|wednesday|thursday|friday|eod|end of (the )?(day|week))\b`)
+
+// automatedSender matches local parts of addresses that never read replies.
+var automatedSender = regexp.MustCompile(`(?i)^(no-?reply|do-?not-?reply|notifications?|mailer-daemon|postmaster|alerts?|bounce[s]?)([+.\-_]|@)`)
+
+// NeedsReply lists received messages since the given date or age (default:
+// 7d) that are likely waiting on the signed-in user: addressed to them
+// directly in To, asking a question or making a request in their new text,
+// from a person rather than an automated sender, with no later message from
+// them in the conversation. Messages are ranked oldest first and cached for --ref.
+func NeedsReply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, since string, jsonOutput bool) error {
+	if since == "" {
+		since = "7d"
+	}
+	start, err := parseFlexibleDate(since)
+	if err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+
+	me, err := myAddresses(ctx, client)
+	if err != nil {
+		return err
+	}
+	received, err := messagesSince(ctx, client, "inbox", "receivedDateTime", start,
+		"id", "subject", "from", "toRecipients", "receivedDateTime", "conversationId", "uniqueBody", "bodyPreview")
+	if err != nil {
+		return err
+	}
+	lastSent, err := lastSentByConversation(ctx, client, start)
+	if err != nil {
+		return err
+	}
+
+	// Keep the newest qualifying message of each conversation.
+	latest := map[string]models.Messageable{}
+	signals := map[string][]string{}
+	for _, msg := range received {
+		if _, ok := msg.(models.EventMessageable); ok || msg.GetReceivedDateTime() == nil {
+			continue
+		}
+		from := strings.ToLower(senderAddress(msg))
+		if from == "" || me[from] || automatedSender.MatchString(from) {
+			continue
+		}
+		if !addressedTo(msg.GetToRecipients(), me) {
+			continue
+		}
+		s := askSignals(newText(msg))
+		if len(s) == 0 {
+			continue
+		}
+		conv := deref(msg.GetConversationId(), deref(msg.GetId(), ""))
+		if sent, ok := lastSent[conv]; ok && !sent.Before(*msg.GetReceivedDateTime()) {
+			continue
+		}
+		if prev, ok := latest[conv]; ok && !msg.GetReceivedDateTime().After(*prev.GetReceivedDateTime()) {
+			continue
+		}
+		latest[conv] = msg
+		signals[deref(msg.GetId(), "")] = s
+	}
+
+	pending := make([]models.Messageable, 0, len(latest))
+	for _, msg := range latest {
+		pending = append(pending, msg)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].GetReceivedDateTime().Before(*pending[j].GetReceivedDateTime())
+	})
+
+	ids := make([]string, 0, len(pending))
+	results := make([]PendingReply, 0, len(pending))
+	for i, msg := range pending {
+		id := deref(msg.GetId(), "")
+		ids = append(ids, id)
+		results = append(results, PendingReply{
+			Index:            i + 1,
+			ID:               id,
+			Subject:          deref(msg.GetSubject(), ""),
+			From:             senderAddress(msg),
+			ReceivedDateTime: formatMsgTime(msg.GetReceivedDateTime()),
+			AgeDays:          int(time.Since(*msg.GetReceivedDateTime()).Hours() / 24),
+			Signals:          signals[id],
+			Preview:          deref(msg.GetBodyPreview(), ""),
+			ConversationID:   deref(msg.GetConversationId(), ""),
+		})
+	}
+	saveIDCache(ids)
+
+	if jsonOutput {
+		return printJSON(results)
+	}
+	if len(results) == 0 {
+		fmt.Printf("Nothing awaiting your reply since %s.\n", start.Format("2006-01-02"))
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-5s  %-45s  %-30s  %s\n", "#", "Age", "Subject", "From", "Asks")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, r := range results {
+		fmt.Printf("%-3d  %-5s  %-45s  %-30s  %s\n",
+			r.Index, fmt.Sprintf("%dd", r.AgeDays),
+			truncate(r.Subject, 45), truncate(r.From, 30), strings.Join(r.Signals, ", "))
+	}
+	slog.Info("Messages may need a reply — use mail reply --ref=<#>",
+		"count", len(results), "checked", len(received), "since", start.Format("2006-01-02"))
+	return nil
+}
+
+// AwaitingResponses lists messages the signed-in user sent since the given
+// date or age (default: 30d) that are at least olderThan old (default: 3d) and
+// that nobody has answered: no message from anyone else arrived later in the
+// same conversation, in any folder. Only the user's latest message in each
+// conversation is considered, so a thread they already chased once shows up
+// by that chaser. Messages are ranked oldest first and cached for --ref.
+func AwaitingResponses(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, since, olderThan string, jsonOutput bool) error {
+	if since == "" {
+		since = "30d"
+	}
+	if olderThan == "" {
+		olderThan = "3d"
+	}
+	start, err := parseFlexibleDate(since)
+	if err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+	cutoff, err := parseFlexibleDate(olderThan)
+	if err != nil {
+		return fmt.Errorf("--older-than: %w", err)
+	}
+
+	me, err := myAddresses(ctx, client)
+	if err != nil {
+		return err
+	}
+	sent, err := messagesSince(ctx, client, "sentitems", "sentDateTime", start,
+		"id", "subject", "toRecipients", "ccRecipients", "sentDateTime", "conversationId", "bodyPreview")
+	if err != nil {
+		return err
+	}
+	inbound, err := lastReceivedByConversation(ctx, client, start, me)
+	if err != nil {
+		return err
+	}
+
+	// Sent Items is newest first, so the first message seen in each
+	// conversation is the latest one sent.
+	seen := map[string]bool{}
+	var waiting []models.Messageable
+	for _, msg := range sent {
+		if _, ok := msg.(models.EventMessageable); ok || msg.GetSentDateTime() == nil {
+			continue
+		}
+		conv := deref(msg.GetConversationId(), deref(msg.GetId(), ""))
+		if seen[conv] {
+			continue
+		}
+		seen[conv] = true
+		sentAt := *msg.GetSentDateTime()
+		if sentAt.After(cutoff) || !hasOthers(msg, me) {
+			continue
+		}
+		if reply, ok := inbound[conv]; ok && reply.After(sentAt) {
+			continue
+		}
+		waiting = append(waiting, msg)
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].GetSentDateTime().Before(*waiting[j].GetSentDateTime())
+	})
+
+	ids := make([]string, 0, len(waiting))
+	results := make([]AwaitingResponse, 0, len(waiting))
+	for i, msg := range waiting {
+		to := []string{}
+		for _, r := range msg.GetToRecipients() {
+			if r.GetEmailAddress() != nil {
+				to = append(to, deref(r.GetEmailAddress().GetAddress(), ""))
+			}
+		}
+		ids = append(ids, deref(msg.GetId(), ""))
+		results = append(results, AwaitingResponse{
+			Index:          i + 1,
+			ID:             deref(msg.GetId(), ""),
+			Subject:        deref(msg.GetSubject(), ""),
+			To:             to,
+			SentDateTime:   formatMsgTime(msg.GetSentDateTime()),
+			AgeDays:        int(time.Since(*msg.GetSentDateTime()).Hours() / 24),
+			Preview:        deref(msg.GetBodyPreview(), ""),
+			ConversationID: deref(msg.GetConversationId(), ""),
+		})
+	}
+	saveIDCache(ids)
+
+	if jsonOutput {
+		return printJSON(results)
+	}
+	if len(results) == 0 {
+		fmt.Println("Every message you sent in that period has had a reply.")
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-5s  %-45s  %s\n", "#", "Age", "Subject", "To")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, r := range results {
+		fmt.Printf("%-3d  %-5s  %-45s  %s\n",
+			r.Index, fmt.Sprintf("%dd", r.AgeDays), truncate(r.Subject, 45), truncate(strings.Join(r.To, ", "), 50))
+	}
+	slog.Info("Sent messages still waiting for a response", "count", len(results))
+	return nil
+}
+
+// ---------- Helpers ----------
+
+// myAddresses returns the lowercased addresses the mailbox receives mail at:
+// its primary address, UPN, and SMTP aliases. Aliases need more than basic
+// profile access in app-only mode, so they are skipped if they can't be read.
+func myAddresses(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) (map[string]bool, error) {
+	get := func(fields ...string) (models.Userable, error) {
+		return mailbox.Of(client).Get(ctx, &users.UserItemRequestBuilderGetRequestConfiguration{
+			QueryParameters: &users.UserItemRequestBuilderGetQueryParameters{Select: fields},
+		})
+	}
+	me, err := get("mail", "userPrincipalName", "proxyAddresses")
+	if err != nil {
+		me, err = get("mail", "userPrincipalName")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading mailbox owner: %w", err)
+	}
+	addrs := map[string]bool{}
+	for _, a := range []string{deref(me.GetMail(), ""), deref(me.GetUserPrincipalName(), "")} {
+		if a != "" {
+			addrs[strings.ToLower(a)] = true
+		}
+	}
+	for _, p := range me.GetProxyAddresses() {
+		if scheme, addr, ok := strings.Cut(p, ":"); ok && strings.EqualFold(scheme, "smtp") {
+			addrs[strings.ToLower(addr)] = true
+		}
+	}
+	return addrs, nil
+}
+
+// messagesSince returns up to maxScanned messages from folder whose dateField
+// is on or after start, newest first, with bodies as plain text.
+func messagesSince(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folder, dateField string, start time.Time, fields ...string) ([]models.Messageable, error) {
+	filter := dateField + " ge " + start.UTC().Format(time.RFC3339)
+	top := int32(100)
+	config := &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+		Headers: abstractions.NewRequestHeaders(),
+		QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+			Filter:  &filter,
+			Select:  fields,
+			Top:     &top,
+			Orderby: []string{dateField + " DESC"},
+		},
+	}
+	config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+
+	var messages []models.Messageable
+	builder := mailbox.Of(client).MailFolders().ByMailFolderId(folder).Messages()
+	for len(messages) < maxScanned {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", folder, err)
+		}
+		messages = append(messages, result.GetValue()...)
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config.QueryParameters = nil
+	}
+	if len(messages) > maxScanned {
+		messages = messages[:maxScanned]
+	}
+	return messages, nil
+}
+
+// lastSentByConversation maps each conversation the user has sent mail in
+// since start to the time of their latest message in it.
+func lastSentByConversation(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, start time.Time) (map[string]time.Time, error) {
+	sent, err := messagesSince(ctx, client, "sentitems", "sentDateTime", start, "id", "conversationId", "sentDateTime")
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]time.Time{}
+	for _, msg := range sent {
+		conv := deref(msg.GetConversationId(), "")
+		if conv == "" || msg.GetSentDateTime() == nil {
+			continue
+		}
+		if t := *msg.GetSentDateTime(); t.After(last[conv]) {
+			last[conv] = t
+		}
+	}
+	return last, nil
+}
+
+// lastReceivedByConversation maps each conversation to the time of the latest
+// message in it, in any folder, that was received since start from someone
+// other than the user.
+func lastReceivedByConversation(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, start time.Time, me map[string]bool) (map[string]time.Time, error) {
+	filter := "receivedDateTime ge " + start.UTC().Format(time.RFC3339)
+	top := int32(100)
+	config := &users.ItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesRequestBuilderGetQueryParameters{
+			Filter: &filter,
+			Select: []string{"id", "from", "conversationId", "receivedDateTime"},
+			Top:    &top,
+		},
+	}
+
+	last := map[string]time.Time{}
+	scanned := 0
+	builder := mailbox.Of(client).Messages()
+	for scanned < 5*maxScanned {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("listing received messages: %w", err)
+		}
+		for _, msg := range result.GetValue() {
+			scanned++
+			conv := deref(msg.GetConversationId(), "")
+			if conv == "" || msg.GetReceivedDateTime() == nil || me[strings.ToLower(senderAddress(msg))] {
+				continue
+			}
+			if t := *msg.GetReceivedDateTime(); t.After(last[conv]) {
+				last[conv] = t
+			}
+		}
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+	return last, nil
+}
+
+// hasOthers reports whether msg was sent to anyone besides the user.
+func hasOthers(msg models.Messageable, me map[string]bool) bool {
+	for _, r := range append(msg.GetToRecipients(), msg.GetCcRecipients()...) {
+		if r.GetEmailAddress() != nil && !me[strings.ToLower(deref(r.GetEmailAddress().GetAddress(), ""))] {
+			return true
+		}
+	}
+	return false
+}
+
+func addressedTo(recipients []models.Recipientable, addrs map[string]bool) bool {
+	for _, r := range recipients {
+		if r.GetEmailAddress() != nil && addrs[strings.ToLower(deref(r.GetEmailAddress().GetAddress(), ""))] {
+			return true
+		}
+	}
+	return false
+}
+
+// newText is the part of a message its sender wrote, without quoted history
+// when Graph can tell them apart.
+func newText(msg models.Messageable) string {
+	if b := msg.GetUniqueBody(); b != nil && deref(b.GetContent(), "") != "" {
+		return deref(b.GetContent(), "")
+	}
+	return deref(msg.GetBodyPreview(), "")
+}
+
+// askSignals reports whether text asks a question or makes a request,
+// ignoring lines quoted with ">".
+func askSignals(text string) []string {
+	var own []string
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			own = append(own, line)
+		}
+	}
+	body := strings.Join(own, "\n")
+	signals := []string{}
+	if strings.Contains(body, "?") {
+		signals = append(signals, "question")
+	}
+	if requestPattern.MatchString(body) {
+		signals = append(signals, "request")
+	}
+	return signals
+}
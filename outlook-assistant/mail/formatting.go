@@ -1,7 +1,6 @@
 package mail
 
 import (
-	"fmt"
 	"html"
 	"regexp"
 	"strings"
@@ -29,6 +28,21 @@ func ParseBodyFormat(s string) BodyFormat {
 	}
 }
 
+// markdownBlock matches a line that starts a Markdown block: a heading, list
+// item, blockquote, code fence, or table row. markdownInline matches bold text, inline
+// code, or a link.
+var (
+	markdownBlock  = regexp.MustCompile("(?m)^(#{1,6} |[-*+] |\\d+\\. |> |```|~~~|\\|.*\\|[ \\t]*$)")
+	markdownInline = regexp.MustCompile("\\*\\*[^*\\n]+\\*\\*|`[^`\\n]+`|\\[[^\\]\\n]+\\]\\([^)\\s]+\\)")
+)
+
+// LooksLikeMarkdown reports whether body uses Markdown syntax, so that it
+// reads better rendered as Markdown than as plain text. Single * and _
+// emphasis is not counted, as plain text uses both for other things.
+func LooksLikeMarkdown(body string) bool {
+	return markdownBlock.MatchString(body) || markdownInline.MatchString(body)
+}
+
 // emailCSS is the base CSS injected into every outgoing email.
 const emailCSS = `
 body {
@@ -75,6 +89,11 @@ hr {
 a { color: #0066cc; }
 strong { font-weight: 600; }
 em { font-style: italic; }
+table { border-collapse: collapse; margin: 0 0 12px; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; font-weight: 600; }
+img { max-width: 100%; }
+li.task { list-style-type: none; }
 `
 
 // wrapEmailHTML wraps inner HTML content in a full HTML document with CSS.
@@ -147,155 +166,3 @@ func textToHTMLFragment(s string) string {
 	}
 	return b.String()
 }
-
-// ── Markdown → HTML ──────────────────────────────────────────────────────────
-//
-// A minimal CommonMark-compatible renderer without external dependencies.
-// Supports: headings, bold, italic, inline code, code blocks, blockquotes,
-// unordered & ordered lists, horizontal rules, links, and paragraphs.
-
-func markdownToHTML(src string) string {
-	lines := strings.Split(src, "\n")
-	var out strings.Builder
-	i := 0
-	for i < len(lines) {
-		line := lines[i]
-
-		// Fenced code block ```
-		if strings.HasPrefix(line, "```") {
-			lang := strings.TrimSpace(strings.TrimPrefix(line, "```"))
-			i++
-			var code strings.Builder
-			for i < len(lines) && !strings.HasPrefix(lines[i], "```") {
-				code.WriteString(html.EscapeString(lines[i]))
-				code.WriteByte('\n')
-				i++
-			}
-			i++ // skip closing ```
-			if lang != "" {
-				out.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
-			} else {
-				out.WriteString("<pre><code>")
-			}
-			out.WriteString(code.String())
-			out.WriteString("</code></pre>\n")
-			continue
-		}
-
-		// Blockquote
-		if strings.HasPrefix(line, "> ") || line == ">" {
-			var bq strings.Builder
-			for i < len(lines) && (strings.HasPrefix(lines[i], "> ") || lines[i] == ">") {
-				bq.WriteString(strings.TrimPrefix(strings.TrimPrefix(lines[i], ">"), " "))
-				bq.WriteByte('\n')
-				i++
-			}
-			out.WriteString("<blockquote>\n")
-			out.WriteString(markdownToHTML(bq.String()))
-			out.WriteString("</blockquote>\n")
-			continue
-		}
-
-		// Horizontal rule
-		stripped := strings.TrimSpace(line)
-		if stripped == "---" || stripped == "***" || stripped == "___" {
-			out.WriteString("<hr>\n")
-			i++
-			continue
-		}
-
-		// ATX headings
-		if strings.HasPrefix(line, "#") {
-			level := 0
-			for level < len(line) && line[level] == '#' {
-				level++
-			}
-			if level <= 6 && (len(line) == level || line[level] == ' ') {
-				content := strings.TrimSpace(line[level:])
-				tag := fmt.Sprintf("h%d", level)
-				out.WriteString("<" + tag + ">" + renderInline(content) + "</" + tag + ">\n")
-				i++
-				continue
-			}
-		}
-
-		// Unordered list
-		if isUnorderedItem(line) {
-			out.WriteString("<ul>\n")
-			for i < len(lines) && isUnorderedItem(lines[i]) {
-				content := strings.TrimSpace(regexp.MustCompile(`^[-*+] `).ReplaceAllString(lines[i], ""))
-				out.WriteString("<li>" + renderInline(content) + "</li>\n")
-				i++
-			}
-			out.WriteString("</ul>\n")
-			continue
-		}
-
-		// Ordered list
-		if isOrderedItem(line) {
-			out.WriteString("<ol>\n")
-			for i < len(lines) && isOrderedItem(lines[i]) {
-				content := strings.TrimSpace(regexp.MustCompile(`^\d+\. `).ReplaceAllString(lines[i], ""))
-				out.WriteString("<li>" + renderInline(content) + "</li>\n")
-				i++
-			}
-			out.WriteString("</ol>\n")
-			continue
-		}
-
-		// Blank line — paragraph break
-		if strings.TrimSpace(line) == "" {
-			i++
-			continue
-		}
-
-		// Paragraph — collect until blank line or block-level element
-		var para strings.Builder
-		for i < len(lines) {
-			l := lines[i]
-			if strings.TrimSpace(l) == "" {
-				break
-			}
-			if strings.HasPrefix(l, "#") || strings.HasPrefix(l, "```") ||
-				strings.HasPrefix(l, "> ") || isUnorderedItem(l) || isOrderedItem(l) ||
-				strings.TrimSpace(l) == "---" || strings.TrimSpace(l) == "***" {
-				break
-			}
-			if para.Len() > 0 {
-				para.WriteString("<br>\n")
-			}
-			para.WriteString(strings.TrimSpace(l))
-			i++
-		}
-		if para.Len() > 0 {
-			out.WriteString("<p>" + renderInline(para.String()) + "</p>\n")
-		}
-	}
-	return out.String()
-}
-
-func isUnorderedItem(line string) bool {
-	return regexp.MustCompile(`^[-*+] `).MatchString(line)
-}
-
-func isOrderedItem(line string) bool {
-	return regexp.MustCompile(`^\d+\. `).MatchString(line)
-}
-
-// renderInline processes inline Markdown: **bold**, *italic*, `code`, [link](url).
-func renderInline(s string) string {
-	// Inline code (must come before bold/italic to avoid double-processing)
-	s = regexp.MustCompile("`([^`]+)`").ReplaceAllStringFunc(s, func(m string) string {
-		inner := regexp.MustCompile("`([^`]+)`").FindStringSubmatch(m)[1]
-		return "<code>" + html.EscapeString(inner) + "</code>"
-	})
-	// Bold **text** or __text__
-	s = regexp.MustCompile(`\*\*(.+?)\*\*`).ReplaceAllString(s, "<strong>$1</strong>")
-	s = regexp.MustCompile(`__(.+?)__`).ReplaceAllString(s, "<strong>$1</strong>")
-	// Italic *text* or _text_
-	s = regexp.MustCompile(`\*(.+?)\*`).ReplaceAllString(s, "<em>$1</em>")
-	s = regexp.MustCompile(`_(.+?)_`).ReplaceAllString(s, "<em>$1</em>")
-	// Links [text](url)
-	s = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`).ReplaceAllString(s, `<a href="$2">$1</a>`)
-	return s
-}
@@ -0,0 +1,485 @@
+package mail
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ---------- HTML → Markdown ----------
+
+// htmlToMarkdown converts an HTML message body to Markdown, keeping the
+// headings, emphasis, links, images, lists, quotes, code, and tables that
+// stripHTML flattens. Tables used only for layout, as in newsletters, are
+// unpacked into their contents; Outlook safe links are unwrapped.
+func htmlToMarkdown(s string) string {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return stripHTML(s)
+	}
+	md := strings.Join(htmlBlocks(doc), "\n\n")
+	return strings.TrimSpace(stripInvisibleUnicode(md))
+}
+
+// skippedTags hold no message content.
+var skippedTags = map[atom.Atom]bool{
+	atom.Head: true, atom.Style: true, atom.Script: true, atom.Title: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Math: true, atom.Object: true, atom.Iframe: true,
+}
+
+// blockTags start a block of their own; any other element is inline.
+var blockTags = map[atom.Atom]bool{
+	atom.Html: true, atom.Body: true, atom.Address: true, atom.Article: true, atom.Aside: true,
+	atom.Blockquote: true, atom.Center: true, atom.Dd: true, atom.Details: true, atom.Dialog: true,
+	atom.Dir: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Fieldset: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true,
+	atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Summary: true, atom.Table: true, atom.Tbody: true,
+	atom.Td: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true, atom.Tr: true, atom.Ul: true,
+	atom.Caption: true,
+}
+
+var headingLevel = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// htmlBlocks renders the children of n as Markdown blocks. Inline content
+// between block elements becomes a paragraph.
+func htmlBlocks(n *html.Node) []string {
+	var out []string
+	var para strings.Builder
+	flush := func() {
+		if p := tidyInline(para.String()); p != "" {
+			out = append(out, p)
+		}
+		para.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.DataAtom] {
+			flush()
+			out = append(out, htmlBlock(c)...)
+			continue
+		}
+		para.WriteString(htmlInline(c))
+	}
+	flush()
+	return out
+}
+
+func htmlBlock(n *html.Node) []string {
+	if level, ok := headingLevel[n.DataAtom]; ok {
+		text := strings.ReplaceAll(tidyInline(inlineChildren(n)), "\n", " ")
+		if text == "" {
+			return nil
+		}
+		return []string{strings.Repeat("#", level) + " " + text}
+	}
+	switch n.DataAtom {
+	case atom.Hr:
+		return []string{"---"}
+	case atom.Pre:
+		return []string{mdFence(strings.Trim(textOf(n), "\n"), codeLanguage(n))}
+	case atom.Blockquote:
+		inner := strings.Join(htmlBlocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return []string{strings.Join(lines, "\n")}
+	case atom.Ul, atom.Ol:
+		if list := htmlList(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case atom.Table:
+		if isDataTable(n) {
+			return []string{htmlTable(n)}
+		}
+	}
+	return htmlBlocks(n)
+}
+
+// htmlList renders a list, nesting the lists inside its items.
+func htmlList(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attrOf(n, "start")); err == nil && ordered {
+		number = start
+	}
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		// A list directly inside a list belongs to the item before it.
+		if (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) && len(items) > 0 {
+			items[len(items)-1] += "\n" + indentLines(htmlList(c), "   ")
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		var body strings.Builder
+		for i, block := range htmlBlocks(c) {
+			switch {
+			case i == 0:
+			case listMarker.MatchString(block):
+				body.WriteString("\n")
+			default:
+				body.WriteString("\n\n")
+			}
+			body.WriteString(block)
+		}
+		items = append(items, marker+strings.TrimLeft(indentLines(body.String(), strings.Repeat(" ", len(marker))), " "))
+	}
+	return strings.Join(items, "\n")
+}
+
+// isDataTable reports whether a table holds data, rather than laying out
+// the message: at least two rows and two columns, and no table inside it.
+func isDataTable(n *html.Node) bool {
+	if attrOf(n, "role") == "presentation" {
+		return false
+	}
+	rows, columns := 0, 0
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Table:
+				return false
+			case atom.Tr:
+				rows++
+				cells := 0
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						cells++
+					}
+				}
+				columns = max(columns, cells)
+			}
+			if !walk(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return walk(n) && rows >= 2 && columns >= 2
+}
+
+// htmlTable renders a data table as a GitHub table whose header is the first
+// row.
+func htmlTable(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						text := strings.Join(htmlBlocks(cell), "<br>")
+						text = strings.ReplaceAll(strings.ReplaceAll(text, "\n", "<br>"), "|", `\|`)
+						row = append(row, text)
+					}
+				}
+				rows = append(rows, row)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// htmlInline renders n as inline Markdown. A block element met inside an
+// inline one is set on lines of its own.
+func htmlInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return mdEscape(collapseWhitespace(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if skippedTags[n.DataAtom] {
+		return ""
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Strong, atom.B:
+		return mdWrap("**", inlineChildren(n))
+	case atom.Em, atom.I, atom.Cite:
+		return mdWrap("*", inlineChildren(n))
+	case atom.S, atom.Strike, atom.Del:
+		return mdWrap("~~", inlineChildren(n))
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return mdCode(textOf(n))
+	case atom.A:
+		return htmlLink(n)
+	case atom.Img:
+		return htmlImage(n)
+	case atom.Input:
+		if strings.EqualFold(attrOf(n, "type"), "checkbox") {
+			if hasAttr(n, "checked") {
+				return "[x] "
+			}
+			return "[ ] "
+		}
+		return ""
+	}
+	if blockTags[n.DataAtom] {
+		return "\n" + inlineChildren(n) + "\n"
+	}
+	return inlineChildren(n)
+}
+
+func inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(htmlInline(c))
+	}
+	return b.String()
+}
+
+func htmlLink(n *html.Node) string {
+	text := inlineChildren(n)
+	label := strings.TrimSpace(text)
+	href := unwrapSafeLink(strings.TrimSpace(attrOf(n, "href")))
+	lower := strings.ToLower(href)
+	switch {
+	case label == "":
+		return text
+	case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:"):
+		return text
+	case strings.HasPrefix(lower, "mailto:") && strings.EqualFold(href[len("mailto:"):], label):
+		return text
+	case href == label && !strings.ContainsAny(href, " <>"):
+		return mdWrap("", "<"+href+">")
+	}
+	return mdWrapWith(text, "["+label+"]("+mdDestination(href)+")")
+}
+
+func htmlImage(n *html.Node) string {
+	src := strings.TrimSpace(attrOf(n, "src"))
+	// A 1×1 image is a tracking pixel, not content.
+	if src == "" || attrOf(n, "width") == "1" || attrOf(n, "height") == "1" {
+		return ""
+	}
+	alt := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(collapseWhitespace(attrOf(n, "alt")))
+	return "![" + strings.TrimSpace(alt) + "](" + mdDestination(src) + ")"
+}
+
+// unwrapSafeLink returns the original URL of an Outlook safe link, which
+// Defender for Office 365 wraps around every link in a message.
+func unwrapSafeLink(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || !strings.HasSuffix(strings.ToLower(u.Hostname()), "safelinks.protection.outlook.com") {
+		return href
+	}
+	if original := u.Query().Get("url"); original != "" {
+		return original
+	}
+	return href
+}
+
+// mdDestination writes a link destination so that spaces and parentheses in
+// it do not end it: in angle brackets, which keep a file path as it is, or
+// else percent-encoded.
+func mdDestination(href string) string {
+	if !strings.ContainsAny(href, " ()") {
+		return href
+	}
+	if !strings.ContainsAny(href, "<>\n") {
+		return "<" + href + ">"
+	}
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E", "\n", "").Replace(href)
+}
+
+// mdWrap puts marker around the text of s, leaving its surrounding spaces
+// outside, where emphasis needs them.
+func mdWrap(marker, s string) string {
+	inner := strings.TrimSpace(s)
+	if inner == "" {
+		return s
+	}
+	return mdWrapWith(s, marker+inner+marker)
+}
+
+// mdWrapWith replaces the text of s with inner, keeping its surrounding
+// spaces and line breaks.
+func mdWrapWith(s, inner string) string {
+	trimmed := strings.TrimLeft(s, " \n")
+	lead := s[:len(s)-len(trimmed)]
+	trail := trimmed[len(strings.TrimRight(trimmed, " \n")):]
+	return lead + inner + trail
+}
+
+// mdCode renders inline code, fenced by more backticks than it holds.
+func mdCode(code string) string {
+	code = collapseWhitespace(code)
+	if strings.TrimSpace(code) == "" {
+		return code
+	}
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+// mdFence renders a code block, fenced by more backticks than it holds.
+func mdFence(code, lang string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// codeLanguage reads the language-xxx class that marks a code block's
+// language, on the <pre> or the <code> inside it.
+func codeLanguage(pre *html.Node) string {
+	for n := pre; n != nil; n = n.FirstChild {
+		for _, class := range strings.Fields(attrOf(n, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				return lang
+			}
+		}
+		if n != pre && n.DataAtom != atom.Code {
+			break
+		}
+	}
+	return ""
+}
+
+// textOf is the text of n as written, with <br> as a line break.
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.DataAtom == atom.Br {
+		return "\n"
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textOf(c))
+	}
+	return b.String()
+}
+
+func attrOf(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+var spaceRun = regexp.MustCompile(`[ \t\r\n\f\x{00A0}]+`)
+
+// collapseWhitespace turns each run of whitespace, including non-breaking
+// spaces, into one space, as a browser shows it.
+func collapseWhitespace(s string) string {
+	return spaceRun.ReplaceAllString(s, " ")
+}
+
+// tidyInline trims the lines of a paragraph and keeps at most one blank line
+// between them.
+func tidyInline(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(strings.Join(strings.Fields(line), " "))
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// mdEscape escapes the characters of text that Markdown would read as
+// emphasis, code, or HTML: *, `, _ at the edge of a word, and < before a tag
+// name.
+func mdEscape(s string) string {
+	if !strings.ContainsAny(s, "*`_<\\") {
+		return s
+	}
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '*', '`', '\\':
+			b.WriteByte('\\')
+		case '_':
+			before, _ := utf8.DecodeLastRuneInString(s[:i])
+			after, _ := utf8.DecodeRuneInString(s[i+1:])
+			if !isWordRune(before) || !isWordRune(after) {
+				b.WriteByte('\\')
+			}
+		case '<':
+			if after, _ := utf8.DecodeRuneInString(s[i+1:]); unicode.IsLetter(after) || after == '/' || after == '!' {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// indentLines prefixes every non-empty line of s with indent.
+func indentLines(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
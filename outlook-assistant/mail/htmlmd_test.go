@@ -0,0 +1,81 @@
+package mail
+
+import "testing"
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain text", "plain text", "plain text"},
+		{"empty elements", "<h1></h1><p></p>", ""},
+		{"paragraphs", "<p>Hello <b>world</b></p><p>Second</p>", "Hello **world**\n\nSecond"},
+		{"divs", "<div>one</div><div>two</div>", "one\n\ntwo"},
+		{"line break", "<p>a<br>b</p>", "a\nb"},
+		{"whitespace", "<p>  lots   of\n\n spaces&nbsp;here </p>", "lots of spaces here"},
+		{"invisible characters", "<p>zero​width</p>", "zerowidth"},
+		{"unclosed tags", "<p>un<b>clos", "un**clos**"},
+		{"not HTML", "<<<>>>", "<<<>>>"},
+
+		{"heading", "<h2>Title</h2>", "## Title"},
+		{"emphasis", "<em>a</em> <i>b</i> <strong>c</strong>", "*a* *b* **c**"},
+		{"nested emphasis", "<b>bold<i>both</i></b>", "**bold*both***"},
+		{"spaces inside emphasis", "<p><b> spaced </b>x</p>", "**spaced** x"},
+		{"strikethrough", "<s>gone</s> <del>x</del>", "~~gone~~ ~~x~~"},
+		{"rule", "<hr>", "---"},
+
+		{"link", `<a href="https://x.com">x</a>`, "[x](https://x.com)"},
+		{"safe link", `<a href="https://nam12.safelinks.protection.outlook.com/?url=https%3A%2F%2Fexample.com%2Fa&amp;data=1">ex</a>`, "[ex](https://example.com/a)"},
+		{"link without href", "<a>no href</a>", "no href"},
+		{"mailto link to itself", `<a href="mailto:a@b.com">a@b.com</a>`, "a@b.com"},
+		{"space in href", `<a href="https://x.com/a b">sp</a>`, "[sp](<https://x.com/a b>)"},
+		{"image", `<img src="i.png" alt="pic">`, "![pic](i.png)"},
+		{"inline image", `<img src="cid:abc">`, "![](cid:abc)"},
+
+		{"nested list", "<ul><li>a</li><li>b<ul><li>c</li></ul></li></ul>", "- a\n- b\n  - c"},
+		{"ordered list start", `<ol start="3"><li>x</li><li>y</li></ol>`, "3. x\n4. y"},
+		{"blockquote", "<blockquote><p>q1</p><p>q2</p></blockquote>", "> q1\n>\n> q2"},
+
+		{"code block", `<pre><code class="language-go">x := 1` + "\n\n" + `fmt.Println(x)</code></pre>`, "```go\nx := 1\n\nfmt.Println(x)\n```"},
+		{"backtick in code", "<code>a`b</code>", "``a`b``"},
+		{"fence in a code block", "<pre>has ``` fence</pre>", "````\nhas ``` fence\n````"},
+
+		{"data table", "<table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td>2|3</td></tr></table>", "| A | B |\n| --- | --- |\n| 1 | 2\\|3 |"},
+		{"layout table", "<table><tr><td><p>layout</p></td></tr></table>", "layout"},
+
+		{"skipped elements", "<style>p{}</style><script>x</script><p>kept</p>", "kept"},
+		{"Markdown characters escaped", "<p>*stars* and _under_ and # hash</p>", "\\*stars\\* and \\_under\\_ and # hash"},
+		{"entities", "<p>&lt;tag&gt; &amp; &copy;</p>", "\\<tag> & ©"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToMarkdown(tt.html); got != tt.want {
+				t.Errorf("htmlToMarkdown(%q)\n got: %q\nwant: %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHTMLMarkdownRoundTrip checks that the Markdown htmlToMarkdown writes
+// renders back to the same structure, escapes included.
+func TestHTMLMarkdownRoundTrip(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<b>bold<i>both</i></b>", "<p><strong>bold<em>both</em></strong></p>\n"},
+		{"<p>*stars* and _under_</p>", "<p>*stars* and _under_</p>\n"},
+		{"<p>&lt;tag&gt;</p>", "<p>&lt;tag&gt;</p>\n"},
+		{`<ol start="3"><li>x</li></ol>`, "<ol start=\"3\">\n<li>x</li>\n</ol>\n"},
+		{`<a href="https://x.com/a b">sp</a>`, "<p><a href=\"https://x.com/a%20b\">sp</a></p>\n"},
+		{"<table><tr><th>A</th><th>B</th></tr><tr><td>1|2</td><td>3</td></tr></table>", "<table>\n<thead>\n<tr><th>A</th><th>B</th></tr>\n</thead>\n<tbody>\n<tr><td>1|2</td><td>3</td></tr>\n</tbody>\n</table>\n"},
+	}
+	for _, tt := range tests {
+		md := htmlToMarkdown(tt.html)
+		if got := markdownToHTML(md); got != tt.want {
+			t.Errorf("%q → %q →\n got: %q\nwant: %q", tt.html, md, got, tt.want)
+		}
+	}
+}
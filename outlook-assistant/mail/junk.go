@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// ---------- Junk email settings ----------
+
+// trustContactsUnavailable explains why the trust-contacts toggle cannot be
+// read or changed: Graph v1.0 does not expose the mailbox junk-mail options.
+const trustContactsUnavailable = "the \"trust email from my contacts\" option is not exposed by Microsoft Graph — change it in Outlook on the web under Settings > Mail > Junk email"
+
+// JunkSettings is the JSON representation of the junk mail configuration.
+// TrustContacts is always null because Graph cannot read it.
+type JunkSettings struct {
+	BlockedSenders []string `json:"blockedSenders"`
+	SafeSenders    []string `json:"safeSenders"`
+	BlockedDomains []string `json:"blockedDomains"`
+	SafeDomains    []string `json:"safeDomains"`
+	TrustContacts  *bool    `json:"trustContacts"`
+}
+
+// JunkUpdate lists the changes requested by `settings junk`. Domain lists are
+// comma-separated; Safe selects the safe domain list instead of the blocked one.
+type JunkUpdate struct {
+	AddDomains    string
+	RemoveDomains string
+	Safe          bool
+	TrustContacts string
+}
+
+// Junk applies any requested domain list changes and then prints the junk
+// mail configuration: blocked and safe senders (see Blocklist) and domains.
+func Junk(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, update JunkUpdate, jsonOutput bool) error {
+	if update.TrustContacts != "" {
+		return fmt.Errorf("cannot set --trust-contacts: %s", trustContactsUnavailable)
+	}
+
+	if update.AddDomains != "" || update.RemoveDomains != "" {
+		list := blockedDomains
+		if update.Safe {
+			list = safeDomains
+		}
+		if err := list.update(ctx, client, splitList(update.AddDomains), splitList(update.RemoveDomains)); err != nil {
+			return err
+		}
+	}
+
+	var out JunkSettings
+	for _, l := range []struct {
+		list senderList
+		dst  *[]string
+	}{
+		{blockedSenders, &out.BlockedSenders},
+		{safeSenders, &out.SafeSenders},
+		{blockedDomains, &out.BlockedDomains},
+		{safeDomains, &out.SafeDomains},
+	} {
+		entries, _, err := l.list.load(ctx, client)
+		if err != nil {
+			return err
+		}
+		*l.dst = nonNil(entries)
+	}
+
+	if jsonOutput {
+		return printJSON(out)
+	}
+
+	for _, section := range []struct {
+		title   string
+		entries []string
+	}{
+		{"Blocked senders", out.BlockedSenders},
+		{"Safe senders", out.SafeSenders},
+		{"Blocked domains", out.BlockedDomains},
+		{"Safe domains", out.SafeDomains},
+	} {
+		fmt.Printf("\n%s (%d)\n", section.title, len(section.entries))
+		fmt.Println(strings.Repeat("-", 40))
+		for _, e := range section.entries {
+			fmt.Println(e)
+		}
+	}
+	fmt.Printf("\nTrust contacts: unknown (%s)\n", trustContactsUnavailable)
+	return nil
+}
@@ -4,18 +4,27 @@ package mail
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	abstractions "github.com/microsoft/kiota-abstractions-go"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	"github.com/microsoftgraph/msgraph-sdk-go/users"
 
 	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/charset"
+	"outlook-assistant/mailbox"
+	"outlook-assistant/reldate"
+	"outlook-assistant/schema"
 )
 
 // ---------- JSON output types ----------
@@ -30,6 +39,21 @@ type MessageSummary struct {
 	IsRead           bool     `json:"isRead"`
 	BodyPreview      string   `json:"bodyPreview"`
 	Categories       []string `json:"categories,omitempty"`
+	Importance       string   `json:"importance,omitempty"`     // low, normal, or high
+	Classification   string   `json:"classification,omitempty"` // focused or other: the inbox tab it is on
+	Flag             string   `json:"flag,omitempty"`           // flagged or complete; omitted when not flagged
+	FlagDue          string   `json:"flagDue,omitempty"`        // YYYY-MM-DD
+	HasAttachments   bool     `json:"hasAttachments"`
+	Size             int64    `json:"size"` // bytes
+	Threading
+}
+
+// MessageList is the JSON representation of one page of `mail list`.
+type MessageList struct {
+	Page     int              `json:"page"`
+	Count    int              `json:"count"`
+	HasMore  bool             `json:"hasMore"`
+	Messages []MessageSummary `json:"messages"`
 }
 
 // MessageDetail is the JSON representation of a fully-read message.
@@ -40,7 +64,23 @@ type MessageDetail struct {
 	To               []string `json:"to"`
 	ReceivedDateTime string   `json:"receivedDateTime"`
 	Body             string   `json:"body"`
+	NewContent       *string  `json:"newContent,omitempty"`    // with --split-quotes
+	QuotedContent    *string  `json:"quotedContent,omitempty"` // with --split-quotes
 	Categories       []string `json:"categories,omitempty"`
+	Size             int64    `json:"size,omitempty"` // bytes
+	Threading
+	Attachments []AttachmentInfo `json:"attachments,omitempty"` // with --save-dir
+	Images      []AttachmentInfo `json:"images,omitempty"`      // with --save-images
+}
+
+// Threading holds the identifiers needed to reconstruct conversations and
+// deduplicate messages without further Graph calls. It is embedded in
+// MessageSummary and MessageDetail.
+type Threading struct {
+	ConversationID    string `json:"conversationId,omitempty"`
+	ConversationIndex string `json:"conversationIndex,omitempty"` // base64
+	InternetMessageID string `json:"internetMessageId,omitempty"`
+	InReplyTo         string `json:"inReplyTo,omitempty"`
 }
 
 // FolderSummary is the JSON representation of a mail folder.
@@ -52,11 +92,23 @@ type FolderSummary struct {
 	UnreadItems int32  `json:"unreadItems"`
 }
 
+// FolderNode is the JSON representation of a mail folder and its subfolders,
+// as produced by `mail folders --tree`.
+type FolderNode struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	TotalItems  int32         `json:"totalItems"`
+	UnreadItems int32         `json:"unreadItems"`
+	Children    []*FolderNode `json:"children,omitempty"`
+
+	childCount int32
+}
+
 // ---------- ID cache (stored in home directory) ----------
 
 func idCachePath() string {
 	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".outlook-assistant-mail-cache.json")
+	return filepath.Join(home, mailbox.CacheFile(".outlook-assistant-mail-cache.json"))
 }
 
 func saveIDCache(ids []string) {
@@ -105,49 +157,181 @@ func resolveMessageID(ref string) (string, error) {
 	return ref, nil
 }
 
+// ---------- Threading ----------
+
+// inReplyToProperty is the MAPI PR_IN_REPLY_TO_ID property. Graph v1.0 has no
+// first-class inReplyTo field, so it is fetched as an extended property.
+const inReplyToProperty = "String 0x1042"
+
+// summaryFields are the message properties selected for list and search results.
+var summaryFields = []string{
+	"id", "subject", "from", "receivedDateTime", "isRead", "bodyPreview", "categories", "importance",
+	"inferenceClassification", "flag", "hasAttachments", "conversationId", "conversationIndex", "internetMessageId",
+}
+
+// extendedExpand expands the extended properties holding In-Reply-To and the
+// message size.
+var extendedExpand = []string{
+	"singleValueExtendedProperties($filter=id eq '" + inReplyToProperty + "' or id eq '" + sizeProperty + "')",
+}
+
+// threadingOf extracts threading identifiers from a message fetched with
+// the conversation fields selected and extendedExpand applied.
+func threadingOf(msg models.Messageable) Threading {
+	t := Threading{
+		ConversationID:    deref(msg.GetConversationId(), ""),
+		InternetMessageID: deref(msg.GetInternetMessageId(), ""),
+	}
+	if idx := msg.GetConversationIndex(); len(idx) > 0 {
+		t.ConversationIndex = base64.StdEncoding.EncodeToString(idx)
+	}
+	for _, p := range msg.GetSingleValueExtendedProperties() {
+		if strings.EqualFold(deref(p.GetId(), ""), inReplyToProperty) {
+			t.InReplyTo = deref(p.GetValue(), "")
+		}
+	}
+	return t
+}
+
+// messageFields is what a MessageSummary or MessageDetail is built from: a
+// message decoded by the SDK (sdkMessage) or by the thin client
+// (thinMessage), so that both print the same output.
+type messageFields interface {
+	id() string
+	subject() string
+	sender() string
+	recipients() []string
+	received() string
+	isRead() bool
+	preview() string
+	categories() []string
+	importance() string
+	classification() string
+	flag() (status, due string)
+	hasAttachments() bool
+	size() int64
+	threading() Threading
+	// body and uniqueBody return the body and uniqueBody as text, or as
+	// Markdown with opts.Markdown, and "" when they were not fetched.
+	body(opts ReadOptions) string
+	uniqueBody(opts ReadOptions) string
+}
+
+// messageSummary converts a listed message into its JSON representation.
+func messageSummary(index int, msg models.Messageable) MessageSummary {
+	return summaryOf(index, sdkMessage{msg})
+}
+
+// summaryOf builds the JSON representation of m, listed at index.
+func summaryOf(index int, m messageFields) MessageSummary {
+	flag, due := m.flag()
+	return MessageSummary{
+		Index:            index,
+		ID:               m.id(),
+		Subject:          m.subject(),
+		From:             m.sender(),
+		ReceivedDateTime: m.received(),
+		IsRead:           m.isRead(),
+		BodyPreview:      m.preview(),
+		Categories:       m.categories(),
+		Importance:       m.importance(),
+		Classification:   m.classification(),
+		Flag:             flag,
+		FlagDue:          due,
+		HasAttachments:   m.hasAttachments(),
+		Size:             m.size(),
+		Threading:        m.threading(),
+	}
+}
+
+// sdkMessage provides the messageFields of a message decoded by the SDK.
+type sdkMessage struct {
+	msg models.Messageable
+}
+
+func (m sdkMessage) id() string                 { return deref(m.msg.GetId(), "") }
+func (m sdkMessage) subject() string            { return deref(m.msg.GetSubject(), "") }
+func (m sdkMessage) sender() string             { return senderAddress(m.msg) }
+func (m sdkMessage) received() string           { return formatMsgTime(m.msg.GetReceivedDateTime()) }
+func (m sdkMessage) isRead() bool               { return m.msg.GetIsRead() != nil && *m.msg.GetIsRead() }
+func (m sdkMessage) preview() string            { return deref(m.msg.GetBodyPreview(), "") }
+func (m sdkMessage) categories() []string       { return m.msg.GetCategories() }
+func (m sdkMessage) importance() string         { return importanceOf(m.msg) }
+func (m sdkMessage) classification() string     { return classificationOf(m.msg) }
+func (m sdkMessage) flag() (status, due string) { return flagOf(m.msg) }
+func (m sdkMessage) size() int64                { return sizeOf(m.msg) }
+func (m sdkMessage) threading() Threading       { return threadingOf(m.msg) }
+func (m sdkMessage) body(opts ReadOptions) string {
+	return extractBody(m.msg, opts)
+}
+
+func (m sdkMessage) hasAttachments() bool {
+	return m.msg.GetHasAttachments() != nil && *m.msg.GetHasAttachments()
+}
+
+func (m sdkMessage) recipients() []string {
+	to := []string{}
+	for _, r := range m.msg.GetToRecipients() {
+		if r.GetEmailAddress() != nil {
+			to = append(to, deref(r.GetEmailAddress().GetAddress(), ""))
+		}
+	}
+	return to
+}
+
+func (m sdkMessage) uniqueBody(opts ReadOptions) string {
+	b := m.msg.GetUniqueBody()
+	if b == nil {
+		return ""
+	}
+	text := charset.Repair(deref(b.GetContent(), ""))
+	if b.GetContentType() != nil && *b.GetContentType() == models.HTML_BODYTYPE {
+		text = htmlText(text, opts)
+	}
+	return text
+}
+
 // ---------- List ----------
 
 // ListOptions holds optional filter parameters for List.
 type ListOptions struct {
-	Since      string // RFC3339 or "2006-01-02" lower bound on receivedDateTime
-	Before     string // RFC3339 or "2006-01-02" upper bound on receivedDateTime
-	From       string // filter by sender email address
-	UnreadOnly bool   // only return unread messages
-	Folder     string // folder name or well-known name (default: inbox)
-	Subject    string // client-side subject substring filter (case-insensitive)
+	Since          string // RFC3339 or "2006-01-02" lower bound on receivedDateTime
+	Before         string // RFC3339 or "2006-01-02" upper bound on receivedDateTime
+	From           string // filter by sender email address
+	To             string // only messages with this address on the To line
+	CcMe           bool   // only messages with the mailbox owner on the Cc line
+	Domain         string // only messages from senders at this domain, e.g. vendor.com
+	UnreadOnly     bool   // only return unread messages
+	Flagged        bool   // only return messages flagged for follow-up
+	Importance     string // only return messages of this importance: low, normal, or high
+	Classification string // focused or other: only messages on that tab of the Focused Inbox
+	Folder         string // folder name or well-known name (default: inbox)
+	Subject        string // client-side subject substring filter (case-insensitive)
+	HasAttachments bool   // only messages with file attachments
+	MinSize        int64  // only messages of at least this many bytes
+	MaxSize        int64  // only messages of at most this many bytes
+	Out            string // write every page to this JSON file instead of printing one
+	MarkRead       bool   // mark the displayed unread messages as read afterwards
+	NewerThan      string // age such as 7d; stands in for Since
+	OlderThan      string // age such as 3w; stands in for Before
+
+	me string // the mailbox owner's address, for CcMe; set by resolveMe
 }
 
 // List prints inbox emails for the given page with optional filters.
 // Page is 1-based; page 1 resets the ID cache, subsequent pages append to it
 // so that index references remain valid across multi-page fetches.
 func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count int32, page int, opts ListOptions, jsonOutput bool) error {
-	// Build $filter expression from options.
-	var filters []string
-	if opts.Since != "" {
-		t, err := parseFlexibleDate(opts.Since)
-		if err != nil {
-			return fmt.Errorf("--since: %w", err)
-		}
-		filters = append(filters, "receivedDateTime ge "+t.UTC().Format(time.RFC3339))
-	}
-	if opts.Before != "" {
-		t, err := parseFlexibleDate(opts.Before)
-		if err != nil {
-			return fmt.Errorf("--before: %w", err)
-		}
-		filters = append(filters, "receivedDateTime le "+t.UTC().Format(time.RFC3339))
-	}
-	if opts.From != "" {
-		filters = append(filters, fmt.Sprintf("from/emailAddress/address eq '%s'", opts.From))
+	var err error
+	if opts.Since, opts.Before, err = ageBounds(opts.Since, opts.Before, opts.NewerThan, opts.OlderThan); err != nil {
+		return err
 	}
-	if opts.UnreadOnly {
-		filters = append(filters, "isRead eq false")
+	if err := opts.resolveMe(ctx, client); err != nil {
+		return err
 	}
-
-	var filterPtr *string
-	if len(filters) > 0 {
-		s := strings.Join(filters, " and ")
-		filterPtr = &s
+	filterPtr, err := listFilter(opts)
+	if err != nil {
+		return err
 	}
 
 	skip := int32((page - 1) * int(count))
@@ -160,7 +344,8 @@ func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count in
 	}
 
 	requestParams := &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
-		Select:  []string{"id", "subject", "from", "receivedDateTime", "isRead", "bodyPreview", "categories"},
+		Select:  summaryFields,
+		Expand:  extendedExpand,
 		Top:     &count,
 		Skip:    &skip,
 		Orderby: []string{orderField + " DESC"},
@@ -179,30 +364,142 @@ func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count in
 		}
 	}
 
-	result, err := client.Me().MailFolders().ByMailFolderId(folderID).Messages().Get(ctx, config)
+	if opts.Out != "" {
+		if opts.MarkRead {
+			return fmt.Errorf("--mark-read cannot be combined with --out")
+		}
+		return exportList(ctx, client, folderID, config, opts, jsonOutput)
+	}
+	if thinMode() {
+		return thinList(ctx, client, folderID, requestParams, page, opts, jsonOutput)
+	}
+
+	result, err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages().Get(ctx, config)
 	if err != nil {
 		return fmt.Errorf("listing messages: %w", err)
 	}
 
-	messages := result.GetValue()
+	messages := subjectContains(result.GetValue(), opts.Subject)
+	summaries := make([]MessageSummary, 0, len(messages))
+	for i, msg := range messages {
+		summaries = append(summaries, messageSummary(i+1, msg))
+	}
+	return showList(ctx, client, summaries, page, result.GetOdataNextLink() != nil, opts, jsonOutput)
+}
+
+// listFilter builds the $filter expression for the options List sends to
+// Graph; it is nil when nothing is filtered. Since and Before must already
+// have any ages resolved.
+func listFilter(opts ListOptions) (*string, error) {
+	var filters []string
 
-	// Client-side subject filter (Graph does not support subject $filter reliably).
-	if opts.Subject != "" {
-		lower := strings.ToLower(opts.Subject)
-		filtered := make([]models.Messageable, 0, len(messages))
-		for _, msg := range messages {
-			if strings.Contains(strings.ToLower(deref(msg.GetSubject(), "")), lower) {
-				filtered = append(filtered, msg)
-			}
+	if opts.Since != "" {
+		t, err := parseFlexibleDate(opts.Since)
+		if err != nil {
+			return nil, fmt.Errorf("--since: %w", err)
+		}
+		filters = append(filters, "receivedDateTime ge "+t.UTC().Format(time.RFC3339))
+	}
+	if opts.Before != "" {
+		t, err := parseFlexibleDate(opts.Before)
+		if err != nil {
+			return nil, fmt.Errorf("--before: %w", err)
 		}
-		messages = filtered
+		filters = append(filters, "receivedDateTime le "+t.UTC().Format(time.RFC3339))
+	}
+	if opts.From != "" {
+		filters = append(filters, fmt.Sprintf("from/emailAddress/address eq '%s'", strings.ReplaceAll(opts.From, "'", "''")))
+	}
+	if opts.Domain != "" {
+		domain, err := senderDomain(opts.Domain)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fmt.Sprintf("endswith(from/emailAddress/address, '%s')", strings.ReplaceAll(domain, "'", "''")))
+	}
+	if opts.To != "" {
+		filters = append(filters, recipientFilter("toRecipients", opts.To))
+	}
+	if opts.CcMe {
+		filters = append(filters, recipientFilter("ccRecipients", opts.me))
+	}
+	if opts.UnreadOnly {
+		filters = append(filters, "isRead eq false")
+	}
+	if opts.Flagged {
+		filters = append(filters, "flag/flagStatus eq 'flagged'")
+	}
+	if opts.Importance != "" {
+		f, err := importanceFilter(opts.Importance)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if opts.Classification != "" {
+		filters = append(filters, fmt.Sprintf("inferenceClassification eq '%s'", opts.Classification))
+	}
+	if opts.HasAttachments {
+		filters = append(filters, "hasAttachments eq true")
+	}
+	if opts.MinSize > 0 {
+		filters = append(filters, sizeFilter("ge", opts.MinSize))
+	}
+	if opts.MaxSize > 0 {
+		filters = append(filters, sizeFilter("le", opts.MaxSize))
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	s := strings.Join(filters, " and ")
+	return &s, nil
+
+}
+
+// resolveMe looks up the mailbox owner's address when CcMe needs it.
+func (opts *ListOptions) resolveMe(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) error {
+	if !opts.CcMe || opts.me != "" {
+		return nil
+	}
+	me, err := mailbox.Of(client).Get(ctx, &users.UserItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.UserItemRequestBuilderGetQueryParameters{Select: []string{"mail", "userPrincipalName"}},
+	})
+	if err != nil {
+		return fmt.Errorf("reading mailbox owner for --cc-me: %w", err)
+	}
+	opts.me = deref(me.GetMail(), deref(me.GetUserPrincipalName(), ""))
+	if opts.me == "" {
+		return fmt.Errorf("--cc-me: the mailbox has no email address")
 	}
+	return nil
+}
 
+// recipientFilter matches messages with address among the recipients in
+// field, toRecipients or ccRecipients.
+func recipientFilter(field, address string) string {
+	return fmt.Sprintf("%s/any(r: r/emailAddress/address eq '%s')", field, strings.ReplaceAll(address, "'", "''"))
+}
+
+// senderDomain turns vendor.com, @vendor.com, or *@vendor.com into the
+// "@vendor.com" a sender's address ends with.
+func senderDomain(domain string) (string, error) {
+	d := strings.ToLower(strings.TrimSpace(domain))
+	d = strings.TrimPrefix(strings.TrimPrefix(d, "*"), "@")
+	if d == "" || strings.ContainsAny(d, "@*' ") || !strings.Contains(d, ".") {
+		return "", fmt.Errorf("--domain %q is not a domain such as vendor.com", domain)
+	}
+	return "@" + d, nil
+}
+
+// showList caches the IDs of one listed page, prints it, and marks it read
+// when opts.MarkRead is set. hasMore reports whether more pages exist.
+func showList(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, summaries []MessageSummary, page int, hasMore bool, opts ListOptions, jsonOutput bool) error {
 	// Update ID cache: page 1 resets it; subsequent pages accumulate so that
 	// index references stay valid across multi-page fetches of the same query.
-	ids := make([]string, 0, len(messages))
-	for _, msg := range messages {
-		ids = append(ids, deref(msg.GetId(), ""))
+	ids := make([]string, 0, len(summaries))
+	for _, s := range summaries {
+		ids = append(ids, s.ID)
 	}
 	if page == 1 {
 		saveIDCache(ids)
@@ -210,146 +507,372 @@ func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, count in
 		appendIDCache(ids)
 	}
 
-	// Indicate whether more pages exist.
-	hasMore := result.GetOdataNextLink() != nil
-
 	if jsonOutput {
-		summaries := make([]MessageSummary, 0, len(messages))
-		for i, msg := range messages {
-			summaries = append(summaries, MessageSummary{
-				Index:            i + 1,
-				ID:               deref(msg.GetId(), ""),
-				Subject:          deref(msg.GetSubject(), ""),
-				From:             senderAddress(msg),
-				ReceivedDateTime: formatMsgTime(msg.GetReceivedDateTime()),
-				IsRead:           msg.GetIsRead() != nil && *msg.GetIsRead(),
-				BodyPreview:      deref(msg.GetBodyPreview(), ""),
-				Categories:       msg.GetCategories(),
-			})
-		}
-		type listResult struct {
-			Page     int              `json:"page"`
-			Count    int              `json:"count"`
-			HasMore  bool             `json:"hasMore"`
-			Messages []MessageSummary `json:"messages"`
+		if err := printJSON(MessageList{Page: page, Count: len(summaries), HasMore: hasMore, Messages: summaries}); err != nil {
+			return err
 		}
-		return printJSON(listResult{Page: page, Count: len(summaries), HasMore: hasMore, Messages: summaries})
+	} else {
+		printList(summaries, page, hasMore)
 	}
 
-	if len(messages) == 0 {
+	if opts.MarkRead {
+		return markListedRead(ctx, client, summaries)
+	}
+	return nil
+}
+
+// printList prints one page of List as a table.
+func printList(summaries []MessageSummary, page int, hasMore bool) {
+	if len(summaries) == 0 {
 		fmt.Println("No messages found.")
-		return nil
+		return
 	}
 
-	fmt.Printf("\nPage %d  (showing %d messages)\n", page, len(messages))
-	fmt.Printf("%-3s  %-50s  %-30s  %s\n", "#", "Subject", "From", "Received")
-	fmt.Println(strings.Repeat("-", 110))
-	for i, msg := range messages {
+	fmt.Printf("\nPage %d  (showing %d messages)\n", page, len(summaries))
+	fmt.Printf("%-3s  %-50s  %-30s  %-16s  %8s\n", "#", "Subject", "From", "Received", "Size")
+	fmt.Println(strings.Repeat("-", 120))
+	for _, s := range summaries {
 		read := " "
-		if msg.GetIsRead() != nil && !*msg.GetIsRead() {
+		if !s.IsRead {
 			read = "*"
 		}
 		cats := ""
-		if len(msg.GetCategories()) > 0 {
-			cats = " [" + strings.Join(msg.GetCategories(), ", ") + "]"
+		if len(s.Categories) > 0 {
+			cats = " [" + strings.Join(s.Categories, ", ") + "]"
 		}
-		fmt.Printf("%s%-3d  %-50s  %-30s  %s%s\n",
-			read, i+1,
-			truncate(deref(msg.GetSubject(), "(no subject)"), 50),
-			truncate(senderAddress(msg), 30),
-			formatMsgTime(msg.GetReceivedDateTime()),
+		subject := s.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		fmt.Printf("%s%-3d  %-50s  %-30s  %-16s  %8s%s%s%s\n",
+			read, s.Index,
+			truncate(subject, 50),
+			truncate(s.From, 30),
+			s.ReceivedDateTime,
+			formatSize(s.Size),
 			cats,
+			importanceMark(s.Importance),
+			flagMark(s.Flag, s.FlagDue),
 		)
 	}
-	fmt.Println("\n(* = unread)")
+	fmt.Println("\n(* = unread, ! = high importance, ↓ = low importance, ⚑ = flagged, ✓ = flag completed)")
 	if hasMore {
-		fmt.Fprintf(os.Stderr, "More messages available — use --page=%d to continue.\n", page+1)
+		slog.Info("More messages available", "nextPage", page+1)
+	}
+}
+
+// markListedRead marks the unread messages among those just listed as read,
+// in one $batch. Messages that were already read are left untouched.
+func markListedRead(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, summaries []MessageSummary) error {
+	isRead := true
+	var steps []*abstractions.RequestInformation
+	for _, s := range summaries {
+		if s.IsRead {
+			continue
+		}
+		patch := models.NewMessage()
+		patch.SetIsRead(&isRead)
+		info, err := mailbox.Of(client).Messages().ByMessageId(s.ID).ToPatchRequestInformation(ctx, patch, nil)
+		if err != nil {
+			return fmt.Errorf("building read state update: %w", err)
+		}
+		steps = append(steps, info)
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+
+	statuses, err := sendBatch(ctx, client, steps)
+	if err != nil {
+		return fmt.Errorf("marking listed messages as read: %w", err)
 	}
+	if failed := countFailed(statuses); failed > 0 {
+		return fmt.Errorf("%d of %d listed messages could not be marked as read", failed, len(steps))
+	}
+	slog.Info("Marked listed messages as read", "count", len(steps))
 	return nil
 }
 
 // ---------- Read ----------
 
-// Read fetches and prints a single message.
-// ref may be a 1-based list index or a raw Graph message ID.
-func Read(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, jsonOutput bool) error {
-	messageID, err := resolveMessageID(ref)
+// ReadOptions controls how message bodies are returned by Read and Thread.
+type ReadOptions struct {
+	Clean       bool   // only the new content, see cleanText
+	SplitQuotes bool   // also return the body split into newContent and quotedContent
+	StripQuotes bool   // the body without its quoted history, see splitQuotes
+	Unique      bool   // Thread only: each body is the text the message added, see uniqueText
+	Markdown    bool   // an HTML body as Markdown rather than plain text, see htmlToMarkdown
+	SaveDir     string // Read only: download the attachments to this directory
+	SaveImages  string // Read only: download the inline images to this directory and link them in the body
+
+	images map[string]string // saved inline images by content ID, set by Read for each message
+}
+
+// textBody reports whether bodies are fetched as Exchange's text rendering.
+// Quote detection works best on it, as it keeps the line structure that
+// stripHTML can lose; Markdown and inline images need the HTML.
+func (o ReadOptions) textBody() bool {
+	return (o.Clean || o.SplitQuotes || o.StripQuotes) && !o.Markdown && o.SaveImages == ""
+}
+
+// Read fetches and prints a message.
+// ref may be a 1-based list index or a raw Graph message ID, or several of
+// them and index ranges (1,3,5-9), which are fetched in one $batch.
+func Read(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, opts ReadOptions, jsonOutput bool) error {
+	refs, ids, err := resolveMessageIDs(ref)
 	if err != nil {
 		return err
 	}
+	messageID := ids[0]
 
 	config := &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
 		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
-			Select: []string{"id", "subject", "from", "toRecipients", "receivedDateTime", "body", "isRead", "categories"},
+			Select: []string{"id", "subject", "from", "toRecipients", "receivedDateTime", "body", "isRead", "categories",
+				"conversationId", "conversationIndex", "internetMessageId"},
+			Expand: extendedExpand,
 		},
 	}
+	if opts.textBody() {
+		config.Headers = abstractions.NewRequestHeaders()
+		config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+	}
+	if opts.Clean {
+		config.QueryParameters.Select = append(config.QueryParameters.Select, "uniqueBody")
+	}
+	if len(ids) > 1 {
+		return readMany(ctx, client, refs, ids, config, opts, jsonOutput)
+	}
+	if thinMode() {
+		return thinRead(ctx, client, messageID, config.QueryParameters.Select, config.QueryParameters.Expand, opts, jsonOutput)
+	}
 
-	msg, err := client.Me().Messages().ByMessageId(messageID).Get(ctx, config)
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, config)
 	if err != nil {
 		return fmt.Errorf("reading message: %w", err)
 	}
 
-	body := extractBody(msg)
-
+	var images []AttachmentInfo
+	if opts.SaveImages != "" {
+		if images, err = saveInlineImages(ctx, client, messageID, opts.SaveImages, map[string]bool{}); err != nil {
+			return err
+		}
+		opts.images = imagePaths(images)
+	}
+	detail := messageDetail(msg, opts)
+	detail.Images = images
+	if opts.SaveDir != "" {
+		if detail.Attachments, err = messageAttachments(ctx, client, messageID, opts.SaveDir); err != nil {
+			return err
+		}
+	}
 	if jsonOutput {
-		to := []string{}
-		for _, r := range msg.GetToRecipients() {
-			if r.GetEmailAddress() != nil {
-				to = append(to, deref(r.GetEmailAddress().GetAddress(), ""))
-			}
+		return printJSON(detail)
+	}
+	h := headerOf(msg)
+	h.Attachments = detail.Attachments
+	printMessage(h, detail.Body)
+	return nil
+}
+
+// readMany is Read for several messages: their bodies are fetched in one
+// $batch (split only past maxBatchSize) and printed in the order of ref, as a
+// JSON array with jsonOutput. Messages that could not be read are left out and
+// their refs listed in the error once the rest are printed.
+func readMany(
+	ctx context.Context,
+	client *msgraphsdkgo.GraphServiceClient,
+	refs, ids []string,
+	config *users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration,
+	opts ReadOptions,
+	jsonOutput bool,
+) error {
+	steps := make([]*abstractions.RequestInformation, 0, len(ids))
+	for _, id := range ids {
+		info, err := mailbox.Of(client).Messages().ByMessageId(id).ToGetRequestInformation(ctx, config)
+		if err != nil {
+			return fmt.Errorf("building batch request: %w", err)
 		}
-		return printJSON(MessageDetail{
-			ID:               deref(msg.GetId(), ""),
-			Subject:          deref(msg.GetSubject(), ""),
-			From:             senderAddress(msg),
-			To:               to,
-			ReceivedDateTime: formatMsgTime(msg.GetReceivedDateTime()),
-			Body:             body,
-			Categories:       msg.GetCategories(),
-		})
+		steps = append(steps, info)
 	}
 
-	fmt.Printf("\nSubject : %s\n", deref(msg.GetSubject(), "(no subject)"))
-	if msg.GetFrom() != nil && msg.GetFrom().GetEmailAddress() != nil {
-		fmt.Printf("From    : %s <%s>\n",
-			deref(msg.GetFrom().GetEmailAddress().GetName(), ""),
-			deref(msg.GetFrom().GetEmailAddress().GetAddress(), ""),
-		)
+	msgs, statuses, err := getBatch[models.Messageable](ctx, client, steps, models.CreateMessageFromDiscriminatorValue)
+	if err != nil {
+		return fmt.Errorf("reading messages: %w", err)
+	}
+	details := []MessageDetail{}
+	var failed []string
+	usedImages := map[string]bool{}
+	for i, msg := range msgs {
+		if msg == nil || statuses[i] < 200 || statuses[i] > 299 {
+			failed = append(failed, refs[i])
+			continue
+		}
+		var images []AttachmentInfo
+		msgOpts := opts
+		if opts.SaveImages != "" {
+			if images, err = saveInlineImages(ctx, client, ids[i], opts.SaveImages, usedImages); err != nil {
+				return err
+			}
+			msgOpts.images = imagePaths(images)
+		}
+		detail := messageDetail(msg, msgOpts)
+		detail.Images = images
+		if opts.SaveDir != "" {
+			if detail.Attachments, err = messageAttachments(ctx, client, ids[i], opts.SaveDir); err != nil {
+				return err
+			}
+		}
+		if !jsonOutput {
+			h := headerOf(msg)
+			h.Attachments = detail.Attachments
+			printMessage(h, detail.Body)
+		}
+		details = append(details, detail)
 	}
-	if msg.GetReceivedDateTime() != nil {
-		fmt.Printf("Date    : %s\n", msg.GetReceivedDateTime().Format("Mon, 02 Jan 2006 15:04:05"))
+	if jsonOutput {
+		if err := printJSON(details); err != nil {
+			return err
+		}
 	}
-	to := []string{}
-	for _, r := range msg.GetToRecipients() {
-		if r.GetEmailAddress() != nil {
-			to = append(to, deref(r.GetEmailAddress().GetAddress(), ""))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d messages could not be read: --ref=%s", len(failed), len(ids), strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// messageDetail converts a message read with the fields selected by Read,
+// and uniqueBody as well when opts.Clean is set.
+func messageDetail(msg models.Messageable, opts ReadOptions) MessageDetail {
+	return detailOf(sdkMessage{msg}, opts)
+}
+
+// detailOf builds the JSON representation of a message read as m.
+func detailOf(m messageFields, opts ReadOptions) MessageDetail {
+	body := m.body(opts)
+	detail := MessageDetail{
+		ID:               m.id(),
+		Subject:          m.subject(),
+		From:             m.sender(),
+		To:               m.recipients(),
+		ReceivedDateTime: m.received(),
+		Body:             body,
+		Categories:       m.categories(),
+		Size:             m.size(),
+		Threading:        m.threading(),
+	}
+	if opts.SplitQuotes || opts.StripQuotes {
+		newContent, quoted := splitQuotes(body)
+		if opts.SplitQuotes {
+			detail.NewContent, detail.QuotedContent = &newContent, &quoted
+		}
+		if opts.StripQuotes {
+			detail.Body = newContent
 		}
 	}
-	fmt.Printf("To      : %s\n", strings.Join(to, ", "))
-	if len(msg.GetCategories()) > 0 {
-		fmt.Printf("Categories: %s\n", strings.Join(msg.GetCategories(), ", "))
+	if opts.Unique {
+		detail.Body = uniqueText(m, opts)
+	}
+	if opts.Clean {
+		detail.Body = cleanText(m, opts)
+	}
+	return detail
+}
+
+// printMessage prints a message's headers followed by body.
+func printMessage(h messageHeader, body string) {
+	fmt.Printf("\nSubject : %s\n", h.Subject)
+	if h.HasFrom {
+		fmt.Printf("From    : %s <%s>\n", h.FromName, h.FromAddress)
+	}
+	if h.Received != nil {
+		fmt.Printf("Date    : %s\n", h.Received.Format("Mon, 02 Jan 2006 15:04:05"))
+	}
+	fmt.Printf("To      : %s\n", strings.Join(h.To, ", "))
+	if len(h.Categories) > 0 {
+		fmt.Printf("Categories: %s\n", strings.Join(h.Categories, ", "))
+	}
+	for _, a := range h.Attachments {
+		line := fmt.Sprintf("%s (%s, %d KB)", a.Name, a.Kind, (a.Size+1023)/1024)
+		if a.File != "" {
+			line += " → " + a.File
+		}
+		fmt.Printf("Attachment: %s\n", line)
 	}
 	fmt.Println(strings.Repeat("-", 60))
 	fmt.Println(body)
-	return nil
+}
+
+// messageHeader holds the fields printMessage shows above the body.
+type messageHeader struct {
+	Subject     string
+	HasFrom     bool
+	FromName    string
+	FromAddress string
+	Received    *time.Time
+	To          []string
+	Categories  []string
+	Attachments []AttachmentInfo
+}
+
+func headerOf(msg models.Messageable) messageHeader {
+	h := messageHeader{
+		Subject:    deref(msg.GetSubject(), "(no subject)"),
+		Received:   msg.GetReceivedDateTime(),
+		To:         []string{},
+		Categories: msg.GetCategories(),
+	}
+	if msg.GetFrom() != nil && msg.GetFrom().GetEmailAddress() != nil {
+		h.HasFrom = true
+		h.FromName = deref(msg.GetFrom().GetEmailAddress().GetName(), "")
+		h.FromAddress = deref(msg.GetFrom().GetEmailAddress().GetAddress(), "")
+	}
+	for _, r := range msg.GetToRecipients() {
+		if r.GetEmailAddress() != nil {
+			h.To = append(h.To, deref(r.GetEmailAddress().GetAddress(), ""))
+		}
+	}
+	return h
 }
 
 // ---------- Send ----------
 
 // Send composes and sends an email from flag arguments — no interactive prompts.
 // to, cc, and bcc accept comma-separated email addresses; cc and bcc may be empty.
-func Send(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, to, cc, bcc, subject, body string, format BodyFormat) error {
+// attach is a comma-separated list of files to attach, and may be empty.
+// marking sets the message's importance and sensitivity.
+func Send(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, to, cc, bcc, subject, body, attach string, format BodyFormat, marking Marking) error {
 	if to == "" {
 		return fmt.Errorf("--to is required")
 	}
 	if subject == "" {
 		return fmt.Errorf("--subject is required")
 	}
+	if err := marking.Check(); err != nil {
+		return err
+	}
+
+	small, large, smallSize, err := fileAttachments(attach)
+	if err != nil {
+		return err
+	}
+
+	htmlBody := RenderBody(body, format)
+	// Attachments need the SDK's upload sessions, so only plain messages
+	// take the thin path.
+	if thinMode() && attach == "" {
+		if err := thinSend(ctx, client, to, cc, bcc, subject, htmlBody, marking); err != nil {
+			return err
+		}
+		slog.Info("Email sent", "to", to)
+		return nil
+	}
 
 	message := models.NewMessage()
 	message.SetSubject(&subject)
+	if err := marking.apply(message); err != nil {
+		return err
+	}
 
-	htmlBody := RenderBody(body, format)
 	bodyContent := models.NewItemBody()
 	contentType := models.HTML_BODYTYPE
 	bodyContent.SetContentType(&contentType)
@@ -364,16 +887,27 @@ func Send(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, to, cc,
 		message.SetBccRecipients(parseRecipients(bcc))
 	}
 
+	if len(large) > 0 || smallSize > inlineAttachmentLimit {
+		if err := sendWithUploads(ctx, client, message, small, large); err != nil {
+			return err
+		}
+		slog.Info("Email sent", "to", to)
+		return nil
+	}
+	if len(small) > 0 {
+		message.SetAttachments(small)
+	}
+
 	sendMailBody := users.NewItemSendMailPostRequestBody()
 	saveToSentItems := true
 	sendMailBody.SetSaveToSentItems(&saveToSentItems)
 	sendMailBody.SetMessage(message)
 
-	if err := client.Me().SendMail().Post(ctx, sendMailBody, nil); err != nil {
+	if err := mailbox.Of(client).SendMail().Post(ctx, sendMailBody, nil); err != nil {
 		return fmt.Errorf("sending message: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Email sent to %s\n", to)
+	slog.Info("Email sent", "to", to)
 	return nil
 }
 
@@ -398,44 +932,72 @@ func parseRecipients(addresses string) []models.Recipientable {
 
 // Reply sends a reply to a message identified by ref (list index or Graph ID).
 // Uses createReply → patch body → send so that HTML formatting is preserved.
-func Reply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, body string, format BodyFormat) error {
+func Reply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, body string, format BodyFormat, marking Marking) error {
+	if err := reply(ctx, client, ref, body, format, marking, false); err != nil {
+		return err
+	}
+	slog.Info("Reply sent")
+	return nil
+}
+
+// ReplyAll is Reply addressed to the sender and every other To and CC
+// recipient of the original, as Outlook's Reply All. Uses createReplyAll →
+// patch body → send; Graph leaves the signed-in user off the draft.
+func ReplyAll(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, body string, format BodyFormat, marking Marking) error {
+	if err := reply(ctx, client, ref, body, format, marking, true); err != nil {
+		return err
+	}
+	slog.Info("Reply sent to all recipients")
+	return nil
+}
+
+func reply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, body string, format BodyFormat, marking Marking, all bool) error {
 	if body == "" {
 		return fmt.Errorf("--body is required")
 	}
+	if err := marking.Check(); err != nil {
+		return err
+	}
 
 	messageID, err := resolveMessageID(ref)
 	if err != nil {
 		return err
 	}
 
-	// Step 1: create a draft reply.
-	createReplyReqBody := users.NewItemMessagesItemCreateReplyPostRequestBody()
-	draft, err := client.Me().Messages().ByMessageId(messageID).CreateReply().Post(ctx, createReplyReqBody, nil)
+	// Step 1: create a draft reply, already addressed.
+	var draft models.Messageable
+	if all {
+		draft, err = mailbox.Of(client).Messages().ByMessageId(messageID).CreateReplyAll().Post(ctx, users.NewItemMessagesItemCreateReplyAllPostRequestBody(), nil)
+	} else {
+		draft, err = mailbox.Of(client).Messages().ByMessageId(messageID).CreateReply().Post(ctx, users.NewItemMessagesItemCreateReplyPostRequestBody(), nil)
+	}
 	if err != nil {
 		return fmt.Errorf("creating reply draft: %w", err)
 	}
 
 	draftID := deref(draft.GetId(), "")
 
-	// Step 2: patch the draft with our HTML body so formatting is preserved.
+	// Step 2: patch the draft with our HTML body so formatting is preserved,
+	// and with the importance and sensitivity, which a draft does not inherit.
 	htmlBody := RenderBody(body, format)
 	patch := models.NewMessage()
+	if err := marking.apply(patch); err != nil {
+		return err
+	}
 	itemBody := models.NewItemBody()
 	contentType := models.HTML_BODYTYPE
 	itemBody.SetContentType(&contentType)
 	itemBody.SetContent(&htmlBody)
 	patch.SetBody(itemBody)
 
-	if _, err := client.Me().Messages().ByMessageId(draftID).Patch(ctx, patch, nil); err != nil {
+	if _, err := mailbox.Of(client).Messages().ByMessageId(draftID).Patch(ctx, patch, nil); err != nil {
 		return fmt.Errorf("updating reply draft body: %w", err)
 	}
 
 	// Step 3: send the draft.
-	if err := client.Me().Messages().ByMessageId(draftID).Send().Post(ctx, nil); err != nil {
+	if err := mailbox.Of(client).Messages().ByMessageId(draftID).Send().Post(ctx, nil); err != nil {
 		return fmt.Errorf("sending reply draft: %w", err)
 	}
-
-	fmt.Fprintln(os.Stderr, "Reply sent")
 	return nil
 }
 
@@ -445,10 +1007,13 @@ func Reply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, bo
 // Uses createForward → patch body → send so that HTML formatting is preserved.
 // ref may be a 1-based list index or a raw Graph message ID.
 // body is optional prepend text; if empty only the original message is forwarded.
-func Forward(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, to, cc, bcc, body string, format BodyFormat) error {
+func Forward(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, to, cc, bcc, body string, format BodyFormat, marking Marking) error {
 	if to == "" {
 		return fmt.Errorf("--to is required for mail forward")
 	}
+	if err := marking.Check(); err != nil {
+		return err
+	}
 
 	messageID, err := resolveMessageID(ref)
 	if err != nil {
@@ -459,15 +1024,19 @@ func Forward(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref,
 	fwdBody := users.NewItemMessagesItemCreateForwardPostRequestBody()
 	fwdBody.SetToRecipients(parseRecipients(to))
 
-	draft, err := client.Me().Messages().ByMessageId(messageID).CreateForward().Post(ctx, fwdBody, nil)
+	draft, err := mailbox.Of(client).Messages().ByMessageId(messageID).CreateForward().Post(ctx, fwdBody, nil)
 	if err != nil {
 		return fmt.Errorf("creating forward draft: %w", err)
 	}
 
 	draftID := deref(draft.GetId(), "")
 
-	// Step 2: patch the draft — set CC/BCC and optionally prepend a custom body.
+	// Step 2: patch the draft — set CC/BCC, the importance and sensitivity,
+	// and optionally prepend a custom body.
 	patch := models.NewMessage()
+	if err := marking.apply(patch); err != nil {
+		return err
+	}
 
 	if cc != "" {
 		patch.SetCcRecipients(parseRecipients(cc))
@@ -480,7 +1049,7 @@ func Forward(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref,
 	// forwarded content created by Graph is preserved untouched).
 	if body != "" {
 		// Fetch the current draft body so we can prepend our text above it.
-		draftMsg, err := client.Me().Messages().ByMessageId(draftID).Get(ctx,
+		draftMsg, err := mailbox.Of(client).Messages().ByMessageId(draftID).Get(ctx,
 			&users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
 				QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
 					Select: []string{"body"},
@@ -512,25 +1081,26 @@ func Forward(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref,
 		patch.SetBody(itemBody)
 	}
 
-	if _, err := client.Me().Messages().ByMessageId(draftID).Patch(ctx, patch, nil); err != nil {
+	if _, err := mailbox.Of(client).Messages().ByMessageId(draftID).Patch(ctx, patch, nil); err != nil {
 		return fmt.Errorf("updating forward draft: %w", err)
 	}
 
 	// Step 3: send the draft.
-	if err := client.Me().Messages().ByMessageId(draftID).Send().Post(ctx, nil); err != nil {
+	if err := mailbox.Of(client).Messages().ByMessageId(draftID).Send().Post(ctx, nil); err != nil {
 		return fmt.Errorf("sending forward draft: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Message forwarded to %s\n", to)
+	slog.Info("Message forwarded", "to", to)
 	return nil
 }
 
 // ---------- MarkRead ----------
 
 // MarkRead sets or clears the isRead flag on a message.
-// ref may be a 1-based list index or a raw Graph message ID.
+// ref may be a 1-based list index or a raw Graph message ID, or several of
+// them and index ranges (1,3,5-9), which are updated in one $batch.
 func MarkRead(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, isRead bool) error {
-	messageID, err := resolveMessageID(ref)
+	refs, ids, err := resolveMessageIDs(ref)
 	if err != nil {
 		return err
 	}
@@ -538,43 +1108,102 @@ func MarkRead(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref
 	patch := models.NewMessage()
 	patch.SetIsRead(&isRead)
 
-	if _, err := client.Me().Messages().ByMessageId(messageID).Patch(ctx, patch, nil); err != nil {
-		return fmt.Errorf("updating read state: %w", err)
+	state := "read"
+	if !isRead {
+		state = "unread"
 	}
 
-	if isRead {
-		fmt.Fprintln(os.Stderr, "Message marked as read")
-	} else {
-		fmt.Fprintln(os.Stderr, "Message marked as unread")
+	if len(ids) > 1 {
+		err := bulk(ctx, client, refs, ids, "marked as "+state, func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).ToPatchRequestInformation(ctx, patch, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("updating read state: %w", err)
+		}
+		slog.Info("Messages marked", "count", len(ids), "state", state)
+		return nil
 	}
+
+	if _, err := mailbox.Of(client).Messages().ByMessageId(ids[0]).Patch(ctx, patch, nil); err != nil {
+		return fmt.Errorf("updating read state: %w", err)
+	}
+
+	slog.Info("Message marked as " + state)
 	return nil
 }
 
 // ---------- Delete ----------
 
 // Delete permanently deletes a message (moves to Recoverable Items).
-// ref may be a 1-based list index or a raw Graph message ID.
-func Delete(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
-	messageID, err := resolveMessageID(ref)
+// ref may be a 1-based list index or a raw Graph message ID, or several of
+// them and index ranges (1,3,5-9), which are deleted in one $batch.
+// permanent purges the message instead, past Recoverable Items, so that
+// neither Outlook nor Restore can bring it back.
+func Delete(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, permanent bool) error {
+	refs, ids, err := resolveMessageIDs(ref)
 	if err != nil {
 		return err
 	}
 
-	if err := client.Me().Messages().ByMessageId(messageID).Delete(ctx, nil); err != nil {
+	done := "deleted"
+	if permanent {
+		done = "permanently deleted"
+	}
+	if len(ids) > 1 {
+		err := bulk(ctx, client, refs, ids, done, func(id string) (*abstractions.RequestInformation, error) {
+			if permanent {
+				return mailbox.Of(client).Messages().ByMessageId(id).PermanentDelete().ToPostRequestInformation(ctx, nil)
+			}
+			return mailbox.Of(client).Messages().ByMessageId(id).ToDeleteRequestInformation(ctx, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("deleting messages: %w", err)
+		}
+		slog.Info("Messages "+done, "count", len(ids))
+		return nil
+	}
+
+	if permanent {
+		err = mailbox.Of(client).Messages().ByMessageId(ids[0]).PermanentDelete().Post(ctx, nil)
+	} else {
+		err = mailbox.Of(client).Messages().ByMessageId(ids[0]).Delete(ctx, nil)
+	}
+	if err != nil {
 		return fmt.Errorf("deleting message: %w", err)
 	}
 
-	fmt.Fprintln(os.Stderr, "Message deleted")
+	slog.Info("Message " + done)
 	return nil
 }
 
+// ---------- Restore ----------
+
+// Restore moves deleted messages back to folderName (default: inbox): out of
+// Deleted Items, or out of Recoverable Items, where Delete puts them. Their
+// references come from listing deleteditems or recoverableitemsdeletions.
+// ref may be several references, as for Move.
+func Restore(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, folderName string) error {
+	if folderName == "" {
+		folderName = "inbox"
+	}
+	switch strings.ToLower(folderName) {
+	case "deleteditems", "recoverableitemsdeletions":
+		return fmt.Errorf("--folder is where restored messages go, so it cannot be %s", folderName)
+	}
+	return Move(ctx, client, ref, folderName)
+}
+
 // ---------- Search ----------
 
 // SearchOptions holds optional post-filter parameters for Search.
 // Graph does not allow combining $search with $filter, so filtering is client-side.
 type SearchOptions struct {
-	Since  string // client-side lower bound on receivedDateTime (YYYY-MM-DD)
-	Before string // client-side upper bound on receivedDateTime (YYYY-MM-DD)
+	Since      string // client-side lower bound on receivedDateTime (YYYY-MM-DD)
+	Before     string // client-side upper bound on receivedDateTime (YYYY-MM-DD)
+	Importance string // client-side: only messages of this importance
+	Out        string // write every page to this JSON file instead of printing one
+	NewerThan  string // age such as 7d; stands in for Since
+	OlderThan  string // age such as 3w; stands in for Before
 }
 
 // Search finds messages matching query.
@@ -583,52 +1212,37 @@ func Search(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, query
 	if query == "" {
 		return fmt.Errorf("search query cannot be empty")
 	}
+	var err error
+	if opts.Since, opts.Before, err = ageBounds(opts.Since, opts.Before, opts.NewerThan, opts.OlderThan); err != nil {
+		return err
+	}
+	if _, err := parseImportance(opts.Importance); err != nil {
+		return err
+	}
 
 	quoted := `"` + query + `"`
 	requestParams := &users.ItemMessagesRequestBuilderGetQueryParameters{
 		Search: &quoted,
-		Select: []string{"id", "subject", "from", "receivedDateTime", "isRead", "bodyPreview", "categories"},
+		Select: summaryFields,
+		Expand: extendedExpand,
 		Top:    &count,
 	}
 	config := &users.ItemMessagesRequestBuilderGetRequestConfiguration{
 		QueryParameters: requestParams,
 	}
 
-	result, err := client.Me().Messages().Get(ctx, config)
+	if opts.Out != "" {
+		return exportSearch(ctx, client, query, config, opts, jsonOutput)
+	}
+
+	result, err := mailbox.Of(client).Messages().Get(ctx, config)
 	if err != nil {
 		return fmt.Errorf("searching messages: %w", err)
 	}
 
-	messages := result.GetValue()
-
-	// Client-side date filtering ($search + $filter cannot be combined in Graph).
-	if opts.Since != "" || opts.Before != "" {
-		var sinceT, beforeT time.Time
-		if opts.Since != "" {
-			if t, err := parseFlexibleDate(opts.Since); err == nil {
-				sinceT = t
-			}
-		}
-		if opts.Before != "" {
-			if t, err := parseFlexibleDate(opts.Before); err == nil {
-				beforeT = t
-			}
-		}
-		filtered := make([]models.Messageable, 0, len(messages))
-		for _, msg := range messages {
-			if msg.GetReceivedDateTime() == nil {
-				continue
-			}
-			msgTime := *msg.GetReceivedDateTime()
-			if !sinceT.IsZero() && msgTime.Before(sinceT) {
-				continue
-			}
-			if !beforeT.IsZero() && msgTime.After(beforeT) {
-				continue
-			}
-			filtered = append(filtered, msg)
-		}
-		messages = filtered
+	messages, err := importanceIs(receivedBetween(result.GetValue(), opts.Since, opts.Before), opts.Importance)
+	if err != nil {
+		return err
 	}
 
 	// Cache IDs so results can be referenced by index.
@@ -641,16 +1255,7 @@ func Search(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, query
 	if jsonOutput {
 		summaries := make([]MessageSummary, 0, len(messages))
 		for i, msg := range messages {
-			summaries = append(summaries, MessageSummary{
-				Index:            i + 1,
-				ID:               deref(msg.GetId(), ""),
-				Subject:          deref(msg.GetSubject(), ""),
-				From:             senderAddress(msg),
-				ReceivedDateTime: formatMsgTime(msg.GetReceivedDateTime()),
-				IsRead:           msg.GetIsRead() != nil && *msg.GetIsRead(),
-				BodyPreview:      deref(msg.GetBodyPreview(), ""),
-				Categories:       msg.GetCategories(),
-			})
+			summaries = append(summaries, messageSummary(i+1, msg))
 		}
 		return printJSON(summaries)
 	}
@@ -668,21 +1273,75 @@ func Search(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, query
 		if msg.GetIsRead() != nil && !*msg.GetIsRead() {
 			read = "*"
 		}
-		fmt.Printf("%s%-3d  %-50s  %-30s  %s\n",
+		fmt.Printf("%s%-3d  %-50s  %-30s  %s%s%s\n",
 			read, i+1,
 			truncate(deref(msg.GetSubject(), "(no subject)"), 50),
 			truncate(senderAddress(msg), 30),
 			formatMsgTime(msg.GetReceivedDateTime()),
+			importanceMark(importanceOf(msg)),
+			flagMark(flagOf(msg)),
 		)
 	}
-	fmt.Println("\n(* = unread)")
+	fmt.Println("\n(* = unread, ! = high importance, ↓ = low importance, ⚑ = flagged, ✓ = flag completed)")
 	return nil
 }
 
+// subjectContains keeps the messages whose subject contains substr, ignoring
+// case. Graph does not support subject $filter reliably, so this is client-side.
+func subjectContains(messages []models.Messageable, substr string) []models.Messageable {
+	if substr == "" {
+		return messages
+	}
+	lower := strings.ToLower(substr)
+	filtered := make([]models.Messageable, 0, len(messages))
+	for _, msg := range messages {
+		if strings.Contains(strings.ToLower(deref(msg.GetSubject(), "")), lower) {
+			filtered = append(filtered, msg)
+		}
+	}
+	return filtered
+}
+
+// receivedBetween keeps the messages received within the since and before
+// bounds; either may be empty. $search and $filter cannot be combined in
+// Graph, so search results are filtered client-side.
+func receivedBetween(messages []models.Messageable, since, before string) []models.Messageable {
+	if since == "" && before == "" {
+		return messages
+	}
+	var sinceT, beforeT time.Time
+	if since != "" {
+		if t, err := parseFlexibleDate(since); err == nil {
+			sinceT = t
+		}
+	}
+	if before != "" {
+		if t, err := parseFlexibleDate(before); err == nil {
+			beforeT = t
+		}
+	}
+	filtered := make([]models.Messageable, 0, len(messages))
+	for _, msg := range messages {
+		if msg.GetReceivedDateTime() == nil {
+			continue
+		}
+		msgTime := *msg.GetReceivedDateTime()
+		if !sinceT.IsZero() && msgTime.Before(sinceT) {
+			continue
+		}
+		if !beforeT.IsZero() && msgTime.After(beforeT) {
+			continue
+		}
+		filtered = append(filtered, msg)
+	}
+	return filtered
+}
+
 // ---------- Archive ----------
 
 // Archive moves a message to the Archive folder.
-// ref may be a 1-based list index or a raw Graph message ID.
+// ref may be a 1-based list index or a raw Graph message ID, or several of
+// them and index ranges (1,3,5-9).
 func Archive(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
 	return Move(ctx, client, ref, "archive")
 }
@@ -692,12 +1351,14 @@ func Archive(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref s
 // Move moves a message to the named folder.
 // folderName may be a well-known name (inbox, archive, deleteditems, drafts, sentitems, junkemail)
 // or a display name that will be resolved against the user's folder list.
+// ref may name several messages and index ranges (1,3,5-9), which are moved
+// in one $batch.
 func Move(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, folderName string) error {
 	if folderName == "" {
 		return fmt.Errorf("--folder is required")
 	}
 
-	messageID, err := resolveMessageID(ref)
+	refs, ids, err := resolveMessageIDs(ref)
 	if err != nil {
 		return err
 	}
@@ -711,17 +1372,35 @@ func Move(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, fol
 	moveBody := users.NewItemMessagesItemMovePostRequestBody()
 	moveBody.SetDestinationId(&folderID)
 
-	if _, err := client.Me().Messages().ByMessageId(messageID).Move().Post(ctx, moveBody, nil); err != nil {
+	if len(ids) > 1 {
+		err := bulk(ctx, client, refs, ids, "moved to "+strconv.Quote(folderName), func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).Move().ToPostRequestInformation(ctx, moveBody, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("moving messages: %w", err)
+		}
+		slog.Info("Messages moved", "count", len(ids), "folder", folderName)
+		return nil
+	}
+
+	if _, err := mailbox.Of(client).Messages().ByMessageId(ids[0]).Move().Post(ctx, moveBody, nil); err != nil {
 		return fmt.Errorf("moving message: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Message moved to %q\n", folderName)
+	slog.Info("Message moved", "folder", folderName)
 	return nil
 }
 
+// FolderID returns the ID of a folder given by name or well-known name, as
+// --folder accepts it, for other packages.
+func FolderID(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name string) (string, error) {
+	return resolveFolderID(ctx, client, name)
+}
+
 // resolveFolderID returns a folder ID for the given name.
 // If the name is a well-known Outlook folder name it is used directly.
-// Otherwise the user's folders are searched by display name (case-insensitive).
+// Otherwise the user's top-level folders, then search folders, are searched by
+// display name (case-insensitive).
 func resolveFolderID(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name string) (string, error) {
 	wellKnown := map[string]bool{
 		"inbox": true, "archive": true, "deleteditems": true,
@@ -735,7 +1414,7 @@ func resolveFolderID(ctx context.Context, client *msgraphsdkgo.GraphServiceClien
 
 	// Search user folders by display name.
 	top := int32(100)
-	result, err := client.Me().MailFolders().Get(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
+	result, err := mailbox.Of(client).MailFolders().Get(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
 		QueryParameters: &users.ItemMailFoldersRequestBuilderGetQueryParameters{
 			Select: []string{"id", "displayName"},
 			Top:    &top,
@@ -750,6 +1429,16 @@ func resolveFolderID(ctx context.Context, client *msgraphsdkgo.GraphServiceClien
 			return deref(f.GetId(), ""), nil
 		}
 	}
+
+	// Search folders live under their own well-known parent; treat them like
+	// any other folder so `mail list --folder=<search folder>` works.
+	if searchFolders, err := listSearchFolders(ctx, client); err == nil {
+		for _, f := range searchFolders {
+			if strings.EqualFold(deref(f.GetDisplayName(), ""), name) {
+				return deref(f.GetId(), ""), nil
+			}
+		}
+	}
 	return "", fmt.Errorf("folder %q not found — use `mail folders` to list available folders", name)
 }
 
@@ -757,8 +1446,10 @@ func resolveFolderID(ctx context.Context, client *msgraphsdkgo.GraphServiceClien
 
 // Categorize sets (or clears) Outlook categories on a message.
 // set is a comma-separated list of category names to apply; pass empty to clear all.
+// ref may name several messages and index ranges (1,3,5-9), which are updated
+// in one $batch.
 func Categorize(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, set string) error {
-	messageID, err := resolveMessageID(ref)
+	refs, ids, err := resolveMessageIDs(ref)
 	if err != nil {
 		return err
 	}
@@ -776,24 +1467,119 @@ func Categorize(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, re
 	patch := models.NewMessage()
 	patch.SetCategories(cats)
 
-	if _, err := client.Me().Messages().ByMessageId(messageID).Patch(ctx, patch, nil); err != nil {
+	if len(ids) > 1 {
+		err := bulk(ctx, client, refs, ids, "categorized", func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).ToPatchRequestInformation(ctx, patch, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("categorizing messages: %w", err)
+		}
+	} else if _, err := mailbox.Of(client).Messages().ByMessageId(ids[0]).Patch(ctx, patch, nil); err != nil {
 		return fmt.Errorf("categorizing message: %w", err)
 	}
 
 	if len(cats) == 0 {
-		fmt.Fprintln(os.Stderr, "Categories cleared")
+		slog.Info("Categories cleared", "count", len(ids))
 	} else {
-		fmt.Fprintf(os.Stderr, "Categories set: %s\n", strings.Join(cats, ", "))
+		slog.Info("Categories set", "categories", strings.Join(cats, ", "), "count", len(ids))
+	}
+	return nil
+}
+
+// UpdateCategories adds and removes Outlook categories on a message, keeping
+// the others it has, such as those a person applied. add and remove are
+// comma-separated names, compared without case as Outlook does; a name in
+// both is removed. Each message's categories are read in one $batch and the
+// changed ones written in another.
+func UpdateCategories(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, add, remove string) error {
+	refs, ids, err := resolveMessageIDs(ref)
+	if err != nil {
+		return err
+	}
+	adding, removing := splitList(add), splitList(remove)
+	if len(adding) == 0 && len(removing) == 0 {
+		return fmt.Errorf("--add or --remove names no categories")
+	}
+
+	config := &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "categories"},
+		},
+	}
+	steps := make([]*abstractions.RequestInformation, 0, len(ids))
+	for _, id := range ids {
+		info, err := mailbox.Of(client).Messages().ByMessageId(id).ToGetRequestInformation(ctx, config)
+		if err != nil {
+			return fmt.Errorf("building batch request: %w", err)
+		}
+		steps = append(steps, info)
+	}
+	msgs, statuses, err := getBatch[models.Messageable](ctx, client, steps, models.CreateMessageFromDiscriminatorValue)
+	if err != nil {
+		return fmt.Errorf("reading categories: %w", err)
+	}
+
+	var failed, changedRefs, changedIDs []string
+	updated := map[string][]string{}
+	for i, msg := range msgs {
+		if msg == nil || statuses[i] < 200 || statuses[i] > 299 {
+			failed = append(failed, refs[i])
+			continue
+		}
+		current := msg.GetCategories()
+		cats := mergeCategories(current, adding, removing)
+		if slices.Equal(cats, current) {
+			continue
+		}
+		changedRefs = append(changedRefs, refs[i])
+		changedIDs = append(changedIDs, ids[i])
+		updated[ids[i]] = cats
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d messages could not be read, so none were categorized: --ref=%s", len(failed), len(ids), strings.Join(failed, ","))
+	}
+
+	if len(changedIDs) > 0 {
+		err := bulk(ctx, client, changedRefs, changedIDs, "categorized", func(id string) (*abstractions.RequestInformation, error) {
+			patch := models.NewMessage()
+			patch.SetCategories(updated[id])
+			return mailbox.Of(client).Messages().ByMessageId(id).ToPatchRequestInformation(ctx, patch, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("categorizing messages: %w", err)
+		}
 	}
+	slog.Info("Categories updated", "added", strings.Join(adding, ", "), "removed", strings.Join(removing, ", "),
+		"count", len(changedIDs), "unchanged", len(ids)-len(changedIDs))
 	return nil
 }
 
+// mergeCategories returns current without the categories in remove and with
+// those in add that it lacks, in their original order.
+func mergeCategories(current, add, remove []string) []string {
+	has := func(list []string, name string) bool {
+		return slices.ContainsFunc(list, func(c string) bool { return strings.EqualFold(c, name) })
+	}
+	cats := []string{}
+	for _, c := range current {
+		if !has(remove, c) && !has(cats, c) {
+			cats = append(cats, c)
+		}
+	}
+	for _, c := range add {
+		if !has(remove, c) && !has(cats, c) {
+			cats = append(cats, c)
+		}
+	}
+	return cats
+}
+
 // ---------- Folders ----------
 
 // Folders lists the user's mail folders.
 func Folders(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
 	top := int32(100)
-	result, err := client.Me().MailFolders().Get(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
+	result, err := mailbox.Of(client).MailFolders().Get(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
 		QueryParameters: &users.ItemMailFoldersRequestBuilderGetQueryParameters{
 			Select: []string{"id", "displayName", "totalItemCount", "unreadItemCount"},
 			Top:    &top,
@@ -843,6 +1629,105 @@ func Folders(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonO
 	return nil
 }
 
+// FolderTree lists the user's mail folders as a hierarchy, expanding
+// childFolders level by level. Each level's subfolder listings (and with
+// them the per-folder counts) are fetched in a single $batch.
+func FolderTree(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	fields := []string{"id", "displayName", "totalItemCount", "unreadItemCount", "childFolderCount"}
+	top := int32(100)
+	result, err := mailbox.Of(client).MailFolders().Get(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersRequestBuilderGetQueryParameters{
+			Select: fields,
+			Top:    &top,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("listing folders: %w", err)
+	}
+
+	roots := folderNodes(result.GetValue())
+	level := roots
+	for len(level) > 0 {
+		var parents []*FolderNode
+		var steps []*abstractions.RequestInformation
+		for _, n := range level {
+			if n.childCount == 0 {
+				continue
+			}
+			info, err := mailbox.Of(client).MailFolders().ByMailFolderId(n.ID).ChildFolders().ToGetRequestInformation(ctx,
+				&users.ItemMailFoldersItemChildFoldersRequestBuilderGetRequestConfiguration{
+					QueryParameters: &users.ItemMailFoldersItemChildFoldersRequestBuilderGetQueryParameters{
+						Select: fields,
+						Top:    &top,
+					},
+				})
+			if err != nil {
+				return fmt.Errorf("building subfolder request: %w", err)
+			}
+			parents = append(parents, n)
+			steps = append(steps, info)
+		}
+		if len(steps) == 0 {
+			break
+		}
+
+		children, statuses, err := getBatch[models.MailFolderCollectionResponseable](ctx, client, steps,
+			models.CreateMailFolderCollectionResponseFromDiscriminatorValue)
+		if err != nil {
+			return fmt.Errorf("listing subfolders: %w", err)
+		}
+
+		level = nil
+		for i, page := range children {
+			if page == nil {
+				slog.Warn("Could not list subfolders", "folder", parents[i].Name, "status", statuses[i])
+				continue
+			}
+			parents[i].Children = folderNodes(page.GetValue())
+			level = append(level, parents[i].Children...)
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(roots)
+	}
+
+	fmt.Printf("\n%-45s  %8s  %8s\n", "Folder", "Total", "Unread")
+	fmt.Println(strings.Repeat("-", 65))
+	var walk func(nodes []*FolderNode, depth int)
+	walk = func(nodes []*FolderNode, depth int) {
+		for _, n := range nodes {
+			name := truncate(strings.Repeat("  ", depth)+n.Name, 45)
+			fmt.Printf("%-45s  %8d  %8d\n", name, n.TotalItems, n.UnreadItems)
+			walk(n.Children, depth+1)
+		}
+	}
+	walk(roots, 0)
+	return nil
+}
+
+// folderNodes converts Graph folders into tree nodes without children.
+func folderNodes(folders []models.MailFolderable) []*FolderNode {
+	nodes := make([]*FolderNode, 0, len(folders))
+	for _, f := range folders {
+		n := &FolderNode{
+			ID:   deref(f.GetId(), ""),
+			Name: deref(f.GetDisplayName(), ""),
+		}
+		if f.GetTotalItemCount() != nil {
+			n.TotalItems = *f.GetTotalItemCount()
+		}
+		if f.GetUnreadItemCount() != nil {
+			n.UnreadItems = *f.GetUnreadItemCount()
+		}
+		if f.GetChildFolderCount() != nil {
+			n.childCount = *f.GetChildFolderCount()
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
 // ---------- Helpers ----------
 
 func senderAddress(msg models.Messageable) string {
@@ -852,19 +1737,47 @@ func senderAddress(msg models.Messageable) string {
 	return ""
 }
 
-func extractBody(msg models.Messageable) string {
+func extractBody(msg models.Messageable, opts ReadOptions) string {
 	if msg.GetBody() == nil {
 		return ""
 	}
-	body := deref(msg.GetBody().GetContent(), "")
+	body := charset.Repair(deref(msg.GetBody().GetContent(), ""))
 	if msg.GetBody().GetContentType() != nil {
 		if strings.ToLower(msg.GetBody().GetContentType().String()) == "html" {
-			return stripHTML(body)
+			return htmlText(body, opts)
 		}
 	}
 	return body
 }
 
+// htmlText renders an HTML body as plain text, or as Markdown with
+// opts.Markdown, linking the inline images saved for it.
+func htmlText(s string, opts ReadOptions) string {
+	s = linkImages(s, opts.images, opts.Markdown)
+	if opts.Markdown {
+		return htmlToMarkdown(s)
+	}
+	return stripHTML(s)
+}
+
+// uniqueText returns the text m added to its conversation: uniqueBody,
+// Exchange's own cut of the body without the messages it quotes, or the whole
+// body when Exchange gave none. m must be fetched with uniqueBody selected.
+func uniqueText(m messageFields, opts ReadOptions) string {
+	if text := m.uniqueBody(opts); strings.TrimSpace(text) != "" {
+		return text
+	}
+	return m.body(opts)
+}
+
+// cleanText returns the new content of m with quoted history, signature
+// and disclaimers removed. m must be fetched with uniqueBody selected and the
+// text body preference set; uniqueBody is Exchange's own cut of the new text,
+// and the heuristics in cleanBody catch what it misses.
+func cleanText(m messageFields, opts ReadOptions) string {
+	return cleanBody(uniqueText(m, opts))
+}
+
 func formatMsgTime(t interface{ Format(string) string }) string {
 	if t == nil {
 		return ""
@@ -873,9 +1786,7 @@ func formatMsgTime(t interface{ Format(string) string }) string {
 }
 
 func printJSON(v interface{}) error {
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(v)
+	return schema.Encode(os.Stdout, v)
 }
 
 func deref(s *string, fallback string) string {
@@ -990,9 +1901,15 @@ func collapseSpaces(s string) string {
 	}
 	return b.String()
 }
+
 // Body rendering is handled by RenderBody / RenderBodyInner in formatting.go.
-// Accepted: "2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04:05Z07:00".
+// Accepted: "2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04:05Z07:00",
+// or a relative date: an age such as "7d" or "2w", or a day such as
+// "yesterday" or "last monday" (see reldate).
 func parseFlexibleDate(s string) (time.Time, error) {
+	if t, ok := reldate.Parse(s, time.Now()); ok {
+		return t, nil
+	}
 	formats := []string{
 		time.RFC3339,
 		"2006-01-02 15:04:05",
@@ -1004,5 +1921,31 @@ func parseFlexibleDate(s string) (time.Time, error) {
 			return t, nil
 		}
 	}
-	return time.Time{}, fmt.Errorf("unrecognised date format %q — use YYYY-MM-DD or YYYY-MM-DD HH:MM", s)
+	return time.Time{}, fmt.Errorf("unrecognised date format %q — use YYYY-MM-DD, YYYY-MM-DD HH:MM, or %s", s, reldate.Forms)
+}
+
+// ageBounds applies --newer-than and --older-than to a since/before pair.
+// Each takes an age only and stands in for the matching date flag, so the two
+// cannot be combined.
+func ageBounds(since, before, newerThan, olderThan string) (string, string, error) {
+	apply := func(bound *string, age, flag, dateFlag string) error {
+		if age == "" {
+			return nil
+		}
+		if *bound != "" {
+			return fmt.Errorf("--%s and --%s cannot be combined", flag, dateFlag)
+		}
+		if _, ok := reldate.Age(age, time.Now()); !ok {
+			return fmt.Errorf("--%s: invalid age %q — use e.g. 12h, 7d, 3w, or 2mo", flag, age)
+		}
+		*bound = strings.TrimSpace(age)
+		return nil
+	}
+	if err := apply(&since, newerThan, "newer-than", "since"); err != nil {
+		return "", "", err
+	}
+	if err := apply(&before, olderThan, "older-than", "before"); err != nil {
+		return "", "", err
+	}
+	return since, before, nil
 }
@@ -0,0 +1,943 @@
+package mail
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ── Markdown → HTML ──────────────────────────────────────────────────────────
+//
+// A CommonMark renderer without external dependencies, with the GitHub
+// extensions agents use most: tables, task lists, strikethrough, and bare
+// links. Supports ATX and setext headings, paragraphs, fenced and indented
+// code blocks, blockquotes, nested ordered and unordered lists, thematic
+// breaks, inline and reference links, images, autolinks, inline HTML,
+// entities, and backslash escapes.
+//
+// Unlike CommonMark, a line break inside a paragraph is kept, as the author
+// of an email means it. Raw HTML is passed through inline only; an HTML block
+// is rendered as a paragraph.
+
+var (
+	fenceOpen     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \\t]*([^`\\s]*)[^`]*$")
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextLine    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	listMarker    = regexp.MustCompile(`^( {0,3})([-+*]|(\d{1,9})([.)]))([ \t]+|$)`)
+	taskMarker    = regexp.MustCompile(`^\[([ xX])\][ \t]+`)
+	tableDelim    = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	linkDef       = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*<?([^\s>]+)>?(?:[ \t]+("[^"]*"|'[^']*'|\([^)]*\)))?[ \t]*$`)
+	entityRef     = regexp.MustCompile(`^&(?:[A-Za-z][A-Za-z0-9]{1,31}|#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6});`)
+	inlineTag     = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][\w.:-]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--[\s\S]*?-->)`)
+	autolink      = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*|[A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+	bareLink      = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]+`)
+)
+
+// markdown renders one document.
+type markdown struct {
+	refs   map[string]mdLink // link reference definitions by normalized label
+	inLink bool              // rendering link text, which may not hold another link
+}
+
+type mdLink struct {
+	dest, title string
+}
+
+func markdownToHTML(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+	m := &markdown{refs: map[string]mdLink{}}
+	m.collectRefs(lines)
+	var out strings.Builder
+	m.blocks(&out, lines, false)
+	return out.String()
+}
+
+// ── Blocks ──
+
+// blocks renders lines as a sequence of blocks. In a tight list item,
+// paragraphs are written without <p> tags.
+func (m *markdown) blocks(out *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fenceOpen.MatchString(line):
+			i = m.fencedCode(out, lines, i)
+		case indentOf(line) >= 4:
+			i = m.indentedCode(out, lines, i)
+		case atxHeading.MatchString(line):
+			g := atxHeading.FindStringSubmatch(line)
+			fmt.Fprintf(out, "<h%d>%s</h%d>\n", len(g[1]), m.inline(g[2]), len(g[1]))
+			i++
+		case thematicBreak.MatchString(line):
+			out.WriteString("<hr>\n")
+			i++
+		case isQuote(line):
+			i = m.blockquote(out, lines, i)
+		case listMarker.MatchString(line):
+			i = m.list(out, lines, i)
+		case isTableStart(lines, i):
+			i = m.table(out, lines, i)
+		case m.isRefDef(line):
+			i++
+		default:
+			i = m.paragraph(out, lines, i, tight)
+		}
+	}
+}
+
+// paragraph renders the lines from i up to a blank line or the start of
+// another block, or a setext heading when they are underlined with = or -.
+func (m *markdown) paragraph(out *strings.Builder, lines []string, i int, tight bool) int {
+	var para []string
+	for ; i < len(lines) && !isBlank(lines[i]); i++ {
+		if len(para) > 0 {
+			if g := setextLine.FindStringSubmatch(lines[i]); g != nil {
+				level := 1
+				if g[1][0] == '-' {
+					level = 2
+				}
+				fmt.Fprintf(out, "<h%d>%s</h%d>\n", level, m.inline(strings.Join(para, "\n")), level)
+				return i + 1
+			}
+			if interruptsParagraph(lines, i) {
+				break
+			}
+		}
+		para = append(para, strings.TrimSpace(lines[i]))
+	}
+	text := m.inline(strings.Join(para, "\n"))
+	if tight {
+		out.WriteString(text + "\n")
+	} else {
+		out.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+// interruptsParagraph reports whether lines[i] starts a block that ends the
+// paragraph above it. A list does so only if its first item has text and, if
+// ordered, starts at 1.
+func interruptsParagraph(lines []string, i int) bool {
+	line := lines[i]
+	if fenceOpen.MatchString(line) || atxHeading.MatchString(line) || thematicBreak.MatchString(line) ||
+		isQuote(line) || isTableStart(lines, i) {
+		return true
+	}
+	if g := listMarker.FindStringSubmatch(line); g != nil {
+		return !isBlank(line[len(g[0]):]) && (g[3] == "" || g[3] == "1")
+	}
+	return false
+}
+
+func (m *markdown) fencedCode(out *strings.Builder, lines []string, i int) int {
+	g := fenceOpen.FindStringSubmatch(lines[i])
+	pad, fence, lang := len(g[1]), g[2], g[3]
+	var code strings.Builder
+	for i++; i < len(lines); i++ {
+		if closesFence(lines[i], fence) {
+			i++
+			break
+		}
+		code.WriteString(html.EscapeString(trimIndent(lines[i], pad)))
+		code.WriteByte('\n')
+	}
+	if lang != "" {
+		out.WriteString(`<pre><code class="language-` + html.EscapeString(unescape(lang)) + `">`)
+	} else {
+		out.WriteString("<pre><code>")
+	}
+	out.WriteString(code.String())
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+// closesFence reports whether line closes a code block opened with fence: the
+// same character at least as many times, and nothing after it.
+func closesFence(line, fence string) bool {
+	if indentOf(line) >= 4 {
+		return false
+	}
+	t := strings.TrimLeft(line, " ")
+	run := len(t) - len(strings.TrimLeft(t, fence[:1]))
+	return run >= len(fence) && isBlank(t[run:])
+}
+
+func (m *markdown) indentedCode(out *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines) && (isBlank(lines[i]) || indentOf(lines[i]) >= 4); i++ {
+		code = append(code, trimIndent(lines[i], 4))
+	}
+	// Blank lines after the code belong to no block.
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+		i--
+	}
+	out.WriteString("<pre><code>")
+	for _, line := range code {
+		out.WriteString(html.EscapeString(line) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+// blockquote renders the quoted lines from i, and the unquoted lines that
+// carry on the quote's last paragraph.
+func (m *markdown) blockquote(out *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isQuote(line) {
+			inner = append(inner, stripQuote(line))
+			continue
+		}
+		if isBlank(line) || isBlank(inner[len(inner)-1]) || indentOf(inner[len(inner)-1]) >= 4 ||
+			interruptsParagraph(lines, i) || listMarker.MatchString(line) {
+			break
+		}
+		inner = append(inner, line)
+	}
+	out.WriteString("<blockquote>\n")
+	m.blocks(out, inner, false)
+	out.WriteString("</blockquote>\n")
+	return i
+}
+
+// list renders the items from i that share a list marker: the same bullet,
+// or numbers with the same delimiter. A list is loose, its items' paragraphs
+// wrapped in <p>, when a blank line separates two items or two blocks of one
+// item.
+func (m *markdown) list(out *strings.Builder, lines []string, i int) int {
+	first := listMarker.FindStringSubmatch(lines[i])
+	ordered := first[3] != ""
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		g := listMarker.FindStringSubmatch(lines[i])
+		if g == nil || thematicBreak.MatchString(lines[i]) || (g[3] != "") != ordered ||
+			(ordered && g[4] != first[4]) || (!ordered && g[2] != first[2]) {
+			break
+		}
+		item, next := listItem(lines, i, g)
+		items = append(items, item)
+		if looseItem(item) {
+			loose = true
+		}
+		// Blank lines before the next item of this list make it loose.
+		j := next
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j == next || j == len(lines) {
+			i = next
+			continue
+		}
+		if g := listMarker.FindStringSubmatch(lines[j]); g != nil && (g[3] != "") == ordered &&
+			((ordered && g[4] == first[4]) || (!ordered && g[2] == first[2])) && !thematicBreak.MatchString(lines[j]) {
+			loose = true
+			i = j
+			continue
+		}
+		i = next
+		break
+	}
+
+	if ordered {
+		start, _ := strconv.Atoi(first[3])
+		if start != 1 {
+			fmt.Fprintf(out, "<ol start=\"%d\">\n", start)
+		} else {
+			out.WriteString("<ol>\n")
+		}
+	} else {
+		out.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		li := "<li>"
+		if len(item) > 0 {
+			if t := taskMarker.FindStringSubmatch(item[0]); t != nil {
+				li = `<li class="task">`
+				box := "\u2610 "
+				if t[1] != " " {
+					box = "\u2611 "
+				}
+				item[0] = box + item[0][len(t[0]):]
+			}
+		}
+		var b strings.Builder
+		m.blocks(&b, item, !loose)
+		out.WriteString(li + strings.TrimSuffix(b.String(), "\n") + "</li>\n")
+	}
+	if ordered {
+		out.WriteString("</ol>\n")
+	} else {
+		out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// listItem returns the content of the list item starting at lines[i], whose
+// marker is g, with its indentation removed, and the index of the line after
+// it. Trailing blank lines are not part of the item.
+func listItem(lines []string, i int, g []string) ([]string, int) {
+	line := lines[i]
+	markerEnd := len(g[1]) + len(g[2])
+	width := len(g[0])
+	first := line[width:]
+	switch {
+	case isBlank(first):
+		width, first = markerEnd+1, ""
+	case len(g[5]) > 4:
+		// The item starts with indented code, one space after the marker.
+		width, first = markerEnd+1, line[markerEnd+1:]
+	}
+	item := []string{first}
+
+	end := i + 1
+	for j := i + 1; j < len(lines); j++ {
+		line := lines[j]
+		switch {
+		case isBlank(line):
+			item = append(item, "")
+			continue
+		case indentOf(line) >= width:
+			item = append(item, line[width:])
+		case !isBlank(lines[j-1]) && !interruptsParagraph(lines, j) && !listMarker.MatchString(line):
+			// A lazy continuation line carries on the item's last paragraph.
+			item = append(item, strings.TrimLeft(line, " "))
+		default:
+			return trimBlankTail(item), end
+		}
+		end = j + 1
+	}
+	return trimBlankTail(item), end
+}
+
+// looseItem reports whether a blank line in item separates two of its own
+// blocks, rather than lying inside a code block or a nested list.
+func looseItem(item []string) bool {
+	fence := ""
+	nested := false
+	for j, line := range item {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if g := fenceOpen.FindStringSubmatch(line); g != nil {
+			fence = g[2]
+			continue
+		}
+		if listMarker.MatchString(line) && !thematicBreak.MatchString(line) && j > 0 {
+			nested = true
+		}
+		if j > 0 && isBlank(item[j-1]) && !isBlank(line) && indentOf(line) == 0 &&
+			!(nested && listMarker.MatchString(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// table renders a GitHub table: a header row, a delimiter row that sets each
+// column's alignment, and body rows up to a blank line or another block.
+func (m *markdown) table(out *strings.Builder, lines []string, i int) int {
+	header := splitRow(lines[i])
+	aligns := make([]string, len(header))
+	for c, d := range splitRow(lines[i+1]) {
+		left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+		switch {
+		case left && right:
+			aligns[c] = "center"
+		case right:
+			aligns[c] = "right"
+		case left:
+			aligns[c] = "left"
+		}
+	}
+
+	out.WriteString("<table>\n<thead>\n<tr>")
+	for c, cell := range header {
+		m.cell(out, "th", cell, aligns[c])
+	}
+	out.WriteString("</tr>\n</thead>\n")
+	i += 2
+	if i < len(lines) && !endsTable(lines[i]) {
+		out.WriteString("<tbody>\n")
+		for ; i < len(lines) && !endsTable(lines[i]); i++ {
+			cells := splitRow(lines[i])
+			out.WriteString("<tr>")
+			for c := range header {
+				cell := ""
+				if c < len(cells) {
+					cell = cells[c]
+				}
+				m.cell(out, "td", cell, aligns[c])
+			}
+			out.WriteString("</tr>\n")
+		}
+		out.WriteString("</tbody>\n")
+	}
+	out.WriteString("</table>\n")
+	return i
+}
+
+func (m *markdown) cell(out *strings.Builder, tag, text, align string) {
+	if align != "" {
+		fmt.Fprintf(out, `<%s style="text-align: %s">`, tag, align)
+	} else {
+		out.WriteString("<" + tag + ">")
+	}
+	out.WriteString(m.inline(text) + "</" + tag + ">")
+}
+
+// isTableStart reports whether lines[i] is a table's header row: a row with
+// a pipe, followed by a delimiter row with as many cells.
+func isTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) && indentOf(lines[i]) < 4 && strings.Contains(lines[i], "|") &&
+		tableDelim.MatchString(lines[i+1]) && len(splitRow(lines[i])) == len(splitRow(lines[i+1]))
+}
+
+func endsTable(line string) bool {
+	return isBlank(line) || fenceOpen.MatchString(line) || atxHeading.MatchString(line) ||
+		thematicBreak.MatchString(line) || isQuote(line) || listMarker.MatchString(line)
+}
+
+// splitRow splits a table row into its trimmed cells at each pipe not escaped
+// with a backslash; an escaped pipe is kept as a pipe.
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// collectRefs records the link reference definitions, [label]: url "title",
+// outside code blocks, so that a link may come before its definition.
+func (m *markdown) collectRefs(lines []string) {
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if g := fenceOpen.FindStringSubmatch(line); g != nil {
+			fence = g[2]
+			continue
+		}
+		g := linkDef.FindStringSubmatch(line)
+		if g == nil || (i > 0 && !isBlank(lines[i-1]) && !linkDef.MatchString(lines[i-1])) {
+			continue
+		}
+		label := normalizeLabel(g[1])
+		if _, ok := m.refs[label]; ok {
+			continue
+		}
+		title := ""
+		if len(g[3]) >= 2 {
+			title = unescape(g[3][1 : len(g[3])-1])
+		}
+		m.refs[label] = mdLink{dest: unescape(g[2]), title: title}
+	}
+}
+
+func (m *markdown) isRefDef(line string) bool {
+	g := linkDef.FindStringSubmatch(line)
+	if g == nil {
+		return false
+	}
+	_, ok := m.refs[normalizeLabel(g[1])]
+	return ok
+}
+
+// ── Inlines ──
+
+// inlineToken is a run of rendered HTML, or a run of *, _, or ~ that may
+// open or close emphasis.
+type inlineToken struct {
+	html              string
+	delim             byte
+	count, orig       int // characters left in the run, and at first
+	canOpen, canClose bool
+	open, close       string // tags the run opens after, or closes before, its characters
+}
+
+// inline renders the inline Markdown of s. Text is escaped as it is read, so
+// code spans, URLs, and backslash escapes are never processed twice.
+func (m *markdown) inline(s string) string {
+	tokens := m.scanInline(s)
+	matchEmphasis(tokens)
+	var b strings.Builder
+	for _, t := range tokens {
+		if t.delim == 0 {
+			b.WriteString(t.html)
+			continue
+		}
+		b.WriteString(t.close)
+		b.WriteString(strings.Repeat(string(t.delim), t.count))
+		b.WriteString(t.open)
+	}
+	return b.String()
+}
+
+func (m *markdown) scanInline(s string) []*inlineToken {
+	var tokens []*inlineToken
+	var text strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			text.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			// A hard line break; every line break is kept anyway.
+			i++
+		case c == '\n':
+			text.WriteString("<br>\n")
+			i++
+		case c == '`':
+			code, n := codeSpan(s[i:])
+			if n == 0 {
+				n = len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+				code = s[i : i+n]
+			}
+			text.WriteString(code)
+			i += n
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if link, n := m.link(s, i+1, true); n > 0 {
+				text.WriteString(link)
+				i += 1 + n
+			} else {
+				text.WriteString("!")
+				i++
+			}
+		case c == '[':
+			if link, n := m.link(s, i, false); n > 0 {
+				text.WriteString(link)
+				i += n
+			} else {
+				text.WriteString("[")
+				i++
+			}
+		case c == '<':
+			if g := autolink.FindStringSubmatch(s[i:]); g != nil && !m.inLink {
+				href := g[1]
+				if !strings.Contains(href, ":") {
+					href = "mailto:" + href
+				}
+				text.WriteString(`<a href="` + escapeURL(href) + `">` + html.EscapeString(g[1]) + "</a>")
+				i += len(g[0])
+			} else if tag := inlineTag.FindString(s[i:]); tag != "" {
+				text.WriteString(tag)
+				i += len(tag)
+			} else {
+				text.WriteString("&lt;")
+				i++
+			}
+		case c == '&':
+			if ref := entityRef.FindString(s[i:]); ref != "" {
+				text.WriteString(ref)
+				i += len(ref)
+			} else {
+				text.WriteString("&amp;")
+				i++
+			}
+		case (c == 'h' || c == 'w') && !m.inLink && bareLinkAllowed(s, i):
+			url := trimLinkEnd(bareLink.FindString(s[i:]))
+			if url == "" {
+				text.WriteByte(c)
+				i++
+				continue
+			}
+			href := url
+			if strings.HasPrefix(href, "www.") {
+				href = "http://" + href
+			}
+			text.WriteString(`<a href="` + escapeURL(href) + `">` + html.EscapeString(url) + "</a>")
+			i += len(url)
+		case c == '*' || c == '_' || c == '~':
+			if text.Len() > 0 {
+				tokens = append(tokens, &inlineToken{html: text.String()})
+				text.Reset()
+			}
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], string(c)))
+			tokens = append(tokens, delimiterRun(s, i, n))
+			i += n
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			text.WriteString(html.EscapeString(s[i : i+size]))
+			i += size
+		}
+	}
+	if text.Len() > 0 {
+		tokens = append(tokens, &inlineToken{html: text.String()})
+	}
+	return tokens
+}
+
+// codeSpan renders the code span at the start of s, which begins with a run
+// of backticks, and returns its length in s; 0 if the run is not closed.
+func codeSpan(s string) (string, int) {
+	n := len(s) - len(strings.TrimLeft(s, "`"))
+	for j := n; j < len(s); {
+		k := strings.Index(s[j:], s[:n])
+		if k < 0 {
+			return "", 0
+		}
+		k += j
+		end := k + n
+		for end < len(s) && s[end] == '`' {
+			end++
+		}
+		if end-k != n {
+			j = end
+			continue
+		}
+		code := strings.ReplaceAll(s[n:k], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		return "<code>" + html.EscapeString(code) + "</code>", end
+	}
+	return "", 0
+}
+
+// link renders the link or image whose text starts at s[i], a [, and returns
+// its length in s; 0 if there is no link there. The destination follows in
+// parentheses, or in a reference definition named by a second [label], or
+// by the text itself.
+func (m *markdown) link(s string, i int, image bool) (string, int) {
+	j := closingBracket(s, i)
+	if j < 0 {
+		return "", 0
+	}
+	label := s[i+1 : j]
+	rest := s[j+1:]
+	var target mdLink
+	n := 0
+	switch {
+	case strings.HasPrefix(rest, "("):
+		var ok bool
+		if target, n, ok = parseDestination(rest); !ok {
+			return "", 0
+		}
+	case strings.HasPrefix(rest, "[") && strings.Contains(rest, "]"):
+		k := strings.Index(rest, "]")
+		ref := rest[1:k]
+		if ref == "" {
+			ref = label
+		}
+		var ok bool
+		if target, ok = m.refs[normalizeLabel(ref)]; !ok {
+			return "", 0
+		}
+		n = k + 1
+	default:
+		var ok bool
+		if target, ok = m.refs[normalizeLabel(label)]; !ok {
+			return "", 0
+		}
+	}
+
+	title := ""
+	if target.title != "" {
+		title = ` title="` + html.EscapeString(target.title) + `"`
+	}
+	length := j + 1 + n - i
+	if image {
+		return `<img src="` + escapeURL(target.dest) + `" alt="` + html.EscapeString(unescape(label)) + `"` + title + `>`, length
+	}
+	if m.inLink {
+		return "", 0
+	}
+	m.inLink = true
+	text := m.inline(label)
+	m.inLink = false
+	return `<a href="` + escapeURL(target.dest) + `"` + title + `>` + text + "</a>", length
+}
+
+// closingBracket returns the index of the ] matching the [ at s[i], skipping
+// escaped brackets and code spans; -1 if there is none.
+func closingBracket(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			if _, n := codeSpan(s[j:]); n > 0 {
+				j += n - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// parseDestination reads (url "title") at the start of s and returns its
+// length in s.
+func parseDestination(s string) (mdLink, int, bool) {
+	var link mdLink
+	p := skipSpace(s, 1)
+	if p < len(s) && s[p] == '<' {
+		end := strings.IndexAny(s[p:], ">\n")
+		if end < 0 || s[p+end] != '>' {
+			return link, 0, false
+		}
+		link.dest = s[p+1 : p+end]
+		p += end + 1
+	} else {
+		start, depth := p, 0
+		for ; p < len(s) && s[p] > ' '; p++ {
+			if s[p] == '\\' && p+1 < len(s) {
+				p++
+			} else if s[p] == '(' {
+				depth++
+			} else if s[p] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		link.dest = s[start:p]
+	}
+
+	q := skipSpace(s, p)
+	if q > p && q < len(s) && strings.IndexByte(`"'(`, s[q]) >= 0 {
+		closer := s[q]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := q + 1
+		for ; end < len(s) && s[end] != closer; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return link, 0, false
+		}
+		link.title = unescape(s[q+1 : end])
+		q = skipSpace(s, end+1)
+	}
+	if q >= len(s) || s[q] != ')' {
+		return link, 0, false
+	}
+	link.dest = unescape(link.dest)
+	return link, q + 1, true
+}
+
+// delimiterRun describes the run of n delimiter characters at s[i], which
+// can open emphasis when followed by text and close it when preceded by text.
+func delimiterRun(s string, i, n int) *inlineToken {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if i+n < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[i+n:])
+	}
+	left := !unicode.IsSpace(after) && (!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	right := !unicode.IsSpace(before) && (!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	t := &inlineToken{delim: s[i], count: n, orig: n, canOpen: left, canClose: right}
+	if s[i] == '_' {
+		// Underscores inside a word, as in snake_case, are not emphasis.
+		t.canOpen = left && (!right || isPunct(before))
+		t.canClose = right && (!left || isPunct(after))
+	}
+	return t
+}
+
+// matchEmphasis pairs delimiter runs into <em>, <strong>, and <del> tags, as
+// CommonMark's delimiter algorithm does: each closer takes the nearest
+// opener of the same character before it.
+func matchEmphasis(tokens []*inlineToken) {
+	for c := 0; c < len(tokens); c++ {
+		closer := tokens[c]
+		if closer.delim == 0 || !closer.canClose || closer.count == 0 {
+			continue
+		}
+		o := c - 1
+		for ; o >= 0; o-- {
+			opener := tokens[o]
+			if opener.delim != closer.delim || !opener.canOpen || opener.count == 0 {
+				continue
+			}
+			if closer.delim == '~' && opener.count != closer.count {
+				continue
+			}
+			if (opener.canClose || closer.canOpen) && (opener.orig+closer.orig)%3 == 0 &&
+				(opener.orig%3 != 0 || closer.orig%3 != 0) {
+				continue
+			}
+			break
+		}
+		if o < 0 {
+			continue
+		}
+
+		opener := tokens[o]
+		n, tag := 1, "em"
+		switch {
+		case closer.delim == '~':
+			if closer.count > 2 {
+				continue
+			}
+			n, tag = closer.count, "del"
+		case opener.count >= 2 && closer.count >= 2:
+			n, tag = 2, "strong"
+		}
+		opener.count -= n
+		closer.count -= n
+		opener.open = "<" + tag + ">" + opener.open
+		closer.close += "</" + tag + ">"
+		// Runs between the two can no longer pair across them.
+		for _, t := range tokens[o+1 : c] {
+			t.canOpen, t.canClose = false, false
+		}
+		if closer.count > 0 {
+			c--
+		}
+	}
+}
+
+// bareLinkAllowed reports whether a bare URL may start at s[i]: at the start
+// of the text, or after a space or an opening parenthesis or emphasis.
+func bareLinkAllowed(s string, i int) bool {
+	if i > 0 && strings.IndexByte(" \t\n(*_~", s[i-1]) < 0 {
+		return false
+	}
+	return bareLink.MatchString(s[i:])
+}
+
+// trimLinkEnd drops trailing punctuation from a bare URL, and a closing
+// parenthesis that has no opening one in the URL.
+func trimLinkEnd(url string) string {
+	for url != "" {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte(`?!.,:*_~'"`, last) >= 0:
+			url = url[:len(url)-1]
+		case last == ')' && strings.Count(url, ")") > strings.Count(url, "("):
+			url = url[:len(url)-1]
+		default:
+			return url
+		}
+	}
+	return url
+}
+
+// ── Helpers ──
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// trimIndent removes up to n leading spaces.
+func trimIndent(line string, n int) string {
+	return line[min(n, indentOf(line)):]
+}
+
+func trimBlankTail(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// expandTabs replaces the tabs in a line's indentation with spaces up to the
+// next multiple of four columns.
+func expandTabs(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\t':
+			b.WriteString(strings.Repeat(" ", 4-b.Len()%4))
+		case ' ':
+			b.WriteByte(' ')
+		default:
+			return b.String() + line[i:]
+		}
+	}
+	return b.String()
+}
+
+func isQuote(line string) bool {
+	return indentOf(line) < 4 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+func stripQuote(line string) string {
+	line = strings.TrimPrefix(strings.TrimLeft(line, " "), ">")
+	return strings.TrimPrefix(line, " ")
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+var backslashEscape = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+
+// unescape removes the backslashes from backslash escapes.
+func unescape(s string) string {
+	return backslashEscape.ReplaceAllString(s, "$1")
+}
+
+// escapeURL makes a link destination safe to use in an HTML attribute.
+func escapeURL(url string) string {
+	return html.EscapeString(strings.ReplaceAll(url, " ", "%20"))
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < 0x80 && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,93 @@
+package mail
+
+import "testing"
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty", "", ""},
+		{"blank", "   \n\t\n", ""},
+		{"paragraph", "Hello *world*", "<p>Hello <em>world</em></p>\n"},
+		{"line break kept", "line one\nline two", "<p>line one<br>\nline two</p>\n"},
+		{"CRLF", "line one\r\nline two", "<p>line one<br>\nline two</p>\n"},
+		{"hard break", "a  \nb", "<p>a<br>\nb</p>\n"},
+		{"backslash break", "a\\\nb", "<p>a<br>\nb</p>\n"},
+
+		{"ATX heading", "# Title\n\nText", "<h1>Title</h1>\n<p>Text</p>\n"},
+		{"closing hashes", "## Title ##", "<h2>Title</h2>\n"},
+		{"empty heading", "# ", "<h1></h1>\n"},
+		{"no space after hash", "#nospace", "<p>#nospace</p>\n"},
+		{"seven hashes", "####### seven", "<p>####### seven</p>\n"},
+		{"setext h1", "Title\n=====", "<h1>Title</h1>\n"},
+		{"setext h2", "Sub\n---", "<h2>Sub</h2>\n"},
+		{"thematic breaks", "a\n***\nb\n\n* * *", "<p>a</p>\n<hr>\n<p>b</p>\n<hr>\n"},
+
+		{"fenced code", "```go\nx := 1 < 2\n```", "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>\n"},
+		{"tilde fence", "~~~\n*not em*\n~~~", "<pre><code>*not em*\n</code></pre>\n"},
+		{"unclosed fence", "```\nunclosed", "<pre><code>unclosed\n</code></pre>\n"},
+		{"indented code", "    code\n    more", "<pre><code>code\nmore\n</code></pre>\n"},
+		{"tab-indented code", "\tcode", "<pre><code>code\n</code></pre>\n"},
+
+		{"blockquote", "> quote\n> more\n\nafter", "<blockquote>\n<p>quote<br>\nmore</p>\n</blockquote>\n<p>after</p>\n"},
+		{"list in a quote", "> - a\n> - b", "<blockquote>\n<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n</blockquote>\n"},
+		{"quote after a list", "- a\n> q", "<ul>\n<li>a</li>\n</ul>\n<blockquote>\n<p>q</p>\n</blockquote>\n"},
+
+		{"nested list", "- a\n- b\n  - c\n- d", "<ul>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul></li>\n<li>d</li>\n</ul>\n"},
+		{"ordered list", "1. one\n2. two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"ordered list start", "3) three\n4) four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"loose list", "- a\n\n- b", "<ul>\n<li><p>a</p></li>\n<li><p>b</p></li>\n</ul>\n"},
+		{"task list", "- [ ] todo\n- [x] done", "<ul>\n<li class=\"task\">☐ todo</li>\n<li class=\"task\">☑ done</li>\n</ul>\n"},
+		{"empty items", "- \n- x", "<ul>\n<li></li>\n<li>x</li>\n</ul>\n"},
+		{"lone marker", "*", "<ul>\n<li></li>\n</ul>\n"},
+
+		{"table", "| a | b |\n|:--|--:|\n| 1 | 2 |\n| 3 |",
+			"<table>\n<thead>\n<tr><th style=\"text-align: left\">a</th><th style=\"text-align: right\">b</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td style=\"text-align: left\">1</td><td style=\"text-align: right\">2</td></tr>\n" +
+				"<tr><td style=\"text-align: left\">3</td><td style=\"text-align: right\"></td></tr>\n</tbody>\n</table>\n"},
+		{"table without pipes at the ends, extra cell dropped", "a | b\n--|--\n1 | 2 | 3",
+			"<table>\n<thead>\n<tr><th>a</th><th>b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td>2</td></tr>\n</tbody>\n</table>\n"},
+		{"escaped pipe in a cell", "| a |\n|---|\n| x \\| y |",
+			"<table>\n<thead>\n<tr><th>a</th></tr>\n</thead>\n<tbody>\n<tr><td>x | y</td></tr>\n</tbody>\n</table>\n"},
+
+		{"emphasis", "**bold** and _em_ and ***both***", "<p><strong>bold</strong> and <em>em</em> and <em><strong>both</strong></em></p>\n"},
+		{"nested emphasis", "**bold*both***", "<p><strong>bold<em>both</em></strong></p>\n"},
+		{"intraword star", "foo*bar*baz", "<p>foo<em>bar</em>baz</p>\n"},
+		{"intraword underscore", "snake_case_word", "<p>snake_case_word</p>\n"},
+		{"underscores inside", "_a_b_", "<p><em>a_b</em></p>\n"},
+		{"unmatched stars", "**", "<p>**</p>\n"},
+		{"strikethrough", "~~gone~~", "<p><del>gone</del></p>\n"},
+		{"backslash escapes", "\\*not em\\*", "<p>*not em*</p>\n"},
+
+		{"code spans", "`code` and ``a ` b``", "<p><code>code</code> and <code>a ` b</code></p>\n"},
+		{"unclosed code span", "`unclosed", "<p>`unclosed</p>\n"},
+		{"emphasis in a code span", "`*x*`", "<p><code>*x*</code></p>\n"},
+
+		{"link with title", "[link](https://x.com \"T\")", "<p><a href=\"https://x.com\" title=\"T\">link</a></p>\n"},
+		{"image", "![alt](img.png)", "<p><img src=\"img.png\" alt=\"alt\"></p>\n"},
+		{"reference link", "[ref][1]\n\n[1]: https://x.com 'Title'", "<p><a href=\"https://x.com\" title=\"Title\">ref</a></p>\n"},
+		{"collapsed reference, any case", "[Ref][]\n\n[ref]: https://x.com", "<p><a href=\"https://x.com\">Ref</a></p>\n"},
+		{"missing reference", "[missing][nope]", "<p>[missing][nope]</p>\n"},
+		{"brackets in link text", "[a [nested] b](u)", "<p><a href=\"u\">a [nested] b</a></p>\n"},
+		{"destination with spaces", "[a](<url with spaces>)", "<p><a href=\"url%20with%20spaces\">a</a></p>\n"},
+		{"quote in destination", "[a](url\"q)", "<p><a href=\"url&#34;q\">a</a></p>\n"},
+		{"text after a link", "[link](u)(x)", "<p><a href=\"u\">link</a>(x)</p>\n"},
+		{"autolink", "<https://x.com>", "<p><a href=\"https://x.com\">https://x.com</a></p>\n"},
+		{"email autolink", "<a@b.com>", "<p><a href=\"mailto:a@b.com\">a@b.com</a></p>\n"},
+		{"bare link, trailing punctuation", "see https://x.com/a_b). now", "<p>see <a href=\"https://x.com/a_b\">https://x.com/a_b</a>). now</p>\n"},
+		{"bare www link", "www.example.com.", "<p><a href=\"http://www.example.com\">www.example.com</a>.</p>\n"},
+
+		{"entities", "&amp; &copy; &bogus; & <", "<p>&amp; &copy; &bogus; &amp; &lt;</p>\n"},
+		{"inline HTML", "<span>inline</span> text", "<p><span>inline</span> text</p>\n"},
+		{"tab kept in text", "tab\tstop", "<p>tab\tstop</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToHTML(tt.src); got != tt.want {
+				t.Errorf("markdownToHTML(%q)\n got: %q\nwant: %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Raw MIME ----------
+
+// ExportedMessage is the JSON representation of a message written by `mail
+// export`.
+type ExportedMessage struct {
+	ID      string `json:"id"`
+	Subject string `json:"subject"`
+	File    string `json:"file"`
+	Size    int    `json:"size"` // bytes
+}
+
+// messageMIME returns a message as Exchange stores it for transport: its
+// internet headers and MIME body, attachments included.
+func messageMIME(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, messageID string) ([]byte, error) {
+	data, err := mailbox.Of(client).Messages().ByMessageId(messageID).Content().Get(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("downloading MIME content: %w", err)
+	}
+	return data, nil
+}
+
+// ReadRaw prints the raw MIME of the message identified by ref (list index or
+// Graph ID), headers and all, exactly as Exchange returns it.
+func ReadRaw(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+	data, err := messageMIME(ctx, client, messageID)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// Export writes the message identified by ref to an .eml file, which mail
+// clients and archiving tools open as the original message. out defaults to
+// the subject, made safe as a file name, in the current directory.
+func Export(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref, format, out string, jsonOutput bool) error {
+	if format != "" && !strings.EqualFold(format, "eml") {
+		return fmt.Errorf("unknown --format %q for mail export — valid formats: eml", format)
+	}
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading message: %w", err)
+	}
+	data, err := messageMIME(ctx, client, messageID)
+	if err != nil {
+		return err
+	}
+
+	subject := deref(msg.GetSubject(), "")
+	if out == "" {
+		out = emlName(subject)
+	}
+	if err := os.WriteFile(out, data, 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", out, err)
+	}
+	slog.Info("Message exported", "path", out, "bytes", len(data))
+	if jsonOutput {
+		return printJSON(ExportedMessage{ID: messageID, Subject: subject, File: out, Size: len(data)})
+	}
+	return nil
+}
+
+// emlName is a file name for a message with subject: the subject without the
+// characters file systems reject, cut to a readable length.
+func emlName(subject string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(subject))
+	if runes := []rune(name); len(runes) > 80 {
+		name = strings.TrimSpace(string(runes[:80]))
+	}
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		name = "message"
+	}
+	return name + ".eml"
+}
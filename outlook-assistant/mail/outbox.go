@@ -0,0 +1,269 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Outbox (stored in home directory) ----------
+
+// Outgoing is one send, reply, reply-all, or forward, either delivered immediately or
+// kept in the local outbox until `mail outbox-flush` succeeds.
+type Outgoing struct {
+	ID          string `json:"id"`
+	Action      string `json:"action"`
+	Mailbox     string `json:"mailbox,omitempty"`
+	MessageID   string `json:"messageId,omitempty"`
+	To          string `json:"to,omitempty"`
+	Cc          string `json:"cc,omitempty"`
+	Bcc         string `json:"bcc,omitempty"`
+	Subject     string `json:"subject,omitempty"`
+	Body        string `json:"body,omitempty"`
+	Attach      string `json:"attach,omitempty"` // absolute paths, comma-separated
+	Format      string `json:"format,omitempty"`
+	Importance  string `json:"importance,omitempty"`
+	Sensitivity string `json:"sensitivity,omitempty"`
+	QueuedAt    string `json:"queuedAt,omitempty"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"lastError,omitempty"`
+}
+
+func outboxPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".outlook-assistant-outbox.json")
+}
+
+func loadOutbox() ([]Outgoing, error) {
+	data, err := os.ReadFile(outboxPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading outbox: %w", err)
+	}
+	var entries []Outgoing
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing outbox %s: %w", outboxPath(), err)
+	}
+	return entries, nil
+}
+
+func saveOutbox(entries []Outgoing) error {
+	if len(entries) == 0 {
+		if err := os.Remove(outboxPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("clearing outbox: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outboxPath(), data, 0600); err != nil {
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	return nil
+}
+
+// Deliver runs a send, reply, reply-all, or forward. When queue is true and it fails for
+// a reason that may pass (no network, expired sign-in, Graph unavailable), the
+// operation is saved to the outbox and nil is returned.
+// A --ref index is resolved now, since the cached list may change before a
+// retry, and attached files are recorded by absolute path for the same reason.
+func Deliver(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, o Outgoing, queue bool) error {
+	if err := (Marking{Importance: o.Importance, Sensitivity: o.Sensitivity}).Check(); err != nil {
+		return err
+	}
+	if o.MessageID != "" {
+		id, err := resolveMessageID(o.MessageID)
+		if err != nil {
+			return err
+		}
+		o.MessageID = id
+	}
+	if o.Attach != "" {
+		var paths []string
+		for _, path := range strings.Split(o.Attach, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				continue
+			}
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, abs)
+		}
+		o.Attach = strings.Join(paths, ",")
+	}
+
+	err := o.deliver(ctx, client)
+	if err == nil || !queue || !retryable(err) {
+		return err
+	}
+
+	entries, loadErr := loadOutbox()
+	if loadErr != nil {
+		return fmt.Errorf("%w (could not queue: %v)", err, loadErr)
+	}
+	now := time.Now()
+	o.ID = fmt.Sprintf("%d", now.UnixNano())
+	o.Mailbox = mailbox.User()
+	o.QueuedAt = now.Format(time.RFC3339)
+	o.Attempts = 1
+	o.LastError = err.Error()
+	if saveErr := saveOutbox(append(entries, o)); saveErr != nil {
+		return fmt.Errorf("%w (could not queue: %v)", err, saveErr)
+	}
+	slog.Warn("Queued in the outbox — run `mail outbox-flush` to retry", "action", o.Action, "error", err)
+	return nil
+}
+
+func (o Outgoing) deliver(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) error {
+	format := ParseBodyFormat(o.Format)
+	marking := Marking{Importance: o.Importance, Sensitivity: o.Sensitivity}
+	switch o.Action {
+	case "send":
+		return Send(ctx, client, o.To, o.Cc, o.Bcc, o.Subject, o.Body, o.Attach, format, marking)
+	case "reply":
+		return Reply(ctx, client, o.MessageID, o.Body, format, marking)
+	case "reply-all":
+		return ReplyAll(ctx, client, o.MessageID, o.Body, format, marking)
+	case "forward":
+		return Forward(ctx, client, o.MessageID, o.To, o.Cc, o.Bcc, o.Body, format, marking)
+	default:
+		return fmt.Errorf("unknown outbox action %q", o.Action)
+	}
+}
+
+// retryable reports whether err shows the message was never sent, so queuing
+// it cannot deliver it twice: the connection or the sign-in failed, Graph
+// refused the token (401), or Graph turned the request away unread (503 with
+// Retry-After). A timeout, a 502, or a 504 may come after Graph sent the
+// message, so those fail instead.
+func retryable(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return true
+	}
+	var apiErr interface {
+		GetStatusCode() int
+		GetResponseHeaders() *abstractions.ResponseHeaders
+	}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.GetStatusCode() {
+	case 401:
+		return true
+	case 503:
+		headers := apiErr.GetResponseHeaders()
+		return headers != nil && len(headers.Get("Retry-After")) > 0
+	}
+	return false
+}
+
+// Outbox lists operations waiting in the outbox.
+func Outbox(jsonOutput bool) error {
+	entries, err := loadOutbox()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if entries == nil {
+			entries = []Outgoing{}
+		}
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Outbox is empty.")
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-9s  %-30s  %-35s  %-16s  %s\n", "#", "Action", "To / Message", "Subject", "Queued", "Tries")
+	fmt.Println(strings.Repeat("-", 111))
+	for i, o := range entries {
+		target := o.To
+		if target == "" {
+			target = o.MessageID
+		}
+		queued := o.QueuedAt
+		if t, err := time.Parse(time.RFC3339, o.QueuedAt); err == nil {
+			queued = t.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-3d  %-9s  %-30s  %-35s  %-16s  %d\n",
+			i+1, o.Action, truncate(target, 30), truncate(o.Subject, 35), queued, o.Attempts)
+		if o.LastError != "" {
+			fmt.Printf("     last error: %s\n", truncate(o.LastError, 100))
+		}
+	}
+	return nil
+}
+
+// FlushOutbox retries every queued operation for the current mailbox, in the
+// order queued. Delivered entries are removed; failures stay with their error.
+// A reply or forward that failed after its draft was created may leave that
+// draft behind in Drafts.
+func FlushOutbox(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) error {
+	entries, err := loadOutbox()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		slog.Info("Outbox is empty")
+		return nil
+	}
+
+	var remaining []Outgoing
+	sent, failed, skipped := 0, 0, 0
+	for _, o := range entries {
+		if o.Mailbox != mailbox.User() {
+			skipped++
+			remaining = append(remaining, o)
+			continue
+		}
+		if err := o.deliver(ctx, client); err != nil {
+			failed++
+			o.Attempts++
+			o.LastError = err.Error()
+			remaining = append(remaining, o)
+			slog.Warn("Outbox delivery failed", "action", o.Action, "attempt", o.Attempts, "error", err)
+			continue
+		}
+		sent++
+	}
+	if err := saveOutbox(remaining); err != nil {
+		return err
+	}
+
+	attrs := []any{"delivered", sent, "failed", failed}
+	if skipped > 0 {
+		// Rerun with their --mailbox (or --user) to deliver these.
+		attrs = append(attrs, "otherMailboxes", skipped)
+	}
+	slog.Info("Outbox flushed", attrs...)
+	if failed > 0 {
+		return fmt.Errorf("%d outbox entries could not be delivered", failed)
+	}
+	return nil
+}
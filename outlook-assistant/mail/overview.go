@@ -0,0 +1,208 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	msgraphgocore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Overview ----------
+
+// OverviewFolder is the JSON representation of one folder in `mail overview`.
+// WellKnown is set for the standard folders (inbox, drafts, …).
+type OverviewFolder struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	WellKnown string `json:"wellKnown,omitempty"`
+	Unread    int32  `json:"unread"`
+	Total     int32  `json:"total"`
+}
+
+// MailboxOverview is the JSON representation of `mail overview`. Counts that could
+// not be read are null.
+type MailboxOverview struct {
+	InboxUnread   int32            `json:"inboxUnread"`
+	InboxTotal    int32            `json:"inboxTotal"`
+	FocusedUnread *int32           `json:"focusedUnread"`
+	OtherUnread   *int32           `json:"otherUnread"`
+	Flagged       *int32           `json:"flagged"`
+	Folders       []OverviewFolder `json:"folders"`
+}
+
+// overviewWellKnown are the standard folders identified in the overview, in
+// display order; the remaining folders are the user's own.
+var overviewWellKnown = []string{"inbox", "drafts", "sentitems", "archive", "junkemail", "deleteditems", "outbox"}
+
+// Overview prints unread and total counts for the Inbox (split into Focused
+// and Other), flagged messages, and every top-level folder. All of it comes
+// from one $batch call.
+func Overview(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	var steps []*abstractions.RequestInformation
+	add := func(info *abstractions.RequestInformation, err error) error {
+		if err != nil {
+			return fmt.Errorf("building overview request: %w", err)
+		}
+		steps = append(steps, info)
+		return nil
+	}
+
+	// Step 0: every top-level folder with its counts.
+	top := int32(100)
+	if err := add(mailbox.Of(client).MailFolders().ToGetRequestInformation(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersRequestBuilderGetQueryParameters{
+			Select: []string{"id", "displayName", "totalItemCount", "unreadItemCount"},
+			Top:    &top,
+		},
+	})); err != nil {
+		return err
+	}
+
+	// Steps 1-3: message counts that folders do not carry.
+	one := int32(1)
+	count := true
+	countFilters := []string{
+		"inferenceClassification eq 'focused' and isRead eq false",
+		"inferenceClassification eq 'other' and isRead eq false",
+	}
+	for _, filter := range countFilters {
+		filter := filter
+		if err := add(mailbox.Of(client).MailFolders().ByMailFolderId("inbox").Messages().ToGetRequestInformation(ctx, &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+			QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+				Filter: &filter,
+				Select: []string{"id"},
+				Top:    &one,
+				Count:  &count,
+			},
+		})); err != nil {
+			return err
+		}
+	}
+	flagged := "flag/flagStatus eq 'flagged'"
+	if err := add(mailbox.Of(client).Messages().ToGetRequestInformation(ctx, &users.ItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesRequestBuilderGetQueryParameters{
+			Filter: &flagged,
+			Select: []string{"id"},
+			Top:    &one,
+			Count:  &count,
+		},
+	})); err != nil {
+		return err
+	}
+	countSteps := len(steps)
+
+	// Remaining steps: the IDs of the well-known folders, to tell them apart.
+	for _, name := range overviewWellKnown {
+		if err := add(mailbox.Of(client).MailFolders().ByMailFolderId(name).ToGetRequestInformation(ctx, &users.ItemMailFoldersMailFolderItemRequestBuilderGetRequestConfiguration{
+			QueryParameters: &users.ItemMailFoldersMailFolderItemRequestBuilderGetQueryParameters{
+				Select: []string{"id"},
+			},
+		})); err != nil {
+			return err
+		}
+	}
+
+	var folders []models.MailFolderable
+	counts := make([]*int32, countSteps)
+	wellKnown := map[string]string{}
+	failed := false
+	err := runBatch(ctx, client, steps, func(i int, resp msgraphgocore.BatchResponse, item msgraphgocore.BatchItem) {
+		if item.GetStatus() == nil || *item.GetStatus() < 200 || *item.GetStatus() > 299 {
+			// A mailbox without an archive folder answers 404; that is not a failure.
+			failed = failed || i < countSteps
+			return
+		}
+		id := deref(item.GetId(), "")
+		switch {
+		case i == 0:
+			if page, err := msgraphgocore.GetBatchResponseById[models.MailFolderCollectionResponseable](resp, id, models.CreateMailFolderCollectionResponseFromDiscriminatorValue); err == nil {
+				folders = page.GetValue()
+			}
+		case i < countSteps:
+			if page, err := msgraphgocore.GetBatchResponseById[models.MessageCollectionResponseable](resp, id, models.CreateMessageCollectionResponseFromDiscriminatorValue); err == nil && page.GetOdataCount() != nil {
+				n := int32(*page.GetOdataCount())
+				counts[i] = &n
+			}
+		default:
+			if f, err := msgraphgocore.GetBatchResponseById[models.MailFolderable](resp, id, models.CreateMailFolderFromDiscriminatorValue); err == nil {
+				wellKnown[deref(f.GetId(), "")] = overviewWellKnown[i-countSteps]
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if folders == nil {
+		return fmt.Errorf("listing folders failed")
+	}
+	if failed {
+		slog.Warn("Some counts could not be read and are shown as -")
+	}
+
+	overview := MailboxOverview{
+		FocusedUnread: counts[1],
+		OtherUnread:   counts[2],
+		Flagged:       counts[3],
+		Folders:       make([]OverviewFolder, 0, len(folders)),
+	}
+	for _, f := range folders {
+		of := OverviewFolder{
+			ID:        deref(f.GetId(), ""),
+			Name:      deref(f.GetDisplayName(), ""),
+			WellKnown: wellKnown[deref(f.GetId(), "")],
+		}
+		if f.GetUnreadItemCount() != nil {
+			of.Unread = *f.GetUnreadItemCount()
+		}
+		if f.GetTotalItemCount() != nil {
+			of.Total = *f.GetTotalItemCount()
+		}
+		if of.WellKnown == "inbox" {
+			overview.InboxUnread, overview.InboxTotal = of.Unread, of.Total
+		}
+		overview.Folders = append(overview.Folders, of)
+	}
+	// Standard folders first, in a fixed order, then the user's own.
+	rank := func(f OverviewFolder) int {
+		for i, name := range overviewWellKnown {
+			if f.WellKnown == name {
+				return i
+			}
+		}
+		return len(overviewWellKnown)
+	}
+	sort.SliceStable(overview.Folders, func(i, j int) bool { return rank(overview.Folders[i]) < rank(overview.Folders[j]) })
+
+	if jsonOutput {
+		return printJSON(overview)
+	}
+
+	orDash := func(n *int32) string {
+		if n == nil {
+			return "-"
+		}
+		return fmt.Sprint(*n)
+	}
+	fmt.Printf("\nInbox    %d unread of %d   (focused %s, other %s)\n",
+		overview.InboxUnread, overview.InboxTotal, orDash(overview.FocusedUnread), orDash(overview.OtherUnread))
+	fmt.Printf("Flagged  %s\n\n", orDash(overview.Flagged))
+	fmt.Printf("%-35s  %8s  %8s\n", "Folder", "Unread", "Total")
+	fmt.Println(strings.Repeat("-", 55))
+	for i, f := range overview.Folders {
+		if i > 0 && f.WellKnown == "" && overview.Folders[i-1].WellKnown != "" {
+			fmt.Println()
+		}
+		fmt.Printf("%-35s  %8d  %8d\n", truncate(f.Name, 35), f.Unread, f.Total)
+	}
+	return nil
+}
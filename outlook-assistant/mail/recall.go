@@ -0,0 +1,136 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Recall (beta) ----------
+
+// RecallRecipient is the recall status for one recipient of a recalled message.
+type RecallRecipient struct {
+	Address string `json:"address"`
+	Status  string `json:"status"`
+}
+
+// RecallResult is the JSON representation of a recall request.
+type RecallResult struct {
+	ID         string            `json:"id"`
+	Subject    string            `json:"subject"`
+	Requested  bool              `json:"requested"`
+	Recipients []RecallRecipient `json:"recipients"`
+	Response   json.RawMessage   `json:"response,omitempty"`
+}
+
+// Per-recipient recall statuses.
+const (
+	recallRequested = "requested"
+	recallExternal  = "not recallable: outside your organization"
+)
+
+// Recall asks Exchange to recall a message the user sent, using the Graph beta
+// /messages/{id}/recall endpoint. Recall only works for recipients in the
+// sender's organization who have not opened the message; the final outcome for
+// each recipient arrives later as a "Message Recall Report" email.
+// Recipients outside the sender's domain are reported as not recallable.
+func Recall(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, jsonOutput bool) error {
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"id", "subject", "from", "toRecipients", "ccRecipients", "bccRecipients", "isDraft", "sentDateTime"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading message: %w", err)
+	}
+	if msg.GetIsDraft() != nil && *msg.GetIsDraft() {
+		return fmt.Errorf("message %q is a draft and has not been sent", ref)
+	}
+
+	me, err := mailbox.Of(client).Get(ctx, &users.UserItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.UserItemRequestBuilderGetQueryParameters{
+			Select: []string{"mail", "userPrincipalName"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading mailbox owner: %w", err)
+	}
+	myAddress := strings.ToLower(deref(me.GetMail(), deref(me.GetUserPrincipalName(), "")))
+	if !strings.EqualFold(senderAddress(msg), myAddress) {
+		return fmt.Errorf("only messages you sent can be recalled (this one is from %s)", senderAddress(msg))
+	}
+
+	// The v1.0 SDK has no recall request builder, so send the beta call through
+	// the client's adapter: it still gets authentication, retries and --stats.
+	adapter := client.GetAdapter()
+	info := abstractions.NewRequestInformation()
+	info.Method = abstractions.POST
+	info.UrlTemplate = "{+baseurl}/{+mailbox}/messages/{message%2Did}/recall"
+	info.PathParameters = map[string]string{
+		"baseurl":      strings.Replace(adapter.GetBaseUrl(), "/v1.0", "/beta", 1),
+		"mailbox":      mailbox.Path(),
+		"message%2Did": messageID,
+	}
+	info.Headers.TryAdd("Accept", "application/json")
+	raw, err := adapter.SendPrimitive(ctx, info, "[]byte", abstractions.ErrorMappings{
+		"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue,
+	})
+	if err != nil {
+		return fmt.Errorf("recalling message (beta endpoint): %w", err)
+	}
+
+	domain := ""
+	if at := strings.LastIndex(myAddress, "@"); at >= 0 {
+		domain = myAddress[at:]
+	}
+	result := RecallResult{
+		ID:         messageID,
+		Subject:    deref(msg.GetSubject(), ""),
+		Requested:  true,
+		Recipients: []RecallRecipient{},
+	}
+	for _, list := range [][]string{
+		recipientAddresses(msg.GetToRecipients()),
+		recipientAddresses(msg.GetCcRecipients()),
+		recipientAddresses(msg.GetBccRecipients()),
+	} {
+		for _, addr := range list {
+			status := recallRequested
+			if domain == "" || !strings.HasSuffix(addr, domain) {
+				status = recallExternal
+			}
+			result.Recipients = append(result.Recipients, RecallRecipient{Address: addr, Status: status})
+		}
+	}
+	if b, ok := raw.([]byte); ok && json.Valid(b) {
+		result.Response = b
+	}
+
+	if jsonOutput {
+		return printJSON(result)
+	}
+
+	slog.Info("Recall requested", "subject", result.Subject)
+	fmt.Printf("\n%-40s  %s\n", "Recipient", "Status")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, r := range result.Recipients {
+		fmt.Printf("%-40s  %s\n", truncate(r.Address, 40), r.Status)
+	}
+	slog.Info("Outlook sends a \"Message Recall Report\" email with the final result for each recipient")
+	return nil
+}
@@ -0,0 +1,397 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Inbox rules ----------
+
+// RuleSummary is the JSON representation of an inbox rule. Conditions,
+// exceptions, and actions are described in words, such as "from a@b.com"
+// or "move to Receipts".
+type RuleSummary struct {
+	Sequence   int32    `json:"sequence"`
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Enabled    bool     `json:"enabled"`
+	ReadOnly   bool     `json:"readOnly,omitempty"` // created in Outlook with options Graph cannot change
+	HasError   bool     `json:"hasError,omitempty"`
+	Conditions []string `json:"conditions"`
+	Exceptions []string `json:"exceptions,omitempty"`
+	Actions    []string `json:"actions"`
+}
+
+// RuleSpec holds the conditions and actions of a rule to create. Every
+// condition given must match; every action given is taken.
+type RuleSpec struct {
+	Name            string
+	From            string // comma-separated sender addresses
+	SubjectContains string // comma-separated; any of them matches
+	HasAttachment   bool
+	MoveTo          string // folder name
+	Categories      string // comma-separated
+	MarkRead        bool
+	ForwardTo       string // comma-separated addresses
+}
+
+// Rules lists the inbox rules in the order Exchange applies them.
+func Rules(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	rules, err := listRules(ctx, client)
+	if err != nil {
+		return err
+	}
+	names := folderNames(ctx, client)
+
+	summaries := make([]RuleSummary, 0, len(rules))
+	for _, r := range rules {
+		summaries = append(summaries, ruleSummary(r, names))
+	}
+	if jsonOutput {
+		return printJSON(summaries)
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No inbox rules found.")
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-35s  %-3s  %-40s  %s\n", "#", "Rule", "On", "Conditions", "Actions")
+	fmt.Println(strings.Repeat("-", 120))
+	for _, s := range summaries {
+		on := "yes"
+		if !s.Enabled {
+			on = "no"
+		}
+		conditions := strings.Join(s.Conditions, "; ")
+		if len(s.Exceptions) > 0 {
+			conditions += "; except " + strings.Join(s.Exceptions, "; ")
+		}
+		fmt.Printf("%-3d  %-35s  %-3s  %-40s  %s\n", s.Sequence, truncate(s.Name, 35), on,
+			truncate(conditions, 40), strings.Join(s.Actions, "; "))
+	}
+	return nil
+}
+
+// CreateRule creates an inbox rule from spec, after the existing rules. At
+// least one condition is required, so that a mistyped command cannot file
+// away every incoming message.
+func CreateRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, spec RuleSpec, jsonOutput bool) error {
+	if spec.Name == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	conditions := models.NewMessageRulePredicates()
+	hasCondition := false
+	if spec.From != "" {
+		conditions.SetFromAddresses(parseRecipients(spec.From))
+		hasCondition = true
+	}
+	if words := splitList(spec.SubjectContains); len(words) > 0 {
+		conditions.SetSubjectContains(words)
+		hasCondition = true
+	}
+	if spec.HasAttachment {
+		conditions.SetHasAttachments(&spec.HasAttachment)
+		hasCondition = true
+	}
+	if !hasCondition {
+		return fmt.Errorf("give at least one condition: --from, --subject-contains, or --has-attachment")
+	}
+
+	actions := models.NewMessageRuleActions()
+	hasAction := false
+	if spec.MoveTo != "" {
+		folderID, err := folderIDForRule(ctx, client, spec.MoveTo)
+		if err != nil {
+			return err
+		}
+		actions.SetMoveToFolder(&folderID)
+		hasAction = true
+	}
+	if cats := splitList(spec.Categories); len(cats) > 0 {
+		actions.SetAssignCategories(cats)
+		hasAction = true
+	}
+	if spec.MarkRead {
+		actions.SetMarkAsRead(&spec.MarkRead)
+		hasAction = true
+	}
+	if spec.ForwardTo != "" {
+		actions.SetForwardTo(parseRecipients(spec.ForwardTo))
+		hasAction = true
+	}
+	if !hasAction {
+		return fmt.Errorf("give at least one action: --move-to, --set, --mark-read, or --to")
+	}
+
+	existing, err := listRules(ctx, client)
+	if err != nil {
+		return err
+	}
+	sequence := int32(1)
+	for _, r := range existing {
+		if s := r.GetSequence(); s != nil && *s >= sequence {
+			sequence = *s + 1
+		}
+	}
+
+	rule := models.NewMessageRule()
+	rule.SetDisplayName(&spec.Name)
+	rule.SetSequence(&sequence)
+	enabled := true
+	rule.SetIsEnabled(&enabled)
+	rule.SetConditions(conditions)
+	rule.SetActions(actions)
+
+	created, err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().Post(ctx, rule, nil)
+	if err != nil {
+		return fmt.Errorf("creating inbox rule: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(ruleSummary(created, folderNames(ctx, client)))
+	}
+	slog.Info("Inbox rule created", "rule", deref(created.GetDisplayName(), spec.Name), "sequence", sequence)
+	return nil
+}
+
+// DeleteRule deletes the inbox rule named by ref, a rule ID or display name.
+func DeleteRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
+	rule, err := findRule(ctx, client, ref)
+	if err != nil {
+		return err
+	}
+	if err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().ByMessageRuleId(deref(rule.GetId(), "")).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("deleting inbox rule: %w", err)
+	}
+	slog.Info("Inbox rule deleted", "rule", deref(rule.GetDisplayName(), ref))
+	return nil
+}
+
+// EnableRule turns the inbox rule named by ref, a rule ID or display name, on
+// or off. A disabled rule keeps its conditions and actions.
+func EnableRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, enabled bool) error {
+	rule, err := findRule(ctx, client, ref)
+	if err != nil {
+		return err
+	}
+	patch := models.NewMessageRule()
+	patch.SetIsEnabled(&enabled)
+	if _, err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().ByMessageRuleId(deref(rule.GetId(), "")).Patch(ctx, patch, nil); err != nil {
+		return fmt.Errorf("updating inbox rule: %w", err)
+	}
+	if enabled {
+		slog.Info("Inbox rule enabled", "rule", deref(rule.GetDisplayName(), ref))
+	} else {
+		slog.Info("Inbox rule disabled", "rule", deref(rule.GetDisplayName(), ref))
+	}
+	return nil
+}
+
+// listRules returns the inbox rules sorted by sequence.
+func listRules(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) ([]models.MessageRuleable, error) {
+	result, err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().Get(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing inbox rules: %w", err)
+	}
+	rules := result.GetValue()
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i].GetSequence(), rules[j].GetSequence()
+		return a != nil && (b == nil || *a < *b)
+	})
+	return rules, nil
+}
+
+// findRule returns the inbox rule whose ID is ref, or else whose display name
+// is ref (case-insensitive). A name shared by several rules is refused.
+func findRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) (models.MessageRuleable, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("--rule is required")
+	}
+	rules, err := listRules(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var byName []models.MessageRuleable
+	for _, r := range rules {
+		if deref(r.GetId(), "") == ref {
+			return r, nil
+		}
+		if strings.EqualFold(deref(r.GetDisplayName(), ""), ref) {
+			byName = append(byName, r)
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("inbox rule %q not found — use `rules list` to see them", ref)
+	case 1:
+		return byName[0], nil
+	default:
+		return nil, fmt.Errorf("%d inbox rules are named %q — pass the rule ID from `rules list --json`", len(byName), ref)
+	}
+}
+
+// folderIDForRule returns the real ID of the named folder. Rule actions do not
+// accept well-known names such as "archive" in place of an ID.
+func folderIDForRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name string) (string, error) {
+	folderID, err := resolveFolderID(ctx, client, name)
+	if err != nil {
+		return "", err
+	}
+	folder, err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Get(ctx, &users.ItemMailFoldersMailFolderItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersMailFolderItemRequestBuilderGetQueryParameters{
+			Select: []string{"id"},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("reading folder %q: %w", name, err)
+	}
+	return deref(folder.GetId(), folderID), nil
+}
+
+// folderNames maps top-level folder IDs to display names, so rule actions can
+// name their folder. A failure only means IDs are shown instead.
+func folderNames(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) map[string]string {
+	top := int32(100)
+	result, err := mailbox.Of(client).MailFolders().Get(ctx, &users.ItemMailFoldersRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersRequestBuilderGetQueryParameters{
+			Select: []string{"id", "displayName"},
+			Top:    &top,
+		},
+	})
+	names := make(map[string]string)
+	if err != nil {
+		return names
+	}
+	for _, f := range result.GetValue() {
+		names[deref(f.GetId(), "")] = deref(f.GetDisplayName(), "")
+	}
+	return names
+}
+
+func ruleSummary(r models.MessageRuleable, folders map[string]string) RuleSummary {
+	s := RuleSummary{
+		ID:         deref(r.GetId(), ""),
+		Name:       deref(r.GetDisplayName(), ""),
+		Enabled:    r.GetIsEnabled() != nil && *r.GetIsEnabled(),
+		ReadOnly:   r.GetIsReadOnly() != nil && *r.GetIsReadOnly(),
+		HasError:   r.GetHasError() != nil && *r.GetHasError(),
+		Conditions: describePredicates(r.GetConditions()),
+		Exceptions: describePredicates(r.GetExceptions()),
+		Actions:    describeActions(r.GetActions(), folders),
+	}
+	if r.GetSequence() != nil {
+		s.Sequence = *r.GetSequence()
+	}
+	if len(s.Conditions) == 0 {
+		s.Conditions = []string{"every message"}
+	}
+	return s
+}
+
+// describePredicates lists the set predicates of p in words.
+func describePredicates(p models.MessageRulePredicatesable) []string {
+	if p == nil {
+		return nil
+	}
+	var out []string
+	list := func(label string, values []string) {
+		if len(values) > 0 {
+			out = append(out, label+" "+strings.Join(values, ", "))
+		}
+	}
+	list("from", recipientAddresses(p.GetFromAddresses()))
+	list("sender contains", p.GetSenderContains())
+	list("sent to", recipientAddresses(p.GetSentToAddresses()))
+	list("recipient contains", p.GetRecipientContains())
+	list("subject contains", p.GetSubjectContains())
+	list("body contains", p.GetBodyContains())
+	list("subject or body contains", p.GetBodyOrSubjectContains())
+	list("category", p.GetCategories())
+	if v := p.GetHasAttachments(); v != nil {
+		if *v {
+			out = append(out, "has attachment")
+		} else {
+			out = append(out, "has no attachment")
+		}
+	}
+	if v := p.GetImportance(); v != nil {
+		out = append(out, "importance "+v.String())
+	}
+	if v := p.GetSentToMe(); v != nil && *v {
+		out = append(out, "sent to me")
+	}
+	if v := p.GetSentCcMe(); v != nil && *v {
+		out = append(out, "cc me")
+	}
+	if v := p.GetSentToOrCcMe(); v != nil && *v {
+		out = append(out, "sent to or cc me")
+	}
+	if v := p.GetSentOnlyToMe(); v != nil && *v {
+		out = append(out, "sent only to me")
+	}
+	if v := p.GetNotSentToMe(); v != nil && *v {
+		out = append(out, "not sent to me")
+	}
+	// The rest are rarely used; name them as Graph does.
+	out = append(out, unsupportedPredicates(p)...)
+	return out
+}
+
+// describeActions lists the actions of a in words, naming folders from folders.
+func describeActions(a models.MessageRuleActionsable, folders map[string]string) []string {
+	if a == nil {
+		return nil
+	}
+	var out []string
+	folder := func(id string) string {
+		if name := folders[id]; name != "" {
+			return name
+		}
+		return id
+	}
+	if v := a.GetMoveToFolder(); v != nil {
+		out = append(out, "move to "+folder(*v))
+	}
+	if v := a.GetCopyToFolder(); v != nil {
+		out = append(out, "copy to "+folder(*v))
+	}
+	if v := a.GetAssignCategories(); len(v) > 0 {
+		out = append(out, "categorize "+strings.Join(v, ", "))
+	}
+	if v := a.GetMarkAsRead(); v != nil && *v {
+		out = append(out, "mark as read")
+	}
+	if v := a.GetMarkImportance(); v != nil {
+		out = append(out, "mark importance "+v.String())
+	}
+	if v := a.GetForwardTo(); len(v) > 0 {
+		out = append(out, "forward to "+strings.Join(recipientAddresses(v), ", "))
+	}
+	if v := a.GetForwardAsAttachmentTo(); len(v) > 0 {
+		out = append(out, "forward as attachment to "+strings.Join(recipientAddresses(v), ", "))
+	}
+	if v := a.GetRedirectTo(); len(v) > 0 {
+		out = append(out, "redirect to "+strings.Join(recipientAddresses(v), ", "))
+	}
+	if v := a.GetDelete(); v != nil && *v {
+		out = append(out, "delete")
+	}
+	if v := a.GetPermanentDelete(); v != nil && *v {
+		out = append(out, "delete permanently")
+	}
+	if v := a.GetStopProcessingRules(); v != nil && *v {
+		out = append(out, "stop processing rules")
+	}
+	return out
+}
@@ -0,0 +1,334 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	jsonserialization "github.com/microsoft/kiota-serialization-json-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Rule simulation ----------
+
+// RuleTestResult is the JSON representation of a rule dry-run.
+type RuleTestResult struct {
+	Rule        string           `json:"rule"`
+	Folder      string           `json:"folder"`
+	Evaluated   int              `json:"evaluated"`
+	Matched     int              `json:"matched"`
+	Unsupported []string         `json:"unsupportedConditions,omitempty"`
+	Messages    []MessageSummary `json:"messages"`
+}
+
+// TestRule evaluates an inbox rule's conditions and exceptions locally against
+// the most recent count messages in folder and reports which would match.
+// Nothing is modified. rule is either a messageRule ID from the inbox rule
+// list or a path to a JSON file in Graph's messageRule format.
+//
+// Conditions Graph evaluates server-side against data not available here
+// (headers, size, sensitivity, message classes) are reported as unsupported and ignored.
+func TestRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, rule, folder string, count int32, jsonOutput bool) error {
+	if rule == "" {
+		return fmt.Errorf("--rule is required")
+	}
+
+	r, err := loadRule(ctx, client, rule)
+	if err != nil {
+		return err
+	}
+
+	me, err := mailbox.Of(client).Get(ctx, &users.UserItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.UserItemRequestBuilderGetQueryParameters{
+			Select: []string{"mail", "userPrincipalName"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading mailbox owner: %w", err)
+	}
+	myAddress := strings.ToLower(deref(me.GetMail(), deref(me.GetUserPrincipalName(), "")))
+
+	unsupported := unsupportedPredicates(r.GetConditions())
+	for _, u := range unsupportedPredicates(r.GetExceptions()) {
+		unsupported = append(unsupported, "exception "+u)
+	}
+
+	fields := append([]string{"toRecipients", "ccRecipients", "sender"}, summaryFields...)
+	needsBody := needsBodyText(r.GetConditions()) || needsBodyText(r.GetExceptions())
+	if needsBody {
+		fields = append(fields, "body")
+	}
+
+	folderID, err := resolveFolderID(ctx, client, folder)
+	if err != nil {
+		return err
+	}
+	config := &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+			Select:  fields,
+			Expand:  extendedExpand,
+			Top:     &count,
+			Orderby: []string{"receivedDateTime DESC"},
+		},
+	}
+	if needsBody {
+		config.Headers = abstractions.NewRequestHeaders()
+		config.Headers.Add("Prefer", `outlook.body-content-type="text"`)
+	}
+	result, err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages().Get(ctx, config)
+	if err != nil {
+		return fmt.Errorf("listing messages: %w", err)
+	}
+	messages := result.GetValue()
+
+	var matched []models.Messageable
+	for _, msg := range messages {
+		if !predicatesMatch(r.GetConditions(), msg, myAddress, true) {
+			continue
+		}
+		if r.GetExceptions() != nil && predicatesMatch(r.GetExceptions(), msg, myAddress, false) {
+			continue
+		}
+		matched = append(matched, msg)
+	}
+
+	ruleName := deref(r.GetDisplayName(), rule)
+
+	if jsonOutput {
+		out := RuleTestResult{
+			Rule:        ruleName,
+			Folder:      folder,
+			Evaluated:   len(messages),
+			Matched:     len(matched),
+			Unsupported: unsupported,
+			Messages:    make([]MessageSummary, 0, len(matched)),
+		}
+		for i, msg := range matched {
+			out.Messages = append(out.Messages, messageSummary(i+1, msg))
+		}
+		return printJSON(out)
+	}
+
+	for _, u := range unsupported {
+		slog.Warn("Condition cannot be simulated locally and was ignored", "condition", u)
+	}
+	fmt.Printf("\nRule %q would match %d of the last %d messages in %s\n\n", ruleName, len(matched), len(messages), folder)
+	if len(matched) == 0 {
+		return nil
+	}
+	fmt.Printf("%-3s  %-50s  %-30s  %s\n", "#", "Subject", "From", "Received")
+	fmt.Println(strings.Repeat("-", 110))
+	for i, msg := range matched {
+		fmt.Printf("%-3d  %-50s  %-30s  %s\n",
+			i+1,
+			truncate(deref(msg.GetSubject(), "(no subject)"), 50),
+			truncate(senderAddress(msg), 30),
+			formatMsgTime(msg.GetReceivedDateTime()),
+		)
+	}
+	return nil
+}
+
+// loadRule reads a messageRule from a local JSON file if rule names one,
+// otherwise fetches it by ID from the inbox rule list.
+func loadRule(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, rule string) (models.MessageRuleable, error) {
+	if data, err := os.ReadFile(rule); err == nil {
+		node, err := jsonserialization.NewJsonParseNode(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing rule file: %w", err)
+		}
+		v, err := node.GetObjectValue(models.CreateMessageRuleFromDiscriminatorValue)
+		if err != nil {
+			return nil, fmt.Errorf("parsing rule file: %w", err)
+		}
+		r, ok := v.(models.MessageRuleable)
+		if !ok || r == nil {
+			return nil, fmt.Errorf("rule file %q does not contain a messageRule", rule)
+		}
+		return r, nil
+	}
+
+	r, err := mailbox.Of(client).MailFolders().ByMailFolderId("inbox").MessageRules().ByMessageRuleId(rule).Get(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading rule %q (not a readable file, so treated as a rule ID): %w", rule, err)
+	}
+	return r, nil
+}
+
+// predicatesMatch reports whether msg satisfies p. For conditions every set
+// predicate must match (all=true); for exceptions any single set predicate
+// matching is enough (all=false). An empty predicate set matches when all is
+// true, since a rule without conditions applies to every message.
+func predicatesMatch(p models.MessageRulePredicatesable, msg models.Messageable, me string, all bool) bool {
+	if p == nil {
+		return all
+	}
+
+	var results []bool
+	check := func(ok bool) { results = append(results, ok) }
+
+	subject := strings.ToLower(deref(msg.GetSubject(), ""))
+	body := ""
+	if msg.GetBody() != nil {
+		body = strings.ToLower(deref(msg.GetBody().GetContent(), ""))
+	}
+	from := strings.ToLower(senderAddress(msg))
+	to := recipientAddresses(msg.GetToRecipients())
+	cc := recipientAddresses(msg.GetCcRecipients())
+
+	if v := p.GetSubjectContains(); len(v) > 0 {
+		check(containsAny(subject, v))
+	}
+	if v := p.GetBodyContains(); len(v) > 0 {
+		check(containsAny(body, v))
+	}
+	if v := p.GetBodyOrSubjectContains(); len(v) > 0 {
+		check(containsAny(subject, v) || containsAny(body, v))
+	}
+	if v := p.GetSenderContains(); len(v) > 0 {
+		check(containsAny(from, v))
+	}
+	if v := p.GetFromAddresses(); len(v) > 0 {
+		check(containsAddress(recipientAddresses(v), from))
+	}
+	if v := p.GetSentToAddresses(); len(v) > 0 {
+		matched := false
+		for _, addr := range recipientAddresses(v) {
+			if containsAddress(to, addr) || containsAddress(cc, addr) {
+				matched = true
+			}
+		}
+		check(matched)
+	}
+	if v := p.GetRecipientContains(); len(v) > 0 {
+		check(containsAny(strings.Join(append(to, cc...), " "), v))
+	}
+	if v := p.GetCategories(); len(v) > 0 {
+		matched := false
+		for _, c := range msg.GetCategories() {
+			for _, want := range v {
+				if strings.EqualFold(c, want) {
+					matched = true
+				}
+			}
+		}
+		check(matched)
+	}
+	if v := p.GetHasAttachments(); v != nil {
+		check(msg.GetHasAttachments() != nil && *msg.GetHasAttachments() == *v)
+	}
+	if v := p.GetImportance(); v != nil {
+		check(msg.GetImportance() != nil && *msg.GetImportance() == *v)
+	}
+	if v := p.GetSentToMe(); v != nil && *v {
+		check(containsAddress(to, me))
+	}
+	if v := p.GetSentCcMe(); v != nil && *v {
+		check(containsAddress(cc, me))
+	}
+	if v := p.GetSentToOrCcMe(); v != nil && *v {
+		check(containsAddress(to, me) || containsAddress(cc, me))
+	}
+	if v := p.GetSentOnlyToMe(); v != nil && *v {
+		check(len(to) == 1 && len(cc) == 0 && containsAddress(to, me))
+	}
+	if v := p.GetNotSentToMe(); v != nil && *v {
+		check(!containsAddress(to, me))
+	}
+
+	if len(results) == 0 {
+		return all
+	}
+	for _, ok := range results {
+		if all && !ok {
+			return false
+		}
+		if !all && ok {
+			return true
+		}
+	}
+	return all
+}
+
+// unsupportedPredicates lists the set predicates predicatesMatch cannot evaluate.
+func unsupportedPredicates(p models.MessageRulePredicatesable) []string {
+	if p == nil {
+		return nil
+	}
+	var names []string
+	if len(p.GetHeaderContains()) > 0 {
+		names = append(names, "headerContains")
+	}
+	if p.GetWithinSizeRange() != nil {
+		names = append(names, "withinSizeRange")
+	}
+	if p.GetSensitivity() != nil {
+		names = append(names, "sensitivity")
+	}
+	if p.GetMessageActionFlag() != nil {
+		names = append(names, "messageActionFlag")
+	}
+	flags := []struct {
+		name  string
+		value *bool
+	}{
+		{"isApprovalRequest", p.GetIsApprovalRequest()},
+		{"isAutomaticForward", p.GetIsAutomaticForward()},
+		{"isAutomaticReply", p.GetIsAutomaticReply()},
+		{"isEncrypted", p.GetIsEncrypted()},
+		{"isMeetingRequest", p.GetIsMeetingRequest()},
+		{"isMeetingResponse", p.GetIsMeetingResponse()},
+		{"isNonDeliveryReport", p.GetIsNonDeliveryReport()},
+		{"isPermissionControlled", p.GetIsPermissionControlled()},
+		{"isReadReceipt", p.GetIsReadReceipt()},
+		{"isSigned", p.GetIsSigned()},
+		{"isVoicemail", p.GetIsVoicemail()},
+	}
+	for _, f := range flags {
+		if f.value != nil && *f.value {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func needsBodyText(p models.MessageRulePredicatesable) bool {
+	return p != nil && (len(p.GetBodyContains()) > 0 || len(p.GetBodyOrSubjectContains()) > 0)
+}
+
+// recipientAddresses returns the lower-cased addresses of recipients.
+func recipientAddresses(recipients []models.Recipientable) []string {
+	addrs := make([]string, 0, len(recipients))
+	for _, r := range recipients {
+		if r.GetEmailAddress() != nil {
+			addrs = append(addrs, strings.ToLower(deref(r.GetEmailAddress().GetAddress(), "")))
+		}
+	}
+	return addrs
+}
+
+func containsAny(s string, needles []string) bool {
+	for _, n := range needles {
+		if n != "" && strings.Contains(s, strings.ToLower(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddress(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if addr != "" && strings.EqualFold(a, addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Search folders ----------
+
+// searchFoldersRoot is the well-known parent folder of all mailSearchFolders.
+const searchFoldersRoot = "searchfolders"
+
+// SearchFolderSummary is the JSON representation of a search (smart) folder.
+type SearchFolderSummary struct {
+	Index         int      `json:"index"`
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Filter        string   `json:"filter"`
+	SourceFolders []string `json:"sourceFolderIds"`
+	IncludeNested bool     `json:"includeNestedFolders"`
+	TotalItems    int32    `json:"totalItems"`
+	UnreadItems   int32    `json:"unreadItems"`
+}
+
+// CreateSearchFolder creates a persistent server-side saved search.
+// filter is an OData $filter expression evaluated against messages, e.g.
+// "from/emailAddress/address eq 'cfo@example.com'". sourceFolders is a
+// comma-separated list of folder names searched, including their subfolders.
+func CreateSearchFolder(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name, filter, sourceFolders string, jsonOutput bool) error {
+	if name == "" || filter == "" {
+		return fmt.Errorf("--name and --filter are required")
+	}
+
+	var sourceIDs []string
+	for _, f := range strings.Split(sourceFolders, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		id, err := resolveFolderID(ctx, client, f)
+		if err != nil {
+			return err
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	if len(sourceIDs) == 0 {
+		return fmt.Errorf("at least one source --folder is required")
+	}
+
+	folder := models.NewMailSearchFolder()
+	folder.SetDisplayName(&name)
+	folder.SetFilterQuery(&filter)
+	folder.SetSourceFolderIds(sourceIDs)
+	nested := true
+	folder.SetIncludeNestedFolders(&nested)
+
+	created, err := mailbox.Of(client).MailFolders().ByMailFolderId(searchFoldersRoot).ChildFolders().Post(ctx, folder, nil)
+	if err != nil {
+		return fmt.Errorf("creating search folder: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(searchFolderSummary(1, created))
+	}
+	slog.Info("Search folder created — list its messages with mail list --folder=<name>", "name", deref(created.GetDisplayName(), name))
+	return nil
+}
+
+// SearchFolders lists the user's search folders.
+func SearchFolders(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	folders, err := listSearchFolders(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		summaries := make([]SearchFolderSummary, 0, len(folders))
+		for i, f := range folders {
+			summaries = append(summaries, searchFolderSummary(i+1, f))
+		}
+		return printJSON(summaries)
+	}
+
+	if len(folders) == 0 {
+		fmt.Println("No search folders found.")
+		return nil
+	}
+
+	fmt.Printf("\n%-3s  %-30s  %8s  %8s  %s\n", "#", "Search folder", "Total", "Unread", "Filter")
+	fmt.Println(strings.Repeat("-", 110))
+	for i, f := range folders {
+		s := searchFolderSummary(i+1, f)
+		fmt.Printf("%-3d  %-30s  %8d  %8d  %s\n", s.Index, truncate(s.Name, 30), s.TotalItems, s.UnreadItems, truncate(s.Filter, 60))
+	}
+	return nil
+}
+
+// DeleteSearchFolder deletes a search folder identified by display name or ID.
+// Only the saved search is removed; the messages it matched are untouched.
+func DeleteSearchFolder(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, nameOrID string) error {
+	if nameOrID == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	folders, err := listSearchFolders(ctx, client)
+	if err != nil {
+		return err
+	}
+	folderID := ""
+	for _, f := range folders {
+		if deref(f.GetId(), "") == nameOrID || strings.EqualFold(deref(f.GetDisplayName(), ""), nameOrID) {
+			folderID = deref(f.GetId(), "")
+			break
+		}
+	}
+	if folderID == "" {
+		return fmt.Errorf("search folder %q not found — use `mail searchfolder-list` to list them", nameOrID)
+	}
+
+	if err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("deleting search folder: %w", err)
+	}
+
+	slog.Info("Search folder deleted", "name", nameOrID)
+	return nil
+}
+
+// listSearchFolders returns the children of the well-known searchfolders folder.
+func listSearchFolders(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) ([]models.MailFolderable, error) {
+	top := int32(100)
+	result, err := mailbox.Of(client).MailFolders().ByMailFolderId(searchFoldersRoot).ChildFolders().Get(ctx,
+		&users.ItemMailFoldersItemChildFoldersRequestBuilderGetRequestConfiguration{
+			QueryParameters: &users.ItemMailFoldersItemChildFoldersRequestBuilderGetQueryParameters{
+				Top: &top,
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("listing search folders: %w", err)
+	}
+	return result.GetValue(), nil
+}
+
+func searchFolderSummary(index int, f models.MailFolderable) SearchFolderSummary {
+	s := SearchFolderSummary{
+		Index: index,
+		ID:    deref(f.GetId(), ""),
+		Name:  deref(f.GetDisplayName(), ""),
+	}
+	if f.GetTotalItemCount() != nil {
+		s.TotalItems = *f.GetTotalItemCount()
+	}
+	if f.GetUnreadItemCount() != nil {
+		s.UnreadItems = *f.GetUnreadItemCount()
+	}
+	if sf, ok := f.(models.MailSearchFolderable); ok {
+		s.Filter = deref(sf.GetFilterQuery(), "")
+		s.SourceFolders = sf.GetSourceFolderIds()
+		s.IncludeNested = sf.GetIncludeNestedFolders() != nil && *sf.GetIncludeNestedFolders()
+	}
+	return s
+}
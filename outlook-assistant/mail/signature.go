@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ---------- Signature (stored in home directory) ----------
+
+// Signature is the text appended to outgoing mail, saved with `signature set`.
+type Signature struct {
+	Body      string `json:"body"`
+	Format    string `json:"format"` // text, md, or html
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+func signaturePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".outlook-assistant-signature.json")
+}
+
+// LoadSignature returns the saved signature, or one with an empty Body if
+// none is saved.
+func LoadSignature() (Signature, error) {
+	var sig Signature
+	data, err := os.ReadFile(signaturePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return sig, nil
+	}
+	if err != nil {
+		return sig, fmt.Errorf("reading signature: %w", err)
+	}
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return sig, fmt.Errorf("parsing signature %s: %w", signaturePath(), err)
+	}
+	return sig, nil
+}
+
+// SetSignature saves body as the signature, replacing any saved one. Without
+// a format, a body that starts with a tag is taken as HTML, and any other as
+// Markdown.
+func SetSignature(body, format string) error {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return fmt.Errorf("the signature is empty — use `signature clear` to remove it")
+	}
+	switch format {
+	case "":
+		format = "md"
+		if strings.HasPrefix(body, "<") {
+			format = "html"
+		}
+	case "text", "md", "html":
+	case "markdown":
+		format = "md"
+	default:
+		return fmt.Errorf("unknown --format %q — valid formats: text, md, html", format)
+	}
+
+	sig := Signature{Body: body, Format: format, UpdatedAt: time.Now().Format(time.RFC3339)}
+	data, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(signaturePath(), data, 0600); err != nil {
+		return fmt.Errorf("writing signature: %w", err)
+	}
+	slog.Info("Saved signature", "format", format)
+	return nil
+}
+
+// ClearSignature removes the saved signature.
+func ClearSignature() error {
+	err := os.Remove(signaturePath())
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("No signature is saved")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("removing signature: %w", err)
+	}
+	slog.Info("Removed signature")
+	return nil
+}
+
+// ShowSignature prints the saved signature.
+func ShowSignature(jsonOutput bool) error {
+	sig, err := LoadSignature()
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(sig)
+	}
+	if sig.Body == "" {
+		fmt.Println("No signature saved — save one with `signature set --body=<text>`")
+		return nil
+	}
+	fmt.Printf("Format: %s   Updated: %s\n\n%s\n", sig.Format, sig.UpdatedAt, sig.Body)
+	return nil
+}
+
+// AppendSignature appends sig to body. A signature in the body's format is
+// joined after a blank line, or a line break in HTML; otherwise both are
+// rendered to HTML, and the result is HTML.
+func AppendSignature(body, format string, sig Signature) (string, string) {
+	if body == "" || sig.Body == "" {
+		return body, format
+	}
+	bodyFormat, sigFormat := ParseBodyFormat(format), ParseBodyFormat(sig.Format)
+	switch {
+	case bodyFormat != sigFormat:
+		return RenderBodyInner(body, bodyFormat) + `<div class="signature">` + RenderBodyInner(sig.Body, sigFormat) + "</div>", "html"
+	case bodyFormat == FormatHTML:
+		return body + "<br><br>" + sig.Body, format
+	default:
+		return body + "\n\n" + sig.Body, format
+	}
+}
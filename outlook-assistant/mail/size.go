@@ -0,0 +1,214 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Message size ----------
+
+const (
+	// sizeProperty is the MAPI PR_MESSAGE_SIZE property. Graph v1.0 has no
+	// size field on messages, so it is fetched as an extended property.
+	sizeProperty = "Integer 0x0E08"
+	// folderSizeProperty is PR_MESSAGE_SIZE_EXTENDED, the total size of the
+	// items in a folder.
+	folderSizeProperty = "Long 0x0E08"
+	// defaultLargestMin is the smallest message `mail largest` considers
+	// unless --min-size says otherwise; it keeps the scan short.
+	defaultLargestMin = 1024 * 1024
+)
+
+// FolderFootprint is the JSON representation of a folder's size and its
+// largest messages, as produced by `mail largest`.
+type FolderFootprint struct {
+	Folder     string           `json:"folder"`
+	TotalItems int32            `json:"totalItems"`
+	TotalSize  int64            `json:"totalSize,omitempty"` // bytes, when Exchange reports it
+	MinSize    int64            `json:"minSize"`
+	Matched    int              `json:"matched"`
+	Messages   []MessageSummary `json:"messages"`
+}
+
+var sizePattern = regexp.MustCompile(`(?i)^\s*(\d+(?:\.\d+)?)\s*(b|k|kb|m|mb|g|gb)?\s*$`)
+
+// ParseSize parses a size such as 500KB, 5MB, 1.5GB, or a plain number of
+// bytes. Units are binary (1 KB = 1024 bytes), as in Outlook.
+func ParseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q — use e.g. 500KB, 5MB, or 1GB", s)
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	switch strings.TrimSuffix(strings.ToLower(m[2]), "b") {
+	case "k":
+		n *= 1 << 10
+	case "m":
+		n *= 1 << 20
+	case "g":
+		n *= 1 << 30
+	}
+	if n >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q — too large", s)
+	}
+	return int64(n), nil
+}
+
+// sizeFilter is the $filter clause comparing a message's size in bytes with
+// n: op ge matches messages of at least n bytes, le of at most n.
+func sizeFilter(op string, n int64) string {
+	return fmt.Sprintf("singleValueExtendedProperties/any(ep: ep/id eq '%s' and cast(ep/value, Edm.Int32) %s %d)", sizeProperty, op, n)
+}
+
+// sizeOf returns a message's size in bytes from the expanded size property,
+// or 0 when it was not fetched.
+func sizeOf(msg models.Messageable) int64 {
+	for _, p := range msg.GetSingleValueExtendedProperties() {
+		if sameProperty(deref(p.GetId(), ""), sizeProperty) {
+			n, _ := strconv.ParseInt(deref(p.GetValue(), ""), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// sameProperty reports whether two extended property IDs name the same
+// property. Graph echoes tags in its own spelling ("Integer 0xe08" for
+// "Integer 0x0E08"), so the type is compared case-insensitively and the tag
+// numerically.
+func sameProperty(a, b string) bool {
+	typeA, tagA, okA := strings.Cut(strings.TrimSpace(a), " ")
+	typeB, tagB, okB := strings.Cut(strings.TrimSpace(b), " ")
+	if !okA || !okB || !strings.EqualFold(typeA, typeB) {
+		return strings.EqualFold(a, b)
+	}
+	nA, errA := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(tagA), "0x"), 16, 32)
+	nB, errB := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(tagB), "0x"), 16, 32)
+	return errA == nil && errB == nil && nA == nB
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	case n > 0:
+		return fmt.Sprintf("%d B", n)
+	}
+	return ""
+}
+
+// Largest prints the count largest messages in a folder, biggest first, with
+// the folder's total size, and caches their indexes for --ref. Only messages
+// of at least minSize bytes (default: 1 MB) are scanned, since Graph cannot
+// sort by size and every candidate has to be fetched.
+func Largest(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderName string, count int, minSize int64, jsonOutput bool) error {
+	if folderName == "" {
+		folderName = "inbox"
+	}
+	if minSize <= 0 {
+		minSize = defaultLargestMin
+	}
+	folderID, err := resolveFolderID(ctx, client, folderName)
+	if err != nil {
+		return err
+	}
+
+	footprint := FolderFootprint{Folder: folderName, MinSize: minSize}
+	folder, err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Get(ctx, &users.ItemMailFoldersMailFolderItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersMailFolderItemRequestBuilderGetQueryParameters{
+			Select: []string{"displayName", "totalItemCount"},
+			Expand: []string{"singleValueExtendedProperties($filter=id eq '" + folderSizeProperty + "')"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reading folder %q: %w", folderName, err)
+	}
+	footprint.Folder = deref(folder.GetDisplayName(), folderName)
+	if folder.GetTotalItemCount() != nil {
+		footprint.TotalItems = *folder.GetTotalItemCount()
+	}
+	for _, p := range folder.GetSingleValueExtendedProperties() {
+		if sameProperty(deref(p.GetId(), ""), folderSizeProperty) {
+			footprint.TotalSize, _ = strconv.ParseInt(deref(p.GetValue(), ""), 10, 64)
+		}
+	}
+
+	filter := sizeFilter("ge", minSize)
+	top := int32(100)
+	config := &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+			Select: summaryFields,
+			Expand: extendedExpand,
+			Filter: &filter,
+			Top:    &top,
+		},
+	}
+	var messages []models.Messageable
+	builder := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages()
+	for len(messages) < maxScanned {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return fmt.Errorf("listing messages: %w", err)
+		}
+		messages = append(messages, result.GetValue()...)
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+	if len(messages) >= maxScanned {
+		slog.Warn("Scan stopped early — raise --min-size to narrow it", "scanned", len(messages))
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool { return sizeOf(messages[i]) > sizeOf(messages[j]) })
+	footprint.Matched = len(messages)
+	if count > 0 && len(messages) > count {
+		messages = messages[:count]
+	}
+	ids := make([]string, 0, len(messages))
+	footprint.Messages = make([]MessageSummary, 0, len(messages))
+	for i, msg := range messages {
+		ids = append(ids, deref(msg.GetId(), ""))
+		footprint.Messages = append(footprint.Messages, messageSummary(i+1, msg))
+	}
+	saveIDCache(ids)
+
+	if jsonOutput {
+		return printJSON(footprint)
+	}
+
+	fmt.Printf("\n%s: %d items", footprint.Folder, footprint.TotalItems)
+	if footprint.TotalSize > 0 {
+		fmt.Printf(", %s", formatSize(footprint.TotalSize))
+	}
+	fmt.Printf(" — %d messages of %s or more\n", footprint.Matched, formatSize(minSize))
+	if len(messages) == 0 {
+		return nil
+	}
+	fmt.Printf("%-3s  %8s  %-50s  %-30s  %s\n", "#", "Size", "Subject", "From", "Received")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, s := range footprint.Messages {
+		fmt.Printf("%-3d  %8s  %-50s  %-30s  %s\n",
+			s.Index, formatSize(s.Size), truncate(s.Subject, 50), truncate(s.From, 30), s.ReceivedDateTime)
+	}
+	return nil
+}
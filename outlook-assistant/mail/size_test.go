@@ -0,0 +1,37 @@
+package mail
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"0", 0, false},
+		{"10B", 10, false},
+		{"500KB", 500 << 10, false},
+		{"10k", 10 << 10, false},
+		{"5MB", 5 << 20, false},
+		{"  2 mb ", 2 << 20, false},
+		{"1g", 1 << 30, false},
+		{"1.5GB", 3 << 29, false},
+		{"1.5", 1, false},
+		{"0.0001KB", 0, false},
+		{"", 0, true},
+		{"KB", 0, true},
+		{"-1", 0, true},
+		{"1TB", 0, true},
+		{"1e3", 0, true},
+		{"5 M B", 0, true},
+		{"1,000", 0, true},
+		{"99999999999999999999GB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
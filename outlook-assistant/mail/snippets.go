@@ -0,0 +1,324 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Snippets (stored in home directory) ----------
+
+// Snippet is a named canned response. Body is Markdown and may contain
+// {{placeholders}}, filled in when the snippet is used.
+type Snippet struct {
+	Name         string   `json:"name"`
+	Body         string   `json:"body"`
+	Placeholders []string `json:"placeholders,omitempty"`
+	UpdatedAt    string   `json:"updatedAt"`
+}
+
+// placeholderPattern matches {{name}}, allowing spaces inside the braces.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// messagePlaceholders are filled from the message being replied to.
+var messagePlaceholders = map[string]bool{
+	"sender": true, "firstName": true, "senderEmail": true, "subject": true,
+}
+
+func snippetsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".outlook-assistant-snippets.json")
+}
+
+func loadSnippets() ([]Snippet, error) {
+	data, err := os.ReadFile(snippetsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading snippets: %w", err)
+	}
+	var snippets []Snippet
+	if err := json.Unmarshal(data, &snippets); err != nil {
+		return nil, fmt.Errorf("parsing snippets %s: %w", snippetsPath(), err)
+	}
+	return snippets, nil
+}
+
+func saveSnippets(snippets []Snippet) error {
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].Name < snippets[j].Name })
+	data, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(snippetsPath(), data, 0600); err != nil {
+		return fmt.Errorf("writing snippets: %w", err)
+	}
+	return nil
+}
+
+func findSnippet(name string) (Snippet, error) {
+	snippets, err := loadSnippets()
+	if err != nil {
+		return Snippet{}, err
+	}
+	for _, s := range snippets {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Snippet{}, fmt.Errorf("no snippet named %q — run `snippets list` to see them", name)
+}
+
+// AddSnippet saves a snippet, replacing any existing one with the same name.
+func AddSnippet(name, body string) error {
+	if strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("snippet names cannot contain spaces, got %q", name)
+	}
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("snippet %q has an empty body", name)
+	}
+	snippets, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+
+	s := Snippet{
+		Name:         name,
+		Body:         body,
+		Placeholders: placeholdersOf(body),
+		UpdatedAt:    time.Now().Format(time.RFC3339),
+	}
+	verb := "Added"
+	kept := snippets[:0]
+	for _, existing := range snippets {
+		if existing.Name == name {
+			verb = "Updated"
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	if err := saveSnippets(append(kept, s)); err != nil {
+		return err
+	}
+	attrs := []any{"name", name}
+	if len(s.Placeholders) > 0 {
+		attrs = append(attrs, "placeholders", strings.Join(s.Placeholders, ", "))
+	}
+	slog.Info(verb+" snippet", attrs...)
+	return nil
+}
+
+// RemoveSnippet deletes a snippet by name.
+func RemoveSnippet(name string) error {
+	snippets, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+	kept := snippets[:0]
+	for _, s := range snippets {
+		if s.Name != name {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == len(snippets) {
+		return fmt.Errorf("no snippet named %q", name)
+	}
+	if err := saveSnippets(kept); err != nil {
+		return err
+	}
+	slog.Info("Removed snippet", "name", name)
+	return nil
+}
+
+// Snippets lists the saved snippets.
+func Snippets(jsonOutput bool) error {
+	snippets, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if snippets == nil {
+			snippets = []Snippet{}
+		}
+		return printJSON(snippets)
+	}
+
+	if len(snippets) == 0 {
+		fmt.Println("No snippets yet — add one with snippets add --name=<name> --body=<markdown>")
+		return nil
+	}
+	fmt.Printf("\n%-20s  %-30s  %s\n", "Name", "Placeholders", "Text")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, s := range snippets {
+		fmt.Printf("%-20s  %-30s  %s\n",
+			truncate(s.Name, 20), truncate(strings.Join(s.Placeholders, ", "), 30), truncate(collapseSpaces(s.Body), 55))
+	}
+	return nil
+}
+
+// UseSnippet prints a snippet with its placeholders filled in, without
+// sending anything.
+func UseSnippet(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name, vars, ref string, jsonOutput bool) error {
+	text, err := ExpandSnippet(ctx, client, name, vars, ref)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(map[string]string{"name": name, "body": text})
+	}
+	fmt.Println(text)
+	return nil
+}
+
+// ExpandSnippet returns the named snippet with its placeholders filled in.
+//
+// Values come from vars ("key=value;key=value"), then from the built-ins
+// {{today}} (YYYY-MM-DD) and {{weekday}}, and, when ref names a message, from
+// its {{sender}}, {{firstName}}, {{senderEmail}} and {{subject}}. The message
+// is only fetched if one of those is needed. A placeholder left without a
+// value is an error, so a half-filled snippet is never sent.
+func ExpandSnippet(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name, vars, ref string) (string, error) {
+	s, err := findSnippet(name)
+	if err != nil {
+		return "", err
+	}
+	values, err := parseVars(vars)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	builtins := map[string]string{
+		"today":   now.Format("2006-01-02"),
+		"weekday": now.Weekday().String(),
+	}
+	fromMessage := false
+	for _, p := range placeholdersOf(s.Body) {
+		if _, ok := values[p]; ok {
+			continue
+		}
+		if v, ok := builtins[p]; ok {
+			values[p] = v
+		} else if messagePlaceholders[p] && ref != "" {
+			fromMessage = true
+		}
+	}
+	if fromMessage {
+		if err := messageValues(ctx, client, ref, values); err != nil {
+			return "", err
+		}
+	}
+
+	var missing []string
+	text := placeholderPattern.ReplaceAllStringFunc(s.Body, func(m string) string {
+		key := placeholderPattern.FindStringSubmatch(m)[1]
+		if v, ok := values[key]; ok {
+			return v
+		}
+		if !slices.Contains(missing, key) {
+			missing = append(missing, key)
+		}
+		return m
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("snippet %q needs a value for %s — pass --vars=\"%s=...\"",
+			name, strings.Join(missing, ", "), missing[0])
+	}
+	return text, nil
+}
+
+// messageValues adds the placeholders describing the message ref to values,
+// without overriding ones already set.
+func messageValues(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, values map[string]string) error {
+	messageID, err := resolveMessageID(ref)
+	if err != nil {
+		return err
+	}
+	msg, err := mailbox.Of(client).Messages().ByMessageId(messageID).Get(ctx, &users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: []string{"from", "subject"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("fetching message for snippet: %w", err)
+	}
+
+	email := senderAddress(msg)
+	sender := email
+	if msg.GetFrom() != nil && msg.GetFrom().GetEmailAddress() != nil {
+		sender = deref(msg.GetFrom().GetEmailAddress().GetName(), email)
+	}
+	set := func(key, value string) {
+		if _, ok := values[key]; !ok {
+			values[key] = value
+		}
+	}
+	set("sender", sender)
+	set("firstName", firstName(sender))
+	set("senderEmail", email)
+	set("subject", deref(msg.GetSubject(), ""))
+	return nil
+}
+
+// firstName guesses a first name from a display name: "Ada Lovelace" and
+// "Lovelace, Ada" both give "Ada", and an address gives its local part.
+func firstName(name string) string {
+	if _, first, ok := strings.Cut(name, ","); ok && strings.TrimSpace(first) != "" {
+		name = first
+	}
+	if local, _, ok := strings.Cut(name, "@"); ok {
+		name = local
+	}
+	if fields := strings.Fields(name); len(fields) > 0 {
+		return fields[0]
+	}
+	return name
+}
+
+// parseVars parses "key=value;key=value".
+func parseVars(s string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --vars entry %q — use key=value;key=value", pair)
+		}
+		values[key] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// placeholdersOf returns the distinct placeholder names in body, in order of
+// first use.
+func placeholdersOf(body string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
@@ -0,0 +1,243 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/progress"
+)
+
+// ---------- Sweep ----------
+
+// SweepOptions controls Sweep. The list filters choose the messages, within
+// ListOptions.Folder; Out and MarkRead do not apply.
+type SweepOptions struct {
+	ListOptions
+	Apply    string // archive, move, delete, markread, or categorize
+	ToFolder string // move: the folder to move the messages to
+	Set      string // categorize: comma-separated categories
+	DryRun   bool   // list the matching messages without changing them
+	Confirm  int    // act only when at most this many messages match
+}
+
+// SweepSummary is the JSON representation of a `mail sweep`.
+type SweepSummary struct {
+	Apply    string            `json:"apply"`
+	Folder   string            `json:"folder"`
+	Filters  map[string]string `json:"filters"`
+	DryRun   bool              `json:"dryRun"`
+	Matched  int               `json:"matched"`
+	Changed  int               `json:"changed"`            // 0 on a dry run
+	Messages []MessageSummary  `json:"messages,omitempty"` // on a dry run, the messages that would change
+}
+
+// sweepStep is what Sweep does to each message.
+type sweepStep struct {
+	verb  string // "archive", for the dry run
+	done  string // "archived", for the log and errors
+	build func(id string) (*abstractions.RequestInformation, error)
+}
+
+// Sweep applies one action to every message in a folder that matches the list
+// filters, following every page of the listing before changing any, so moving
+// messages does not shift the pages still to be read. The changes go in
+// $batch requests, as for --ref=1,3,5-9.
+//
+// A sweep cannot be undone, so it needs at least one filter, and without
+// --dry-run, --confirm with the number of messages the caller expects: a
+// sweep that matches more, because a filter was wrong or mail arrived since
+// the dry run, changes nothing.
+func Sweep(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, opts SweepOptions, jsonOutput bool) error {
+	var err error
+	if opts.Since, opts.Before, err = ageBounds(opts.Since, opts.Before, opts.NewerThan, opts.OlderThan); err != nil {
+		return err
+	}
+	filters := listFilters(opts.ListOptions)
+	if len(filters) == 0 {
+		return fmt.Errorf("mail sweep needs at least one filter, such as --from, --domain, --subject, --since, --before, or --unread")
+	}
+	if !opts.DryRun && opts.Confirm < 1 {
+		return fmt.Errorf("--confirm=<count> is required: run with --dry-run to see how many messages match, then confirm that count")
+	}
+	step, err := sweepAction(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+	if err := opts.resolveMe(ctx, client); err != nil {
+		return err
+	}
+	filter, err := listFilter(opts.ListOptions)
+	if err != nil {
+		return err
+	}
+	folder := opts.Folder
+	if folder == "" {
+		folder = "inbox"
+	}
+	folderID, err := resolveFolderID(ctx, client, folder)
+	if err != nil {
+		return err
+	}
+
+	messages, err := sweepList(ctx, client, folderID, filter)
+	if err != nil {
+		return err
+	}
+	messages = subjectContains(messages, opts.Subject)
+	summary := SweepSummary{Apply: opts.Apply, Folder: folder, Filters: filters, DryRun: opts.DryRun, Matched: len(messages)}
+
+	if opts.DryRun {
+		ids := make([]string, 0, len(messages))
+		for i, msg := range messages {
+			ids = append(ids, deref(msg.GetId(), ""))
+			summary.Messages = append(summary.Messages, messageSummary(i+1, msg))
+		}
+		saveIDCache(ids)
+		if jsonOutput {
+			return printJSON(summary)
+		}
+		printSweep(summary, step.verb)
+		return nil
+	}
+
+	if len(messages) > opts.Confirm {
+		return fmt.Errorf("%d messages match, more than --confirm=%d — nothing was changed; check them with --dry-run", len(messages), opts.Confirm)
+	}
+	if len(messages) > 0 {
+		ids := make([]string, 0, len(messages))
+		for _, msg := range messages {
+			ids = append(ids, deref(msg.GetId(), ""))
+		}
+		if err := bulk(ctx, client, ids, ids, step.done, step.build); err != nil {
+			return fmt.Errorf("sweeping messages: %w", err)
+		}
+		summary.Changed = len(ids)
+	}
+	slog.Info("Messages "+step.done, "count", summary.Changed, "folder", folder)
+	if jsonOutput {
+		return printJSON(summary)
+	}
+	return nil
+}
+
+// sweepAction checks the options of opts.Apply and returns the request it
+// makes for each message.
+func sweepAction(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, opts SweepOptions) (sweepStep, error) {
+	move := func(folderName string) (sweepStep, error) {
+		folderID, err := resolveFolderID(ctx, client, folderName)
+		if err != nil {
+			return sweepStep{}, err
+		}
+		body := users.NewItemMessagesItemMovePostRequestBody()
+		body.SetDestinationId(&folderID)
+		return sweepStep{build: func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).Move().ToPostRequestInformation(ctx, body, nil)
+		}}, nil
+	}
+	patch := func(p models.Messageable) func(id string) (*abstractions.RequestInformation, error) {
+		return func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).ToPatchRequestInformation(ctx, p, nil)
+		}
+	}
+
+	switch opts.Apply {
+	case "archive":
+		step, err := move("archive")
+		step.verb, step.done = "archive", "archived"
+		return step, err
+	case "move":
+		if opts.ToFolder == "" {
+			return sweepStep{}, fmt.Errorf("--to-folder is required with --apply=move")
+		}
+		step, err := move(opts.ToFolder)
+		step.verb, step.done = "move to "+strconv.Quote(opts.ToFolder), "moved to "+strconv.Quote(opts.ToFolder)
+		return step, err
+	case "delete":
+		return sweepStep{verb: "delete", done: "deleted", build: func(id string) (*abstractions.RequestInformation, error) {
+			return mailbox.Of(client).Messages().ByMessageId(id).ToDeleteRequestInformation(ctx, nil)
+		}}, nil
+	case "markread":
+		isRead := true
+		p := models.NewMessage()
+		p.SetIsRead(&isRead)
+		return sweepStep{verb: "mark as read", done: "marked as read", build: patch(p)}, nil
+	case "categorize":
+		var cats []string
+		for _, c := range strings.Split(opts.Set, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				cats = append(cats, c)
+			}
+		}
+		if len(cats) == 0 {
+			return sweepStep{}, fmt.Errorf("--set=<cat1,cat2,...> is required with --apply=categorize")
+		}
+		p := models.NewMessage()
+		p.SetCategories(cats)
+		return sweepStep{verb: "categorize", done: "categorized", build: patch(p)}, nil
+	case "":
+		return sweepStep{}, fmt.Errorf("--apply is required for mail sweep — archive, move, delete, markread, or categorize")
+	}
+	return sweepStep{}, fmt.Errorf("unknown --apply %q — valid actions: archive, move, delete, markread, categorize", opts.Apply)
+}
+
+// sweepList returns every message in a folder that matches filter. The
+// listing is not sorted: Graph rejects some filters combined with an order,
+// and the order does not change what is swept.
+func sweepList(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderID string, filter *string) ([]models.Messageable, error) {
+	top := exportPageSize
+	config := &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+			Select: summaryFields,
+			Expand: extendedExpand,
+			Filter: filter,
+			Top:    &top,
+		},
+	}
+	var messages []models.Messageable
+	report := progress.New("Listing messages")
+	builder := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages()
+	for page := 1; ; page++ {
+		result, err := builder.Get(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("listing messages (page %d): %w", page, err)
+		}
+		messages = append(messages, result.GetValue()...)
+		report.Report("listed", len(messages))
+		next := result.GetOdataNextLink()
+		if next == nil || *next == "" {
+			break
+		}
+		builder = builder.WithUrl(*next)
+		config = nil
+	}
+	return messages, nil
+}
+
+// printSweep shows the messages a dry run matched, and how to sweep them.
+func printSweep(summary SweepSummary, verb string) {
+	if summary.Matched == 0 {
+		fmt.Printf("No messages in %s match.\n", summary.Folder)
+		return
+	}
+	fmt.Printf("\n%d messages in %s match; mail sweep would %s them:\n\n", summary.Matched, summary.Folder, verb)
+	fmt.Printf("%-4s  %-16s  %-30s  %s\n", "#", "Received", "From", "Subject")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, s := range summary.Messages {
+		subject := s.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		fmt.Printf("%-4d  %-16s  %-30s  %s\n", s.Index, s.ReceivedDateTime, truncate(s.From, 30), truncate(subject, 56))
+	}
+	fmt.Printf("\nRun it again with --confirm=%d in place of --dry-run to %s them.\n", summary.Matched, verb)
+}
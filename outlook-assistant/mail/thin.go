@@ -0,0 +1,348 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/charset"
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Thin client ----------
+
+// The hot paths — list, read, and send — can skip the SDK's model layer. The
+// request is built by hand and sent through the client's adapter, so it still
+// gets authentication, retries, --cache, and --stats, but the response JSON is
+// decoded straight into the small structs below instead of a backing-store
+// model for every property. thinMessage provides the same messageFields as
+// sdkMessage, so summaryOf and detailOf print identical output in both modes.
+//
+// OUTLOOK_ASSISTANT_CLIENT=thin selects the thin client; the SDK is the
+// default.
+
+// ClientModeEnv names the environment variable that selects the client for
+// list, read, and send: "thin" or "sdk".
+const ClientModeEnv = "OUTLOOK_ASSISTANT_CLIENT"
+
+// thinMode reports whether list, read, and send use the thin client.
+func thinMode() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv(ClientModeEnv)), "thin")
+}
+
+type thinEmailAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+type thinRecipient struct {
+	EmailAddress thinEmailAddress `json:"emailAddress"`
+}
+
+type thinBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type thinFlag struct {
+	FlagStatus  string `json:"flagStatus"`
+	DueDateTime *struct {
+		DateTime string `json:"dateTime"`
+	} `json:"dueDateTime"`
+}
+
+type thinProperty struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// thinMessage holds the message fields that list and read select.
+type thinMessage struct {
+	ID                            string          `json:"id"`
+	Subject                       *string         `json:"subject"`
+	From                          *thinRecipient  `json:"from"`
+	ToRecipients                  []thinRecipient `json:"toRecipients"`
+	ReceivedDateTime              *time.Time      `json:"receivedDateTime"`
+	IsRead                        bool            `json:"isRead"`
+	BodyPreview                   string          `json:"bodyPreview"`
+	Categories                    []string        `json:"categories"`
+	Importance                    string          `json:"importance"`
+	InferenceClassification       string          `json:"inferenceClassification"`
+	Flag                          *thinFlag       `json:"flag"`
+	HasAttachments                bool            `json:"hasAttachments"`
+	Body                          *thinBody       `json:"body"`
+	UniqueBody                    *thinBody       `json:"uniqueBody"`
+	ConversationID                string          `json:"conversationId"`
+	ConversationIndex             string          `json:"conversationIndex"` // base64
+	InternetMessageID             string          `json:"internetMessageId"`
+	SingleValueExtendedProperties []thinProperty  `json:"singleValueExtendedProperties"`
+}
+
+type thinMessagePage struct {
+	Value    []thinMessage `json:"value"`
+	NextLink string        `json:"@odata.nextLink"`
+}
+
+// thinRequest sends method to path under the selected mailbox and decodes the
+// JSON response into out; with out nil, no response body is expected.
+func thinRequest(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, method abstractions.HttpMethod, path string, query url.Values, headers map[string]string, body interface{}, out interface{}) error {
+	adapter := client.GetAdapter()
+	u, err := url.Parse(adapter.GetBaseUrl() + "/" + mailbox.Path() + path)
+	if err != nil {
+		return err
+	}
+	// OData expects %20, not +, for spaces in $filter and $orderby.
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	info := abstractions.NewRequestInformation()
+	info.Method = method
+	info.SetUri(*u)
+	info.Headers.TryAdd("Accept", "application/json")
+	for name, value := range headers {
+		info.Headers.TryAdd(name, value)
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		info.SetStreamContentAndContentType(data, "application/json")
+	}
+
+	errorMapping := abstractions.ErrorMappings{
+		"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue,
+	}
+	if out == nil {
+		return adapter.SendNoContent(ctx, info, errorMapping)
+	}
+	raw, err := adapter.SendPrimitive(ctx, info, "[]byte", errorMapping)
+	if err != nil {
+		return err
+	}
+	data, _ := raw.([]byte)
+	return json.Unmarshal(data, out)
+}
+
+// thinList is List for the thin client; params is the query List built.
+func thinList(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderID string, params *users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters, page int, opts ListOptions, jsonOutput bool) error {
+	query := url.Values{}
+	query.Set("$select", strings.Join(params.Select, ","))
+	query.Set("$expand", strings.Join(params.Expand, ","))
+	query.Set("$orderby", strings.Join(params.Orderby, ","))
+	query.Set("$top", strconv.Itoa(int(*params.Top)))
+	query.Set("$skip", strconv.Itoa(int(*params.Skip)))
+	if params.Filter != nil {
+		query.Set("$filter", *params.Filter)
+	}
+
+	var result thinMessagePage
+	if err := thinRequest(ctx, client, abstractions.GET, "/mailFolders/"+url.PathEscape(folderID)+"/messages", query, nil, nil, &result); err != nil {
+		return fmt.Errorf("listing messages: %w", err)
+	}
+
+	lower := strings.ToLower(opts.Subject)
+	summaries := make([]MessageSummary, 0, len(result.Value))
+	for _, m := range result.Value {
+		if lower != "" && !strings.Contains(strings.ToLower(deref(m.Subject, "")), lower) {
+			continue
+		}
+		summaries = append(summaries, summaryOf(len(summaries)+1, m))
+	}
+	return showList(ctx, client, summaries, page, result.NextLink != "", opts, jsonOutput)
+}
+
+// thinRead is Read for the thin client; fields and expand are those Read
+// built for its request.
+func thinRead(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, messageID string, fields, expand []string, opts ReadOptions, jsonOutput bool) error {
+	query := url.Values{}
+	query.Set("$select", strings.Join(fields, ","))
+	query.Set("$expand", strings.Join(expand, ","))
+	headers := map[string]string{}
+	if opts.textBody() {
+		headers["Prefer"] = `outlook.body-content-type="text"`
+	}
+
+	var m thinMessage
+	if err := thinRequest(ctx, client, abstractions.GET, "/messages/"+url.PathEscape(messageID), query, headers, nil, &m); err != nil {
+		return fmt.Errorf("reading message: %w", err)
+	}
+
+	var images []AttachmentInfo
+	if opts.SaveImages != "" {
+		var err error
+		if images, err = saveInlineImages(ctx, client, messageID, opts.SaveImages, map[string]bool{}); err != nil {
+			return err
+		}
+		opts.images = imagePaths(images)
+	}
+	detail := detailOf(m, opts)
+	detail.Images = images
+	if opts.SaveDir != "" {
+		var err error
+		if detail.Attachments, err = messageAttachments(ctx, client, messageID, opts.SaveDir); err != nil {
+			return err
+		}
+	}
+	if jsonOutput {
+		return printJSON(detail)
+	}
+	h := m.header()
+	h.Attachments = detail.Attachments
+	printMessage(h, detail.Body)
+	return nil
+}
+
+// thinSend is Send for the thin client; htmlBody is already rendered.
+func thinSend(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, to, cc, bcc, subject, htmlBody string, marking Marking) error {
+	type sendMessage struct {
+		Subject                       string          `json:"subject"`
+		Body                          thinBody        `json:"body"`
+		ToRecipients                  []thinRecipient `json:"toRecipients"`
+		CcRecipients                  []thinRecipient `json:"ccRecipients,omitempty"`
+		BccRecipients                 []thinRecipient `json:"bccRecipients,omitempty"`
+		Importance                    string          `json:"importance,omitempty"`
+		SingleValueExtendedProperties []thinProperty  `json:"singleValueExtendedProperties,omitempty"`
+	}
+	type sendMail struct {
+		Message         sendMessage `json:"message"`
+		SaveToSentItems bool        `json:"saveToSentItems"`
+	}
+	request := sendMail{
+		Message: sendMessage{
+			Subject:       subject,
+			Body:          thinBody{ContentType: "HTML", Content: htmlBody},
+			ToRecipients:  thinRecipients(to),
+			CcRecipients:  thinRecipients(cc),
+			BccRecipients: thinRecipients(bcc),
+		},
+		SaveToSentItems: true,
+	}
+	if v, _ := parseImportance(marking.Importance); v != nil {
+		request.Message.Importance = v.String()
+	}
+	if v, _ := parseSensitivity(marking.Sensitivity); v != nil {
+		request.Message.SingleValueExtendedProperties = []thinProperty{{ID: sensitivityProperty, Value: strconv.Itoa(int(*v))}}
+	}
+	if err := thinRequest(ctx, client, abstractions.POST, "/sendMail", url.Values{}, nil, request, nil); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return nil
+}
+
+// thinRecipients is parseRecipients for the thin client.
+func thinRecipients(addresses string) []thinRecipient {
+	var recipients []thinRecipient
+	for _, addr := range strings.Split(addresses, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, thinRecipient{EmailAddress: thinEmailAddress{Address: addr}})
+		}
+	}
+	return recipients
+}
+
+func (m thinMessage) header() messageHeader {
+	h := messageHeader{
+		Subject:    deref(m.Subject, "(no subject)"),
+		Received:   m.ReceivedDateTime,
+		To:         []string{},
+		Categories: m.Categories,
+	}
+	if m.From != nil {
+		h.HasFrom = true
+		h.FromName, h.FromAddress = m.From.EmailAddress.Name, m.From.EmailAddress.Address
+	}
+	for _, r := range m.ToRecipients {
+		h.To = append(h.To, r.EmailAddress.Address)
+	}
+	return h
+}
+
+func (m thinMessage) id() string             { return m.ID }
+func (m thinMessage) subject() string        { return deref(m.Subject, "") }
+func (m thinMessage) isRead() bool           { return m.IsRead }
+func (m thinMessage) preview() string        { return m.BodyPreview }
+func (m thinMessage) categories() []string   { return m.Categories }
+func (m thinMessage) importance() string     { return m.Importance }
+func (m thinMessage) classification() string { return m.InferenceClassification }
+func (m thinMessage) hasAttachments() bool   { return m.HasAttachments }
+
+func (m thinMessage) body(opts ReadOptions) string       { return m.Body.text(opts) }
+func (m thinMessage) uniqueBody(opts ReadOptions) string { return m.UniqueBody.text(opts) }
+
+func (m thinMessage) recipients() []string {
+	to := []string{}
+	for _, r := range m.ToRecipients {
+		to = append(to, r.EmailAddress.Address)
+	}
+	return to
+}
+
+func (m thinMessage) flag() (status, due string) {
+	if m.Flag == nil || m.Flag.FlagStatus == "" || m.Flag.FlagStatus == FlagClear {
+		return "", ""
+	}
+	if m.Flag.DueDateTime != nil {
+		due = dateOf(m.Flag.DueDateTime.DateTime)
+	}
+	return m.Flag.FlagStatus, due
+}
+
+func (m thinMessage) sender() string {
+	if m.From == nil {
+		return ""
+	}
+	return m.From.EmailAddress.Address
+}
+
+func (m thinMessage) received() string {
+	if m.ReceivedDateTime == nil {
+		return ""
+	}
+	return formatMsgTime(m.ReceivedDateTime)
+}
+
+func (m thinMessage) property(id string) string {
+	for _, p := range m.SingleValueExtendedProperties {
+		if sameProperty(p.ID, id) {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+func (m thinMessage) size() int64 {
+	n, _ := strconv.ParseInt(m.property(sizeProperty), 10, 64)
+	return n
+}
+
+func (m thinMessage) threading() Threading {
+	return Threading{
+		ConversationID:    m.ConversationID,
+		ConversationIndex: m.ConversationIndex,
+		InternetMessageID: m.InternetMessageID,
+		InReplyTo:         m.property(inReplyToProperty),
+	}
+}
+
+// text mirrors extractBody.
+func (b *thinBody) text(opts ReadOptions) string {
+	if b == nil {
+		return ""
+	}
+	content := charset.Repair(b.Content)
+	if strings.EqualFold(b.ContentType, "html") {
+		return htmlText(content, opts)
+	}
+	return content
+}
@@ -0,0 +1,290 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/reldate"
+)
+
+// ---------- Interactive triage ----------
+
+// TriageDecision is one entry in the triage audit log: what was decided for
+// a message, when, and whether carrying it out failed.
+type TriageDecision struct {
+	Time      string `json:"time"`
+	Mailbox   string `json:"mailbox,omitempty"`
+	MessageID string `json:"messageId"`
+	Subject   string `json:"subject"`
+	From      string `json:"from"`
+	Action    string `json:"action"`          // archive, reply, flag, snooze, delete, or skip
+	Until     string `json:"until,omitempty"` // snooze only
+	Error     string `json:"error,omitempty"`
+}
+
+// TriageSummary is the JSON representation of a finished triage session.
+type TriageSummary struct {
+	Reviewed  int              `json:"reviewed"`
+	Remaining int              `json:"remaining"` // fetched but not reached before quitting
+	Counts    map[string]int   `json:"counts"`
+	Log       string           `json:"log"`
+	Decisions []TriageDecision `json:"decisions"`
+}
+
+// triageKeys maps the key typed at the prompt to its action.
+var triageKeys = map[string]string{
+	"a": "archive",
+	"r": "reply",
+	"f": "flag",
+	"s": "snooze",
+	"d": "delete",
+	"k": "skip",
+	"":  "skip",
+}
+
+func triageLogPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".outlook-assistant-triage.jsonl")
+}
+
+// TriageInteractive steps through the unread messages in a folder, newest
+// first, and asks what to do with each: archive, reply, flag, snooze, delete,
+// or skip. Every decision is appended to the audit log as it is made, so an
+// interrupted session still leaves a complete record.
+func TriageInteractive(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, folderName string, count int32, jsonOutput bool) error {
+	if folderName == "" {
+		folderName = "inbox"
+	}
+	folderID, err := resolveFolderID(ctx, client, folderName)
+	if err != nil {
+		return err
+	}
+	filter := "isRead eq false"
+	result, err := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages().Get(ctx, &users.ItemMailFoldersItemMessagesRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemMailFoldersItemMessagesRequestBuilderGetQueryParameters{
+			Select:  summaryFields,
+			Filter:  &filter,
+			Top:     &count,
+			Orderby: []string{"receivedDateTime DESC"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("listing unread messages: %w", err)
+	}
+	messages := result.GetValue()
+	if len(messages) == 0 {
+		slog.Info("No unread messages — inbox zero")
+		return nil
+	}
+
+	// With --json, stdout carries only the summary.
+	var out io.Writer = os.Stdout
+	if jsonOutput {
+		out = os.Stderr
+	}
+	summary := TriageSummary{Counts: map[string]int{}, Log: triageLogPath(), Decisions: []TriageDecision{}}
+	in := bufio.NewReader(os.Stdin)
+	quit := false
+	for i, msg := range messages {
+		fmt.Fprintf(out, "\n[%d/%d] %s\n", i+1, len(messages), deref(msg.GetSubject(), "(no subject)"))
+		fmt.Fprintf(out, "From    : %s\n", senderAddress(msg))
+		fmt.Fprintf(out, "Received: %s\n", formatMsgTime(msg.GetReceivedDateTime()))
+		if preview := strings.TrimSpace(deref(msg.GetBodyPreview(), "")); preview != "" {
+			fmt.Fprintf(out, "\n%s\n", preview)
+		}
+
+		for {
+			key, err := prompt(in, out, "[a]rchive [r]eply [f]lag [s]nooze [d]elete s[k]ip [q]uit > ")
+			if err != nil || key == "q" {
+				quit = true
+				break
+			}
+			action, ok := triageKeys[key]
+			if !ok {
+				slog.Warn("Unknown key", "key", key)
+				continue
+			}
+			decision := TriageDecision{
+				Mailbox:   mailbox.User(),
+				MessageID: deref(msg.GetId(), ""),
+				Subject:   deref(msg.GetSubject(), ""),
+				From:      senderAddress(msg),
+				Action:    action,
+			}
+			if err := triageApply(ctx, client, in, out, msg, &decision); err != nil {
+				if err == errTriageCancelled {
+					continue
+				}
+				decision.Error = err.Error()
+				slog.Error("Triage action failed", "action", action, "error", err)
+			}
+			decision.Time = time.Now().UTC().Format(time.RFC3339)
+			if err := appendTriageLog(decision); err != nil {
+				slog.Warn("Could not write the audit log", "error", err)
+			}
+			summary.Decisions = append(summary.Decisions, decision)
+			if decision.Error != "" {
+				// Let the user pick something else for the same message.
+				continue
+			}
+			summary.Reviewed++
+			summary.Counts[action]++
+			break
+		}
+		if quit {
+			summary.Remaining = len(messages) - i
+			break
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(summary)
+	}
+	fmt.Printf("\nReviewed %d messages", summary.Reviewed)
+	for _, action := range []string{"archive", "reply", "flag", "snooze", "delete", "skip"} {
+		if n := summary.Counts[action]; n > 0 {
+			fmt.Printf(", %s %d", action, n)
+		}
+	}
+	fmt.Println()
+	if summary.Remaining > 0 {
+		fmt.Printf("%d messages left unreviewed\n", summary.Remaining)
+	}
+	slog.Info("Decisions logged", "path", summary.Log)
+	return nil
+}
+
+// errTriageCancelled is returned when the user backs out of an action's
+// follow-up question, such as leaving a reply empty.
+var errTriageCancelled = fmt.Errorf("cancelled")
+
+// triageApply carries out the decision for msg, asking for the reply text or
+// snooze time when the action needs one.
+func triageApply(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, in *bufio.Reader, out io.Writer, msg models.Messageable, decision *TriageDecision) error {
+	id := deref(msg.GetId(), "")
+	switch decision.Action {
+	case "archive":
+		return Archive(ctx, client, id)
+	case "delete":
+		// Like the Delete key in Outlook: recoverable from Deleted Items.
+		return Move(ctx, client, id, "deleteditems")
+	case "reply":
+		body, err := promptLine(in, out, "Reply (one line, empty to cancel): ")
+		if err != nil || body == "" {
+			return errTriageCancelled
+		}
+		return Reply(ctx, client, id, body, FormatText, Marking{})
+	case "flag":
+		return patchFlag(ctx, client, id, nil)
+	case "snooze":
+		answer, err := promptLine(in, out, "Snooze for (e.g. 4h, 2d, 1w) or until YYYY-MM-DD [1d]: ")
+		if err != nil {
+			return errTriageCancelled
+		}
+		until, err := snoozeUntil(answer)
+		if err != nil {
+			slog.Warn(err.Error())
+			return errTriageCancelled
+		}
+		decision.Until = until.UTC().Format(time.RFC3339)
+		return patchFlag(ctx, client, id, &until)
+	}
+	return nil
+}
+
+// patchFlag flags a message for follow-up. With until set, the flag starts
+// and is due then and the message is marked read: Graph has no snooze, so
+// the message leaves the unread queue and comes back in To Do and the
+// Flagged view on that date.
+func patchFlag(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, id string, until *time.Time) error {
+	flag := models.NewFollowupFlag()
+	status := models.FLAGGED_FOLLOWUPFLAGSTATUS
+	flag.SetFlagStatus(&status)
+	if until != nil {
+		at := until.UTC().Format("2006-01-02T15:04:05")
+		utc := "UTC"
+		start := models.NewDateTimeTimeZone()
+		start.SetDateTime(&at)
+		start.SetTimeZone(&utc)
+		flag.SetStartDateTime(start)
+		flag.SetDueDateTime(start)
+	}
+	patch := models.NewMessage()
+	patch.SetFlag(flag)
+	if until != nil {
+		isRead := true
+		patch.SetIsRead(&isRead)
+	}
+	if _, err := mailbox.Of(client).Messages().ByMessageId(id).Patch(ctx, patch, nil); err != nil {
+		return fmt.Errorf("flagging message: %w", err)
+	}
+	return nil
+}
+
+// snoozeUntil parses a snooze answer: an interval from now such as 4h, 2d,
+// 1w, or 1mo, or a date (9:00 that day). Empty means one day.
+func snoozeUntil(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		s = "1d"
+	}
+	if t, ok := reldate.Ahead(s, time.Now()); ok {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid snooze %q — use e.g. 4h, 2d, 1w, or YYYY-MM-DD", s)
+	}
+	return t.Add(9 * time.Hour), nil
+}
+
+// prompt reads one key from a line of input, lower-cased.
+func prompt(in *bufio.Reader, out io.Writer, text string) (string, error) {
+	line, err := promptLine(in, out, text)
+	return strings.ToLower(line), err
+}
+
+// promptLine writes text to out, beside the message it asks about, and reads
+// a line of input. It returns io.EOF when input ends before a line is
+// entered, and then ends the prompt's line so that what follows starts on
+// its own.
+func promptLine(in *bufio.Reader, out io.Writer, text string) (string, error) {
+	fmt.Fprint(out, text)
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		fmt.Fprintln(out)
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// appendTriageLog appends one decision to the audit log as a JSON line.
+func appendTriageLog(d TriageDecision) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(triageLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,409 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/contacts"
+	"outlook-assistant/mailbox"
+)
+
+// ---------- Recipient validation ----------
+
+// Recipient check statuses, from best to worst.
+const (
+	RecipientOK         = "ok"
+	RecipientResolved   = "resolved"
+	RecipientSuspicious = "suspicious"
+	RecipientInvalid    = "invalid"
+)
+
+// RecipientCheck is the JSON representation of one validated recipient.
+type RecipientCheck struct {
+	Field   string `json:"field"`
+	Input   string `json:"input"`
+	Address string `json:"address,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Candidates lists the people an ambiguous name matched, most relevant
+	// first, as "Name <address>".
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// maxCandidates caps the people listed for an ambiguous name.
+const maxCandidates = 5
+
+// commonDomains are public mail domains whose misspellings are worth flagging.
+// The sender's own domain is added at check time. Short domains such as aol.com
+// are left out: too many legitimate domains are one edit away from them.
+var commonDomains = []string{
+	"gmail.com", "googlemail.com", "outlook.com", "hotmail.com",
+	"yahoo.com", "icloud.com", "protonmail.com",
+}
+
+// realDomains are genuine providers close enough to a commonDomains entry to
+// be mistaken for a typo of it.
+var realDomains = map[string]bool{
+	"mail.com": true, "ymail.com": true, "gmx.com": true, "email.com": true,
+}
+
+// typoTLDs are top-level domains that are almost always a mistyped .com.
+var typoTLDs = map[string]bool{
+	"con": true, "cmo": true, "ocm": true, "comm": true, "vom": true, "xom": true, "cm": true,
+}
+
+// CheckRecipients validates the comma-separated to, cc, and bcc lists before a
+// send or forward. Addresses are syntax-checked and their domains compared
+// against common providers and the sender's own domain to catch typos such as
+// "gamil.com". Entries without an @ are treated as names and looked up among
+// the people you work with, then in the directory and your contacts; a single
+// match is replaced by that person's address.
+//
+// Invalid or unresolvable entries always fail, since Graph would bounce them.
+// Suspected typos are printed as warnings, and fail only when strict is set.
+// The returned lists have resolved names substituted.
+func CheckRecipients(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, to, cc, bcc string, strict bool) (string, string, string, error) {
+	checks := ValidateRecipients(ctx, client, map[string]string{"to": to, "cc": cc, "bcc": bcc})
+
+	resolved := map[string][]string{}
+	var problems []string
+	for _, c := range checks {
+		switch c.Status {
+		case RecipientInvalid:
+			problems = append(problems, fmt.Sprintf("%s: %s", c.Input, c.Message))
+		case RecipientSuspicious:
+			if strict {
+				problems = append(problems, fmt.Sprintf("%s: %s", c.Input, c.Message))
+			} else {
+				slog.Warn(c.Message, "recipient", c.Input)
+			}
+		case RecipientResolved:
+			slog.Info("Resolved recipient", "name", c.Input, "address", c.Address)
+		}
+		resolved[c.Field] = append(resolved[c.Field], c.Address)
+	}
+	if len(problems) > 0 {
+		return "", "", "", fmt.Errorf("recipient check failed:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return strings.Join(resolved["to"], ","), strings.Join(resolved["cc"], ","), strings.Join(resolved["bcc"], ","), nil
+}
+
+// Validate prints the check for each recipient without sending anything.
+// It returns an error when a recipient is invalid, or suspicious with strict.
+func Validate(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, to, cc, bcc string, strict, jsonOutput bool) error {
+	checks := ValidateRecipients(ctx, client, map[string]string{"to": to, "cc": cc, "bcc": bcc})
+
+	failed := 0
+	for _, c := range checks {
+		if c.Status == RecipientInvalid || (strict && c.Status == RecipientSuspicious) {
+			failed++
+		}
+	}
+
+	if jsonOutput {
+		if err := printJSON(checks); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("\n%-4s  %-30s  %-10s  %s\n", "", "Recipient", "Status", "Detail")
+		fmt.Println(strings.Repeat("-", 90))
+		for _, c := range checks {
+			detail := c.Message
+			if c.Status == RecipientResolved {
+				detail = c.Address
+				if c.Name != "" {
+					detail = c.Name + " <" + c.Address + ">"
+				}
+			}
+			fmt.Printf("%-4s  %-30s  %-10s  %s\n", c.Field, truncate(c.Input, 30), c.Status, detail)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d recipients failed validation", failed, len(checks))
+	}
+	return nil
+}
+
+// ValidateRecipients checks every entry of the comma-separated lists keyed by
+// field (to, cc, bcc), in that order.
+func ValidateRecipients(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, fields map[string]string) []RecipientCheck {
+	domains := commonDomains
+	if own := ownDomain(ctx, client); own != "" {
+		domains = append([]string{own}, domains...)
+	}
+
+	var checks []RecipientCheck
+	for _, field := range []string{"to", "cc", "bcc"} {
+		for _, input := range splitList(fields[field]) {
+			c := checkAddress(input, domains)
+			if c.Status == "" {
+				c = resolvePerson(ctx, client, input)
+			}
+			c.Field = field
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+// checkAddress validates an entry containing an @. It leaves Status empty for
+// entries without one so the caller can try them as names.
+func checkAddress(input string, domains []string) RecipientCheck {
+	c := RecipientCheck{Input: input}
+	if !strings.Contains(input, "@") {
+		return c
+	}
+	parsed, err := netmail.ParseAddress(input)
+	if err != nil {
+		c.Status = RecipientInvalid
+		c.Message = "not a valid email address"
+		return c
+	}
+	c.Address, c.Name = parsed.Address, parsed.Name
+
+	domain := strings.ToLower(parsed.Address[strings.LastIndex(parsed.Address, "@")+1:])
+	if !strings.Contains(domain, ".") {
+		c.Status = RecipientInvalid
+		c.Message = fmt.Sprintf("domain %q has no top-level domain", domain)
+		return c
+	}
+	if suggestion := likelyTypo(domain, domains); suggestion != "" {
+		c.Status = RecipientSuspicious
+		c.Message = fmt.Sprintf("domain %q looks like a typo — did you mean %s?", domain, suggestion)
+		return c
+	}
+	c.Status = RecipientOK
+	return c
+}
+
+// likelyTypo returns the domain that domain was probably meant to be, or "".
+func likelyTypo(domain string, known []string) string {
+	if realDomains[domain] {
+		return ""
+	}
+	for _, k := range known {
+		if domain == k {
+			return ""
+		}
+	}
+	best, bestDist := "", 3
+	for _, k := range known {
+		// Allow one edit for short domains, two for longer ones.
+		limit := 1
+		if len(k) >= 9 {
+			limit = 2
+		}
+		if d := editDistance(domain, k); d <= limit && d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if i := strings.LastIndex(domain, "."); i >= 0 && typoTLDs[domain[i+1:]] {
+		return domain[:i] + ".com"
+	}
+	return ""
+}
+
+// resolvePerson looks up a name with the People API, which ranks the people
+// you work with by how much you communicate with them, so "Sarah" finds the
+// Sarah you mail rather than every Sarah in the company. An exact display
+// name wins; otherwise a single match is used, and several are reported most
+// relevant first. When the People API fails or finds no one, the directory
+// and your contacts are tried instead.
+func resolvePerson(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name string) RecipientCheck {
+	search := `"` + strings.ReplaceAll(name, `"`, "") + `"`
+	top := int32(10)
+	result, err := mailbox.Of(client).People().Get(ctx, &users.ItemPeopleRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.ItemPeopleRequestBuilderGetQueryParameters{
+			Search: &search,
+			Select: []string{"displayName", "scoredEmailAddresses", "jobTitle", "department"},
+			Top:    &top,
+		},
+	})
+	if err != nil {
+		slog.Debug("People lookup failed; trying the directory", "name", name, "error", err)
+		return resolveName(ctx, client, name)
+	}
+
+	var matches, exact []models.Personable
+	for _, p := range result.GetValue() {
+		if personAddress(p) == "" {
+			continue
+		}
+		matches = append(matches, p)
+		if strings.EqualFold(deref(p.GetDisplayName(), ""), name) {
+			exact = append(exact, p)
+		}
+	}
+	if len(exact) > 0 {
+		matches = exact
+	}
+
+	c := RecipientCheck{Input: name, Status: RecipientInvalid}
+	switch len(matches) {
+	case 0:
+		return resolveName(ctx, client, name)
+	case 1:
+		c.Address = personAddress(matches[0])
+		c.Name = deref(matches[0].GetDisplayName(), "")
+		c.Status = RecipientResolved
+	default:
+		for i, p := range matches {
+			if i == maxCandidates {
+				break
+			}
+			candidate := fmt.Sprintf("%s <%s>", deref(p.GetDisplayName(), ""), personAddress(p))
+			var role []string
+			for _, s := range []*string{p.GetJobTitle(), p.GetDepartment()} {
+				if v := deref(s, ""); v != "" {
+					role = append(role, v)
+				}
+			}
+			if len(role) > 0 {
+				candidate += " (" + strings.Join(role, ", ") + ")"
+			}
+			c.Candidates = append(c.Candidates, candidate)
+		}
+		c.Message = fmt.Sprintf("ambiguous — %d people match; use an address or a full name: %s",
+			len(matches), strings.Join(c.Candidates, "; "))
+	}
+	return c
+}
+
+// personAddress returns a person's most relevant email address, or "".
+func personAddress(p models.Personable) string {
+	for _, e := range p.GetScoredEmailAddresses() {
+		if addr := deref(e.GetAddress(), ""); addr != "" {
+			return addr
+		}
+	}
+	return ""
+}
+
+// resolveName looks up a display name in the directory and, when nobody there
+// has it, in your personal contacts, which hold people outside the
+// organization.
+func resolveName(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, name string) RecipientCheck {
+	c := RecipientCheck{Input: name, Status: RecipientInvalid}
+	quoted := strings.ReplaceAll(name, "'", "''")
+	filter := fmt.Sprintf("startswith(displayName,'%s')", quoted)
+	top := int32(5)
+	result, err := client.Users().Get(ctx, &users.UsersRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.UsersRequestBuilderGetQueryParameters{
+			Filter: &filter,
+			Select: []string{"displayName", "mail", "userPrincipalName"},
+			Top:    &top,
+		},
+	})
+	var matches []models.Userable
+	if err == nil {
+		matches = result.GetValue()
+	}
+	if len(matches) == 0 {
+		if found, cerr := contacts.FindByName(ctx, client, name); cerr == nil && len(found) > 0 {
+			return resolveContact(c, found)
+		}
+	}
+	if err != nil {
+		c.Message = fmt.Sprintf("not an email address, and the directory lookup failed: %v", err)
+		return c
+	}
+
+	// Prefer an exact display name match over prefix matches.
+	for _, u := range matches {
+		if strings.EqualFold(deref(u.GetDisplayName(), ""), name) {
+			matches = []models.Userable{u}
+			break
+		}
+	}
+	switch len(matches) {
+	case 0:
+		c.Message = "not an email address, and no one by that name is in the directory or your contacts"
+	case 1:
+		u := matches[0]
+		c.Address = deref(u.GetMail(), deref(u.GetUserPrincipalName(), ""))
+		c.Name = deref(u.GetDisplayName(), "")
+		if c.Address == "" {
+			c.Message = fmt.Sprintf("%s has no mailbox", c.Name)
+			return c
+		}
+		c.Status = RecipientResolved
+	default:
+		for _, u := range matches {
+			c.Candidates = append(c.Candidates, fmt.Sprintf("%s <%s>", deref(u.GetDisplayName(), ""), deref(u.GetMail(), "")))
+		}
+		c.Message = "ambiguous — matches " + strings.Join(c.Candidates, ", ")
+	}
+	return c
+}
+
+// resolveContact completes c from the contacts a name matched.
+func resolveContact(c RecipientCheck, found []contacts.ContactSummary) RecipientCheck {
+	if len(found) > 1 {
+		for _, f := range found {
+			c.Candidates = append(c.Candidates, fmt.Sprintf("%s <%s>", f.Name, f.Emails[0]))
+		}
+		c.Message = "ambiguous — matches contacts " + strings.Join(c.Candidates, ", ")
+		return c
+	}
+	c.Address = found[0].Emails[0]
+	c.Name = found[0].Name
+	c.Status = RecipientResolved
+	return c
+}
+
+// ownDomain returns the domain of the mailbox being sent from, or "" if it
+// cannot be read.
+func ownDomain(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) string {
+	me, err := mailbox.Of(client).Get(ctx, &users.UserItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &users.UserItemRequestBuilderGetQueryParameters{
+			Select: []string{"mail", "userPrincipalName"},
+		},
+	})
+	if err != nil {
+		return ""
+	}
+	addr := deref(me.GetMail(), deref(me.GetUserPrincipalName(), ""))
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return strings.ToLower(addr[i+1:])
+	}
+	return ""
+}
+
+// editDistance is the Damerau–Levenshtein (optimal string alignment) distance,
+// so a transposition such as "gmial" counts as one edit.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
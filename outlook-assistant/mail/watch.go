@@ -0,0 +1,267 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/schema"
+)
+
+// ---------- Watch (delta token stored in home directory) ----------
+
+// WatchEvent is one line of `mail watch --json`: a message that arrived in,
+// changed in, or left the watched folder. A removed message carries only its
+// ID.
+type WatchEvent struct {
+	Event            string   `json:"event"` // new, changed, or removed
+	Folder           string   `json:"folder"`
+	ID               string   `json:"id"`
+	Subject          string   `json:"subject,omitempty"`
+	From             string   `json:"from,omitempty"`
+	ReceivedDateTime string   `json:"receivedDateTime,omitempty"`
+	IsRead           bool     `json:"isRead"`
+	BodyPreview      string   `json:"bodyPreview,omitempty"`
+	Categories       []string `json:"categories,omitempty"`
+	Threading
+}
+
+// WatchOptions controls Watch.
+type WatchOptions struct {
+	Folder   string        // folder name or well-known name (default: inbox)
+	Interval time.Duration // wait between polls
+	Since    string        // start afresh from messages received since then
+	Once     bool          // poll once and return
+}
+
+// watchState is where a folder's watch left off: the delta link for the next
+// poll, and the IDs of the messages already reported, so that a message seen
+// again is reported as changed rather than new.
+type watchState struct {
+	DeltaLink string   `json:"deltaLink"`
+	Seen      []string `json:"seen"`
+}
+
+func watchStatePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, mailbox.CacheFile(".outlook-assistant-watch.json"))
+}
+
+// loadWatchState reads the saved state of every watched folder, by folder ID.
+func loadWatchState() (map[string]watchState, error) {
+	states := map[string]watchState{}
+	data, err := os.ReadFile(watchStatePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading watch state: %w", err)
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("parsing watch state %s: %w", watchStatePath(), err)
+	}
+	return states, nil
+}
+
+func saveWatchState(states map[string]watchState) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(watchStatePath(), data, 0600); err != nil {
+		return fmt.Errorf("writing watch state: %w", err)
+	}
+	return nil
+}
+
+// Watch polls a folder with a Graph delta query every opts.Interval and prints
+// each message that arrived, changed, or left since the last poll, as one
+// NDJSON line with jsonOutput. The delta link is saved after every poll, so a
+// later run picks up where this one stopped; an event from a poll that was
+// interrupted may be printed again. The first run watches messages received
+// from now on, or since opts.Since, which also restarts a saved watch. Watch
+// returns when ctx is cancelled.
+func Watch(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, opts WatchOptions, jsonOutput bool) error {
+	if opts.Folder == "" {
+		opts.Folder = "inbox"
+	}
+	folderID, err := resolveFolderID(ctx, client, opts.Folder)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if opts.Since != "" {
+		if start, err = parseFlexibleDate(opts.Since); err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+	}
+
+	states, err := loadWatchState()
+	if err != nil {
+		return err
+	}
+	state := states[folderID]
+	if opts.Since != "" || state.DeltaLink == "" {
+		state = watchState{}
+		slog.Info("Watching for messages", "folder", opts.Folder, "since", start.Format("2006-01-02 15:04"))
+	} else {
+		slog.Info("Resuming watch", "folder", opts.Folder)
+	}
+
+	for {
+		next, err := watchPoll(ctx, client, folderID, start, &state, opts.Folder, jsonOutput)
+		if ctx.Err() != nil {
+			slog.Info("Watch stopped", "folder", opts.Folder)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		state.DeltaLink = next
+		states[folderID] = state
+		if err := saveWatchState(states); err != nil {
+			return err
+		}
+		if opts.Once {
+			return nil
+		}
+
+		timer := time.NewTimer(opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Watch stopped", "folder", opts.Folder)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// watchPoll follows a delta query through its pages, printing each change,
+// and returns the delta link for the next poll. Without a delta link in
+// state, it starts a new one for messages received since start. A delta link
+// Graph no longer accepts is dropped and the watch starts again from now.
+func watchPoll(
+	ctx context.Context,
+	client *msgraphsdkgo.GraphServiceClient,
+	folderID string,
+	start time.Time,
+	state *watchState,
+	folder string,
+	jsonOutput bool,
+) (string, error) {
+	builder := mailbox.Of(client).MailFolders().ByMailFolderId(folderID).Messages().Delta()
+	filter := "receivedDateTime ge " + start.UTC().Format(time.RFC3339)
+	config := &users.ItemMailFoldersItemMessagesDeltaRequestBuilderGetRequestConfiguration{
+		Headers: abstractions.NewRequestHeaders(),
+		QueryParameters: &users.ItemMailFoldersItemMessagesDeltaRequestBuilderGetQueryParameters{
+			Select: summaryFields,
+			Filter: &filter,
+		},
+	}
+	config.Headers.Add("Prefer", "odata.maxpagesize=50")
+	if state.DeltaLink != "" {
+		builder = builder.WithUrl(state.DeltaLink)
+		// The link carries the query it was started with.
+		config.QueryParameters = nil
+	}
+
+	seen := make(map[string]bool, len(state.Seen))
+	for _, id := range state.Seen {
+		seen[id] = true
+	}
+	for {
+		page, err := builder.GetAsDeltaGetResponse(ctx, config)
+		var odataErr *odataerrors.ODataError
+		if errors.As(err, &odataErr) && odataErr.ResponseStatusCode == http.StatusGone && state.DeltaLink != "" {
+			slog.Warn("Graph no longer accepts the saved watch position; starting again from now", "folder", folder)
+			*state = watchState{}
+			return watchPoll(ctx, client, folderID, time.Now(), state, folder, jsonOutput)
+		}
+		if err != nil {
+			return "", fmt.Errorf("polling for changes: %w", err)
+		}
+
+		for _, msg := range page.GetValue() {
+			ev := watchEvent(msg, folder, seen)
+			if err := printWatchEvent(ev, jsonOutput); err != nil {
+				return "", err
+			}
+		}
+
+		if next := page.GetOdataNextLink(); next != nil {
+			builder = builder.WithUrl(*next)
+			config.QueryParameters = nil
+			continue
+		}
+		state.Seen = state.Seen[:0]
+		for id := range seen {
+			state.Seen = append(state.Seen, id)
+		}
+		sort.Strings(state.Seen)
+		return deref(page.GetOdataDeltaLink(), ""), nil
+	}
+}
+
+// watchEvent describes one message of a delta page and records it in seen.
+func watchEvent(msg models.Messageable, folder string, seen map[string]bool) WatchEvent {
+	id := deref(msg.GetId(), "")
+	if _, removed := msg.GetAdditionalData()["@removed"]; removed {
+		delete(seen, id)
+		return WatchEvent{Event: "removed", Folder: folder, ID: id}
+	}
+	event := "new"
+	if seen[id] {
+		event = "changed"
+	}
+	seen[id] = true
+	return WatchEvent{
+		Event:            event,
+		Folder:           folder,
+		ID:               id,
+		Subject:          deref(msg.GetSubject(), ""),
+		From:             senderAddress(msg),
+		ReceivedDateTime: formatMsgTime(msg.GetReceivedDateTime()),
+		IsRead:           msg.GetIsRead() != nil && *msg.GetIsRead(),
+		BodyPreview:      deref(msg.GetBodyPreview(), ""),
+		Categories:       msg.GetCategories(),
+		Threading:        threadingOf(msg),
+	}
+}
+
+// printWatchEvent prints an event as one compact JSON line, or as a line of
+// text.
+func printWatchEvent(ev WatchEvent, jsonOutput bool) error {
+	if !jsonOutput {
+		if ev.Event == "removed" {
+			fmt.Printf("%-8s %s\n", ev.Event, ev.ID)
+			return nil
+		}
+		fmt.Printf("%-8s %-16s  %-30s  %s\n", ev.Event, ev.ReceivedDateTime, truncate(ev.From, 30), ev.Subject)
+		return nil
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if data, err = schema.Stamp(data); err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,62 @@
+// Package mailbox selects whose mailbox Graph requests act on: the signed-in
+// user's own (/me) by default, or another one: a shared or delegated mailbox
+// the user can open, or any user's when running app-only.
+package mailbox
+
+import (
+	"path/filepath"
+	"strings"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+// user is the UPN or object ID selected with Use; "" means /me.
+var user string
+
+// Use directs every subsequent request to the mailbox of upnOrID.
+// Pass "" to act on the signed-in user's own mailbox.
+func Use(upnOrID string) {
+	user = upnOrID
+}
+
+// Of returns the request builder for the selected mailbox. All mail, calendar,
+// and settings requests start here rather than at client.Me().
+func Of(client *msgraphsdkgo.GraphServiceClient) *users.UserItemRequestBuilder {
+	if user == "" {
+		return client.Me()
+	}
+	return client.Users().ByUserId(user)
+}
+
+// Path returns the selected mailbox as a relative URL segment, "me" or
+// "users/{id}", for requests built by hand.
+func Path() string {
+	if user == "" {
+		return "me"
+	}
+	return "users/" + user
+}
+
+// User returns the UPN or object ID selected with Use, or "" for /me.
+func User() string {
+	return user
+}
+
+// CacheFile returns the name of a local cache file, such as a list's index
+// cache, for the selected mailbox: name itself for /me, otherwise name with
+// the mailbox inserted before its extension, so that indexes listed in one
+// mailbox are never resolved against another.
+func CacheFile(name string) string {
+	if user == "" {
+		return name
+	}
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(user))
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + safe + ext
+}
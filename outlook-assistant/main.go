@@ -1,103 +1,331 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	// --timezone works on systems without a zoneinfo database, such as Windows.
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
+	khttp "github.com/microsoft/kiota-http-go"
 	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
 
 	"outlook-assistant/auth"
 	"outlook-assistant/calendar"
+	"outlook-assistant/config"
+	"outlook-assistant/contacts"
+	"outlook-assistant/daemon"
+	"outlook-assistant/httpcache"
 	"outlook-assistant/mail"
+	"outlook-assistant/mailbox"
+	manifestpkg "outlook-assistant/manifest"
+	"outlook-assistant/mcp"
+	"outlook-assistant/mockgraph"
+	"outlook-assistant/people"
+	"outlook-assistant/retry"
+	"outlook-assistant/schema"
+	"outlook-assistant/stats"
+	"outlook-assistant/subscribe"
 )
 
+// manifest is the tool description printed by --describe.
+//
+//go:embed tool.yaml
+var manifest string
+
+// systemLocal is the system's time zone, which --timezone replaces for one
+// command.
+var systemLocal = time.Local
+
 func main() {
-	if err := run(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	// Until --log-level and --log-format are parsed, log as plain text.
+	slog.SetDefault(newLogger(slog.LevelInfo, "text"))
+	if err := run(context.Background(), os.Args[1:], nil); err != nil {
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 }
 
-func run() error {
+// run executes one command line. sess is set when a server runs the command
+// on behalf of a client, and nil otherwise.
+func run(ctx context.Context, args []string, sess *session) (err error) {
 	// Load credentials — try multiple locations so the tool works from any CWD.
 	// Priority: binary's own directory → ~/.outlook-assistant.env → CWD .env
 	loadEnv()
 
+	// The command is given as words, "mail list", before, between, or after
+	// its flags; --group and --action remain for older callers. The command
+	// line is parsed first with the flags of every action, to find the
+	// command and the flags given, and then with the command's own FlagSet.
+	every := newFlagSet("", "", &globalOptions{}, &commandOptions{}, true)
+	every.Usage = printUsage
+	if sess != nil {
+		// A client gets the parse error, not pages of help.
+		every.Usage = func() {}
+	}
+	words, flagArgs := splitCommand(every, args)
+	if len(words) > 0 && words[0] == "help" {
+		return printHelp(words[1:])
+	}
+	if len(words) > 0 && sess == nil {
+		every.Usage = func() { printHelp(words) }
+	}
+	if err := every.Parse(flagArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	given := map[string]bool{}
+	every.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	group, action := every.Lookup("group").Value.String(), every.Lookup("action").Value.String()
+	if err := selectCommand(words, given, &group, &action); err != nil {
+		return err
+	}
+
+	var g globalOptions
+	var opts commandOptions
+	fs := newFlagSet(group, action, &g, &opts, len(words) == 0)
+	if err := fs.Parse(flagArgs); err != nil {
+		return err
+	}
+
+	// The config file fills in the flags not given on the command line that
+	// the selected action takes without requiring them.
+	configFile, required := g.config, true
+	if configFile == "" {
+		configFile, required = config.DefaultPath(), false
+	}
+	settings, err := config.Load(configFile, g.profile, required)
+	if err != nil {
+		return err
+	}
+	selected := findAction(group, action)
+	known := func(name string) bool { return every.Lookup(name) != nil }
+	settable := func(name, value string) bool {
+		return globalFlags[name] || selected != nil && selected.Settable(name, value)
+	}
+	if err := config.Apply(fs, settings, configFile, known, settable); err != nil {
+		return err
+	}
+
+	time.Local = systemLocal
+	if g.timezone != "" {
+		loc, err := time.LoadLocation(g.timezone)
+		if err != nil {
+			return fmt.Errorf("--timezone: %w", err)
+		}
+		time.Local = loc
+	}
+
+	if err := setupLogging(g.logLevel, g.logFormat); err != nil {
+		return err
+	}
+	if len(words) == 0 && given["action"] && sess == nil {
+		slog.Warn(fmt.Sprintf("--group and --action are deprecated; run `outlook-assistant %s %s` instead", group, action))
+	}
+
+	if g.describe {
+		fmt.Print(manifest)
+		return nil
+	}
+	if g.serve != "" {
+		if sess != nil {
+			return fmt.Errorf("--serve cannot be given to a server")
+		}
+		return handleServe(ctx, g.serve, fs, given)
+	}
+	if action == "" {
+		if len(words) == 1 {
+			return printHelp(words)
+		}
+		printUsage()
+		return nil
+	}
+	if !slices.Contains(groups, group) {
+		return fmt.Errorf("unknown group %q — valid groups: %s", group, strings.Join(groups, ", "))
+	}
+	if b := opts.bodyOf(group); b != nil {
+		if err := b.readBody(sess != nil); err != nil {
+			return err
+		}
+	}
+
+	// Actions that only read or write local files need no credentials and
+	// must not trigger a sign-in.
+	switch {
+	case group == "snippets" && (action != "use" || opts.snippets.ref == ""):
+		return handleSnippets(ctx, nil, action, &opts.snippets, g.json)
+	case group == "signature":
+		return handleSignature(action, &opts.signature, g.json, settings["signature"])
+	case group == "mail" && action == "outbox-list":
+		return mail.Outbox(g.json)
+	case group == "schema":
+		return handleSchema(action, &opts.schema, g.json)
+	case group == "devtools":
+		return handleDevtools(action, &opts.devtools, g.json)
+	}
+
+	if g.maxRetries < 0 {
+		return fmt.Errorf("--max-retries must be 0 or more")
+	}
+	// A server answers one call at a time, so a watch or listener that never
+	// returns would block every call after it.
+	if sess != nil && group == "mail" && action == "watch" && !opts.mail.once {
+		return fmt.Errorf("mail watch runs until interrupted; give --once to poll from a server")
+	}
+	if sess != nil && group == "subscribe" && action == "listen" {
+		return fmt.Errorf("subscribe listen runs until interrupted and cannot be called from a server")
+	}
+	// A mock Graph endpoint needs no app registration or sign-in.
+	graphURL := os.Getenv(auth.GraphURLEnv)
+
 	clientID := os.Getenv("CLIENT_ID")
 	tenantID := os.Getenv("TENANT_ID")
-	if clientID == "" || tenantID == "" {
+	if g.tenant != "" {
+		tenantID = g.tenant
+	}
+	mode := g.auth
+	if mode == "" {
+		mode = os.Getenv("AUTH_MODE")
+	}
+	// A managed identity brings its own client and tenant.
+	if graphURL == "" && mode != auth.ModeManagedIdentity && (clientID == "" || tenantID == "") {
 		return fmt.Errorf("CLIENT_ID and TENANT_ID must be set in environment or .env file")
 	}
 
-	// ── Structural flags ──────────────────────────────────────────────────────
-	group  := flag.String("group", "mail", "Command group: mail | calendar (default: mail)")
-	action := flag.String("action", "", "Action: list | read | send | reply | forward | search | archive | move | categorize | markread | delete | folders | create")
-	ref    := flag.String("ref", "", "Message reference: list index (e.g. 3) or raw Graph message ID")
-	query  := flag.String("query", "", "Search query string (mail search)")
-
-	// ── Shared output flag ────────────────────────────────────────────────────
-	jsonOut := flag.Bool("json", false, "Output results as JSON to stdout")
-
-	// ── List / filter flags ───────────────────────────────────────────────────
-	count   := flag.Int("n", 20, "Number of messages or events to fetch")
-	page    := flag.Int("page", 1, "Page number, 1-based (mail list)")
-	since   := flag.String("since", "", "Only messages received on or after date: YYYY-MM-DD or YYYY-MM-DD HH:MM")
-	before  := flag.String("before", "", "Only messages received on or before date: YYYY-MM-DD or YYYY-MM-DD HH:MM")
-	from    := flag.String("from", "", "Only messages from this sender email address")
-	unread  := flag.Bool("unread", false, "mail list: only unread messages. mail markread: mark as unread instead of read")
-	folder  := flag.String("folder", "inbox", "Folder name or well-known name (mail list, mail move). Default: inbox")
-	subject := flag.String("subject", "", "Email subject — filter substring for mail list, subject line for mail send")
-
-	// ── Send / reply flags ────────────────────────────────────────────────────
-	to   := flag.String("to", "", "Recipient address(es), comma-separated (mail send)")
-	cc   := flag.String("cc", "", "CC address(es), comma-separated (mail send)")
-	bcc  := flag.String("bcc", "", "BCC address(es), comma-separated (mail send)")
-	body   := flag.String("body", "", "Message body text (mail send, mail reply)")
-	format := flag.String("format", "text", "Body format: text (default), md (Markdown), or html (raw HTML pass-through)")
-
-	// ── Categorize flag ───────────────────────────────────────────────────────
-	set := flag.String("set", "", "Comma-separated category names to apply; empty string clears all (mail categorize)")
-
-	// ── Calendar create flags ─────────────────────────────────────────────────
-	title     := flag.String("title", "", "Event title (calendar create)")
-	start     := flag.String("start", "", "Start date/time: \"2006-01-02 15:04\" (calendar create)")
-	end       := flag.String("end", "", "End date/time: \"2006-01-02 15:04\" (calendar create)")
-	location  := flag.String("location", "", "Location string (calendar create)")
-	attendees := flag.String("attendees", "", "Comma-separated attendee emails (calendar create)")
-
-	flag.Usage = printUsage
-	flag.Parse()
-
-	if *action == "" {
-		printUsage()
-		return nil
+	// --mailbox and --user name the same thing; --mailbox reads better for a
+	// shared mailbox opened by a signed-in user.
+	owner := g.user
+	if g.mailbox != "" {
+		if owner != "" && !strings.EqualFold(owner, g.mailbox) {
+			return fmt.Errorf("--user and --mailbox name different mailboxes; give only one")
+		}
+		owner = g.mailbox
+	}
+
+	authConfig := auth.Config{
+		Mode:              mode,
+		ManagedIdentityID:  os.Getenv("MANAGED_IDENTITY_CLIENT_ID"),
+		ClientCertPath:     os.Getenv("CLIENT_CERT_PATH"),
+		ClientCertPassword: os.Getenv("CLIENT_CERT_PASSWORD"),
+		ClientSecret:       os.Getenv("CLIENT_SECRET"),
+		ClientID:           clientID,
+		TenantID:           tenantID,
+		Profile:            g.tenant,
+		TokenStore:         g.tokenStore,
+		GraphURL:           graphURL,
+		SharedMailbox:      owner != "" && mode != auth.ModeManagedIdentity && mode != auth.ModeClientCredentials,
+		Scopes:             auth.CommandScopes(group, action),
+		MaxRetries:         g.maxRetries,
+	}
+
+	// auth actions inspect local state only and must not trigger a sign-in.
+	if group == "auth" {
+		return handleAuth(authConfig, action, g.json)
+	}
+
+	// Turn an opaque 403 into the permission the command needed and how to grant it.
+	defer func() {
+		if auth.AccessDenied(err) {
+			permission := permissionFor(group, action)
+			// Another user's mailbox needs the .Shared variant of a delegated
+			// mail, calendar, or contacts permission.
+			if authConfig.SharedMailbox && !strings.HasPrefix(permission, "MailboxSettings.") && !strings.HasPrefix(permission, "User") && !strings.HasPrefix(permission, "GroupMember") {
+				permission += ".Shared"
+			}
+			err = fmt.Errorf("%w\n\n%s", err, auth.ConsentHelp(authConfig, permission))
+		}
+	}()
+
+	// An app-only identity has no /me, so the mailbox must be named. A
+	// delegated sign-in acts on its own mailbox unless another is named.
+	if authConfig.AppOnly() && owner == "" {
+		return fmt.Errorf("--user=<upn|id> is required with --auth=%s", mode)
 	}
+	mailbox.Use(owner)
 
-	fmt.Fprintln(os.Stderr, "Authenticating with Microsoft...")
-	client, err := auth.NewGraphClient(clientID, tenantID)
+	cacheNamespace := ""
+	if g.cache {
+		cacheNamespace = strings.Join([]string{clientID, tenantID, mode, owner}, "|")
+	}
+	client, recorder, err := sess.graphClient(authConfig, cacheNamespace)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 
-	ctx := context.Background()
+	if g.stats {
+		defer recorder.Report(os.Stderr, g.json)
+	}
+
+	ctx = retry.WithLimit(ctx, g.maxRetries)
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("gave up after --timeout=%s: %w", g.timeout, err)
+			}
+		}()
+	}
+
+	// Calendar times are read and shown in the mailbox's own time zone, as
+	// Outlook shows them, unless --timezone names another.
+	if group == "calendar" && g.timezone == "" {
+		if loc, err := calendar.MailboxTimeZone(ctx, client); err != nil {
+			slog.Debug("Using the system time zone", "reason", err)
+		} else {
+			time.Local = loc
+		}
+	}
 
-	switch *group {
+	switch group {
 	case "mail":
-		return handleMail(ctx, client, *action, *ref, *query, *jsonOut, *count, *page,
-			*since, *before, *from, *unread, *folder, *subject,
-			*to, *cc, *bcc, *body, *format, *set)
+		return handleMail(ctx, client, action, &opts.mail, g.json)
 
 	case "calendar":
-		return handleCalendar(ctx, client, *action, *jsonOut, *count,
-			*since, *before,
-			*title, *start, *end, *location, *attendees)
+		return handleCalendar(ctx, client, action, &opts.calendar, g.json, meetingArtifacts(sess, authConfig, cacheNamespace))
+
+	case "contacts":
+		return handleContacts(ctx, client, action, &opts.contacts, g.json)
+
+	case "people":
+		return handlePeople(ctx, client, action, &opts.people, g.json)
+
+	case "settings":
+		return handleSettings(ctx, client, action, &opts.settings, g.json)
+
+	case "rules":
+		return handleRules(ctx, client, action, &opts.rules, g.json)
+
+	case "categories":
+		return handleCategories(ctx, client, action, &opts.categories, g.json)
+
+	case "subscribe":
+		return handleSubscribe(ctx, client, action, &opts.subscribe, g.json)
+
+	case "snippets":
+		return handleSnippets(ctx, client, action, &opts.snippets, g.json)
 
 	default:
-		return fmt.Errorf("unknown group %q — valid groups: mail, calendar", *group)
+		return fmt.Errorf("unknown group %q — valid groups: %s", group, strings.Join(groups, ", "))
 	}
 }
 
@@ -120,102 +348,491 @@ func loadEnv() {
 	_ = godotenv.Load()
 }
 
+// ── commands ──────────────────────────────────────────────────────────────────
+
+// groups are the first word of every command.
+var groups = []string{"mail", "calendar", "contacts", "people", "settings", "rules", "categories", "subscribe", "snippets", "signature", "schema", "devtools", "auth"}
+
+// globalFlags apply to every command, so every action accepts them.
+var globalFlags = map[string]bool{
+	"json": true, "describe": true, "serve": true, "config": true, "profile": true, "timezone": true,
+	"user": true, "mailbox": true, "auth": true, "token-store": true, "tenant": true,
+	"cache": true, "stats": true, "log-level": true, "log-format": true, "max-retries": true, "timeout": true,
+}
+
+// splitCommand separates the words of a command line, such as "mail list",
+// from its flags. The value of a flag written "--name value" stays with it.
+func splitCommand(fs *flag.FlagSet, args []string) (words, flags []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(words, args[i+1:]...), flags
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			words = append(words, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") || i+1 == len(args) {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		i++
+		flags = append(flags, args[i])
+	}
+	return words, flags
+}
+
+// findAction returns the manifest's entry for an action, or nil when the
+// group has no such action.
+func findAction(group, action string) *manifestpkg.Action {
+	for _, a := range manifestpkg.Actions(manifest, group) {
+		if a.Name == action {
+			return a
+		}
+	}
+	return nil
+}
+
+// actionFlagSet returns the FlagSet of an action, unbound, to read its flags'
+// types, defaults, and help text from.
+func actionFlagSet(group, action string) *flag.FlagSet {
+	return newFlagSet(group, action, &globalOptions{}, &commandOptions{}, false)
+}
+
+// actionFlags returns the flags the manifest lists for an action, and false
+// when the group has no such action.
+func actionFlags(group, action string) (map[string]bool, bool) {
+	a := findAction(group, action)
+	if a == nil {
+		return nil, false
+	}
+	flags := map[string]bool{}
+	for _, name := range a.Flags {
+		flags[name] = true
+	}
+	return flags, true
+}
+
+// selectCommand sets --group and --action from the command words, and checks
+// that every flag given belongs to that action or to every command, so a flag
+// meant for another action is not quietly ignored. A command given with
+// --group and --action is run as before, unchecked.
+func selectCommand(words []string, given map[string]bool, group, action *string) error {
+	if len(words) == 0 {
+		return nil
+	}
+	if given["group"] || given["action"] {
+		return fmt.Errorf("give the command as words, such as `outlook-assistant mail list`, or with --group and --action, not both")
+	}
+	if !slices.Contains(groups, words[0]) {
+		return fmt.Errorf("unknown command %q — commands start with one of: %s", words[0], strings.Join(groups, ", "))
+	}
+	if len(words) > 2 {
+		return fmt.Errorf("unexpected argument %q — flags are written --name=value", words[2])
+	}
+	*group, *action = words[0], ""
+	if len(words) == 1 {
+		return nil
+	}
+	*action = words[1]
+	flags, ok := actionFlags(*group, *action)
+	if !ok {
+		return fmt.Errorf("unknown %s action %q — run `outlook-assistant %s --help` for the list", *group, *action, *group)
+	}
+	names := make([]string, 0, len(given))
+	for name := range given {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !globalFlags[name] && !flags[name] {
+			return fmt.Errorf("--%s is not a flag of %s %s — run `outlook-assistant %s %s --help` for its flags", name, *group, *action, *group, *action)
+		}
+	}
+	return nil
+}
+
+// printHelp prints the help for a group, "mail", or an action, "mail list":
+// its entry in the usage text and, for an action, the flags it takes.
+func printHelp(words []string) error {
+	switch {
+	case len(words) == 0:
+		printUsage()
+		return nil
+	case !slices.Contains(groups, words[0]):
+		return fmt.Errorf("unknown command %q — commands start with one of: %s", words[0], strings.Join(groups, ", "))
+	case len(words) == 1:
+		fmt.Fprintf(os.Stderr, "Usage: outlook-assistant %s <action> [flags]\n\n%s\n", words[0], manifestpkg.UsageSection(usage, words[0]))
+		fmt.Fprintf(os.Stderr, "Run `outlook-assistant %s <action> --help` for an action's flags, and `outlook-assistant help` for the flags every command takes.\n", words[0])
+		return nil
+	}
+	group, action := words[0], words[1]
+	flags, ok := actionFlags(group, action)
+	if !ok {
+		return fmt.Errorf("unknown %s action %q — run `outlook-assistant %s --help` for the list", group, action, group)
+	}
+	fmt.Fprintf(os.Stderr, "Usage: outlook-assistant %s %s [flags]\n\n", group, action)
+	if entry := manifestpkg.UsageEntries(usage, group)[action]; entry != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", entry)
+	}
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	fs := actionFlagSet(group, action)
+	for _, name := range names {
+		if f := fs.Lookup(name); f != nil {
+			fmt.Fprintf(os.Stderr, "  --%-18s %s\n", name, f.Usage)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "\nEvery command also takes --mailbox, --auth, --tenant, --config, --profile, --timezone, --cache, --stats, --log-level, --log-format, --max-retries, and --timeout; see `outlook-assistant help`.")
+	return nil
+}
+
 // ── mail ──────────────────────────────────────────────────────────────────────
 
-func handleMail(
-	ctx context.Context,
-	client *msgraphsdkgo.GraphServiceClient,
-	action, ref, query string,
-	jsonOut bool,
-	count, page int,
-	since, before, from string,
-	unread bool,
-	folder, subject string,
-	to, cc, bcc, body, format, set string,
-) error {
-	bodyFmt := mail.ParseBodyFormat(format)
+func handleMail(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *mailOptions, jsonOut bool) error {
+	var minBytes, maxBytes int64
+	if o.minSize != "" {
+		var err error
+		if minBytes, err = mail.ParseSize(o.minSize); err != nil {
+			return fmt.Errorf("--min-size: %w", err)
+		}
+	}
+	if o.maxSize != "" {
+		var err error
+		if maxBytes, err = mail.ParseSize(o.maxSize); err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+		if maxBytes < minBytes {
+			return fmt.Errorf("--max-size is smaller than --min-size")
+		}
+	}
+
 	switch action {
-	case "list":
+	case "list", "sweep":
 		opts := mail.ListOptions{
-			Since:      since,
-			Before:     before,
-			From:       from,
-			UnreadOnly: unread,
-			Folder:     folder,
-			Subject:    subject,
+			Since:          o.since,
+			Before:         o.before,
+			From:           o.from,
+			To:             o.to,
+			CcMe:           o.ccMe,
+			Domain:         o.domain,
+			UnreadOnly:     o.unread,
+			Flagged:        o.flagged,
+			Importance:     o.importance,
+			Folder:         o.folder,
+			Subject:        o.subject,
+			HasAttachments: o.hasAttachments,
+			MinSize:        minBytes,
+			MaxSize:        maxBytes,
+			Out:            o.out,
+			MarkRead:       o.markRead,
+			NewerThan:      o.newerThan,
+			OlderThan:      o.olderThan,
+		}
+		switch {
+		case o.focused && o.other:
+			return fmt.Errorf("use either --focused or --other, not both")
+		case o.focused:
+			opts.Classification = mail.Focused
+		case o.other:
+			opts.Classification = mail.Other
 		}
-		return mail.List(ctx, client, int32(count), page, opts, jsonOut)
+		if action == "sweep" {
+			return mail.Sweep(ctx, client, mail.SweepOptions{
+				ListOptions: opts, Apply: o.apply, ToFolder: o.toFolder, Set: o.set, DryRun: o.dryRun, Confirm: o.confirm,
+			}, jsonOut)
+		}
+		return mail.List(ctx, client, int32(o.count), o.page, opts, jsonOut)
+
+	case "export-folder":
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return mail.ExportFolder(ctx, client, mail.ArchiveOptions{Folder: o.folder, Mbox: o.out, Maildir: o.maildir, Concurrency: o.concurrency}, jsonOut)
+
+	case "largest":
+		return mail.Largest(ctx, client, o.folder, o.count, minBytes, jsonOut)
+
+	case "overview":
+		return mail.Overview(ctx, client, jsonOut)
+
+	case "triage-interactive":
+		return mail.TriageInteractive(ctx, client, o.folder, int32(o.count), jsonOut)
+
+	case "watch":
+		if o.interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return mail.Watch(ctx, client, mail.WatchOptions{Folder: o.folder, Interval: o.interval, Since: o.since, Once: o.once}, jsonOut)
 
 	case "read":
-		if ref == "" {
+		if o.ref == "" {
 			return fmt.Errorf("--ref is required for mail read")
 		}
-		return mail.Read(ctx, client, ref, jsonOut)
+		if o.raw {
+			if jsonOut {
+				return fmt.Errorf("--raw prints the MIME message as it is and cannot be combined with --json")
+			}
+			return mail.ReadRaw(ctx, client, o.ref)
+		}
+		markdown, err := markdownOutput(o.as)
+		if err != nil {
+			return err
+		}
+		return mail.Read(ctx, client, o.ref, mail.ReadOptions{Clean: o.clean, SplitQuotes: o.splitQuotes, StripQuotes: o.stripQuotes, Markdown: markdown, SaveDir: o.saveDir, SaveImages: o.saveImages}, jsonOut)
+
+	case "attachments":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail attachments")
+		}
+		return mail.Attachments(ctx, client, o.ref, o.saveDir, jsonOut)
+
+	case "export":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail export")
+		}
+		return mail.Export(ctx, client, o.ref, o.format, o.out, jsonOut)
+
+	case "thread":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail thread")
+		}
+		markdown, err := markdownOutput(o.as)
+		if err != nil {
+			return err
+		}
+		return mail.Thread(ctx, client, o.ref, mail.ReadOptions{Clean: o.clean, SplitQuotes: o.splitQuotes, StripQuotes: o.stripQuotes, Unique: !o.full, Markdown: markdown}, jsonOut)
 
 	case "send":
-		if to == "" || subject == "" {
+		if o.to == "" || o.subject == "" {
 			return fmt.Errorf("--to and --subject are required for mail send")
 		}
-		return mail.Send(ctx, client, to, cc, bcc, subject, body, bodyFmt)
+		body, format, err := withSnippet(ctx, client, o.snippet, o.vars, "", o.body, o.format)
+		if err != nil {
+			return err
+		}
+		body, format, err = withSignature(body, bodyFormat(body, format), o.signature, o.noSignature)
+		if err != nil {
+			return err
+		}
+		to, cc, bcc, err := mail.CheckRecipients(ctx, client, o.to, o.cc, o.bcc, o.strict)
+		if err != nil {
+			return err
+		}
+		return mail.Deliver(ctx, client, mail.Outgoing{
+			Action: "send", To: to, Cc: cc, Bcc: bcc, Subject: o.subject, Body: body, Format: format, Attach: o.attach,
+			Importance: o.importance, Sensitivity: o.sensitivity,
+		}, o.queue)
 
-	case "reply":
-		if ref == "" {
-			return fmt.Errorf("--ref is required for mail reply")
+	case "reply", "reply-all":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail %s", action)
+		}
+		body, format, err := withSnippet(ctx, client, o.snippet, o.vars, o.ref, o.body, o.format)
+		if err != nil {
+			return err
 		}
 		if body == "" {
-			return fmt.Errorf("--body is required for mail reply")
+			return fmt.Errorf("--body or --snippet is required for mail %s", action)
+		}
+		body, format, err = withSignature(body, bodyFormat(body, format), o.signature, o.noSignature)
+		if err != nil {
+			return err
 		}
-		return mail.Reply(ctx, client, ref, body, bodyFmt)
+		return mail.Deliver(ctx, client, mail.Outgoing{
+			Action: action, MessageID: o.ref, Body: body, Format: format,
+			Importance: o.importance, Sensitivity: o.sensitivity,
+		}, o.queue)
 
 	case "forward":
-		if ref == "" {
+		if o.ref == "" {
 			return fmt.Errorf("--ref is required for mail forward")
 		}
-		if to == "" {
+		if o.to == "" {
 			return fmt.Errorf("--to is required for mail forward")
 		}
-		return mail.Forward(ctx, client, ref, to, cc, bcc, body, bodyFmt)
+		to, cc, bcc, err := mail.CheckRecipients(ctx, client, o.to, o.cc, o.bcc, o.strict)
+		if err != nil {
+			return err
+		}
+		body, format, err := withSignature(o.body, bodyFormat(o.body, o.format), o.signature, o.noSignature)
+		if err != nil {
+			return err
+		}
+		return mail.Deliver(ctx, client, mail.Outgoing{
+			Action: "forward", MessageID: o.ref, To: to, Cc: cc, Bcc: bcc, Body: body, Format: format,
+			Importance: o.importance, Sensitivity: o.sensitivity,
+		}, o.queue)
+
+	case "validate":
+		if o.to == "" && o.cc == "" && o.bcc == "" {
+			return fmt.Errorf("--to, --cc, or --bcc is required for mail validate")
+		}
+		return mail.Validate(ctx, client, o.to, o.cc, o.bcc, o.strict, jsonOut)
+
+	case "needs-reply":
+		return mail.NeedsReply(ctx, client, o.since, jsonOut)
+
+	case "awaiting-response":
+		return mail.AwaitingResponses(ctx, client, o.since, o.olderThan, jsonOut)
+
+	case "outbox-list":
+		return mail.Outbox(jsonOut)
+
+	case "outbox-flush":
+		return mail.FlushOutbox(ctx, client)
 
 	case "search":
-		if query == "" {
+		if o.query == "" {
 			return fmt.Errorf("--query is required for mail search")
 		}
-		opts := mail.SearchOptions{Since: since, Before: before}
-		return mail.Search(ctx, client, query, int32(count), opts, jsonOut)
+		opts := mail.SearchOptions{Since: o.since, Before: o.before, Importance: o.importance, Out: o.out, NewerThan: o.newerThan, OlderThan: o.olderThan}
+		return mail.Search(ctx, client, o.query, int32(o.count), opts, jsonOut)
 
 	case "archive":
-		if ref == "" {
+		if o.ref == "" {
 			return fmt.Errorf("--ref is required for mail archive")
 		}
-		return mail.Archive(ctx, client, ref)
+		return mail.Archive(ctx, client, o.ref)
 
 	case "move":
-		if ref == "" || folder == "" {
+		// --folder has no default here: a message must not land in the
+		// inbox because the destination was left out.
+		if o.folder == "" {
+			return fmt.Errorf("--folder is required for mail move")
+		}
+		if o.conversation != "" {
+			return mail.MoveConversation(ctx, client, o.conversation, o.folder, o.addRule)
+		}
+		if o.ref == "" {
 			return fmt.Errorf("--ref and --folder are required for mail move")
 		}
-		return mail.Move(ctx, client, ref, folder)
+		return mail.Move(ctx, client, o.ref, o.folder)
 
 	case "categorize":
-		if ref == "" {
+		if o.ref == "" {
 			return fmt.Errorf("--ref is required for mail categorize")
 		}
-		return mail.Categorize(ctx, client, ref, set)
+		if o.add != "" || o.remove != "" {
+			if o.set != "" {
+				return fmt.Errorf("--set replaces every category; give it or --add and --remove, not both")
+			}
+			return mail.UpdateCategories(ctx, client, o.ref, o.add, o.remove)
+		}
+		return mail.Categorize(ctx, client, o.ref, o.set)
+
+	case "flag":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail flag")
+		}
+		status := mail.FlagSet
+		switch {
+		case o.complete && o.clearFlag:
+			return fmt.Errorf("use either --complete or --clear, not both")
+		case o.complete:
+			status = mail.FlagComplete
+		case o.clearFlag:
+			status = mail.FlagClear
+		}
+		return mail.SetFlag(ctx, client, o.ref, status, o.due)
+
+	case "classify":
+		if o.ref == "" || o.tab == "" {
+			return fmt.Errorf("--ref and --as=focused|other are required for mail classify")
+		}
+		return mail.Classify(ctx, client, o.ref, o.tab)
 
 	case "markread":
-		if ref == "" {
-			return fmt.Errorf("--ref is required for mail markread")
+		if o.conversation != "" {
+			return mail.MarkConversationRead(ctx, client, o.conversation, !o.unread)
+		}
+		if o.ref == "" {
+			return fmt.Errorf("--ref or --conversation is required for mail markread")
 		}
-		return mail.MarkRead(ctx, client, ref, !unread)
+		return mail.MarkRead(ctx, client, o.ref, !o.unread)
 
 	case "delete":
-		if ref == "" {
+		if o.ref == "" {
 			return fmt.Errorf("--ref is required for mail delete")
 		}
-		return mail.Delete(ctx, client, ref)
+		return mail.Delete(ctx, client, o.ref, o.permanent)
+
+	case "restore":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail restore")
+		}
+		return mail.Restore(ctx, client, o.ref, o.folder)
+
+	case "empty":
+		return mail.Empty(ctx, client, o.folder, o.yes, jsonOut)
+
+	case "authcheck":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail authcheck")
+		}
+		return mail.AuthCheck(ctx, client, o.ref, jsonOut)
+
+	case "recall":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for mail recall")
+		}
+		return mail.Recall(ctx, client, o.ref, jsonOut)
 
 	case "folders":
+		if o.tree {
+			return mail.FolderTree(ctx, client, jsonOut)
+		}
 		return mail.Folders(ctx, client, jsonOut)
 
+	case "rules-test":
+		if o.rule == "" {
+			return fmt.Errorf("--rule is required for mail rules-test")
+		}
+		return mail.TestRule(ctx, client, o.rule, o.folder, int32(o.count), jsonOut)
+
+	case "searchfolder-create":
+		if o.name == "" || o.filter == "" {
+			return fmt.Errorf("--name and --filter are required for mail searchfolder-create")
+		}
+		return mail.CreateSearchFolder(ctx, client, o.name, o.filter, o.folder, jsonOut)
+
+	case "searchfolder-list":
+		return mail.SearchFolders(ctx, client, jsonOut)
+
+	case "searchfolder-delete":
+		if o.name == "" {
+			return fmt.Errorf("--name is required for mail searchfolder-delete")
+		}
+		return mail.DeleteSearchFolder(ctx, client, o.name)
+
+	case "blocklist-add":
+		if o.address == "" {
+			return fmt.Errorf("--address is required for mail blocklist-add")
+		}
+		return mail.BlocklistAdd(ctx, client, o.address, o.safe)
+
+	case "blocklist-remove":
+		if o.address == "" {
+			return fmt.Errorf("--address is required for mail blocklist-remove")
+		}
+		return mail.BlocklistRemove(ctx, client, o.address, o.safe)
+
+	case "blocklist-list":
+		return mail.Blocklist(ctx, client, jsonOut)
+
 	default:
 		return fmt.Errorf("unknown mail action %q", action)
 	}
@@ -223,82 +840,1349 @@ func handleMail(
 
 // ── calendar ──────────────────────────────────────────────────────────────────
 
-func handleCalendar(
-	ctx context.Context,
-	client *msgraphsdkgo.GraphServiceClient,
-	action string,
-	jsonOut bool,
-	count int,
-	since, before string,
-	title, start, end, location, attendees string,
-) error {
+func handleCalendar(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *calendarOptions, jsonOut bool, artifacts calendar.ArtifactSource) error {
 	switch action {
 	case "list":
-		return calendar.List(ctx, client, int32(count), since, before, jsonOut)
+		return calendar.List(ctx, client, int32(o.count), o.since, o.before, o.newerThan, o.olderThan, o.expand, jsonOut)
 
 	case "create":
-		if title == "" || start == "" || end == "" {
-			return fmt.Errorf("--title, --start, and --end are required for calendar create")
+		if o.title == "" || o.start == "" {
+			return fmt.Errorf("--title and --start are required for calendar create, with --end or --duration unless --start gives a range")
+		}
+		attendees, err := resolveAttendees(ctx, client, o.attendees)
+		if err != nil {
+			return err
+		}
+		optional, err := resolveAttendees(ctx, client, o.optional)
+		if err != nil {
+			return err
+		}
+		return calendar.Create(ctx, client, o.title, o.start, o.end, o.duration, o.location, attendees, optional, o.showAs, o.reminder, o.address, o.room, o.coordinates, o.attach, o.force, jsonOut)
+
+	case "read":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for calendar read")
+		}
+		return calendar.Read(ctx, client, o.ref, o.out, jsonOut)
+
+	case "update":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for calendar update")
+		}
+		attendees, err := resolveAttendees(ctx, client, o.attendees)
+		if err != nil {
+			return err
+		}
+		optional, err := resolveAttendees(ctx, client, o.optional)
+		if err != nil {
+			return err
+		}
+		return calendar.Update(ctx, client, o.ref, o.title, o.start, o.end, o.duration, o.location, attendees, optional, o.showAs, o.reminder, o.attach, jsonOut)
+
+	case "delete":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for calendar delete")
 		}
-		return calendar.Create(ctx, client, title, start, end, location, attendees, jsonOut)
+		return calendar.Delete(ctx, client, o.ref, o.body)
+
+	case "respond":
+		if o.ref == "" || o.response == "" {
+			return fmt.Errorf("--ref and --response are required for calendar respond")
+		}
+		return calendar.Respond(ctx, client, o.ref, o.response, o.comment, o.sendResponse)
+
+	case "import-bulk":
+		return calendar.ImportBulk(ctx, client, o.file, jsonOut)
+
+	case "import":
+		return calendar.ImportICS(ctx, client, o.file, jsonOut)
+
+	case "rsvps":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for calendar rsvps")
+		}
+		return calendar.RSVPs(ctx, client, o.ref, jsonOut)
+
+	case "meeting-info":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for calendar meeting-info")
+		}
+		return calendar.MeetingInfo(ctx, client, artifacts, o.ref, jsonOut)
+
+	case "export":
+		if o.out != "" {
+			if o.csv || o.file != "" {
+				return fmt.Errorf("--out writes an iCalendar file; leave out --csv and --file")
+			}
+			return calendar.ExportICS(ctx, client, o.ref, o.since, o.before, o.out, jsonOut)
+		}
+		if o.ref != "" {
+			return fmt.Errorf("--ref exports one event as iCalendar; give --out=<file.ics>")
+		}
+		if o.csv == jsonOut {
+			return fmt.Errorf("calendar export needs exactly one of --csv or --json, or --out=<file.ics>")
+		}
+		return calendar.Export(ctx, client, o.since, o.before, o.include, o.file, jsonOut)
+
+	case "find-uid":
+		return calendar.FindUID(ctx, client, o.uid, jsonOut)
+
+	case "week":
+		return calendar.Week(ctx, client, o.start, o.since, jsonOut)
+
+	case "month":
+		return calendar.Month(ctx, client, o.month, o.start, jsonOut)
 
 	default:
 		return fmt.Errorf("unknown calendar action %q", action)
 	}
 }
 
+// resolveAttendees checks --attendees like mail recipients, so a name such as
+// "Sarah" becomes her address. Attendees may be separated by ';' as well.
+func resolveAttendees(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, attendees string) (string, error) {
+	if attendees == "" {
+		return "", nil
+	}
+	resolved, _, _, err := mail.CheckRecipients(ctx, client, strings.ReplaceAll(attendees, ";", ","), "", "", false)
+	return resolved, err
+}
+
+// withSnippet replaces body with the expanded --snippet, which is Markdown.
+// ref is the message being replied to, for the placeholders describing it.
+func withSnippet(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, snippet, vars, ref, body, format string) (string, string, error) {
+	if snippet == "" {
+		return body, format, nil
+	}
+	if body != "" {
+		return "", "", fmt.Errorf("use either --body or --snippet, not both")
+	}
+	text, err := mail.ExpandSnippet(ctx, client, snippet, vars, ref)
+	if err != nil {
+		return "", "", err
+	}
+	return text, "md", nil
+}
+
+// bodyFormat is the --format to send body in: the one given, or else md when
+// the body looks like Markdown and text otherwise.
+func bodyFormat(body, format string) string {
+	if format != "" {
+		return format
+	}
+	if mail.LooksLikeMarkdown(body) {
+		return "md"
+	}
+	return "text"
+}
+
+// markdownOutput reports whether --as asks for bodies as Markdown.
+func markdownOutput(as string) (bool, error) {
+	switch strings.ToLower(as) {
+	case "", "text":
+		return false, nil
+	case "markdown", "md":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown --as %q — valid values: text, markdown", as)
+}
+
+// readBody returns the body given with --body, reading it from stdin for
+// --body=-, or from --body-file. The final line break is dropped.
+func readBody(body, bodyFile string, fromServer bool) (string, error) {
+	var data []byte
+	var err error
+	switch {
+	case bodyFile != "" && body != "":
+		return "", fmt.Errorf("use either --body or --body-file, not both")
+	case bodyFile != "":
+		if data, err = os.ReadFile(bodyFile); err != nil {
+			return "", fmt.Errorf("--body-file: %w", err)
+		}
+	case body == "-":
+		if fromServer {
+			return "", fmt.Errorf("--body=- reads stdin, which carries a server's requests; pass the text in --body")
+		}
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return "", fmt.Errorf("reading the body from stdin: %w", err)
+		}
+	default:
+		return body, nil
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// withSignature appends the signature to body and returns it with its
+// format: --signature, usually from the config file, in the body's format,
+// or else the one saved with `signature set`. A forward without a comment
+// gets none.
+func withSignature(body, format, signature string, noSignature bool) (string, string, error) {
+	if body == "" || noSignature {
+		return body, format, nil
+	}
+	sig := mail.Signature{Body: signature, Format: format}
+	if signature == "" {
+		var err error
+		if sig, err = mail.LoadSignature(); err != nil {
+			return "", "", err
+		}
+	}
+	body, format = mail.AppendSignature(body, format, sig)
+	return body, format, nil
+}
+
+// ── contacts ──────────────────────────────────────────────────────────────────
+
+func handleContacts(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *contactsOptions, jsonOut bool) error {
+	spec := contacts.ContactSpec{Name: o.name, Emails: o.email, Phone: o.phone, Company: o.company}
+	switch action {
+	case "list":
+		return contacts.List(ctx, client, int32(o.count), jsonOut)
+
+	case "search":
+		return contacts.Search(ctx, client, o.query, o.count, jsonOut)
+
+	case "create":
+		return contacts.Create(ctx, client, spec, jsonOut)
+
+	case "update":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for contacts update")
+		}
+		return contacts.Update(ctx, client, o.ref, spec, jsonOut)
+
+	case "delete":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for contacts delete")
+		}
+		return contacts.Delete(ctx, client, o.ref)
+
+	case "dedupe":
+		return contacts.Dedupe(ctx, client, o.merge, o.dryRun, jsonOut)
+
+	case "export":
+		return contacts.Export(ctx, client, o.out, o.vcardVersion)
+
+	case "import":
+		return contacts.Import(ctx, client, o.file, jsonOut)
+
+	case "photo":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for contacts photo")
+		}
+		return contacts.Photo(ctx, client, o.ref, o.out, o.photo, jsonOut)
+
+	default:
+		return fmt.Errorf("unknown contacts action %q", action)
+	}
+}
+
+// ── people ────────────────────────────────────────────────────────────────────
+
+func handlePeople(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *peopleOptions, jsonOut bool) error {
+	switch action {
+	case "expand":
+		return people.Expand(ctx, client, o.list, o.recursive, jsonOut)
+
+	default:
+		return fmt.Errorf("unknown people action %q", action)
+	}
+}
+
+// ── settings ──────────────────────────────────────────────────────────────────
+
+func handleSettings(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *settingsOptions, jsonOut bool) error {
+	switch action {
+	case "junk":
+		return mail.Junk(ctx, client, mail.JunkUpdate{
+			AddDomains:    o.addDomain,
+			RemoveDomains: o.removeDomain,
+			Safe:          o.safe,
+			TrustContacts: o.trustContacts,
+		}, jsonOut)
+
+	case "autoreply":
+		return mail.AutoReply(ctx, client, mail.AutoReplyUpdate{
+			Status:          o.status,
+			InternalMessage: o.body,
+			ExternalMessage: o.externalBody,
+			Format:          mail.ParseBodyFormat(o.format),
+			Audience:        o.audience,
+			Start:           o.start,
+			End:             o.end,
+		}, jsonOut)
+
+	case "mailbox":
+		return calendar.ShowSettings(ctx, client, calendar.SettingsUpdate{
+			TimeZone:   o.mailboxTimezone,
+			WorkDays:   o.workDays,
+			WorkHours:  o.workHours,
+			DateFormat: o.dateFormat,
+			TimeFormat: o.timeFormat,
+			Language:   o.language,
+		}, jsonOut)
+
+	default:
+		return fmt.Errorf("unknown settings action %q", action)
+	}
+}
+
+// ── rules ─────────────────────────────────────────────────────────────────────
+
+func handleRules(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *rulesOptions, jsonOut bool) error {
+	switch action {
+	case "list":
+		return mail.Rules(ctx, client, jsonOut)
+
+	case "create":
+		return mail.CreateRule(ctx, client, mail.RuleSpec{
+			Name:            o.name,
+			From:            o.from,
+			SubjectContains: o.subjectContains,
+			HasAttachment:   o.hasAttachment,
+			MoveTo:          o.moveTo,
+			Categories:      o.set,
+			MarkRead:        o.markRead,
+			ForwardTo:       o.to,
+		}, jsonOut)
+
+	case "delete":
+		return mail.DeleteRule(ctx, client, o.rule)
+
+	case "enable", "disable":
+		return mail.EnableRule(ctx, client, o.rule, action == "enable")
+
+	default:
+		return fmt.Errorf("unknown rules action %q", action)
+	}
+}
+
+// ── categories ────────────────────────────────────────────────────────────────
+
+func handleCategories(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *categoriesOptions, jsonOut bool) error {
+	switch action {
+	case "list":
+		return mail.Categories(ctx, client, jsonOut)
+
+	case "create":
+		return mail.CreateCategory(ctx, client, o.name, o.color, jsonOut)
+
+	case "rename":
+		return mail.RenameCategory(ctx, client, o.name, o.newName, o.color, jsonOut)
+
+	case "delete":
+		return mail.DeleteCategory(ctx, client, o.name)
+
+	default:
+		return fmt.Errorf("unknown categories action %q", action)
+	}
+}
+
+// ── subscribe ─────────────────────────────────────────────────────────────────
+
+func handleSubscribe(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *subscribeOptions, jsonOut bool) error {
+	switch action {
+	case "create":
+		opts := subscribe.CreateOptions{Resource: o.resource, ChangeTypes: o.changeType, NotificationURL: o.notificationURL, Expiry: o.expires}
+		if o.resource == "mail" && o.folder != "" {
+			var err error
+			if opts.FolderID, err = mail.FolderID(ctx, client, o.folder); err != nil {
+				return err
+			}
+		}
+		return subscribe.Create(ctx, client, opts, jsonOut)
+
+	case "list":
+		return subscribe.List(ctx, client, jsonOut)
+
+	case "renew":
+		return subscribe.Renew(ctx, client, o.ref, o.expires, jsonOut)
+
+	case "delete":
+		if o.ref == "" {
+			return fmt.Errorf("--ref is required for subscribe delete")
+		}
+		return subscribe.Delete(ctx, client, o.ref)
+
+	case "listen":
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return subscribe.Listen(ctx, client, subscribe.ListenOptions{Addr: o.listen, CertFile: o.tlsCert, KeyFile: o.tlsKey, Forward: o.forward}, jsonOut)
+
+	default:
+		return fmt.Errorf("unknown subscribe action %q", action)
+	}
+}
+
+// ── snippets ──────────────────────────────────────────────────────────────────
+
+func handleSnippets(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, action string, o *snippetsOptions, jsonOut bool) error {
+	switch action {
+	case "add":
+		if o.name == "" {
+			return fmt.Errorf("--name is required for snippets add")
+		}
+		body := o.body
+		if o.file != "" {
+			data, err := os.ReadFile(o.file)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", o.file, err)
+			}
+			body = string(data)
+		}
+		if body == "" {
+			return fmt.Errorf("--body or --file is required for snippets add")
+		}
+		return mail.AddSnippet(o.name, body)
+
+	case "list":
+		return mail.Snippets(jsonOut)
+
+	case "use":
+		if o.name == "" {
+			return fmt.Errorf("--name is required for snippets use")
+		}
+		return mail.UseSnippet(ctx, client, o.name, o.vars, o.ref, jsonOut)
+
+	case "remove":
+		if o.name == "" {
+			return fmt.Errorf("--name is required for snippets remove")
+		}
+		return mail.RemoveSnippet(o.name)
+
+	default:
+		return fmt.Errorf("unknown snippets action %q", action)
+	}
+}
+
+// ── signature ─────────────────────────────────────────────────────────────────
+
+// handleSignature serves the signature group, which keeps the saved signature
+// in a local file and needs no sign-in. configured is the signature setting
+// of the config file, which takes its place when set.
+func handleSignature(action string, o *signatureOptions, jsonOut bool, configured string) error {
+	switch action {
+	case "set":
+		if o.body == "" {
+			return fmt.Errorf("--body or --body-file is required for signature set")
+		}
+		return mail.SetSignature(o.body, o.format)
+
+	case "show":
+		if configured != "" {
+			slog.Warn("signature in the config file is appended instead of the saved signature")
+		}
+		return mail.ShowSignature(jsonOut)
+
+	case "clear":
+		return mail.ClearSignature()
+
+	default:
+		return fmt.Errorf("unknown signature action %q", action)
+	}
+}
+
+// ── auth ──────────────────────────────────────────────────────────────────────
+
+func handleAuth(cfg auth.Config, action string, jsonOut bool) error {
+	switch action {
+	case "status":
+		return auth.PrintStatus(cfg, jsonOut)
+
+	default:
+		return fmt.Errorf("unknown auth action %q", action)
+	}
+}
+
+// outputTypes are the JSON payloads described by the schema group, in the
+// order `schema list` shows them.
+var outputTypes = []struct {
+	Name    string
+	Value   interface{}
+	Actions string
+}{
+	{"MessageList", mail.MessageList{}, "mail list"},
+	{"MessageSummary", mail.MessageSummary{}, "mail search (one per array element)"},
+	{"MessageDetail", mail.MessageDetail{}, "mail read; mail thread (one per array element)"},
+	{"AttachmentInfo", mail.AttachmentInfo{}, "mail attachments (one per array element)"},
+	{"ExportedMessage", mail.ExportedMessage{}, "mail export"},
+	{"ArchiveSummary", mail.ArchiveSummary{}, "mail export-folder"},
+	{"SweepSummary", mail.SweepSummary{}, "mail sweep"},
+	{"EmptySummary", mail.EmptySummary{}, "mail empty"},
+	{"FolderSummary", mail.FolderSummary{}, "mail folders (one per array element)"},
+	{"FolderNode", mail.FolderNode{}, "mail folders --tree (one per array element)"},
+	{"FolderFootprint", mail.FolderFootprint{}, "mail largest"},
+	{"MailboxOverview", mail.MailboxOverview{}, "mail overview"},
+	{"ExportManifest", mail.ExportManifest{}, "mail list/search --out --json"},
+	{"ResultExport", mail.ResultExport{}, "the file written by mail list/search --out"},
+	{"RecipientCheck", mail.RecipientCheck{}, "mail validate (one per array element)"},
+	{"TriageSummary", mail.TriageSummary{}, "mail triage-interactive"},
+	{"WatchEvent", mail.WatchEvent{}, "mail watch --json (one per line)"},
+	{"EventSummary", calendar.EventSummary{}, "calendar list, find-uid (one per array element)"},
+	{"EventDetail", calendar.EventDetail{}, "calendar read"},
+	{"RSVPReport", calendar.RSVPReport{}, "calendar rsvps"},
+	{"EventCreated", calendar.EventCreated{}, "calendar create, update"},
+	{"ConflictReport", calendar.ConflictReport{}, "calendar create, when the event overlaps others"},
+	{"WeekView", calendar.WeekView{}, "calendar week"},
+	{"MonthView", calendar.MonthView{}, "calendar month"},
+	{"AutoReplySettings", mail.AutoReplySettings{}, "settings autoreply"},
+	{"MailboxSettings", calendar.MailboxSettings{}, "settings mailbox"},
+	{"Signature", mail.Signature{}, "signature show"},
+	{"RuleSummary", mail.RuleSummary{}, "rules list (one per array element), rules create"},
+	{"CategorySummary", mail.CategorySummary{}, "categories list (one per array element), categories create, rename"},
+	{"SubscriptionSummary", subscribe.SubscriptionSummary{}, "subscribe list, renew (one per array element); subscribe create"},
+	{"Notification", subscribe.Notification{}, "subscribe listen --json (one per line), and the body posted to --forward"},
+	{"ContactSummary", contacts.ContactSummary{}, "contacts list, search (one per array element); contacts create, update"},
+	{"Expansion", people.Expansion{}, "people expand"},
+	{"AuthStatus", auth.Status{}, "auth status"},
+}
+
+// handleSchema serves the schema group, which needs no sign-in.
+func handleSchema(action string, o *schemaOptions, jsonOut bool) error {
+	switch action {
+	case "list":
+		if jsonOut {
+			type entry struct {
+				Name    string `json:"name"`
+				Actions string `json:"actions"`
+			}
+			entries := []entry{}
+			for _, t := range outputTypes {
+				entries = append(entries, entry{t.Name, t.Actions})
+			}
+			return schema.Encode(os.Stdout, struct {
+				Entries []entry `json:"types"`
+			}{entries})
+		}
+		fmt.Printf("Schema version %d\n\n", schema.Version)
+		for _, t := range outputTypes {
+			fmt.Printf("  %-16s %s\n", t.Name, t.Actions)
+		}
+		return nil
+
+	case "show":
+		// A JSON Schema document is printed as is: it describes a payload
+		// rather than being one, so it carries no schemaVersion of its own.
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if o.name == "" {
+			all := map[string]interface{}{}
+			for _, t := range outputTypes {
+				all[t.Name] = schema.For(t.Name, t.Value)
+			}
+			return enc.Encode(all)
+		}
+		for _, t := range outputTypes {
+			if strings.EqualFold(t.Name, o.name) {
+				return enc.Encode(schema.For(t.Name, t.Value))
+			}
+		}
+		return fmt.Errorf("unknown schema %q — run `outlook-assistant schema list` for the names", o.name)
+
+	default:
+		return fmt.Errorf("unknown schema action %q", action)
+	}
+}
+
+// handleDevtools serves the devtools group, which needs no sign-in.
+func handleDevtools(action string, o *devtoolsOptions, jsonOut bool) error {
+	switch action {
+	case "mock-server":
+		server := mockgraph.New()
+		server.Log = func(r mockgraph.Request) {
+			slog.Debug("Mock Graph request", "method", r.Method, "path", r.Path, "status", r.Status)
+		}
+		url, err := server.Start(o.listen)
+		if err != nil {
+			return fmt.Errorf("starting mock server: %w", err)
+		}
+		defer server.Close()
+
+		if jsonOut {
+			if err := schema.Encode(os.Stdout, struct {
+				URL string `json:"url"`
+				Env string `json:"env"`
+			}{url, auth.GraphURLEnv}); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("export %s=%s\n", auth.GraphURLEnv, url)
+		}
+		slog.Info("Mock Graph server running — press Ctrl+C to stop", "url", url)
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		slog.Info("Mock Graph server stopped", "requests", len(server.Requests()))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown devtools action %q", action)
+	}
+}
+
+// ── serve ─────────────────────────────────────────────────────────────────────
+
+// mcpGroups are the groups whose actions --serve=mcp offers as tools.
+var mcpGroups = []string{"mail", "calendar"}
+
+// handleServe runs the tool as a server on stdio. Each call runs in this
+// process as a command line of its own, so it behaves exactly as on the
+// command line, while the Graph client, with its sign-in and connections, is
+// kept between calls. Flags given alongside --serve, such as --mailbox or
+// --auth, are passed to every call; fs holds them.
+func handleServe(ctx context.Context, mode string, fs *flag.FlagSet, given map[string]bool) error {
+	// Flags given with --serve apply to every call, so only those every
+	// command takes are allowed; the config file supplies the rest per call.
+	var common []string
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch {
+		case f.Name == "serve" || !given[f.Name]:
+		case !globalFlags[f.Name]:
+			err = fmt.Errorf("--%s cannot be given with --serve; pass it per call or set it in the config file", f.Name)
+		default:
+			common = append(common, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	sess := &session{clients: map[string]sessionClient{}}
+	runner := func(ctx context.Context, args []string) ([]byte, []byte, error) {
+		return capture(func() error {
+			return run(ctx, append(append([]string{}, common...), args...), sess)
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch mode {
+	case "mcp":
+		tools, err := mcp.Catalog(manifest, usage, actionFlagSet, mcpGroups...)
+		if err != nil {
+			return fmt.Errorf("building the MCP tool list: %w", err)
+		}
+		version := ""
+		for _, line := range strings.Split(manifest, "\n") {
+			if v, ok := strings.CutPrefix(line, "version:"); ok {
+				version = strings.TrimSpace(v)
+				break
+			}
+		}
+		server := &mcp.Server{
+			Name:    "outlook-assistant",
+			Version: version,
+			Tools:   tools,
+			Run:     runner,
+		}
+		slog.Info("MCP server running on stdio", "tools", len(tools))
+		return server.Serve(ctx, os.Stdin, os.Stdout)
+
+	case "jsonrpc":
+		longRunning := map[string]bool{}
+		for _, group := range groups {
+			for _, a := range manifestpkg.Actions(manifest, group) {
+				if a.LongRunning {
+					longRunning[group+"."+a.Name] = a.Takes("once")
+				}
+			}
+		}
+		server := &daemon.Server{Run: runner, LongRunning: longRunning}
+		slog.Info("JSON-RPC server running on stdio")
+		return server.Serve(ctx, os.Stdin, os.Stdout)
+
+	default:
+		return fmt.Errorf("unknown --serve mode %q (use mcp or jsonrpc)", mode)
+	}
+}
+
+// meetingArtifacts reads recordings and transcripts for calendar meeting-info
+// with a client that also asks for auth.ArtifactScopes. They need admin
+// consent, so they are only asked for there, and a missing consent is
+// explained in the meeting's notes rather than failing the command.
+func meetingArtifacts(sess *session, cfg auth.Config, cacheNamespace string) calendar.ArtifactSource {
+	cfg.Scopes = append(append([]string{}, cfg.Scopes...), auth.ArtifactScopes...)
+	return calendar.ArtifactSource{
+		Client: func() (*msgraphsdkgo.GraphServiceClient, error) {
+			client, _, err := sess.graphClient(cfg, cacheNamespace)
+			return client, err
+		},
+		Help: func(err error, permission string) string {
+			if !auth.ConsentMissing(err) {
+				return ""
+			}
+			return auth.ConsentHelp(cfg, permission)
+		},
+	}
+}
+
+// session is what a server keeps between the commands it runs: a Graph
+// client per sign-in configuration, so each signs in and opens its
+// connections once.
+type session struct {
+	clients map[string]sessionClient
+}
+
+type sessionClient struct {
+	client   *msgraphsdkgo.GraphServiceClient
+	recorder *stats.Recorder
+}
+
+// graphClient returns a client for cfg, caching responses under
+// cacheNamespace when it is set, and the recorder counting its requests. A
+// nil session builds a new client every time.
+func (s *session) graphClient(cfg auth.Config, cacheNamespace string) (*msgraphsdkgo.GraphServiceClient, *stats.Recorder, error) {
+	key := fmt.Sprintf("%+v|%s", cfg, cacheNamespace)
+	if s != nil {
+		if c, ok := s.clients[key]; ok {
+			c.recorder.Reset()
+			return c.client, c.recorder, nil
+		}
+	}
+
+	recorder := stats.New()
+	middleware := []khttp.Middleware{recorder}
+	if cacheNamespace != "" {
+		// Ahead of the recorder, so --stats shows the bytes actually transferred.
+		middleware = append([]khttp.Middleware{httpcache.New(httpcache.DefaultDir(), cacheNamespace)}, middleware...)
+	}
+	slog.Debug("Authenticating with Microsoft")
+	client, err := auth.NewGraphClient(cfg, middleware...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s != nil {
+		s.clients[key] = sessionClient{client: client, recorder: recorder}
+	}
+	return client, recorder, nil
+}
+
+// capture runs fn with stdout and stderr redirected and stdin at the null
+// device, since a server's own stdio carries the protocol, and returns what
+// fn printed. What fn prints to stderr, such as a device code to sign in
+// with, is also passed through to the server's stderr.
+func capture(fn func() error) (stdout, stderr []byte, err error) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer null.Close()
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		outR.Close()
+		outW.Close()
+		return nil, nil, err
+	}
+
+	stdin, out, errOut, logger := os.Stdin, os.Stdout, os.Stderr, slog.Default()
+	var outBuf, errBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(&outBuf, outR)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(io.MultiWriter(&errBuf, errOut), errR)
+	}()
+
+	func() {
+		defer func() {
+			os.Stdin, os.Stdout, os.Stderr = stdin, out, errOut
+			slog.SetDefault(logger)
+		}()
+		os.Stdin, os.Stdout, os.Stderr = null, outW, errW
+		err = fn()
+	}()
+	outW.Close()
+	errW.Close()
+	wg.Wait()
+	outR.Close()
+	errR.Close()
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// permissionFor returns the Graph permission a command needs. Application and
+// delegated permissions share these names.
+func permissionFor(group, action string) string {
+	switch group {
+	case "calendar":
+		return "Calendars.ReadWrite"
+	case "contacts":
+		return "Contacts.ReadWrite"
+	case "people":
+		return "GroupMember.Read.All"
+	case "settings", "rules", "categories":
+		return "MailboxSettings.ReadWrite"
+	}
+	switch action {
+	case "send", "reply", "reply-all", "forward":
+		return "Mail.Send"
+	case "validate":
+		return "User.ReadBasic.All"
+	case "rules-test", "blocklist-add", "blocklist-remove", "blocklist-list":
+		return "MailboxSettings.ReadWrite"
+	default:
+		return "Mail.ReadWrite"
+	}
+}
+
+// ── logging ───────────────────────────────────────────────────────────────────
+
+// setupLogging installs the logger for status messages on stderr. Results
+// still go to stdout, so --log-format=json gives a second, separate stream.
+func setupLogging(level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown --log-level %q — valid levels: debug, info, warn, error", level)
+	}
+	switch format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown --log-format %q — valid formats: text, json", format)
+	}
+	slog.SetDefault(newLogger(l, format))
+	return nil
+}
+
+func newLogger(level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	// A terminal reader doesn't need a timestamp on every line.
+	opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
 // ── usage ─────────────────────────────────────────────────────────────────────
 
-func printUsage() {
-	fmt.Fprint(os.Stderr, `
+// usage is the --help text. The MCP server describes each action with its
+// entry here.
+const usage = `
 Outlook Assistant — Microsoft Graph mail & calendar CLI.
 
 All flags are named; no positional arguments. Designed for agent and pipeline use.
 
-REQUIRED FLAGS (always)
-  --group=<mail|calendar>    Command group
-  --action=<action>          Action to perform (see below)
+USAGE
+  outlook-assistant <group> <action> [--flag=value ...]
+  group: mail | calendar | contacts | people | settings | rules | categories |
+         subscribe | snippets | signature | schema | devtools | auth
+
+  Each action takes only its own flags, listed below, and the global flags in
+  NOTES; any other flag is an error. outlook-assistant <group> --help lists a
+  group's actions, and outlook-assistant <group> <action> --help (or help
+  <group> <action>) an action's flags. --group=<group> --action=<action> still
+  works, unchecked, but is deprecated.
+
+  --describe prints the tool manifest (every action and parameter) and exits.
+  --serve=mcp and --serve=jsonrpc run a server on stdio instead (see NOTES).
+  Only actions that call Graph sign in: --describe, snippets add/list/remove,
+  snippets use without --ref, signature, mail outbox-list, schema, devtools, and
+  auth status run offline.
 
 MAIL ACTIONS
   list        List messages
               --folder=inbox --n=20 --page=1 --since=YYYY-MM-DD --before=YYYY-MM-DD
-              --from=email --subject=text --unread --json
+              --from=email --subject=text --unread --flagged --min-size=5MB --json
+              [--importance=low|normal|high] [--focused | --other]
+              [--to=email] [--cc-me] [--domain=vendor.com]
+              [--has-attachments] [--max-size=100KB]
+              --to, --cc-me, and --domain match the To line, you on the Cc line,
+              and senders at a domain (also on sweep).
+              --has-attachments, --min-size, and --max-size keep messages with
+              file attachments and within a size range (also on sweep).
+              --newer-than=7d / --older-than=3w stand in for --since / --before
+              (ages: h, d, w, mo); also on search and calendar list.
+              --since / --before also take 7d, today, yesterday, tomorrow, and
+              "last monday" or "next fri", for mail and calendar alike.
+              Each message shows its size; JSON also has hasAttachments.
+              --mark-read marks the unread messages shown as read afterwards,
+              so a listing doubles as acknowledgment (not with --out).
+              --out=<file.json> follows every page and writes all results, with a
+              manifest (query, filters, timestamps, counts), to the file.
 
   read        Read a message body
-              --ref=<index|id> --json
+              --ref=<index|id> [--clean] [--strip-quotes] [--split-quotes]
+              [--as=text|markdown] [--save-dir=<dir>] [--save-images=<dir>] --json
+              --ref=<index|id> --raw
+              --as=markdown turns an HTML body into Markdown, keeping links,
+              lists, headings, and tables, instead of plain text.
+              --save-dir downloads the attachments and lists them with the message.
+              --save-images downloads the inline images (screenshots, charts)
+              and points the body at them: ![alt](<file>) with --as=markdown,
+              an [image: <file>] line in text.
+              --ref=1,3,5-9 reads several messages, fetched in one $batch request;
+              --json then prints an array.
+              --raw prints the raw MIME message, internet headers included.
+
+  attachments List a message's attachments, and download them with --save-dir
+              --ref=<index|id> [--save-dir=<dir>] --json
+              Files keep their names; attached Outlook items are saved as .eml.
+
+  export      Save a message as an .eml file (its raw MIME, attachments included)
+              --ref=<index|id> [--format=eml] [--out=<file.eml>] --json
+              --out defaults to the subject, in the current directory.
+
+  export-folder  Archive every message in a folder as an mbox file or a maildir
+              --folder=inbox --out=<file.mbox> [--concurrency=4] --json
+              --folder=inbox --maildir=<dir> [--concurrency=4] --json
+              Downloads each message's raw MIME, --concurrency at a time.
+              Progress is logged beside the archive (<file.mbox>.progress, or
+              .outlook-assistant-progress in the maildir): an interrupted
+              export resumes where it stopped, and a rerun adds only new mail.
+
+  thread      Read every message in a message's conversation, oldest first
+              --ref=<index|id> [--full] [--clean] [--strip-quotes] [--split-quotes]
+              [--as=text|markdown] --json
+              Indexes are cached, so --ref=<#> then picks one of them.
+              Each body is only the text that message added (Exchange's
+              uniqueBody); --full shows whole bodies, quoted history included.
+              --clean keeps only each message's new text: quoted history,
+              signatures, and legal disclaimers are removed.
+              --strip-quotes removes only the quoted history ("On … wrote:",
+              Outlook's From:/Sent: block, trailing > lines).
+              --split-quotes adds newContent and quotedContent to the JSON,
+              the full body split where the quoted history begins.
 
   send        Send a new message
               --to=<email,...> --subject=<text> --body=<text>
-              --cc=<email,...> --bcc=<email,...>
+              --cc=<email,...> --bcc=<email,...> [--attach=<file,...>]
+              Files up to 3 MB in all go with the message; larger ones are
+              uploaded in slices, resuming where an interrupted upload stopped.
 
   reply       Reply to a message
               --ref=<index|id> --body=<text>
+  reply-all   Reply to the sender and everyone else on To and CC
+              --ref=<index|id> --body=<text>
+
+  Instead of --body, send, reply, and reply-all take --snippet=<name> [--vars="key=value;..."]
+  to use a saved snippet (see SNIPPETS ACTIONS). send, reply, reply-all, and
+  forward read the body from stdin with --body=-, or from a file with
+  --body-file=<path>, so a long Markdown body needs no shell quoting.
+  --format=text|md|html sets how the body is rendered; md is CommonMark with
+  GitHub tables, task lists, strikethrough, and bare links. Without it a body
+  with Markdown headings, lists, quotes, code, tables, bold text, or links is
+  sent as md, and any other as text.
+  --importance=low|normal|high and --sensitivity=personal|private|confidential
+  mark a message sent with send, reply, reply-all, or forward, as Outlook's
+  Importance and Sensitivity options do.
 
   forward     Forward a message to new recipients
               --ref=<index|id> --to=<email,...> [--cc=<email,...>] [--bcc=<email,...>] [--body=<text>]
+  validate    Check recipients without sending
+              --to=<email|name,...> [--cc=...] [--bcc=...] [--strict] --json
+
+  send and forward check recipients first, as do calendar create and update for
+  --attendees. Names are looked up among the people you work with, ranked by
+  relevance, then in the directory and your contacts. Malformed addresses, and
+  names that do not match exactly one person, always fail, listing the likely
+  people; names that do are replaced by that person's address. Likely domain
+  typos ("gamil.com") are warnings, or failures with --strict.
+
+  Add --queue to send, reply, reply-all, or forward to keep the message in a
+  local outbox when the network or sign-in fails, instead of losing it.
+  outbox-list   List queued messages      --json
+  outbox-flush  Retry every queued message
+
+  needs-reply List received messages that are probably waiting on your reply
+              [--since=7d|YYYY-MM-DD] --json   (default: 7d; oldest first)
+              Addressed to you in To, asking a question or making a request,
+              from a person, with nothing sent by you later in the thread.
+              Indexes are cached, so mail reply --ref=<#> answers one.
+
+  awaiting-response  List messages you sent that nobody has answered
+              [--older-than=3d] [--since=30d|YYYY-MM-DD] --json   (oldest first)
+              Your latest message in each thread, sent to someone else,
+              with no later message from anyone else in any folder.
+              Indexes are cached, so mail read --ref=<#> shows one.
 
   search      Search messages
               --query=<text> --n=20 --since=YYYY-MM-DD --before=YYYY-MM-DD --json
+              [--newer-than=7d] [--older-than=3w] [--out=<file.json>]   (all pages to a file, as for list)
+              [--importance=low|normal|high]
+
+  triage-interactive  Step through unread messages one at a time
+              --folder=inbox --n=20 --json   (newest first)
+              Keys: a archive, r reply, f flag, s snooze, d delete (to Deleted
+              Items), k or Enter skip, q quit. Each decision is appended to
+              ~/.outlook-assistant-triage.jsonl as an audit log.
+
+  watch       Print new, changed, and removed messages in a folder as they happen
+              --folder=inbox [--interval=1m] [--since=<date|age>] [--once] --json
+              Polls with a Graph delta query and prints one line per change,
+              NDJSON with --json, until interrupted. The position is saved in
+              ~/.outlook-assistant-watch.json, so the next run resumes there.
+              The first run watches mail received from now on, or since --since,
+              which also restarts a saved watch. --once polls once and exits.
 
   archive     Archive a message         --ref=<index|id>
   move        Move to folder            --ref=<index|id> --folder=<name>
+                                        --conversation=<index|id> moves the whole thread;
+                                        add --add-rule to also file future replies there
   categorize  Set categories            --ref=<index|id> --set=<cat1,cat2,...>
+                                        --ref=<index|id> [--add=<cat,...>] [--remove=<cat,...>]
+              --set replaces every category; --add and --remove keep the others.
+  flag        Flag for follow-up        --ref=<index|id> [--due=YYYY-MM-DD]
+                                        --ref=<index|id> --complete | --clear
+  classify    Move to Focused or Other  --ref=<index|id> --as=focused|other
+              Also adds an override, so later mail from the sender goes to
+              the same tab of the Focused Inbox.
   markread    Mark read/unread          --ref=<index|id> [--unread]
-  delete      Delete a message          --ref=<index|id>
-  folders     List all mail folders     --json
+                                        --conversation=<index|id> marks the whole thread
+  delete      Delete a message          --ref=<index|id> [--permanent]
+              The message goes to Recoverable Items, where restore finds it;
+              --permanent purges it, and nothing can bring it back.
+  restore     Undo a delete             --ref=<index|id> [--folder=inbox]
+              Moves a message listed with --folder=deleteditems, or with
+              --folder=recoverableitemsdeletions after delete, back to --folder.
+              archive, move, categorize, flag, markread, delete, and restore also
+              take several indexes and ranges, --ref=1,3,5-9, sent as one $batch request.
+  sweep       Archive, move, delete, mark read, or categorize every message
+              that matches list filters, across all pages
+              --apply=archive|move|delete|markread|categorize --folder=inbox
+              --from=email --subject=text --unread --since --before
+              --newer-than --older-than --flagged --importance --focused --other
+              --to --cc-me --domain --has-attachments
+              --min-size --max-size   (at least one filter)
+              [--to-folder=<name>] (move) [--set=<cat1,...>] (categorize)
+              --dry-run | --confirm=<count> --json
+              --dry-run lists the matching messages and caches their indexes.
+              --confirm gives the count the dry run showed; a sweep that
+              matches more messages changes none.
+  empty       Delete every message in Deleted Items or Junk Email
+              --folder=deleteditems|junkemail --yes --json
+              Deleted in $batch requests, with progress on stderr; as in
+              Outlook, they go to Recoverable Items or Deleted Items.
+  recall      Recall a sent message     --ref=<index|id> --json
+              (beta endpoint; only recipients in your organization who have
+              not opened it; results arrive as a "Message Recall Report" email)
+  authcheck   Check a message's SPF, DKIM, and DMARC results for phishing triage
+              --ref=<index|id> --json
+              Reads the Authentication-Results, Received-SPF, and DKIM-Signature
+              headers and warns about failed checks, misaligned domains, and
+              Reply-To, Sender, or display-name addresses pointing elsewhere.
+  folders     List all mail folders     [--tree] --json
+  overview    Mailbox dashboard: inbox unread (focused and other), flagged, and
+              unread/total for every top-level folder, in one request   --json
+  largest     Largest messages in a folder, biggest first, with the folder's total size
+              --folder=inbox --n=20 [--min-size=1MB] --json
+              Only messages of at least --min-size (default 1MB) are scanned.
+
+  rules-test  Dry-run an inbox rule against recent mail (nothing is changed)
+              --rule=<id|file.json> --folder=inbox --n=200 --json
+
+  searchfolder-create   Create a saved server-side search
+              --name=<text> --filter=<OData filter> [--folder=<source,...>] --json
+              (default source: inbox, including subfolders)
+  searchfolder-list     List search folders            --json
+  searchfolder-delete   Delete a search folder         --name=<name|id>
+  Search folders can then be listed like any folder: mail list --folder=<name>
+
+  blocklist-add         Block senders (mail moves to Junk Email)
+              --address=<email,...> [--safe adds to the safe list instead]
+  blocklist-remove      Remove senders from a list     --address=<email,...> [--safe]
+  blocklist-list        Show blocked and safe senders  --json
+  Both lists are stored as inbox rules, so they apply server-side.
 
 CALENDAR ACTIONS
-  list        List events in a date range
+  list        List events in a date range and cache their indexes for --ref
               --n=20 --since=YYYY-MM-DD --before=YYYY-MM-DD --json
-              (default: 30 days ago → 30 days ahead)
+              [--newer-than=2w] [--older-than=1mo] [--expand=occurrences|masters]
+              (default: 30 days ago → 30 days ahead, each occurrence listed;
+              masters lists each recurring series once with its recurrence rule)
   create      Create an event
               --title=<text> --start="2006-01-02 15:04" --end="2006-01-02 15:04"
               --location=<text> --attendees=<email,...> --json
+              [--optional-attendees=<email,...>]
+              [--duration=30m]   (in place of --end)
+              --start and --end also take phrases: "tomorrow 2pm", "fri at 9:30am",
+              "next tuesday 09:00"; --start="next tuesday 09:00-09:30" gives the
+              end too, and --end=15:00 alone is on the start's day.
+              [--show-as=busy|free|tentative|oof|workingElsewhere] (default: busy)
+              [--reminder=15m|1h|1d|none] (default: Outlook's)
+              [--force]   (create it even though it overlaps busy time)
+              [--address="street, city, state, postal code, country"]
+              [--coordinates=<lat,lon>] [--room=<room email>] [--attach=<file,...>]
+              (--location may list several, separated by ';')
+  read        Show an event's details, attendees and their responses, body, and attachments
+              --ref=<index|id> [--out=<dir>] --json   (index from the last calendar list)
+              --out saves file attachments to that directory
+  rsvps       Show who has accepted, tentatively accepted, or declined a meeting, and who
+              has not answered
+              --ref=<index|id> --json   (tracked on meetings you organize)
+  update      Change an event; only the flags given are changed
+              --ref=<index|id> [--title] [--start] [--end] [--duration] [--location]
+              [--show-as] [--reminder] [--attendees=<email,...>]
+              [--optional-attendees=<email,...>] [--attach=<file,...>] --json
+              (a new --start alone keeps the event's length; --location takes several
+              separated by ';'; --attendees replaces the required attendees and
+              --optional-attendees the optional ones, keeping booked rooms; --attach
+              adds to the existing attachments)
+  delete      Delete an event, or cancel a meeting you organize
+              --ref=<index|id> [--body=<cancellation message>]
+              (attendees of a cancelled meeting are notified, with --body if given)
+  respond     Answer a meeting invitation
+              --ref=<index|id> --response=accept|decline|tentative [--comment=<text>]
+              [--send-response=false]   (=false updates your calendar without replying)
+  import-bulk Create one event per row of a CSV or JSON file
+              --file=events.csv|events.json --json
+              Columns: title, start, end, attendees, location, recurrence
+              recurrence: daily|weekdays|weekly|monthly[;interval=N][;count=N|;until=YYYY-MM-DD]
+              Bad rows are reported and skipped; the rest are still created.
+  import      Create an event from each VEVENT in an iCalendar (.ics) file
+              --file=invite.ics --json
+              Times keep their time zone; recurrence rules, attendees, location,
+              and description are carried over. Attendees are sent invitations.
+  export      Write one flat row per event for time-tracking and utilization reports
+              --csv|--json --since=YYYY-MM-DD --before=YYYY-MM-DD
+              [--include=attendees,categories] [--file=<path>] (default: stdout)
+              Recurring meetings are expanded; times are UTC.
+              Or write an iCalendar (.ics) file for another calendar:
+              --ref=<index|id> --out=<file.ics> | --since=... --before=... --out=<file.ics>
+              --out=- writes to stdout. Recurring series are written once,
+              with their recurrence rule; attendees keep their responses.
+  meeting-info  Show an online meeting's join link, dial-in numbers, conference
+              ID, lobby settings, and any recordings and transcripts
+              --ref=<index|id> --json   (index from the last calendar list)
+  find-uid    Find events by the iCalUId other calendars and .ics files use
+              --uid=<iCalUId> --json   (caches indexes for --ref like list)
+  week        Show a week as a seven-column grid
+              [--start=monday] [--since=YYYY-MM-DD] --json
+              (default: the current week, starting on the first working day;
+              times are local, and "~" marks events outside working hours)
+  month       Show a month grid of busy hours and event counts per day
+              [--month=YYYY-MM] [--start=monday] --json
+              (default: this month; days are shaded by busy hours)
+
+CONTACTS ACTIONS
+  list        List contacts             --n=20 --json
+  search      Find contacts by name, company, email, or phone
+              --query=<text> --n=20 --json   (caches indexes for --ref like list)
+  create      Add a contact             --name=<full name> [--email=<email,...>]
+              [--phone=<mobile>] [--company=<name>] --json   (cached as index 1)
+  update      Change a contact; only the flags given are changed
+              --ref=<index|id> [--name] [--email] [--phone] [--company] --json
+              (--email replaces the contact's addresses)
+  delete      Delete a contact          --ref=<index|id>
+  dedupe      Find duplicate contacts (same email, phone, or name)
+              [--merge] [--dry-run] --json
+              --merge keeps the most complete contact in each group with the
+              union of all fields and deletes the rest; --dry-run previews it.
+  export      Write all contacts as vCards
+              [--out=contacts.vcf] [--vcard-version=3.0|4.0] (default: stdout, 3.0)
+  import      Create a contact from each vCard (3.0 or 4.0) in a file
+              --file=cards.vcf --json
+  photo       Show, download, or replace a contact's photo
+              --ref=<index|id> [--out=photo.jpg | --set=<jpeg|png path>] --json
+
+PEOPLE ACTIONS
+  expand      List the members of a distribution list or Microsoft 365 group
+              --list=<email|name|id> [--recursive] --json
+              --recursive expands nested groups into the people who would
+              receive a message. Dynamic distribution groups are not in the
+              directory and cannot be expanded.
+
+SETTINGS ACTIONS
+  junk        View the junk mail configuration (blocked/safe senders and domains)
+              [--add-domain=<domain,...>] [--remove-domain=<domain,...>] [--safe] --json
+              Domains are matched as @domain by the same server-side inbox rules
+              as blocklist-*. The "trust contacts" option is not available via Graph;
+              --trust-contacts=on|off reports an error saying so.
+  autoreply   View or change the automatic replies (out of office)
+              [--status=off|on|scheduled] [--start=<local time>] [--end=<local time>]
+              [--body=<internal reply>] [--external-body=<external reply>]
+              [--format=text|md|html] [--audience=none|contacts|all] --json
+              --start/--end imply --status=scheduled; flags not given are kept.
+  mailbox     View or change the time zone, working hours, formats, and language
+              [--mailbox-timezone=<IANA|Windows name>] [--work-days=mon-fri]
+              [--work-hours=09:00-17:30] [--date-format=dd.MM.yyyy]
+              [--time-format=HH:mm] [--language=en-US] --json
+              Saved locally too: calendar commands take their time zone and
+              calendar week/month their first day and working hours from it.
+
+RULES ACTIONS
+  list        List inbox rules in the order they run, with conditions and actions   --json
+  create      Add an inbox rule after the existing ones, enabled
+              --name=<text> and at least one condition and one action:
+              conditions (all must match): [--from=<email,...>]
+              [--subject-contains=<word,...>] [--has-attachment]
+              actions: [--move-to=<folder>] [--set=<cat1,cat2,...>] [--mark-read]
+              [--to=<email,...>] (forward) --json
+  delete      Delete an inbox rule      --rule=<name|id>
+  enable      Turn an inbox rule on     --rule=<name|id>
+  disable     Turn an inbox rule off, keeping it   --rule=<name|id>
+  Rules run server-side on arriving mail. Try one first with mail rules-test.
+
+CATEGORIES ACTIONS
+  list        List the master category list, with each category's color   --json
+  create      Add a category to the master list
+              --name=<text> [--color=red|orange|...|darkcranberry|preset0-24|none] --json
+              (default color: the first preset no other category uses)
+  rename      Rename a category, keeping its color unless --color is given
+              --name=<name|id> --new-name=<text> [--color=<color>] --json
+  delete      Remove a category from the master list   --name=<name|id>
+  Only names in the master list are shown with a color; mail categorize
+  applies any name. Messages keep a renamed or deleted category's old name.
+
+SUBSCRIBE ACTIONS
+  create      Ask Graph to post mail or calendar changes to a URL, such as a tunnel
+              to subscribe listen. Graph checks the URL answers before it agrees.
+              --resource=mail|calendar --notification-url=<https url>
+              [--folder=<name>] (mail; --folder= watches every folder)
+              [--change-type=created,updated,deleted] [--expires=72h] --json
+  list        List the subscriptions of the signed-in app and when they expire   --json
+  renew       Extend a subscription, or every saved one without --ref
+              [--ref=<index|id>] [--expires=72h] --json
+  delete      Delete a subscription     --ref=<index|id>
+  listen      Receive notifications on a local port until Ctrl+C: print each as a
+              line, or NDJSON with --json, or POST it to --forward. Subscriptions
+              close to expiry are renewed while it runs.
+              [--listen=127.0.0.1:8765] [--tls-cert=<file> --tls-key=<file>]
+              [--forward=<webhook url>] --json
+  Graph posts only to public HTTPS URLs: serve --tls-cert/--tls-key on a public
+  host, or run a tunnel (ngrok http 8765, cloudflared) to the listen port and
+  give its URL as --notification-url. A notification carries the item's ID,
+  not its content; read it with --ref=<id>. Subscriptions and their client
+  state secrets are kept in ~/.outlook-assistant-subscriptions.json; listen
+  ignores notifications without a matching secret.
+
+SNIPPETS ACTIONS
+  add         Save a named canned response (Markdown), replacing any with that name
+              --name=<name> --body=<markdown> | --file=<file.md>
+  list        List saved snippets and their placeholders   --json
+  use         Print a snippet with its placeholders filled in (nothing is sent)
+              --name=<name> [--vars="key=value;..."] [--ref=<index|id>] --json
+  remove      Delete a snippet          --name=<name>
+  Placeholders are written {{name}}. {{today}} and {{weekday}} are built in;
+  with --ref (or on reply) {{sender}}, {{firstName}}, {{senderEmail}} and
+  {{subject}} come from that message. Any other placeholder needs --vars, and
+  a placeholder left without a value is an error. Stored in
+  ~/.outlook-assistant-snippets.json.
+
+SIGNATURE ACTIONS
+  set         Save the signature appended to mail send, reply, reply-all, and
+              forward, replacing any saved one
+              --body=<text> | --body-file=<file> [--format=text|md|html]
+              Without --format, text that starts with a tag is HTML, and any
+              other Markdown.
+  show        Print the saved signature   --json
+  clear       Remove the saved signature
+  A signature in the body's format is joined after a blank line; otherwise
+  both are rendered to HTML. --no-signature leaves it off one message, and
+  --signature=<text> (or signature: in the config file) replaces it. Stored
+  in ~/.outlook-assistant-signature.json.
+
+SCHEMA ACTIONS
+  list        List the JSON output types and the actions that print them   --json
+  show        Print the JSON Schema (draft 2020-12) for one type, or for all
+              [--name=<type>]
+  Every --json payload carries "schemaVersion"; in a bare array, such as mail
+  search, each element carries it. The version goes up only when a field is
+  removed, renamed, or changes type.
+
+DEVTOOLS ACTIONS
+  mock-server Serve canned Graph mail, calendar, and contacts data on a local port until
+              Ctrl+C, and print the export line that points commands at it
+              [--listen=127.0.0.1:8765] --json
+  Data is reset on every start; sends, moves, and edits last until it stops.
+
+AUTH ACTIONS
+  status      Show the token store in use and the signed-in account (no sign-in)
+              [--token-store=...] [--tenant=...] --json
 
 NOTES
   --json outputs structured JSON to stdout; all status messages go to stderr.
+  Message and event bodies garbled by a wrong legacy charset (Windows-1252,
+          ISO-8859-x, Shift-JIS) are repaired before they are shown.
+  --log-level=<debug|info|warn|error> drops status messages below the level
+          (default: info; error keeps only the final error).
+  --log-format=<text|json> writes status messages as key=value text (default)
+          or as one JSON object per line with time, level, msg, and attributes.
+  --cache stores GET responses that carry an ETag (a message, event, or contact)
+          in ~/.outlook-assistant-cache and revalidates them with If-None-Match,
+          and the folder list with a delta query; unchanged data is served
+          locally. Contains message content.
+  --stats prints Graph request statistics to stderr when the command finishes
+          (as a single JSON line when combined with --json).
+  --max-retries=<n> re-sends a request Graph throttled (429) or failed with a
+          transient 5xx up to n times (default 5; 0 turns it off), waiting as
+          Retry-After asks or backing off exponentially with jitter. Sends
+          and other POSTs are retried only after 429 or 503, never twice.
+          Requests throttled inside a $batch are sent again in a new batch.
+  --timeout=<duration> gives up on the command after e.g. 30s or 5m, waits
+          between retries included (default: no limit).
+  --tenant=<id|domain> overrides TENANT_ID for one invocation. Each tenant keeps
+          its own cached sign-in (~/.outlook-assistant-auth.<tenant>.json).
+  --auth=device-code signs in without a local browser, for SSH sessions and CI:
+          it prints a URL and code to stderr, to be entered on any device, and
+          waits. The sign-in is cached as with the browser.
+  --auth=managed-identity authenticates app-only as the Azure managed identity of
+          the host (VM, Function, container) — no secrets, no browser. Set
+          MANAGED_IDENTITY_CLIENT_ID for a user-assigned identity. AUTH_MODE in
+          the environment sets the default.
+  --auth=client-credentials authenticates app-only as the app registration, with
+          the certificate in CLIENT_CERT_PATH (PEM or PKCS#12; CLIENT_CERT_PASSWORD
+          if protected) or else CLIENT_SECRET. Needs application permissions.
+  --user=<upn|id> selects whose mailbox, calendar, and settings every request
+          acts on. Required with --auth=managed-identity and client-credentials.
+  --mailbox=<upn|id> is the same as --user. Signed in as yourself, it opens a
+          shared mailbox or one you are a delegate of (needs the .Shared
+          permissions and access granted in Exchange); --ref indexes are cached
+          per mailbox.
+  --token-store=<auto|keychain|file|memory> controls where tokens are cached:
+          auto (default) uses the OS keychain/DPAPI and falls back to memory;
+          keychain fails instead of falling back; file writes an AES-256-GCM
+          encrypted file keyed by OUTLOOK_ASSISTANT_TOKEN_KEY; memory persists nothing.
+  OUTLOOK_ASSISTANT_CLIENT=thin sends mail list, read, and send as plain REST calls
+          decoded into minimal structs instead of the SDK's models; unset or
+          =sdk uses the SDK. Output is the same either way.
+  OUTLOOK_ASSISTANT_GRAPH_URL=<url> sends every request to that Graph endpoint
+          without signing in, and CLIENT_ID and TENANT_ID are not needed; set it
+          to the URL devtools mock-server prints.
+  --serve=mcp (MCP SERVER) reads JSON-RPC requests on stdin and answers on stdout,
+          one per line; status messages stay on stderr. Each mail and calendar
+          action is a tool named <group>_<action> (mail_list, calendar_create),
+          its arguments the action's flags; the result is the action's --json
+          output. Global flags given with --serve, such as --mailbox, apply to every
+          call; others are an error.
+  --serve=jsonrpc reads JSON-RPC requests such as {"jsonrpc":"2.0","id":1,
+          "method":"mail.list","params":{"n":5}}, one per line, for any action
+          but devtools; the result is {"output": <--json output>, "messages":
+          [<status messages>]}. Both servers sign in once and keep the Graph
+          client and its connections for every later call.
+  --config=<file> reads default flag values from a YAML file (default:
+          ~/.outlook-assistant.yaml, if present); keys are flag names without
+          the dashes, such as folder, n, format, mailbox, timezone, signature.
+          A flag given on the command line always wins. --profile=<name> (or a
+          profile: key in the file) adds the settings under profiles.<name>.
+  --timezone=<IANA name> reads and counts local dates (--since, --before,
+          --start/--end of settings autoreply, calendar week/month days) in
+          that zone instead of the system's. Calendar commands default to the
+          mailbox's time zone from its Outlook settings (cached for a day, or
+          until settings mailbox runs): events are created in it and their
+          times shown in it. Mail timestamps stay UTC.
+  --signature=<text> is appended to the body of mail send, reply, reply-all,
+          and forward after a blank line, in the body's --format, in place of
+          the signature saved with signature set; --no-signature leaves off
+          both.
   --ref accepts the index number from the last mail list/search, or a raw Graph ID.
   Well-known folder names: inbox, archive, deleteditems, drafts, sentitems, junkemail.
   Credentials: CLIENT_ID and TENANT_ID must be set in environment or .env file.
-`)
+`
+
+func printUsage() {
+	fmt.Fprint(os.Stderr, usage)
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"outlook-assistant/auth"
+	"outlook-assistant/mail"
+	manifestpkg "outlook-assistant/manifest"
+	"outlook-assistant/mockgraph"
+)
+
+// startMock serves a fresh mock Graph for one test and points the commands
+// at it, with a home directory of their own for the list index and caches.
+func startMock(t *testing.T) *mockgraph.Server {
+	t.Helper()
+	srv := mockgraph.New()
+	url, err := srv.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("starting mock Graph: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv(auth.GraphURLEnv, url)
+	t.Setenv("HOME", t.TempDir())
+	return srv
+}
+
+// runCommand runs one command line as the binary would and returns what it
+// printed to stdout.
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	err = run(context.Background(), args, nil)
+	os.Stdout = stdout
+	w.Close()
+	printed := <-out
+	if err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	return printed
+}
+
+// decode unmarshals a command's --json output into v.
+func decode(t *testing.T, out string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		t.Fatalf("decoding %q: %v", out, err)
+	}
+}
+
+// requested reports whether the mock answered method on path, before any
+// query, with status.
+func requested(srv *mockgraph.Server, method, path string, status int) bool {
+	for _, r := range srv.Requests() {
+		p, _, _ := strings.Cut(r.Path, "?")
+		if r.Method == method && p == path && r.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+type messageList struct {
+	Count    int `json:"count"`
+	Messages []struct {
+		Index   int    `json:"index"`
+		ID      string `json:"id"`
+		Subject string `json:"subject"`
+	} `json:"messages"`
+}
+
+func TestMailListAndRead(t *testing.T) {
+	startMock(t)
+
+	var list messageList
+	decode(t, runCommand(t, "mail", "list", "--json"), &list)
+	if list.Count != 4 || len(list.Messages) != 4 {
+		t.Fatalf("inbox lists %d messages, want 4", len(list.Messages))
+	}
+	index := 0
+	for _, m := range list.Messages {
+		if m.Subject == "Q1 budget review" {
+			index = m.Index
+		}
+	}
+	if index == 0 {
+		t.Fatalf("inbox is missing %q: %+v", "Q1 budget review", list.Messages)
+	}
+
+	var msg struct {
+		ID   string `json:"id"`
+		From string `json:"from"`
+		Body string `json:"body"`
+	}
+	decode(t, runCommand(t, "mail", "read", "--ref="+strconv.Itoa(index), "--json"), &msg)
+	if msg.ID != "mock-msg-1" || msg.From != "dana@contoso.example" || !strings.Contains(msg.Body, "review the attached numbers") {
+		t.Errorf("mail read --ref=%d = %+v", index, msg)
+	}
+}
+
+func TestThinClientOutput(t *testing.T) {
+	startMock(t)
+
+	commands := [][]string{
+		{"mail", "list", "--json"},
+		{"mail", "read", "--ref=1", "--json"},
+		{"mail", "read", "--ref=1", "--clean", "--json"},
+		{"mail", "read", "--ref=1"},
+	}
+	for _, args := range commands {
+		t.Setenv(mail.ClientModeEnv, "sdk")
+		sdk := runCommand(t, args...)
+		t.Setenv(mail.ClientModeEnv, "thin")
+		if thin := runCommand(t, args...); thin != sdk {
+			t.Errorf("%s prints\n%s\nwith the thin client, want\n%s", strings.Join(args, " "), thin, sdk)
+		}
+	}
+}
+
+func TestMailSend(t *testing.T) {
+	srv := startMock(t)
+
+	runCommand(t, "mail", "send", "--to=dana@contoso.example", "--subject=Numbers look good", "--body=Approved.")
+	if !requested(srv, "POST", "/me/sendMail", 202) {
+		t.Fatalf("no sendMail request: %+v", srv.Requests())
+	}
+	var sent messageList
+	decode(t, runCommand(t, "mail", "list", "--folder=sentitems", "--json"), &sent)
+	for _, m := range sent.Messages {
+		if m.Subject == "Numbers look good" {
+			return
+		}
+	}
+	t.Errorf("sent message is not in sentitems: %+v", sent.Messages)
+}
+
+func TestCalendarListAndCreate(t *testing.T) {
+	srv := startMock(t)
+
+	var events []struct {
+		Subject string `json:"subject"`
+	}
+	decode(t, runCommand(t, "calendar", "list", "--since=2026-03-01", "--before=2026-03-08", "--json"), &events)
+	if len(events) != 4 || events[0].Subject != "Weekly team sync" {
+		t.Fatalf("calendar list = %+v, want the 4 fixture events starting with the team sync", events)
+	}
+
+	var created struct {
+		ID      string `json:"id"`
+		Subject string `json:"subject"`
+	}
+	decode(t, runCommand(t, "calendar", "create", "--title=Planning", "--start=2026-03-10 09:00", "--duration=30m", "--json"), &created)
+	if created.ID == "" || created.Subject != "Planning" {
+		t.Fatalf("calendar create = %+v", created)
+	}
+	if !requested(srv, "POST", "/me/events", 201) {
+		t.Errorf("no event created: %+v", srv.Requests())
+	}
+
+	events = nil
+	decode(t, runCommand(t, "calendar", "list", "--since=2026-03-10", "--before=2026-03-11", "--json"), &events)
+	if len(events) != 1 || events[0].Subject != "Planning" {
+		t.Errorf("calendar list after create = %+v", events)
+	}
+}
+
+func TestMailArchiveBatch(t *testing.T) {
+	srv := startMock(t)
+
+	runCommand(t, "mail", "list", "--json")
+	runCommand(t, "mail", "archive", "--ref=1,2")
+	if !requested(srv, "POST", "/$batch", 200) {
+		t.Fatalf("archiving two messages sent no $batch: %+v", srv.Requests())
+	}
+	var inbox messageList
+	decode(t, runCommand(t, "mail", "list", "--json"), &inbox)
+	if len(inbox.Messages) != 2 {
+		t.Errorf("inbox holds %d messages after archiving 2 of 4, want 2", len(inbox.Messages))
+	}
+}
+
+func TestMailArchiveBatchThrottled(t *testing.T) {
+	srv := startMock(t)
+	srv.ThrottleBatchItems = 1
+
+	runCommand(t, "mail", "list", "--json")
+	runCommand(t, "mail", "archive", "--ref=1,2")
+	batches := 0
+	for _, r := range srv.Requests() {
+		if r.Path == "/$batch" {
+			batches++
+		}
+	}
+	if batches != 2 {
+		t.Errorf("sent %d $batch requests, want 2: the batch and the throttled request again", batches)
+	}
+	var inbox messageList
+	decode(t, runCommand(t, "mail", "list", "--json"), &inbox)
+	if len(inbox.Messages) != 2 {
+		t.Errorf("inbox holds %d messages after archiving 2 of 4, want 2", len(inbox.Messages))
+	}
+}
+
+func TestMailThread(t *testing.T) {
+	startMock(t)
+
+	runCommand(t, "mail", "list", "--json")
+	type threadMessage struct {
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+	}
+	var thread []threadMessage
+	decode(t, runCommand(t, "mail", "thread", "--ref=1", "--json"), &thread)
+	if len(thread) != 2 || thread[1].Subject != "RE: Q1 budget review" {
+		t.Fatalf("mail thread = %+v, want the budget review and its reply", thread)
+	}
+	if strings.Contains(thread[1].Body, "Can you review") {
+		t.Errorf("reply repeats the message it quotes: %q", thread[1].Body)
+	}
+
+	thread = nil
+	decode(t, runCommand(t, "mail", "thread", "--ref=1", "--full", "--json"), &thread)
+	if len(thread) != 2 || !strings.Contains(thread[1].Body, "Can you review") {
+		t.Errorf("mail thread --full = %+v, want the reply's whole body", thread)
+	}
+}
+
+func TestFolderListCache(t *testing.T) {
+	srv := startMock(t)
+
+	type folder struct {
+		Name        string `json:"name"`
+		UnreadItems int    `json:"unreadItems"`
+	}
+	unread := func() int {
+		t.Helper()
+		var folders []folder
+		decode(t, runCommand(t, "mail", "folders", "--cache", "--json"), &folders)
+		for _, f := range folders {
+			if f.Name == "Inbox" {
+				return f.UnreadItems
+			}
+		}
+		t.Fatalf("mail folders = %+v, want an Inbox", folders)
+		return 0
+	}
+	listed := func() int {
+		n := 0
+		for _, r := range srv.Requests() {
+			if p, _, _ := strings.Cut(r.Path, "?"); p == "/me/mailFolders" {
+				n++
+			}
+		}
+		return n
+	}
+
+	before := unread()
+	unread()
+	if n := listed(); n != 1 {
+		t.Errorf("folders listed %d times in two runs with nothing changed, want 1", n)
+	}
+
+	runCommand(t, "mail", "list", "--unread", "--json")
+	runCommand(t, "mail", "markread", "--ref=1")
+	if after := unread(); after != before-1 {
+		t.Errorf("Inbox unread = %d after marking a message read, want %d", after, before-1)
+	}
+	if n := listed(); n != 2 {
+		t.Errorf("folders listed %d times, want 2: a change must be fetched again", n)
+	}
+}
+
+// TestActionFlags checks each action's FlagSet against the manifest: it has
+// every flag the action lists, every flag a group defines is taken by one of
+// its actions, and a flag is a switch in every group or in none, as
+// splitCommand reads it before the command is known.
+func TestActionFlags(t *testing.T) {
+	isBool := func(f *flag.Flag) bool {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		return ok && b.IsBoolFlag()
+	}
+	every := newFlagSet("", "", &globalOptions{}, &commandOptions{}, true)
+	for _, group := range groups {
+		taken := map[string]bool{}
+		for _, a := range manifestpkg.Actions(manifest, group) {
+			fs := actionFlagSet(group, a.Name)
+			for _, name := range a.Flags {
+				if fs.Lookup(name) == nil {
+					t.Errorf("%s %s: the manifest lists --%s, which the action does not define", group, a.Name, name)
+				}
+				taken[name] = true
+			}
+		}
+		o := (&commandOptions{}).of(group)
+		if o == nil {
+			continue
+		}
+		own := flag.NewFlagSet(group, flag.ContinueOnError)
+		o.flags(own, "")
+		own.VisitAll(func(f *flag.Flag) {
+			if !taken[f.Name] {
+				t.Errorf("%s defines --%s, which none of its actions takes", group, f.Name)
+			}
+			if isBool(f) != isBool(every.Lookup(f.Name)) {
+				t.Errorf("--%s is a switch in some groups and takes a value in %s", f.Name, group)
+			}
+		})
+	}
+}
+
+func TestMailMoveNeedsFolder(t *testing.T) {
+	startMock(t)
+
+	err := run(context.Background(), []string{"mail", "move", "--ref=1"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "--folder is required") {
+		t.Errorf("mail move without --folder: err = %v, want --folder is required", err)
+	}
+}
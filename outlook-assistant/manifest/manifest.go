@@ -0,0 +1,284 @@
+// Package manifest reads the action list of the tool manifest (tool.yaml)
+// and the --help text: which actions each group has, the flags each action
+// takes, and each action's help entry. The MCP tool list and the flags a
+// subcommand accepts both come from here.
+package manifest
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	flagName     = regexp.MustCompile(`--([a-z][a-z0-9-]*)`)
+	flagValue    = regexp.MustCompile(`^=("[^"]*"|[^\s\])]+)`)
+	choiceList   = regexp.MustCompile(`^[a-z0-9][a-zA-Z0-9.-]*(\|[a-z0-9][a-zA-Z0-9.-]*)*$`)
+	manifestLine = regexp.MustCompile(`^    ([a-z][a-z0-9-]*)(?:\s+(.*))?$`)
+	usageLine    = regexp.MustCompile(`^  ([a-z][a-z0-9-]*)\s+(\S.*)$`)
+)
+
+// Action is one action line of the manifest's usage block, with its
+// continuation lines.
+type Action struct {
+	Name        string
+	Synopsis    string   // the rest of the action line
+	Flags       []string // the flags it takes, sorted, without group and action
+	Interactive bool     // marked "(interactive:", as it needs a terminal
+	LongRunning bool     // marked "(long-running:", as it runs until interrupted
+
+	// Required are the flags written with a <placeholder> value outside
+	// [brackets], which a caller must give.
+	Required []string
+	// Choices holds, for a flag the group writes as a list of choices such as
+	// --format=text|md|html, the values this action writes for it.
+	Choices map[string][]string
+}
+
+// Settable reports whether a default from outside the command line, such as
+// the config file, may set the flag name to value: the action takes the flag,
+// does not require it, and lists value among its choices if it has any.
+func (a *Action) Settable(name, value string) bool {
+	if !a.Takes(name) || slices.Contains(a.Required, name) {
+		return false
+	}
+	choices, ok := a.Choices[name]
+	return !ok || slices.Contains(choices, value)
+}
+
+// Takes reports whether the action takes the flag name.
+func (a *Action) Takes(name string) bool {
+	for _, f := range a.Flags {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Actions reads the "<GROUP> ACTIONS" section of the manifest's usage
+// block. A continuation line that opens with a list of actions, such as
+// "(send, reply, and reply-all take --snippet=<name> ...)", adds its flags to
+// each of them.
+func Actions(manifest, group string) []*Action {
+	var uses []flagUse
+	header := "  " + strings.ToUpper(group) + " ACTIONS"
+	var actions []*Action
+	byName := map[string]*Action{}
+	in := false
+	var current *Action
+	for _, line := range strings.Split(manifest, "\n") {
+		if !in {
+			in = strings.TrimRight(line, " ") == header
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if m := manifestLine.FindStringSubmatch(line); m != nil {
+			current = &Action{Name: m[1], Synopsis: strings.TrimSpace(m[2])}
+			actions = append(actions, current)
+			byName[current.Name] = current
+			addFlags(current, line)
+			uses = append(uses, flagUses(current, line)...)
+			current.Interactive = strings.Contains(line, "(interactive:")
+			current.LongRunning = strings.Contains(line, "(long-running:")
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		targets := []*Action{current}
+		if text := strings.TrimSpace(line); strings.HasPrefix(text, "(") {
+			var named []*Action
+			for _, word := range strings.Fields(strings.TrimPrefix(text, "(")) {
+				word = strings.TrimSuffix(word, ",")
+				if word == "and" {
+					continue
+				}
+				a, ok := byName[word]
+				if !ok {
+					break
+				}
+				named = append(named, a)
+			}
+			if len(named) > 0 {
+				targets = named
+			}
+		}
+		for _, a := range targets {
+			addFlags(a, line)
+			uses = append(uses, flagUses(a, line)...)
+		}
+	}
+	addChoices(uses)
+	return actions
+}
+
+// flagUse is one flag written with a value on an action's line.
+type flagUse struct {
+	action   *Action
+	name     string
+	value    string
+	optional bool // inside [brackets]
+}
+
+// flagUses returns the flags written with a value on line, for a, and adds
+// those a caller must give to a.Required.
+func flagUses(a *Action, line string) []flagUse {
+	var uses []flagUse
+	for _, m := range flagName.FindAllStringSubmatchIndex(line, -1) {
+		v := flagValue.FindStringSubmatch(line[m[1]:])
+		if v == nil {
+			continue
+		}
+		use := flagUse{
+			action:   a,
+			name:     line[m[2]:m[3]],
+			value:    v[1],
+			optional: strings.Count(line[:m[0]], "[") > strings.Count(line[:m[0]], "]"),
+		}
+		if !use.optional && strings.HasPrefix(use.value, "<") && !slices.Contains(a.Required, use.name) {
+			a.Required = append(a.Required, use.name)
+		}
+		uses = append(uses, use)
+	}
+	return uses
+}
+
+// addChoices sets the Choices of each action: a flag written anywhere in the
+// group as choices, a|b, takes only the values an action writes for it, so
+// mail export's --format=eml rules out send's md.
+func addChoices(uses []flagUse) {
+	choiceFlags := map[string]bool{}
+	for _, u := range uses {
+		if strings.Contains(u.value, "|") && choiceList.MatchString(u.value) {
+			choiceFlags[u.name] = true
+		}
+	}
+	for _, u := range uses {
+		if !choiceFlags[u.name] || !choiceList.MatchString(u.value) {
+			continue
+		}
+		if u.action.Choices == nil {
+			u.action.Choices = map[string][]string{}
+		}
+		for _, c := range strings.Split(u.value, "|") {
+			if !slices.Contains(u.action.Choices[u.name], c) {
+				u.action.Choices[u.name] = append(u.action.Choices[u.name], c)
+			}
+		}
+	}
+}
+
+func addFlags(a *Action, line string) {
+	for _, m := range flagName.FindAllStringSubmatch(line, -1) {
+		name := m[1]
+		if name == "group" || name == "action" {
+			continue
+		}
+		seen := false
+		for _, f := range a.Flags {
+			if f == name {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			a.Flags = append(a.Flags, name)
+		}
+	}
+	sort.Strings(a.Flags)
+}
+
+// Params maps each parameter in the manifest to its description.
+func Params(manifest string) map[string]string {
+	params := map[string]string{}
+	name := ""
+	for _, line := range strings.Split(manifest, "\n") {
+		switch text := strings.TrimSpace(line); {
+		case strings.HasPrefix(text, "- name:"):
+			name = strings.TrimSpace(strings.TrimPrefix(text, "- name:"))
+		case strings.HasPrefix(text, "description:") && name != "":
+			desc := strings.TrimSpace(strings.TrimPrefix(text, "description:"))
+			if unquoted, err := strconv.Unquote(desc); err == nil {
+				desc = unquoted
+			}
+			params[name] = desc
+			name = ""
+		}
+	}
+	return params
+}
+
+// UsageSection returns the "<GROUP> ACTIONS" section of the --help text,
+// heading included.
+func UsageSection(usage, group string) string {
+	header := strings.ToUpper(group) + " ACTIONS"
+	var lines []string
+	for _, line := range strings.Split(usage, "\n") {
+		switch {
+		case len(lines) == 0 && strings.TrimRight(line, " ") != header:
+		case len(lines) > 0 && line != "" && line[0] != ' ':
+			return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+		default:
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// UsageEntries reads the "<GROUP> ACTIONS" section of the --help text and
+// returns each action's entry: its one-line summary followed by the indented
+// lines under it.
+func UsageEntries(usage, group string) map[string]string {
+	header := strings.ToUpper(group) + " ACTIONS"
+	entries := map[string]string{}
+	in := false
+	var current string
+	var lines []string
+	flush := func() {
+		if current != "" {
+			entries[current] = strings.Join(lines, "\n")
+		}
+		current, lines = "", nil
+	}
+	for _, line := range strings.Split(usage, "\n") {
+		if !in {
+			in = strings.TrimRight(line, " ") == header
+			continue
+		}
+		switch {
+		case line != "" && line[0] != ' ':
+			flush()
+			return entries
+		case strings.TrimSpace(line) == "":
+			flush()
+		case usageLine.MatchString(line) && !strings.HasPrefix(line, "   "):
+			flush()
+			m := usageLine.FindStringSubmatch(line)
+			// The summary is capitalized; "send and forward check ..." is prose.
+			if _, seen := entries[m[1]]; seen || m[2][0] < 'A' || m[2][0] > 'Z' {
+				continue
+			}
+			current = m[1]
+			// The summary may be followed by a column of flags.
+			summary, rest := m[2], ""
+			if i := strings.Index(summary, "  "); i >= 0 {
+				summary, rest = summary[:i], strings.TrimSpace(summary[i:])
+			}
+			lines = []string{summary}
+			if rest != "" {
+				lines = append(lines, rest)
+			}
+		case strings.HasPrefix(line, "   ") && current != "":
+			lines = append(lines, strings.TrimSpace(line))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return entries
+}
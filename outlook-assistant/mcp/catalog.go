@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"outlook-assistant/manifest"
+)
+
+// Tool is one action offered to the client, named "<group>_<action>" with
+// dashes turned into underscores, as MCP tool names are identifiers.
+type Tool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	InputSchema InputSchema `json:"inputSchema"`
+
+	Group  string `json:"-"`
+	Action string `json:"-"`
+	// Once is set for an action that runs until interrupted unless given
+	// --once, which every call then passes.
+	Once bool `json:"-"`
+}
+
+// InputSchema is the JSON Schema of a tool's arguments: one property per
+// flag the action takes.
+type InputSchema struct {
+	Type                 string              `json:"type"`
+	Properties           map[string]Property `json:"properties"`
+	AdditionalProperties bool                `json:"additionalProperties"`
+}
+
+// Property describes one flag.
+type Property struct {
+	Type        string      `json:"type"` // string, integer, or boolean
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// ---------- Catalog ----------
+
+// ignoredFlags are set by the server itself rather than by the client.
+var ignoredFlags = map[string]bool{"json": true}
+
+// Catalog builds a tool for every action of the given groups. The flags each
+// action takes come from the usage block of manifestText (tool.yaml), their
+// descriptions from its parameters, and their types and defaults from the
+// action's FlagSet, which flags returns. The tool description is the action's entry in
+// usage, the --help text. Actions marked interactive in the manifest need a
+// terminal and are left out. So are those marked long-running, which would
+// keep the server from answering any later call, unless --once makes them
+// return; their tools always pass it.
+func Catalog(manifestText, usage string, flags func(group, action string) *flag.FlagSet, groups ...string) ([]Tool, error) {
+	params := manifest.Params(manifestText)
+	var tools []Tool
+	for _, group := range groups {
+		actions := manifest.Actions(manifestText, group)
+		if len(actions) == 0 {
+			return nil, fmt.Errorf("no %s actions in the manifest", group)
+		}
+		help := manifest.UsageEntries(usage, group)
+		for _, a := range actions {
+			if a.Interactive || a.LongRunning && !a.Takes("once") {
+				continue
+			}
+			tool := Tool{
+				Name:   strings.ReplaceAll(group+"_"+a.Name, "-", "_"),
+				Group:  group,
+				Action: a.Name,
+				Once:   a.LongRunning,
+				InputSchema: InputSchema{
+					Type:       "object",
+					Properties: map[string]Property{},
+				},
+			}
+			tool.Description = help[a.Name]
+			if tool.Description == "" {
+				tool.Description = group + " " + a.Name + " " + a.Synopsis
+			}
+			fs := flags(group, a.Name)
+			for _, name := range a.Flags {
+				if ignoredFlags[name] || tool.Once && name == "once" {
+					continue
+				}
+				f := fs.Lookup(name)
+				if f == nil {
+					return nil, fmt.Errorf("%s %s: the manifest names --%s, which is not a flag", group, a.Name, name)
+				}
+				p := flagProperty(f)
+				if d := params[name]; d != "" {
+					p.Description = d
+				}
+				tool.InputSchema.Properties[name] = p
+			}
+			tools = append(tools, tool)
+		}
+	}
+	return tools, nil
+}
+
+// flagProperty reads the type and default of a flag.
+func flagProperty(f *flag.Flag) Property {
+	p := Property{Type: "string", Description: f.Usage}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		p.Type = "boolean"
+		if f.DefValue == "true" {
+			p.Default = true
+		}
+		return p
+	}
+	if g, ok := f.Value.(flag.Getter); ok {
+		if _, isInt := g.Get().(int); isInt {
+			p.Type = "integer"
+			if n, err := strconv.Atoi(f.DefValue); err == nil && n != 0 {
+				p.Default = n
+			}
+			return p
+		}
+	}
+	if f.DefValue != "" {
+		p.Default = f.DefValue
+	}
+	return p
+}
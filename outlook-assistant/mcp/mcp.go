@@ -0,0 +1,214 @@
+// Package mcp serves the tool's actions as Model Context Protocol tools over
+// stdio, so an agent can call them directly instead of building command
+// lines.
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"outlook-assistant/jsonrpc"
+)
+
+// protocolVersions are the MCP revisions the server speaks, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Runner runs one command line and returns what it printed. err is set when
+// the command failed.
+type Runner func(ctx context.Context, args []string) (stdout, stderr []byte, err error)
+
+// Server answers MCP requests with Tools, running each call through Run.
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+	Run     Runner
+}
+
+// content is one block of a tool result; only text is produced.
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type callResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError"`
+}
+
+// Serve answers requests from in on out until in ends or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	return jsonrpc.Serve(ctx, in, out, s.handle)
+}
+
+func (s *Server) handle(ctx context.Context, method string, params json.RawMessage) (interface{}, *jsonrpc.Error) {
+	switch method {
+	case "initialize":
+		return s.initialize(params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.Tools}, nil
+	case "tools/call":
+		return s.call(ctx, params)
+	default:
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// initialize agrees on the client's protocol version when the server speaks
+// it, or offers the newest one otherwise.
+func (s *Server) initialize(params json.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ClientInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: "invalid initialize params: " + err.Error()}
+	}
+	version := protocolVersions[0]
+	for _, v := range protocolVersions {
+		if v == p.ProtocolVersion {
+			version = v
+		}
+	}
+	slog.Info("MCP client connected", "client", p.ClientInfo.Name, "protocol", version)
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{"tools": map[string]bool{"listChanged": false}},
+		"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+	}, nil
+}
+
+// call runs a tool. Unknown tools and arguments are protocol errors; a
+// failing action is a tool result with isError set, carrying its message.
+func (s *Server) call(ctx context.Context, params json.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p struct {
+		Name      string                     `json:"name"`
+		Arguments map[string]json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: "invalid tools/call params: " + err.Error()}
+	}
+	var tool *Tool
+	for i := range s.Tools {
+		if s.Tools[i].Name == p.Name {
+			tool = &s.Tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	args, err := toolArgs(*tool, p.Arguments)
+	if err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: err.Error()}
+	}
+
+	slog.Info("MCP tool call", "tool", tool.Name)
+	stdout, stderr, runErr := s.Run(ctx, args)
+	result := callResult{IsError: runErr != nil}
+	texts := []string{string(stdout), strings.TrimSpace(string(stderr))}
+	if runErr != nil {
+		texts = append(texts, runErr.Error())
+	}
+	for _, text := range texts {
+		if text != "" {
+			result.Content = append(result.Content, content{Type: "text", Text: text})
+		}
+	}
+	if len(result.Content) == 0 {
+		result.Content = []content{{Type: "text", Text: "Done."}}
+	}
+	return result, nil
+}
+
+// toolArgs turns a call's arguments into the command line for its action.
+// --json is always given, except that calendar export writes CSV instead when
+// asked to, and so is --once for a tool that needs it to return.
+func toolArgs(tool Tool, arguments map[string]json.RawMessage) ([]string, error) {
+	args := []string{tool.Group, tool.Action}
+	names := make([]string, 0, len(arguments))
+	for name := range arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	csv := false
+	for _, name := range names {
+		prop, ok := tool.InputSchema.Properties[name]
+		if !ok {
+			return nil, fmt.Errorf("%s takes no argument %q", tool.Name, name)
+		}
+		value, err := argValue(prop.Type, arguments[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: argument %q: %w", tool.Name, name, err)
+		}
+		if name == "csv" && value == "true" {
+			csv = true
+		}
+		args = append(args, "--"+name+"="+value)
+	}
+	if tool.Once {
+		args = append(args, "--once")
+	}
+	if !csv {
+		args = append(args, "--json")
+	}
+	return args, nil
+}
+
+// argValue renders a JSON argument as a flag value. Numbers and booleans are
+// accepted for string flags, as a model may send --ref=3 as 3.
+func argValue(typ string, raw json.RawMessage) (string, error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return "", err
+	}
+	switch typ {
+	case "boolean":
+		switch b := v.(type) {
+		case bool:
+			return strconv.FormatBool(b), nil
+		case string:
+			if parsed, err := strconv.ParseBool(b); err == nil {
+				return strconv.FormatBool(parsed), nil
+			}
+		}
+		return "", fmt.Errorf("want a boolean")
+	case "integer":
+		switch n := v.(type) {
+		case json.Number:
+			if _, err := n.Int64(); err == nil {
+				return n.String(), nil
+			}
+		case string:
+			if _, err := strconv.Atoi(n); err == nil {
+				return n, nil
+			}
+		}
+		return "", fmt.Errorf("want an integer")
+	default:
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case json.Number:
+			return x.String(), nil
+		case bool:
+			return strconv.FormatBool(x), nil
+		}
+		return "", fmt.Errorf("want a string")
+	}
+}
@@ -0,0 +1,1562 @@
+// Package mockgraph is a stand-in for Microsoft Graph that serves a small,
+// fixed mailbox, calendar, and contacts folder from memory. Point the tool at it with
+// OUTLOOK_ASSISTANT_GRAPH_URL and every command that reads or changes mail
+// or events runs end to end with no tenant, no credentials, and the same
+// data every time. `devtools mock-server` runs it from the command line; Go
+// tests can start one in-process with New and Start.
+//
+// Only the endpoints the mail, calendar, and contacts commands use are served. Anything
+// else gets a 501 with a Graph-style error naming the request, so a gap shows
+// up as a clear failure rather than as wrong data.
+package mockgraph
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures.json
+var fixtures []byte
+
+// BasePath is the path prefix Graph serves v1.0 under; the URL returned by
+// Start ends with it.
+const BasePath = "/v1.0"
+
+// The signed-in user the fixtures belong to.
+const (
+	userID      = "mock-user-1"
+	userName    = "Alex Rivera"
+	userAddress = "alex@contoso.example"
+)
+
+// rootFolderID is the parent of the top-level folders, like msgfolderroot.
+const rootFolderID = "mock-folder-root"
+
+// Request records one request the server answered, in order. Requests sent
+// inside a $batch are recorded individually after the batch itself.
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"` // relative to BasePath, with the query
+	Status int    `json:"status"`
+}
+
+type object = map[string]interface{}
+
+// Server is an in-memory Graph. Changes such as sending, moving, or deleting
+// a message are kept for the life of the server, so a test can check their
+// effect with a later request.
+type Server struct {
+	// Log, when set, is called with each request once it is answered.
+	Log func(Request)
+	// FailUploadSlices makes the next that many attachment upload slices
+	// answer 503, to exercise resuming an interrupted upload.
+	FailUploadSlices int
+	// ThrottleBatchItems makes the next that many requests inside a $batch
+	// answer 429 with Retry-After, untouched, as Graph throttles them one by
+	// one.
+	ThrottleBatchItems int
+
+	mu         sync.Mutex
+	folders    []object
+	messages   []object
+	events     []object
+	contacts   []object
+	people     []object // people you work with, most relevant first
+	rules      []object // inbox rules
+	overrides  []object // Focused Inbox overrides
+	categories []object // the master category list
+	settings   object   // mailboxSettings
+	// attachments holds each message's attachments by message ID.
+	attachments map[string][]object
+	uploads     map[string]*upload // by session ID
+	nextID      int
+	requests    []Request
+	base        string // this server's URL, for next-page links
+
+	// Every change to a message is numbered, for delta queries: seq is the
+	// latest, changed holds the last one of each message, and removals the
+	// messages that left a folder.
+	seq      int
+	changed  map[string]int
+	removals []removal
+
+	// subscriptions are told of changes to messages, as Graph's change
+	// notifications; changes to events are not sent.
+	subscriptions []object
+
+	http *http.Server
+	url  string
+}
+
+// New returns a server holding a fresh copy of the fixtures.
+func New() *Server {
+	var data struct {
+		Folders     []object            `json:"folders"`
+		Messages    []object            `json:"messages"`
+		Events      []object            `json:"events"`
+		Contacts    []object            `json:"contacts"`
+		People      []object            `json:"people"`
+		Attachments map[string][]object `json:"attachments"`
+	}
+	if err := json.Unmarshal(fixtures, &data); err != nil {
+		panic("mockgraph: invalid fixtures: " + err.Error())
+	}
+	if data.Attachments == nil {
+		data.Attachments = map[string][]object{}
+	}
+	return &Server{
+		folders:       data.Folders,
+		messages:      data.Messages,
+		events:        data.Events,
+		contacts:      data.Contacts,
+		people:        data.People,
+		rules:         []object{},
+		overrides:     []object{},
+		categories:    []object{},
+		subscriptions: []object{},
+		settings: object{
+			"timeZone":   "UTC",
+			"dateFormat": "yyyy-MM-dd",
+			"timeFormat": "HH:mm",
+			"language":   object{"locale": "en-US", "displayName": "English (United States)"},
+			"workingHours": object{
+				"daysOfWeek": []interface{}{"monday", "tuesday", "wednesday", "thursday", "friday"},
+				"startTime":  "09:00:00.0000000",
+				"endTime":    "17:00:00.0000000",
+				"timeZone":   object{"name": "UTC"},
+			},
+			"automaticRepliesSetting": object{
+				"status":               "disabled",
+				"externalAudience":     "all",
+				"internalReplyMessage": "",
+				"externalReplyMessage": "",
+			},
+		},
+		attachments: data.Attachments,
+		uploads:     map[string]*upload{},
+		nextID:      100,
+		changed:     map[string]int{},
+	}
+}
+
+// Start listens on addr, such as "127.0.0.1:0" for any free port, and serves
+// in the background. It returns the base URL to set OUTLOOK_ASSISTANT_GRAPH_URL
+// to.
+func (s *Server) Start(addr string) (string, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	s.url = "http://" + l.Addr().String() + BasePath
+	s.http = &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go s.http.Serve(l)
+	return s.url, nil
+}
+
+// URL returns the base URL Start returned, or "" before Start.
+func (s *Server) URL() string {
+	return s.url
+}
+
+// Close stops a server started with Start.
+func (s *Server) Close() error {
+	if s.http == nil {
+		return nil
+	}
+	return s.http.Close()
+}
+
+// Requests returns the requests answered so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ServeHTTP answers one Graph request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, BasePath)
+	if strings.HasPrefix(path, uploadPath) {
+		// Upload slices are raw bytes, not JSON.
+		status, resp := s.serveUpload(r, strings.TrimPrefix(path, uploadPath))
+		s.record(r.Method, path, r.URL.RawQuery, status)
+		writeJSON(w, status, resp)
+		return
+	}
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, graphError("BadRequest", err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	s.base = "http://" + r.Host + BasePath
+	s.mu.Unlock()
+
+	var status int
+	var resp interface{}
+	if path == "/$batch" && r.Method == http.MethodPost {
+		status, resp = s.batch(body)
+	} else {
+		s.mu.Lock()
+		status, resp = s.route(r.Method, path, r.URL.Query(), body)
+		s.mu.Unlock()
+	}
+	s.record(r.Method, path, r.URL.RawQuery, status)
+	writeJSON(w, status, resp)
+}
+
+func (s *Server) record(method, path, query string, status int) {
+	if query != "" {
+		path += "?" + query
+	}
+	req := Request{Method: method, Path: path, Status: status}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+	if s.Log != nil {
+		s.Log(req)
+	}
+}
+
+// readBody decodes a JSON request body.
+func readBody(r *http.Request) (object, error) {
+	data, err := readRaw(r)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil, err
+	}
+	var body object
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("request body is not a JSON object: %v", err)
+	}
+	return body, nil
+}
+
+// writeJSON writes v as JSON; raw bytes, such as an attachment's $value, are
+// written as they are.
+// readRaw returns the request body, which the SDK may gzip.
+func readRaw(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	return io.ReadAll(reader)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if v == nil {
+		w.WriteHeader(status)
+		return
+	}
+	if data, ok := v.([]byte); ok {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(status)
+		w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func graphError(code, message string) object {
+	return object{"error": object{"code": code, "message": message}}
+}
+
+// batch runs each request of a JSON $batch in order through the same routes.
+func (s *Server) batch(body object) (int, interface{}) {
+	steps, _ := body["requests"].([]interface{})
+	responses := []interface{}{}
+	for _, step := range steps {
+		req, _ := step.(object)
+		method, _ := req["method"].(string)
+		rawURL, _ := req["url"].(string)
+		stepBody, _ := req["body"].(object)
+
+		u, err := url.Parse(rawURL)
+		status, resp := http.StatusBadRequest, interface{}(graphError("BadRequest", fmt.Sprint(err)))
+		headers := object{"Content-Type": "application/json"}
+		if err == nil {
+			s.mu.Lock()
+			if s.ThrottleBatchItems > 0 {
+				s.ThrottleBatchItems--
+				status, resp = http.StatusTooManyRequests, graphError("TooManyRequests", "the mock Graph server throttled this request on purpose")
+				headers["Retry-After"] = "0"
+			} else {
+				status, resp = s.route(strings.ToUpper(method), u.Path, u.Query(), stepBody)
+			}
+			s.mu.Unlock()
+		}
+		s.record(strings.ToUpper(method), u.Path, u.RawQuery, status)
+
+		item := object{"id": req["id"], "status": status, "headers": headers}
+		if resp != nil {
+			item["body"] = resp
+		}
+		responses = append(responses, item)
+	}
+	return http.StatusOK, object{"responses": responses}
+}
+
+// ---------- Routing ----------
+
+// route answers one request for path, relative to BasePath. The caller holds
+// s.mu.
+func (s *Server) route(method, path string, query url.Values, body object) (int, interface{}) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case segs[0] == "subscriptions":
+		return s.routeSubscriptions(method, path, segs[1:], body)
+	case len(segs) >= 1 && segs[0] == "me":
+		segs = segs[1:]
+	case len(segs) >= 2 && segs[0] == "users":
+		segs = segs[2:]
+	default:
+		return notImplemented(method, path)
+	}
+
+	switch {
+	case len(segs) == 0 && method == http.MethodGet:
+		return http.StatusOK, object{"id": userID, "displayName": userName, "mail": userAddress, "userPrincipalName": userAddress}
+	case len(segs) == 0:
+		return notImplemented(method, path)
+	}
+
+	switch segs[0] {
+	case "mailFolders":
+		return s.routeFolders(method, path, segs[1:], query, body)
+	case "messages":
+		return s.routeMessages(method, path, segs[1:], query, body)
+	case "sendMail":
+		if method != http.MethodPost || len(segs) != 1 {
+			break
+		}
+		msg, _ := field(body, "message").(object)
+		if msg == nil {
+			return http.StatusBadRequest, graphError("ErrorInvalidRequest", "message is required")
+		}
+		s.sent(msg)
+		return http.StatusAccepted, nil
+	case "outlook":
+		if len(segs) >= 2 && segs[1] == "masterCategories" {
+			return s.routeCategories(method, path, segs[2:], body)
+		}
+	case "inferenceClassification":
+		if len(segs) == 2 && segs[1] == "overrides" {
+			return s.routeOverrides(method, path, body)
+		}
+	case "calendarView":
+		if method == http.MethodGet && len(segs) == 1 {
+			return s.calendarView(query, path)
+		}
+	case "events":
+		return s.routeEvents(method, path, segs[1:], query, body)
+	case "contacts":
+		return s.routeContacts(method, path, segs[1:], query, body)
+	case "people":
+		if method == http.MethodGet && len(segs) == 1 {
+			return http.StatusOK, object{"value": s.searchPeople(query.Get("$search"))}
+		}
+	case "mailboxSettings":
+		if len(segs) != 1 {
+			break
+		}
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, s.settings
+		case http.MethodPatch:
+			// Like Graph, a complex setting is merged property by property.
+			for k, v := range body {
+				current, ok := s.settings[k].(object)
+				update, isObject := v.(object)
+				if !ok || !isObject {
+					s.settings[k] = v
+					continue
+				}
+				for name, value := range update {
+					current[name] = value
+				}
+			}
+			return http.StatusOK, s.settings
+		}
+	}
+	return notImplemented(method, path)
+}
+
+func notImplemented(method, path string) (int, interface{}) {
+	return http.StatusNotImplemented, graphError("NotImplemented", fmt.Sprintf("the mock Graph server has no response for %s %s", method, path))
+}
+
+func notFound(kind, id string) (int, interface{}) {
+	return http.StatusNotFound, graphError("ErrorItemNotFound", fmt.Sprintf("%s %q not found", kind, id))
+}
+
+// ---------- Folders ----------
+
+func (s *Server) routeFolders(method, path string, segs []string, query url.Values, body object) (int, interface{}) {
+	if len(segs) >= 2 && segs[1] == "messageRules" {
+		if id, _ := s.folderID(segs[0]); id != "mock-folder-inbox" {
+			return http.StatusBadRequest, graphError("ErrorInvalidRequest", "Rules can only be set on the Inbox.")
+		}
+		return s.routeRules(method, path, segs[2:], body)
+	}
+	if method != http.MethodGet {
+		return notImplemented(method, path)
+	}
+	if len(segs) == 0 {
+		return http.StatusOK, s.page(s.childFolders(rootFolderID), query, path)
+	}
+	if len(segs) == 1 && strings.TrimSuffix(segs[0], "()") == "delta" {
+		return s.folderDelta(query, path)
+	}
+	id, ok := s.folderID(segs[0])
+	switch {
+	case len(segs) == 1 && ok:
+		return http.StatusOK, s.folder(id)
+	case len(segs) == 2 && segs[1] == "childFolders":
+		// Unknown parents, such as the search folders root, have no children.
+		return http.StatusOK, s.page(s.childFolders(id), query, path)
+	case len(segs) == 3 && segs[1] == "messages" && strings.TrimSuffix(segs[2], "()") == "delta" && ok:
+		return s.delta(id, query, path)
+	case len(segs) == 2 && segs[1] == "messages" && ok:
+		var inFolder []object
+		for _, m := range s.messages {
+			if m["parentFolderId"] == id {
+				inFolder = append(inFolder, m)
+			}
+		}
+		return http.StatusOK, s.page(sortMessages(filterMessages(inFolder, query)), query, path)
+	case !ok:
+		return notFound("folder", segs[0])
+	}
+	return notImplemented(method, path)
+}
+
+// folderID resolves a folder ID or a well-known name such as inbox.
+func (s *Server) folderID(ref string) (string, bool) {
+	for _, f := range s.folders {
+		if id := f["id"].(string); id == ref || id == "mock-folder-"+strings.ToLower(ref) {
+			return id, true
+		}
+	}
+	return ref, false
+}
+
+// folder returns a folder with its counts worked out from the messages.
+func (s *Server) folder(id string) object {
+	var f object
+	for _, candidate := range s.folders {
+		if candidate["id"] == id {
+			f = copyObject(candidate)
+		}
+	}
+	total, unread, children := 0, 0, 0
+	for _, m := range s.messages {
+		if m["parentFolderId"] == id {
+			total++
+			if read, _ := m["isRead"].(bool); !read {
+				unread++
+			}
+		}
+	}
+	for _, c := range s.folders {
+		if c["parentFolderId"] == id {
+			children++
+		}
+	}
+	f["totalItemCount"], f["unreadItemCount"], f["childFolderCount"] = total, unread, children
+	return f
+}
+
+func (s *Server) childFolders(parent string) []object {
+	children := []object{}
+	for _, f := range s.folders {
+		if f["parentFolderId"] == parent {
+			children = append(children, s.folder(f["id"].(string)))
+		}
+	}
+	return children
+}
+
+// routeRules serves the inbox rules. Nothing applies them to arriving mail;
+// the mock only stores them.
+func (s *Server) routeRules(method, path string, segs []string, body object) (int, interface{}) {
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, object{"value": s.rules}
+		case http.MethodPost:
+			s.nextID++
+			rule := copyObject(body)
+			rule["id"] = "mock-rule-" + strconv.Itoa(s.nextID)
+			rule["isReadOnly"], rule["hasError"] = false, false
+			s.rules = append(s.rules, rule)
+			return http.StatusCreated, rule
+		}
+		return notImplemented(method, path)
+	}
+	i := -1
+	for j, r := range s.rules {
+		if r["id"] == segs[0] {
+			i = j
+		}
+	}
+	if i < 0 {
+		return notFound("rule", segs[0])
+	}
+	switch {
+	case len(segs) == 1 && method == http.MethodGet:
+		return http.StatusOK, s.rules[i]
+	case len(segs) == 1 && method == http.MethodPatch:
+		for k, v := range body {
+			s.rules[i][k] = v
+		}
+		return http.StatusOK, s.rules[i]
+	case len(segs) == 1 && method == http.MethodDelete:
+		s.rules = append(s.rules[:i], s.rules[i+1:]...)
+		return http.StatusNoContent, nil
+	}
+	return notImplemented(method, path)
+}
+
+// routeCategories serves the master category list. As in Graph, a name is
+// unique regardless of case and cannot be changed once created.
+func (s *Server) routeCategories(method, path string, segs []string, body object) (int, interface{}) {
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, object{"value": s.categories}
+		case http.MethodPost:
+			name, _ := body["displayName"].(string)
+			for _, c := range s.categories {
+				if strings.EqualFold(c["displayName"].(string), name) {
+					return http.StatusConflict, graphError("ErrorDuplicateCategoryName", fmt.Sprintf("category %q already exists", name))
+				}
+			}
+			s.nextID++
+			category := copyObject(body)
+			category["id"] = "mock-category-" + strconv.Itoa(s.nextID)
+			if category["color"] == nil {
+				category["color"] = "none"
+			}
+			s.categories = append(s.categories, category)
+			return http.StatusCreated, category
+		}
+		return notImplemented(method, path)
+	}
+	i := -1
+	for j, c := range s.categories {
+		if c["id"] == segs[0] {
+			i = j
+		}
+	}
+	if i < 0 {
+		return notFound("category", segs[0])
+	}
+	switch {
+	case len(segs) == 1 && method == http.MethodGet:
+		return http.StatusOK, s.categories[i]
+	case len(segs) == 1 && method == http.MethodPatch:
+		if _, ok := body["displayName"]; ok {
+			return http.StatusBadRequest, graphError("ErrorInvalidRequest", "displayName cannot be changed")
+		}
+		for k, v := range body {
+			s.categories[i][k] = v
+		}
+		return http.StatusOK, s.categories[i]
+	case len(segs) == 1 && method == http.MethodDelete:
+		s.categories = append(s.categories[:i], s.categories[i+1:]...)
+		return http.StatusNoContent, nil
+	}
+	return notImplemented(method, path)
+}
+
+// routeOverrides serves the Focused Inbox overrides. As in Graph, posting one
+// for a sender that already has one changes it. Nothing applies them to
+// arriving mail.
+func (s *Server) routeOverrides(method, path string, body object) (int, interface{}) {
+	switch method {
+	case http.MethodGet:
+		return http.StatusOK, object{"value": s.overrides}
+	case http.MethodPost:
+		senderOf := func(o object) string {
+			e, _ := o["senderEmailAddress"].(object)
+			a, _ := e["address"].(string)
+			return a
+		}
+		for _, o := range s.overrides {
+			if strings.EqualFold(senderOf(o), senderOf(body)) {
+				o["classifyAs"] = body["classifyAs"]
+				return http.StatusCreated, o
+			}
+		}
+		s.nextID++
+		override := copyObject(body)
+		override["id"] = "mock-override-" + strconv.Itoa(s.nextID)
+		s.overrides = append(s.overrides, override)
+		return http.StatusCreated, override
+	}
+	return notImplemented(method, path)
+}
+
+// ---------- Messages ----------
+
+func (s *Server) routeMessages(method, path string, segs []string, query url.Values, body object) (int, interface{}) {
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, s.page(sortMessages(searchMessages(filterMessages(s.messages, query), query.Get("$search"))), query, path)
+		case http.MethodPost:
+			draft := copyObject(body)
+			draft["isDraft"] = true
+			return http.StatusCreated, s.add(draft, "mock-folder-drafts")
+		}
+		return notImplemented(method, path)
+	}
+
+	i := s.messageIndex(segs[0])
+	if i < 0 {
+		return notFound("message", segs[0])
+	}
+	msg := s.messages[i]
+
+	if len(segs) == 1 {
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, msg
+		case http.MethodPatch:
+			for k, v := range body {
+				msg[k] = v
+			}
+			s.touch(msg, "updated")
+			return http.StatusOK, msg
+		case http.MethodDelete:
+			s.remove(msg)
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			return http.StatusNoContent, nil
+		}
+		return notImplemented(method, path)
+	}
+
+	if segs[1] == "attachments" {
+		return s.messageAttachments(method, path, msg, segs[2:], body)
+	}
+	if segs[1] == "$value" && len(segs) == 2 && method == http.MethodGet {
+		return http.StatusOK, mimeOf(msg)
+	}
+	if method != http.MethodPost || len(segs) != 2 {
+		return notImplemented(method, path)
+	}
+	switch segs[1] {
+	case "permanentDelete":
+		s.remove(msg)
+		s.messages = append(s.messages[:i], s.messages[i+1:]...)
+		return http.StatusNoContent, nil
+	case "move":
+		dest, _ := field(body, "destinationId").(string)
+		id, ok := s.folderID(dest)
+		if !ok {
+			return notFound("folder", dest)
+		}
+		s.remove(msg)
+		msg["parentFolderId"] = id
+		s.touch(msg, "created")
+		return http.StatusCreated, msg
+	case "reply", "replyAll", "forward":
+		s.sent(object{"subject": prefixed(msg, segs[1]), "toRecipients": msg["toRecipients"]})
+		return http.StatusAccepted, nil
+	case "createReply", "createReplyAll", "createForward":
+		draft := object{
+			"subject":        prefixed(msg, segs[1]),
+			"toRecipients":   []interface{}{},
+			"body":           object{"contentType": "html", "content": quoted(msg)},
+			"conversationId": msg["conversationId"],
+		}
+		switch segs[1] {
+		case "createReply":
+			draft["toRecipients"] = []interface{}{msg["from"]}
+		case "createReplyAll":
+			draft["toRecipients"] = append([]interface{}{msg["from"]}, others(msg["toRecipients"])...)
+			draft["ccRecipients"] = others(msg["ccRecipients"])
+		}
+		return http.StatusCreated, s.add(draft, "mock-folder-drafts")
+	case "send":
+		s.remove(msg)
+		msg["parentFolderId"] = "mock-folder-sentitems"
+		msg["isDraft"] = false
+		msg["from"] = object{"emailAddress": object{"name": userName, "address": userAddress}}
+		s.touch(msg, "created")
+		return http.StatusAccepted, nil
+	}
+	return notImplemented(method, path)
+}
+
+// messageAttachments serves /messages/{id}/attachments and below. A list
+// leaves out file content, as a $select without contentBytes does; $value is
+// the file itself, or an attached item as MIME.
+func (s *Server) messageAttachments(method, path string, msg object, segs []string, body object) (int, interface{}) {
+	id, _ := msg["id"].(string)
+	list := s.attachments[id]
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			items := make([]object, len(list))
+			for i, a := range list {
+				items[i] = copyObject(a)
+				delete(items[i], "contentBytes")
+			}
+			return http.StatusOK, object{"value": items}
+		case http.MethodPost:
+			return http.StatusCreated, s.attach(msg, copyObject(body))
+		}
+		return notImplemented(method, path)
+	}
+	if len(segs) == 1 && segs[0] == "createUploadSession" && method == http.MethodPost {
+		item, _ := field(body, "attachmentItem").(object)
+		return http.StatusCreated, s.startUpload(id, item)
+	}
+
+	var att object
+	for _, a := range list {
+		if a["id"] == segs[0] {
+			att = a
+		}
+	}
+	if att == nil {
+		return notFound("attachment", segs[0])
+	}
+	switch {
+	case method == http.MethodGet && len(segs) == 1:
+		return http.StatusOK, att
+	case method == http.MethodGet && len(segs) == 2 && segs[1] == "$value":
+		if content, ok := att["contentBytes"].(string); ok {
+			data, err := base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				return http.StatusInternalServerError, graphError("ErrorInternalServerError", err.Error())
+			}
+			return http.StatusOK, data
+		}
+		name, _ := att["name"].(string)
+		return http.StatusOK, []byte(fmt.Sprintf("From: %s <%s>\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+			userName, userAddress, name, name))
+	}
+	return notImplemented(method, path)
+}
+
+// attach stores a as an attachment of msg, with a fresh ID.
+func (s *Server) attach(msg, a object) object {
+	id, _ := msg["id"].(string)
+	s.nextID++
+	a["id"] = "mock-att-" + strconv.Itoa(s.nextID)
+	if content, ok := a["contentBytes"].(string); ok {
+		data, _ := base64.StdEncoding.DecodeString(content)
+		a["size"] = len(data)
+	}
+	if a["@odata.type"] == nil {
+		a["@odata.type"] = "#microsoft.graph.fileAttachment"
+	}
+	s.attachments[id] = append(s.attachments[id], a)
+	msg["hasAttachments"] = true
+	return a
+}
+
+func (s *Server) messageIndex(id string) int {
+	for i, m := range s.messages {
+		if m["id"] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sent files msg in Sent Items as sent now by the signed-in user. A message
+// addressed to the user is also delivered to the inbox, unread, which is how
+// new mail arrives in the mock.
+func (s *Server) sent(msg object) object {
+	msg = copyObject(msg)
+	msg["from"] = object{"emailAddress": object{"name": userName, "address": userAddress}}
+	for _, field := range []string{"toRecipients", "ccRecipients"} {
+		list, _ := msg[field].([]interface{})
+		if len(others(msg[field])) < len(list) {
+			delivered := copyObject(msg)
+			delivered["isRead"] = false
+			s.add(delivered, "mock-folder-inbox")
+			break
+		}
+	}
+	msg["isRead"] = true
+	return s.add(msg, "mock-folder-sentitems")
+}
+
+// add stores a new message in folder with a fresh ID and the current time.
+func (s *Server) add(msg object, folder string) object {
+	s.nextID++
+	now := time.Now().UTC().Format(time.RFC3339)
+	msg["id"] = "mock-msg-" + strconv.Itoa(s.nextID)
+	msg["parentFolderId"] = folder
+	msg["receivedDateTime"], msg["sentDateTime"] = now, now
+	if _, ok := msg["isRead"]; !ok {
+		msg["isRead"] = true
+	}
+	msg["inferenceClassification"] = "focused"
+	msg["flag"] = object{"flagStatus": "notFlagged"}
+	if msg["conversationId"] == nil {
+		msg["conversationId"] = "mock-conv-" + strconv.Itoa(s.nextID)
+	}
+	msg["internetMessageId"] = fmt.Sprintf("<mock-%d@contoso.example>", s.nextID)
+	if list, ok := msg["attachments"].([]interface{}); ok {
+		// Attachments sent with a message are kept apart from it, as Graph
+		// serves them from their own endpoint.
+		delete(msg, "attachments")
+		for _, a := range list {
+			if a, ok := a.(object); ok {
+				s.attach(msg, a)
+			}
+		}
+	}
+	if b, ok := msg["body"].(object); ok {
+		content, _ := b["content"].(string)
+		msg["bodyPreview"] = truncate(content, 255)
+	}
+	s.messages = append(s.messages, msg)
+	s.touch(msg, "created")
+	return msg
+}
+
+func prefixed(msg object, action string) string {
+	subject, _ := msg["subject"].(string)
+	prefix := "RE: "
+	if strings.Contains(strings.ToLower(action), "forward") {
+		prefix = "FW: "
+	}
+	if strings.HasPrefix(strings.ToUpper(subject), prefix) {
+		return subject
+	}
+	return prefix + subject
+}
+
+func quoted(msg object) string {
+	content := ""
+	if b, ok := msg["body"].(object); ok {
+		content, _ = b["content"].(string)
+	}
+	return "<hr><div>" + content + "</div>"
+}
+
+// mimeOf renders msg as the MIME message its $value serves: the headers
+// Exchange writes most often, then the body as a single part.
+func mimeOf(msg object) []byte {
+	address := func(r interface{}) string {
+		o, _ := r.(object)
+		e, _ := field(o, "emailAddress").(object)
+		name, _ := e["name"].(string)
+		addr, _ := e["address"].(string)
+		if name == "" {
+			return "<" + addr + ">"
+		}
+		return fmt.Sprintf("%q <%s>", name, addr)
+	}
+	list := func(key string) string {
+		items, _ := msg[key].([]interface{})
+		parts := make([]string, 0, len(items))
+		for _, r := range items {
+			parts = append(parts, address(r))
+		}
+		return strings.Join(parts, ", ")
+	}
+	contentType, content := "text/plain", ""
+	if b, ok := msg["body"].(object); ok {
+		content, _ = b["content"].(string)
+		if t, _ := b["contentType"].(string); strings.EqualFold(t, "html") {
+			contentType = "text/html"
+		}
+	}
+	var b strings.Builder
+	if from := msg["from"]; from != nil {
+		fmt.Fprintf(&b, "From: %s\r\n", address(from))
+	}
+	if to := list("toRecipients"); to != "" {
+		fmt.Fprintf(&b, "To: %s\r\n", to)
+	}
+	if cc := list("ccRecipients"); cc != "" {
+		fmt.Fprintf(&b, "Cc: %s\r\n", cc)
+	}
+	subject, _ := msg["subject"].(string)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	if at, err := time.Parse(time.RFC3339, fmt.Sprint(msg["receivedDateTime"])); err == nil {
+		fmt.Fprintf(&b, "Date: %s\r\n", at.Format(time.RFC1123Z))
+	}
+	if id, ok := msg["internetMessageId"].(string); ok {
+		fmt.Fprintf(&b, "Message-ID: %s\r\n", id)
+	}
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\nContent-Type: %s; charset=utf-8\r\n\r\n", contentType)
+	b.WriteString(strings.ReplaceAll(content, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// filterMessages applies the $filter clauses the commands send: isRead,
+// hasAttachments, receivedDateTime ge/le, and the sender's address, joined
+// with "and".
+// Other clauses are ignored.
+func filterMessages(messages []object, query url.Values) []object {
+	filter := query.Get("$filter")
+	if filter == "" {
+		return messages
+	}
+	var keep []object
+	for _, m := range messages {
+		match := true
+		for _, clause := range strings.Split(filter, " and ") {
+			if ok, handled := matchFunction(m, strings.TrimSpace(clause)); handled {
+				match = match && ok
+				continue
+			}
+			field, op, value := splitClause(clause)
+			switch field {
+			case "isRead", "hasAttachments":
+				v, _ := m[field].(bool)
+				match = match && strconv.FormatBool(v) == value
+			case "receivedDateTime":
+				received, _ := m["receivedDateTime"].(string)
+				match = match && compareTime(received, op, value)
+			case "from/emailAddress/address":
+				match = match && strings.EqualFold(address(m["from"]), value)
+			case "conversationId", "inferenceClassification", "importance":
+				v, _ := m[field].(string)
+				match = match && v == value
+			case "flag/flagStatus":
+				flag, _ := m["flag"].(object)
+				status, _ := flag["flagStatus"].(string)
+				match = match && status == value
+			}
+		}
+		if match {
+			keep = append(keep, m)
+		}
+	}
+	return keep
+}
+
+// matchFunction matches the clauses that are not "field op value":
+// endswith(from/emailAddress/address, '...') and recipient lambdas such as
+// toRecipients/any(r: r/emailAddress/address eq '...'). handled is false for
+// any other clause.
+func matchFunction(m object, clause string) (ok, handled bool) {
+	value := clause
+	if i, j := strings.Index(clause, "'"), strings.LastIndex(clause, "'"); i >= 0 && j > i {
+		value = clause[i+1 : j]
+	}
+	switch {
+	case strings.HasPrefix(clause, "endswith(from/emailAddress/address,"):
+		return strings.HasSuffix(strings.ToLower(address(m["from"])), strings.ToLower(value)), true
+	case strings.Contains(clause, "/any("):
+		field, _, _ := strings.Cut(clause, "/any(")
+		recipients, _ := m[field].([]interface{})
+		for _, r := range recipients {
+			if strings.EqualFold(address(r), value) {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	return false, false
+}
+
+// splitClause splits "field op value" and unquotes value.
+func splitClause(clause string) (string, string, string) {
+	parts := strings.SplitN(strings.TrimSpace(strings.Trim(strings.TrimSpace(clause), "()")), " ", 3)
+	if len(parts) != 3 {
+		return "", "", ""
+	}
+	return parts[0], parts[1], strings.Trim(parts[2], "'")
+}
+
+func compareTime(at, op, value string) bool {
+	t, err1 := time.Parse(time.RFC3339, at)
+	v, err2 := time.Parse(time.RFC3339, value)
+	if err1 != nil || err2 != nil {
+		return true
+	}
+	switch op {
+	case "ge":
+		return !t.Before(v)
+	case "gt":
+		return t.After(v)
+	case "le":
+		return !t.After(v)
+	case "lt":
+		return t.Before(v)
+	}
+	return true
+}
+
+// searchMessages matches $search terms against subject, preview, and sender.
+func searchMessages(messages []object, search string) []object {
+	search = strings.ToLower(strings.Trim(search, `"`))
+	if search == "" {
+		return messages
+	}
+	var keep []object
+	for _, m := range messages {
+		subject, _ := m["subject"].(string)
+		preview, _ := m["bodyPreview"].(string)
+		text := strings.ToLower(subject + " " + preview + " " + address(m["from"]))
+		if strings.Contains(text, search) {
+			keep = append(keep, m)
+		}
+	}
+	return keep
+}
+
+// sortMessages orders messages newest first, as every command asks.
+func sortMessages(messages []object) []object {
+	sorted := append([]object(nil), messages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := sorted[i]["receivedDateTime"].(string)
+		b, _ := sorted[j]["receivedDateTime"].(string)
+		return a > b
+	})
+	return sorted
+}
+
+// ---------- Delta ----------
+
+// removal records a message leaving a folder, by deletion or a move.
+type removal struct {
+	seq    int
+	id     string
+	folder string
+}
+
+// touch records a change to msg, created or updated, and notifies the
+// subscriptions watching it. The caller holds s.mu.
+func (s *Server) touch(msg object, changeType string) {
+	s.seq++
+	s.changed[msg["id"].(string)] = s.seq
+	folder, _ := msg["parentFolderId"].(string)
+	s.notify(msg["id"].(string), folder, changeType)
+}
+
+// remove records msg leaving its folder. The caller holds s.mu.
+func (s *Server) remove(msg object) {
+	s.seq++
+	folder, _ := msg["parentFolderId"].(string)
+	s.removals = append(s.removals, removal{seq: s.seq, id: msg["id"].(string), folder: folder})
+	s.notify(msg["id"].(string), folder, "deleted")
+}
+
+// delta answers /mailFolders/{id}/messages/delta in a single page. Without
+// $deltatoken it returns every message in the folder that matches $filter;
+// with one, the messages changed and removed since. The token is the change
+// number, and the delta link carries the $filter along as Graph's opaque one
+// does.
+func (s *Server) delta(folderID string, query url.Values, path string) (int, interface{}) {
+	since := -1
+	if token := query.Get("$deltatoken"); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n > s.seq {
+			return http.StatusGone, graphError("SyncStateNotFound", "the delta token is not valid")
+		}
+		since = n
+	}
+
+	value := []interface{}{}
+	inFolder := map[string]bool{}
+	var candidates []object
+	for _, m := range s.messages {
+		if m["parentFolderId"] == folderID {
+			inFolder[m["id"].(string)] = true
+			candidates = append(candidates, m)
+		}
+	}
+	for _, m := range sortMessages(filterMessages(candidates, query)) {
+		if since < 0 || s.changed[m["id"].(string)] > since {
+			value = append(value, m)
+		}
+	}
+	if since >= 0 {
+		reported := map[string]bool{}
+		for _, r := range s.removals {
+			if r.seq > since && r.folder == folderID && !inFolder[r.id] && !reported[r.id] {
+				reported[r.id] = true
+				value = append(value, object{"id": r.id, "@removed": object{"reason": "deleted"}})
+			}
+		}
+	}
+
+	link := url.Values{}
+	link.Set("$deltatoken", strconv.Itoa(s.seq))
+	if filter := query.Get("$filter"); filter != "" {
+		link.Set("$filter", filter)
+	}
+	return http.StatusOK, object{"value": value, "@odata.deltaLink": s.base + path + "?" + link.Encode()}
+}
+
+// folderDelta answers /mailFolders/delta in a single page, numbered like
+// delta. Without $deltatoken it returns every folder; with one, the folders
+// whose counts may have changed since: those a changed message is in, or a
+// removed one left.
+func (s *Server) folderDelta(query url.Values, path string) (int, interface{}) {
+	since := -1
+	if token := query.Get("$deltatoken"); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n > s.seq {
+			return http.StatusGone, graphError("SyncStateNotFound", "the delta token is not valid")
+		}
+		since = n
+	}
+
+	touched := map[string]bool{}
+	for _, m := range s.messages {
+		if s.changed[m["id"].(string)] > since {
+			touched[m["parentFolderId"].(string)] = true
+		}
+	}
+	for _, r := range s.removals {
+		if r.seq > since {
+			touched[r.folder] = true
+		}
+	}
+	value := []interface{}{}
+	for _, f := range s.folders {
+		if id := f["id"].(string); since < 0 || touched[id] {
+			value = append(value, s.folder(id))
+		}
+	}
+
+	link := url.Values{}
+	link.Set("$deltatoken", strconv.Itoa(s.seq))
+	return http.StatusOK, object{"value": value, "@odata.deltaLink": s.base + path + "?" + link.Encode()}
+}
+
+// ---------- Subscriptions ----------
+
+// routeSubscriptions serves /subscriptions. Like Graph, creating one first
+// checks that its notification URL echoes a validation token.
+func (s *Server) routeSubscriptions(method, path string, segs []string, body object) (int, interface{}) {
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			return http.StatusOK, object{"value": s.subscriptions}
+		case http.MethodPost:
+			sub := copyObject(body)
+			target, _ := sub["notificationUrl"].(string)
+			if err := validateEndpoint(target); err != nil {
+				return http.StatusBadRequest, graphError("ValidationError", "Subscription validation request failed: "+err.Error())
+			}
+			s.nextID++
+			sub["id"] = "mock-sub-" + strconv.Itoa(s.nextID)
+			s.subscriptions = append(s.subscriptions, sub)
+			return http.StatusCreated, sub
+		}
+		return notImplemented(method, path)
+	}
+	i := -1
+	for j, sub := range s.subscriptions {
+		if sub["id"] == segs[0] {
+			i = j
+		}
+	}
+	if i < 0 {
+		return notFound("subscription", segs[0])
+	}
+	switch {
+	case len(segs) == 1 && method == http.MethodGet:
+		return http.StatusOK, s.subscriptions[i]
+	case len(segs) == 1 && method == http.MethodPatch:
+		if expires, ok := body["expirationDateTime"]; ok {
+			s.subscriptions[i]["expirationDateTime"] = expires
+		}
+		return http.StatusOK, s.subscriptions[i]
+	case len(segs) == 1 && method == http.MethodDelete:
+		s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+		return http.StatusNoContent, nil
+	}
+	return notImplemented(method, path)
+}
+
+// validateEndpoint sends target a validation token and checks that it comes
+// back as the answer.
+func validateEndpoint(target string) error {
+	token := "mock-validation-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(target+"?validationToken="+url.QueryEscape(token), "text/plain", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK || string(answer) != token {
+		return fmt.Errorf("%s answered %s without echoing the token", target, resp.Status)
+	}
+	return nil
+}
+
+// notify posts a change notification for message id in folder to each
+// subscription that watches it, in the background. The caller holds s.mu.
+func (s *Server) notify(id, folder, changeType string) {
+	for _, sub := range s.subscriptions {
+		types, _ := sub["changeType"].(string)
+		resource, _ := sub["resource"].(string)
+		target, _ := sub["notificationUrl"].(string)
+		if !strings.Contains(","+types+",", ","+changeType+",") || !s.watches(resource, folder) {
+			continue
+		}
+		itemPath := "Users/" + userID + "/Messages/" + id
+		payload := object{"value": []interface{}{object{
+			"subscriptionId":                 sub["id"],
+			"subscriptionExpirationDateTime": sub["expirationDateTime"],
+			"changeType":                     changeType,
+			"clientState":                    sub["clientState"],
+			"tenantId":                       "mock-tenant",
+			"resource":                       itemPath,
+			"resourceData": object{
+				"@odata.type": "#Microsoft.Graph.Message",
+				"@odata.id":   itemPath,
+				"id":          id,
+			},
+		}}}
+		data, _ := json.Marshal(payload)
+		go func() {
+			client := &http.Client{Timeout: 10 * time.Second}
+			if resp, err := client.Post(target, "application/json", bytes.NewReader(data)); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+}
+
+// watches reports whether a subscription resource, such as me/messages or
+// me/mailFolders('inbox')/messages, covers messages in folder.
+func (s *Server) watches(resource, folder string) bool {
+	segs := strings.Split(strings.Trim(resource, "/"), "/")
+	switch {
+	case len(segs) >= 1 && strings.EqualFold(segs[0], "me"):
+		segs = segs[1:]
+	case len(segs) >= 2 && strings.EqualFold(segs[0], "users"):
+		segs = segs[2:]
+	}
+	switch {
+	case len(segs) == 1 && strings.EqualFold(segs[0], "messages"):
+		return true
+	case len(segs) == 2 && strings.EqualFold(segs[1], "messages"):
+		name := strings.TrimSuffix(strings.TrimPrefix(segs[0], "mailFolders('"), "')")
+		id, ok := s.folderID(name)
+		return ok && id == folder
+	}
+	return false
+}
+
+// ---------- Events ----------
+
+func (s *Server) routeEvents(method, path string, segs []string, query url.Values, body object) (int, interface{}) {
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			var keep []object
+			for _, e := range s.events {
+				match := true
+				for _, clause := range strings.Split(query.Get("$filter"), " and ") {
+					field, _, value := splitClause(clause)
+					switch field {
+					case "iCalUId":
+						match = match && e["iCalUId"] == value
+					case "type":
+						match = match && e["type"] == value
+					}
+				}
+				if match {
+					keep = append(keep, e)
+				}
+			}
+			return http.StatusOK, s.page(sortEvents(keep), query, path)
+		case http.MethodPost:
+			s.nextID++
+			event := copyObject(body)
+			event["id"] = "mock-event-" + strconv.Itoa(s.nextID)
+			event["iCalUId"] = fmt.Sprintf("040000008200E00074C5B7101A82E0080000000010000000mock%04d", s.nextID)
+			event["organizer"] = object{"emailAddress": object{"name": userName, "address": userAddress}}
+			event["isOrganizer"] = true
+			event["type"] = "singleInstance"
+			event["webLink"] = "https://outlook.office365.com/owa/?itemid=" + event["id"].(string)
+			if event["showAs"] == nil {
+				event["showAs"] = "busy"
+			}
+			s.events = append(s.events, event)
+			return http.StatusCreated, event
+		}
+		return notImplemented(method, path)
+	}
+
+	i := -1
+	for j, e := range s.events {
+		if e["id"] == segs[0] {
+			i = j
+		}
+	}
+	if i < 0 {
+		return notFound("event", segs[0])
+	}
+	event := s.events[i]
+	switch {
+	case len(segs) == 1 && method == http.MethodGet:
+		return http.StatusOK, event
+	case len(segs) == 1 && method == http.MethodPatch:
+		for k, v := range body {
+			event[k] = v
+		}
+		return http.StatusOK, event
+	case len(segs) == 1 && method == http.MethodDelete:
+		s.events = append(s.events[:i], s.events[i+1:]...)
+		return http.StatusNoContent, nil
+	case len(segs) == 2 && segs[1] == "cancel" && method == http.MethodPost:
+		// Only the organizer may cancel; attendees are notified, which the
+		// mock has nobody to do for.
+		if event["isOrganizer"] != true {
+			return http.StatusBadRequest, graphError("ErrorAccessDenied", "Only the organizer can cancel the meeting.")
+		}
+		s.events = append(s.events[:i], s.events[i+1:]...)
+		return http.StatusAccepted, nil
+	case len(segs) == 2 && responses[segs[1]] != "" && method == http.MethodPost:
+		if event["isOrganizer"] == true {
+			return http.StatusBadRequest, graphError("ErrorInvalidRequest", "Your request can't be completed. You can't respond to a meeting you organized.")
+		}
+		if responses[segs[1]] == "declined" {
+			s.events = append(s.events[:i], s.events[i+1:]...)
+		} else {
+			event["responseStatus"] = object{"response": responses[segs[1]], "time": time.Now().UTC().Format(time.RFC3339)}
+		}
+		return http.StatusAccepted, nil
+	case len(segs) == 2 && segs[1] == "attachments" && method == http.MethodGet:
+		return http.StatusOK, object{"value": []object{}}
+	}
+	return notImplemented(method, path)
+}
+
+// responses maps the invitation reply actions to the responseStatus they set.
+var responses = map[string]string{
+	"accept":            "accepted",
+	"decline":           "declined",
+	"tentativelyAccept": "tentativelyAccepted",
+}
+
+// calendarView returns the events that overlap startDateTime..endDateTime.
+func (s *Server) calendarView(query url.Values, path string) (int, interface{}) {
+	from, err1 := time.Parse(time.RFC3339, query.Get("startDateTime"))
+	to, err2 := time.Parse(time.RFC3339, query.Get("endDateTime"))
+	if err1 != nil || err2 != nil {
+		return http.StatusBadRequest, graphError("ErrorInvalidParameter", "startDateTime and endDateTime are required")
+	}
+	var keep []object
+	for _, e := range s.events {
+		start, end := eventTime(e["start"]), eventTime(e["end"])
+		if start.Before(to) && end.After(from) {
+			keep = append(keep, e)
+		}
+	}
+	return http.StatusOK, s.page(sortEvents(keep), query, path)
+}
+
+// eventTime reads a dateTimeTimeZone; the fixtures are all in UTC.
+func eventTime(v interface{}) time.Time {
+	dt, _ := v.(object)
+	s, _ := dt["dateTime"].(string)
+	if len(s) > 19 {
+		s = s[:19]
+	}
+	t, _ := time.Parse("2006-01-02T15:04:05", s)
+	return t
+}
+
+func sortEvents(events []object) []object {
+	sorted := append([]object(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return eventTime(sorted[i]["start"]).Before(eventTime(sorted[j]["start"]))
+	})
+	return sorted
+}
+
+// ---------- Contacts ----------
+
+func (s *Server) routeContacts(method, path string, segs []string, query url.Values, body object) (int, interface{}) {
+	if len(segs) == 0 {
+		switch method {
+		case http.MethodGet:
+			sorted := append([]object{}, s.contacts...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				a, _ := sorted[i]["displayName"].(string)
+				b, _ := sorted[j]["displayName"].(string)
+				return strings.ToLower(a) < strings.ToLower(b)
+			})
+			return http.StatusOK, s.page(sorted, query, path)
+		case http.MethodPost:
+			s.nextID++
+			contact := copyObject(body)
+			contact["id"] = "mock-contact-" + strconv.Itoa(s.nextID)
+			s.contacts = append(s.contacts, contact)
+			return http.StatusCreated, contact
+		}
+		return notImplemented(method, path)
+	}
+	i := -1
+	for j, c := range s.contacts {
+		if c["id"] == segs[0] {
+			i = j
+		}
+	}
+	if i < 0 {
+		return notFound("contact", segs[0])
+	}
+	switch {
+	case len(segs) == 1 && method == http.MethodGet:
+		return http.StatusOK, s.contacts[i]
+	case len(segs) == 1 && method == http.MethodPatch:
+		for k, v := range body {
+			s.contacts[i][k] = v
+		}
+		return http.StatusOK, s.contacts[i]
+	case len(segs) == 1 && method == http.MethodDelete:
+		s.contacts = append(s.contacts[:i], s.contacts[i+1:]...)
+		return http.StatusNoContent, nil
+	}
+	return notImplemented(method, path)
+}
+
+// searchPeople answers a People API $search: the people, then the contacts,
+// with a word of the name or the start of an address beginning with the
+// search text.
+func (s *Server) searchPeople(search string) []object {
+	search = strings.ToLower(strings.Trim(search, `"`))
+	found := []object{}
+	candidates := append([]object{}, s.people...)
+	for _, c := range s.contacts {
+		var scored []interface{}
+		emails, _ := c["emailAddresses"].([]interface{})
+		for _, e := range emails {
+			if a, _ := e.(object)["address"].(string); a != "" {
+				scored = append(scored, object{"address": a, "relevanceScore": 5.0})
+			}
+		}
+		candidates = append(candidates, object{"id": c["id"], "displayName": c["displayName"], "scoredEmailAddresses": scored})
+	}
+	for _, p := range candidates {
+		name, _ := p["displayName"].(string)
+		words := strings.Fields(strings.ToLower(name))
+		scored, _ := p["scoredEmailAddresses"].([]interface{})
+		for _, e := range scored {
+			if a, _ := e.(object)["address"].(string); a != "" {
+				words = append(words, strings.ToLower(a))
+			}
+		}
+		for _, w := range words {
+			if search != "" && (strings.HasPrefix(w, search) || strings.HasPrefix(strings.ToLower(name), search)) {
+				found = append(found, p)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// ---------- Helpers ----------
+
+// page applies $skip and $top to items and links to the next page, if any.
+func (s *Server) page(items []object, query url.Values, path string) object {
+	if items == nil {
+		items = []object{}
+	}
+	skip, _ := strconv.Atoi(query.Get("$skip"))
+	top, err := strconv.Atoi(query.Get("$top"))
+	if err != nil || top <= 0 {
+		top = 10
+	}
+	if skip > len(items) {
+		skip = len(items)
+	}
+	end := skip + top
+	if end > len(items) {
+		end = len(items)
+	}
+	result := object{"value": items[skip:end]}
+	if query.Get("$count") == "true" {
+		result["@odata.count"] = len(items)
+	}
+	if end < len(items) {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("$skip", strconv.Itoa(end))
+		result["@odata.nextLink"] = s.base + path + "?" + next.Encode()
+	}
+	return result
+}
+
+// others returns recipients without the signed-in user, as Reply All
+// addresses them.
+func others(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	keep := []interface{}{}
+	for _, r := range list {
+		if !strings.EqualFold(address(r), userAddress) {
+			keep = append(keep, r)
+		}
+	}
+	return keep
+}
+
+func address(v interface{}) string {
+	r, _ := v.(object)
+	e, _ := r["emailAddress"].(object)
+	a, _ := e["address"].(string)
+	return a
+}
+
+// field returns the named member of a request body. Graph matches property
+// names without regard to case, and the SDK sends some, such as the message
+// of a sendMail call, capitalised.
+func field(o object, name string) interface{} {
+	if v, ok := o[name]; ok {
+		return v
+	}
+	for k, v := range o {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func copyObject(o object) object {
+	c := object{}
+	for k, v := range o {
+		c[k] = v
+	}
+	return c
+}
+
+func truncate(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max])
+	}
+	return s
+}
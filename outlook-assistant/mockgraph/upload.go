@@ -0,0 +1,108 @@
+package mockgraph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ---------- Upload sessions ----------
+
+// uploadPath is where upload session URLs point, under BasePath. Graph hands
+// out URLs on another host that need no token; here they share the server.
+const uploadPath = "/uploads/"
+
+// upload is an attachment upload session in progress.
+type upload struct {
+	messageID string
+	item      object // the attachmentItem it was created with
+	data      []byte
+	received  int64 // bytes received, from the start
+	expires   time.Time
+}
+
+// startUpload opens a session for an attachment of the message messageID.
+// The caller holds s.mu.
+func (s *Server) startUpload(messageID string, item object) object {
+	size, _ := item["size"].(float64)
+	s.nextID++
+	id := "mock-upload-" + strconv.Itoa(s.nextID)
+	u := &upload{messageID: messageID, item: item, data: make([]byte, int64(size)), expires: time.Now().Add(time.Hour)}
+	s.uploads[id] = u
+	return object{
+		"uploadUrl":          s.base + uploadPath + id,
+		"expirationDateTime": u.expires.UTC().Format(time.RFC3339),
+		"nextExpectedRanges": []string{"0-"},
+	}
+}
+
+// serveUpload answers a request to an upload session URL: PUT sends the next
+// slice, with a Content-Range of "bytes first-last/total"; GET reports the
+// ranges still expected; DELETE cancels. Slices must arrive in order, as
+// Outlook requires; one that skips ahead gets 416.
+func (s *Server) serveUpload(r *http.Request, id string) (int, interface{}) {
+	data, err := readRaw(r)
+	if err != nil {
+		return http.StatusBadRequest, graphError("BadRequest", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.uploads[id]
+	if u == nil {
+		return notFound("upload session", id)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return http.StatusOK, u.status()
+	case http.MethodDelete:
+		delete(s.uploads, id)
+		return http.StatusNoContent, nil
+	case http.MethodPut:
+	default:
+		return notImplemented(r.Method, uploadPath+id)
+	}
+
+	if s.FailUploadSlices > 0 {
+		s.FailUploadSlices--
+		return http.StatusServiceUnavailable, graphError("ServiceUnavailable", "the mock Graph server failed this slice on purpose")
+	}
+	var first, last, total int64
+	if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &first, &last, &total); err != nil ||
+		total != int64(len(u.data)) || last < first || last >= total || last-first+1 != int64(len(data)) {
+		return http.StatusBadRequest, graphError("InvalidContentRange", fmt.Sprintf("bad Content-Range %q for %d bytes", r.Header.Get("Content-Range"), len(data)))
+	}
+	if first > u.received {
+		return http.StatusRequestedRangeNotSatisfiable, graphError("InvalidContentRange", fmt.Sprintf("expected bytes from %d, got %d", u.received, first))
+	}
+	copy(u.data[first:], data)
+	if last+1 > u.received {
+		u.received = last + 1
+	}
+	if u.received < total {
+		return http.StatusOK, u.status()
+	}
+
+	delete(s.uploads, id)
+	if i := s.messageIndex(u.messageID); i >= 0 {
+		a := object{
+			"@odata.type":  "#microsoft.graph.fileAttachment",
+			"name":         u.item["name"],
+			"contentType":  u.item["contentType"],
+			"isInline":     false,
+			"contentBytes": base64.StdEncoding.EncodeToString(u.data),
+		}
+		s.attach(s.messages[i], a)
+	}
+	return http.StatusCreated, nil
+}
+
+func (u *upload) status() object {
+	return object{
+		"expirationDateTime": u.expires.UTC().Format(time.RFC3339),
+		"nextExpectedRanges": []string{strconv.FormatInt(u.received, 10) + "-"},
+	}
+}
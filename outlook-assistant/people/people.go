@@ -0,0 +1,210 @@
+// Package people provides functions for looking up people and groups in the
+// organization's directory via the Microsoft Graph API.
+package people
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/groups"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/schema"
+)
+
+// ---------- JSON output types ----------
+
+// Member is the JSON representation of one member of a group.
+type Member struct {
+	Type  string `json:"type"` // user, group, or contact
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+// Expansion is the JSON response for people expand.
+type Expansion struct {
+	Group     Member   `json:"group"`
+	Recursive bool     `json:"recursive"`
+	Members   []Member `json:"members"`
+}
+
+// memberFields are the properties selected for each member.
+var memberFields = []string{"id", "displayName", "mail", "userPrincipalName"}
+
+// ---------- Expand ----------
+
+// Expand lists the members of a distribution list, mail-enabled security
+// group, or Microsoft 365 group, identified by email address, display name,
+// or object ID. Without recursive, nested groups are listed as members; with
+// it, they are expanded and only the people and contacts who would receive a
+// message are listed, each once.
+//
+// Dynamic distribution groups exist only in Exchange, not the directory, and
+// cannot be expanded through Graph.
+func Expand(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, list string, recursive, jsonOutput bool) error {
+	group, err := findGroup(ctx, client, list)
+	if err != nil {
+		return err
+	}
+	result := Expansion{Group: memberOf(group), Recursive: recursive, Members: []Member{}}
+
+	nested := 0
+	if recursive {
+		builder := client.Groups().ByGroupId(result.Group.ID).TransitiveMembers()
+		config := &groups.ItemTransitiveMembersRequestBuilderGetRequestConfiguration{
+			QueryParameters: &groups.ItemTransitiveMembersRequestBuilderGetQueryParameters{Select: memberFields},
+		}
+		for {
+			page, err := builder.Get(ctx, config)
+			if err != nil {
+				return fmt.Errorf("listing members of %s: %w", result.Group.Name, err)
+			}
+			for _, obj := range page.GetValue() {
+				if m := memberOf(obj); m.Type == "group" {
+					nested++
+				} else {
+					result.Members = append(result.Members, m)
+				}
+			}
+			next := page.GetOdataNextLink()
+			if next == nil || *next == "" {
+				break
+			}
+			builder, config = builder.WithUrl(*next), nil
+		}
+	} else {
+		builder := client.Groups().ByGroupId(result.Group.ID).Members()
+		config := &groups.ItemMembersRequestBuilderGetRequestConfiguration{
+			QueryParameters: &groups.ItemMembersRequestBuilderGetQueryParameters{Select: memberFields},
+		}
+		for {
+			page, err := builder.Get(ctx, config)
+			if err != nil {
+				return fmt.Errorf("listing members of %s: %w", result.Group.Name, err)
+			}
+			for _, obj := range page.GetValue() {
+				result.Members = append(result.Members, memberOf(obj))
+			}
+			next := page.GetOdataNextLink()
+			if next == nil || *next == "" {
+				break
+			}
+			builder, config = builder.WithUrl(*next), nil
+		}
+	}
+
+	sort.SliceStable(result.Members, func(i, j int) bool {
+		return strings.ToLower(result.Members[i].Name) < strings.ToLower(result.Members[j].Name)
+	})
+
+	if jsonOutput {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("\n%s <%s>\n", result.Group.Name, result.Group.Email)
+		fmt.Printf("\n%-3s  %-8s  %-30s  %s\n", "#", "Type", "Name", "Email")
+		fmt.Println(strings.Repeat("-", 80))
+		for i, m := range result.Members {
+			fmt.Printf("%-3d  %-8s  %-30s  %s\n", i+1, m.Type, truncate(m.Name, 30), m.Email)
+		}
+	}
+
+	if recursive {
+		slog.Info("Expanded recipients", "recipients", len(result.Members), "nestedGroups", nested)
+	} else {
+		slog.Info("Listed members", "members", len(result.Members))
+		for _, m := range result.Members {
+			if m.Type == "group" {
+				slog.Info("Some members are groups — add --recursive to expand them")
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// findGroup looks list up by email address, then by display name, then as an
+// object ID.
+func findGroup(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, list string) (models.Groupable, error) {
+	if list == "" {
+		return nil, fmt.Errorf("--list is required (group email address, name, or ID)")
+	}
+	quoted := strings.ReplaceAll(list, "'", "''")
+	filter := fmt.Sprintf("displayName eq '%s'", quoted)
+	if strings.Contains(list, "@") {
+		filter = fmt.Sprintf("mail eq '%s'", quoted)
+	}
+	result, err := client.Groups().Get(ctx, &groups.GroupsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &groups.GroupsRequestBuilderGetQueryParameters{
+			Filter: &filter,
+			Select: []string{"id", "displayName", "mail"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking up group %s: %w", list, err)
+	}
+	switch found := result.GetValue(); len(found) {
+	case 1:
+		return found[0], nil
+	case 0:
+		if g, err := client.Groups().ByGroupId(list).Get(ctx, &groups.GroupItemRequestBuilderGetRequestConfiguration{
+			QueryParameters: &groups.GroupItemRequestBuilderGetQueryParameters{
+				Select: []string{"id", "displayName", "mail"},
+			},
+		}); err == nil {
+			return g, nil
+		}
+		return nil, fmt.Errorf("no group %q in the directory (dynamic distribution groups cannot be expanded through Graph)", list)
+	default:
+		var names []string
+		for _, g := range found {
+			names = append(names, fmt.Sprintf("%s <%s>", deref(g.GetDisplayName(), ""), deref(g.GetMail(), "")))
+		}
+		return nil, fmt.Errorf("%q matches %d groups — use the email address: %s", list, len(found), strings.Join(names, ", "))
+	}
+}
+
+// memberOf converts a directory object returned by a members query.
+func memberOf(obj models.DirectoryObjectable) Member {
+	m := Member{ID: deref(obj.GetId(), "")}
+	switch o := obj.(type) {
+	case models.Userable:
+		m.Type, m.Name = "user", deref(o.GetDisplayName(), "")
+		m.Email = deref(o.GetMail(), deref(o.GetUserPrincipalName(), ""))
+	case models.Groupable:
+		m.Type, m.Name, m.Email = "group", deref(o.GetDisplayName(), ""), deref(o.GetMail(), "")
+	case models.OrgContactable:
+		m.Type, m.Name, m.Email = "contact", deref(o.GetDisplayName(), ""), deref(o.GetMail(), "")
+	default:
+		m.Type = strings.TrimPrefix(deref(obj.GetOdataType(), ""), "#microsoft.graph.")
+	}
+	return m
+}
+
+// ---------- Helpers ----------
+
+func printJSON(v interface{}) error {
+	return schema.Encode(os.Stdout, v)
+}
+
+func deref(s *string, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+	return *s
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-1] + "…"
+}
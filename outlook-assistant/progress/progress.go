@@ -0,0 +1,45 @@
+// Package progress reports how far a long-running operation has got, such
+// as an export or a large upload, as status messages through slog. Each
+// Reporter logs at most once per interval, so a folder of thousands of
+// messages yields a line every few seconds rather than one per page, and
+// operations that finish within the interval say nothing at all.
+package progress
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Interval is the least time between two messages from one Reporter.
+const Interval = 3 * time.Second
+
+// Reporter logs progress messages for one operation. It is safe for
+// concurrent use.
+type Reporter struct {
+	msg string
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// New returns a Reporter that logs msg, such as "Exporting messages", with
+// the attributes passed to Report.
+func New(msg string) *Reporter {
+	return &Reporter{msg: msg, last: time.Now()}
+}
+
+// Report logs msg with args, as slog.Info does, when Interval has passed
+// since the Reporter was created or last logged.
+func (r *Reporter) Report(args ...any) {
+	r.mu.Lock()
+	now := time.Now()
+	due := now.Sub(r.last) >= Interval
+	if due {
+		r.last = now
+	}
+	r.mu.Unlock()
+	if due {
+		slog.Info(r.msg, args...)
+	}
+}
@@ -0,0 +1,111 @@
+// Package reldate parses the relative dates that mail and calendar accept
+// wherever they take a date bound such as --since or --before: ages such as
+// 7d or 2w, and day names such as yesterday or last monday. Working them out
+// here spares callers from turning "last week" into a calendar date
+// themselves, which is easy to get wrong.
+package reldate
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// agePattern matches an age such as 12h, 7d, 3w, or 2mo.
+var agePattern = regexp.MustCompile(`(?i)^(\d+)(h|d|w|mo)$`)
+
+// Forms lists the relative forms Parse accepts, for error messages.
+const Forms = "an age such as 12h, 7d, 2w, or 3mo, today, yesterday, tomorrow, or last/next <weekday>"
+
+// Parse returns the time s names relative to now: for an age, the moment
+// that long before now; for a day, its midnight in now's location. ok is
+// false when s is neither.
+func Parse(s string, now time.Time) (t time.Time, ok bool) {
+	if t, ok := Age(s, now); ok {
+		return t, true
+	}
+	return Day(s, now)
+}
+
+// Age returns the moment the age s lies before now, and ok false when s is
+// not an age.
+func Age(s string, now time.Time) (t time.Time, ok bool) {
+	return offset(s, now, -1)
+}
+
+// Ahead returns the moment the interval s, written as an age, lies after
+// now, such as the end of a snooze, and ok false when s is not an age.
+func Ahead(s string, now time.Time) (t time.Time, ok bool) {
+	return offset(s, now, 1)
+}
+
+// offset moves now by the age s, back for sign -1 and ahead for 1.
+func offset(s string, now time.Time, sign int) (time.Time, bool) {
+	m := agePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return time.Time{}, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	n *= sign
+	switch strings.ToLower(m[2]) {
+	case "h":
+		return now.Add(time.Duration(n) * time.Hour), true
+	case "d":
+		return now.AddDate(0, 0, n), true
+	case "w":
+		return now.AddDate(0, 0, 7*n), true
+	default: // mo
+		return now.AddDate(0, n, 0), true
+	}
+}
+
+// Day returns midnight of the day s names, in now's location: today,
+// yesterday, tomorrow, "last monday" (the latest Monday before today), or
+// "next monday" (the first after it). Case and extra spaces are ignored.
+func Day(s string, now time.Time) (t time.Time, ok bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	words := strings.Fields(strings.ToLower(s))
+	switch len(words) {
+	case 1:
+		switch words[0] {
+		case "today":
+			return today, true
+		case "yesterday":
+			return today.AddDate(0, 0, -1), true
+		case "tomorrow":
+			return today.AddDate(0, 0, 1), true
+		}
+	case 2:
+		day, ok := weekday(words[1])
+		if !ok {
+			break
+		}
+		switch words[0] {
+		case "last":
+			back := (int(today.Weekday()) - int(day) + 7) % 7
+			if back == 0 {
+				back = 7
+			}
+			return today.AddDate(0, 0, -back), true
+		case "next":
+			ahead := (int(day) - int(today.Weekday()) + 7) % 7
+			if ahead == 0 {
+				ahead = 7
+			}
+			return today.AddDate(0, 0, ahead), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// weekday parses a weekday name, in full or as its first three letters.
+func weekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,144 @@
+package reldate
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// newYork is a zone with daylight saving time; 2026-03-08 02:00 EST is
+// 03:00 EDT, and 2026-11-01 02:00 EDT is 01:00 EST.
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestAge(t *testing.T) {
+	ny := newYork(t)
+	tests := []struct {
+		name string
+		s    string
+		now  time.Time
+		want time.Time
+		ok   bool
+	}{
+		{"hours", "12h", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC), true},
+		{"days", "7d", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2026, 5, 3, 12, 0, 0, 0, time.UTC), true},
+		{"weeks", "2w", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2026, 4, 26, 12, 0, 0, 0, time.UTC), true},
+		{"months", "3mo", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC), true},
+		{"upper case and spaces", " 2D ", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2026, 5, 8, 12, 0, 0, 0, time.UTC), true},
+		{"zero", "0d", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), true},
+		// AddDate normalizes February 31 to March 3.
+		{"month from the 31st", "1mo", time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC), true},
+		// Across the spring-forward night a day keeps the wall clock but
+		// 24h does not.
+		{"day across DST", "1d", time.Date(2026, 3, 8, 12, 0, 0, 0, ny), time.Date(2026, 3, 7, 12, 0, 0, 0, ny), true},
+		{"hours across DST", "24h", time.Date(2026, 3, 8, 12, 0, 0, 0, ny), time.Date(2026, 3, 7, 11, 0, 0, 0, ny), true},
+		{"empty", "", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"no unit", "7", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"no number", "d", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"negative", "-7d", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"fraction", "1.5d", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"minutes", "30m", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"space before unit", "7 d", time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Age(tt.s, tt.now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("Age(%q) = %v, %v; want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAhead(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+		ok   bool
+	}{
+		{"3h", time.Date(2026, 5, 10, 15, 0, 0, 0, time.UTC), true},
+		{"1w", time.Date(2026, 5, 17, 12, 0, 0, 0, time.UTC), true},
+		{"1mo", time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC), true},
+		{"tomorrow", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Ahead(tt.s, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("Ahead(%q) = %v, %v; want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDay(t *testing.T) {
+	ny := newYork(t)
+	// Wednesday, 2026-05-13.
+	wed := time.Date(2026, 5, 13, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		s    string
+		now  time.Time
+		want time.Time
+		ok   bool
+	}{
+		{"today", "today", wed, time.Date(2026, 5, 13, 0, 0, 0, 0, time.UTC), true},
+		{"yesterday", "yesterday", wed, time.Date(2026, 5, 12, 0, 0, 0, 0, time.UTC), true},
+		{"tomorrow", "tomorrow", wed, time.Date(2026, 5, 14, 0, 0, 0, 0, time.UTC), true},
+		{"case and spaces", "  Last   MONDAY ", wed, time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC), true},
+		{"last same weekday", "last wednesday", wed, time.Date(2026, 5, 6, 0, 0, 0, 0, time.UTC), true},
+		{"next same weekday", "next wed", wed, time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC), true},
+		{"next later weekday", "next fri", wed, time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC), true},
+		{"next earlier weekday", "next monday", wed, time.Date(2026, 5, 18, 0, 0, 0, 0, time.UTC), true},
+		{"last later weekday", "last saturday", wed, time.Date(2026, 5, 9, 0, 0, 0, 0, time.UTC), true},
+		{"across a month", "tomorrow", time.Date(2026, 4, 30, 23, 59, 0, 0, time.UTC), time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"across a year", "yesterday", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), true},
+		// Midnight is in now's zone, on either side of a DST change.
+		{"after spring forward", "yesterday", time.Date(2026, 3, 9, 0, 30, 0, 0, ny), time.Date(2026, 3, 8, 0, 0, 0, 0, ny), true},
+		{"after fall back", "yesterday", time.Date(2026, 11, 2, 0, 30, 0, 0, ny), time.Date(2026, 11, 1, 0, 0, 0, 0, ny), true},
+		{"empty", "", wed, time.Time{}, false},
+		{"unknown word", "someday", wed, time.Time{}, false},
+		{"weekday alone", "monday", wed, time.Time{}, false},
+		{"unknown weekday", "last funday", wed, time.Time{}, false},
+		{"two-letter weekday", "next mo", wed, time.Time{}, false},
+		{"three words", "last monday morning", wed, time.Time{}, false},
+		{"this weekday", "this monday", wed, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Day(tt.s, tt.now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("Day(%q) = %v, %v; want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+			}
+			if ok && got.Location() != tt.now.Location() {
+				t.Errorf("Day(%q) is in %v; want %v", tt.s, got.Location(), tt.now.Location())
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 5, 13, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+		ok   bool
+	}{
+		{"2d", time.Date(2026, 5, 11, 15, 30, 0, 0, time.UTC), true},
+		{"yesterday", time.Date(2026, 5, 12, 0, 0, 0, 0, time.UTC), true},
+		{"2026-05-01", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.s, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("Parse(%q) = %v, %v; want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package retry re-sends Graph requests that were throttled or failed
+// transiently, waiting as long as Graph asks in Retry-After or, without it,
+// with jittered exponential backoff, so a bulk run survives throttling.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	khttp "github.com/microsoft/kiota-http-go"
+)
+
+const (
+	// DefaultMaxRetries is how often a request is re-sent unless configured.
+	DefaultMaxRetries = 5
+	// baseDelay is the wait before the first retry without Retry-After; it
+	// doubles with each further one, up to maxDelay.
+	baseDelay = 2 * time.Second
+	maxDelay  = time.Minute
+)
+
+// Handler is a Kiota middleware that retries a request up to MaxRetries
+// times. A 429 or 503 means Graph did not act on the request, so any request
+// is retried. Other 5xx responses and network errors are retried only for
+// methods that are safe to repeat, so a message is never sent twice.
+//
+// Each re-sent request carries a Retry-Attempt header, which the stats
+// recorder counts. A retry that would outlast the request's context
+// deadline, such as --timeout, is not attempted.
+type Handler struct {
+	MaxRetries int
+}
+
+// New returns a Handler that retries up to maxRetries times; 0 turns
+// retrying off.
+func New(maxRetries int) *Handler {
+	return &Handler{MaxRetries: maxRetries}
+}
+
+// Intercept implements khttp.Middleware.
+func (h *Handler) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := pipeline.Next(req, middlewareIndex)
+		if attempt > h.MaxRetries || !retryable(req, resp, err) || !rewind(req) {
+			return resp, err
+		}
+
+		delay := backoff(attempt)
+		if after, ok := retryAfter(resp); ok {
+			delay = after
+		}
+		reason := "network error"
+		if resp != nil {
+			reason = resp.Status
+		}
+		wait := delay.Round(100 * time.Millisecond).String()
+		ctx := req.Context()
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			slog.Warn("Graph request failed; not retrying, as the wait would pass the time limit", "reason", reason, "wait", wait)
+			return resp, err
+		}
+
+		if resp != nil {
+			// The connection can be reused only once the body is read.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		slog.Warn("Graph request failed; retrying", "reason", reason, "attempt", attempt, "of", h.MaxRetries, "wait", wait)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		req.Header.Set("Retry-Attempt", strconv.Itoa(attempt))
+	}
+}
+
+// retryable reports whether a request that got resp or err may be re-sent.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead ||
+		req.Method == http.MethodPut || req.Method == http.MethodDelete || req.Method == http.MethodOptions
+	if err != nil {
+		return idempotent && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// rewind puts the request body back at its start for another attempt. A
+// body that cannot be replayed cannot be retried.
+func rewind(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if seeker, ok := req.Body.(io.Seeker); ok {
+		_, err := seeker.Seek(0, io.SeekStart)
+		return err == nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return false
+		}
+		req.Body = body
+		return true
+	}
+	return false
+}
+
+// retryAfter reads the wait Graph asks for, in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After"))
+}
+
+// parseRetryAfter reads a Retry-After value, in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Delay is the wait before the given retry of a request Graph answered with
+// the Retry-After value retryAfter: as long as it asks, or without it the
+// same backoff the Handler uses. It is for requests retried outside the
+// Handler, such as the items of a $batch.
+func Delay(retryAfter string, attempt int) time.Duration {
+	if after, ok := parseRetryAfter(retryAfter); ok {
+		return after
+	}
+	return backoff(attempt)
+}
+
+type limitKey struct{}
+
+// WithLimit returns ctx carrying maxRetries, the --max-retries the Handler
+// was given, for the requests retried outside it.
+func WithLimit(ctx context.Context, maxRetries int) context.Context {
+	return context.WithValue(ctx, limitKey{}, maxRetries)
+}
+
+// Limit returns the retry limit ctx carries, or DefaultMaxRetries.
+func Limit(ctx context.Context) int {
+	if n, ok := ctx.Value(limitKey{}).(int); ok {
+		return n
+	}
+	return DefaultMaxRetries
+}
+
+// backoff is the wait before the given retry: the exponential delay, capped,
+// less a random part of up to half of it, so that many clients throttled
+// together do not all come back at once.
+func backoff(attempt int) time.Duration {
+	delay := maxDelay
+	if attempt < 16 {
+		delay = min(baseDelay<<(attempt-1), maxDelay)
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,197 @@
+// Package schema versions the tool's JSON output. Every payload printed with
+// --json carries a schemaVersion, and For describes an output type as a JSON
+// Schema document, so consumers can validate what they receive and notice
+// when its shape changes.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the schemaVersion stamped on every JSON payload. It is raised
+// whenever a field is removed, renamed, or changes type; adding a field does
+// not change it.
+const Version = 1
+
+// Draft is the JSON Schema dialect of the documents returned by For.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Encode writes v to w as indented JSON, stamped with the schema version.
+func Encode(w io.Writer, v interface{}) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Marshal returns v as indented JSON, stamped with the schema version.
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	data, err = Stamp(data)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Stamp adds "schemaVersion" as the first field of a JSON object. Actions
+// that print a list print a bare array, which has nowhere to put it without
+// breaking existing consumers, so there each element object is stamped
+// instead. Other values are returned unchanged.
+func Stamp(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		return stampObject(trimmed), nil
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			if len(item) > 0 && item[0] == '{' {
+				items[i] = stampObject(item)
+			}
+		}
+		if items == nil {
+			return trimmed, nil
+		}
+		return json.Marshal(items)
+	}
+	return data, nil
+}
+
+func stampObject(obj []byte) []byte {
+	field := `{"schemaVersion":` + strconv.Itoa(Version)
+	rest := bytes.TrimSpace(obj[1:])
+	if len(rest) > 0 && rest[0] == '}' {
+		return []byte(field + "}")
+	}
+	return append([]byte(field+","), rest...)
+}
+
+// ---------- JSON Schema ----------
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// For returns a JSON Schema document for the JSON encoding of v, a value of
+// an output type, titled name. Struct types that v refers to are described
+// under $defs; a struct nested inside the payload, including one of v's own
+// type, is not stamped and so has no schemaVersion.
+func For(name string, v interface{}) map[string]interface{} {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	g := &generator{defs: map[string]interface{}{}}
+	var doc map[string]interface{}
+	if t.Kind() != reflect.Struct {
+		doc = g.schemaOf(t)
+	} else {
+		doc = g.object(t)
+		props := doc["properties"].(map[string]interface{})
+		props["schemaVersion"] = map[string]interface{}{"const": Version}
+		doc["required"] = append([]string{"schemaVersion"}, doc["required"].([]string)...)
+	}
+	doc["$schema"] = Draft
+	doc["title"] = name
+	if len(g.defs) > 0 {
+		doc["$defs"] = g.defs
+	}
+	return doc
+}
+
+type generator struct {
+	defs map[string]interface{}
+}
+
+func (g *generator) schemaOf(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if _, seen := g.defs[t.Name()]; !seen {
+			g.defs[t.Name()] = nil // placeholder, in case t refers to itself
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// object describes a struct as encoding/json would encode it: exported
+// fields under their json names, with embedded structs flattened. Fields
+// without omitempty are always present and so are required.
+func (g *generator) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	required := []string{}
+	g.fields(t, props, &required)
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func (g *generator) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s := g.schemaOf(f.Type)
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+		// A nil pointer, slice, or map without omitempty encodes as null.
+		if k := f.Type.Kind(); (k == reflect.Ptr || k == reflect.Slice || k == reflect.Map) && !omitempty {
+			s = map[string]interface{}{"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}}}
+		}
+		props[name] = s
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package stats records Microsoft Graph request statistics for a single
+// invocation so heavy users can see what their calls cost.
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	khttp "github.com/microsoft/kiota-http-go"
+
+	"outlook-assistant/schema"
+)
+
+// Summary is the JSON representation of the statistics for one invocation.
+type Summary struct {
+	Requests      int     `json:"requests"`
+	Retries       int     `json:"retries"`
+	Throttled     int     `json:"throttled"`
+	BytesSent     int64   `json:"bytesSent"`
+	BytesReceived int64   `json:"bytesReceived"`
+	LatencyMs     float64 `json:"latencyMs"`
+}
+
+// Recorder is a Kiota middleware that counts every HTTP request sent to Graph.
+// It must be placed after the retry handler in the pipeline so that each retry
+// attempt is seen as its own request.
+type Recorder struct {
+	mu      sync.Mutex
+	summary Summary
+	latency time.Duration
+}
+
+// New returns an empty Recorder.
+func New() *Recorder {
+	return &Recorder{}
+}
+
+// Intercept implements khttp.Middleware.
+func (r *Recorder) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := pipeline.Next(req, middlewareIndex)
+	elapsed := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary.Requests++
+	r.latency += elapsed
+	// The retry handler tags every re-sent request with Retry-Attempt.
+	if req.Header.Get("Retry-Attempt") != "" {
+		r.summary.Retries++
+	}
+	if req.ContentLength > 0 {
+		r.summary.BytesSent += req.ContentLength
+	}
+	if resp != nil {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			r.summary.Throttled++
+		}
+		if resp.Body != nil {
+			resp.Body = &countingBody{ReadCloser: resp.Body, r: r}
+		}
+	}
+	return resp, err
+}
+
+// Summary returns a snapshot of the statistics recorded so far.
+func (r *Recorder) Summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.summary
+	s.LatencyMs = float64(r.latency.Microseconds()) / 1000
+	return s
+}
+
+// Reset clears the statistics, so a recorder kept between commands by a
+// server counts each command on its own.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary = Summary{}
+	r.latency = 0
+}
+
+// Report writes the statistics to w, as a single JSON line when jsonOutput is
+// set and as a short human-readable block otherwise.
+func (r *Recorder) Report(w io.Writer, jsonOutput bool) {
+	s := r.Summary()
+	if jsonOutput {
+		b, _ := json.Marshal(struct {
+			Stats Summary `json:"stats"`
+		}{s})
+		b, _ = schema.Stamp(b)
+		fmt.Fprintln(w, string(b))
+		return
+	}
+	fmt.Fprintf(w, "Graph requests : %d (%d retries, %d throttled)\n", s.Requests, s.Retries, s.Throttled)
+	fmt.Fprintf(w, "Bytes          : %d sent, %d received\n", s.BytesSent, s.BytesReceived)
+	fmt.Fprintf(w, "Latency        : %.0f ms total\n", s.LatencyMs)
+}
+
+// countingBody adds the bytes read from a response body to the recorder.
+type countingBody struct {
+	io.ReadCloser
+	r *Recorder
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.r.mu.Lock()
+		b.r.summary.BytesReceived += int64(n)
+		b.r.mu.Unlock()
+	}
+	return n, err
+}
@@ -0,0 +1,312 @@
+package subscribe
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/schema"
+)
+
+// ---------- Listen ----------
+
+// Notification is one change Graph reported, as printed or forwarded by
+// listen.
+type Notification struct {
+	SubscriptionID string `json:"subscriptionId"`
+	ChangeType     string `json:"changeType"`         // created, updated, or deleted
+	Kind           string `json:"kind,omitempty"`     // message or event
+	ID             string `json:"id,omitempty"`       // the message or event ID, usable as --ref
+	Resource       string `json:"resource,omitempty"` // Graph's path to the item
+	ReceivedAt     string `json:"receivedAt"`         // RFC 3339
+}
+
+// ListenOptions controls Listen.
+type ListenOptions struct {
+	Addr     string // address to listen on, such as 127.0.0.1:8765
+	CertFile string // with KeyFile, serve HTTPS instead of HTTP
+	KeyFile  string
+	Forward  string // POST each notification to this URL instead of printing it
+}
+
+const (
+	// renewEvery is how often listen checks its subscriptions' expiry, and
+	// renewBefore how close to expiry one is renewed.
+	renewEvery  = 10 * time.Minute
+	renewBefore = 12 * time.Hour
+	// maxBody caps a notification request; Graph batches at most a few
+	// hundred changes into one.
+	maxBody = 4 << 20
+)
+
+// listener answers Graph's requests to the notification URL.
+type listener struct {
+	mu      sync.Mutex
+	secrets map[string]string // client state by subscription ID
+	events  chan Notification
+}
+
+// rawNotification is one entry of the value array Graph posts.
+type rawNotification struct {
+	SubscriptionID string `json:"subscriptionId"`
+	ClientState    string `json:"clientState"`
+	ChangeType     string `json:"changeType"`
+	Resource       string `json:"resource"`
+	ResourceData   struct {
+		ODataType string `json:"@odata.type"`
+		ID        string `json:"id"`
+	} `json:"resourceData"`
+}
+
+// Listen receives notifications for the subscriptions created with Create,
+// answering Graph's validation request when one is created. Each notification
+// whose client state matches is printed, as one NDJSON line with jsonOutput,
+// or posted to opts.Forward. Graph needs an HTTPS URL, so Addr is either
+// served with a certificate or reached through a tunnel that terminates TLS.
+// While it runs, Listen renews the subscriptions saved for the selected
+// mailbox before they expire. It returns when ctx is cancelled.
+func Listen(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, opts ListenOptions, jsonOutput bool) error {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+	l := &listener{secrets: map[string]string{}, events: make(chan Notification, 1000)}
+	if err := l.reload(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", opts.Addr, err)
+	}
+	server := &http.Server{Handler: l, ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	scheme := "http"
+	if opts.CertFile != "" {
+		scheme = "https"
+		go func() { served <- server.ServeTLS(ln, opts.CertFile, opts.KeyFile) }()
+	} else {
+		go func() { served <- server.Serve(ln) }()
+	}
+	slog.Info("Listening for Graph notifications — press Ctrl+C to stop", "url", scheme+"://"+ln.Addr().String())
+
+	delivered := make(chan struct{})
+	go func() {
+		defer close(delivered)
+		deliver(l.events, opts.Forward, jsonOutput)
+	}()
+	go keepRenewed(ctx, client)
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("serving notifications: %w", err)
+	case <-ctx.Done():
+	}
+	// Once Shutdown returns no handler is running, so nothing more is queued.
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = server.Shutdown(shutdown)
+	close(l.events)
+	<-delivered
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("stopping the listener: %w", err)
+	}
+	slog.Info("Stopped listening for Graph notifications")
+	return nil
+}
+
+// reload reads the client states of the saved subscriptions.
+func (l *listener) reload() error {
+	entries, err := loadSaved()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range entries {
+		l.secrets[e.ID] = e.ClientState
+	}
+	return nil
+}
+
+// verified reports whether n carries the client state saved for its
+// subscription. A subscription created after Listen started is looked up
+// again on disk.
+func (l *listener) verified(n rawNotification) bool {
+	l.mu.Lock()
+	secret, ok := l.secrets[n.SubscriptionID]
+	l.mu.Unlock()
+	if !ok {
+		if err := l.reload(); err != nil {
+			slog.Warn("Could not read saved subscriptions", "error", err)
+		}
+		l.mu.Lock()
+		secret, ok = l.secrets[n.SubscriptionID]
+		l.mu.Unlock()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(secret), []byte(n.ClientState)) == 1
+}
+
+// ServeHTTP answers a validation request by echoing its token, and a
+// notification by accepting it at once and queuing its changes, as Graph
+// drops a subscription whose URL answers slowly.
+func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get("validationToken"); token != "" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		io.WriteString(w, token)
+		slog.Info("Answered Graph's validation request")
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "notifications are POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Value []rawNotification `json:"value"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBody)).Decode(&body); err != nil {
+		http.Error(w, "invalid notification: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, n := range body.Value {
+		if !l.verified(n) {
+			slog.Warn("Ignored a notification with an unknown subscription or client state", "subscription", n.SubscriptionID)
+			continue
+		}
+		event := Notification{
+			SubscriptionID: n.SubscriptionID,
+			ChangeType:     n.ChangeType,
+			Kind:           kindOf(n.ResourceData.ODataType),
+			ID:             n.ResourceData.ID,
+			Resource:       n.Resource,
+			ReceivedAt:     now,
+		}
+		select {
+		case l.events <- event:
+		default:
+			slog.Warn("Dropped a notification: too many waiting to be delivered", "id", event.ID)
+		}
+	}
+}
+
+// kindOf names the kind of item a resourceData @odata.type describes.
+func kindOf(odataType string) string {
+	name := strings.ToLower(odataType[strings.LastIndex(odataType, ".")+1:])
+	switch name {
+	case "message", "event":
+		return name
+	}
+	return ""
+}
+
+// deliver prints or forwards each notification in order until events is
+// closed.
+func deliver(events <-chan Notification, forward string, jsonOutput bool) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	for n := range events {
+		if forward != "" {
+			if err := post(httpClient, forward, n); err != nil {
+				slog.Warn("Forwarding a notification failed", "id", n.ID, "error", err)
+			}
+			continue
+		}
+		if !jsonOutput {
+			fmt.Printf("%-8s %-8s %s\n", n.ChangeType, n.Kind, n.ID)
+			continue
+		}
+		data, err := stamped(n)
+		if err == nil {
+			_, err = os.Stdout.Write(append(data, '\n'))
+		}
+		if err != nil {
+			slog.Warn("Printing a notification failed", "error", err)
+		}
+	}
+}
+
+// post sends a notification to a webhook as JSON.
+func post(httpClient *http.Client, url string, n Notification) error {
+	data, err := stamped(n)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// stamped is a notification as compact JSON with the schema version.
+func stamped(n Notification) ([]byte, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Stamp(data)
+}
+
+// keepRenewed renews the saved subscriptions of the selected mailbox that are
+// close to expiry, now and every renewEvery until ctx is cancelled.
+func keepRenewed(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) {
+	ticker := time.NewTicker(renewEvery)
+	defer ticker.Stop()
+	for {
+		renewDue(ctx, client)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func renewDue(ctx context.Context, client *msgraphsdkgo.GraphServiceClient) {
+	entries, err := loadSaved()
+	if err != nil {
+		slog.Warn("Could not read saved subscriptions", "error", err)
+		return
+	}
+	changed := false
+	for i, e := range entries {
+		expires, err := time.Parse(time.RFC3339, e.Expires)
+		if e.Mailbox != mailbox.User() || (err == nil && time.Until(expires) > renewBefore) {
+			continue
+		}
+		sub, err := renew(ctx, client, e.ID, DefaultExpiry)
+		if err != nil {
+			slog.Warn("Renewing subscription failed", "id", e.ID, "error", err)
+			continue
+		}
+		entries[i].Expires = rfc3339(sub.GetExpirationDateTime())
+		changed = true
+		slog.Info("Subscription renewed", "id", e.ID, "expires", formatTime(sub.GetExpirationDateTime()))
+	}
+	if changed {
+		if err := storeSaved(entries); err != nil {
+			slog.Warn("Could not save renewed subscriptions", "error", err)
+		}
+	}
+}
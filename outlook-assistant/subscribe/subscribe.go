@@ -0,0 +1,450 @@
+// Package subscribe manages Microsoft Graph change-notification subscriptions
+// for mail and calendar changes, and receives their notifications, so an
+// assistant hears about new mail as it arrives instead of polling for it.
+package subscribe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+
+	msgraphsdkgo "github.com/microsoftgraph/msgraph-sdk-go"
+
+	"outlook-assistant/mailbox"
+	"outlook-assistant/schema"
+)
+
+// DefaultExpiry is how long a new or renewed subscription lasts unless
+// configured; Graph allows up to a little under seven days for mail and
+// events.
+const DefaultExpiry = 72 * time.Hour
+
+// ---------- JSON output types ----------
+
+// SubscriptionSummary is the JSON representation of a subscription.
+type SubscriptionSummary struct {
+	Index           int    `json:"index,omitempty"`
+	ID              string `json:"id"`
+	Resource        string `json:"resource"`
+	ChangeType      string `json:"changeType"`
+	NotificationURL string `json:"notificationUrl"`
+	Expires         string `json:"expirationDateTime"`
+	// Local is set for subscriptions created here, whose client state is
+	// saved, so that listen accepts their notifications.
+	Local bool `json:"local"`
+}
+
+// ---------- Saved subscriptions (stored in home directory) ----------
+
+// saved is a subscription created by this tool. ClientState is the secret
+// Graph sends back with every notification, which proves it came from Graph.
+type saved struct {
+	ID              string `json:"id"`
+	Mailbox         string `json:"mailbox,omitempty"`
+	Resource        string `json:"resource"`
+	ChangeType      string `json:"changeType"`
+	NotificationURL string `json:"notificationUrl"`
+	ClientState     string `json:"clientState"`
+	Expires         string `json:"expirationDateTime"` // RFC 3339
+}
+
+func storePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".outlook-assistant-subscriptions.json")
+}
+
+func loadSaved() ([]saved, error) {
+	data, err := os.ReadFile(storePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading subscriptions: %w", err)
+	}
+	var entries []saved
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing subscriptions %s: %w", storePath(), err)
+	}
+	return entries, nil
+}
+
+func storeSaved(entries []saved) error {
+	if len(entries) == 0 {
+		if err := os.Remove(storePath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("clearing subscriptions: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(storePath(), data, 0600); err != nil {
+		return fmt.Errorf("writing subscriptions: %w", err)
+	}
+	return nil
+}
+
+// ---------- ID cache (stored in home directory) ----------
+
+func idCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, mailbox.CacheFile(".outlook-assistant-subscribe-cache.json"))
+}
+
+func saveIDCache(ids []string) {
+	data, _ := json.Marshal(ids)
+	_ = os.WriteFile(idCachePath(), data, 0600)
+}
+
+func loadIDCache() []string {
+	data, err := os.ReadFile(idCachePath())
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	_ = json.Unmarshal(data, &ids)
+	return ids
+}
+
+func resolveSubscriptionID(ref string) (string, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		ids := loadIDCache()
+		if ids == nil {
+			return "", fmt.Errorf("no cached subscription list — run `subscribe list` first")
+		}
+		if n < 1 || n > len(ids) {
+			return "", fmt.Errorf("index %d out of range (last list had %d subscriptions)", n, len(ids))
+		}
+		return ids[n-1], nil
+	}
+	return ref, nil
+}
+
+// ---------- Create ----------
+
+// CreateOptions describes a subscription to create.
+type CreateOptions struct {
+	Resource        string        // mail or calendar
+	FolderID        string        // mail only: the folder to watch; "" for every folder
+	ChangeTypes     string        // comma-separated: created, updated, deleted
+	NotificationURL string        // public HTTPS URL that reaches listen
+	Expiry          time.Duration // lifetime; DefaultExpiry when 0
+}
+
+// Create subscribes to changes in the selected mailbox's mail or calendar.
+// Graph checks NotificationURL before it answers, so listen must already be
+// reachable there.
+func Create(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, opts CreateOptions, jsonOutput bool) error {
+	resource, err := resourcePath(opts.Resource, opts.FolderID)
+	if err != nil {
+		return err
+	}
+	changeTypes := opts.ChangeTypes
+	if changeTypes == "" {
+		changeTypes = "created,updated,deleted"
+	}
+	for _, t := range strings.Split(changeTypes, ",") {
+		switch strings.TrimSpace(t) {
+		case "created", "updated", "deleted":
+		default:
+			return fmt.Errorf("unknown change type %q — valid types: created, updated, deleted", t)
+		}
+	}
+	if opts.NotificationURL == "" {
+		return fmt.Errorf("--notification-url is required: the public HTTPS URL where subscribe listen receives notifications")
+	}
+	if opts.Expiry <= 0 {
+		opts.Expiry = DefaultExpiry
+	}
+
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("generating client state: %w", err)
+	}
+	clientState := hex.EncodeToString(secret)
+	expires := time.Now().Add(opts.Expiry).UTC()
+
+	sub := models.NewSubscription()
+	sub.SetResource(&resource)
+	sub.SetChangeType(&changeTypes)
+	sub.SetNotificationUrl(&opts.NotificationURL)
+	sub.SetClientState(&clientState)
+	sub.SetExpirationDateTime(&expires)
+
+	created, err := client.Subscriptions().Post(ctx, sub, nil)
+	if err != nil {
+		return fmt.Errorf("creating subscription: %w", err)
+	}
+
+	entry := saved{
+		ID:              deref(created.GetId(), ""),
+		Mailbox:         mailbox.User(),
+		Resource:        resource,
+		ChangeType:      changeTypes,
+		NotificationURL: opts.NotificationURL,
+		ClientState:     clientState,
+		Expires:         rfc3339(created.GetExpirationDateTime()),
+	}
+	entries, err := loadSaved()
+	if err != nil {
+		return err
+	}
+	if err := storeSaved(append(entries, entry)); err != nil {
+		return err
+	}
+
+	summary := summaryOf(created)
+	summary.Local = true
+	if jsonOutput {
+		return printJSON(summary)
+	}
+	slog.Info("Subscription created", "id", summary.ID, "resource", summary.Resource, "expires", summary.Expires)
+	return nil
+}
+
+// resourcePath is the Graph resource a subscription watches.
+func resourcePath(resource, folderID string) (string, error) {
+	switch resource {
+	case "mail":
+		if folderID == "" {
+			return mailbox.Path() + "/messages", nil
+		}
+		return mailbox.Path() + "/mailFolders('" + folderID + "')/messages", nil
+	case "calendar":
+		return mailbox.Path() + "/events", nil
+	case "":
+		return "", fmt.Errorf("--resource is required: mail or calendar")
+	default:
+		return "", fmt.Errorf("unknown resource %q — valid resources: mail, calendar", resource)
+	}
+}
+
+// ---------- List ----------
+
+// List prints the subscriptions Graph holds for this app and user, and drops
+// saved ones Graph no longer has, such as those that expired.
+func List(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, jsonOutput bool) error {
+	result, err := client.Subscriptions().Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("listing subscriptions: %w", err)
+	}
+
+	entries, err := loadSaved()
+	if err != nil {
+		return err
+	}
+	local := map[string]bool{}
+	for _, e := range entries {
+		local[e.ID] = true
+	}
+
+	live := map[string]bool{}
+	ids := []string{}
+	summaries := []SubscriptionSummary{}
+	for i, sub := range result.GetValue() {
+		s := summaryOf(sub)
+		s.Index = i + 1
+		s.Local = local[s.ID]
+		live[s.ID] = true
+		ids = append(ids, s.ID)
+		summaries = append(summaries, s)
+	}
+	saveIDCache(ids)
+
+	kept := entries[:0]
+	for _, e := range entries {
+		// Another mailbox's subscriptions may not be visible from this one.
+		if live[e.ID] || e.Mailbox != mailbox.User() {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) < len(entries) {
+		slog.Info("Forgot subscriptions Graph no longer has", "count", len(entries)-len(kept))
+		if err := storeSaved(kept); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(summaries)
+	}
+	if len(summaries) == 0 {
+		fmt.Println("No subscriptions.")
+		return nil
+	}
+	fmt.Printf("\n%-3s  %-40s  %-17s  %-24s  %s\n", "#", "Resource", "Expires (UTC)", "Changes", "Notification URL")
+	fmt.Println(strings.Repeat("-", 120))
+	for _, s := range summaries {
+		fmt.Printf("%-3d  %-40s  %-17s  %-24s  %s\n", s.Index, truncate(s.Resource, 40), s.Expires, s.ChangeType, s.NotificationURL)
+	}
+	return nil
+}
+
+// ---------- Renew ----------
+
+// Renew extends a subscription to expiry from now. Without ref, every
+// subscription saved for the selected mailbox is renewed.
+func Renew(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string, expiry time.Duration, jsonOutput bool) error {
+	if expiry <= 0 {
+		expiry = DefaultExpiry
+	}
+	entries, err := loadSaved()
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	if ref != "" {
+		id, err := resolveSubscriptionID(ref)
+		if err != nil {
+			return err
+		}
+		ids = []string{id}
+	} else {
+		for _, e := range entries {
+			if e.Mailbox == mailbox.User() {
+				ids = append(ids, e.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("no saved subscriptions to renew — create one with `subscribe create`, or give --ref")
+		}
+	}
+
+	summaries := []SubscriptionSummary{}
+	var failed []string
+	for _, id := range ids {
+		sub, err := renew(ctx, client, id, expiry)
+		if err != nil {
+			slog.Error("Renewing subscription failed", "id", id, "error", err)
+			failed = append(failed, id)
+			continue
+		}
+		s := summaryOf(sub)
+		for i := range entries {
+			if entries[i].ID == id {
+				entries[i].Expires = rfc3339(sub.GetExpirationDateTime())
+				s.Local = true
+			}
+		}
+		summaries = append(summaries, s)
+		if !jsonOutput {
+			slog.Info("Subscription renewed", "id", id, "expires", s.Expires)
+		}
+	}
+	if err := storeSaved(entries); err != nil {
+		return err
+	}
+	if jsonOutput {
+		if err := printJSON(summaries); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d subscriptions could not be renewed: %s", len(failed), len(ids), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// renew moves a subscription's expiry to expiry from now.
+func renew(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, id string, expiry time.Duration) (models.Subscriptionable, error) {
+	expires := time.Now().Add(expiry).UTC()
+	patch := models.NewSubscription()
+	patch.SetExpirationDateTime(&expires)
+	return client.Subscriptions().BySubscriptionId(id).Patch(ctx, patch, nil)
+}
+
+// ---------- Delete ----------
+
+// Delete removes a subscription from Graph and forgets it. One Graph no
+// longer has is forgotten too.
+func Delete(ctx context.Context, client *msgraphsdkgo.GraphServiceClient, ref string) error {
+	id, err := resolveSubscriptionID(ref)
+	if err != nil {
+		return err
+	}
+	err = client.Subscriptions().BySubscriptionId(id).Delete(ctx, nil)
+	var odataErr *odataerrors.ODataError
+	gone := errors.As(err, &odataErr) && odataErr.ResponseStatusCode == http.StatusNotFound
+	if err != nil && !gone {
+		return fmt.Errorf("deleting subscription: %w", err)
+	}
+
+	entries, lerr := loadSaved()
+	if lerr != nil {
+		return lerr
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	if err := storeSaved(kept); err != nil {
+		return err
+	}
+	if gone {
+		slog.Warn("Graph no longer had the subscription; forgot it", "id", id)
+		return nil
+	}
+	slog.Info("Subscription deleted", "id", id)
+	return nil
+}
+
+// ---------- Helpers ----------
+
+func summaryOf(sub models.Subscriptionable) SubscriptionSummary {
+	return SubscriptionSummary{
+		ID:              deref(sub.GetId(), ""),
+		Resource:        deref(sub.GetResource(), ""),
+		ChangeType:      deref(sub.GetChangeType(), ""),
+		NotificationURL: deref(sub.GetNotificationUrl(), ""),
+		Expires:         formatTime(sub.GetExpirationDateTime()),
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+func rfc3339(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func printJSON(v interface{}) error {
+	return schema.Encode(os.Stdout, v)
+}
+
+func deref(s *string, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+	return *s
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-1] + "…"
+}